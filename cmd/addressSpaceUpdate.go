@@ -33,10 +33,15 @@ func (m *RegistrationImpl) TrackAddressSpaceSizeUpdates(interval time.Duration,
 			jww.INFO.Print("Stopping address space size update tracker.")
 			return
 		case <-ticker.C:
-			latestAddressSpace, err = m.updateAddressSpace(latestAddressSpace,
-				store)
-			if err != nil {
+			var updated ndf.AddressSpace
+			updated, err = m.updateAddressSpace(latestAddressSpace, store)
+			if err != nil && store.IsDegraded() {
+				jww.WARN.Printf("Skipping address space size update while "+
+					"storage is degraded: %+v", err)
+			} else if err != nil {
 				jww.FATAL.Panic(err)
+			} else {
+				latestAddressSpace = updated
 			}
 		}
 	}
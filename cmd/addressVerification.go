@@ -0,0 +1,187 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Handles verifying the addresses nodes report in their polls before they are
+// accepted into the NDF
+
+package cmd
+
+import (
+	"github.com/pkg/errors"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/elixxir/registration/storage/node"
+	"net"
+	"strings"
+)
+
+// Looks up the host names of an IP address, replaced in testing
+var lookupAddr = net.LookupAddr
+
+// addressAllowlist holds the address ranges and domains reported addresses
+// must belong to
+type addressAllowlist struct {
+	ranges  []*net.IPNet
+	domains []string
+}
+
+// parseAddressAllowlist parses a list of CIDR ranges and domains. A domain
+// allows itself and all of its subdomains.
+func parseAddressAllowlist(entries []string) (*addressAllowlist, error) {
+	allowlist := &addressAllowlist{}
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if strings.Contains(entry, "/") {
+			_, ipNet, err := net.ParseCIDR(entry)
+			if err != nil {
+				return nil, errors.Errorf("Invalid address allowlist range "+
+					"%q: %+v", entry, err)
+			}
+			allowlist.ranges = append(allowlist.ranges, ipNet)
+			continue
+		}
+		allowlist.domains = append(allowlist.domains,
+			strings.ToLower(strings.TrimSuffix(entry, ".")))
+	}
+	return allowlist, nil
+}
+
+// allowsDomain returns true if the domain is an allowlisted domain or one of
+// their subdomains.
+func (al *addressAllowlist) allowsDomain(domain string) bool {
+	domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+	for _, allowed := range al.domains {
+		if domain == allowed || strings.HasSuffix(domain, "."+allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// allows returns true if the host is in an allowlisted range or domain. IP
+// addresses outside every range are allowed if their reverse DNS names a host
+// in an allowlisted domain.
+func (al *addressAllowlist) allows(host string) bool {
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return al.allowsDomain(host)
+	}
+	for _, ipNet := range al.ranges {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	if len(al.domains) == 0 {
+		return false
+	}
+	names, err := lookupAddr(host)
+	if err != nil {
+		return false
+	}
+	for _, name := range names {
+		if al.allowsDomain(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// addressHost returns the host of an address, which may lack a port.
+func addressHost(address string) string {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return address
+	}
+	return host
+}
+
+// verifyReportedAddresses checks the addresses the node reported which differ
+// from the ones it is known by. Each must be in the address allowlist, if
+// one is configured, and when requireRegisteredAddresses is set, must be on
+// the same host as the address stored for the node. Public addresses must be
+// on the host of either stored address. An error is returned for the first
+// address which fails, so that none of them are accepted.
+func (m *RegistrationImpl) verifyReportedAddresses(n *node.State, nodeAddress,
+	gatewayAddress, publicAddress string) error {
+	if m.addressAllowlist == nil && !m.params.requireRegisteredAddresses {
+		return nil
+	}
+
+	type reported struct {
+		kind, address string
+		registered    []string
+	}
+	var changed []reported
+	if nodeAddress != n.GetNodeAddresses() {
+		changed = append(changed, reported{kind: "node", address: nodeAddress})
+	}
+	if gatewayAddress != n.GetGatewayAddress() {
+		changed = append(changed, reported{kind: "gateway",
+			address: gatewayAddress})
+	}
+	if publicAddress != "" && publicAddress != n.GetPublicAddress() {
+		changed = append(changed, reported{kind: "public",
+			address: publicAddress})
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+
+	if m.params.requireRegisteredAddresses {
+		dbNode, err := storage.PermissioningDb.GetNodeById(n.GetID())
+		if err != nil {
+			return errors.WithMessagef(err, "Failed to look up the "+
+				"registered addresses of node %s", n.GetID())
+		}
+		for i := range changed {
+			switch changed[i].kind {
+			case "node":
+				changed[i].registered = []string{dbNode.ServerAddress}
+			case "gateway":
+				changed[i].registered = []string{dbNode.GatewayAddress}
+			default:
+				changed[i].registered = []string{dbNode.ServerAddress,
+					dbNode.GatewayAddress}
+			}
+		}
+	}
+
+	for _, r := range changed {
+		if r.address == "" {
+			continue
+		}
+		host := addressHost(r.address)
+		if m.addressAllowlist != nil && !m.addressAllowlist.allows(host) {
+			return errors.Errorf("Reported %s address %s of node %s is not "+
+				"in the address allowlist", r.kind, r.address, n.GetID())
+		}
+		if !matchesRegisteredHost(host, r.registered) {
+			return errors.Errorf("Reported %s address %s of node %s is not "+
+				"on the host it is registered with", r.kind, r.address,
+				n.GetID())
+		}
+	}
+	return nil
+}
+
+// matchesRegisteredHost returns true if the host is the host of one of the
+// registered addresses, or no address is registered.
+func matchesRegisteredHost(host string, registered []string) bool {
+	known := false
+	for _, address := range registered {
+		if address == "" {
+			continue
+		}
+		known = true
+		if strings.EqualFold(addressHost(address), host) {
+			return true
+		}
+	}
+	return !known
+}
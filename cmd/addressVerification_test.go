@@ -0,0 +1,139 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package cmd
+
+import (
+	"github.com/pkg/errors"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/elixxir/registration/storage/node"
+	"gitlab.com/xx_network/comms/connect"
+	"testing"
+)
+
+// Sets up a node registered at the advertised address for address
+// verification, returning its host
+func setupAddressVerificationTest(t *testing.T) (*RegistrationImpl,
+	*node.State, *connect.Host) {
+	impl, n := setupObservedAddressTest(false, t)
+	err := storage.PermissioningDb.InsertApplication(
+		&storage.Application{Id: 1}, &storage.Node{Code: "AAAA",
+			Id: n.GetID().Marshal(), ServerAddress: testAdvertisedAddr,
+			GatewayAddress: "1.2.3.4:22840", ApplicationId: 1})
+	if err != nil {
+		t.Fatalf("Failed to insert node: %+v", err)
+	}
+	nodeHost, err := connect.NewHost(n.GetID(), testAdvertisedAddr, nil,
+		connect.GetDefaultHostParams())
+	if err != nil {
+		t.Fatalf("Failed to create host: %+v", err)
+	}
+	return impl, n, nodeHost
+}
+
+// Tests that the allowlist accepts hosts in its ranges and domains, and IP
+// addresses whose reverse DNS is in its domains.
+func TestAddressAllowlist_Allows(t *testing.T) {
+	allowlist, err := parseAddressAllowlist([]string{"10.0.0.0/8",
+		"2001:db8::/32", "nodes.example.com."})
+	if err != nil {
+		t.Fatalf("parseAddressAllowlist() produced an error: %+v", err)
+	}
+	defer func(lookup func(string) ([]string, error)) { lookupAddr = lookup }(lookupAddr)
+	lookupAddr = func(addr string) ([]string, error) {
+		switch addr {
+		case "9.9.9.9":
+			return []string{"a.nodes.example.com."}, nil
+		case "8.8.8.8":
+			return []string{"dns.google."}, nil
+		}
+		return nil, errors.New("no such host")
+	}
+
+	tests := map[string]bool{
+		"10.1.2.3":             true,
+		"11.1.2.3":             false,
+		"2001:db8::1":          true,
+		"nodes.example.com":    true,
+		"b.NODES.example.com":  true,
+		"badnodes.example.com": false,
+		"9.9.9.9":              true,
+		"8.8.8.8":              false,
+		"7.7.7.7":              false,
+	}
+	for host, expected := range tests {
+		if allowed := allowlist.allows(host); allowed != expected {
+			t.Errorf("Unexpected verdict for %s.\nexpected: %t\nreceived: %t",
+				host, expected, allowed)
+		}
+	}
+
+	if _, err = parseAddressAllowlist([]string{"10.0.0.0/33"}); err == nil {
+		t.Errorf("parseAddressAllowlist() accepted an invalid range.")
+	}
+}
+
+// Tests that an address outside the allowlist is rejected without changing
+// the node's addresses or the NDF, and that one inside it is accepted.
+func TestCheckIPAddresses_Allowlist(t *testing.T) {
+	impl, n, nodeHost := setupAddressVerificationTest(t)
+	impl.addressAllowlist, _ = parseAddressAllowlist([]string{"1.2.3.0/24"})
+
+	err := checkIPAddresses(impl, n, newAddressPoll("5.6.7.8:11420", ""),
+		nodeHost)
+	if err == nil {
+		t.Errorf("checkIPAddresses() accepted an address outside the " +
+			"allowlist.")
+	}
+	if n.GetNodeAddresses() != testAdvertisedAddr {
+		t.Errorf("Rejected address was stored: %q", n.GetNodeAddresses())
+	}
+	if addr := impl.State.GetUnprunedNdf().Nodes[0].Address; addr != testAdvertisedAddr {
+		t.Errorf("Rejected address was added to the NDF: %q", addr)
+	}
+
+	err = checkIPAddresses(impl, n, newAddressPoll("1.2.3.5:11420", ""),
+		nodeHost)
+	if err != nil {
+		t.Fatalf("checkIPAddresses() rejected an address in the "+
+			"allowlist: %+v", err)
+	}
+	if addr := impl.State.GetUnprunedNdf().Nodes[0].Address; addr != "1.2.3.5:11420" {
+		t.Errorf("Allowlisted address was not added to the NDF: %q", addr)
+	}
+}
+
+// Tests that when reported addresses must be on the registered hosts, only
+// their ports may change.
+func TestCheckIPAddresses_RegisteredHost(t *testing.T) {
+	impl, n, nodeHost := setupAddressVerificationTest(t)
+	impl.params.requireRegisteredAddresses = true
+
+	err := checkIPAddresses(impl, n,
+		newAddressPoll("5.6.7.8:11420", testPublicAddr), nodeHost)
+	if err == nil {
+		t.Errorf("checkIPAddresses() accepted an address off the registered " +
+			"host.")
+	}
+	if n.GetNodeAddresses() != testAdvertisedAddr || n.GetPublicAddress() != "" {
+		t.Errorf("Rejected addresses were stored: %q, %q",
+			n.GetNodeAddresses(), n.GetPublicAddress())
+	}
+	if addr := impl.State.GetUnprunedNdf().Nodes[0].Address; addr != testAdvertisedAddr {
+		t.Errorf("Rejected address was added to the NDF: %q", addr)
+	}
+
+	err = checkIPAddresses(impl, n, newAddressPoll("1.2.3.4:11421", ""),
+		nodeHost)
+	if err != nil {
+		t.Fatalf("checkIPAddresses() rejected a new port on the registered "+
+			"host: %+v", err)
+	}
+	if n.GetNodeAddresses() != "1.2.3.4:11421" {
+		t.Errorf("New port was not stored: %q", n.GetNodeAddresses())
+	}
+}
@@ -0,0 +1,250 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Handles automatically unbanning banned nodes which meet an operator defined
+// condition
+
+package cmd
+
+import (
+	"github.com/pkg/errors"
+	jww "github.com/spf13/jwalterweatherman"
+	"gitlab.com/elixxir/primitives/version"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/elixxir/registration/storage/node"
+	"gitlab.com/xx_network/comms/connect"
+	"gitlab.com/xx_network/primitives/id"
+	"sync"
+)
+
+// UnbanCondition is a condition under which a banned node is automatically
+// unbanned. Conditions are evaluated against every banned node each time the
+// banned nodes are checked, and a node meeting any of them is unbanned.
+type UnbanCondition interface {
+	// Name describes the condition in logs
+	Name() string
+	// Met returns true if the banned node meets the condition
+	Met(m *RegistrationImpl, n *node.State) bool
+}
+
+// versionReached is met once the node reports versions at or above the
+// required ones
+type versionReached struct {
+	server, gateway version.Version
+	// Whether a gateway version is required
+	checkGateway bool
+}
+
+// NewVersionReachedCondition returns a condition met once the banned node
+// reports a server version at or above serverVersion and, if gatewayVersion is
+// not empty, a gateway version at or above it.
+func NewVersionReachedCondition(serverVersion, gatewayVersion string) (UnbanCondition, error) {
+	server, err := version.ParseVersion(serverVersion)
+	if err != nil {
+		return nil, errors.Errorf("Failed to parse unban server version "+
+			"%q: %+v", serverVersion, err)
+	}
+	c := &versionReached{server: server}
+	if gatewayVersion != "" {
+		if c.gateway, err = version.ParseVersion(gatewayVersion); err != nil {
+			return nil, errors.Errorf("Failed to parse unban gateway "+
+				"version %q: %+v", gatewayVersion, err)
+		}
+		c.checkGateway = true
+	}
+	return c, nil
+}
+
+// Name describes the condition in logs.
+func (c *versionReached) Name() string {
+	if c.checkGateway {
+		return "server version " + c.server.String() + " and gateway version " +
+			c.gateway.String() + " reached"
+	}
+	return "server version " + c.server.String() + " reached"
+}
+
+// Met returns true if the versions the node last reported are at or above
+// the required ones.
+func (c *versionReached) Met(_ *RegistrationImpl, n *node.State) bool {
+	serverVersion, gatewayVersion := n.GetVersions()
+	server, err := version.ParseVersion(serverVersion)
+	if err != nil || version.Cmp(server, c.server) < 0 {
+		return false
+	}
+	if !c.checkGateway {
+		return true
+	}
+	gateway, err := version.ParseVersion(gatewayVersion)
+	return err == nil && version.Cmp(gateway, c.gateway) >= 0
+}
+
+// connectivityRecovered is met once the node and its gateway pass a number of
+// consecutive connectivity probes
+type connectivityRecovered struct {
+	probes uint
+
+	// Consecutive probes each banned node passed
+	passed map[id.ID]uint
+	mux    sync.Mutex
+}
+
+// NewConnectivityRecoveredCondition returns a condition met once the banned
+// node and its gateway can be contacted in probes consecutive checks.
+func NewConnectivityRecoveredCondition(probes uint) (UnbanCondition, error) {
+	if probes == 0 {
+		return nil, errors.New("Connectivity unban condition requires at " +
+			"least one probe")
+	}
+	return &connectivityRecovered{probes: probes, passed: make(map[id.ID]uint)}, nil
+}
+
+// Name describes the condition in logs.
+func (c *connectivityRecovered) Name() string {
+	return "connectivity recovered"
+}
+
+// Met probes the node and its gateway, returning true once they passed the
+// required number of probes in a row. A failed probe starts the count over.
+func (c *connectivityRecovered) Met(m *RegistrationImpl, n *node.State) bool {
+	online := m.probeBannedNode(n)
+
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	nid := *n.GetID()
+	if !online {
+		delete(c.passed, nid)
+		return false
+	}
+	c.passed[nid]++
+	if c.passed[nid] < c.probes {
+		return false
+	}
+	delete(c.passed, nid)
+	return true
+}
+
+// probeBannedNode returns true if the node and, unless it runs without one,
+// its gateway can be contacted at the addresses they registered.
+func (m *RegistrationImpl) probeBannedNode(n *node.State) bool {
+	snapshot := n.Snapshot()
+	dbNode, err := storage.PermissioningDb.GetNodeById(snapshot.ID)
+	if err != nil {
+		jww.WARN.Printf("Failed to look up banned node %s to probe it: %+v",
+			snapshot.ID, err)
+		return false
+	}
+	params := connect.GetDefaultHostParams()
+	params.AuthEnabled = false
+	if !m.isHostOnline(snapshot.ID, snapshot.NodeAddress,
+		[]byte(dbNode.NodeCertificate), params) {
+		return false
+	}
+	if snapshot.Gatewayless {
+		return true
+	}
+	gwID := snapshot.ID.DeepCopy()
+	gwID.SetType(id.Gateway)
+	return m.isHostOnline(gwID, snapshot.GatewayAddress,
+		[]byte(dbNode.GatewayCertificate), params)
+}
+
+// newUnbanConditions returns the built in unban conditions enabled by the
+// parameters.
+func newUnbanConditions(params *Params) ([]UnbanCondition, error) {
+	var conditions []UnbanCondition
+	if params.autoUnbanServerVersion != "" {
+		c, err := NewVersionReachedCondition(params.autoUnbanServerVersion,
+			params.autoUnbanGatewayVersion)
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, c)
+	} else if params.autoUnbanGatewayVersion != "" {
+		return nil, errors.New("Cannot unban on a gateway version without " +
+			"a server version")
+	}
+	if params.autoUnbanConnectivityProbes > 0 {
+		c, err := NewConnectivityRecoveredCondition(
+			params.autoUnbanConnectivityProbes)
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, c)
+	}
+	return conditions, nil
+}
+
+// AddUnbanCondition adds a condition under which banned nodes are
+// automatically unbanned.
+func (m *RegistrationImpl) AddUnbanCondition(auth *connect.Auth,
+	c UnbanCondition) error {
+	if err := checkAdminAuth(auth); err != nil {
+		return err
+	}
+
+	m.unbanMux.Lock()
+	defer m.unbanMux.Unlock()
+	m.unbanConditions = append(m.unbanConditions, c)
+	return nil
+}
+
+// CheckAutoUnban unbans the banned nodes meeting an unban condition at once,
+// without waiting for the banned node tracker to check them.
+func (m *RegistrationImpl) CheckAutoUnban(auth *connect.Auth) error {
+	if err := checkAdminAuth(auth); err != nil {
+		return err
+	}
+	m.checkAutoUnban()
+	return nil
+}
+
+// checkAutoUnban evaluates the unban conditions against every banned node,
+// unbanning the nodes which meet one of them both in Storage and in the node
+// map and returning them to the NDF. Unbanned nodes return to scheduling once
+// they poll waiting. Nodes in the blacklist are never unbanned.
+func (m *RegistrationImpl) checkAutoUnban() {
+	m.unbanMux.Lock()
+	conditions := m.unbanConditions
+	m.unbanMux.Unlock()
+	if len(conditions) == 0 {
+		return
+	}
+
+	for _, ns := range m.State.GetNodeMap().GetNodeStates() {
+		if !ns.IsBanned() || m.isBlacklisted(ns.GetID()) {
+			continue
+		}
+		for _, c := range conditions {
+			if !c.Met(m, ns) {
+				continue
+			}
+			if err := m.autoUnbanNode(ns); err != nil {
+				jww.ERROR.Printf("Failed to automatically unban node %s: %+v",
+					ns.GetID(), err)
+			} else {
+				jww.INFO.Printf("Automatically unbanned node %s: %s",
+					ns.GetID(), c.Name())
+			}
+			break
+		}
+	}
+}
+
+// autoUnbanNode lifts the node's ban in Storage, so that it is not banned
+// again by the banned node tracker, and then in the node map.
+func (m *RegistrationImpl) autoUnbanNode(ns *node.State) error {
+	dbNode, err := storage.PermissioningDb.GetNodeById(ns.GetID())
+	if err != nil {
+		return errors.WithMessage(err, "Failed to look up node")
+	}
+	err = storage.PermissioningDb.UpdateNodeStatus(ns.GetID(), node.Active)
+	if err != nil {
+		return errors.WithMessage(err, "Failed to store node status")
+	}
+	return m.liftBan(ns, dbNode.Code)
+}
@@ -0,0 +1,240 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package cmd
+
+import (
+	"gitlab.com/elixxir/primitives/current"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/elixxir/registration/storage/node"
+	"gitlab.com/xx_network/comms/connect"
+	"gitlab.com/xx_network/primitives/id"
+	"gitlab.com/xx_network/primitives/ndf"
+	"gitlab.com/xx_network/primitives/region"
+	"testing"
+)
+
+// Tests that banned nodes meeting an unban condition are unbanned in Storage
+// and the node map, return to the NDF and re-enter scheduling once they poll
+// waiting, while blacklisted nodes and nodes meeting no condition stay banned.
+func TestRegistrationImpl_CheckAutoUnban(t *testing.T) {
+	var err error
+	var closeDb func() error
+	storage.PermissioningDb, closeDb, err = storage.NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("Failed to create database: %+v", err)
+	}
+	t.Cleanup(func() { _ = closeDb() })
+	state, err := storage.NewState(getTestKey(), 8, "", "", region.GetCountryBins())
+	if err != nil {
+		t.Fatalf("Failed to create state: %+v", err)
+	}
+	params := &Params{autoUnbanServerVersion: "3.0.0",
+		autoUnbanConnectivityProbes: 2}
+	conditions, err := newUnbanConditions(params)
+	if err != nil {
+		t.Fatalf("Failed to create unban conditions: %+v", err)
+	}
+	impl := &RegistrationImpl{
+		State:             state,
+		params:            params,
+		registrationTimes: make(map[id.ID]int64),
+		unbanConditions:   conditions,
+	}
+
+	var nodes []*id.ID
+	var infos []node.Info
+	for i := 0; i < 3; i++ {
+		nodes = append(nodes, id.NewIdFromUInt(uint64(i), id.Node, t))
+		infos = append(infos, node.Info{RegCode: "code" + nodes[i].String(),
+			Order: "US"})
+	}
+	storage.PopulateNodeRegistrationCodes(infos)
+	def := &ndf.NetworkDefinition{}
+	for i, nid := range nodes {
+		err = storage.PermissioningDb.RegisterNode(nid, []byte("salt"),
+			infos[i].RegCode, "10.0.0.1:11420", "", "10.0.0.1:22840", "",
+			storage.SelfServeRegistration)
+		if err != nil {
+			t.Fatalf("Failed to register node: %+v", err)
+		}
+		err = state.GetNodeMap().AddNode(nid, "US", "10.0.0.1:11420",
+			"10.0.0.1:22840", 0)
+		if err != nil {
+			t.Fatalf("Failed to add node: %+v", err)
+		}
+		err = storage.PermissioningDb.GetDatabaseImpl(t).BannedNode(nid, t)
+		if err != nil {
+			t.Fatalf("Failed to ban node: %+v", err)
+		}
+		gwID := nid.DeepCopy()
+		gwID.SetType(id.Gateway)
+		def.Nodes = append(def.Nodes, ndf.Node{ID: nid.Marshal()})
+		def.Gateways = append(def.Gateways, ndf.Gateway{ID: gwID.Marshal()})
+	}
+	state.InternalNdfLock.Lock()
+	state.UpdateInternalNdf(def)
+	state.InternalNdfLock.Unlock()
+	if err = BannedNodeTracker(impl); err != nil {
+		t.Fatalf("Failed to ban nodes: %+v", err)
+	}
+	for len(state.GetNodeUpdateChannel()) > 0 {
+		nun := <-state.GetNodeUpdateChannel()
+		state.GetNodeMap().GetNode(nun.Node).GetPollingLock().Unlock()
+	}
+
+	// The first node is not online, the second one is blacklisted
+	state.GetNodeMap().GetNode(nodes[0]).UpdateVersions("2.9.0", "2.9.0")
+	state.GetNodeMap().GetNode(nodes[1]).UpdateVersions("3.1.0", "3.1.0")
+	impl.blacklist.listed = map[id.ID]bool{*nodes[1]: true}
+	defer func(probe func(*connect.Host) bool) { hostOnline = probe }(hostOnline)
+	hostOnline = func(h *connect.Host) bool {
+		nid := h.GetId().DeepCopy()
+		nid.SetType(id.Node)
+		return !nid.Cmp(nodes[0])
+	}
+
+	check := func(stage string, banned ...bool) {
+		inNdf := make(map[id.ID]bool)
+		for _, n := range state.GetUnprunedNdf().Nodes {
+			nid, _ := id.Unmarshal(n.ID)
+			inNdf[*nid] = true
+		}
+		for i, nid := range nodes {
+			if isBanned := state.GetNodeMap().GetNode(nid).IsBanned(); isBanned != banned[i] {
+				t.Errorf("Node %d banned is %t %s, expected %t.", i,
+					isBanned, stage, banned[i])
+			}
+			if inNdf[*nid] == banned[i] {
+				t.Errorf("Node %d in the NDF is %t %s, expected %t.", i,
+					inNdf[*nid], stage, !banned[i])
+			}
+			dbNode, err := storage.PermissioningDb.GetNodeById(nid)
+			if err != nil {
+				t.Fatalf("Failed to get node: %+v", err)
+			}
+			if dbBanned := node.Status(dbNode.Status) == node.Banned; dbBanned != banned[i] {
+				t.Errorf("Node %d banned in Storage is %t %s, expected %t.",
+					i, dbBanned, stage, banned[i])
+			}
+		}
+	}
+
+	permHost, err := connect.NewHost(&id.Permissioning, "", nil,
+		connect.GetDefaultHostParams())
+	if err != nil {
+		t.Fatalf("Failed to create host: %+v", err)
+	}
+	nodeHost, err := connect.NewHost(nodes[1], "", nil,
+		connect.GetDefaultHostParams())
+	if err != nil {
+		t.Fatalf("Failed to create host: %+v", err)
+	}
+	auth := &connect.Auth{IsAuthenticated: true, Sender: permHost}
+	if impl.CheckAutoUnban(&connect.Auth{IsAuthenticated: true, Sender: nodeHost}) == nil {
+		t.Errorf("Node was able to check for nodes to unban.")
+	}
+	check("after a node checked", true, true, true)
+
+	// The third node has only passed one of the two probes
+	if err = impl.CheckAutoUnban(auth); err != nil {
+		t.Fatalf("CheckAutoUnban() returned an error: %+v", err)
+	}
+	check("after one check", true, true, true)
+
+	state.GetNodeMap().GetNode(nodes[0]).UpdateVersions("3.0.0", "")
+	impl.checkAutoUnban()
+	check("after the conditions were met", false, true, false)
+
+	// The unbanned nodes are not banned again by the banned node tracker
+	if err = BannedNodeTracker(impl); err != nil {
+		t.Fatalf("Failed to check banned nodes: %+v", err)
+	}
+	check("after checking banned nodes", false, true, false)
+
+	ns := state.GetNodeMap().GetNode(nodes[0])
+	if ns.GetStatus() != node.Inactive {
+		t.Errorf("Unbanned node is %s, expected %s.", ns.GetStatus(),
+			node.Inactive)
+	}
+	updated, nun, err := ns.Update(current.WAITING)
+	if err != nil || !updated {
+		t.Fatalf("Unbanned node failed to poll waiting: %t, %+v", updated, err)
+	}
+	if ns.GetStatus() != node.Active || nun.ToStatus != node.Active {
+		t.Errorf("Unbanned node did not return to scheduling: %s, %+v",
+			ns.GetStatus(), nun)
+	}
+}
+
+// Tests that the unban conditions are built from the parameters, and that
+// invalid ones are rejected.
+func TestNewUnbanConditions(t *testing.T) {
+	conditions, err := newUnbanConditions(&Params{})
+	if err != nil || len(conditions) != 0 {
+		t.Errorf("Unban conditions created by default: %v, %+v", conditions, err)
+	}
+
+	conditions, err = newUnbanConditions(&Params{
+		autoUnbanServerVersion: "3.0.0", autoUnbanGatewayVersion: "3.1.0"})
+	if err != nil || len(conditions) != 1 {
+		t.Fatalf("Failed to create version condition: %v, %+v", conditions, err)
+	}
+	ns := &node.State{}
+	for _, v := range []struct {
+		server, gateway string
+		met             bool
+	}{
+		{"3.0.0", "3.0.0", false},
+		{"3.0.0", "3.1.0", true},
+		{"2.9.9", "3.1.0", false},
+		{"4.0.0", "3.2.1", true},
+	} {
+		ns.UpdateVersions(v.server, v.gateway)
+		if conditions[0].Met(nil, ns) != v.met {
+			t.Errorf("Version condition met is %t for %s and %s, expected %t.",
+				!v.met, v.server, v.gateway, v.met)
+		}
+	}
+
+	for _, p := range []*Params{
+		{autoUnbanServerVersion: "invalid"},
+		{autoUnbanServerVersion: "3.0.0", autoUnbanGatewayVersion: "invalid"},
+		{autoUnbanGatewayVersion: "3.0.0"},
+	} {
+		if _, err = newUnbanConditions(p); err == nil {
+			t.Errorf("Invalid unban parameters accepted: %+v", p)
+		}
+	}
+
+	// Conditions can also be plugged in by the permissioning server
+	impl := &RegistrationImpl{}
+	c, _ := NewConnectivityRecoveredCondition(1)
+	nodeHost, err := connect.NewHost(id.NewIdFromUInt(0, id.Node, t), "", nil,
+		connect.GetDefaultHostParams())
+	if err != nil {
+		t.Fatalf("Failed to create host: %+v", err)
+	}
+	if impl.AddUnbanCondition(&connect.Auth{IsAuthenticated: true, Sender: nodeHost}, c) == nil {
+		t.Errorf("Node was able to add an unban condition.")
+	}
+	permHost, err := connect.NewHost(&id.Permissioning, "", nil,
+		connect.GetDefaultHostParams())
+	if err != nil {
+		t.Fatalf("Failed to create host: %+v", err)
+	}
+	err = impl.AddUnbanCondition(&connect.Auth{IsAuthenticated: true, Sender: permHost}, c)
+	if err != nil {
+		t.Fatalf("AddUnbanCondition() returned an error: %+v", err)
+	}
+	if len(impl.unbanConditions) != 1 {
+		t.Errorf("Unban condition was not added.")
+	}
+	if _, err = NewConnectivityRecoveredCondition(0); err == nil {
+		t.Errorf("Connectivity condition without probes accepted.")
+	}
+}
@@ -0,0 +1,76 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Contains the administrative functions for managing Application avoid-lists
+
+package cmd
+
+import (
+	jww "github.com/spf13/jwalterweatherman"
+	"gitlab.com/elixxir/registration/storage/node"
+	"gitlab.com/xx_network/comms/connect"
+)
+
+// SetApplicationAvoidList replaces the list of Applications whose Nodes the
+// Node of the given Application must not be teamed with. An empty list clears
+// it. Returns the fraction of the network the avoid-list excludes so excessive
+// lists can be surfaced to the operator.
+func (m *RegistrationImpl) SetApplicationAvoidList(auth *connect.Auth,
+	applicationId uint64, avoided []uint64) (float64, error) {
+	if err := checkAdminAuth(auth); err != nil {
+		return 0, err
+	}
+	return m.setApplicationAvoidList(applicationId, avoided)
+}
+
+// setApplicationAvoidList replaces the avoid-list of the Application and
+// returns the fraction of the network it excludes.
+func (m *RegistrationImpl) setApplicationAvoidList(applicationId uint64,
+	avoided []uint64) (float64, error) {
+	err := m.State.SetAvoidList(applicationId, avoided)
+	if err != nil {
+		return 0, err
+	}
+
+	excluded := m.State.GetAvoidListExclusion(applicationId)
+	jww.INFO.Printf("Set avoid-list of application %d with %d entries, "+
+		"excluding %.1f%% of the network", applicationId, len(avoided),
+		excluded*100)
+	return excluded, nil
+}
+
+// GetExcessiveAvoidLists returns the Applications whose avoid-lists exclude
+// more than the warning fraction of the network, mapped to the fraction they
+// exclude.
+func (m *RegistrationImpl) GetExcessiveAvoidLists(auth *connect.Auth) (map[uint64]float64, error) {
+	if err := checkAdminAuth(auth); err != nil {
+		return nil, err
+	}
+	return m.State.GetExcessiveAvoidLists(), nil
+}
+
+// loadApplicationAvoidLists applies the avoid-lists in the Application
+// metadata of the registration codes, where each Application's ID is its
+// position in the list starting at 1, then warns of every avoid-list which
+// excludes too much of the network. Invalid avoid-lists are logged and
+// skipped.
+func (m *RegistrationImpl) loadApplicationAvoidLists(infos []node.Info) {
+	for i, info := range infos {
+		if len(info.Avoid) == 0 {
+			continue
+		}
+		if _, err := m.setApplicationAvoidList(uint64(i+1), info.Avoid); err != nil {
+			jww.ERROR.Printf("Failed to load avoid-list of application %d: %+v",
+				i+1, err)
+		}
+	}
+
+	for appId, excluded := range m.State.GetExcessiveAvoidLists() {
+		jww.WARN.Printf("Avoid-list of application %d excludes %.1f%% of "+
+			"the network", appId, excluded*100)
+	}
+}
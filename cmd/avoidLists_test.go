@@ -0,0 +1,139 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package cmd
+
+import (
+	"crypto/rand"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/elixxir/registration/storage/node"
+	"gitlab.com/xx_network/comms/connect"
+	"gitlab.com/xx_network/crypto/signature/rsa"
+	"gitlab.com/xx_network/primitives/id"
+	"gitlab.com/xx_network/primitives/region"
+	"testing"
+)
+
+// Tests that SetApplicationAvoidList stores the avoid-list and reports the
+// fraction of the network it excludes, and that only the permissioning server
+// can set it.
+func TestRegistrationImpl_SetApplicationAvoidList(t *testing.T) {
+	var err error
+	storage.PermissioningDb, _, err = storage.NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	privKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	testState, err := storage.NewState(privKey, 8, "", "", region.GetCountryBins())
+	if err != nil {
+		t.Fatalf("Failed to create test state: %v", err)
+	}
+	impl := &RegistrationImpl{State: testState}
+
+	for i := uint64(1); i <= 3; i++ {
+		err = testState.GetNodeMap().AddNode(
+			id.NewIdFromUInt(i, id.Node, t), "US", "", "", i)
+		if err != nil {
+			t.Fatalf("Couldn't add node: %v", err)
+		}
+	}
+
+	permHost, err := connect.NewHost(&id.Permissioning, "", nil,
+		connect.GetDefaultHostParams())
+	if err != nil {
+		t.Fatalf("Failed to create host: %+v", err)
+	}
+	nodeHost, err := connect.NewHost(id.NewIdFromUInt(1, id.Node, t), "", nil,
+		connect.GetDefaultHostParams())
+	if err != nil {
+		t.Fatalf("Failed to create host: %+v", err)
+	}
+	auth := &connect.Auth{IsAuthenticated: true, Sender: permHost}
+
+	_, err = impl.SetApplicationAvoidList(
+		&connect.Auth{IsAuthenticated: true, Sender: nodeHost}, 1, []uint64{2})
+	if err == nil {
+		t.Errorf("Node was able to set an avoid-list.")
+	}
+	if testState.Avoids(2, 1) {
+		t.Errorf("Unauthenticated avoid-list was applied.")
+	}
+
+	excluded, err := impl.SetApplicationAvoidList(auth, 1, []uint64{2})
+	if err != nil {
+		t.Fatalf("SetApplicationAvoidList() returned an error: %+v", err)
+	}
+	if excluded != 0.5 {
+		t.Errorf("Unexpected excluded fraction.\nexpected: %f\nreceived: %f",
+			0.5, excluded)
+	}
+	if !testState.Avoids(2, 1) {
+		t.Errorf("Avoid-list was not applied.")
+	}
+
+	_, err = impl.SetApplicationAvoidList(auth, 1, []uint64{1})
+	if err == nil {
+		t.Errorf("SetApplicationAvoidList() should reject an application " +
+			"avoiding itself.")
+	}
+}
+
+// Tests that loadApplicationAvoidLists applies the avoid-lists given with the
+// registration codes by the position of each Application, skips invalid ones
+// and reports excessive ones through GetExcessiveAvoidLists.
+func TestRegistrationImpl_loadApplicationAvoidLists(t *testing.T) {
+	var err error
+	storage.PermissioningDb, _, err = storage.NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	privKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	testState, err := storage.NewState(privKey, 8, "", "", region.GetCountryBins())
+	if err != nil {
+		t.Fatalf("Failed to create test state: %v", err)
+	}
+	impl := &RegistrationImpl{State: testState}
+
+	for i := uint64(1); i <= 3; i++ {
+		err = testState.GetNodeMap().AddNode(
+			id.NewIdFromUInt(i, id.Node, t), "US", "", "", i)
+		if err != nil {
+			t.Fatalf("Couldn't add node: %v", err)
+		}
+	}
+
+	impl.loadApplicationAvoidLists([]node.Info{
+		{RegCode: "AAAA", Avoid: []uint64{3}},
+		{RegCode: "BBBB", Avoid: []uint64{2}},
+		{RegCode: "CCCC"},
+	})
+	if !testState.Avoids(1, 3) {
+		t.Errorf("Avoid-list of the first application was not applied.")
+	}
+	if len(testState.GetAvoidList(2)) != 0 {
+		t.Errorf("Avoid-list of an application avoiding itself was applied.")
+	}
+
+	permHost, err := connect.NewHost(&id.Permissioning, "", nil,
+		connect.GetDefaultHostParams())
+	if err != nil {
+		t.Fatalf("Failed to create host: %+v", err)
+	}
+	if _, err = impl.GetExcessiveAvoidLists(&connect.Auth{Sender: permHost}); err == nil {
+		t.Errorf("Unauthenticated sender was able to get excessive avoid-lists.")
+	}
+	auth := &connect.Auth{IsAuthenticated: true, Sender: permHost}
+	excessive, err := impl.GetExcessiveAvoidLists(auth)
+	if err != nil {
+		t.Fatalf("Failed to get excessive avoid-lists: %+v", err)
+	}
+	if len(excessive) != 1 || excessive[1] != 0.5 {
+		t.Errorf("Unexpected excessive avoid-lists: %v", excessive)
+	}
+}
@@ -0,0 +1,61 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Contains the handling of polls from banned nodes
+
+package cmd
+
+import (
+	"github.com/pkg/errors"
+	jww "github.com/spf13/jwalterweatherman"
+	pb "gitlab.com/elixxir/comms/mixmessages"
+	"gitlab.com/elixxir/registration/storage/node"
+	"google.golang.org/protobuf/encoding/protowire"
+	"time"
+)
+
+// bannedPollField is the field number of the ban notice in the
+// PermissionPollResponse message. It is sent as a string holding the reason
+// for the ban in the message's unknown fields until the comms message declares
+// the field. A node receiving it has been banned and should stop polling.
+const bannedPollField protowire.Number = 15
+
+// Reason sent in the ban notice when none is configured
+const defaultBannedPollReason = "Node has been banned from the network"
+
+// respondBanned answers a poll from a banned node. When ban notices are
+// enabled, the response carries the notice and no error, so the node can tell
+// it is banned apart from transient errors. Polls within the banned poll
+// interval of the last answered one are rejected without further processing.
+func (m *RegistrationImpl) respondBanned(response *pb.PermissionPollResponse,
+	n *node.State, now time.Time) (*pb.PermissionPollResponse, error) {
+	nid := n.GetID()
+	if !n.AllowBannedPoll(now, m.params.bannedPollInterval) {
+		return nil, errors.Errorf("Node %s is banned, polling too often", nid)
+	}
+
+	if !m.params.bannedPollNotice {
+		return response, errors.Errorf("Node %s has been banned from the network", nid)
+	}
+
+	reason := m.params.bannedPollReason
+	if reason == "" {
+		reason = defaultBannedPollReason
+	}
+	jww.DEBUG.Printf("Sending ban notice to node %s: %s", nid, reason)
+	setBannedNotice(response, reason)
+	return response, nil
+}
+
+// setBannedNotice adds the ban notice with the given reason to the poll
+// response.
+func setBannedNotice(response *pb.PermissionPollResponse, reason string) {
+	unknown := response.ProtoReflect().GetUnknown()
+	unknown = protowire.AppendTag(unknown, bannedPollField, protowire.BytesType)
+	unknown = protowire.AppendString(unknown, reason)
+	response.ProtoReflect().SetUnknown(unknown)
+}
@@ -0,0 +1,146 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package cmd
+
+import (
+	pb "gitlab.com/elixxir/comms/mixmessages"
+	"gitlab.com/elixxir/primitives/current"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/elixxir/registration/storage/node"
+	"gitlab.com/xx_network/comms/connect"
+	"gitlab.com/xx_network/primitives/id"
+	"gitlab.com/xx_network/primitives/ndf"
+	"google.golang.org/protobuf/encoding/protowire"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Reads the ban notice sent in the poll response, returning false if there is
+// none
+func getBannedNotice(t *testing.T, response *pb.PermissionPollResponse) (string, bool) {
+	unknown := response.ProtoReflect().GetUnknown()
+	for len(unknown) > 0 {
+		num, typ, n := protowire.ConsumeTag(unknown)
+		if n < 0 {
+			t.Fatalf("Malformed unknown fields: %v", unknown)
+		}
+		unknown = unknown[n:]
+		if num == bannedPollField && typ == protowire.BytesType {
+			reason, _ := protowire.ConsumeString(unknown)
+			return reason, true
+		}
+		unknown = unknown[protowire.ConsumeFieldValue(num, typ, unknown):]
+	}
+	return "", false
+}
+
+// Tests that a banned node polling is sent the ban notice with the configured
+// reason instead of an error, and that polls within the banned poll interval
+// are rejected.
+func TestRegistrationImpl_Poll_BannedNotice(t *testing.T) {
+	var err error
+	dblck.Lock()
+	defer dblck.Unlock()
+
+	var dc func() error
+	storage.PermissioningDb, dc, err = storage.NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	defer func() { _ = dc() }()
+	err = storage.PermissioningDb.InsertEphemeralLength(
+		&storage.EphemeralLength{Length: 8, Timestamp: time.Now()})
+	if err != nil {
+		t.Errorf("Failed to insert ephemeral length into database: %+v", err)
+	}
+
+	impl, err := StartRegistration(testParams)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer impl.Comms.Shutdown()
+	atomic.CompareAndSwapUint32(impl.NdfReady, 0, 1)
+	impl.params.bannedPollNotice = true
+	impl.params.bannedPollReason = "operator ban"
+	impl.params.bannedPollInterval = time.Hour
+
+	impl.State.UpdateInternalNdf(&ndf.NetworkDefinition{
+		Registration: ndf.Registration{Address: "420"},
+	})
+	if err = impl.State.UpdateOutputNdf(); err != nil {
+		t.Fatalf("Failed to update output ndf: %+v", err)
+	}
+
+	testID := id.NewIdFromString("banned", id.Node, t)
+	if err = impl.State.GetNodeMap().AddNode(testID, "", "", "", 0); err != nil {
+		t.Fatalf("Failed to add node: %+v", err)
+	}
+	if _, err = impl.State.GetNodeMap().GetNode(testID).Ban(); err != nil {
+		t.Fatalf("Failed to ban node: %+v", err)
+	}
+
+	testHost, _ := connect.NewHost(testID, "test", nil,
+		connect.GetDefaultHostParams())
+	testAuth := &connect.Auth{IsAuthenticated: true, Sender: testHost}
+	testMsg := &pb.PermissioningPoll{
+		Full:           &pb.NDFHash{Hash: []byte("test")},
+		Partial:        &pb.NDFHash{Hash: []byte("test")},
+		Activity:       uint32(current.WAITING),
+		GatewayVersion: "1.1.0",
+		ServerVersion:  "1.1.0",
+	}
+
+	response, err := impl.Poll(testMsg, testAuth)
+	if err != nil {
+		t.Fatalf("Poll from a banned node sent an error instead of the "+
+			"notice: %+v", err)
+	}
+	reason, banned := getBannedNotice(t, response)
+	if !banned {
+		t.Fatalf("No ban notice in the poll response.")
+	}
+	if reason != "operator ban" {
+		t.Errorf("Unexpected ban reason.\nexpected: %q\nreceived: %q",
+			"operator ban", reason)
+	}
+	if response.GetFullNDF() != nil || response.GetPartialNDF() != nil {
+		t.Errorf("Banned node was sent the NDF.")
+	}
+
+	if _, err = impl.Poll(testMsg, testAuth); err == nil {
+		t.Errorf("Poll within the banned poll interval was answered.")
+	}
+}
+
+// Tests that the default reason is sent when none is configured, and that the
+// notice is sent alongside the policy hint.
+func TestRegistrationImpl_respondBanned_DefaultReason(t *testing.T) {
+	impl := &RegistrationImpl{params: &Params{bannedPollNotice: true},
+		policyVersion: 1}
+	nodeMap := node.NewStateMap()
+	nid := id.NewIdFromString("banned", id.Node, t)
+	if err := nodeMap.AddNode(nid, "", "", "", 0); err != nil {
+		t.Fatalf("Failed to add node: %+v", err)
+	}
+	n := nodeMap.GetNode(nid)
+
+	response := &pb.PermissionPollResponse{}
+	impl.setPolicyHint(response)
+	response, err := impl.respondBanned(response, n, time.Now())
+	if err != nil {
+		t.Fatalf("respondBanned() produced an error: %+v", err)
+	}
+	if reason, _ := getBannedNotice(t, response); reason != defaultBannedPollReason {
+		t.Errorf("Unexpected ban reason.\nexpected: %q\nreceived: %q",
+			defaultBannedPollReason, reason)
+	}
+	if hint := getPolicyHint(t, response); hint != 1 {
+		t.Errorf("Policy hint lost: %d", hint)
+	}
+}
@@ -0,0 +1,145 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Handles banning the nodes listed in an external blacklist file
+
+package cmd
+
+import (
+	"github.com/pkg/errors"
+	jww "github.com/spf13/jwalterweatherman"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/elixxir/registration/storage/node"
+	"gitlab.com/xx_network/comms/connect"
+	"gitlab.com/xx_network/primitives/id"
+	"gitlab.com/xx_network/primitives/utils"
+	"sync"
+)
+
+// nodeBlacklist holds the nodes banned by the blacklist file
+type nodeBlacklist struct {
+	listed map[id.ID]bool
+	mux    sync.Mutex
+}
+
+// isBlacklisted returns true if the node is in the blacklist last loaded.
+func (m *RegistrationImpl) isBlacklisted(nid *id.ID) bool {
+	bl := &m.blacklist
+	bl.mux.Lock()
+	defer bl.mux.Unlock()
+	return bl.listed[*nid]
+}
+
+// LoadBlacklist reloads the blacklist file at once, as on SIGHUP.
+func (m *RegistrationImpl) LoadBlacklist(auth *connect.Auth) error {
+	if err := checkAdminAuth(auth); err != nil {
+		return err
+	}
+	return m.loadBlacklist()
+}
+
+// loadBlacklist reads the blacklist file, a list of base64 encoded node IDs
+// separated by new lines, and bans every listed node in the node map. When
+// the blacklist is reloaded and blacklistUnban is set, nodes which were
+// removed from it are unbanned unless they are also banned in Storage. A
+// blacklist which cannot be read leaves the current one in place.
+func (m *RegistrationImpl) loadBlacklist() error {
+	if m.params.blacklistPath == "" {
+		return nil
+	}
+	data, err := utils.ReadFile(m.params.blacklistPath)
+	if err != nil {
+		return errors.Errorf("Failed to read node blacklist: %+v", err)
+	}
+	nodes, err := storage.ParseNodeIdList(string(data))
+	if err != nil {
+		jww.WARN.Printf("Error while parsing node blacklist: %v", err)
+	}
+	return m.applyBlacklist(nodes)
+}
+
+// applyBlacklist bans the listed nodes and, when blacklistUnban is set,
+// unbans the nodes which are no longer listed.
+func (m *RegistrationImpl) applyBlacklist(nodes []*id.ID) error {
+	bl := &m.blacklist
+	bl.mux.Lock()
+	defer bl.mux.Unlock()
+
+	listed := make(map[id.ID]bool, len(nodes))
+	for _, nid := range nodes {
+		listed[*nid] = true
+	}
+
+	m.State.InternalNdfLock.Lock()
+	for _, nid := range nodes {
+		ns := m.State.GetNodeMap().GetNode(nid)
+		if ns == nil || ns.IsBanned() {
+			continue
+		}
+		if err := banNode(m.State, nid); err != nil {
+			m.State.InternalNdfLock.Unlock()
+			return errors.WithMessagef(err, "Failed to ban blacklisted node %s",
+				nid)
+		}
+		jww.INFO.Printf("Banned blacklisted node %s", nid)
+	}
+	m.State.InternalNdfLock.Unlock()
+
+	if m.params.blacklistUnban {
+		for nid := range bl.listed {
+			if !listed[nid] {
+				m.unbanNode(nid.DeepCopy())
+			}
+		}
+	}
+
+	bl.listed = listed
+	return nil
+}
+
+// unbanNode lifts the ban of a node removed from the blacklist and returns it
+// to the NDF. Nodes banned in Storage stay banned.
+func (m *RegistrationImpl) unbanNode(nid *id.ID) {
+	ns := m.State.GetNodeMap().GetNode(nid)
+	if ns == nil || !ns.IsBanned() {
+		return
+	}
+	dbNode, err := storage.PermissioningDb.GetNodeById(nid)
+	if err != nil {
+		jww.ERROR.Printf("Failed to look up node %s removed from the "+
+			"blacklist: %+v", nid, err)
+		return
+	}
+	if node.Status(dbNode.Status) == node.Banned {
+		jww.INFO.Printf("Node %s was removed from the blacklist but is "+
+			"banned in Storage, leaving it banned", nid)
+		return
+	}
+
+	if err = m.liftBan(ns, dbNode.Code); err != nil {
+		jww.ERROR.Printf("Failed to unban node %s: %+v", nid, err)
+		return
+	}
+	jww.INFO.Printf("Unbanned node %s removed from the blacklist", nid)
+}
+
+// liftBan unbans the node in the node map and returns it to the NDF under its
+// registration code.
+func (m *RegistrationImpl) liftBan(ns *node.State, code string) error {
+	if err := ns.Unban(); err != nil {
+		return err
+	}
+
+	m.State.InternalNdfLock.Lock()
+	defer m.State.InternalNdfLock.Unlock()
+	def := m.State.GetUnprunedNdf()
+	if err := m.addNodeToNdf(def, code, ns.GetID()); err != nil {
+		return errors.WithMessage(err, "Failed to return node to the NDF")
+	}
+	m.State.UpdateInternalNdf(def)
+	return nil
+}
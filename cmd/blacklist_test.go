@@ -0,0 +1,150 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package cmd
+
+import (
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/elixxir/registration/storage/node"
+	"gitlab.com/xx_network/comms/connect"
+	"gitlab.com/xx_network/primitives/id"
+	"gitlab.com/xx_network/primitives/ndf"
+	"gitlab.com/xx_network/primitives/region"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Tests that loading the blacklist bans the listed nodes and that reloading a
+// modified list bans the added nodes and unbans the removed ones, except for
+// nodes banned in Storage, and that only the permissioning server can reload
+// it.
+func TestRegistrationImpl_LoadBlacklist(t *testing.T) {
+	var err error
+	var closeDb func() error
+	storage.PermissioningDb, closeDb, err = storage.NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("Failed to create database: %+v", err)
+	}
+	t.Cleanup(func() { _ = closeDb() })
+	state, err := storage.NewState(getTestKey(), 8, "", "", region.GetCountryBins())
+	if err != nil {
+		t.Fatalf("Failed to create state: %+v", err)
+	}
+	path := filepath.Join(t.TempDir(), "blacklist.txt")
+	impl := &RegistrationImpl{
+		State:             state,
+		params:            &Params{blacklistPath: path, blacklistUnban: true},
+		registrationTimes: make(map[id.ID]int64),
+	}
+
+	var nodes []*id.ID
+	var infos []node.Info
+	for i := 0; i < 3; i++ {
+		nodes = append(nodes, id.NewIdFromUInt(uint64(i), id.Node, t))
+		infos = append(infos, node.Info{RegCode: "code" + nodes[i].String(),
+			Order: "US"})
+	}
+	storage.PopulateNodeRegistrationCodes(infos)
+	def := &ndf.NetworkDefinition{}
+	for i, nid := range nodes {
+		err = storage.PermissioningDb.RegisterNode(nid, []byte("salt"),
+			infos[i].RegCode, "10.0.0.1:11420", "", "10.0.0.1:22840", "",
+			storage.SelfServeRegistration)
+		if err != nil {
+			t.Fatalf("Failed to register node: %+v", err)
+		}
+		err = state.GetNodeMap().AddNode(nid, "US", "10.0.0.1:11420",
+			"10.0.0.1:22840", 0)
+		if err != nil {
+			t.Fatalf("Failed to add node: %+v", err)
+		}
+		gwID := nid.DeepCopy()
+		gwID.SetType(id.Gateway)
+		def.Nodes = append(def.Nodes, ndf.Node{ID: nid.Marshal()})
+		def.Gateways = append(def.Gateways, ndf.Gateway{ID: gwID.Marshal()})
+	}
+	state.InternalNdfLock.Lock()
+	state.UpdateInternalNdf(def)
+	state.InternalNdfLock.Unlock()
+
+	// The last node is also banned in Storage
+	err = storage.PermissioningDb.GetDatabaseImpl(t).BannedNode(nodes[2], t)
+	if err != nil {
+		t.Fatalf("Failed to ban node: %+v", err)
+	}
+
+	permHost, err := connect.NewHost(&id.Permissioning, "", nil,
+		connect.GetDefaultHostParams())
+	if err != nil {
+		t.Fatalf("Failed to create host: %+v", err)
+	}
+	auth := &connect.Auth{IsAuthenticated: true, Sender: permHost}
+
+	load := func(listed ...*id.ID) {
+		var lines []string
+		for _, nid := range listed {
+			lines = append(lines, nid.String())
+		}
+		err := os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0644)
+		if err != nil {
+			t.Fatalf("Failed to write blacklist: %+v", err)
+		}
+		if err = impl.LoadBlacklist(auth); err != nil {
+			t.Fatalf("Failed to load blacklist: %+v", err)
+		}
+		// Release the nodes the ban notifications were sent for
+		for len(state.GetNodeUpdateChannel()) > 0 {
+			nun := <-state.GetNodeUpdateChannel()
+			state.GetNodeMap().GetNode(nun.Node).GetPollingLock().Unlock()
+		}
+	}
+	check := func(stage string, banned ...bool) {
+		inNdf := make(map[id.ID]bool)
+		for _, n := range state.GetUnprunedNdf().Nodes {
+			nid, _ := id.Unmarshal(n.ID)
+			inNdf[*nid] = true
+		}
+		for i, nid := range nodes {
+			if isBanned := state.GetNodeMap().GetNode(nid).IsBanned(); isBanned != banned[i] {
+				t.Errorf("Node %d banned is %t %s, expected %t.", i,
+					isBanned, stage, banned[i])
+			}
+			if inNdf[*nid] == banned[i] {
+				t.Errorf("Node %d in the NDF is %t %s, expected %t.", i,
+					inNdf[*nid], stage, !banned[i])
+			}
+		}
+	}
+
+	nodeHost, err := connect.NewHost(nodes[0], "", nil,
+		connect.GetDefaultHostParams())
+	if err != nil {
+		t.Fatalf("Failed to create host: %+v", err)
+	}
+	if impl.LoadBlacklist(&connect.Auth{IsAuthenticated: true, Sender: nodeHost}) == nil {
+		t.Errorf("Node was able to reload the blacklist.")
+	}
+
+	load(nodes[0], nodes[2])
+	check("after loading", true, false, true)
+	if !impl.isBlacklisted(nodes[0]) || impl.isBlacklisted(nodes[1]) {
+		t.Errorf("Blacklisted nodes were not recorded.")
+	}
+
+	load(nodes[1])
+	check("after reloading", false, true, true)
+	if status := state.GetNodeMap().GetNode(nodes[0]).GetStatus(); status != node.Inactive {
+		t.Errorf("Unbanned node is %s, expected %s.", status, node.Inactive)
+	}
+
+	// Removed nodes stay banned unless unbanning is enabled
+	impl.params.blacklistUnban = false
+	load()
+	check("after reloading without unbanning", false, true, true)
+}
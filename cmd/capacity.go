@@ -0,0 +1,73 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Contains the handling of capacity hints reported by nodes in their polls
+
+package cmd
+
+import (
+	jww "github.com/spf13/jwalterweatherman"
+	pb "gitlab.com/elixxir/comms/mixmessages"
+	"gitlab.com/elixxir/registration/storage/node"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// capacityPollField is the field number of the capacity hint in the
+// PermissioningPoll message. Nodes which support capacity reporting send it
+// as a varint; until the comms message declares the field, it is read from the
+// message's unknown fields.
+const capacityPollField protowire.Number = 12
+
+// getCapacityHint returns the capacity hint sent in the poll, if any.
+func getCapacityHint(msg *pb.PermissioningPoll) (uint32, bool) {
+	unknown := msg.ProtoReflect().GetUnknown()
+	var capacity uint64
+	found := false
+	for len(unknown) > 0 {
+		num, typ, n := protowire.ConsumeTag(unknown)
+		if n < 0 {
+			return 0, false
+		}
+		unknown = unknown[n:]
+
+		if num == capacityPollField && typ == protowire.VarintType {
+			v, m := protowire.ConsumeVarint(unknown)
+			if m < 0 {
+				return 0, false
+			}
+			capacity, found = v, true
+			unknown = unknown[m:]
+			continue
+		}
+
+		m := protowire.ConsumeFieldValue(num, typ, unknown)
+		if m < 0 {
+			return 0, false
+		}
+		unknown = unknown[m:]
+	}
+
+	if !found {
+		return 0, false
+	}
+	if capacity > uint64(node.MaxCapacity) {
+		capacity = uint64(node.MaxCapacity)
+	}
+	return uint32(capacity), true
+}
+
+// updateCapacity stores the capacity hint sent in the poll on the node state.
+// The hint is advisory and is clamped to node.MaxCapacity.
+func updateCapacity(n *node.State, msg *pb.PermissioningPoll) {
+	capacity, ok := getCapacityHint(msg)
+	if !ok || capacity == n.GetCapacity() {
+		return
+	}
+
+	jww.DEBUG.Printf("Node %s reported a capacity of %d", n.GetID(),
+		n.SetCapacity(capacity))
+}
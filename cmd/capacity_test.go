@@ -0,0 +1,64 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package cmd
+
+import (
+	pb "gitlab.com/elixxir/comms/mixmessages"
+	"gitlab.com/elixxir/registration/storage/node"
+	"google.golang.org/protobuf/encoding/protowire"
+	"testing"
+)
+
+// newCapacityPoll returns a poll carrying the given capacity hint, along with
+// an unrelated unknown field.
+func newCapacityPoll(capacity uint64) *pb.PermissioningPoll {
+	var unknown []byte
+	unknown = protowire.AppendTag(unknown, 99, protowire.BytesType)
+	unknown = protowire.AppendBytes(unknown, []byte("unrelated"))
+	unknown = protowire.AppendTag(unknown, capacityPollField, protowire.VarintType)
+	unknown = protowire.AppendVarint(unknown, capacity)
+
+	msg := &pb.PermissioningPoll{ServerAddress: "1.2.3.4:11420"}
+	msg.ProtoReflect().SetUnknown(unknown)
+	return msg
+}
+
+// Tests that getCapacityHint reads the capacity from the poll's unknown fields
+// and clamps it to node.MaxCapacity.
+func TestGetCapacityHint(t *testing.T) {
+	if _, ok := getCapacityHint(&pb.PermissioningPoll{}); ok {
+		t.Errorf("Capacity hint found in poll without one.")
+	}
+
+	capacity, ok := getCapacityHint(newCapacityPoll(640))
+	if !ok || capacity != 640 {
+		t.Errorf("Unexpected capacity hint.\nexpected: %d\nreceived: %d, %t",
+			640, capacity, ok)
+	}
+
+	capacity, ok = getCapacityHint(newCapacityPoll(1 << 40))
+	if !ok || capacity != node.MaxCapacity {
+		t.Errorf("Capacity hint not clamped.\nexpected: %d\nreceived: %d, %t",
+			node.MaxCapacity, capacity, ok)
+	}
+}
+
+// Tests that updateCapacity stores the poll's capacity hint on the node.
+func TestUpdateCapacity(t *testing.T) {
+	n := &node.State{}
+	updateCapacity(n, &pb.PermissioningPoll{})
+	if n.GetCapacity() != 0 {
+		t.Errorf("Capacity set from poll without a hint: %d", n.GetCapacity())
+	}
+
+	updateCapacity(n, newCapacityPoll(256))
+	if n.GetCapacity() != 256 {
+		t.Errorf("Capacity not updated.\nexpected: %d\nreceived: %d",
+			256, n.GetCapacity())
+	}
+}
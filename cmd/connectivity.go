@@ -0,0 +1,100 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Handles probing the connectivity of nodes, and keeping it across restarts so
+// that the whole network is not checked again at once on startup
+
+package cmd
+
+import (
+	jww "github.com/spf13/jwalterweatherman"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/elixxir/registration/storage/node"
+	"gitlab.com/xx_network/comms/connect"
+	"gitlab.com/xx_network/primitives/id"
+	"time"
+)
+
+// Probes whether the host can be contacted, replaced in testing
+var hostOnline = func(h *connect.Host) bool {
+	_, isOnline := h.IsOnline()
+	return isOnline
+}
+
+// probeHost returns true if the host can be contacted. A failed probe is
+// retried connectivityProbeRetries times, connectivityProbeRetryDelay apart,
+// so that a single dropped connection does not fail the port.
+func (m *RegistrationImpl) probeHost(h *connect.Host) bool {
+	for attempt := uint(0); ; attempt++ {
+		if hostOnline(h) {
+			return true
+		}
+		if attempt >= m.params.connectivityProbeRetries {
+			return false
+		}
+		jww.DEBUG.Printf("Connectivity probe of %s at %s failed, retrying "+
+			"in %s", h.GetId(), h.GetAddress(),
+			m.params.connectivityProbeRetryDelay)
+		time.Sleep(m.params.connectivityProbeRetryDelay)
+	}
+}
+
+// storeConnectivity records the connectivity of the node in Storage when
+// connectivity is persisted.
+func (m *RegistrationImpl) storeConnectivity(n *node.State, connectivity uint32) {
+	if !m.params.persistConnectivity {
+		return
+	}
+	err := storage.PermissioningDb.UpdateNodeConnectivity(n.GetID(), connectivity)
+	if err != nil {
+		jww.WARN.Printf("Failed to store connectivity of node %s: %+v",
+			n.GetID(), err)
+	}
+}
+
+// restoreConnectivity restores the last known connectivity of the loaded
+// nodes when connectivity is persisted. Each restored node is checked again
+// at its own point within the reprobe window, so that the checks are spread
+// across it instead of all happening at once.
+func (m *RegistrationImpl) restoreConnectivity(nodes []*storage.Node, now time.Time) {
+	if !m.params.persistConnectivity {
+		return
+	}
+
+	var restored []*node.State
+	var connectivity []uint32
+	for _, n := range nodes {
+		switch n.Connectivity {
+		case node.PortSuccessful, node.NodePortFailed, node.GatewayPortFailed,
+			node.PortFailed:
+		default:
+			continue
+		}
+		nid, err := id.Unmarshal(n.Id)
+		if err != nil {
+			continue
+		}
+		ns := m.State.GetNodeMap().GetNode(nid)
+		if ns == nil {
+			continue
+		}
+		restored = append(restored, ns)
+		connectivity = append(connectivity, n.Connectivity)
+	}
+
+	for i, ns := range restored {
+		offset := m.params.connectivityReprobeWindow *
+			time.Duration(i+1) / time.Duration(len(restored))
+		ns.RestoreConnectivity(connectivity[i], now.Add(offset))
+	}
+
+	if len(restored) > 0 {
+		jww.INFO.Printf("Restored the connectivity of %d node(s), checking "+
+			"them again over %s", len(restored),
+			m.params.connectivityReprobeWindow)
+	}
+}
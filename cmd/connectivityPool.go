@@ -0,0 +1,164 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Handles bounding the number of connectivity checks run at once, so that a
+// mass reconnect of nodes cannot exhaust the server's file descriptors
+
+package cmd
+
+import (
+	"github.com/pkg/errors"
+	jww "github.com/spf13/jwalterweatherman"
+	"gitlab.com/elixxir/registration/storage/node"
+	"gitlab.com/xx_network/comms/connect"
+	"gitlab.com/xx_network/primitives/id"
+	"sync"
+	"sync/atomic"
+)
+
+// Number of connectivity checks waiting for a worker which are kept when no
+// queue size is given
+const defaultConnectivityQueueSize = 1024
+
+// ConnectivityCheckStats describes the connectivity checks handled by the
+// connectivity check workers.
+type ConnectivityCheckStats struct {
+	// Number of workers, the most checks which run at once
+	Workers int
+	// Checks running and waiting for a worker
+	Running int
+	Queued  int
+	// Checks finished, and dropped because the queue was full
+	Completed uint64
+	Dropped   uint64
+}
+
+// connectivityCheck is a queued check of a node's connectivity
+type connectivityCheck struct {
+	node  *node.State
+	check func()
+}
+
+// connectivityPool runs connectivity checks on a fixed number of workers.
+// Each node has at most one check outstanding.
+type connectivityPool struct {
+	workers int
+	queue   chan connectivityCheck
+
+	// Nodes with a check queued or running, and the check submitted for each
+	// while its check was outstanding, which is run once it finishes
+	pending map[id.ID]func()
+	mux     sync.Mutex
+
+	running   int32
+	completed uint64
+	dropped   uint64
+}
+
+// newConnectivityPool starts the given number of connectivity check workers,
+// which take checks from a queue of the given size. A queue size of 0
+// selects the default. No pool is returned for 0 workers, in which case every
+// check runs at once.
+func newConnectivityPool(workers, queueSize int) (*connectivityPool, error) {
+	if workers < 0 {
+		return nil, errors.Errorf("Number of connectivity check workers %d "+
+			"is negative", workers)
+	} else if queueSize < 0 {
+		return nil, errors.Errorf("Connectivity check queue size %d is "+
+			"negative", queueSize)
+	} else if workers == 0 {
+		return nil, nil
+	} else if queueSize == 0 {
+		queueSize = defaultConnectivityQueueSize
+	}
+
+	cp := &connectivityPool{
+		workers: workers,
+		queue:   make(chan connectivityCheck, queueSize),
+		pending: make(map[id.ID]func()),
+	}
+	for i := 0; i < workers; i++ {
+		go cp.work()
+	}
+	return cp, nil
+}
+
+// work runs queued checks. The check submitted for a node while its previous
+// check was outstanding is run by the same worker once that one finishes.
+func (cp *connectivityPool) work() {
+	for c := range cp.queue {
+		nid := *c.node.GetID()
+		for check := c.check; check != nil; {
+			atomic.AddInt32(&cp.running, 1)
+			check()
+			atomic.AddInt32(&cp.running, -1)
+			atomic.AddUint64(&cp.completed, 1)
+
+			cp.mux.Lock()
+			if check = cp.pending[nid]; check == nil {
+				delete(cp.pending, nid)
+			} else {
+				cp.pending[nid] = nil
+			}
+			cp.mux.Unlock()
+		}
+	}
+}
+
+// submit queues the check of the node's connectivity. If the node already has
+// a check outstanding, the check is held and run once that one finishes
+// instead, replacing any check held before it. When
+// the queue is full, the check is dropped and the node's connectivity is reset
+// to unknown so that it is checked again on its next poll. A nil pool runs the
+// check at once.
+func (cp *connectivityPool) submit(n *node.State, check func()) {
+	if cp == nil {
+		go check()
+		return
+	}
+
+	nid := *n.GetID()
+	cp.mux.Lock()
+	defer cp.mux.Unlock()
+	if _, outstanding := cp.pending[nid]; outstanding {
+		cp.pending[nid] = check
+		return
+	}
+
+	select {
+	case cp.queue <- connectivityCheck{node: n, check: check}:
+		cp.pending[nid] = nil
+	default:
+		atomic.AddUint64(&cp.dropped, 1)
+		n.SetConnectivity(node.PortUnknown)
+		jww.WARN.Printf("Dropped connectivity check of node %s, %d checks "+
+			"are already queued", &nid, len(cp.queue))
+	}
+}
+
+// stats returns the checks the pool handled. A nil pool reports none.
+func (cp *connectivityPool) stats() ConnectivityCheckStats {
+	if cp == nil {
+		return ConnectivityCheckStats{}
+	}
+	return ConnectivityCheckStats{
+		Workers:   cp.workers,
+		Running:   int(atomic.LoadInt32(&cp.running)),
+		Queued:    len(cp.queue),
+		Completed: atomic.LoadUint64(&cp.completed),
+		Dropped:   atomic.LoadUint64(&cp.dropped),
+	}
+}
+
+// GetConnectivityCheckStats returns the connectivity checks running, queued,
+// completed and dropped, to tell whether the workers keep up with the nodes.
+func (m *RegistrationImpl) GetConnectivityCheckStats(auth *connect.Auth) (ConnectivityCheckStats, error) {
+	if err := checkAdminAuth(auth); err != nil {
+		return ConnectivityCheckStats{}, err
+	}
+	return m.connectivityChecks.stats(), nil
+}
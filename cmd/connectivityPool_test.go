@@ -0,0 +1,210 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package cmd
+
+import (
+	"gitlab.com/elixxir/comms/registration"
+	"gitlab.com/elixxir/primitives/current"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/elixxir/registration/storage/node"
+	"gitlab.com/xx_network/comms/connect"
+	"gitlab.com/xx_network/primitives/id"
+	"gitlab.com/xx_network/primitives/region"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Tests that a flood of nodes with unknown connectivity never has more checks
+// running than there are workers, that each node is checked once however
+// often it polls, and that every node's connectivity is concluded.
+func TestRegistrationImpl_checkConnectivity_Bounded(t *testing.T) {
+	const numNodes = 200
+	const workers = 4
+
+	var err error
+	var closeDb func() error
+	storage.PermissioningDb, closeDb, err = storage.NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("Failed to create database: %+v", err)
+	}
+	t.Cleanup(func() { _ = closeDb() })
+	state, err := storage.NewState(getTestKey(), 8, "", "", region.GetCountryBins())
+	if err != nil {
+		t.Fatalf("Failed to create state: %+v", err)
+	}
+	pool, err := newConnectivityPool(workers, numNodes)
+	if err != nil {
+		t.Fatalf("Failed to create connectivity pool: %+v", err)
+	}
+	impl := &RegistrationImpl{
+		State:  state,
+		params: &Params{disableGeoBinning: true},
+		Comms: &registration.Comms{
+			ProtoComms: &connect.ProtoComms{
+				Manager: connect.NewManagerTesting(t),
+			},
+		},
+		connectivityChecks: pool,
+	}
+	params := connect.GetDefaultHostParams()
+	params.AuthEnabled = false
+
+	nodes := make([]*node.State, numNodes)
+	for i := range nodes {
+		nid := id.NewIdFromUInt(uint64(i), id.Node, t)
+		err = storage.PermissioningDb.InsertApplication(
+			&storage.Application{Id: uint64(i + 1)}, &storage.Node{
+				Code: "code" + strconv.Itoa(i), Id: nid.Marshal(),
+				ApplicationId: uint64(i + 1)})
+		if err != nil {
+			t.Fatalf("Failed to insert node: %+v", err)
+		}
+		err = state.GetNodeMap().AddNode(nid, "US", testAdvertisedAddr,
+			"1.2.3.4:22840", uint64(i+1))
+		if err != nil {
+			t.Fatalf("Failed to add node: %+v", err)
+		}
+		if _, err = impl.Comms.AddHost(nid, testAdvertisedAddr, nil, params); err != nil {
+			t.Fatalf("Failed to add host: %+v", err)
+		}
+		nodes[i] = state.GetNodeMap().GetNode(nid)
+	}
+
+	var running, maxRunning int32
+	var mux sync.Mutex
+	probes := make(map[id.ID]int)
+	defer func(probe func(*connect.Host) bool) { hostOnline = probe }(hostOnline)
+	hostOnline = func(h *connect.Host) bool {
+		now := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		mux.Lock()
+		if now > maxRunning {
+			maxRunning = now
+		}
+		probes[*h.GetId()]++
+		mux.Unlock()
+		time.Sleep(time.Millisecond)
+		return true
+	}
+
+	// Every node polls several times at once
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		for _, n := range nodes {
+			wg.Add(1)
+			go func(n *node.State) {
+				defer wg.Done()
+				if _, err := impl.checkConnectivity(n, "1.2.3.4", current.WAITING); err != nil {
+					t.Errorf("checkConnectivity() returned an error: %+v", err)
+				}
+			}(n)
+		}
+	}
+	wg.Wait()
+
+	for i := 0; pool.stats().Completed < numNodes; i++ {
+		if i == 500 {
+			t.Fatalf("Connectivity checks did not finish: %+v", pool.stats())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	for i, n := range nodes {
+		if c := n.GetRawConnectivity(); c != node.PortSuccessful {
+			t.Errorf("Node %d connectivity is %d, expected %d.", i, c,
+				node.PortSuccessful)
+		}
+	}
+
+	mux.Lock()
+	defer mux.Unlock()
+	if maxRunning > workers {
+		t.Errorf("%d probes ran at once with %d workers.", maxRunning, workers)
+	}
+	for hid, num := range probes {
+		if num != 1 {
+			t.Errorf("Host %s was probed %d times.", &hid, num)
+		}
+	}
+	if stats := pool.stats(); stats.Completed != numNodes || stats.Dropped != 0 {
+		t.Errorf("Unexpected connectivity check stats: %+v", stats)
+	}
+}
+
+// Tests that checks past the queue are dropped and counted, leaving the
+// node's connectivity unknown so that its next poll checks it again, and that
+// a check submitted while the node's check is outstanding runs after it.
+func TestConnectivityPool_submit(t *testing.T) {
+	pool, err := newConnectivityPool(1, 1)
+	if err != nil {
+		t.Fatalf("Failed to create connectivity pool: %+v", err)
+	}
+	sm := node.NewStateMap()
+	nodes := make([]*node.State, 3)
+	for i := range nodes {
+		nid := id.NewIdFromUInt(uint64(i), id.Node, t)
+		if err = sm.AddNode(nid, "", "", "", 0); err != nil {
+			t.Fatalf("Failed to add node: %+v", err)
+		}
+		nodes[i] = sm.GetNode(nid)
+		nodes[i].GetConnectivity()
+	}
+
+	release := make(chan struct{})
+	done := make(chan int, 4)
+	check := func(i int) func() {
+		return func() {
+			<-release
+			done <- i
+		}
+	}
+
+	// The first check runs, the second is queued and the third is dropped
+	pool.submit(nodes[0], check(0))
+	for i := 0; pool.stats().Running == 0; i++ {
+		if i == 100 {
+			t.Fatalf("Connectivity check did not start.")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	pool.submit(nodes[1], check(1))
+	pool.submit(nodes[2], check(2))
+	if stats := pool.stats(); stats.Dropped != 1 || stats.Queued != 1 {
+		t.Errorf("Unexpected connectivity check stats: %+v", stats)
+	}
+	if c := nodes[2].GetRawConnectivity(); c != node.PortUnknown {
+		t.Errorf("Dropped node connectivity is %d, expected %d.", c,
+			node.PortUnknown)
+	}
+
+	// A check submitted while the first node's check runs follows it before
+	// the queued check
+	pool.submit(nodes[0], check(3))
+	close(release)
+	var order []int
+	for len(order) < 3 {
+		select {
+		case i := <-done:
+			order = append(order, i)
+		case <-time.After(time.Second):
+			t.Fatalf("Connectivity checks did not finish: %v", order)
+		}
+	}
+	if order[0] != 0 || order[1] != 3 || order[2] != 1 {
+		t.Errorf("Unexpected order of connectivity checks: %v", order)
+	}
+
+	if _, err = newConnectivityPool(-1, 0); err == nil {
+		t.Errorf("Negative number of workers accepted.")
+	}
+	if pool, err = newConnectivityPool(0, 0); pool != nil || err != nil {
+		t.Errorf("Connectivity pool created without workers: %v, %+v", pool, err)
+	}
+}
@@ -0,0 +1,282 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package cmd
+
+import (
+	"gitlab.com/elixxir/comms/registration"
+	"gitlab.com/elixxir/primitives/current"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/elixxir/registration/storage/node"
+	"gitlab.com/xx_network/comms/connect"
+	"gitlab.com/xx_network/primitives/id"
+	"gitlab.com/xx_network/primitives/region"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Builds a registration impl with a fresh state over the current database,
+// holding the given nodes in its node map
+func newConnectivityTestImpl(t *testing.T, nodes []*id.ID) *RegistrationImpl {
+	state, err := storage.NewState(getTestKey(), 8, "", "", region.GetCountryBins())
+	if err != nil {
+		t.Fatalf("Failed to create state: %+v", err)
+	}
+	for _, nid := range nodes {
+		err = state.GetNodeMap().AddNode(nid, "US", "", "", 0)
+		if err != nil {
+			t.Fatalf("Failed to add node: %+v", err)
+		}
+	}
+	return &RegistrationImpl{
+		State: state,
+		params: &Params{
+			persistConnectivity:       true,
+			connectivityReprobeWindow: 10 * time.Minute,
+		},
+	}
+}
+
+// Tests that connectivity stored before a restart is restored afterwards, and
+// that the restored nodes are checked again spread across the reprobe window.
+func TestRegistrationImpl_restoreConnectivity(t *testing.T) {
+	var err error
+	var closeDb func() error
+	storage.PermissioningDb, closeDb, err = storage.NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("Failed to create database: %+v", err)
+	}
+	t.Cleanup(func() { _ = closeDb() })
+
+	var infos []node.Info
+	var nodes []*id.ID
+	for i := 0; i < 5; i++ {
+		code := "code" + strconv.Itoa(i)
+		infos = append(infos, node.Info{RegCode: code, Order: "US"})
+		nodes = append(nodes, id.NewIdFromUInt(uint64(i), id.Node, t))
+	}
+	storage.PopulateNodeRegistrationCodes(infos)
+	for i, nid := range nodes {
+		err = storage.PermissioningDb.RegisterNode(nid, []byte("salt"),
+			infos[i].RegCode, "0.0.0.0", "", "0.0.0.0", "",
+			storage.SelfServeRegistration)
+		if err != nil {
+			t.Fatalf("Failed to register node: %+v", err)
+		}
+	}
+
+	// Probe results before the restart, the last node is never probed
+	expected := []uint32{node.PortSuccessful, node.NodePortFailed,
+		node.GatewayPortFailed, node.PortFailed}
+	impl := newConnectivityTestImpl(t, nodes)
+	for i, c := range expected {
+		ns := impl.State.GetNodeMap().GetNode(nodes[i])
+		ns.SetConnectivity(c)
+		impl.storeConnectivity(ns, c)
+	}
+
+	// Restart
+	impl = newConnectivityTestImpl(t, nodes)
+	stored, err := storage.PermissioningDb.GetNodesByStatus(node.Active)
+	if err != nil {
+		t.Fatalf("Failed to get nodes: %+v", err)
+	}
+	now := time.Now()
+	impl.restoreConnectivity(stored, now)
+
+	for i, nid := range nodes {
+		c := impl.State.GetNodeMap().GetNode(nid).GetRawConnectivity()
+		want := node.PortUnknown
+		if i < len(expected) {
+			want = expected[i]
+		}
+		if c != want {
+			t.Errorf("Unexpected connectivity restored for node %d."+
+				"\nexpected: %d\nreceived: %d", i, want, c)
+		}
+	}
+
+	// Nothing is checked again before the first slot of the window, and
+	// everything has been by its end
+	reprobed := func(at time.Time) int {
+		count := 0
+		for _, nid := range nodes {
+			if impl.State.GetNodeMap().GetNode(nid).CheckReprobe(at) {
+				count++
+			}
+		}
+		return count
+	}
+	if n := reprobed(now.Add(2 * time.Minute)); n != 0 {
+		t.Errorf("%d node(s) checked again before their slot.", n)
+	}
+	if n := reprobed(now.Add(5 * time.Minute)); n != 2 {
+		t.Errorf("Expected 2 nodes to be checked again half way through "+
+			"the window, %d were.", n)
+	}
+	if n := reprobed(now.Add(10 * time.Minute)); n != 2 {
+		t.Errorf("Expected the last 2 nodes to be checked again by the end "+
+			"of the window, %d were.", n)
+	}
+	for i, nid := range nodes {
+		if c := impl.State.GetNodeMap().GetNode(nid).GetRawConnectivity(); c != node.PortUnknown {
+			t.Errorf("Node %d not marked unknown after being checked "+
+				"again: %d", i, c)
+		}
+	}
+}
+
+// Tests that connectivity is neither stored nor restored when it is not
+// persisted.
+func TestRegistrationImpl_restoreConnectivity_Disabled(t *testing.T) {
+	var err error
+	var closeDb func() error
+	storage.PermissioningDb, closeDb, err = storage.NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("Failed to create database: %+v", err)
+	}
+	t.Cleanup(func() { _ = closeDb() })
+
+	nid := id.NewIdFromUInt(0, id.Node, t)
+	impl := newConnectivityTestImpl(t, []*id.ID{nid})
+	impl.params.persistConnectivity = false
+
+	impl.restoreConnectivity([]*storage.Node{{Id: nid.Marshal(),
+		Connectivity: node.PortSuccessful}}, time.Now())
+	if c := impl.State.GetNodeMap().GetNode(nid).GetRawConnectivity(); c != node.PortUnknown {
+		t.Errorf("Connectivity restored when not persisted: %d", c)
+	}
+}
+
+// Runs a connectivity check of a node whose gateway probes fail the given
+// number of times before succeeding, returning the connectivity concluded
+func checkFlakyConnectivity(t *testing.T, gatewayFailures int, retries uint) uint32 {
+	var err error
+	var closeDb func() error
+	storage.PermissioningDb, closeDb, err = storage.NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("Failed to create database: %+v", err)
+	}
+	t.Cleanup(func() { _ = closeDb() })
+
+	nid := id.NewIdFromUInt(0, id.Node, t)
+	err = storage.PermissioningDb.InsertApplication(
+		&storage.Application{Id: 1}, &storage.Node{Code: "AAAA",
+			Id: nid.Marshal(), ApplicationId: 1})
+	if err != nil {
+		t.Fatalf("Failed to insert node: %+v", err)
+	}
+	state, err := storage.NewState(getTestKey(), 8, "", "", region.GetCountryBins())
+	if err != nil {
+		t.Fatalf("Failed to create state: %+v", err)
+	}
+	err = state.GetNodeMap().AddNode(nid, "US", testAdvertisedAddr,
+		"1.2.3.4:22840", 1)
+	if err != nil {
+		t.Fatalf("Failed to add node: %+v", err)
+	}
+	impl := &RegistrationImpl{
+		State: state,
+		params: &Params{
+			disableGeoBinning:           true,
+			connectivityProbeRetries:    retries,
+			connectivityProbeRetryDelay: time.Millisecond,
+		},
+		Comms: &registration.Comms{
+			ProtoComms: &connect.ProtoComms{
+				Manager: connect.NewManagerTesting(t),
+			},
+		},
+	}
+	params := connect.GetDefaultHostParams()
+	params.AuthEnabled = false
+	if _, err = impl.Comms.AddHost(nid, testAdvertisedAddr, nil, params); err != nil {
+		t.Fatalf("Failed to add host: %+v", err)
+	}
+
+	var mux sync.Mutex
+	failures := gatewayFailures
+	defer func(probe func(*connect.Host) bool) { hostOnline = probe }(hostOnline)
+	hostOnline = func(h *connect.Host) bool {
+		mux.Lock()
+		defer mux.Unlock()
+		if h.GetId().GetType() != id.Gateway {
+			return true
+		}
+		if failures > 0 {
+			failures--
+			return false
+		}
+		return true
+	}
+
+	n := state.GetNodeMap().GetNode(nid)
+	_, err = impl.checkConnectivity(n, "1.2.3.4", current.WAITING)
+	if err != nil {
+		t.Fatalf("checkConnectivity() returned an error: %+v", err)
+	}
+	for i := 0; i < 100; i++ {
+		if c := n.GetRawConnectivity(); c != node.PortVerifying {
+			return c
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("Connectivity check did not finish.")
+	return 0
+}
+
+// Tests that a port which fails its first probe but succeeds on a retry is not
+// marked as failed.
+func TestRegistrationImpl_checkConnectivity_RetrySucceeds(t *testing.T) {
+	if c := checkFlakyConnectivity(t, 2, 2); c != node.PortSuccessful {
+		t.Errorf("Node with a retried gateway probe was marked %d.", c)
+	}
+}
+
+// Tests that a port which fails every probe is marked as failed.
+func TestRegistrationImpl_checkConnectivity_RetriesFail(t *testing.T) {
+	if c := checkFlakyConnectivity(t, 3, 2); c != node.GatewayPortFailed {
+		t.Errorf("Node failing every gateway probe was marked %d.", c)
+	}
+}
+
+// Tests that the connectivity of a node without a host, or which cannot be
+// read from storage, is left unknown to be checked again instead of the check
+// failing.
+func TestRegistrationImpl_probeConnectivity_Unavailable(t *testing.T) {
+	var err error
+	var closeDb func() error
+	storage.PermissioningDb, closeDb, err = storage.NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("Failed to create database: %+v", err)
+	}
+	t.Cleanup(func() { _ = closeDb() })
+
+	noHost, notStored := id.NewIdFromUInt(0, id.Node, t), id.NewIdFromUInt(1, id.Node, t)
+	impl := newConnectivityTestImpl(t, []*id.ID{noHost, notStored})
+	impl.Comms = &registration.Comms{
+		ProtoComms: &connect.ProtoComms{Manager: connect.NewManagerTesting(t)},
+	}
+	params := connect.GetDefaultHostParams()
+	params.AuthEnabled = false
+	if _, err = impl.Comms.AddHost(notStored, testAdvertisedAddr, nil, params); err != nil {
+		t.Fatalf("Failed to add host: %+v", err)
+	}
+
+	for _, nid := range []*id.ID{noHost, notStored} {
+		n := impl.State.GetNodeMap().GetNode(nid)
+		if n.GetConnectivity() != node.PortUnknown {
+			t.Fatalf("Node %s did not start with unknown connectivity.", nid)
+		}
+		impl.probeConnectivity(n, n.Snapshot())
+		if c := n.GetRawConnectivity(); c != node.PortUnknown {
+			t.Errorf("Connectivity of node %s was set to %d.", nid, c)
+		}
+	}
+}
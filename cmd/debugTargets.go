@@ -0,0 +1,81 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Contains the administrative functions for elevating the logging of specific
+// rounds and nodes
+
+package cmd
+
+import (
+	"encoding/base64"
+	"github.com/pkg/errors"
+	jww "github.com/spf13/jwalterweatherman"
+	"gitlab.com/xx_network/comms/connect"
+	"gitlab.com/xx_network/primitives/id"
+)
+
+// SetDebugRound starts elevating the logging of the round to INFO when
+// enabled is true, and stops otherwise.
+func (m *RegistrationImpl) SetDebugRound(auth *connect.Auth, roundID id.Round,
+	enabled bool) error {
+	if err := checkAdminAuth(auth); err != nil {
+		return err
+	}
+	m.State.SetDebugRound(roundID, enabled)
+	jww.INFO.Printf("Targeted debugging of round %d set to %t", roundID, enabled)
+	return nil
+}
+
+// SetDebugNode starts elevating the logging of the node to INFO when enabled
+// is true, and stops otherwise.
+func (m *RegistrationImpl) SetDebugNode(auth *connect.Auth, nodeID *id.ID,
+	enabled bool) error {
+	if err := checkAdminAuth(auth); err != nil {
+		return err
+	}
+	if nodeID == nil {
+		return errors.New("Cannot set targeted debugging of a nil node ID")
+	}
+	m.State.SetDebugNode(nodeID, enabled)
+	jww.INFO.Printf("Targeted debugging of node %s set to %t", nodeID, enabled)
+	return nil
+}
+
+// GetDebugTargets returns the rounds and nodes whose logging is elevated.
+func (m *RegistrationImpl) GetDebugTargets(auth *connect.Auth) ([]id.Round, []*id.ID, error) {
+	if err := checkAdminAuth(auth); err != nil {
+		return nil, nil, err
+	}
+	rounds, nodes := m.State.GetDebugTargets()
+	return rounds, nodes, nil
+}
+
+// setConfiguredDebugTargets elevates the logging of the rounds and the base64
+// encoded node IDs given in the config.
+func (m *RegistrationImpl) setConfiguredDebugTargets(rounds []int, nodes []string) error {
+	for _, roundID := range rounds {
+		if roundID <= 0 {
+			return errors.Errorf("Invalid debug round ID %d", roundID)
+		}
+		m.State.SetDebugRound(id.Round(roundID), true)
+	}
+
+	for _, encoded := range nodes {
+		decoded, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return errors.Errorf("Failed to decode debug node ID %q: %+v",
+				encoded, err)
+		}
+		nodeID, err := id.Unmarshal(decoded)
+		if err != nil {
+			return errors.Errorf("Failed to unmarshal debug node ID %q: %+v",
+				encoded, err)
+		}
+		m.State.SetDebugNode(nodeID, true)
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package cmd
+
+import (
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/xx_network/comms/connect"
+	"gitlab.com/xx_network/primitives/id"
+	"gitlab.com/xx_network/primitives/region"
+	"reflect"
+	"testing"
+)
+
+// Tests that only the permissioning server can change the debug targets.
+func TestRegistrationImpl_SetDebugRound(t *testing.T) {
+	var err error
+	var dc func() error
+	storage.PermissioningDb, dc, err = storage.NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("Failed to create database: %+v", err)
+	}
+	t.Cleanup(func() { _ = dc() })
+	state, err := storage.NewState(getTestKey(), 8, "", "", region.GetCountryBins())
+	if err != nil {
+		t.Fatalf("Failed to create state: %+v", err)
+	}
+	impl := &RegistrationImpl{State: state}
+
+	permHost, err := connect.NewHost(&id.Permissioning, "", nil,
+		connect.GetDefaultHostParams())
+	if err != nil {
+		t.Fatalf("Failed to create host: %+v", err)
+	}
+	nid := id.NewIdFromString("node", id.Node, t)
+	nodeHost, err := connect.NewHost(nid, "", nil, connect.GetDefaultHostParams())
+	if err != nil {
+		t.Fatalf("Failed to create host: %+v", err)
+	}
+
+	nodeAuth := &connect.Auth{IsAuthenticated: true, Sender: nodeHost}
+	if err = impl.SetDebugRound(nodeAuth, 5, true); err == nil {
+		t.Errorf("Node was able to set a debug round.")
+	}
+	if err = impl.SetDebugNode(nodeAuth, nid, true); err == nil {
+		t.Errorf("Node was able to set a debug node.")
+	}
+
+	auth := &connect.Auth{IsAuthenticated: true, Sender: permHost}
+	if err = impl.SetDebugRound(auth, 5, true); err != nil {
+		t.Fatalf("Failed to set debug round: %+v", err)
+	}
+	if err = impl.SetDebugNode(auth, nid, true); err != nil {
+		t.Fatalf("Failed to set debug node: %+v", err)
+	}
+	if err = impl.SetDebugNode(auth, nil, true); err == nil {
+		t.Errorf("No error setting a nil debug node.")
+	}
+
+	rounds, nodes, err := impl.GetDebugTargets(auth)
+	if err != nil {
+		t.Fatalf("Failed to get debug targets: %+v", err)
+	}
+	if !reflect.DeepEqual(rounds, []id.Round{5}) || len(nodes) != 1 ||
+		!nodes[0].Cmp(nid) {
+		t.Errorf("Unexpected debug targets: %v %v", rounds, nodes)
+	}
+
+	if err = impl.SetDebugRound(auth, 5, false); err != nil {
+		t.Fatalf("Failed to unset debug round: %+v", err)
+	}
+	if rounds, _, _ = impl.GetDebugTargets(auth); len(rounds) != 0 {
+		t.Errorf("Debug round not removed: %v", rounds)
+	}
+}
+
+// Tests that the debug targets in the config are parsed and invalid ones are
+// rejected.
+func TestRegistrationImpl_setConfiguredDebugTargets(t *testing.T) {
+	impl := &RegistrationImpl{State: &storage.NetworkState{}}
+	nid := id.NewIdFromString("node", id.Node, t)
+
+	err := impl.setConfiguredDebugTargets([]int{7}, []string{nid.String()})
+	if err != nil {
+		t.Fatalf("Failed to set configured debug targets: %+v", err)
+	}
+	rounds, nodes := impl.State.GetDebugTargets()
+	if !reflect.DeepEqual(rounds, []id.Round{7}) || len(nodes) != 1 ||
+		!nodes[0].Cmp(nid) {
+		t.Errorf("Unexpected debug targets: %v %v", rounds, nodes)
+	}
+
+	if err = impl.setConfiguredDebugTargets([]int{0}, nil); err == nil {
+		t.Errorf("No error for an invalid round ID.")
+	}
+	if err = impl.setConfiguredDebugTargets(nil, []string{"not an ID"}); err == nil {
+		t.Errorf("No error for an invalid node ID.")
+	}
+}
@@ -0,0 +1,106 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package cmd
+
+// Contains alerting operators of nodes demoted to the diagnostic state and the
+// administrative reactivation of those nodes
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/pkg/errors"
+	jww "github.com/spf13/jwalterweatherman"
+	"gitlab.com/elixxir/registration/storage/node"
+	"gitlab.com/xx_network/comms/connect"
+	"gitlab.com/xx_network/primitives/id"
+	"net/http"
+	"time"
+)
+
+// How long posting a demotion alert to the diagnostic webhook may take
+const diagnosticWebhookTimeout = 10 * time.Second
+
+// nodeDemotionAlert is the body posted to the diagnostic webhook when a node
+// is demoted to the diagnostic state.
+type nodeDemotionAlert struct {
+	Node      string    `json:"node"`
+	Reason    string    `json:"reason"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// alertNodeDemotion posts an alert of the node's demotion to the diagnostic
+// webhook, if one is set. The alert is posted in another thread so as not to
+// block the scheduler, and failures are logged.
+func (m *RegistrationImpl) alertNodeDemotion(n *node.State, reason string) {
+	if m.params.diagnosticWebhook == "" {
+		return
+	}
+
+	alert := nodeDemotionAlert{
+		Node:      n.GetID().String(),
+		Reason:    reason,
+		Timestamp: time.Now(),
+	}
+	go func() {
+		err := postDiagnosticWebhook(m.params.diagnosticWebhook, alert)
+		if err != nil {
+			jww.ERROR.Printf("Failed to alert demotion of node %s: %+v",
+				alert.Node, err)
+		}
+	}()
+}
+
+// postDiagnosticWebhook posts the alert as JSON to the webhook URL.
+func postDiagnosticWebhook(url string, alert nodeDemotionAlert) error {
+	data, err := json.Marshal(alert)
+	if err != nil {
+		return errors.Errorf("Failed to marshal alert: %+v", err)
+	}
+
+	client := &http.Client{Timeout: diagnosticWebhookTimeout}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return errors.Errorf("Failed to post alert: %+v", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return errors.Errorf("Webhook responded with status %s", resp.Status)
+	}
+	return nil
+}
+
+// ReactivateNode takes the node out of the diagnostic state, returning it to
+// teams once it next waits.
+func (m *RegistrationImpl) ReactivateNode(auth *connect.Auth, nodeID *id.ID) error {
+	if err := checkAdminAuth(auth); err != nil {
+		return err
+	}
+	n := m.State.GetNodeMap().GetNode(nodeID)
+	if n == nil {
+		return errors.Errorf("Node %s is not registered", nodeID)
+	}
+	if !n.Reactivate() {
+		return errors.Errorf("Node %s is not in the diagnostic state", nodeID)
+	}
+	jww.INFO.Printf("Node %s reactivated from the diagnostic state", nodeID)
+	return nil
+}
+
+// GetNodeRejections returns the log of the reasons the node was held out of
+// the network, oldest first.
+func (m *RegistrationImpl) GetNodeRejections(auth *connect.Auth,
+	nodeID *id.ID) ([]node.Rejection, error) {
+	if err := checkAdminAuth(auth); err != nil {
+		return nil, err
+	}
+	n := m.State.GetNodeMap().GetNode(nodeID)
+	if n == nil {
+		return nil, errors.Errorf("Node %s is not registered", nodeID)
+	}
+	return n.GetRejections(), nil
+}
@@ -0,0 +1,101 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package cmd
+
+import (
+	"encoding/json"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/xx_network/comms/connect"
+	"gitlab.com/xx_network/primitives/id"
+	"gitlab.com/xx_network/primitives/region"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// Tests that a node demoted to the diagnostic state is alerted to the
+// diagnostic webhook, that its rejection log records the demotion and that it
+// is taken out of the state when reactivated.
+func TestRegistrationImpl_ReactivateNode(t *testing.T) {
+	var err error
+	var closeDb func() error
+	storage.PermissioningDb, closeDb, err = storage.NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("Failed to create database: %+v", err)
+	}
+	t.Cleanup(func() { _ = closeDb() })
+	state, err := storage.NewState(getTestKey(), 8, "", "", region.GetCountryBins())
+	if err != nil {
+		t.Fatalf("Failed to create state: %+v", err)
+	}
+
+	alerts := make(chan nodeDemotionAlert, 1)
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			var alert nodeDemotionAlert
+			if err := json.NewDecoder(r.Body).Decode(&alert); err != nil {
+				t.Errorf("Failed to decode alert: %+v", err)
+			}
+			alerts <- alert
+		}))
+	defer server.Close()
+
+	impl := &RegistrationImpl{
+		State:  state,
+		params: &Params{diagnosticWebhook: server.URL},
+	}
+	impl.State.SetNodeDemotionHandler(impl.alertNodeDemotion)
+
+	nid := id.NewIdFromUInt(0, id.Node, t)
+	if err = state.GetNodeMap().AddNode(nid, "US", "", "", 0); err != nil {
+		t.Fatalf("Failed to add node: %+v", err)
+	}
+	n := state.GetNodeMap().GetNode(nid)
+	n.Demote(time.Now(), "test reason")
+	state.NodeDemoted(n, "test reason")
+
+	select {
+	case alert := <-alerts:
+		if alert.Node != nid.String() || alert.Reason != "test reason" {
+			t.Errorf("Unexpected alert: %+v", alert)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Demotion was not alerted to the webhook.")
+	}
+
+	permHost, err := connect.NewHost(&id.Permissioning, "", nil,
+		connect.GetDefaultHostParams())
+	if err != nil {
+		t.Fatalf("Failed to create host: %+v", err)
+	}
+	if err = impl.ReactivateNode(&connect.Auth{Sender: permHost}, nid); err == nil {
+		t.Errorf("Unauthenticated sender was able to reactivate a node.")
+	}
+	auth := &connect.Auth{IsAuthenticated: true, Sender: permHost}
+	rejections, err := impl.GetNodeRejections(auth, nid)
+	if err != nil {
+		t.Fatalf("GetNodeRejections() returned an error: %+v", err)
+	}
+	if len(rejections) != 1 || rejections[0].Reason != "test reason" {
+		t.Errorf("Unexpected rejection log: %+v", rejections)
+	}
+
+	if err = impl.ReactivateNode(auth, nid); err != nil {
+		t.Fatalf("ReactivateNode() returned an error: %+v", err)
+	}
+	if n.IsDiagnostic() {
+		t.Errorf("Reactivated node is still in the diagnostic state.")
+	}
+	if err = impl.ReactivateNode(auth, nid); err == nil {
+		t.Errorf("Node not in the diagnostic state was reactivated.")
+	}
+	if err = impl.ReactivateNode(auth, id.NewIdFromUInt(1, id.Node, t)); err == nil {
+		t.Errorf("Unregistered node was reactivated.")
+	}
+}
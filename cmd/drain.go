@@ -0,0 +1,61 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Contains the administrative functions for draining the network for a
+// coordinated shutdown, and the drain status sent to nodes in poll responses
+
+package cmd
+
+import (
+	jww "github.com/spf13/jwalterweatherman"
+	pb "gitlab.com/elixxir/comms/mixmessages"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/xx_network/comms/connect"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// drainPollField is the field number of the network drain status in the
+// PermissionPollResponse message. It is sent as a varint of the
+// storage.DrainStatus in the message's unknown fields, only once the network
+// starts draining, until the comms message declares the field.
+const drainPollField protowire.Number = 18
+
+// DrainNetwork stops the creation of new rounds and lets the rounds in flight
+// finish, after which the network is drained and no longer takes node
+// updates. Unlike stopping round creation, draining is reported to the nodes
+// in their poll responses, and cannot be undone.
+func (m *RegistrationImpl) DrainNetwork(auth *connect.Auth) error {
+	if err := checkAdminAuth(auth); err != nil {
+		return err
+	}
+	if err := m.State.StartDrain(); err != nil {
+		return err
+	}
+	jww.WARN.Printf("Draining the network, no new rounds will be created")
+	return nil
+}
+
+// GetDrainStatus returns how far the network is through being drained.
+func (m *RegistrationImpl) GetDrainStatus(auth *connect.Auth) (storage.DrainStatus, error) {
+	if err := checkAdminAuth(auth); err != nil {
+		return storage.NotDraining, err
+	}
+	return m.State.GetDrainStatus(), nil
+}
+
+// setDrainStatus adds the network's drain status to the poll response once
+// the network starts draining.
+func setDrainStatus(response *pb.PermissionPollResponse,
+	status storage.DrainStatus) {
+	if status == storage.NotDraining {
+		return
+	}
+	unknown := response.ProtoReflect().GetUnknown()
+	unknown = protowire.AppendTag(unknown, drainPollField, protowire.VarintType)
+	unknown = protowire.AppendVarint(unknown, uint64(status))
+	response.ProtoReflect().SetUnknown(unknown)
+}
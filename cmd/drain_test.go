@@ -0,0 +1,123 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package cmd
+
+import (
+	pb "gitlab.com/elixxir/comms/mixmessages"
+	"gitlab.com/elixxir/primitives/current"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/xx_network/comms/connect"
+	"gitlab.com/xx_network/primitives/id"
+	"google.golang.org/protobuf/encoding/protowire"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Reads the drain status sent in the poll response, returning false if there
+// is none
+func getDrainStatus(t *testing.T, response *pb.PermissionPollResponse) (storage.DrainStatus, bool) {
+	unknown := response.ProtoReflect().GetUnknown()
+	for len(unknown) > 0 {
+		num, typ, n := protowire.ConsumeTag(unknown)
+		if n < 0 {
+			t.Fatalf("Malformed unknown fields: %v", unknown)
+		}
+		unknown = unknown[n:]
+		if num == drainPollField && typ == protowire.VarintType {
+			status, _ := protowire.ConsumeVarint(unknown)
+			return storage.DrainStatus(status), true
+		}
+		unknown = unknown[protowire.ConsumeFieldValue(num, typ, unknown):]
+	}
+	return storage.NotDraining, false
+}
+
+// Tests that only the permissioning server can drain the network, that it
+// can only be drained once, and that polling nodes are sent the drain status
+// once it starts draining.
+func TestRegistrationImpl_DrainNetwork(t *testing.T) {
+	var err error
+	dblck.Lock()
+	defer dblck.Unlock()
+
+	var dc func() error
+	storage.PermissioningDb, dc, err = storage.NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	defer func() { _ = dc() }()
+	err = storage.PermissioningDb.InsertEphemeralLength(
+		&storage.EphemeralLength{Length: 8, Timestamp: time.Now()})
+	if err != nil {
+		t.Errorf("Failed to insert ephemeral length into database: %+v", err)
+	}
+
+	impl, err := StartRegistration(testParams)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer impl.Comms.Shutdown()
+	atomic.CompareAndSwapUint32(impl.NdfReady, 0, 1)
+
+	// Banned nodes are answered without a full network
+	nid := id.NewIdFromString("drain", id.Node, t)
+	if err = impl.State.GetNodeMap().AddNode(nid, "", "", "", 0); err != nil {
+		t.Fatalf("Failed to add node: %+v", err)
+	}
+	if _, err = impl.State.GetNodeMap().GetNode(nid).Ban(); err != nil {
+		t.Fatalf("Failed to ban node: %+v", err)
+	}
+	nodeHost, _ := connect.NewHost(nid, "test", nil, connect.GetDefaultHostParams())
+	nodeAuth := &connect.Auth{IsAuthenticated: true, Sender: nodeHost}
+	permHost, _ := connect.NewHost(&id.Permissioning, "", nil,
+		connect.GetDefaultHostParams())
+	permAuth := &connect.Auth{IsAuthenticated: true, Sender: permHost}
+	msg := &pb.PermissioningPoll{
+		Full:           &pb.NDFHash{Hash: []byte("test")},
+		Partial:        &pb.NDFHash{Hash: []byte("test")},
+		Activity:       uint32(current.WAITING),
+		GatewayVersion: "1.1.0",
+		ServerVersion:  "1.1.0",
+	}
+	poll := func(stage string) (storage.DrainStatus, bool) {
+		response, _ := impl.Poll(msg, nodeAuth)
+		if response == nil {
+			t.Fatalf("No poll response %s.", stage)
+		}
+		return getDrainStatus(t, response)
+	}
+
+	if _, sent := poll("before draining"); sent {
+		t.Errorf("Drain status sent before the network was drained.")
+	}
+
+	if err = impl.DrainNetwork(nodeAuth); err == nil {
+		t.Errorf("Node was able to drain the network.")
+	}
+	if err = impl.DrainNetwork(permAuth); err != nil {
+		t.Fatalf("Failed to drain the network: %+v", err)
+	}
+	if err = impl.DrainNetwork(permAuth); err == nil {
+		t.Errorf("Network was drained twice.")
+	}
+	if status, sent := poll("while draining"); !sent || status != storage.Draining {
+		t.Errorf("Unexpected drain status while draining: %s, %t", status, sent)
+	}
+
+	impl.State.MarkDrained()
+	if status, sent := poll("once drained"); !sent || status != storage.Drained {
+		t.Errorf("Unexpected drain status once drained: %s, %t", status, sent)
+	}
+	if _, err = impl.GetDrainStatus(nodeAuth); err == nil {
+		t.Errorf("Node was able to get the drain status.")
+	}
+	if status, err := impl.GetDrainStatus(permAuth); err != nil || status != storage.Drained {
+		t.Errorf("Unexpected drain status: %s, %+v", status, err)
+	}
+}
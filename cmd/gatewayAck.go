@@ -0,0 +1,115 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Contains the tracking of the round updates gateways confirmed processing and
+// the safe update watermark published from them
+
+package cmd
+
+import (
+	jww "github.com/spf13/jwalterweatherman"
+	pb "gitlab.com/elixxir/comms/mixmessages"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/xx_network/comms/connect"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// gatewayAckPollField is the field number of the gateway's acknowledgment in
+// the PermissioningPoll message. Nodes send the highest round update ID their
+// gateway confirmed processing as a varint; until the comms message declares
+// the field, it is read from the message's unknown fields.
+const gatewayAckPollField protowire.Number = 17
+
+// safeUpdatePollField is the field number of the safe update watermark in the
+// PermissionPollResponse message. It is sent as a varint in the message's
+// unknown fields, alongside the earliest rounds, until the comms message
+// declares the field.
+const safeUpdatePollField protowire.Number = 17
+
+// getGatewayAck returns the round update ID the node reported its gateway
+// confirmed processing, and false if the poll carries none.
+func getGatewayAck(msg *pb.PermissioningPoll) (uint64, bool) {
+	unknown := msg.ProtoReflect().GetUnknown()
+	var ack uint64
+	reported := false
+	for len(unknown) > 0 {
+		num, typ, n := protowire.ConsumeTag(unknown)
+		if n < 0 {
+			return 0, false
+		}
+		unknown = unknown[n:]
+
+		if num == gatewayAckPollField && typ == protowire.VarintType {
+			v, m := protowire.ConsumeVarint(unknown)
+			if m < 0 {
+				return 0, false
+			}
+			ack, reported = v, true
+			unknown = unknown[m:]
+			continue
+		}
+
+		m := protowire.ConsumeFieldValue(num, typ, unknown)
+		if m < 0 {
+			return 0, false
+		}
+		unknown = unknown[m:]
+	}
+	return ack, reported
+}
+
+// checkGatewayAcks updates the safe update watermark from the acknowledgments
+// of the active nodes' gateways, warning about every node whose
+// acknowledgment lags the newest update by more than gatewayAckLagThreshold.
+func (m *RegistrationImpl) checkGatewayAcks() {
+	status := m.State.GetGatewayAckStatus(m.params.gatewayAckLagThreshold)
+	for _, nid := range status.Lagging {
+		n := m.State.GetNodeMap().GetNode(nid)
+		if n == nil {
+			continue
+		}
+		ack, _ := n.GetGatewayAck()
+		jww.WARN.Printf("Gateway of node %s acknowledged round update %d, "+
+			"%d behind the newest update %d", nid, ack,
+			status.LatestUpdate-ack, status.LatestUpdate)
+	}
+	m.gatewayAcks.Store(&status)
+}
+
+// getGatewayAckStatus returns the acknowledgments found by the last check,
+// and false if none was made.
+func (m *RegistrationImpl) getGatewayAckStatus() (storage.GatewayAckStatus, bool) {
+	status, ok := m.gatewayAcks.Load().(*storage.GatewayAckStatus)
+	if !ok || status == nil {
+		return storage.GatewayAckStatus{}, false
+	}
+	return *status, true
+}
+
+// setSafeUpdateWatermark adds the safe update watermark to the poll response,
+// if any gateway reported acknowledgments as of the last check.
+func (m *RegistrationImpl) setSafeUpdateWatermark(response *pb.PermissionPollResponse) {
+	status, ok := m.getGatewayAckStatus()
+	if !ok || status.Reporting == 0 {
+		return
+	}
+	unknown := response.ProtoReflect().GetUnknown()
+	unknown = protowire.AppendTag(unknown, safeUpdatePollField,
+		protowire.VarintType)
+	unknown = protowire.AppendVarint(unknown, status.Watermark)
+	response.ProtoReflect().SetUnknown(unknown)
+}
+
+// GetGatewayAckStatus returns the safe update watermark and the nodes whose
+// gateways lag behind it, as of the last check.
+func (m *RegistrationImpl) GetGatewayAckStatus(auth *connect.Auth) (storage.GatewayAckStatus, error) {
+	if err := checkAdminAuth(auth); err != nil {
+		return storage.GatewayAckStatus{}, err
+	}
+	status, _ := m.getGatewayAckStatus()
+	return status, nil
+}
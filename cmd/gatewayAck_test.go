@@ -0,0 +1,148 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package cmd
+
+import (
+	"bytes"
+	jww "github.com/spf13/jwalterweatherman"
+	pb "gitlab.com/elixxir/comms/mixmessages"
+	"gitlab.com/elixxir/primitives/states"
+	"gitlab.com/xx_network/comms/connect"
+	"gitlab.com/xx_network/primitives/id"
+	"google.golang.org/protobuf/encoding/protowire"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Builds a poll reporting the update ID as the gateway's acknowledgment
+func newGatewayAckPoll(ack uint64) *pb.PermissioningPoll {
+	msg := &pb.PermissioningPoll{}
+	field := protowire.AppendTag(nil, gatewayAckPollField, protowire.VarintType)
+	msg.ProtoReflect().SetUnknown(protowire.AppendVarint(field, ack))
+	return msg
+}
+
+// Returns the safe update watermark sent in the poll response, and false if
+// none was sent
+func getSafeUpdateWatermark(t *testing.T, response *pb.PermissionPollResponse) (uint64, bool) {
+	unknown := response.ProtoReflect().GetUnknown()
+	for len(unknown) > 0 {
+		num, typ, n := protowire.ConsumeTag(unknown)
+		if n < 0 {
+			t.Fatalf("Failed to consume tag of poll response field")
+		}
+		unknown = unknown[n:]
+		if num == safeUpdatePollField && typ == protowire.VarintType {
+			v, _ := protowire.ConsumeVarint(unknown)
+			return v, true
+		}
+		unknown = unknown[protowire.ConsumeFieldValue(num, typ, unknown):]
+	}
+	return 0, false
+}
+
+// Tests that the acknowledgment is read from the poll, and that a poll without
+// one reports none.
+func TestGetGatewayAck(t *testing.T) {
+	if ack, reported := getGatewayAck(newGatewayAckPoll(42)); !reported || ack != 42 {
+		t.Errorf("Unexpected acknowledgment: %d, %t", ack, reported)
+	}
+	if ack, reported := getGatewayAck(newGatewayAckPoll(0)); !reported || ack != 0 {
+		t.Errorf("Acknowledgment of update 0 not read: %d, %t", ack, reported)
+	}
+	if _, reported := getGatewayAck(&pb.PermissioningPoll{}); reported {
+		t.Errorf("Acknowledgment read from a poll without one.")
+	}
+}
+
+// Tests that gateways lagging behind the newest round update hold back the
+// safe update watermark sent with the earliest rounds, and are warned about.
+func TestRegistrationImpl_checkGatewayAcks(t *testing.T) {
+	state, host := newPolicyTestState(t)
+	impl := &RegistrationImpl{State: state,
+		params: &Params{gatewayAckLagThreshold: 5}}
+
+	// No watermark is sent before acknowledgments were checked
+	response, _ := impl.Poll(&pb.PermissioningPoll{}, &connect.Auth{Sender: host})
+	if _, sent := getSafeUpdateWatermark(t, response); sent {
+		t.Errorf("Watermark sent before acknowledgments were checked.")
+	}
+
+	for i := 0; i < 20; i++ {
+		err := state.AddRoundUpdate(&pb.RoundInfo{
+			ID:         1,
+			State:      uint32(states.PRECOMPUTING),
+			Timestamps: make([]uint64, states.NUM_STATES),
+		})
+		if err != nil {
+			t.Fatalf("Failed to add round update: %+v", err)
+		}
+	}
+	// Updates are published asynchronously
+	next, err := state.GetUpdateID()
+	if err != nil {
+		t.Fatalf("Failed to get update ID: %+v", err)
+	}
+	for i := 0; uint64(state.GetLastUpdateID())+1 < next; i++ {
+		if i == 100 {
+			t.Fatalf("Round updates were not published.")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	latest := next - 1
+
+	lagging := id.NewIdFromString("lagging", id.Node, t)
+	if err := state.GetNodeMap().AddNode(lagging, "", "", "", 2); err != nil {
+		t.Fatalf("Failed to add node: %+v", err)
+	}
+	for nid, msg := range map[*id.ID]*pb.PermissioningPoll{
+		host.GetId(): newGatewayAckPoll(latest),
+		lagging:      newGatewayAckPoll(latest - 7),
+	} {
+		ack, _ := getGatewayAck(msg)
+		state.GetNodeMap().GetNode(nid).SetGatewayAck(ack)
+	}
+
+	buf := &bytes.Buffer{}
+	threshold := jww.StdoutThreshold()
+	jww.SetStdoutOutput(buf)
+	jww.SetStdoutThreshold(jww.LevelWarn)
+	impl.checkGatewayAcks()
+	jww.SetStdoutOutput(os.Stdout)
+	jww.SetStdoutThreshold(threshold)
+
+	if !strings.Contains(buf.String(), lagging.String()) ||
+		strings.Contains(buf.String(), host.GetId().String()) {
+		t.Errorf("Unexpected warnings for lagging gateways: %s", buf.String())
+	}
+
+	response, _ = impl.Poll(&pb.PermissioningPoll{}, &connect.Auth{Sender: host})
+	if watermark, sent := getSafeUpdateWatermark(t, response); !sent || watermark != latest-7 {
+		t.Errorf("Unexpected watermark sent: %d, %t", watermark, sent)
+	}
+
+	permHost, err := connect.NewHost(&id.Permissioning, "", nil,
+		connect.GetDefaultHostParams())
+	if err != nil {
+		t.Fatalf("Failed to create host: %+v", err)
+	}
+	status, err := impl.GetGatewayAckStatus(
+		&connect.Auth{IsAuthenticated: true, Sender: permHost})
+	if err != nil {
+		t.Fatalf("Failed to get acknowledgment status: %+v", err)
+	}
+	if status.Watermark != latest-7 || status.LatestUpdate != latest ||
+		status.Reporting != 2 || len(status.Lagging) != 1 {
+		t.Errorf("Unexpected acknowledgment status: %+v", status)
+	}
+	if _, err = impl.GetGatewayAckStatus(&connect.Auth{Sender: host}); err == nil {
+		t.Errorf("Node was able to get the acknowledgment status.")
+	}
+}
@@ -0,0 +1,71 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Contains the handling of gateways which serve clients on a port separate
+// from the one they serve their node on
+
+package cmd
+
+import (
+	"github.com/pkg/errors"
+	pb "gitlab.com/elixxir/comms/mixmessages"
+	"google.golang.org/protobuf/encoding/protowire"
+	"math"
+	"net"
+	"strconv"
+)
+
+// gatewayClientPortPollField is the field number of the gateway's client port
+// in the PermissioningPoll message. Gateways serving clients on a port other
+// than that of GatewayAddress send it as a varint; until the comms message
+// declares the field, it is read from the message's unknown fields.
+const gatewayClientPortPollField protowire.Number = 16
+
+// getGatewayClientPort returns the client port of the gateway sent in the
+// poll, or zero if the gateway serves clients on its reported address. Returns
+// an error if the port is not valid.
+func getGatewayClientPort(msg *pb.PermissioningPoll) (uint16, error) {
+	unknown := msg.ProtoReflect().GetUnknown()
+	var port uint64
+	for len(unknown) > 0 {
+		num, typ, n := protowire.ConsumeTag(unknown)
+		if n < 0 {
+			return 0, nil
+		}
+		unknown = unknown[n:]
+
+		if num == gatewayClientPortPollField && typ == protowire.VarintType {
+			v, m := protowire.ConsumeVarint(unknown)
+			if m < 0 {
+				return 0, nil
+			}
+			port = v
+			unknown = unknown[m:]
+			continue
+		}
+
+		m := protowire.ConsumeFieldValue(num, typ, unknown)
+		if m < 0 {
+			return 0, nil
+		}
+		unknown = unknown[m:]
+	}
+
+	if port > math.MaxUint16 {
+		return 0, errors.Errorf("Gateway client port %d is not a valid "+
+			"port", port)
+	}
+
+	// A client port the same as the node facing one is a single address
+	if port != 0 && msg.GatewayAddress != "" {
+		_, gwPort, err := net.SplitHostPort(msg.GatewayAddress)
+		if err == nil && gwPort == strconv.FormatUint(port, 10) {
+			return 0, nil
+		}
+	}
+	return uint16(port), nil
+}
@@ -0,0 +1,252 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package cmd
+
+import (
+	pb "gitlab.com/elixxir/comms/mixmessages"
+	"gitlab.com/elixxir/comms/registration"
+	"gitlab.com/elixxir/primitives/current"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/elixxir/registration/storage/node"
+	"gitlab.com/xx_network/comms/connect"
+	"gitlab.com/xx_network/primitives/id"
+	"gitlab.com/xx_network/primitives/ndf"
+	"gitlab.com/xx_network/primitives/region"
+	"google.golang.org/protobuf/encoding/protowire"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	testGatewayAddr       = "1.2.3.4:22840"
+	testGatewayClientAddr = "1.2.3.4:8443"
+)
+
+// Builds a poll reporting the gateway address and, if it is not zero, the
+// gateway's client port
+func newGatewayPortPoll(gatewayAddress string, clientPort uint64) *pb.PermissioningPoll {
+	msg := &pb.PermissioningPoll{ServerAddress: testAdvertisedAddr,
+		GatewayAddress: gatewayAddress}
+	if clientPort != 0 {
+		field := protowire.AppendTag(nil, gatewayClientPortPollField,
+			protowire.VarintType)
+		field = protowire.AppendVarint(field, clientPort)
+		msg.ProtoReflect().SetUnknown(field)
+	}
+	return msg
+}
+
+// Tests that the gateway client port is read from the poll, that a poll
+// without one or with one matching the gateway's port reports a single
+// address, and that an invalid port is refused.
+func TestGetGatewayClientPort(t *testing.T) {
+	tests := map[string]struct {
+		msg      *pb.PermissioningPoll
+		expected uint16
+	}{
+		"single":   {newGatewayPortPoll(testGatewayAddr, 0), 0},
+		"dual":     {newGatewayPortPoll(testGatewayAddr, 8443), 8443},
+		"matching": {newGatewayPortPoll(testGatewayAddr, 22840), 0},
+	}
+	for name, tt := range tests {
+		port, err := getGatewayClientPort(tt.msg)
+		if err != nil {
+			t.Errorf("Unexpected error for %s poll: %+v", name, err)
+		} else if port != tt.expected {
+			t.Errorf("Unexpected client port for %s poll."+
+				"\nexpected: %d\nreceived: %d", name, tt.expected, port)
+		}
+	}
+
+	if _, err := getGatewayClientPort(newGatewayPortPoll(testGatewayAddr, 1<<16)); err == nil {
+		t.Errorf("Out of range client port was accepted.")
+	}
+}
+
+// Tests that a gateway client port reported in the poll is stored and
+// published in the partial NDF only, and that the gateway is published with
+// its single address once the port is no longer reported.
+func TestCheckIPAddresses_GatewayClientPort(t *testing.T) {
+	impl, n := setupObservedAddressTest(false, t)
+	gwID := n.GetID().DeepCopy()
+	gwID.SetType(id.Gateway)
+	impl.State.UpdateInternalNdf(&ndf.NetworkDefinition{
+		Nodes:    []ndf.Node{{ID: n.GetID().Marshal(), Address: testAdvertisedAddr}},
+		Gateways: []ndf.Gateway{{ID: gwID.Marshal()}},
+	})
+	err := storage.PermissioningDb.InsertApplication(
+		&storage.Application{Id: 1}, &storage.Node{Code: "AAAA",
+			Id: n.GetID().Marshal(), ServerAddress: testAdvertisedAddr,
+			ApplicationId: 1})
+	if err != nil {
+		t.Fatalf("Failed to insert node: %+v", err)
+	}
+	nodeHost, err := connect.NewHost(n.GetID(), testAdvertisedAddr, nil,
+		connect.GetDefaultHostParams())
+	if err != nil {
+		t.Fatalf("Failed to create host: %+v", err)
+	}
+	n.SetConnectivity(node.PortSuccessful)
+
+	err = checkIPAddresses(impl, n, newGatewayPortPoll(testGatewayAddr, 8443),
+		nodeHost)
+	if err != nil {
+		t.Fatalf("checkIPAddresses() produced an error: %+v", err)
+	}
+	if n.GetGatewayClientPort() != 8443 {
+		t.Errorf("Client port not set: %d", n.GetGatewayClientPort())
+	}
+	if n.GetConnectivity() != node.PortUnknown {
+		t.Errorf("Connectivity was not checked again for the new port.")
+	}
+	nDb, err := storage.PermissioningDb.GetNode("AAAA")
+	if err != nil {
+		t.Fatalf("Failed to get node: %+v", err)
+	}
+	if nDb.GatewayClientPort != 8443 {
+		t.Errorf("Client port not stored: %d", nDb.GatewayClientPort)
+	}
+
+	if err = impl.State.UpdateOutputNdf(); err != nil {
+		t.Fatalf("Failed to update output NDF: %+v", err)
+	}
+	if addr := impl.State.GetFullNdf().Get().Gateways[0].Address; addr != testGatewayAddr {
+		t.Errorf("Full NDF has gateway address %q instead of the node "+
+			"facing address.", addr)
+	}
+	if addr := impl.State.GetPartialNdf().Get().Gateways[0].Address; addr != testGatewayClientAddr {
+		t.Errorf("Partial NDF has gateway address %q instead of the client "+
+			"address.", addr)
+	}
+	if addr := impl.State.GetUnprunedNdf().Gateways[0].Address; addr != testGatewayAddr {
+		t.Errorf("Internal NDF gateway address changed to %q.", addr)
+	}
+
+	// The gateway goes back to a single address
+	err = checkIPAddresses(impl, n, newGatewayPortPoll(testGatewayAddr, 0),
+		nodeHost)
+	if err != nil {
+		t.Fatalf("checkIPAddresses() produced an error: %+v", err)
+	}
+	if n.GetGatewayClientPort() != 0 {
+		t.Errorf("Client port kept: %d", n.GetGatewayClientPort())
+	}
+	if err = impl.State.UpdateOutputNdf(); err != nil {
+		t.Fatalf("Failed to update output NDF: %+v", err)
+	}
+	if addr := impl.State.GetPartialNdf().Get().Gateways[0].Address; addr != testGatewayAddr {
+		t.Errorf("Partial NDF has gateway address %q for a single address "+
+			"gateway.", addr)
+	}
+}
+
+// Checks the connectivity of a node whose gateway reports the client port,
+// with the client port reachable or not, and returns the resulting
+// connectivity and the gateway addresses probed.
+func checkGatewayClientConnectivity(clientPort uint16, clientReachable bool,
+	t *testing.T) (uint32, []string) {
+	var err error
+	storage.PermissioningDb, _, err = storage.NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	nid := id.NewIdFromString("node", id.Node, t)
+	err = storage.PermissioningDb.InsertApplication(
+		&storage.Application{Id: 1}, &storage.Node{Code: "AAAA",
+			Id: nid.Marshal(), ApplicationId: 1})
+	if err != nil {
+		t.Fatalf("Failed to insert node: %+v", err)
+	}
+	state, err := storage.NewState(getTestKey(), 8, "", "", region.GetCountryBins())
+	if err != nil {
+		t.Fatalf("Failed to create state: %+v", err)
+	}
+	err = state.GetNodeMap().AddNode(nid, "US", testAdvertisedAddr,
+		testGatewayAddr, 1)
+	if err != nil {
+		t.Fatalf("Failed to add node: %+v", err)
+	}
+	impl := &RegistrationImpl{
+		State:  state,
+		params: &Params{disableGeoBinning: true},
+		Comms: &registration.Comms{
+			ProtoComms: &connect.ProtoComms{
+				Manager: connect.NewManagerTesting(t),
+			},
+		},
+	}
+	params := connect.GetDefaultHostParams()
+	params.AuthEnabled = false
+	if _, err = impl.Comms.AddHost(nid, testAdvertisedAddr, nil, params); err != nil {
+		t.Fatalf("Failed to add host: %+v", err)
+	}
+
+	var mux sync.Mutex
+	var probed []string
+	defer func(probe func(*connect.Host) bool) { hostOnline = probe }(hostOnline)
+	hostOnline = func(h *connect.Host) bool {
+		mux.Lock()
+		defer mux.Unlock()
+		if h.GetId().GetType() != id.Gateway {
+			return true
+		}
+		probed = append(probed, h.GetAddress())
+		return h.GetAddress() == testGatewayAddr || clientReachable
+	}
+
+	n := state.GetNodeMap().GetNode(nid)
+	n.SetGatewayClientPort(clientPort)
+	_, err = impl.checkConnectivity(n, "1.2.3.4", current.WAITING)
+	if err != nil {
+		t.Fatalf("checkConnectivity() returned an error: %+v", err)
+	}
+	for i := 0; i < 100; i++ {
+		if c := n.GetRawConnectivity(); c != node.PortVerifying {
+			mux.Lock()
+			defer mux.Unlock()
+			return c, probed
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("Connectivity check did not finish.")
+	return 0, nil
+}
+
+// Tests that a gateway with a client port is probed on both of its ports.
+func TestRegistrationImpl_checkConnectivity_GatewayClientPort(t *testing.T) {
+	c, probed := checkGatewayClientConnectivity(8443, true, t)
+	if c != node.PortSuccessful {
+		t.Errorf("Node with a reachable gateway was marked %d.", c)
+	}
+	if len(probed) != 2 || probed[0] != testGatewayAddr ||
+		probed[1] != testGatewayClientAddr {
+		t.Errorf("Gateway was not probed on both ports: %v", probed)
+	}
+}
+
+// Tests that a gateway which cannot be reached on its client port fails its
+// connectivity check.
+func TestRegistrationImpl_checkConnectivity_GatewayClientPortFails(t *testing.T) {
+	c, _ := checkGatewayClientConnectivity(8443, false, t)
+	if c != node.GatewayPortFailed {
+		t.Errorf("Node with an unreachable gateway client port was "+
+			"marked %d.", c)
+	}
+}
+
+// Tests that a gateway without a client port is only probed on its address.
+func TestRegistrationImpl_checkConnectivity_NoGatewayClientPort(t *testing.T) {
+	c, probed := checkGatewayClientConnectivity(0, false, t)
+	if c != node.PortSuccessful {
+		t.Errorf("Node with a single address gateway was marked %d.", c)
+	}
+	if len(probed) != 1 || probed[0] != testGatewayAddr {
+		t.Errorf("Unexpected gateway probes: %v", probed)
+	}
+}
@@ -0,0 +1,43 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Contains the handling of nodes which run without a public gateway
+
+package cmd
+
+import (
+	"github.com/pkg/errors"
+)
+
+// checkGatewayFields checks the gateway address and certificate a node
+// registers with. Both are required, unless gateway-less nodes are allowed, in
+// which case both may be left empty.
+func (m *RegistrationImpl) checkGatewayFields(gatewayAddr,
+	gatewayTlsCert string) error {
+	switch {
+	case gatewayAddr != "" && gatewayTlsCert != "":
+		return nil
+	case gatewayAddr == "" && gatewayTlsCert == "":
+		if m.params.allowGatewaylessNodes {
+			return nil
+		}
+		return errors.New("Cannot register a node without a gateway " +
+			"address and certificate")
+	case gatewayAddr == "":
+		return errors.New("Cannot register a node with a gateway " +
+			"certificate but no gateway address")
+	default:
+		return errors.Errorf("Cannot register a node with gateway address "+
+			"%s but no gateway certificate", gatewayAddr)
+	}
+}
+
+// isGatewayless returns true if a node registered with the given gateway
+// certificate runs without a public gateway.
+func (m *RegistrationImpl) isGatewayless(gatewayTlsCert string) bool {
+	return m.params.allowGatewaylessNodes && gatewayTlsCert == ""
+}
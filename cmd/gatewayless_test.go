@@ -0,0 +1,172 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package cmd
+
+import (
+	"bytes"
+	pb "gitlab.com/elixxir/comms/mixmessages"
+	"gitlab.com/elixxir/primitives/current"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/elixxir/registration/storage/node"
+	"gitlab.com/xx_network/comms/connect"
+	"gitlab.com/xx_network/primitives/id"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Tests that gateway fields are only allowed to be empty when gateway-less
+// nodes are, and never only one of them
+func TestRegistrationImpl_checkGatewayFields(t *testing.T) {
+	m := &RegistrationImpl{params: &Params{}}
+	if err := m.checkGatewayFields(nodeAddr, "cert"); err != nil {
+		t.Errorf("Gateway fields were refused: %+v", err)
+	}
+	if err := m.checkGatewayFields("", ""); err == nil {
+		t.Errorf("Empty gateway fields were allowed without the flag.")
+	}
+
+	m.params.allowGatewaylessNodes = true
+	if err := m.checkGatewayFields("", ""); err != nil {
+		t.Errorf("Empty gateway fields were refused with the flag: %+v", err)
+	}
+	if err := m.checkGatewayFields(nodeAddr, ""); err == nil {
+		t.Errorf("Gateway address without a certificate was allowed.")
+	}
+	if err := m.checkGatewayFields("", "cert"); err == nil {
+		t.Errorf("Gateway certificate without an address was allowed.")
+	}
+}
+
+// Tests that a node registered without a gateway is published in the NDF
+// without a gateway entry, passes its connectivity check without its gateway
+// being probed, and may not report a gateway address.
+func TestRegistrationImpl_RegisterNode_Gatewayless(t *testing.T) {
+	dblck.Lock()
+	defer dblck.Unlock()
+	var err error
+	var dc func() error
+	storage.PermissioningDb, dc, err = storage.NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	t.Cleanup(func() { _ = dc() })
+	err = storage.PermissioningDb.InsertEphemeralLength(
+		&storage.EphemeralLength{Length: 8, Timestamp: time.Now()})
+	if err != nil {
+		t.Errorf("Failed to insert ephemeral length into database: %+v", err)
+	}
+	storage.PopulateNodeRegistrationCodes([]node.Info{
+		{RegCode: "AAAA", Order: "US"}, {RegCode: "BBBB", Order: "US"}})
+
+	impl, err := StartRegistration(testParams)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	t.Cleanup(impl.Comms.Shutdown)
+
+	salt := bytes.Repeat([]byte{1}, 32)
+	if err = impl.RegisterNode(salt, nodeAddr, string(nodeCert), "", "",
+		"AAAA"); err == nil {
+		t.Errorf("Node registered without a gateway while not allowed.")
+	}
+	impl.params.allowGatewaylessNodes = true
+	if err = impl.RegisterNode(salt, nodeAddr, string(nodeCert), "", "",
+		"AAAA"); err != nil {
+		t.Fatalf("Failed to register gateway-less node: %+v", err)
+	}
+	err = impl.RegisterNode(bytes.Repeat([]byte{2}, 32), nodeAddr,
+		string(nodeCert), "0.0.0.0:6901", string(gatewayCert), "BBBB")
+	if err != nil {
+		t.Fatalf("Failed to register node: %+v", err)
+	}
+	other, err := storage.PermissioningDb.GetNode("BBBB")
+	if err != nil {
+		t.Fatalf("Failed to get node: %+v", err)
+	}
+	otherId, err := id.Unmarshal(other.Id)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal node ID: %+v", err)
+	}
+	info, err := storage.PermissioningDb.GetNode("AAAA")
+	if err != nil {
+		t.Fatalf("Failed to get node: %+v", err)
+	}
+	nid, err := id.Unmarshal(info.Id)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal node ID: %+v", err)
+	}
+	n := impl.State.GetNodeMap().GetNode(nid)
+	if !n.IsGatewayless() {
+		t.Fatalf("Node was not registered as gateway-less.")
+	}
+
+	// The published NDF has both nodes but only the gateway of the other
+	impl.State.RemovePrunedNode(nid)
+	impl.State.RemovePrunedNode(otherId)
+	if err = impl.State.UpdateOutputNdf(); err != nil {
+		t.Fatalf("Failed to output NDF: %+v", err)
+	}
+	def := impl.State.GetFullNdf().Get()
+	gwID := nid.DeepCopy()
+	gwID.SetType(id.Gateway)
+	if len(def.Nodes) != 2 || len(def.Gateways) != 1 ||
+		bytes.Equal(def.Gateways[0].ID, gwID.Bytes()) {
+		t.Errorf("NDF should publish 2 nodes and the other node's gateway."+
+			"\nnodes: %+v\ngateways: %+v", def.Nodes, def.Gateways)
+	}
+	if len(impl.State.GetUnprunedNdf().Gateways) != 2 {
+		t.Errorf("Internal NDF should keep a gateway entry per node.")
+	}
+
+	// Only the node is probed, and the node is found reachable
+	impl.params.disablePing = false
+	var mux sync.Mutex
+	var gatewayProbes int
+	defer func(probe func(*connect.Host) bool) { hostOnline = probe }(hostOnline)
+	hostOnline = func(h *connect.Host) bool {
+		mux.Lock()
+		defer mux.Unlock()
+		if h.GetId().GetType() == id.Gateway {
+			gatewayProbes++
+			return false
+		}
+		return true
+	}
+	impl.params.allowLocalIPs = true
+	if _, err = impl.checkConnectivity(n, "1.2.3.4", current.WAITING); err != nil {
+		t.Fatalf("checkConnectivity() returned an error: %+v", err)
+	}
+	for i := 0; i < 100 && n.GetRawConnectivity() == node.PortVerifying; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if c := n.GetRawConnectivity(); c != node.PortSuccessful {
+		t.Errorf("Gateway-less node was marked %d.", c)
+	}
+	mux.Lock()
+	if gatewayProbes != 0 {
+		t.Errorf("Gateway of gateway-less node was probed %d times.",
+			gatewayProbes)
+	}
+	mux.Unlock()
+
+	// A reachable gateway-less node is let through to scheduling
+	cont, err := impl.checkConnectivity(n, "1.2.3.4", current.WAITING)
+	if err != nil || !cont {
+		t.Errorf("Reachable gateway-less node was held back: %t, %+v",
+			cont, err)
+	}
+
+	// A gateway-less node cannot report a gateway address
+	nodeHost, _ := impl.Comms.GetHost(nid)
+	msg := &pb.PermissioningPoll{ServerAddress: nodeAddr,
+		GatewayAddress: "0.0.0.0:6902"}
+	if err = checkIPAddresses(impl, n, msg, nodeHost); err == nil {
+		t.Errorf("Gateway-less node reported a gateway address.")
+	}
+}
@@ -9,7 +9,6 @@ package cmd
 
 import (
 	"fmt"
-	"gitlab.com/xx_network/primitives/region"
 	"strconv"
 	"sync/atomic"
 
@@ -18,6 +17,7 @@ import (
 	jww "github.com/spf13/jwalterweatherman"
 	"gitlab.com/elixxir/registration/storage"
 	"gitlab.com/elixxir/registration/storage/node"
+	"gitlab.com/xx_network/primitives/region"
 	"gitlab.com/xx_network/primitives/utils"
 )
 
@@ -37,42 +37,79 @@ func (m *RegistrationImpl) setNodeGeos(n *node.State, location, geo_bin, gps_loc
 	return storage.PermissioningDb.UpdateGeoIP(n.GetAppID(), location, geo_bin, gps_location)
 }
 
+// nodeGeo is the geographic information of a node found from its IP address.
+type nodeGeo struct {
+	countryCode string
+	location    string
+	geoBin      string
+	gps         string
+}
+
 // setNodeSequence assigns a country code to each node
 func (m *RegistrationImpl) setNodeSequence(n *node.State, nodeIpAddr string) error {
-	var countryCode, countryName, city, gps string
-	var geobin region.GeoBin
-	var err error
-	var ok bool
-	// Get country code for node
+	var geo nodeGeo
 	if m.params.disableGeoBinning {
-		countryCode = n.GetOrdering()
+		geo.countryCode = n.GetOrdering()
 	} else {
-		countryCode, err = getAddressCountry(nodeIpAddr, m.geoIPDB, &m.geoIPDBStatus)
+		var ok bool
+		var err error
+		geo, ok, err = m.lookupNodeGeo(nodeIpAddr)
 		if err != nil {
-			return errors.WithMessage(err, "Failed to get country for address")
+			return err
 		}
-		city, err = getAddressCity(nodeIpAddr, m.geoIPDB, &m.geoIPDBStatus)
-		if err != nil {
-			return errors.WithMessage(err, "Failed to get city for address")
-		}
-		gps, err = getAddressCoords(nodeIpAddr, m.geoIPDB, &m.geoIPDBStatus)
-		if err != nil {
-			return errors.WithMessage(err, "Failed to get gps for address")
-		}
-		geobin, ok = region.GetCountryBin(countryCode)
 		if !ok {
-			return errors.WithMessage(err, "Could not get bin for country code")
-		}
-		countryName, err = lookupCountryName(nodeIpAddr, m.geoIPDB)
-		if err != nil {
-			return errors.WithMessage(err, "Could not get country name")
+			// Nodes in a country without a bin keep their current bin
+			return nil
 		}
 	}
 
 	// Update sequence for the node in the database
-	err = storage.PermissioningDb.UpdateNodeSequence(n.GetID(), countryCode)
+	err := storage.PermissioningDb.UpdateNodeSequence(n.GetID(), geo.countryCode)
 	if err != nil {
-		return errors.Errorf(setDbSequenceErr, n.GetID(), countryCode)
+		return errors.Errorf(setDbSequenceErr, n.GetID(), geo.countryCode)
+	}
+
+	err = storage.PermissioningDb.UpdateGeoIP(
+		n.GetAppID(), geo.location, geo.geoBin, geo.gps)
+
+	// Set the state ordering
+	n.SetOrdering(geo.countryCode)
+	return nil
+}
+
+// getCountryBin returns the geographic bin the country maps to in the state's
+// mapping, or in the built-in mapping if there is no state. Returns false if
+// the country is not mapped to a bin.
+func (m *RegistrationImpl) getCountryBin(country string) (region.GeoBin, bool) {
+	if m.State == nil {
+		bin, exists := region.GetCountryBins()[country]
+		return bin, exists
+	}
+	return m.State.GetCountryBin(country)
+}
+
+// lookupNodeGeo finds the country, location and bin of the IP address in the
+// GeoIP2 database. Returns false if the country has no bin.
+func (m *RegistrationImpl) lookupNodeGeo(nodeIpAddr string) (nodeGeo, bool, error) {
+	countryCode, err := getAddressCountry(nodeIpAddr, m.geoIPDB, &m.geoIPDBStatus)
+	if err != nil {
+		return nodeGeo{}, false, errors.WithMessage(err, "Failed to get country for address")
+	}
+	city, err := getAddressCity(nodeIpAddr, m.geoIPDB, &m.geoIPDBStatus)
+	if err != nil {
+		return nodeGeo{}, false, errors.WithMessage(err, "Failed to get city for address")
+	}
+	gps, err := getAddressCoords(nodeIpAddr, m.geoIPDB, &m.geoIPDBStatus)
+	if err != nil {
+		return nodeGeo{}, false, errors.WithMessage(err, "Failed to get gps for address")
+	}
+	geobin, ok := m.getCountryBin(countryCode)
+	if !ok {
+		return nodeGeo{countryCode: countryCode}, false, nil
+	}
+	countryName, err := lookupCountryName(nodeIpAddr, m.geoIPDB)
+	if err != nil {
+		return nodeGeo{}, false, errors.WithMessage(err, "Could not get country name")
 	}
 
 	// Generate the location string (exclude city if none is found)
@@ -81,12 +118,7 @@ func (m *RegistrationImpl) setNodeSequence(n *node.State, nodeIpAddr string) err
 		location = city + ", " + location
 	}
 
-	err = storage.PermissioningDb.UpdateGeoIP(
-		n.GetAppID(), location, geobin.String(), gps)
-
-	// Set the state ordering
-	n.SetOrdering(countryCode)
-	return nil
+	return nodeGeo{countryCode, location, geobin.String(), gps}, true, nil
 }
 
 // getAddressCountry returns an alpha-2 country code for the address. Panics if
@@ -39,7 +39,8 @@ func TestRegistrationImpl_setNodeBin_GeoIP2DB(t *testing.T) {
 
 	// Add an application to it
 	testID := id.NewIdFromUInt(0, id.Node, t)
-	applicationId := rand.Uint64()
+	// The database does not support uint64 values with the high bit set
+	applicationId := rand.Uint64() >> 1
 	err = storage.PermissioningDb.InsertApplication(
 		&storage.Application{Id: applicationId}, &storage.Node{Code: "AAAA"})
 	if err != nil {
@@ -47,7 +48,8 @@ func TestRegistrationImpl_setNodeBin_GeoIP2DB(t *testing.T) {
 	}
 
 	// Register a node
-	err = storage.PermissioningDb.RegisterNode(testID, nil, "AAAA", "", "", "", "")
+	err = storage.PermissioningDb.RegisterNode(testID, nil, "AAAA", "", "", "", "",
+		storage.SelfServeRegistration)
 	if err != nil {
 		t.Fatalf("Failed to register a node: %+v", err)
 	}
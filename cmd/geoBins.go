@@ -0,0 +1,123 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package cmd
+
+// Contains loading the mapping of countries to the geographic bins nodes are
+// binned into, and reloading it while running
+
+import (
+	"github.com/pkg/errors"
+	jww "github.com/spf13/jwalterweatherman"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/xx_network/comms/connect"
+	"gitlab.com/xx_network/primitives/id"
+	"gitlab.com/xx_network/primitives/region"
+	"gitlab.com/xx_network/primitives/utils"
+)
+
+// loadGeoBins returns the mapping of countries to the bins nodes are binned
+// into. It is read from the geoBinsPath file if one is set, from the geo bins
+// in Storage if blockchainGeoBinning is set, and otherwise is the built-in
+// mapping.
+func loadGeoBins(params *Params) (map[string]region.GeoBin, error) {
+	if params.geoBinsPath != "" {
+		data, err := utils.ReadFile(params.geoBinsPath)
+		if err != nil {
+			return nil, errors.Errorf("Failed to read GeoBins file: %+v", err)
+		}
+		geoBins, err := storage.ParseGeoBins(data)
+		if err != nil {
+			return nil, err
+		}
+		jww.INFO.Printf("Loaded %d GeoBins from %s!", len(geoBins),
+			params.geoBinsPath)
+		return geoBins, nil
+	}
+
+	if params.blockchainGeoBinning {
+		geoBins, err := storage.PermissioningDb.GetBins()
+		if err != nil {
+			return nil, err
+		}
+		jww.INFO.Printf("Loaded %d GeoBins from Storage!", len(geoBins))
+		return geoBins, nil
+	}
+
+	geoBins := region.GetCountryBins()
+	jww.INFO.Printf("Loaded %d GeoBins from Primitives!", len(geoBins))
+	return geoBins, nil
+}
+
+// warnUnmappedGeoBins warns of the countries registered nodes are in which the
+// mapping has no bin for. Those nodes are binned as unknown and cannot be
+// ordered into a team by the scheduler.
+func (m *RegistrationImpl) warnUnmappedGeoBins(geoBins map[string]region.GeoBin) {
+	unmapped := m.State.GetUnmappedCountries(geoBins)
+	if len(unmapped) > 0 {
+		jww.WARN.Printf("GeoBins do not map the countries %v of registered "+
+			"nodes", unmapped)
+	}
+}
+
+// LoadGeoBins loads the mapping of countries to bins again and replaces the
+// one nodes are binned by, warning of the countries of registered nodes it does
+// not map. The bins of the gateways in the NDF are updated to the new mapping.
+// A mapping which cannot be loaded leaves the current one in place.
+func (m *RegistrationImpl) LoadGeoBins() error {
+	geoBins, err := loadGeoBins(m.params)
+	if err != nil {
+		return errors.WithMessage(err, "Failed to reload GeoBins")
+	}
+	m.warnUnmappedGeoBins(geoBins)
+	m.State.SetGeoBins(geoBins)
+
+	m.State.InternalNdfLock.Lock()
+	currentNdf := m.State.GetUnprunedNdf()
+	if currentNdf == nil {
+		m.State.InternalNdfLock.Unlock()
+		return nil
+	}
+	changed := false
+	for i := range currentNdf.Gateways {
+		gwId, err := id.Unmarshal(currentNdf.Gateways[i].ID)
+		if err != nil {
+			m.State.InternalNdfLock.Unlock()
+			return errors.Errorf("Could not unmarshal ID from definition: "+
+				"%+v", err)
+		}
+		nid := gwId.DeepCopy()
+		nid.SetType(id.Node)
+		n := m.State.GetNodeMap().GetNode(nid)
+		if n == nil {
+			continue
+		}
+		bin, exists := geoBins[n.GetOrdering()]
+		if exists && bin != currentNdf.Gateways[i].Bin {
+			currentNdf.Gateways[i].Bin = bin
+			changed = true
+		}
+	}
+	if !changed {
+		m.State.InternalNdfLock.Unlock()
+		return nil
+	}
+	m.State.UpdateInternalNdf(currentNdf)
+	m.State.InternalNdfLock.Unlock()
+
+	return errors.WithMessage(m.State.UpdateOutputNdf(),
+		"Failed to update the NDF to the reloaded GeoBins")
+}
+
+// ReloadGeoBins reloads the mapping of countries to bins on an administrative
+// request. See LoadGeoBins.
+func (m *RegistrationImpl) ReloadGeoBins(auth *connect.Auth) error {
+	if err := checkAdminAuth(auth); err != nil {
+		return err
+	}
+	return m.LoadGeoBins()
+}
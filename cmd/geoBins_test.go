@@ -0,0 +1,127 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package cmd
+
+import (
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/xx_network/comms/connect"
+	"gitlab.com/xx_network/primitives/id"
+	"gitlab.com/xx_network/primitives/ndf"
+	"gitlab.com/xx_network/primitives/region"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Tests that a custom mapping of countries to bins is loaded from the
+// geoBinsPath file and used to bin nodes registering and moving into the
+// remapped countries, and that reloading it updates the bins in the NDF.
+func TestRegistrationImpl_LoadGeoBins(t *testing.T) {
+	impl := newGeoRebinTestImpl(t, time.Millisecond)
+	impl.params.geoBinsPath = filepath.Join(t.TempDir(), "geoBins.json")
+	writeGeoBins := func(data string) {
+		if err := os.WriteFile(impl.params.geoBinsPath, []byte(data), 0644); err != nil {
+			t.Fatalf("Failed to write GeoBins file: %+v", err)
+		}
+	}
+	writeGeoBins(`{"GB": "Oceania", "SE": "Oceania", "US": "NorthAmerica"}`)
+
+	permHost, err := connect.NewHost(&id.Permissioning, "", nil,
+		connect.GetDefaultHostParams())
+	if err != nil {
+		t.Fatalf("Failed to create host: %+v", err)
+	}
+	if err = impl.ReloadGeoBins(&connect.Auth{Sender: permHost}); err == nil {
+		t.Errorf("Unauthenticated sender was able to reload GeoBins.")
+	}
+	auth := &connect.Auth{IsAuthenticated: true, Sender: permHost}
+	if err = impl.ReloadGeoBins(auth); err != nil {
+		t.Fatalf("ReloadGeoBins() returned an error: %+v", err)
+	}
+
+	// A node registering in a remapped country is given its custom bin
+	registered := addGeoRebinTestNode(t, impl, 0, "GB", "81.2.69.160")
+	gateway, _, _, err := impl.assembleNdf("code0")
+	if err != nil {
+		t.Fatalf("Failed to assemble NDF of the node: %+v", err)
+	}
+	if gateway.Bin != region.Oceania {
+		t.Errorf("Registered gateway is in bin %s, expected %s.",
+			gateway.Bin, region.Oceania)
+	}
+
+	// A node moving into a remapped country is given its custom bin, while a
+	// node moving into an unmapped one keeps its bin
+	moved := addGeoRebinTestNode(t, impl, 1, "US", "89.160.20.112")
+	unmapped := addGeoRebinTestNode(t, impl, 2, "US", "202.196.224.6")
+	for _, nid := range []*id.ID{moved, unmapped} {
+		n := impl.State.GetNodeMap().GetNode(nid)
+		if err = impl.setNodeSequence(n, n.GetNodeAddresses()); err != nil {
+			t.Fatalf("Failed to set sequence of node %s: %+v", nid, err)
+		}
+	}
+	expected := map[*id.ID]string{registered: "Oceania", moved: "Oceania",
+		unmapped: "NorthAmerica"}
+	for nid, bin := range expected {
+		n := impl.State.GetNodeMap().GetNode(nid)
+		if nodeBin := impl.State.GetNodeBin(n); nodeBin != bin {
+			t.Errorf("Node in %s is in bin %s, expected %s.",
+				n.GetOrdering(), nodeBin, bin)
+		}
+	}
+	app, err := storage.PermissioningDb.GetApplication(2)
+	if err != nil {
+		t.Fatalf("Failed to get application: %+v", err)
+	}
+	if app.GeoBin != "Oceania" {
+		t.Errorf("Stored bin of the moved node is %s, expected %s.",
+			app.GeoBin, "Oceania")
+	}
+
+	// Reloading a changed mapping updates the bins of the gateways in the NDF
+	def := &ndf.NetworkDefinition{}
+	for nid := range expected {
+		gwId := nid.DeepCopy()
+		gwId.SetType(id.Gateway)
+		def.Nodes = append(def.Nodes, ndf.Node{ID: nid.Marshal()})
+		def.Gateways = append(def.Gateways,
+			ndf.Gateway{ID: gwId.Marshal(), Bin: region.Oceania})
+	}
+	impl.State.InternalNdfLock.Lock()
+	impl.State.UpdateInternalNdf(def)
+	impl.State.InternalNdfLock.Unlock()
+
+	writeGeoBins(`{"GB": "EasternAsia", "SE": "Oceania", "US": "NorthAmerica"}`)
+	if err = impl.LoadGeoBins(); err != nil {
+		t.Fatalf("LoadGeoBins() returned an error: %+v", err)
+	}
+	expectedBins := map[id.ID]region.GeoBin{*registered: region.EasternAsia,
+		*moved: region.Oceania, *unmapped: region.NorthAmerica}
+	for _, gw := range impl.State.GetUnprunedNdf().Gateways {
+		gwId, err := id.Unmarshal(gw.ID)
+		if err != nil {
+			t.Fatalf("Failed to unmarshal gateway ID: %+v", err)
+		}
+		nid := gwId.DeepCopy()
+		nid.SetType(id.Node)
+		if gw.Bin != expectedBins[*nid] {
+			t.Errorf("Gateway of node %s is in bin %s, expected %s.",
+				nid, gw.Bin, expectedBins[*nid])
+		}
+	}
+
+	// A mapping which cannot be loaded leaves the current one in place
+	writeGeoBins(`{"GB": "Atlantis"}`)
+	if err = impl.LoadGeoBins(); err == nil {
+		t.Errorf("Invalid GeoBins were loaded.")
+	}
+	if bin, _ := impl.State.GetCountryBin("GB"); bin != region.EasternAsia {
+		t.Errorf("Invalid GeoBins replaced the mapping of GB with %s.", bin)
+	}
+}
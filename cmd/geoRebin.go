@@ -0,0 +1,207 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Contains the administrative job looking up the geographic bins of all
+// active nodes again, for when the GeoIP2 database or the bins change
+
+package cmd
+
+import (
+	"github.com/pkg/errors"
+	jww "github.com/spf13/jwalterweatherman"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/elixxir/registration/storage/node"
+	"gitlab.com/xx_network/comms/connect"
+	"gitlab.com/xx_network/primitives/id"
+	"gitlab.com/xx_network/primitives/utils"
+	"sync"
+	"time"
+)
+
+// GeoRebinReport is the progress of the geo re-binning job.
+type GeoRebinReport struct {
+	// Whether the job is still running
+	Running bool
+
+	// When the job started and, once it is no longer running, finished
+	Started  time.Time
+	Finished time.Time
+
+	// Number of active nodes when the job started
+	Total int
+
+	// Number of nodes looked at so far, those whose sequence or geographic
+	// information changed, and those whose lookup failed
+	Processed int
+	Changed   int
+	Failed    int
+
+	// Nodes whose sequence or geographic information changed
+	ChangedNodes []*id.ID
+
+	// Nodes skipped because their address is not an IP address
+	Skipped []*id.ID
+}
+
+// geoRebinJob tracks the progress of the geo re-binning job.
+type geoRebinJob struct {
+	report GeoRebinReport
+	mux    sync.Mutex
+}
+
+// Outcomes of re-binning a node.
+const (
+	rebinUnchanged = iota
+	rebinChanged
+	rebinSkipped
+	rebinFailed
+)
+
+// StartGeoRebin starts looking up the country and bin of every active node
+// again in the GeoIP2 database, updating the nodes whose sequence or
+// geographic information changed. The job waits geoRebinInterval between
+// nodes. Returns an error if the job is already running or geo binning is not
+// in use.
+func (m *RegistrationImpl) StartGeoRebin(auth *connect.Auth) error {
+	if err := checkAdminAuth(auth); err != nil {
+		return err
+	}
+	if m.params.disableGeoBinning {
+		return errors.New("Geo binning is disabled")
+	}
+	if m.geoIPDB == nil || !m.geoIPDBStatus.IsRunning() {
+		return errors.New(ipdbNotRunningErr)
+	}
+
+	var nodes []*node.State
+	for _, n := range m.State.GetNodeMap().GetNodeStates() {
+		if n.GetStatus() == node.Active {
+			nodes = append(nodes, n)
+		}
+	}
+
+	m.geoRebin.mux.Lock()
+	defer m.geoRebin.mux.Unlock()
+	if m.geoRebin.report.Running {
+		return errors.New("Geo re-binning is already running")
+	}
+	m.geoRebin.report = GeoRebinReport{
+		Running: true,
+		Started: time.Now(),
+		Total:   len(nodes),
+	}
+
+	jww.INFO.Printf("Starting geo re-binning of %d nodes", len(nodes))
+	go m.runGeoRebin(nodes)
+	return nil
+}
+
+// GetGeoRebinReport returns the progress of the running geo re-binning job, or
+// the report of the last one to finish.
+func (m *RegistrationImpl) GetGeoRebinReport(auth *connect.Auth) (GeoRebinReport, error) {
+	if err := checkAdminAuth(auth); err != nil {
+		return GeoRebinReport{}, err
+	}
+
+	m.geoRebin.mux.Lock()
+	defer m.geoRebin.mux.Unlock()
+	report := m.geoRebin.report
+	report.ChangedNodes = append([]*id.ID(nil), report.ChangedNodes...)
+	report.Skipped = append([]*id.ID(nil), report.Skipped...)
+	return report, nil
+}
+
+// runGeoRebin re-bins each of the nodes in turn, recording the outcome of each
+// in the report.
+func (m *RegistrationImpl) runGeoRebin(nodes []*node.State) {
+	for i, n := range nodes {
+		if i > 0 {
+			time.Sleep(m.params.geoRebinInterval)
+		}
+		outcome := m.rebinNode(n)
+
+		m.geoRebin.mux.Lock()
+		m.geoRebin.report.Processed++
+		switch outcome {
+		case rebinChanged:
+			m.geoRebin.report.Changed++
+			m.geoRebin.report.ChangedNodes =
+				append(m.geoRebin.report.ChangedNodes, n.GetID())
+		case rebinSkipped:
+			m.geoRebin.report.Skipped = append(m.geoRebin.report.Skipped, n.GetID())
+		case rebinFailed:
+			m.geoRebin.report.Failed++
+		}
+		m.geoRebin.mux.Unlock()
+	}
+
+	m.geoRebin.mux.Lock()
+	defer m.geoRebin.mux.Unlock()
+	m.geoRebin.report.Running = false
+	m.geoRebin.report.Finished = time.Now()
+	jww.INFO.Printf("Finished geo re-binning: %d processed, %d changed, "+
+		"%d failed, %d skipped", m.geoRebin.report.Processed,
+		m.geoRebin.report.Changed, m.geoRebin.report.Failed,
+		len(m.geoRebin.report.Skipped))
+}
+
+// rebinNode looks up the node's country and bin again from the address its
+// polls are observed to come from, or its advertised address before it
+// polled, and updates its sequence and geographic information where they
+// changed.
+func (m *RegistrationImpl) rebinNode(n *node.State) int {
+	address, _ := n.GetObservedAddress()
+	if address == "" {
+		address = n.GetNodeAddresses()
+	}
+	if utils.ParseIP(address) == nil {
+		jww.WARN.Printf("Skipping geo re-binning of node %s: %s", n.GetID(),
+			errors.Errorf(parseIpErr, address))
+		return rebinSkipped
+	}
+
+	geo, ok, err := m.lookupNodeGeo(address)
+	if err == nil && !ok {
+		err = errors.Errorf("no bin for country code %q", geo.countryCode)
+	}
+	if err != nil {
+		jww.WARN.Printf("Failed to re-bin node %s: %+v", n.GetID(), err)
+		return rebinFailed
+	}
+
+	app, err := storage.PermissioningDb.GetApplication(n.GetAppID())
+	if err != nil {
+		jww.WARN.Printf("Failed to re-bin node %s: %+v", n.GetID(), err)
+		return rebinFailed
+	}
+
+	outcome := rebinUnchanged
+	if n.GetOrdering() != geo.countryCode {
+		err = storage.PermissioningDb.UpdateNodeSequence(n.GetID(), geo.countryCode)
+		if err != nil {
+			jww.WARN.Printf("Failed to re-bin node %s: %s", n.GetID(),
+				errors.Errorf(setDbSequenceErr, n.GetID(), geo.countryCode))
+			return rebinFailed
+		}
+		jww.INFO.Printf("Re-binned node %s from %s to %s", n.GetID(),
+			n.GetOrdering(), geo.countryCode)
+		n.SetOrdering(geo.countryCode)
+		outcome = rebinChanged
+	}
+	if app.Location != geo.location || app.GeoBin != geo.geoBin ||
+		app.GpsLocation != geo.gps {
+		err = storage.PermissioningDb.UpdateGeoIP(
+			n.GetAppID(), geo.location, geo.geoBin, geo.gps)
+		if err != nil {
+			jww.WARN.Printf("Failed to update geo information of node %s: "+
+				"%+v", n.GetID(), err)
+			return rebinFailed
+		}
+		outcome = rebinChanged
+	}
+	return outcome
+}
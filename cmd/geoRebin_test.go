@@ -0,0 +1,208 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package cmd
+
+import (
+	"github.com/oschwald/geoip2-golang"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/xx_network/comms/connect"
+	"gitlab.com/xx_network/primitives/id"
+	"gitlab.com/xx_network/primitives/region"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// newGeoRebinTestImpl creates a RegistrationImpl with the testing GeoIP2
+// database, a state and a storage database.
+func newGeoRebinTestImpl(t *testing.T, interval time.Duration) *RegistrationImpl {
+	var err error
+	var closeDb func() error
+	storage.PermissioningDb, closeDb, err = storage.NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("Failed to create database: %+v", err)
+	}
+	t.Cleanup(func() { _ = closeDb() })
+	state, err := storage.NewState(getTestKey(), 8, "", "", region.GetCountryBins())
+	if err != nil {
+		t.Fatalf("Failed to create state: %+v", err)
+	}
+
+	impl := &RegistrationImpl{
+		State:  state,
+		params: &Params{geoRebinInterval: interval},
+	}
+	impl.geoIPDB, err = geoip2.Open("../testkeys/GeoIP2-City-Test.mmdb")
+	if err != nil {
+		t.Fatalf("Failed to open GeoIP2 database file: %+v", err)
+	}
+	t.Cleanup(func() { _ = impl.geoIPDB.Close() })
+	impl.geoIPDBStatus.ToRunning()
+	return impl
+}
+
+// addGeoRebinTestNode registers an active node with the ordering and address
+// in the state and storage.
+func addGeoRebinTestNode(t *testing.T, impl *RegistrationImpl, i int,
+	ordering, address string) *id.ID {
+	nid := id.NewIdFromUInt(uint64(i), id.Node, t)
+	err := storage.PermissioningDb.InsertApplication(
+		&storage.Application{Id: uint64(i + 1)}, &storage.Node{
+			Code: "code" + strconv.Itoa(i), Id: nid.Marshal(),
+			ApplicationId: uint64(i + 1), Sequence: ordering})
+	if err != nil {
+		t.Fatalf("Failed to insert node: %+v", err)
+	}
+	err = impl.State.GetNodeMap().AddNode(nid, ordering, address, "", uint64(i+1))
+	if err != nil {
+		t.Fatalf("Failed to add node: %+v", err)
+	}
+	return nid
+}
+
+// waitGeoRebin waits for the geo re-binning job to finish and returns its
+// report.
+func waitGeoRebin(t *testing.T, impl *RegistrationImpl, auth *connect.Auth) GeoRebinReport {
+	for i := 0; ; i++ {
+		report, err := impl.GetGeoRebinReport(auth)
+		if err != nil {
+			t.Fatalf("GetGeoRebinReport() returned an error: %+v", err)
+		}
+		if !report.Running {
+			return report
+		}
+		if i == 500 {
+			t.Fatalf("Geo re-binning did not finish: %+v", report)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// Tests that the geo re-binning job moves the nodes whose country changed,
+// leaves those already binned correctly alone, skips and lists the nodes
+// without an IP address, counts failed lookups and ignores banned nodes.
+func TestRegistrationImpl_StartGeoRebin(t *testing.T) {
+	impl := newGeoRebinTestImpl(t, time.Millisecond)
+	permHost, err := connect.NewHost(&id.Permissioning, "", nil,
+		connect.GetDefaultHostParams())
+	if err != nil {
+		t.Fatalf("Failed to create host: %+v", err)
+	}
+	auth := &connect.Auth{IsAuthenticated: true, Sender: permHost}
+
+	moved := addGeoRebinTestNode(t, impl, 0, "US", "202.196.224.6:11420")
+	binned := addGeoRebinTestNode(t, impl, 1, "", "81.2.69.160:11420")
+	err = impl.setNodeSequence(impl.State.GetNodeMap().GetNode(binned),
+		"81.2.69.160")
+	if err != nil {
+		t.Fatalf("Failed to bin node: %+v", err)
+	}
+	domain := addGeoRebinTestNode(t, impl, 2, "US", "node.example.com:11420")
+	unknown := addGeoRebinTestNode(t, impl, 3, "US", "10.1.2.3:11420")
+	observed := addGeoRebinTestNode(t, impl, 4, "US", "other.example.com:11420")
+	impl.State.GetNodeMap().GetNode(observed).SetObservedAddress("2.125.160.216")
+	banned := id.NewIdFromUInt(5, id.Node, t)
+	err = impl.State.GetNodeMap().AddBannedNode(banned, "US", "89.160.20.112:11420", "")
+	if err != nil {
+		t.Fatalf("Failed to add banned node: %+v", err)
+	}
+
+	if err = impl.StartGeoRebin(auth); err != nil {
+		t.Fatalf("StartGeoRebin() returned an error: %+v", err)
+	}
+	report := waitGeoRebin(t, impl, auth)
+
+	if report.Total != 5 || report.Processed != 5 || report.Changed != 2 ||
+		report.Failed != 1 || report.Finished.Before(report.Started) {
+		t.Errorf("Unexpected geo re-binning report: %+v", report)
+	}
+	if len(report.Skipped) != 1 || !report.Skipped[0].Cmp(domain) {
+		t.Errorf("Expected node %s to be skipped, skipped %v.", domain,
+			report.Skipped)
+	}
+	changed := make(map[id.ID]bool)
+	for _, nid := range report.ChangedNodes {
+		changed[*nid] = true
+	}
+	if len(changed) != 2 || !changed[*moved] || !changed[*observed] {
+		t.Errorf("Unexpected changed nodes: %v", report.ChangedNodes)
+	}
+
+	for nid, expected := range map[*id.ID]string{
+		moved: "PH", binned: "GB", domain: "US", unknown: "US", observed: "GB",
+		banned: "US"} {
+		if ordering := impl.State.GetNodeMap().GetNode(nid).GetOrdering(); ordering != expected {
+			t.Errorf("Node %s has ordering %s, expected %s.", nid, ordering,
+				expected)
+		}
+	}
+	nodeDb, err := storage.PermissioningDb.GetNodeById(moved)
+	if err != nil {
+		t.Fatalf("Failed to get node: %+v", err)
+	}
+	if nodeDb.Sequence != "PH" {
+		t.Errorf("Stored sequence is %s, expected PH.", nodeDb.Sequence)
+	}
+	app, err := storage.PermissioningDb.GetApplication(1)
+	if err != nil {
+		t.Fatalf("Failed to get application: %+v", err)
+	}
+	bin, _ := region.GetCountryBin("PH")
+	if app.GeoBin != bin.String() || app.Location == "" {
+		t.Errorf("Geo information was not updated: %+v", app)
+	}
+}
+
+// Tests that the geo re-binning job reports its progress while running, is
+// not started twice at once and is refused without the GeoIP2 database or
+// admin authentication.
+func TestRegistrationImpl_StartGeoRebin_Running(t *testing.T) {
+	impl := newGeoRebinTestImpl(t, time.Hour)
+	permHost, err := connect.NewHost(&id.Permissioning, "", nil,
+		connect.GetDefaultHostParams())
+	if err != nil {
+		t.Fatalf("Failed to create host: %+v", err)
+	}
+	auth := &connect.Auth{IsAuthenticated: true, Sender: permHost}
+	addGeoRebinTestNode(t, impl, 0, "US", "202.196.224.6:11420")
+	addGeoRebinTestNode(t, impl, 1, "US", "81.2.69.160:11420")
+
+	if err = impl.StartGeoRebin(&connect.Auth{Sender: permHost}); err == nil {
+		t.Errorf("Unauthenticated geo re-binning was started.")
+	}
+	if err = impl.StartGeoRebin(auth); err != nil {
+		t.Fatalf("StartGeoRebin() returned an error: %+v", err)
+	}
+	if err = impl.StartGeoRebin(auth); err == nil {
+		t.Errorf("Geo re-binning was started twice.")
+	}
+
+	var report GeoRebinReport
+	for i := 0; report.Processed == 0; i++ {
+		if i == 500 {
+			t.Fatalf("First node was not re-binned: %+v", report)
+		}
+		time.Sleep(10 * time.Millisecond)
+		if report, err = impl.GetGeoRebinReport(auth); err != nil {
+			t.Fatalf("GetGeoRebinReport() returned an error: %+v", err)
+		}
+	}
+	if !report.Running || report.Total != 2 || report.Processed != 1 ||
+		report.Changed != 1 || !report.Finished.IsZero() {
+		t.Errorf("Unexpected report of running geo re-binning: %+v", report)
+	}
+
+	disabled := &RegistrationImpl{params: &Params{disableGeoBinning: true}}
+	if err = disabled.StartGeoRebin(auth); err == nil {
+		t.Errorf("Geo re-binning was started with geo binning disabled.")
+	}
+	closed := &RegistrationImpl{params: &Params{}}
+	if err = closed.StartGeoRebin(auth); err == nil {
+		t.Errorf("Geo re-binning was started without a GeoIP2 database.")
+	}
+}
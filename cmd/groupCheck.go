@@ -0,0 +1,179 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Handles checking the cMix and E2E groups from the config before they are
+// published in the NDF
+
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/pkg/errors"
+	jww "github.com/spf13/jwalterweatherman"
+	"github.com/spf13/viper"
+	"gitlab.com/xx_network/primitives/ndf"
+	"math/big"
+	"os"
+	"strings"
+)
+
+// Bit length group primes are expected to have when groups.primeBits is not
+// set
+const defaultGroupPrimeBits = 4096
+
+// Number of Miller-Rabin rounds group primes are tested with, in addition to
+// the Baillie-PSW test
+const groupPrimalityRounds = 4
+
+// parseGroupInt parses a hex encoded group value.
+func parseGroupInt(value string) (*big.Int, bool) {
+	value = strings.TrimPrefix(strings.TrimSpace(value), "0x")
+	return new(big.Int).SetString(value, 16)
+}
+
+// validateGroup checks that the group prime is a hex encoded probable prime of
+// the expected bit length and that the generator is an element of the group
+// other than 1 and p-1. A primeBits of 0 accepts primes of any length.
+func validateGroup(grp *ndf.Group, primeBits int) error {
+	prime, ok := parseGroupInt(grp.Prime)
+	if !ok {
+		return errors.New("prime is not a valid hex number")
+	}
+	if primeBits > 0 && prime.BitLen() != primeBits {
+		return errors.Errorf("prime is %d bits, expected %d bits",
+			prime.BitLen(), primeBits)
+	}
+	if !prime.ProbablyPrime(groupPrimalityRounds) {
+		return errors.New("prime is not prime")
+	}
+
+	generator, ok := parseGroupInt(grp.Generator)
+	if !ok {
+		return errors.New("generator is not a valid hex number")
+	}
+	pMinusOne := new(big.Int).Sub(prime, big.NewInt(1))
+	if generator.Cmp(big.NewInt(1)) <= 0 || generator.Cmp(pMinusOne) >= 0 {
+		return errors.New("generator is not between 1 and p-1")
+	}
+	return nil
+}
+
+// sameGroup returns true if the groups have the same prime and generator,
+// regardless of how they are encoded.
+func sameGroup(a, b ndf.Group) bool {
+	aPrime, aOk := parseGroupInt(a.Prime)
+	bPrime, bOk := parseGroupInt(b.Prime)
+	aGen, aGenOk := parseGroupInt(a.Generator)
+	bGen, bGenOk := parseGroupInt(b.Generator)
+	if !aOk || !bOk || !aGenOk || !bGenOk {
+		return a.Prime == b.Prime && a.Generator == b.Generator
+	}
+	return aPrime.Cmp(bPrime) == 0 && aGen.Cmp(bGen) == 0
+}
+
+// groupFingerprint returns a short hash of the group's prime and generator,
+// for comparing the groups of permissioning servers in their logs.
+func groupFingerprint(grp ndf.Group) string {
+	h := sha256.New()
+	if prime, ok := parseGroupInt(grp.Prime); ok {
+		h.Write(prime.Bytes())
+	}
+	if generator, ok := parseGroupInt(grp.Generator); ok {
+		h.Write(generator.Bytes())
+	}
+	return hex.EncodeToString(h.Sum(nil)[:8])
+}
+
+// checkGroups validates the cMix and E2E groups from the config and compares
+// them against the groups in the NDF previously output to ndfPath, if there is
+// one. Groups which differ from the persisted ones are refused unless
+// allowChange is set, as changing them cuts clients and nodes off from the
+// network.
+func checkGroups(cmix, e2e *ndf.Group, primeBits int, ndfPath string,
+	allowChange bool) error {
+	if err := validateGroup(cmix, primeBits); err != nil {
+		return errors.WithMessage(err, "Invalid cMix group")
+	}
+	if err := validateGroup(e2e, primeBits); err != nil {
+		return errors.WithMessage(err, "Invalid E2E group")
+	}
+	jww.INFO.Printf("cMix group fingerprint: %s, E2E group fingerprint: %s",
+		groupFingerprint(*cmix), groupFingerprint(*e2e))
+
+	if ndfPath == "" {
+		return nil
+	}
+	data, err := os.ReadFile(ndfPath)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return errors.Errorf("Failed to read the previous NDF to compare "+
+			"groups against: %+v", err)
+	}
+	persisted, err := ndf.Unmarshal(data)
+	if err != nil {
+		return errors.Errorf("Failed to parse the previous NDF to compare "+
+			"groups against: %+v", err)
+	}
+
+	var changed []string
+	if !sameGroup(*cmix, persisted.CMIX) {
+		changed = append(changed, "cMix")
+	}
+	if !sameGroup(*e2e, persisted.E2E) {
+		changed = append(changed, "E2E")
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+	if !allowChange {
+		return errors.Errorf("The %s group(s) differ from the previous NDF "+
+			"at %s; refusing to start without --allow-group-change",
+			strings.Join(changed, " and "), ndfPath)
+	}
+	jww.WARN.Printf("The %s group(s) differ from the previous NDF at %s and "+
+		"will be replaced as --allow-group-change is set",
+		strings.Join(changed, " and "), ndfPath)
+	return nil
+}
+
+// checkGroupReload validates the groups in a reloaded config. The groups are
+// only read on startup, so a change is reported rather than applied.
+func (m *RegistrationImpl) checkGroupReload() {
+	for name, key := range map[string]string{"cMix": "groups.cmix",
+		"E2E": "groups.e2e"} {
+		grp, err := toGroup(viper.GetStringMapString(key))
+		if err == nil {
+			err = validateGroup(grp, getGroupPrimeBits())
+		}
+		if err != nil {
+			jww.ERROR.Printf("Invalid %s group in reloaded config: %+v",
+				name, err)
+			continue
+		}
+
+		running := m.params.cmix
+		if key == "groups.e2e" {
+			running = m.params.e2e
+		}
+		if !sameGroup(*grp, running) {
+			jww.WARN.Printf("The %s group in the reloaded config differs "+
+				"from the running group (fingerprint %s, running %s) and "+
+				"is ignored until restart", name, groupFingerprint(*grp),
+				groupFingerprint(running))
+		}
+	}
+}
+
+// getGroupPrimeBits returns the bit length group primes are expected to have.
+func getGroupPrimeBits() int {
+	if viper.IsSet("groups.primeBits") {
+		return viper.GetInt("groups.primeBits")
+	}
+	return defaultGroupPrimeBits
+}
@@ -0,0 +1,121 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package cmd
+
+import (
+	"crypto/rand"
+	"gitlab.com/xx_network/primitives/ndf"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Bit length of the groups generated for testing
+const testGroupPrimeBits = 256
+
+// newTestGroup returns a group with a newly generated prime.
+func newTestGroup(t *testing.T) *ndf.Group {
+	prime, err := rand.Prime(rand.Reader, testGroupPrimeBits)
+	if err != nil {
+		t.Fatalf("Failed to generate prime: %+v", err)
+	}
+	return &ndf.Group{Prime: prime.Text(16), Generator: "2"}
+}
+
+// Tests that validateGroup() accepts a valid group and rejects malformed
+// primes and generators.
+func TestValidateGroup(t *testing.T) {
+	grp := newTestGroup(t)
+	if err := validateGroup(grp, testGroupPrimeBits); err != nil {
+		t.Errorf("validateGroup() rejected a valid group: %+v", err)
+	}
+	if err := validateGroup(grp, 0); err != nil {
+		t.Errorf("validateGroup() rejected a valid group of any length: %+v",
+			err)
+	}
+
+	prime, _ := parseGroupInt(grp.Prime)
+	composite := new(big.Int).Add(prime, big.NewInt(1))
+	pMinusOne := new(big.Int).Sub(prime, big.NewInt(1))
+	invalid := map[string]*ndf.Group{
+		"non-hex prime":  {Prime: "not hex", Generator: "2"},
+		"composite":      {Prime: composite.Text(16), Generator: "2"},
+		"short prime":    {Prime: grp.Prime[2:], Generator: "2"},
+		"non-hex gen":    {Prime: grp.Prime, Generator: "zz"},
+		"generator of 1": {Prime: grp.Prime, Generator: "1"},
+		"generator p-1":  {Prime: grp.Prime, Generator: pMinusOne.Text(16)},
+		"generator >= p": {Prime: grp.Prime, Generator: grp.Prime},
+	}
+	for name, g := range invalid {
+		if err := validateGroup(g, testGroupPrimeBits); err == nil {
+			t.Errorf("validateGroup() accepted a group with a %s.", name)
+		}
+	}
+}
+
+// Tests that checkGroups() refuses groups which differ from the previously
+// output NDF unless the change is allowed.
+func TestCheckGroups(t *testing.T) {
+	cmix, e2e := newTestGroup(t), newTestGroup(t)
+	ndfPath := filepath.Join(t.TempDir(), "ndf.json")
+
+	// Nothing to compare against before an NDF is output
+	if err := checkGroups(cmix, e2e, testGroupPrimeBits, ndfPath, false); err != nil {
+		t.Errorf("checkGroups() failed without a previous NDF: %+v", err)
+	}
+
+	data, err := (&ndf.NetworkDefinition{CMIX: *cmix, E2E: *e2e}).Marshal()
+	if err != nil {
+		t.Fatalf("Failed to marshal NDF: %+v", err)
+	}
+	if err = os.WriteFile(ndfPath, data, 0644); err != nil {
+		t.Fatalf("Failed to write NDF: %+v", err)
+	}
+	if err = checkGroups(cmix, e2e, testGroupPrimeBits, ndfPath, false); err != nil {
+		t.Errorf("checkGroups() rejected the persisted groups: %+v", err)
+	}
+
+	// Encoding differences are not changes
+	reencoded := &ndf.Group{Prime: "0x" + cmix.Prime, Generator: "02"}
+	if err = checkGroups(reencoded, e2e, testGroupPrimeBits, ndfPath, false); err != nil {
+		t.Errorf("checkGroups() rejected a re-encoded group: %+v", err)
+	}
+
+	changed := newTestGroup(t)
+	if err = checkGroups(changed, e2e, testGroupPrimeBits, ndfPath, false); err == nil {
+		t.Errorf("checkGroups() accepted a changed cMix group.")
+	}
+	if err = checkGroups(cmix, changed, testGroupPrimeBits, ndfPath, false); err == nil {
+		t.Errorf("checkGroups() accepted a changed E2E group.")
+	}
+	if err = checkGroups(changed, changed, testGroupPrimeBits, ndfPath, true); err != nil {
+		t.Errorf("checkGroups() rejected an allowed group change: %+v", err)
+	}
+
+	// Invalid groups are refused even when changes are allowed
+	malformed := &ndf.Group{Prime: "not hex", Generator: "2"}
+	if err = checkGroups(malformed, e2e, testGroupPrimeBits, ndfPath, true); err == nil {
+		t.Errorf("checkGroups() accepted a malformed cMix group.")
+	}
+	if err = checkGroups(cmix, malformed, testGroupPrimeBits, ndfPath, true); err == nil {
+		t.Errorf("checkGroups() accepted a malformed E2E group.")
+	}
+}
+
+// Tests that groupFingerprint() depends only on the group values.
+func TestGroupFingerprint(t *testing.T) {
+	grp := newTestGroup(t)
+	reencoded := ndf.Group{Prime: "0x" + grp.Prime, Generator: "02"}
+	if groupFingerprint(*grp) != groupFingerprint(reencoded) {
+		t.Errorf("Fingerprint changed with the encoding of the group.")
+	}
+	if groupFingerprint(*grp) == groupFingerprint(*newTestGroup(t)) {
+		t.Errorf("Different groups have the same fingerprint.")
+	}
+}
@@ -0,0 +1,177 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Contains the handling of health errors nodes report outside of any round
+
+package cmd
+
+import (
+	"github.com/pkg/errors"
+	jww "github.com/spf13/jwalterweatherman"
+	pb "gitlab.com/elixxir/comms/mixmessages"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/elixxir/registration/storage/node"
+	"gitlab.com/xx_network/comms/connect"
+	"gitlab.com/xx_network/primitives/id"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"strings"
+	"time"
+)
+
+// healthReportPollField is the field number of the health report in the
+// PermissioningPoll message. Nodes send it as a marshalled RoundError with a
+// round ID of zero, signed like a round error, whose error text is the
+// severity followed by a colon and a description; until the comms message
+// declares the field, it is read from the message's unknown fields.
+const healthReportPollField protowire.Number = 14
+
+// Severities of node health reports. A critical report keeps the node out of
+// scheduling, if enabled, until it reports healthy.
+const (
+	HealthInfo     = "info"
+	HealthWarning  = "warning"
+	HealthCritical = "critical"
+	HealthHealthy  = "healthy"
+)
+
+// Longest description of a health report which is stored
+const maxHealthMessageLength = 1024
+
+// getHealthReport returns the health report sent in the poll, or nil if the
+// node sent none.
+func getHealthReport(msg *pb.PermissioningPoll) (*pb.RoundError, error) {
+	unknown := msg.ProtoReflect().GetUnknown()
+	var report []byte
+	for len(unknown) > 0 {
+		num, typ, n := protowire.ConsumeTag(unknown)
+		if n < 0 {
+			return nil, errors.New("Malformed unknown fields in poll")
+		}
+		unknown = unknown[n:]
+
+		if num == healthReportPollField && typ == protowire.BytesType {
+			v, m := protowire.ConsumeBytes(unknown)
+			if m < 0 {
+				return nil, errors.New("Malformed health report in poll")
+			}
+			report = v
+			unknown = unknown[m:]
+			continue
+		}
+
+		m := protowire.ConsumeFieldValue(num, typ, unknown)
+		if m < 0 {
+			return nil, errors.New("Malformed unknown fields in poll")
+		}
+		unknown = unknown[m:]
+	}
+
+	if report == nil {
+		return nil, nil
+	}
+	roundErr := &pb.RoundError{}
+	if err := proto.Unmarshal(report, roundErr); err != nil {
+		return nil, errors.Errorf("Failed to unmarshal health report: %+v",
+			err)
+	}
+	return roundErr, nil
+}
+
+// parseHealthReport splits the error text of a health report into its
+// severity and description.
+func parseHealthReport(report *pb.RoundError) (string, string, error) {
+	severity, message, _ := strings.Cut(report.Error, ":")
+	severity = strings.ToLower(strings.TrimSpace(severity))
+	switch severity {
+	case HealthInfo, HealthWarning, HealthCritical, HealthHealthy:
+	default:
+		return "", "", errors.Errorf("Unknown health report severity %q",
+			severity)
+	}
+	message = strings.TrimSpace(message)
+	if len(message) > maxHealthMessageLength {
+		message = message[:maxHealthMessageLength]
+	}
+	return severity, message, nil
+}
+
+// handleHealthReport verifies and stores the health report sent in the poll,
+// if any. Reports must be signed by the reporting node and carry no round.
+// When removeCriticalNodes is set, a critical report keeps the node out of
+// scheduling until it reports healthy.
+func (m *RegistrationImpl) handleHealthReport(n *node.State,
+	msg *pb.PermissioningPoll, now time.Time) error {
+	report, err := getHealthReport(msg)
+	if err != nil || report == nil {
+		return err
+	}
+
+	if report.Id != 0 {
+		return errors.New("Health reports cannot be associated with a round")
+	}
+	reporter, err := id.Unmarshal(report.NodeId)
+	if err != nil {
+		return errors.WithMessage(err, "Could not unmarshal node ID from "+
+			"health report in poll")
+	}
+	if !reporter.Cmp(n.GetID()) {
+		return errors.Errorf("Node %s cannot submit a health report for "+
+			"node %s", n.GetID(), reporter)
+	}
+	if err = verifyErrorSignature(m, report); err != nil {
+		return err
+	}
+
+	severity, message, err := parseHealthReport(report)
+	if err != nil {
+		return err
+	}
+	err = storage.PermissioningDb.InsertNodeHealthEvent(&storage.NodeHealthEvent{
+		NodeId:    n.GetID().Marshal(),
+		Severity:  severity,
+		Message:   message,
+		Timestamp: now,
+	})
+	if err != nil {
+		jww.WARN.Printf("Failed to store health report of node %s: %+v",
+			n.GetID(), err)
+	}
+
+	if !m.params.removeCriticalNodes {
+		return nil
+	}
+	switch severity {
+	case HealthCritical:
+		if n.SetHealthCritical(true) {
+			jww.WARN.Printf("Node %s reported a critical health error, "+
+				"removing it from scheduling until it reports healthy: %s",
+				n.GetID(), message)
+		}
+	case HealthHealthy:
+		if n.SetHealthCritical(false) {
+			jww.INFO.Printf("Node %s reported healthy, returning it to "+
+				"scheduling", n.GetID())
+		}
+	}
+	return nil
+}
+
+// GetNodeHealthEvents returns the health errors the node reported since the
+// given time, oldest first.
+func (m *RegistrationImpl) GetNodeHealthEvents(auth *connect.Auth,
+	nodeId *id.ID, since time.Time) ([]*storage.NodeHealthEvent, error) {
+	if err := checkAdminAuth(auth); err != nil {
+		return nil, err
+	}
+	events, err := storage.PermissioningDb.GetNodeHealthEvents(nodeId, since)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "Failed to get health events "+
+			"of node %s", nodeId)
+	}
+	return events, nil
+}
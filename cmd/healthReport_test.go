@@ -0,0 +1,209 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package cmd
+
+import (
+	pb "gitlab.com/elixxir/comms/mixmessages"
+	"gitlab.com/elixxir/comms/registration"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/elixxir/registration/storage/node"
+	"gitlab.com/elixxir/registration/testkeys"
+	"gitlab.com/xx_network/comms/connect"
+	"gitlab.com/xx_network/comms/signature"
+	"gitlab.com/xx_network/crypto/signature/rsa"
+	"gitlab.com/xx_network/primitives/id"
+	"gitlab.com/xx_network/primitives/region"
+	"gitlab.com/xx_network/primitives/utils"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"testing"
+	"time"
+)
+
+// Sets up a registered node whose host is known to the comms, returning the
+// key it signs health reports with
+func setupHealthReportTest(t *testing.T) (*RegistrationImpl, *node.State,
+	*rsa.PrivateKey) {
+	var err error
+	var closeDb func() error
+	storage.PermissioningDb, closeDb, err = storage.NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("Failed to create database: %+v", err)
+	}
+	t.Cleanup(func() { _ = closeDb() })
+
+	state, err := storage.NewState(getTestKey(), 8, "", "", region.GetCountryBins())
+	if err != nil {
+		t.Fatalf("Failed to create state: %+v", err)
+	}
+	impl := &RegistrationImpl{
+		State:  state,
+		params: &Params{removeCriticalNodes: true},
+		Comms: &registration.Comms{
+			ProtoComms: &connect.ProtoComms{
+				Manager: connect.NewManagerTesting(t),
+			},
+		},
+	}
+
+	nid := id.NewIdFromString("node", id.Node, t)
+	err = storage.PermissioningDb.InsertApplication(
+		&storage.Application{Id: 1}, &storage.Node{Code: "AAAA",
+			Id: nid.Marshal(), ApplicationId: 1})
+	if err != nil {
+		t.Fatalf("Failed to insert node: %+v", err)
+	}
+	if err = state.GetNodeMap().AddNode(nid, "US", "", "", 1); err != nil {
+		t.Fatalf("Failed to add node: %+v", err)
+	}
+
+	nodeCert, err := utils.ReadFile(testkeys.GetNodeCertPath())
+	if err != nil {
+		t.Fatalf("Could not get node cert: %+v", err)
+	}
+	params := connect.GetDefaultHostParams()
+	params.AuthEnabled = false
+	if _, err = impl.Comms.AddHost(nid, "0.0.0.0:8000", nodeCert, params); err != nil {
+		t.Fatalf("Failed to add host: %+v", err)
+	}
+	keyPem, err := utils.ReadFile(testkeys.GetNodeKeyPath())
+	if err != nil {
+		t.Fatalf("Could not get node key: %+v", err)
+	}
+	key, err := rsa.LoadPrivateKeyFromPem(keyPem)
+	if err != nil {
+		t.Fatalf("Failed to load node key: %+v", err)
+	}
+	return impl, state.GetNodeMap().GetNode(nid), key
+}
+
+// Builds a poll carrying a health report with the given text, signed by the
+// key if it is not nil
+func newHealthReportPoll(t *testing.T, nid *id.ID, roundId uint64,
+	text string, key *rsa.PrivateKey) *pb.PermissioningPoll {
+	report := &pb.RoundError{Id: roundId, NodeId: nid.Marshal(), Error: text}
+	if key != nil {
+		if err := signature.SignRsa(report, key); err != nil {
+			t.Fatalf("Failed to sign health report: %+v", err)
+		}
+	}
+	data, err := proto.Marshal(report)
+	if err != nil {
+		t.Fatalf("Failed to marshal health report: %+v", err)
+	}
+	field := protowire.AppendTag(nil, healthReportPollField, protowire.BytesType)
+	field = protowire.AppendBytes(field, data)
+	msg := &pb.PermissioningPoll{}
+	msg.ProtoReflect().SetUnknown(field)
+	return msg
+}
+
+// Tests that the severity and description of a health report are parsed and
+// that unknown severities are refused.
+func TestParseHealthReport(t *testing.T) {
+	severity, message, err := parseHealthReport(
+		&pb.RoundError{Error: " Critical: GPU failure: device lost"})
+	if err != nil || severity != HealthCritical ||
+		message != "GPU failure: device lost" {
+		t.Errorf("Unexpected report %q, %q: %+v", severity, message, err)
+	}
+	severity, message, err = parseHealthReport(&pb.RoundError{Error: "healthy"})
+	if err != nil || severity != HealthHealthy || message != "" {
+		t.Errorf("Unexpected report %q, %q: %+v", severity, message, err)
+	}
+	if _, _, err = parseHealthReport(&pb.RoundError{Error: "panic: oops"}); err == nil {
+		t.Errorf("Report with an unknown severity was accepted.")
+	}
+}
+
+// Tests that signed health reports are stored, that a critical report removes
+// the node from scheduling until it reports healthy, and that the events can
+// be queried by the permissioning server only.
+func TestRegistrationImpl_handleHealthReport(t *testing.T) {
+	impl, n, key := setupHealthReportTest(t)
+	start := time.Now()
+
+	reports := []string{"warning: disk 90% full", "critical: disk full",
+		"healthy"}
+	for i, text := range reports {
+		msg := newHealthReportPoll(t, n.GetID(), 0, text, key)
+		err := impl.handleHealthReport(n, msg, start.Add(time.Duration(i)*time.Second))
+		if err != nil {
+			t.Fatalf("handleHealthReport() rejected %q: %+v", text, err)
+		}
+		if critical := n.IsHealthCritical(); critical != (i == 1) {
+			t.Errorf("Node critical after %q: %t", text, critical)
+		}
+	}
+
+	nodeHost, err := connect.NewHost(n.GetID(), "", nil,
+		connect.GetDefaultHostParams())
+	if err != nil {
+		t.Fatalf("Failed to create host: %+v", err)
+	}
+	_, err = impl.GetNodeHealthEvents(
+		&connect.Auth{IsAuthenticated: true, Sender: nodeHost}, n.GetID(), start)
+	if err == nil {
+		t.Errorf("Node was able to get its health events.")
+	}
+	permHost, err := connect.NewHost(&id.Permissioning, "", nil,
+		connect.GetDefaultHostParams())
+	if err != nil {
+		t.Fatalf("Failed to create host: %+v", err)
+	}
+	events, err := impl.GetNodeHealthEvents(
+		&connect.Auth{IsAuthenticated: true, Sender: permHost}, n.GetID(), start)
+	if err != nil {
+		t.Fatalf("GetNodeHealthEvents() returned an error: %+v", err)
+	}
+	if len(events) != len(reports) || events[1].Severity != HealthCritical ||
+		events[1].Message != "disk full" {
+		t.Errorf("Unexpected health events: %+v", events)
+	}
+
+	// Critical reports are stored without removing the node when disabled
+	impl.params.removeCriticalNodes = false
+	err = impl.handleHealthReport(n,
+		newHealthReportPoll(t, n.GetID(), 0, "critical: GPU failure", key), start)
+	if err != nil || n.IsHealthCritical() {
+		t.Errorf("Critical report removed the node while disabled: %+v", err)
+	}
+}
+
+// Tests that unsigned, round bound and impersonating health reports are
+// rejected without being stored.
+func TestRegistrationImpl_handleHealthReport_Invalid(t *testing.T) {
+	impl, n, key := setupHealthReportTest(t)
+
+	invalid := map[string]*pb.PermissioningPoll{
+		"unsigned": newHealthReportPoll(t, n.GetID(), 0, "critical: x", nil),
+		"round bound": newHealthReportPoll(t, n.GetID(), 5, "critical: x",
+			key),
+		"other node": newHealthReportPoll(t,
+			id.NewIdFromString("other", id.Node, t), 0, "critical: x", key),
+		"unknown severity": newHealthReportPoll(t, n.GetID(), 0, "bad: x",
+			key),
+	}
+	for name, msg := range invalid {
+		if err := impl.handleHealthReport(n, msg, time.Now()); err == nil {
+			t.Errorf("handleHealthReport() accepted a %s report.", name)
+		}
+	}
+	if n.IsHealthCritical() {
+		t.Errorf("Invalid report removed the node from scheduling.")
+	}
+	events, err := storage.PermissioningDb.GetNodeHealthEvents(n.GetID(), time.Time{})
+	if err != nil || len(events) != 0 {
+		t.Errorf("Invalid reports were stored: %+v, %+v", events, err)
+	}
+
+	// Polls without a report are accepted
+	if err = impl.handleHealthReport(n, &pb.PermissioningPoll{}, time.Now()); err != nil {
+		t.Errorf("Poll without a health report was rejected: %+v", err)
+	}
+}
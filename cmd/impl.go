@@ -20,6 +20,7 @@ import (
 	"gitlab.com/elixxir/registration/scheduling"
 	"gitlab.com/elixxir/registration/storage"
 	"gitlab.com/elixxir/registration/storage/node"
+	"gitlab.com/elixxir/registration/transition"
 	"gitlab.com/xx_network/comms/connect"
 	"gitlab.com/xx_network/crypto/signature/rsa"
 	"gitlab.com/xx_network/crypto/tls"
@@ -62,7 +63,59 @@ type RegistrationImpl struct {
 	// Status of the geoip2.Reader; signals if the reader is running or stopped
 	geoIPDBStatus geoipStatus
 
+	// Job looking up the bins of all active nodes again
+	geoRebin geoRebinJob
+
 	earliestRoundTracker atomic.Value
+
+	// Round update acknowledgments of the gateways as of the last check
+	gatewayAcks atomic.Value
+
+	// Version of the permissioning policy, incremented whenever a policy
+	// value changes at runtime
+	policyVersion uint64
+
+	// Failed node registrations of each source
+	registrationAttempts registrationAttempts
+
+	// Nonces consumed by the registration of each code
+	registrationNonces registrationNonces
+
+	// Policy and last report of reconciling the NDF with Storage
+	ndfReconciliation ndfReconciliation
+
+	// Nodes banned by the blacklist file
+	blacklist nodeBlacklist
+
+	// Ranges and domains reported addresses must belong to, nil to accept
+	// any address
+	addressAllowlist *addressAllowlist
+
+	// Slots held by the polls being handled, nil for no limit
+	pollSlots chan struct{}
+
+	// Rate limits of the polls of each application's nodes, nil for no limit
+	appPollLimits *appPollLimiter
+
+	// Spreads the delivery of NDF changes to nodes, nil to deliver each at
+	// once
+	ndfSpread *ndfSpread
+
+	// Workers running the connectivity checks of nodes, nil for no limit
+	connectivityChecks *connectivityPool
+
+	// Raw poll messages captured for debugging
+	pollCapture *pollCapture
+
+	// Gate on the network being ready for clients, nil if it always is
+	readiness *networkReadiness
+
+	// Node metrics deleted past their retention
+	nodeMetricPrunes nodeMetricPrunes
+
+	// Conditions under which banned nodes are automatically unbanned
+	unbanConditions []UnbanCondition
+	unbanMux        sync.Mutex
 }
 
 // function used to schedule nodes
@@ -132,6 +185,47 @@ func StartRegistration(params Params) (*RegistrationImpl, error) {
 		beginScheduling:      make(chan struct{}, 1),
 		registrationTimes:    make(map[id.ID]int64),
 		earliestRoundTracker: atomic.Value{},
+		policyVersion:        1,
+		registrationAttempts: registrationAttempts{
+			limit:   params.registrationAttemptLimit,
+			lockout: params.registrationLockout,
+		},
+	}
+	if params.maxConcurrentPolls > 0 {
+		regImpl.pollSlots = make(chan struct{}, params.maxConcurrentPolls)
+	}
+	regImpl.appPollLimits, err = newAppPollLimiter(params.appPollRate,
+		params.appPollBurst)
+	if err != nil {
+		return nil, err
+	}
+	regImpl.ndfSpread, err = newNdfSpread(params.ndfSpreadWindow,
+		params.ndfSpreadCritical)
+	if err != nil {
+		return nil, err
+	}
+	regImpl.connectivityChecks, err = newConnectivityPool(
+		params.connectivityCheckWorkers, params.connectivityCheckQueueSize)
+	if err != nil {
+		return nil, err
+	}
+	regImpl.pollCapture, err = newPollCapture(params.pollCaptureFraction,
+		params.pollCaptureSize)
+	if err != nil {
+		return nil, err
+	}
+	if err = checkPollSourceThreshold(params.pollSourceThreshold); err != nil {
+		return nil, err
+	}
+	regImpl.readiness, err = newNetworkReadiness(params.readinessMinNodes,
+		params.readinessMinNodeFraction, params.readinessHysteresis,
+		params.readinessStrict)
+	if err != nil {
+		return nil, err
+	}
+	regImpl.unbanConditions, err = newUnbanConditions(&params)
+	if err != nil {
+		return nil, err
 	}
 
 	// If the the GeoIP2 database file is supplied, then use it to open the
@@ -150,15 +244,9 @@ func StartRegistration(params Params) (*RegistrationImpl, error) {
 	}
 
 	// Determine which type of GeoBinning we're using
-	if regImpl.params.blockchainGeoBinning {
-		geoBins, err = storage.PermissioningDb.GetBins()
-		if err != nil {
-			return nil, err
-		}
-		jww.INFO.Printf("Loaded %d GeoBins from Storage!", len(geoBins))
-	} else {
-		geoBins = region.GetCountryBins()
-		jww.INFO.Printf("Loaded %d GeoBins from Primitives!", len(geoBins))
+	geoBins, err = loadGeoBins(&params)
+	if err != nil {
+		return nil, err
 	}
 
 	whitelistedIds := make([]string, 0)
@@ -203,6 +291,74 @@ func StartRegistration(params Params) (*RegistrationImpl, error) {
 	if err != nil {
 		return nil, err
 	}
+	if err = regImpl.State.LoadNodeGroups(); err != nil {
+		return nil, err
+	}
+	if err = regImpl.State.LoadAvoidLists(); err != nil {
+		return nil, err
+	}
+
+	regImpl.State.SetRoundHealthWindow(params.roundHealthWindow)
+	regImpl.State.SetUpdateLagThreshold(params.updateLagThreshold)
+	err = regImpl.State.SetNodeStalenessThresholds(params.nodeStaleThreshold,
+		params.nodeDeadThreshold)
+	if err != nil {
+		return nil, err
+	}
+	if len(params.addressAllowlist) > 0 {
+		regImpl.addressAllowlist, err = parseAddressAllowlist(params.addressAllowlist)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if params.legacyNdfOutputPath != "" {
+		err = regImpl.State.SetFormatNdfOutputPath(storage.LegacyNdfFormat,
+			params.legacyNdfOutputPath)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = regImpl.State.SetErrorRedactionPatterns(params.errorRedactionPatterns)
+	if err != nil {
+		return nil, err
+	}
+	if params.avoidListWarnFraction > 0 {
+		regImpl.State.SetAvoidListWarnFraction(params.avoidListWarnFraction)
+	}
+	err = regImpl.State.SetNdfHistory(params.ndfHistoryLimit, params.ndfHistoryPath)
+	if err != nil {
+		return nil, err
+	}
+	err = regImpl.State.SetNdfChangelog(params.ndfChangelogLimit, params.ndfChangelogPersist)
+	if err != nil {
+		return nil, err
+	}
+	regImpl.State.SetNdfRoundTripCheck(params.ndfRoundTripCheck)
+	regImpl.State.SetNdfWriteDebounce(params.ndfWriteDebounce, params.ndfWriteJitter)
+	regImpl.State.SetPollingLockTimeout(params.pollingLockTimeout)
+	regImpl.State.SetNodeDemotionHandler(regImpl.alertNodeDemotion)
+	err = regImpl.State.SetNdfSnapshots(params.ndfSnapshots, params.ndfSnapshotRetention)
+	if err != nil {
+		return nil, err
+	}
+	err = regImpl.setConfiguredDebugTargets(params.debugRounds, params.debugNodes)
+	if err != nil {
+		return nil, err
+	}
+	regImpl.ndfReconciliation.policy, err = parseNdfReconcilePolicy(params.ndfReconcilePolicy)
+	if err != nil {
+		return nil, err
+	}
+	if params.experimentalTransitionTable != "" {
+		transition.Node, err = transition.LoadTransitions(params.experimentalTransitionTable)
+		if err != nil {
+			return nil, err
+		}
+		jww.WARN.Printf("Using the experimental transition table %s in "+
+			"place of the default node state machine",
+			params.experimentalTransitionTable)
+	}
 
 	if !noTLS {
 		// Read in TLS keys from files
@@ -302,8 +458,20 @@ func StartRegistration(params Params) (*RegistrationImpl, error) {
 		if err != nil {
 			jww.FATAL.Panicf("Could not load all nodes from database: %+v", err)
 		}
+
+		regImpl.warnUnmappedGeoBins(geoBins)
+
+		// Catch any drift between the NDF and Storage left by an incident
+		if _, err = regImpl.reconcileNdf(time.Now()); err != nil {
+			jww.ERROR.Printf("Failed to reconcile the NDF with Storage on "+
+				"startup: %+v", err)
+		}
 	}
 
+	// Apply the avoid-lists given with the registration codes once the nodes
+	// they exclude are known
+	regImpl.loadApplicationAvoidLists(regCodeInfos)
+
 	// Start the communication server
 	regImpl.Comms = registration.StartRegistrationServer(&id.Permissioning,
 		params.Address, NewImplementation(regImpl),
@@ -328,7 +496,6 @@ func BannedNodeTracker(impl *RegistrationImpl) error {
 
 	impl.State.InternalNdfLock.Lock()
 	defer impl.State.InternalNdfLock.Unlock()
-	def := state.GetUnprunedNdf()
 
 	// Parse through the returned node list
 	for _, n := range bannedNodes {
@@ -338,77 +505,97 @@ func BannedNodeTracker(impl *RegistrationImpl) error {
 			return errors.Errorf("Failed to convert node %s to id.ID: %v", n.Id, err)
 		}
 
-		gatewayID := nodeId.DeepCopy()
-		gatewayID.SetType(id.Gateway)
-
-		var remainingNodes []ndf.Node
-		var remainingGateways []ndf.Gateway
-		// Loop through NDF nodes to remove any that are banned
-		for i, n := range def.Nodes {
-			ndfNodeID, err := id.Unmarshal(n.ID)
-			if err != nil {
-				return errors.WithMessage(err, "Failed to unmarshal node id from NDF")
-			}
-			if ndfNodeID.Cmp(nodeId) {
-				continue
-			} else {
-				remainingNodes = append(remainingNodes, def.Nodes[i])
-			}
+		if err = banNode(state, nodeId); err != nil {
+			return err
 		}
+	}
 
-		for i, g := range def.Gateways {
-			ndfGatewayID, err := id.Unmarshal(g.ID)
-			if err != nil {
-				return errors.WithMessage(err, "Failed to unmarshal gateway id from NDF")
-			}
-			if ndfGatewayID.Cmp(gatewayID) {
-				continue
-			} else {
-				remainingGateways = append(remainingGateways, def.Gateways[i])
-			}
-		}
+	return nil
+}
 
-		update := false
+// banNode removes the node and its gateway from the NDF and bans the node in
+// the node map, sending the ban to the scheduler. Nodes which are already
+// banned in the node map are only removed from the NDF. Must be called with
+// the internal NDF lock held.
+func banNode(state *storage.NetworkState, nodeId *id.ID) error {
+	def := state.GetUnprunedNdf()
+	update, err := removeNdfNode(def, nodeId)
+	if err != nil {
+		return err
+	}
+	if update {
+		state.UpdateInternalNdf(def)
+	}
 
-		if len(remainingNodes) != len(def.Nodes) {
-			def.Nodes = remainingNodes
-			update = true
-		}
+	// Get the node from the nodeMap
+	ns := state.GetNodeMap().GetNode(nodeId)
+	// If the node is already banned do not attempt to re-ban
+	if ns == nil || ns.IsBanned() {
+		return nil
+	}
 
-		if len(remainingGateways) != len(def.Gateways) {
-			def.Gateways = remainingGateways
-			update = true
-		}
+	// Ban the node, propagating the ban to the node's state
+	nun, err := ns.Ban()
+	if err != nil {
+		return errors.WithMessage(err, "Could not ban node")
+	}
 
-		if update {
-			state.UpdateInternalNdf(def)
-		}
+	// take the polling lock
+	ns.GetPollingLock().Lock()
+
+	/// Send the node's update notification to the scheduler
+	err = state.SendUpdateNotification(nun)
+	if err != nil {
+		return errors.WithMessage(err, "Could not send update notification")
+	}
+	return nil
+}
 
-		// Get the node from the nodeMap
-		ns := state.GetNodeMap().GetNode(nodeId)
-		var nun node.UpdateNotification
-		// If the node is already banned do not attempt to re-ban
-		if ns == nil || ns.IsBanned() {
+// removeNdfNode removes the node and its gateway from the NDF, returning true
+// if either was in it.
+func removeNdfNode(def *ndf.NetworkDefinition, nodeId *id.ID) (bool, error) {
+	gatewayID := nodeId.DeepCopy()
+	gatewayID.SetType(id.Gateway)
+
+	var remainingNodes []ndf.Node
+	var remainingGateways []ndf.Gateway
+	// Loop through NDF nodes to remove the node
+	for i, n := range def.Nodes {
+		ndfNodeID, err := id.Unmarshal(n.ID)
+		if err != nil {
+			return false, errors.WithMessage(err, "Failed to unmarshal node id from NDF")
+		}
+		if ndfNodeID.Cmp(nodeId) {
 			continue
+		} else {
+			remainingNodes = append(remainingNodes, def.Nodes[i])
 		}
+	}
 
-		// Ban the node, propagating the ban to the node's state
-		nun, err = ns.Ban()
+	for i, g := range def.Gateways {
+		ndfGatewayID, err := id.Unmarshal(g.ID)
 		if err != nil {
-			return errors.WithMessage(err, "Could not ban node")
+			return false, errors.WithMessage(err, "Failed to unmarshal gateway id from NDF")
+		}
+		if ndfGatewayID.Cmp(gatewayID) {
+			continue
+		} else {
+			remainingGateways = append(remainingGateways, def.Gateways[i])
 		}
+	}
 
-		// take the polling lock
-		ns.GetPollingLock().Lock()
+	update := false
 
-		/// Send the node's update notification to the scheduler
-		err = state.SendUpdateNotification(nun)
-		if err != nil {
-			return errors.WithMessage(err, "Could not send update notification")
-		}
+	if len(remainingNodes) != len(def.Nodes) {
+		def.Nodes = remainingNodes
+		update = true
 	}
 
-	return nil
+	if len(remainingGateways) != len(def.Gateways) {
+		def.Gateways = remainingGateways
+		update = true
+	}
+	return update, nil
 }
 
 // NewImplementation returns a registration server Handler
@@ -476,3 +663,33 @@ func (m *RegistrationImpl) GetEarliestRoundInfo() (uint64, uint64, int64, error)
 	return earliestRound.ClientRoundId,
 		earliestRound.GatewayRoundId, earliestRound.GatewayTimestamp, nil
 }
+
+// RegenerateNdf immediately rebuilds the NDF from the current internal NDF,
+// re-signs it, and writes it to the configured outputs without waiting for a
+// poll to trigger it. Used after administrative changes such as manual
+// address corrections or key rotation. It is safe to call concurrently with
+// poll driven NDF updates.
+func (m *RegistrationImpl) RegenerateNdf(auth *connect.Auth) error {
+	if err := checkAdminAuth(auth); err != nil {
+		return err
+	}
+
+	m.State.InternalNdfLock.Lock()
+	currentNdf := m.State.GetUnprunedNdf()
+	if currentNdf == nil {
+		m.State.InternalNdfLock.Unlock()
+		return errors.New("Cannot regenerate NDF: no internal NDF exists")
+	}
+
+	// Refresh the timestamp so the output is not skipped as stale
+	m.State.UpdateInternalNdf(currentNdf)
+	m.State.InternalNdfLock.Unlock()
+
+	err := m.State.UpdateOutputNdf()
+	if err != nil {
+		return errors.WithMessage(err, "Failed to regenerate NDF")
+	}
+
+	jww.INFO.Printf("Regenerated NDF on request")
+	return nil
+}
@@ -0,0 +1,100 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package cmd
+
+import (
+	"bytes"
+	"crypto/rand"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/xx_network/comms/connect"
+	"gitlab.com/xx_network/comms/signature"
+	"gitlab.com/xx_network/crypto/signature/rsa"
+	"gitlab.com/xx_network/primitives/id"
+	"gitlab.com/xx_network/primitives/ndf"
+	"gitlab.com/xx_network/primitives/region"
+	"sync"
+	"testing"
+)
+
+// Tests that RegenerateNdf publishes changes made directly to the internal
+// NDF with a fresh, valid signature, and that only the permissioning server can
+// trigger it.
+func TestRegistrationImpl_RegenerateNdf(t *testing.T) {
+	var err error
+	storage.PermissioningDb, _, err = storage.NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	privKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	testState, err := storage.NewState(privKey, 8, "", "", region.GetCountryBins())
+	if err != nil {
+		t.Fatalf("Failed to create test state: %v", err)
+	}
+	impl := &RegistrationImpl{State: testState}
+
+	nid := id.NewIdFromString("node", id.Node, t)
+	testState.UpdateInternalNdf(&ndf.NetworkDefinition{
+		Nodes:    []ndf.Node{{ID: nid.Marshal(), Address: "1.2.3.4:11420"}},
+		Gateways: []ndf.Gateway{{ID: nid.Marshal(), Address: "1.2.3.4:22840"}},
+	})
+	if err = testState.UpdateOutputNdf(); err != nil {
+		t.Fatalf("Failed to output initial NDF: %+v", err)
+	}
+	oldSig := testState.GetFullNdf().GetPb().GetSig().GetSignature()
+
+	// Change the internal NDF without refreshing its timestamp so that a
+	// plain output update would skip it
+	testState.InternalNdfLock.Lock()
+	testState.GetUnprunedNdf().Nodes[0].Address = "5.6.7.8:11420"
+	testState.InternalNdfLock.Unlock()
+
+	// Regenerate concurrently with poll driven output updates
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := testState.UpdateOutputNdf(); err != nil {
+				t.Errorf("UpdateOutputNdf() returned an error: %+v", err)
+			}
+		}()
+	}
+	nodeHost, err := connect.NewHost(nid, "", nil, connect.GetDefaultHostParams())
+	if err != nil {
+		t.Fatalf("Failed to create host: %+v", err)
+	}
+	if impl.RegenerateNdf(&connect.Auth{IsAuthenticated: true, Sender: nodeHost}) == nil {
+		t.Errorf("Node was able to regenerate the NDF.")
+	}
+
+	permHost, err := connect.NewHost(&id.Permissioning, "", nil,
+		connect.GetDefaultHostParams())
+	if err != nil {
+		t.Fatalf("Failed to create host: %+v", err)
+	}
+	auth := &connect.Auth{IsAuthenticated: true, Sender: permHost}
+	if err = impl.RegenerateNdf(auth); err != nil {
+		t.Fatalf("RegenerateNdf() returned an error: %+v", err)
+	}
+	wg.Wait()
+
+	published := testState.GetFullNdf()
+	if published.Get().Nodes[0].Address != "5.6.7.8:11420" {
+		t.Errorf("Published NDF does not reflect the change."+
+			"\nexpected: %s\nreceived: %s", "5.6.7.8:11420",
+			published.Get().Nodes[0].Address)
+	}
+
+	if err = signature.VerifyRsa(published.GetPb(), privKey.GetPublic()); err != nil {
+		t.Errorf("Published NDF signature is invalid: %+v", err)
+	}
+	if bytes.Equal(oldSig, published.GetPb().GetSig().GetSignature()) {
+		t.Errorf("Published NDF was not re-signed.")
+	}
+}
@@ -0,0 +1,532 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Contains the synthetic load generator used to measure how many nodes a
+// single permissioning instance can handle
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	jww "github.com/spf13/jwalterweatherman"
+	"github.com/spf13/viper"
+	pb "gitlab.com/elixxir/comms/mixmessages"
+	"gitlab.com/elixxir/primitives/current"
+	"gitlab.com/elixxir/primitives/states"
+	"gitlab.com/elixxir/primitives/version"
+	"gitlab.com/elixxir/registration/scheduling"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/elixxir/registration/storage/node"
+	"gitlab.com/elixxir/registration/testkeys"
+	"gitlab.com/xx_network/comms/connect"
+	"gitlab.com/xx_network/primitives/id"
+	"gitlab.com/xx_network/primitives/utils"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Version reported by the synthetic nodes and required of them
+const loadTestVersion = "1.1.0"
+
+// Stages of stopping a load test run. While draining, nodes stop once they
+// are not in a round; when aborting, they stop immediately.
+const (
+	loadTestRunning uint32 = iota
+	loadTestDrain
+	loadTestAbort
+)
+
+// loadTestConfig describes a synthetic load test run
+type loadTestConfig struct {
+	// Number of synthetic nodes to simulate
+	numNodes int
+	// How long the nodes poll for before the run is stopped
+	duration time.Duration
+	// Time between polls of each node
+	pollInterval time.Duration
+	// Scheduling parameters used to team the nodes
+	teamSize  uint32
+	batchSize uint32
+	threshold float64
+	// How long a round may run before it is timed out
+	roundTimeout time.Duration
+	// Address the registration server listens on
+	address string
+}
+
+// LoadTestStats summarizes a synthetic load test run.
+type LoadTestStats struct {
+	Nodes    int
+	Duration time.Duration
+
+	// Number of polls sent and how many of them returned an error
+	Polls      uint64
+	PollErrors uint64
+
+	// Polls handled per second and the 99th percentile poll latency
+	Throughput     float64
+	P99PollLatency time.Duration
+
+	// Rounds the synthetic nodes saw finish
+	RoundsCompleted uint64
+	RoundsFailed    uint64
+
+	// Time between a node entering the waiting pool and it seeing the round
+	// it was assigned to
+	MeanSchedulerLag time.Duration
+	MaxSchedulerLag  time.Duration
+}
+
+// String returns a human-readable summary of the run.
+func (s LoadTestStats) String() string {
+	lines := []string{
+		fmt.Sprintf("Nodes:              %d", s.Nodes),
+		fmt.Sprintf("Duration:           %s", s.Duration),
+		fmt.Sprintf("Polls:              %d (%d errors)", s.Polls, s.PollErrors),
+		fmt.Sprintf("Throughput:         %.1f polls/s", s.Throughput),
+		fmt.Sprintf("p99 poll latency:   %s", s.P99PollLatency),
+		fmt.Sprintf("Rounds completed:   %d (%d failed)", s.RoundsCompleted, s.RoundsFailed),
+		fmt.Sprintf("Scheduler lag:      %s mean, %s max", s.MeanSchedulerLag, s.MaxSchedulerLag),
+	}
+	return strings.Join(lines, "\n")
+}
+
+// loadTestActor is a synthetic node which polls the Poll handler directly and
+// walks through the node state machine for the rounds it is assigned to.
+type loadTestActor struct {
+	impl  *RegistrationImpl
+	state *node.State
+	auth  *connect.Auth
+	msg   *pb.PermissioningPoll
+
+	// Activity the actor is trying to report and the round it is in
+	desired    current.Activity
+	roundID    id.Round
+	roundState states.Round
+
+	// When the actor entered the waiting pool, zero if it is not waiting
+	waitingSince time.Time
+
+	latencies []time.Duration
+	lags      []time.Duration
+	errors    uint64
+}
+
+// loadTestRounds tracks the rounds the actors have seen finish
+type loadTestRounds struct {
+	finished  sync.Map
+	completed uint64
+	failed    uint64
+}
+
+// runLoadTest registers the configured number of synthetic nodes with an
+// in-process registration server backed by in-memory storage, runs the
+// scheduler, and drives the nodes through rounds until the duration expires.
+// Nodes finish the round they are in before the run is stopped.
+func runLoadTest(config loadTestConfig) (LoadTestStats, error) {
+	if int(config.teamSize) > config.numNodes || config.teamSize == 0 {
+		return LoadTestStats{}, errors.Errorf("Team size %d is invalid for "+
+			"%d nodes", config.teamSize, config.numNodes)
+	}
+
+	var err error
+	var closeDb func() error
+	storage.PermissioningDb, closeDb, err = storage.NewDatabase(
+		"", "", fmt.Sprintf("loadTest%d", time.Now().UnixNano()), "", "")
+	if err != nil {
+		return LoadTestStats{}, errors.WithMessage(err,
+			"Failed to create load test database")
+	}
+	defer func() {
+		if err := closeDb(); err != nil {
+			jww.ERROR.Printf("Error closing load test database: %+v", err)
+		}
+	}()
+
+	nodeVersion, err := version.ParseVersion(loadTestVersion)
+	if err != nil {
+		return LoadTestStats{}, err
+	}
+
+	params := Params{
+		Address:                    config.address,
+		CertPath:                   testkeys.GetCACertPath(),
+		KeyPath:                    testkeys.GetCAKeyPath(),
+		FullNdfOutputPath:          filepath.Join(os.TempDir(), "loadTestNdf.json"),
+		SignedPartialNdfOutputPath: filepath.Join(os.TempDir(), "loadTestPartialNdf.json"),
+		udbCertPath:                testkeys.GetUdbCertPath(),
+		minimumNodes:               uint32(config.numNodes),
+		minGatewayVersion:          nodeVersion,
+		minServerVersion:           nodeVersion,
+		addressSpaceSize:           32,
+		disableGeoBinning:          true,
+		disablePing:                true,
+		disableNDFPruning:          true,
+		pruneRetentionLimit:        defaultPruneRetention,
+	}
+
+	impl, err := StartRegistration(params)
+	if err != nil {
+		return LoadTestStats{}, errors.WithMessage(err,
+			"Failed to start registration server")
+	}
+	defer impl.Comms.Shutdown()
+
+	actors, err := registerLoadTestNodes(impl, config.numNodes)
+	if err != nil {
+		return LoadTestStats{}, err
+	}
+
+	err = impl.State.UpdateOutputNdf()
+	if err != nil {
+		return LoadTestStats{}, errors.WithMessage(err,
+			"Failed to update output NDF with registered nodes")
+	}
+
+	roundTimeout := uint64(config.roundTimeout / time.Millisecond)
+	schedulingParams := &scheduling.SafeParams{Params: &scheduling.Params{
+		TeamSize:              config.teamSize,
+		BatchSize:             config.batchSize,
+		Threshold:             config.threshold,
+		ResourceQueueTimeout:  time.Duration(roundTimeout),
+		PrecomputationTimeout: time.Duration(roundTimeout),
+		RealtimeTimeout:       time.Duration(roundTimeout),
+	}}
+	impl.schedulingParams = schedulingParams
+
+	schedulerErr := make(chan error, 1)
+	go func() {
+		schedulerErr <- scheduling.Scheduler(schedulingParams, impl.State,
+			make(chan chan struct{}))
+	}()
+
+	var stopping uint32
+	rounds := &loadTestRounds{}
+	wg := sync.WaitGroup{}
+	start := time.Now()
+	for _, actor := range actors {
+		wg.Add(1)
+		go func(actor *loadTestActor) {
+			defer wg.Done()
+			actor.run(config.pollInterval, &stopping, rounds)
+		}(actor)
+	}
+
+	// Stop the run once the duration expires, unless scheduling fails first
+	select {
+	case <-time.After(config.duration):
+	case err = <-schedulerErr:
+		atomic.StoreUint32(&stopping, loadTestAbort)
+		wg.Wait()
+		return LoadTestStats{}, errors.WithMessage(err, "Scheduler exited")
+	}
+
+	// Give the nodes time to finish their rounds, which at worst time out
+	atomic.StoreUint32(&stopping, loadTestDrain)
+	drained := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+	case <-time.After(3 * config.roundTimeout):
+		jww.WARN.Printf("Load test nodes did not finish their rounds in %s",
+			3*config.roundTimeout)
+		atomic.StoreUint32(&stopping, loadTestAbort)
+		<-drained
+	}
+	elapsed := time.Since(start)
+
+	return summarizeLoadTest(actors, rounds, config.numNodes, elapsed), nil
+}
+
+// registerLoadTestNodes populates registration codes for the synthetic nodes
+// and registers them with the server. Every node shares the test certificate
+// and derives its own identity from a unique salt.
+func registerLoadTestNodes(impl *RegistrationImpl, numNodes int) ([]*loadTestActor, error) {
+	cert, err := utils.ReadFile(testkeys.GetNodeCertPath())
+	if err != nil {
+		return nil, errors.Errorf("Could not read node certificate: %+v", err)
+	}
+
+	infos := make([]node.Info, numNodes)
+	for i := range infos {
+		infos[i] = node.Info{RegCode: fmt.Sprintf("LOADTEST%d", i), Order: "US"}
+	}
+	storage.PopulateNodeRegistrationCodes(infos)
+
+	actors := make([]*loadTestActor, numNodes)
+	for i, info := range infos {
+		ip := fmt.Sprintf("10.%d.%d.%d", (i>>16)&0xFF, (i>>8)&0xFF, i&0xFF)
+		serverAddr, gatewayAddr := ip+":11420", ip+":8443"
+		salt := []byte(fmt.Sprintf("%032d", i))
+
+		err = impl.RegisterNode(salt, serverAddr, string(cert), gatewayAddr,
+			string(cert), info.RegCode)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "Failed to register "+
+				"synthetic node %d", i)
+		}
+
+		nodeInfo, err := storage.PermissioningDb.GetNode(info.RegCode)
+		if err != nil {
+			return nil, err
+		}
+		nid, err := id.Unmarshal(nodeInfo.Id)
+		if err != nil {
+			return nil, err
+		}
+		host, ok := impl.Comms.GetHost(nid)
+		if !ok {
+			return nil, errors.Errorf("No host for synthetic node %s", nid)
+		}
+
+		actors[i] = &loadTestActor{
+			impl:  impl,
+			state: impl.State.GetNodeMap().GetNode(nid),
+			auth: &connect.Auth{
+				IsAuthenticated: true,
+				Sender:          host,
+				IpAddress:       ip,
+			},
+			msg: &pb.PermissioningPoll{
+				Full:           &pb.NDFHash{},
+				Partial:        &pb.NDFHash{},
+				ServerAddress:  serverAddr,
+				GatewayAddress: gatewayAddr,
+				ServerVersion:  loadTestVersion,
+				GatewayVersion: loadTestVersion,
+			},
+			desired: current.WAITING,
+		}
+	}
+
+	return actors, nil
+}
+
+// run polls at the given interval until the run is aborted, or is drained and
+// the actor is not in a round.
+func (a *loadTestActor) run(interval time.Duration, stopping *uint32,
+	rounds *loadTestRounds) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		switch atomic.LoadUint32(stopping) {
+		case loadTestDrain:
+			if a.roundID == 0 {
+				return
+			}
+		case loadTestAbort:
+			return
+		}
+		a.poll(rounds)
+	}
+}
+
+// poll sends a single poll reporting the desired activity, then processes the
+// round updates in the response and advances the desired activity.
+func (a *loadTestActor) poll(rounds *loadTestRounds) {
+	a.msg.Activity = uint32(a.desired)
+
+	start := time.Now()
+	response, err := a.impl.Poll(a.msg, a.auth)
+	a.latencies = append(a.latencies, time.Since(start))
+	if err != nil {
+		a.errors++
+	}
+	if response == nil {
+		return
+	}
+
+	if response.FullNDF != nil {
+		a.msg.Full.Hash = a.impl.State.GetFullNdf().GetHash()
+	}
+	for _, update := range response.Updates {
+		a.processUpdate(update, rounds)
+	}
+
+	a.advance()
+}
+
+// processUpdate tracks the state of the round the actor is assigned to.
+func (a *loadTestActor) processUpdate(update *pb.RoundInfo, rounds *loadTestRounds) {
+	if update.UpdateID > a.msg.LastUpdate {
+		a.msg.LastUpdate = update.UpdateID
+	}
+
+	roundID := id.Round(update.ID)
+	roundState := states.Round(update.State)
+	if roundState == states.COMPLETED || roundState == states.FAILED {
+		if _, seen := rounds.finished.LoadOrStore(roundID, true); !seen {
+			if roundState == states.COMPLETED {
+				atomic.AddUint64(&rounds.completed, 1)
+			} else {
+				atomic.AddUint64(&rounds.failed, 1)
+			}
+		}
+	}
+
+	if a.roundID == 0 && roundState == states.PRECOMPUTING && a.inTopology(update) {
+		a.roundID, a.roundState = roundID, roundState
+		if !a.waitingSince.IsZero() {
+			a.lags = append(a.lags, time.Since(a.waitingSince))
+			a.waitingSince = time.Time{}
+		}
+		a.desired = current.STANDBY
+	} else if roundID == a.roundID && roundState > a.roundState {
+		a.roundState = roundState
+	}
+}
+
+// advance moves the desired activity on once the node's state has reached it.
+func (a *loadTestActor) advance() {
+	// Polls after a round fails are forced to an error, after which the
+	// node can wait again
+	if a.roundState == states.FAILED || a.state.GetActivity() == current.ERROR {
+		a.roundID, a.roundState = 0, 0
+		a.desired = current.WAITING
+		return
+	}
+
+	if a.state.GetActivity() != a.desired {
+		return
+	}
+
+	switch a.desired {
+	case current.WAITING:
+		if a.waitingSince.IsZero() && a.roundID == 0 {
+			a.waitingSince = time.Now()
+		}
+	case current.STANDBY:
+		if a.roundState >= states.QUEUED {
+			a.desired = current.REALTIME
+		}
+	case current.REALTIME:
+		a.desired = current.COMPLETED
+	case current.COMPLETED:
+		a.roundID, a.roundState = 0, 0
+		a.desired = current.WAITING
+	}
+}
+
+// inTopology returns true if the actor's node is in the round's topology.
+func (a *loadTestActor) inTopology(update *pb.RoundInfo) bool {
+	nid := a.state.GetID().Marshal()
+	for _, member := range update.Topology {
+		if bytes.Equal(member, nid) {
+			return true
+		}
+	}
+	return false
+}
+
+// summarizeLoadTest merges the measurements of all the actors.
+func summarizeLoadTest(actors []*loadTestActor, rounds *loadTestRounds,
+	numNodes int, elapsed time.Duration) LoadTestStats {
+	stats := LoadTestStats{
+		Nodes:           numNodes,
+		Duration:        elapsed,
+		RoundsCompleted: atomic.LoadUint64(&rounds.completed),
+		RoundsFailed:    atomic.LoadUint64(&rounds.failed),
+	}
+
+	var latencies []time.Duration
+	var totalLag time.Duration
+	numLags := 0
+	for _, actor := range actors {
+		latencies = append(latencies, actor.latencies...)
+		stats.PollErrors += actor.errors
+		for _, lag := range actor.lags {
+			totalLag += lag
+			numLags++
+			if lag > stats.MaxSchedulerLag {
+				stats.MaxSchedulerLag = lag
+			}
+		}
+	}
+
+	stats.Polls = uint64(len(latencies))
+	stats.Throughput = float64(stats.Polls) / elapsed.Seconds()
+	if len(latencies) > 0 {
+		sort.Slice(latencies, func(i, j int) bool {
+			return latencies[i] < latencies[j]
+		})
+		stats.P99PollLatency = latencies[(len(latencies)*99+99)/100-1]
+	}
+	if numLags > 0 {
+		stats.MeanSchedulerLag = totalLag / time.Duration(numLags)
+	}
+
+	return stats
+}
+
+// loadTestCmd runs a synthetic load test
+var loadTestCmd = &cobra.Command{
+	Use:   "loadtest",
+	Short: "Runs a synthetic load test against an in-process server",
+	Long: `Registers synthetic nodes with an in-process registration server ` +
+		`backed by in-memory storage and drives them through rounds using the ` +
+		`real poll handler and scheduler, then prints throughput, poll ` +
+		`latency, and scheduler lag. Uses the test keys, so it must be run ` +
+		`from a source checkout.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		config := loadTestConfig{
+			numNodes:     viper.GetInt("loadTest.nodes"),
+			duration:     viper.GetDuration("loadTest.duration"),
+			pollInterval: viper.GetDuration("loadTest.pollInterval"),
+			teamSize:     viper.GetUint32("loadTest.teamSize"),
+			batchSize:    viper.GetUint32("loadTest.batchSize"),
+			threshold:    viper.GetFloat64("loadTest.threshold"),
+			roundTimeout: viper.GetDuration("loadTest.roundTimeout"),
+			address:      viper.GetString("loadTest.address"),
+		}
+
+		stats, err := runLoadTest(config)
+		if err != nil {
+			jww.FATAL.Panicf("Load test failed: %+v", err)
+		}
+		fmt.Println(stats)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(loadTestCmd)
+
+	loadTestCmd.Flags().Int("nodes", 1000, "Number of synthetic nodes")
+	loadTestCmd.Flags().Duration("duration", time.Minute,
+		"How long the nodes poll for")
+	loadTestCmd.Flags().Duration("pollInterval", 100*time.Millisecond,
+		"Time between polls of each node")
+	loadTestCmd.Flags().Uint32("teamSize", 3, "Number of nodes in a team")
+	loadTestCmd.Flags().Uint32("batchSize", 32, "Number of slots in a batch")
+	loadTestCmd.Flags().Float64("threshold", 0, "Fraction of nodes which "+
+		"must be waiting before a team is formed")
+	loadTestCmd.Flags().Duration("roundTimeout", 30*time.Second,
+		"How long a round phase may run before the round is timed out")
+	loadTestCmd.Flags().String("address", "0.0.0.0:0",
+		"Address the in-process server listens on")
+
+	for _, flag := range []string{"nodes", "duration", "pollInterval",
+		"teamSize", "batchSize", "threshold", "roundTimeout", "address"} {
+		err := viper.BindPFlag("loadTest."+flag,
+			loadTestCmd.Flags().Lookup(flag))
+		if err != nil {
+			jww.FATAL.Panicf("could not bind flag: %+v", err)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package cmd
+
+import (
+	"testing"
+	"time"
+)
+
+// Tests that a small load test drives the synthetic nodes through rounds and
+// reports statistics for the run.
+func TestRunLoadTest(t *testing.T) {
+	dblck.Lock()
+	defer dblck.Unlock()
+
+	stats, err := runLoadTest(loadTestConfig{
+		numNodes:     12,
+		duration:     2 * time.Second,
+		pollInterval: 10 * time.Millisecond,
+		teamSize:     3,
+		batchSize:    32,
+		roundTimeout: 5 * time.Second,
+		address:      "0.0.0.0:5950",
+	})
+	if err != nil {
+		t.Fatalf("Load test failed: %+v", err)
+	}
+	t.Logf("Load test results:\n%s", stats)
+
+	if stats.Nodes != 12 || stats.Polls == 0 || stats.Throughput <= 0 {
+		t.Errorf("No polls recorded: %+v", stats)
+	}
+	if stats.P99PollLatency <= 0 {
+		t.Errorf("No poll latency recorded: %+v", stats)
+	}
+	if stats.RoundsCompleted == 0 {
+		t.Errorf("No rounds completed: %+v", stats)
+	}
+	if stats.MeanSchedulerLag <= 0 || stats.MaxSchedulerLag < stats.MeanSchedulerLag {
+		t.Errorf("Invalid scheduler lag: %+v", stats)
+	}
+}
+
+// Tests that runLoadTest rejects a team larger than the number of nodes.
+func TestRunLoadTest_InvalidTeamSize(t *testing.T) {
+	_, err := runLoadTest(loadTestConfig{numNodes: 2, teamSize: 3})
+	if err == nil {
+		t.Errorf("Load test did not error for a team larger than the network.")
+	}
+}
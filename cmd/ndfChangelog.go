@@ -0,0 +1,27 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Contains the endpoint serving the changelog of published NDFs
+
+package cmd
+
+import (
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/xx_network/comms/connect"
+)
+
+// GetNdfChanges returns the change records of the NDFs published after
+// fromVersion, oldest first, so that services mirroring the NDF can apply the
+// changes without diffing NDFs. Returns an error if the records since the
+// version are no longer kept, in which case the full NDF must be fetched.
+func (m *RegistrationImpl) GetNdfChanges(auth *connect.Auth,
+	fromVersion uint64) ([]storage.NdfChange, error) {
+	if !auth.IsAuthenticated {
+		return nil, connect.AuthError(auth.Sender.GetId())
+	}
+	return m.State.GetNdfChanges(fromVersion)
+}
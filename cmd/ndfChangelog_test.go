@@ -0,0 +1,63 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package cmd
+
+import (
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/xx_network/comms/connect"
+	"gitlab.com/xx_network/primitives/id"
+	"gitlab.com/xx_network/primitives/ndf"
+	"gitlab.com/xx_network/primitives/region"
+	"testing"
+)
+
+// Tests that only authenticated senders get the NDF changes, and that they
+// get the changes after the requested version.
+func TestRegistrationImpl_GetNdfChanges(t *testing.T) {
+	var err error
+	var dc func() error
+	storage.PermissioningDb, dc, err = storage.NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("Failed to create database: %+v", err)
+	}
+	t.Cleanup(func() { _ = dc() })
+	state, err := storage.NewState(getTestKey(), 8, "", "", region.GetCountryBins())
+	if err != nil {
+		t.Fatalf("Failed to create state: %+v", err)
+	}
+	impl := &RegistrationImpl{State: state}
+
+	nid := id.NewIdFromString("node", id.Node, t)
+	for _, addr := range []string{"10.0.0.1:11420", "10.0.0.2:11420"} {
+		state.UpdateInternalNdf(&ndf.NetworkDefinition{
+			Nodes: []ndf.Node{{ID: nid.Marshal(), Address: addr}},
+		})
+		if err = state.UpdateOutputNdf(); err != nil {
+			t.Fatalf("Failed to publish NDF: %+v", err)
+		}
+	}
+
+	host, err := connect.NewHost(nid, "", nil, connect.GetDefaultHostParams())
+	if err != nil {
+		t.Fatalf("Failed to create host: %+v", err)
+	}
+	if _, err = impl.GetNdfChanges(&connect.Auth{Sender: host}, 0); err == nil {
+		t.Errorf("Unauthenticated sender was able to get the NDF changes.")
+	}
+
+	changes, err := impl.GetNdfChanges(
+		&connect.Auth{IsAuthenticated: true, Sender: host}, 1)
+	if err != nil {
+		t.Fatalf("Failed to get the NDF changes: %+v", err)
+	}
+	if len(changes) != 1 || changes[0].Version != 2 ||
+		len(changes[0].AddressChanges) != 1 ||
+		changes[0].AddressChanges[0].New != "10.0.0.2:11420" {
+		t.Errorf("Unexpected NDF changes: %+v", changes)
+	}
+}
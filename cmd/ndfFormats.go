@@ -0,0 +1,34 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Handles consumers selecting the format of the NDF they are sent
+
+package cmd
+
+import (
+	"bytes"
+)
+
+// Prefix of an NDF hash which selects the format of the NDF sent, in the form
+// "ndf-format:<format>:<hash>"
+var ndfFormatPrefix = []byte("ndf-format:")
+
+// parseNdfFormatRequest splits the hash sent in an NDF poll into the format
+// requested and the hash of the NDF the consumer holds in that format. The
+// format is empty if the consumer did not select one, in which case the
+// current format is sent.
+func parseNdfFormatRequest(theirNdfHash []byte) (string, []byte) {
+	if !bytes.HasPrefix(theirNdfHash, ndfFormatPrefix) {
+		return "", theirNdfHash
+	}
+	rest := theirNdfHash[len(ndfFormatPrefix):]
+	sep := bytes.IndexByte(rest, ':')
+	if sep < 0 {
+		return string(rest), nil
+	}
+	return string(rest[:sep]), rest[sep+1:]
+}
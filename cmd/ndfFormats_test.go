@@ -0,0 +1,92 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package cmd
+
+import (
+	"bytes"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/xx_network/primitives/id"
+	"gitlab.com/xx_network/primitives/ndf"
+	"gitlab.com/xx_network/primitives/region"
+	"testing"
+)
+
+// Tests that PollNdf() sends the NDF in the format the consumer selects.
+func TestRegistrationImpl_PollNdf_Format(t *testing.T) {
+	var err error
+	var dc func() error
+	storage.PermissioningDb, dc, err = storage.NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("Failed to create database: %+v", err)
+	}
+	t.Cleanup(func() { _ = dc() })
+	state, err := storage.NewState(getTestKey(), 8, "", "", region.GetCountryBins())
+	if err != nil {
+		t.Fatalf("Failed to create state: %+v", err)
+	}
+	ndfReady := uint32(1)
+	impl := &RegistrationImpl{State: state, NdfReady: &ndfReady}
+
+	state.UpdateInternalNdf(&ndf.NetworkDefinition{
+		Nodes: []ndf.Node{{ID: id.NewIdFromString("node", id.Node, t).Marshal(),
+			Address: "10.0.0.1:11420"}},
+	})
+	if err = state.UpdateOutputNdf(); err != nil {
+		t.Fatalf("Failed to publish NDF: %+v", err)
+	}
+
+	current, err := impl.PollNdf(nil)
+	if err != nil {
+		t.Fatalf("Failed to poll the NDF: %+v", err)
+	}
+	request := []byte("ndf-format:" + storage.LegacyNdfFormat + ":")
+	legacy, err := impl.PollNdf(request)
+	if err != nil {
+		t.Fatalf("Failed to poll the legacy NDF: %+v", err)
+	}
+	if !bytes.Equal(legacy.Ndf, state.GetFormatNdf(storage.LegacyNdfFormat).GetPb().Ndf) {
+		t.Errorf("Legacy NDF was not sent.")
+	}
+	if bytes.Equal(legacy.Ndf, current.Ndf) {
+		t.Errorf("Current NDF was sent in place of the legacy NDF.")
+	}
+
+	// A consumer holding the legacy NDF is not sent it again
+	request = append(request,
+		state.GetFormatNdf(storage.LegacyNdfFormat).GetHash()...)
+	if legacy, err = impl.PollNdf(request); err != nil {
+		t.Fatalf("Failed to poll the legacy NDF: %+v", err)
+	}
+	if len(legacy.Ndf) != 0 {
+		t.Errorf("Consumer holding the legacy NDF was sent it again.")
+	}
+
+	if _, err = impl.PollNdf([]byte("ndf-format:unknown:")); err == nil {
+		t.Errorf("PollNdf() accepted an unknown format.")
+	}
+}
+
+// Tests that parseNdfFormatRequest() splits the format from the hash.
+func TestParseNdfFormatRequest(t *testing.T) {
+	tests := []struct {
+		request, format, hash string
+	}{
+		{"hash", "", "hash"},
+		{"ndf-format:legacy:hash", "legacy", "hash"},
+		{"ndf-format:legacy:", "legacy", ""},
+		{"ndf-format:legacy", "legacy", ""},
+		{"ndf-format:legacy:a:b", "legacy", "a:b"},
+	}
+	for _, tt := range tests {
+		format, hash := parseNdfFormatRequest([]byte(tt.request))
+		if format != tt.format || string(hash) != tt.hash {
+			t.Errorf("Unexpected split of %q.\nexpected: %q, %q"+
+				"\nreceived: %q, %q", tt.request, tt.format, tt.hash, format, hash)
+		}
+	}
+}
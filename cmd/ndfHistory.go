@@ -0,0 +1,65 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Contains the administrative functions for rolling back a bad NDF update and
+// looking up the NDFs published in the past
+
+package cmd
+
+import (
+	"encoding/base64"
+	jww "github.com/spf13/jwalterweatherman"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/xx_network/comms/connect"
+	"time"
+)
+
+// GetNdfHistory returns the published NDFs which can be rolled back to,
+// oldest first.
+func (m *RegistrationImpl) GetNdfHistory(auth *connect.Auth) ([]storage.NdfVersion, error) {
+	if err := checkAdminAuth(auth); err != nil {
+		return nil, err
+	}
+	return m.State.GetNdfHistory(), nil
+}
+
+// GetNdfAt returns the full NDF which was published at the given time, from
+// the NDF snapshots kept in Storage.
+func (m *RegistrationImpl) GetNdfAt(auth *connect.Auth, t time.Time) ([]byte, error) {
+	if err := checkAdminAuth(auth); err != nil {
+		return nil, err
+	}
+	return m.State.GetNdfAt(t)
+}
+
+// GetNdfByHash returns the full NDF with the given hash, from the NDF
+// snapshots kept in Storage.
+func (m *RegistrationImpl) GetNdfByHash(auth *connect.Auth, hash []byte) ([]byte, error) {
+	if err := checkAdminAuth(auth); err != nil {
+		return nil, err
+	}
+	return m.State.GetNdfByHash(hash)
+}
+
+// RollbackNdf re-publishes the NDF with the given version, re-signed so that
+// nodes, gateways and clients accept it. Every rollback is logged for audit.
+func (m *RegistrationImpl) RollbackNdf(auth *connect.Auth, version uint64) error {
+	if err := checkAdminAuth(auth); err != nil {
+		return err
+	}
+
+	if err := m.State.RollbackNdf(version); err != nil {
+		jww.ERROR.Printf("AUDIT: %s failed to roll back the NDF to version "+
+			"%d: %+v", auth.Sender.GetId(), version, err)
+		return err
+	}
+
+	jww.WARN.Printf("AUDIT: %s rolled back the NDF to version %d, "+
+		"re-published with hash %s", auth.Sender.GetId(), version,
+		base64.StdEncoding.EncodeToString(m.State.GetFullNdf().GetHash()))
+	return nil
+}
@@ -0,0 +1,157 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package cmd
+
+import (
+	"bytes"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/xx_network/comms/connect"
+	"gitlab.com/xx_network/primitives/id"
+	"gitlab.com/xx_network/primitives/ndf"
+	"gitlab.com/xx_network/primitives/region"
+	"testing"
+	"time"
+)
+
+// Tests that only the permissioning server can roll back the NDF and that
+// clients holding a later NDF are sent the rolled back one.
+func TestRegistrationImpl_RollbackNdf(t *testing.T) {
+	var err error
+	var dc func() error
+	storage.PermissioningDb, dc, err = storage.NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("Failed to create database: %+v", err)
+	}
+	t.Cleanup(func() { _ = dc() })
+	state, err := storage.NewState(getTestKey(), 8, "", "", region.GetCountryBins())
+	if err != nil {
+		t.Fatalf("Failed to create state: %+v", err)
+	}
+	ndfReady := uint32(1)
+	impl := &RegistrationImpl{State: state, NdfReady: &ndfReady}
+
+	nid := id.NewIdFromString("node", id.Node, t)
+	for _, addr := range []string{"10.0.0.1:11420", "10.0.0.2:11420", "10.0.0.3:11420"} {
+		state.UpdateInternalNdf(&ndf.NetworkDefinition{
+			Nodes: []ndf.Node{{ID: nid.Marshal(), Address: addr}},
+		})
+		if err = state.UpdateOutputNdf(); err != nil {
+			t.Fatalf("Failed to publish NDF: %+v", err)
+		}
+	}
+	latestHash := state.GetPartialNdf().GetHash()
+
+	permHost, err := connect.NewHost(&id.Permissioning, "", nil,
+		connect.GetDefaultHostParams())
+	if err != nil {
+		t.Fatalf("Failed to create host: %+v", err)
+	}
+	nodeHost, err := connect.NewHost(nid, "", nil, connect.GetDefaultHostParams())
+	if err != nil {
+		t.Fatalf("Failed to create host: %+v", err)
+	}
+
+	if err = impl.RollbackNdf(&connect.Auth{IsAuthenticated: true, Sender: nodeHost}, 1); err == nil {
+		t.Errorf("Node was able to roll back the NDF.")
+	}
+	if _, err = impl.GetNdfHistory(&connect.Auth{Sender: permHost}); err == nil {
+		t.Errorf("Unauthenticated sender was able to get the NDF history.")
+	}
+
+	auth := &connect.Auth{IsAuthenticated: true, Sender: permHost}
+	history, err := impl.GetNdfHistory(auth)
+	if err != nil {
+		t.Fatalf("Failed to get the NDF history: %+v", err)
+	}
+	if len(history) != 3 {
+		t.Fatalf("Unexpected NDF history: %+v", history)
+	}
+	if err = impl.RollbackNdf(auth, history[0].Version); err != nil {
+		t.Fatalf("Failed to roll back the NDF: %+v", err)
+	}
+
+	polled, err := impl.PollNdf(latestHash)
+	if err != nil {
+		t.Fatalf("Failed to poll the NDF: %+v", err)
+	}
+	if len(polled.Ndf) == 0 {
+		t.Fatalf("Client holding the latest NDF was not sent the rollback.")
+	}
+	if addr := state.GetFullNdf().Get().Nodes[0].Address; addr != "10.0.0.1:11420" {
+		t.Errorf("Unexpected NDF after rollback.\nexpected: %s\nreceived: %s",
+			"10.0.0.1:11420", addr)
+	}
+}
+
+// Tests that only the permissioning server can look up past NDFs, and that
+// they are found by time and by hash once snapshots are enabled.
+func TestRegistrationImpl_GetNdfAt(t *testing.T) {
+	var err error
+	var dc func() error
+	storage.PermissioningDb, dc, err = storage.NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("Failed to create database: %+v", err)
+	}
+	t.Cleanup(func() { _ = dc() })
+	state, err := storage.NewState(getTestKey(), 8, "", "", region.GetCountryBins())
+	if err != nil {
+		t.Fatalf("Failed to create state: %+v", err)
+	}
+	if err = state.SetNdfSnapshots(true, 0); err != nil {
+		t.Fatalf("Failed to set NDF snapshots: %+v", err)
+	}
+	impl := &RegistrationImpl{State: state}
+
+	nid := id.NewIdFromString("node", id.Node, t)
+	var first []byte
+	var between time.Time
+	for i, addr := range []string{"10.0.0.1:11420", "10.0.0.2:11420"} {
+		state.UpdateInternalNdf(&ndf.NetworkDefinition{
+			Nodes: []ndf.Node{{ID: nid.Marshal(), Address: addr}},
+		})
+		if err = state.UpdateOutputNdf(); err != nil {
+			t.Fatalf("Failed to publish NDF: %+v", err)
+		}
+		if i == 0 {
+			first = state.GetFullNdf().GetPb().Ndf
+			time.Sleep(5 * time.Millisecond)
+			between = time.Now()
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	permHost, err := connect.NewHost(&id.Permissioning, "", nil,
+		connect.GetDefaultHostParams())
+	if err != nil {
+		t.Fatalf("Failed to create host: %+v", err)
+	}
+	if _, err = impl.GetNdfAt(&connect.Auth{Sender: permHost}, between); err == nil {
+		t.Errorf("Unauthenticated sender was able to get a past NDF.")
+	}
+	if _, err = impl.GetNdfByHash(&connect.Auth{Sender: permHost}, nil); err == nil {
+		t.Errorf("Unauthenticated sender was able to get a past NDF.")
+	}
+
+	auth := &connect.Auth{IsAuthenticated: true, Sender: permHost}
+	body, err := impl.GetNdfAt(auth, between)
+	if err != nil {
+		t.Fatalf("Failed to get the NDF by time: %+v", err)
+	}
+	if !bytes.Equal(body, first) {
+		t.Errorf("Unexpected NDF by time.\nexpected: %s\nreceived: %s",
+			first, body)
+	}
+	body, err = impl.GetNdfByHash(auth, state.GetFullNdf().GetHash())
+	if err != nil {
+		t.Fatalf("Failed to get the NDF by hash: %+v", err)
+	}
+	if !bytes.Equal(body, state.GetFullNdf().GetPb().Ndf) {
+		t.Errorf("Unexpected NDF by hash.\nexpected: %s\nreceived: %s",
+			state.GetFullNdf().GetPb().Ndf, body)
+	}
+}
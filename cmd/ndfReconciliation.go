@@ -0,0 +1,312 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Handles cross-checking the NDF against the nodes in Storage and reconciling
+// any drift between them
+
+package cmd
+
+import (
+	"bytes"
+	"github.com/pkg/errors"
+	jww "github.com/spf13/jwalterweatherman"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/elixxir/registration/storage/node"
+	"gitlab.com/xx_network/comms/connect"
+	"gitlab.com/xx_network/primitives/id"
+	"gitlab.com/xx_network/primitives/ndf"
+	"sync"
+	"time"
+)
+
+// NdfReconcilePolicy decides which side is fixed when the NDF and Storage
+// disagree.
+type NdfReconcilePolicy string
+
+const (
+	// Report discrepancies without fixing them
+	NdfReconcileReportOnly NdfReconcilePolicy = "report"
+	// Fix the NDF to match Storage
+	NdfReconcileDbWins NdfReconcilePolicy = "db"
+	// Fix Storage to match the NDF, only possible for addresses
+	NdfReconcileNdfWins NdfReconcilePolicy = "ndf"
+)
+
+// NdfDriftClass classifies a discrepancy between the NDF and Storage.
+type NdfDriftClass string
+
+const (
+	// Node is in the NDF but banned in Storage
+	NdfDriftBanned NdfDriftClass = "BannedInNdf"
+	// Node is active in Storage but missing from the NDF
+	NdfDriftMissing NdfDriftClass = "MissingFromNdf"
+	// Node is in the NDF but neither active nor banned in Storage
+	NdfDriftUnknown NdfDriftClass = "UnknownInNdf"
+	// Node address in the NDF differs from the one in Storage
+	NdfDriftNodeAddress NdfDriftClass = "NodeAddressMismatch"
+	// Gateway address in the NDF differs from the one in Storage
+	NdfDriftGatewayAddress NdfDriftClass = "GatewayAddressMismatch"
+)
+
+// NdfDiscrepancy is a single disagreement between the NDF and Storage.
+type NdfDiscrepancy struct {
+	Class  NdfDriftClass
+	NodeID *id.ID
+
+	// Values held by each side, where the class has one
+	NdfValue string
+	DbValue  string
+
+	// True if the discrepancy was fixed according to the policy
+	Fixed bool
+}
+
+// NdfReconciliationReport is the result of a reconciliation pass.
+type NdfReconciliationReport struct {
+	Time          time.Time
+	Policy        NdfReconcilePolicy
+	Discrepancies []NdfDiscrepancy
+}
+
+// ndfReconciliation holds the policy and the report of the last pass
+type ndfReconciliation struct {
+	policy NdfReconcilePolicy
+	report *NdfReconciliationReport
+	mux    sync.Mutex
+}
+
+// parseNdfReconcilePolicy returns the policy with the given name, an empty
+// name selects report only.
+func parseNdfReconcilePolicy(name string) (NdfReconcilePolicy, error) {
+	switch NdfReconcilePolicy(name) {
+	case "", NdfReconcileReportOnly:
+		return NdfReconcileReportOnly, nil
+	case NdfReconcileDbWins, NdfReconcileNdfWins:
+		return NdfReconcilePolicy(name), nil
+	}
+	return "", errors.Errorf("Unknown NDF reconciliation policy %q, expected "+
+		"%q, %q or %q", name, NdfReconcileReportOnly, NdfReconcileDbWins,
+		NdfReconcileNdfWins)
+}
+
+// ReconcileNdf triggers a reconciliation pass on demand and returns its
+// report.
+func (m *RegistrationImpl) ReconcileNdf(auth *connect.Auth) (*NdfReconciliationReport, error) {
+	if err := checkAdminAuth(auth); err != nil {
+		return nil, err
+	}
+	jww.INFO.Printf("AUDIT: NDF reconciliation requested by %s",
+		auth.Sender.GetId())
+	return m.reconcileNdf(time.Now())
+}
+
+// GetNdfReconciliationReport returns the report of the last reconciliation
+// pass, or nil if none has been run.
+func (m *RegistrationImpl) GetNdfReconciliationReport(auth *connect.Auth) (*NdfReconciliationReport, error) {
+	if err := checkAdminAuth(auth); err != nil {
+		return nil, err
+	}
+	m.ndfReconciliation.mux.Lock()
+	defer m.ndfReconciliation.mux.Unlock()
+	return m.ndfReconciliation.report, nil
+}
+
+// reconcileNdf compares the internal NDF against the active and banned nodes
+// in Storage, reports every discrepancy and fixes them according to the
+// policy. Under the DB wins policy, banned and unknown nodes are removed from
+// the NDF, missing nodes are added to it and addresses are set to the ones in
+// Storage. Under the NDF wins policy, only addresses can be fixed and they are
+// written to Storage. Nodes excluded from the NDF for invalid certificates are
+// not reported missing.
+func (m *RegistrationImpl) reconcileNdf(now time.Time) (*NdfReconciliationReport, error) {
+	rec := &m.ndfReconciliation
+	rec.mux.Lock()
+	defer rec.mux.Unlock()
+
+	active, err := storage.PermissioningDb.GetNodesByStatus(node.Active)
+	if err != nil {
+		return nil, errors.WithMessage(err, "Failed to get active nodes")
+	}
+	banned, err := storage.PermissioningDb.GetNodesByStatus(node.Banned)
+	if err != nil {
+		return nil, errors.WithMessage(err, "Failed to get banned nodes")
+	}
+
+	report := &NdfReconciliationReport{Time: now, Policy: rec.policy}
+	if report.Policy == "" {
+		report.Policy = NdfReconcileReportOnly
+	}
+
+	m.State.InternalNdfLock.Lock()
+	def := m.State.GetUnprunedNdf()
+	if def == nil {
+		m.State.InternalNdfLock.Unlock()
+		return nil, errors.New("Cannot reconcile NDF: no internal NDF exists")
+	}
+
+	activeNodes := make(map[id.ID]*storage.Node, len(active))
+	for _, n := range active {
+		if nid, err := id.Unmarshal(n.Id); err == nil {
+			activeNodes[*nid] = n
+		}
+	}
+	bannedNodes := make(map[id.ID]bool, len(banned))
+	for _, n := range banned {
+		if nid, err := id.Unmarshal(n.Id); err == nil {
+			bannedNodes[*nid] = true
+		}
+	}
+
+	ndfChanged := false
+	inNdf := make(map[id.ID]bool, len(def.Nodes))
+	var keptNodes []ndf.Node
+	var keptGateways []ndf.Gateway
+	for i, ndfNode := range def.Nodes {
+		nid, err := id.Unmarshal(ndfNode.ID)
+		if err != nil {
+			m.State.InternalNdfLock.Unlock()
+			return nil, errors.WithMessage(err, "Failed to unmarshal node id "+
+				"from NDF")
+		}
+		inNdf[*nid] = true
+
+		dbNode, isActive := activeNodes[*nid]
+		if !isActive {
+			d := NdfDiscrepancy{Class: NdfDriftUnknown, NodeID: nid,
+				NdfValue: ndfNode.Address}
+			if bannedNodes[*nid] {
+				d.Class = NdfDriftBanned
+			}
+			if report.Policy == NdfReconcileDbWins {
+				d.Fixed = true
+				ndfChanged = true
+			} else {
+				keptNodes = append(keptNodes, def.Nodes[i])
+				keptGateways = append(keptGateways, def.Gateways[i])
+			}
+			report.Discrepancies = append(report.Discrepancies, d)
+			continue
+		}
+
+		nodeAddr, gwAddr := ndfNode.Address, def.Gateways[i].Address
+		if nodeAddr != dbNode.ServerAddress {
+			d := NdfDiscrepancy{Class: NdfDriftNodeAddress, NodeID: nid,
+				NdfValue: nodeAddr, DbValue: dbNode.ServerAddress}
+			if report.Policy == NdfReconcileDbWins {
+				nodeAddr = dbNode.ServerAddress
+				d.Fixed = true
+				ndfChanged = true
+			}
+			report.Discrepancies = append(report.Discrepancies, d)
+		}
+		if gwAddr != dbNode.GatewayAddress {
+			d := NdfDiscrepancy{Class: NdfDriftGatewayAddress, NodeID: nid,
+				NdfValue: gwAddr, DbValue: dbNode.GatewayAddress}
+			if report.Policy == NdfReconcileDbWins {
+				gwAddr = dbNode.GatewayAddress
+				d.Fixed = true
+				ndfChanged = true
+			}
+			report.Discrepancies = append(report.Discrepancies, d)
+		}
+		if report.Policy == NdfReconcileNdfWins && (nodeAddr != dbNode.ServerAddress ||
+			gwAddr != dbNode.GatewayAddress) {
+			err = storage.PermissioningDb.UpdateNodeAddresses(nid, nodeAddr, gwAddr)
+			if err != nil {
+				jww.ERROR.Printf("Failed to reconcile addresses of node %s "+
+					"in Storage: %+v", nid, err)
+			} else {
+				markFixed(report.Discrepancies, nid)
+			}
+		}
+
+		n, gw := def.Nodes[i], def.Gateways[i]
+		n.Address, gw.Address = nodeAddr, gwAddr
+		keptNodes = append(keptNodes, n)
+		keptGateways = append(keptGateways, gw)
+	}
+	def.Nodes, def.Gateways = keptNodes, keptGateways
+
+	for _, dbNode := range active {
+		nid, err := id.Unmarshal(dbNode.Id)
+		if err != nil || inNdf[*nid] || m.excludedForCertificates(dbNode, now) {
+			continue
+		}
+		d := NdfDiscrepancy{Class: NdfDriftMissing, NodeID: nid,
+			DbValue: dbNode.ServerAddress}
+		if report.Policy == NdfReconcileDbWins {
+			if err = m.addNodeToNdf(def, dbNode.Code, nid); err != nil {
+				jww.ERROR.Printf("Failed to add node %s to the NDF: %+v",
+					nid, err)
+			} else {
+				d.Fixed = true
+				ndfChanged = true
+			}
+		}
+		report.Discrepancies = append(report.Discrepancies, d)
+	}
+
+	if ndfChanged {
+		m.State.UpdateInternalNdf(def)
+	}
+	m.State.InternalNdfLock.Unlock()
+
+	if ndfChanged {
+		if err = m.State.UpdateOutputNdf(); err != nil {
+			return nil, errors.WithMessage(err, "Failed to output the "+
+				"reconciled NDF")
+		}
+	}
+	if report.Policy != NdfReconcileReportOnly {
+		m.State.MarkNdfReconciled()
+	}
+
+	for _, d := range report.Discrepancies {
+		jww.WARN.Printf("NDF drift %s for node %s: NDF %q, Storage %q, "+
+			"fixed: %t", d.Class, d.NodeID, d.NdfValue, d.DbValue, d.Fixed)
+	}
+	jww.INFO.Printf("Reconciled the NDF with Storage under policy %q: %d "+
+		"discrepancies", report.Policy, len(report.Discrepancies))
+
+	rec.report = report
+	return report, nil
+}
+
+// markFixed marks the address discrepancies of the node as fixed
+func markFixed(discrepancies []NdfDiscrepancy, nid *id.ID) {
+	for i := range discrepancies {
+		d := &discrepancies[i]
+		if d.NodeID.Cmp(nid) && (d.Class == NdfDriftNodeAddress ||
+			d.Class == NdfDriftGatewayAddress) {
+			d.Fixed = true
+		}
+	}
+}
+
+// excludedForCertificates returns true if the node is deliberately left out
+// of the NDF for having invalid certificates
+func (m *RegistrationImpl) excludedForCertificates(n *storage.Node, now time.Time) bool {
+	return m.params.validateNodeCerts && m.params.excludeInvalidCertNodes &&
+		len(validateNodeCertificates(n, now,
+			m.isGatewayless(n.GatewayCertificate))) > 0
+}
+
+// addNodeToNdf inserts the node registered with the code into the NDF in
+// registration order. Must be called with the internal NDF lock held.
+func (m *RegistrationImpl) addNodeToNdf(def *ndf.NetworkDefinition, code string, nid *id.ID) error {
+	gateway, n, regTime, err := m.assembleNdf(code)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(n.ID, nid.Bytes()) {
+		return errors.Errorf("Registration code %s belongs to node %s", code,
+			n.ID)
+	}
+
+	m.registrationTimes[*nid] = regTime
+	return m.insertNdf(def, gateway, n, regTime)
+}
@@ -0,0 +1,256 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package cmd
+
+import (
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/elixxir/registration/storage/node"
+	"gitlab.com/xx_network/comms/connect"
+	"gitlab.com/xx_network/primitives/id"
+	"gitlab.com/xx_network/primitives/ndf"
+	"gitlab.com/xx_network/primitives/region"
+	"testing"
+	"time"
+)
+
+// Nodes seeded with one drift class each, in registration order
+const (
+	driftNone = iota
+	driftBanned
+	driftMissing
+	driftNodeAddress
+	driftGatewayAddress
+	driftUnknown
+	numDriftNodes
+)
+
+// Seeds Storage and the NDF with a node for every drift class and returns
+// the impl and the node IDs
+func newDriftTestImpl(t *testing.T, policy NdfReconcilePolicy) (*RegistrationImpl, []*id.ID) {
+	var err error
+	var closeDb func() error
+	storage.PermissioningDb, closeDb, err = storage.NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("Failed to create database: %+v", err)
+	}
+	t.Cleanup(func() { _ = closeDb() })
+
+	state, err := storage.NewState(getTestKey(), 8, "", "", region.GetCountryBins())
+	if err != nil {
+		t.Fatalf("Failed to create state: %+v", err)
+	}
+	impl := &RegistrationImpl{
+		State:             state,
+		params:            &Params{},
+		registrationTimes: make(map[id.ID]int64),
+		ndfReconciliation: ndfReconciliation{policy: policy},
+	}
+
+	var nodes []*id.ID
+	var infos []node.Info
+	for i := 0; i < numDriftNodes; i++ {
+		nodes = append(nodes, id.NewIdFromUInt(uint64(i), id.Node, t))
+		infos = append(infos, node.Info{RegCode: "code" + nodes[i].String(),
+			Order: "US"})
+	}
+	storage.PopulateNodeRegistrationCodes(infos)
+
+	def := &ndf.NetworkDefinition{}
+	for i, nid := range nodes {
+		gwID := nid.DeepCopy()
+		gwID.SetType(id.Gateway)
+		nodeAddr, gwAddr := "10.0.0.1:11420", "10.0.0.1:22840"
+
+		if i != driftUnknown {
+			err = storage.PermissioningDb.RegisterNode(nid, []byte("salt"),
+				infos[i].RegCode, nodeAddr, "", gwAddr, "",
+				storage.SelfServeRegistration)
+			if err != nil {
+				t.Fatalf("Failed to register node: %+v", err)
+			}
+			time.Sleep(time.Millisecond)
+
+			// Registration times are known for the nodes added on startup
+			dbNode, err := storage.PermissioningDb.GetNodeById(nid)
+			if err != nil {
+				t.Fatalf("Failed to get node: %+v", err)
+			}
+			impl.registrationTimes[*nid] = dbNode.DateRegistered.UnixNano()
+		}
+
+		switch i {
+		case driftBanned:
+			err = storage.PermissioningDb.GetDatabaseImpl(t).BannedNode(nid, t)
+			if err != nil {
+				t.Fatalf("Failed to ban node: %+v", err)
+			}
+		case driftMissing:
+			continue
+		case driftNodeAddress:
+			nodeAddr = "10.0.0.2:11420"
+		case driftGatewayAddress:
+			gwAddr = "10.0.0.2:22840"
+		}
+		def.Nodes = append(def.Nodes, ndf.Node{ID: nid.Marshal(), Address: nodeAddr})
+		def.Gateways = append(def.Gateways, ndf.Gateway{ID: gwID.Marshal(), Address: gwAddr})
+	}
+
+	state.InternalNdfLock.Lock()
+	state.UpdateInternalNdf(def)
+	state.InternalNdfLock.Unlock()
+	return impl, nodes
+}
+
+// Tests that every class of drift is detected and reported without being
+// fixed under the report only policy.
+func TestRegistrationImpl_reconcileNdf_ReportOnly(t *testing.T) {
+	impl, nodes := newDriftTestImpl(t, "")
+	report, err := impl.reconcileNdf(time.Now())
+	if err != nil {
+		t.Fatalf("Failed to reconcile NDF: %+v", err)
+	}
+
+	expected := map[NdfDriftClass]*id.ID{
+		NdfDriftBanned:         nodes[driftBanned],
+		NdfDriftMissing:        nodes[driftMissing],
+		NdfDriftNodeAddress:    nodes[driftNodeAddress],
+		NdfDriftGatewayAddress: nodes[driftGatewayAddress],
+		NdfDriftUnknown:        nodes[driftUnknown],
+	}
+	if report.Policy != NdfReconcileReportOnly {
+		t.Errorf("Unexpected policy: %s", report.Policy)
+	}
+	if len(report.Discrepancies) != len(expected) {
+		t.Errorf("Expected %d discrepancies, received: %+v", len(expected),
+			report.Discrepancies)
+	}
+	for _, d := range report.Discrepancies {
+		if nid, exists := expected[d.Class]; !exists || !nid.Cmp(d.NodeID) {
+			t.Errorf("Unexpected discrepancy: %+v", d)
+		}
+		if d.Fixed {
+			t.Errorf("Discrepancy fixed under the report only policy: %+v", d)
+		}
+	}
+	if d := findDiscrepancy(report, NdfDriftNodeAddress); d == nil ||
+		d.NdfValue != "10.0.0.2:11420" || d.DbValue != "10.0.0.1:11420" {
+		t.Errorf("Unexpected address values reported: %+v", d)
+	}
+	if n := len(impl.State.GetUnprunedNdf().Nodes); n != numDriftNodes-1 {
+		t.Errorf("NDF changed under the report only policy: %d nodes", n)
+	}
+
+	// The report can be retrieved afterward
+	permHost, err := connect.NewHost(&id.Permissioning, "", nil,
+		connect.GetDefaultHostParams())
+	if err != nil {
+		t.Fatalf("Failed to create host: %+v", err)
+	}
+	auth := &connect.Auth{IsAuthenticated: true, Sender: permHost}
+	retrieved, err := impl.GetNdfReconciliationReport(auth)
+	if err != nil {
+		t.Fatalf("Failed to get report: %+v", err)
+	}
+	if retrieved != report {
+		t.Errorf("Retrieved report is not the last one.")
+	}
+	if _, err = impl.ReconcileNdf(&connect.Auth{}); err == nil {
+		t.Errorf("Unauthenticated reconciliation was permitted.")
+	}
+}
+
+// Tests that the DB wins policy fixes the NDF to match Storage.
+func TestRegistrationImpl_reconcileNdf_DbWins(t *testing.T) {
+	impl, nodes := newDriftTestImpl(t, NdfReconcileDbWins)
+	report, err := impl.reconcileNdf(time.Now())
+	if err != nil {
+		t.Fatalf("Failed to reconcile NDF: %+v", err)
+	}
+	for _, d := range report.Discrepancies {
+		if !d.Fixed {
+			t.Errorf("Discrepancy not fixed: %+v", d)
+		}
+	}
+
+	def := impl.State.GetUnprunedNdf()
+	expected := []*id.ID{nodes[driftNone], nodes[driftMissing],
+		nodes[driftNodeAddress], nodes[driftGatewayAddress]}
+	if len(def.Nodes) != len(expected) || len(def.Gateways) != len(expected) {
+		t.Fatalf("Unexpected NDF nodes after reconciliation: %+v", def.Nodes)
+	}
+	for i, nid := range expected {
+		if ndfID, _ := id.Unmarshal(def.Nodes[i].ID); !ndfID.Cmp(nid) {
+			t.Errorf("Node %d of the NDF is %s, expected %s", i, ndfID, nid)
+		}
+		if def.Nodes[i].Address != "10.0.0.1:11420" ||
+			def.Gateways[i].Address != "10.0.0.1:22840" {
+			t.Errorf("Addresses of node %s not fixed: %s, %s", nid,
+				def.Nodes[i].Address, def.Gateways[i].Address)
+		}
+	}
+
+	// Reconciled NDF is published and a second pass finds nothing
+	if impl.State.GetFullNdf() == nil ||
+		len(impl.State.GetFullNdf().Get().Nodes) != len(expected) {
+		t.Errorf("Reconciled NDF not published.")
+	}
+	report, err = impl.reconcileNdf(time.Now())
+	if err != nil {
+		t.Fatalf("Failed to reconcile NDF: %+v", err)
+	}
+	if len(report.Discrepancies) != 0 {
+		t.Errorf("Drift left after reconciliation: %+v", report.Discrepancies)
+	}
+}
+
+// Tests that the NDF wins policy writes the NDF addresses to Storage.
+func TestRegistrationImpl_reconcileNdf_NdfWins(t *testing.T) {
+	impl, nodes := newDriftTestImpl(t, NdfReconcileNdfWins)
+	report, err := impl.reconcileNdf(time.Now())
+	if err != nil {
+		t.Fatalf("Failed to reconcile NDF: %+v", err)
+	}
+	for _, d := range report.Discrepancies {
+		isAddress := d.Class == NdfDriftNodeAddress ||
+			d.Class == NdfDriftGatewayAddress
+		if d.Fixed != isAddress {
+			t.Errorf("Unexpected fix of discrepancy: %+v", d)
+		}
+	}
+
+	dbNode, err := storage.PermissioningDb.GetNodeById(nodes[driftNodeAddress])
+	if err != nil {
+		t.Fatalf("Failed to get node: %+v", err)
+	}
+	if dbNode.ServerAddress != "10.0.0.2:11420" {
+		t.Errorf("Node address not written to Storage: %s",
+			dbNode.ServerAddress)
+	}
+	dbNode, err = storage.PermissioningDb.GetNodeById(nodes[driftGatewayAddress])
+	if err != nil {
+		t.Fatalf("Failed to get node: %+v", err)
+	}
+	if dbNode.GatewayAddress != "10.0.0.2:22840" {
+		t.Errorf("Gateway address not written to Storage: %s",
+			dbNode.GatewayAddress)
+	}
+
+	if _, err = parseNdfReconcilePolicy("both"); err == nil {
+		t.Errorf("No error for an unknown policy.")
+	}
+}
+
+// Returns the first discrepancy of the class in the report
+func findDiscrepancy(report *NdfReconciliationReport, class NdfDriftClass) *NdfDiscrepancy {
+	for i := range report.Discrepancies {
+		if report.Discrepancies[i].Class == class {
+			return &report.Discrepancies[i]
+		}
+	}
+	return nil
+}
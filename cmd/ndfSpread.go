@@ -0,0 +1,90 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package cmd
+
+// Contains the spreading of the delivery of non-critical NDF changes to nodes
+// over a window, so that nodes do not all download a new NDF at once
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"github.com/pkg/errors"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/xx_network/primitives/id"
+	"time"
+)
+
+// Kinds of NDF change delivered to every node at once when none are given
+var defaultNdfSpreadCritical = []string{storage.NdfChangeNodesAdded,
+	storage.NdfChangeNodesRemoved, storage.NdfChangeOther}
+
+// ndfSpread decides when each node is delivered a newly published NDF
+type ndfSpread struct {
+	// Window the delivery of non-critical changes is spread over
+	window time.Duration
+
+	// Kinds of change delivered at once
+	critical map[string]bool
+}
+
+// newNdfSpread returns the ndfSpread spreading non-critical NDF changes over
+// the window, or nil if the window is zero. Changes of the critical kinds,
+// or of the default kinds if none are given, are never spread.
+func newNdfSpread(window time.Duration, critical []string) (*ndfSpread, error) {
+	if window < 0 {
+		return nil, errors.Errorf("NDF spread window of %s is negative",
+			window)
+	} else if window == 0 {
+		return nil, nil
+	}
+
+	if len(critical) == 0 {
+		critical = defaultNdfSpreadCritical
+	}
+	s := &ndfSpread{window: window, critical: make(map[string]bool)}
+	for _, kind := range critical {
+		known := false
+		for _, k := range storage.NdfChangeKinds {
+			known = known || k == kind
+		}
+		if !known {
+			return nil, errors.Errorf("Unknown kind of NDF change %q, "+
+				"expected one of %v", kind, storage.NdfChangeKinds)
+		}
+		s.critical[kind] = true
+	}
+	return s, nil
+}
+
+// withhold returns true if the NDF in the snapshot is not yet delivered to
+// the node holding the NDF with the given hash. Only a non-critical change
+// from the NDF published just before is withheld, until the node's delay into
+// the window has passed since the change was published. Nodes holding an
+// older NDF are delivered the new one at once.
+func (s *ndfSpread) withhold(nid *id.ID, snapshot *storage.PollSnapshot,
+	theirHash []byte, now time.Time) bool {
+	if s == nil || len(snapshot.PreviousFullNdfHash) == 0 ||
+		!bytes.Equal(theirHash, snapshot.PreviousFullNdfHash) {
+		return false
+	}
+	for _, kind := range snapshot.FullNdfChangeKinds {
+		if s.critical[kind] {
+			return false
+		}
+	}
+	return now.Before(snapshot.FullNdfPublished.Add(s.delay(nid)))
+}
+
+// delay returns how far into the window the node is delivered non-critical
+// changes. It is derived from the node's ID, so it is the same for every
+// change and spread evenly across nodes.
+func (s *ndfSpread) delay(nid *id.ID) time.Duration {
+	h := sha256.Sum256(nid.Marshal())
+	return time.Duration(binary.BigEndian.Uint64(h[:8]) % uint64(s.window))
+}
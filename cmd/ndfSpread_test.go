@@ -0,0 +1,135 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package cmd
+
+import (
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/xx_network/primitives/id"
+	"testing"
+	"time"
+)
+
+// Tests that the delivery delays of nodes are the same for every change and
+// smear the delivery of a change evenly over the window.
+func TestNdfSpread_delay(t *testing.T) {
+	window := 10 * time.Second
+	s, err := newNdfSpread(window, nil)
+	if err != nil {
+		t.Fatalf("Failed to create NDF spread: %+v", err)
+	}
+
+	const numNodes, numBuckets = 2000, 10
+	buckets := make([]int, numBuckets)
+	for i := 0; i < numNodes; i++ {
+		nid := id.NewIdFromUInt(uint64(i), id.Node, t)
+		delay := s.delay(nid)
+		if delay < 0 || delay >= window {
+			t.Fatalf("Delay %s of node %s is outside the window.", delay, nid)
+		}
+		if s.delay(nid) != delay {
+			t.Errorf("Delay of node %s is not deterministic.", nid)
+		}
+		buckets[delay*numBuckets/window]++
+	}
+
+	// Each tenth of the window is delivered to about a tenth of the nodes
+	expected := numNodes / numBuckets
+	for i, count := range buckets {
+		if count < expected*3/4 || count > expected*5/4 {
+			t.Errorf("%d nodes are delivered the change in tenth %d of the "+
+				"window, expected about %d: %v", count, i, expected, buckets)
+		}
+	}
+}
+
+// Tests that a non-critical change is withheld from each node holding the NDF
+// it was made to until the node's delay has passed, and that critical changes
+// and nodes holding older NDFs are not withheld.
+func TestNdfSpread_withhold(t *testing.T) {
+	window := time.Minute
+	published := time.Now()
+	oldHash, previousHash := []byte("old"), []byte("previous")
+	snapshot := func(kinds ...string) *storage.PollSnapshot {
+		return &storage.PollSnapshot{
+			FullNdfHash:         []byte("current"),
+			PreviousFullNdfHash: previousHash,
+			FullNdfPublished:    published,
+			FullNdfChangeKinds:  kinds,
+		}
+	}
+	s, err := newNdfSpread(window, nil)
+	if err != nil {
+		t.Fatalf("Failed to create NDF spread: %+v", err)
+	}
+
+	// Find a node delayed into the window
+	var nid *id.ID
+	for i := uint64(0); nid == nil; i++ {
+		if candidate := id.NewIdFromUInt(i, id.Node, t); s.delay(candidate) > time.Second {
+			nid = candidate
+		}
+	}
+	delivered := published.Add(s.delay(nid))
+
+	address := snapshot(storage.NdfChangeAddress)
+	if !s.withhold(nid, address, previousHash, delivered.Add(-time.Nanosecond)) {
+		t.Errorf("Address change was delivered before the node's delay.")
+	}
+	if s.withhold(nid, address, previousHash, delivered) {
+		t.Errorf("Address change was withheld after the node's delay.")
+	}
+	if s.withhold(nid, address, oldHash, published) {
+		t.Errorf("Change was withheld from a node holding an older NDF.")
+	}
+	if s.withhold(nid, &storage.PollSnapshot{}, previousHash, published) {
+		t.Errorf("Change was withheld without a recorded change.")
+	}
+
+	// Critical changes, alone or alongside non-critical ones, bypass the
+	// spread
+	critical := [][]string{{storage.NdfChangeNodesRemoved},
+		{storage.NdfChangeNodesAdded}, {storage.NdfChangeOther},
+		{storage.NdfChangeAddress, storage.NdfChangeOther}}
+	for _, kinds := range critical {
+		if s.withhold(nid, snapshot(kinds...), previousHash, published) {
+			t.Errorf("Critical change %v was withheld.", kinds)
+		}
+	}
+
+	// The kinds of change which are critical are configurable
+	s, err = newNdfSpread(window, []string{storage.NdfChangeAddress})
+	if err != nil {
+		t.Fatalf("Failed to create NDF spread: %+v", err)
+	}
+	if s.withhold(nid, address, previousHash, published) {
+		t.Errorf("Change configured as critical was withheld.")
+	}
+	if !s.withhold(nid, snapshot(storage.NdfChangeNodesRemoved), previousHash,
+		published) {
+		t.Errorf("Change configured as non-critical was delivered at once.")
+	}
+
+	var disabled *ndfSpread
+	if disabled.withhold(nid, address, previousHash, published) {
+		t.Errorf("Change was withheld without a spread.")
+	}
+}
+
+// Tests that newNdfSpread disables the spread without a window and rejects
+// negative windows and unknown kinds of change.
+func TestNewNdfSpread(t *testing.T) {
+	if s, err := newNdfSpread(0, nil); s != nil || err != nil {
+		t.Errorf("Spread without a window was not disabled: %v, %+v", s, err)
+	}
+	if _, err := newNdfSpread(-time.Second, nil); err == nil {
+		t.Errorf("Negative window was accepted.")
+	}
+	if _, err := newNdfSpread(time.Second, []string{"ban"}); err == nil {
+		t.Errorf("Unknown kind of change was accepted.")
+	}
+}
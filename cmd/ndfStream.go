@@ -0,0 +1,25 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Contains the entry point for gateways subscribing to the NDF stream
+
+package cmd
+
+import (
+	"github.com/pkg/errors"
+	"gitlab.com/elixxir/registration/storage"
+)
+
+// SubscribeNdfStream subscribes a gateway to the NDF and round updates pushed
+// as they happen, starting with the current NDF. Returns an error if the NDF
+// stream is disabled.
+func (m *RegistrationImpl) SubscribeNdfStream() (*storage.NdfSubscription, error) {
+	if m.params.ndfStreamBuffer == 0 {
+		return nil, errors.New("NDF stream is disabled")
+	}
+	return m.State.SubscribeNdfStream(m.params.ndfStreamBuffer)
+}
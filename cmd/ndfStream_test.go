@@ -0,0 +1,33 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package cmd
+
+import (
+	"gitlab.com/elixxir/registration/storage"
+	"testing"
+)
+
+// Tests that gateways can only subscribe to the NDF stream when it is enabled.
+func TestRegistrationImpl_SubscribeNdfStream(t *testing.T) {
+	impl := &RegistrationImpl{State: &storage.NetworkState{}, params: &Params{}}
+
+	if _, err := impl.SubscribeNdfStream(); err == nil {
+		t.Errorf("Subscribed while the NDF stream is disabled.")
+	}
+
+	impl.params.ndfStreamBuffer = 4
+	sub, err := impl.SubscribeNdfStream()
+	if err != nil {
+		t.Fatalf("Failed to subscribe: %+v", err)
+	}
+	if cap(sub.Updates()) != 4 {
+		t.Errorf("Unexpected subscription buffer.\nexpected: %d\nreceived: %d",
+			4, cap(sub.Updates()))
+	}
+	sub.Unsubscribe()
+}
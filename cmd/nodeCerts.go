@@ -0,0 +1,104 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Contains the startup validation of the certificates stored for nodes
+
+package cmd
+
+import (
+	"fmt"
+	jww "github.com/spf13/jwalterweatherman"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/xx_network/crypto/tls"
+	"time"
+)
+
+// certStatus describes the result of validating a stored certificate
+type certStatus uint8
+
+const (
+	certValid certStatus = iota
+	certMalformed
+	certExpired
+	certNotYetValid
+)
+
+// String returns a human-readable description of the certStatus
+func (s certStatus) String() string {
+	switch s {
+	case certValid:
+		return "valid"
+	case certMalformed:
+		return "malformed"
+	case certExpired:
+		return "expired"
+	case certNotYetValid:
+		return "not yet valid"
+	default:
+		return fmt.Sprintf("UNKNOWN STATUS: %d", s)
+	}
+}
+
+// checkCertificate parses the PEM encoded certificate and checks that it is
+// valid at the given time.
+func checkCertificate(certPem string, now time.Time) certStatus {
+	cert, err := tls.LoadCertificate(certPem)
+	if err != nil || cert == nil {
+		return certMalformed
+	}
+	if now.After(cert.NotAfter) {
+		return certExpired
+	}
+	if now.Before(cert.NotBefore) {
+		return certNotYetValid
+	}
+	return certValid
+}
+
+// validateNodeCertificates checks the node and gateway certificates stored for
+// the node at the given time. The gateway certificate is not checked for
+// gateway-less nodes. Returns a description of each invalid certificate, or
+// nothing if both are valid.
+func validateNodeCertificates(n *storage.Node, now time.Time,
+	gatewayless bool) []string {
+	var problems []string
+	if status := checkCertificate(n.NodeCertificate, now); status != certValid {
+		problems = append(problems, "node certificate is "+status.String())
+	}
+	if gatewayless {
+		return problems
+	}
+	if status := checkCertificate(n.GatewayCertificate, now); status != certValid {
+		problems = append(problems, "gateway certificate is "+status.String())
+	}
+	return problems
+}
+
+// hasValidCertificates validates the certificates of the node being loaded on
+// startup when validateNodeCerts is set, logging any invalid ones. Returns
+// false if the node is to be excluded from the NDF because of them.
+func (m *RegistrationImpl) hasValidCertificates(n *storage.Node) bool {
+	if !m.params.validateNodeCerts {
+		return true
+	}
+
+	problems := validateNodeCertificates(n, time.Now(),
+		m.isGatewayless(n.GatewayCertificate))
+	if len(problems) == 0 {
+		return true
+	}
+
+	if m.params.excludeInvalidCertNodes {
+		jww.ERROR.Printf("Excluding node with registration code %s from "+
+			"the NDF until its certificates are fixed: %v", n.Code, problems)
+		return false
+	}
+
+	jww.WARN.Printf("Node with registration code %s has invalid "+
+		"certificates: %v", n.Code, problems)
+	return true
+}
@@ -0,0 +1,181 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package cmd
+
+import (
+	"fmt"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/elixxir/registration/storage/node"
+	"gitlab.com/elixxir/registration/testkeys"
+	"gitlab.com/xx_network/crypto/tls"
+	"gitlab.com/xx_network/primitives/id"
+	"gitlab.com/xx_network/primitives/utils"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// Tests that certificates are classified as valid, expired, not yet valid or
+// malformed relative to the given time
+func TestCheckCertificate(t *testing.T) {
+	crt, err := utils.ReadFile(testkeys.GetCACertPath())
+	if err != nil {
+		t.Fatalf("Failed to read certificate: %+v", err)
+	}
+	cert, err := tls.LoadCertificate(string(crt))
+	if err != nil {
+		t.Fatalf("Failed to load certificate: %+v", err)
+	}
+
+	testCases := []struct {
+		cert     string
+		now      time.Time
+		expected certStatus
+	}{
+		{string(crt), cert.NotBefore.Add(time.Hour), certValid},
+		{string(crt), cert.NotAfter.Add(time.Hour), certExpired},
+		{string(crt), cert.NotBefore.Add(-time.Hour), certNotYetValid},
+		{"not a certificate", cert.NotBefore.Add(time.Hour), certMalformed},
+		{"", cert.NotBefore.Add(time.Hour), certMalformed},
+	}
+
+	for i, tc := range testCases {
+		status := checkCertificate(tc.cert, tc.now)
+		if status != tc.expected {
+			t.Errorf("Unexpected status for certificate (%d)."+
+				"\nexpected: %s\nreceived: %s", i, tc.expected, status)
+		}
+	}
+}
+
+// Tests that each invalid certificate of a node is described
+func TestValidateNodeCertificates(t *testing.T) {
+	crt, err := utils.ReadFile(testkeys.GetCACertPath())
+	if err != nil {
+		t.Fatalf("Failed to read certificate: %+v", err)
+	}
+	cert, err := tls.LoadCertificate(string(crt))
+	if err != nil {
+		t.Fatalf("Failed to load certificate: %+v", err)
+	}
+	now := cert.NotBefore.Add(time.Hour)
+
+	n := &storage.Node{NodeCertificate: string(crt), GatewayCertificate: string(crt)}
+	if problems := validateNodeCertificates(n, now, false); len(problems) != 0 {
+		t.Errorf("Valid certificates reported as invalid: %v", problems)
+	}
+
+	n.GatewayCertificate = "malformed"
+	expected := []string{"gateway certificate is malformed"}
+	if problems := validateNodeCertificates(n, now, false); !reflect.DeepEqual(problems, expected) {
+		t.Errorf("Unexpected problems.\nexpected: %v\nreceived: %v",
+			expected, problems)
+	}
+
+	expected = []string{"node certificate is expired",
+		"gateway certificate is malformed"}
+	problems := validateNodeCertificates(n, cert.NotAfter.Add(time.Hour), false)
+	if !reflect.DeepEqual(problems, expected) {
+		t.Errorf("Unexpected problems.\nexpected: %v\nreceived: %v",
+			expected, problems)
+	}
+
+	// The missing gateway certificate of a gateway-less node is not a problem
+	n.GatewayCertificate = ""
+	if problems = validateNodeCertificates(n, now, true); len(problems) != 0 {
+		t.Errorf("Gateway-less node reported invalid: %v", problems)
+	}
+}
+
+// Tests that nodes with invalid certificates are only excluded from the NDF
+// on startup when configured to be
+func TestLoadAllRegisteredNodes_ValidateNodeCerts(t *testing.T) {
+	validCrt, err := utils.ReadFile(testkeys.GetUdbCertPath())
+	if err != nil {
+		t.Fatalf("Failed to read certificate: %+v", err)
+	}
+	expiredCrt, err := utils.ReadFile(testkeys.GetCACertPath())
+	if err != nil {
+		t.Fatalf("Failed to read certificate: %+v", err)
+	}
+	certs := map[string]string{
+		"AAAA": string(validCrt),
+		"BBBB": string(expiredCrt),
+		"CCCC": "malformed",
+	}
+
+	for i, exclude := range []bool{false, true} {
+		storage.PermissioningDb, _, err = storage.NewDatabase("", "", "", "", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = storage.PermissioningDb.InsertEphemeralLength(
+			&storage.EphemeralLength{Length: 8, Timestamp: time.Now()})
+		if err != nil {
+			t.Fatalf("Failed to insert ephemeral length: %+v", err)
+		}
+		storage.PopulateNodeRegistrationCodes([]node.Info{
+			{RegCode: "AAAA", Order: "CR"},
+			{RegCode: "BBBB", Order: "GB"},
+			{RegCode: "CCCC", Order: "BF"},
+		})
+
+		nodeIds := make(map[string]*id.ID, len(certs))
+		for j, code := range []string{"AAAA", "BBBB", "CCCC"} {
+			nodeIds[code] = id.NewIdFromUInt(uint64(j), id.Node, t)
+			err = storage.PermissioningDb.RegisterNode(nodeIds[code],
+				[]byte(code), code, "0.0.0.0", certs[code], "0.0.0.0",
+				certs[code], storage.SelfServeRegistration)
+			if err != nil {
+				t.Fatalf("Failed to register node: %+v", err)
+			}
+		}
+
+		params := Params{
+			Address:                 fmt.Sprintf("0.0.0.0:%d", 5960+i),
+			CertPath:                testkeys.GetCACertPath(),
+			KeyPath:                 testkeys.GetCAKeyPath(),
+			FullNdfOutputPath:       testkeys.GetNDFPath(),
+			udbCertPath:             testkeys.GetUdbCertPath(),
+			NsCertPath:              testkeys.GetUdbCertPath(),
+			WhitelistedIdsPath:      testkeys.GetPreApprovedPath(),
+			disableNDFPruning:       true,
+			disableGeoBinning:       true,
+			validateNodeCerts:       true,
+			excludeInvalidCertNodes: exclude,
+		}
+		impl, err := StartRegistration(params)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = impl.LoadAllRegisteredNodes()
+		if err != nil {
+			t.Fatalf("LoadAllRegisteredNodes returned an error: %+v", err)
+		}
+
+		for code, nid := range nodeIds {
+			expected := !exclude || code == "AAAA"
+			if loaded := impl.State.GetNodeMap().GetNode(nid) != nil; loaded != expected {
+				t.Errorf("Node %s loaded: %t, expected: %t (exclude: %t)",
+					code, loaded, expected, exclude)
+			}
+
+			inNdf := false
+			for _, ndfNode := range impl.State.GetUnprunedNdf().Nodes {
+				if reflect.DeepEqual(ndfNode.ID, nid.Marshal()) {
+					inNdf = true
+				}
+			}
+			if inNdf != expected {
+				t.Errorf("Node %s in NDF: %t, expected: %t (exclude: %t)",
+					code, inNdf, expected, exclude)
+			}
+		}
+	}
+}
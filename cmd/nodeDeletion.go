@@ -0,0 +1,102 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Contains the administrative function for deleting a node's registration when
+// its operator leaves the network
+
+package cmd
+
+import (
+	"github.com/pkg/errors"
+	jww "github.com/spf13/jwalterweatherman"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/xx_network/comms/connect"
+	"gitlab.com/xx_network/primitives/id"
+)
+
+// DeleteNodeRegistration deletes the node registered with the code from
+// Storage, along with its application, and evicts it from the node map, the
+// scheduling pool, the comms host map and the NDF. If purgeMetrics is set its
+// metrics are deleted too, otherwise they are kept without the node. Nodes
+// still in a round cannot be deleted.
+func (m *RegistrationImpl) DeleteNodeRegistration(auth *connect.Auth,
+	code string, purgeMetrics bool) error {
+	if err := checkAdminAuth(auth); err != nil {
+		return err
+	}
+
+	nodeInfo, err := storage.PermissioningDb.GetNode(code)
+	if err != nil {
+		return errors.Errorf("No node is registered with code %s: %+v",
+			code, err)
+	}
+
+	// Nodes which never registered are only in Storage
+	var nid *id.ID
+	if nodeInfo.Id != nil {
+		nid, err = id.Unmarshal(nodeInfo.Id)
+		if err != nil {
+			return errors.Errorf("Could not unmarshal stored ID of node with "+
+				"registration code %s: %+v", code, err)
+		}
+	}
+	if nid != nil {
+		if ns := m.State.GetNodeMap().GetNode(nid); ns != nil {
+			// Wait for any update from a poll in progress to be handled, so
+			// that the scheduler does not handle one once the node is gone
+			ns.GetPollingLock().Lock()
+			defer ns.GetPollingLock().Unlock()
+			if inRound, r := ns.GetCurrentRound(); inRound {
+				return errors.Errorf("Node %s with registration code %s "+
+					"cannot be deleted while in round %d", nid, code,
+					r.GetRoundID())
+			}
+		}
+	}
+
+	err = storage.PermissioningDb.DeleteNodeRegistration(code, purgeMetrics)
+	if err != nil {
+		return errors.WithMessagef(err, "Failed to delete node with "+
+			"registration code %s", code)
+	}
+	jww.INFO.Printf("Deleted node %s with registration code %s, purging "+
+		"metrics: %t", nid, code, purgeMetrics)
+	if nid == nil {
+		return nil
+	}
+
+	m.Comms.RemoveHost(nid)
+	if m.State.GetNodeMap().GetNode(nid) != nil {
+		// The scheduler drops removed nodes from the pool
+		if err = m.State.GetNodeMap().RemoveNode(nid); err != nil {
+			return errors.WithMessage(err, "Could not remove node from "+
+				"state tracker")
+		}
+	}
+	return m.removeDeletedNdfNode(nid)
+}
+
+// removeDeletedNdfNode removes the deleted node and its gateway from the NDF.
+func (m *RegistrationImpl) removeDeletedNdfNode(nid *id.ID) error {
+	m.registrationLock.Lock()
+	defer m.registrationLock.Unlock()
+	m.State.InternalNdfLock.Lock()
+	defer m.State.InternalNdfLock.Unlock()
+
+	delete(m.registrationTimes, *nid)
+	m.State.RemovePrunedNode(nid)
+
+	def := m.State.GetUnprunedNdf()
+	update, err := removeNdfNode(def, nid)
+	if err != nil {
+		return err
+	}
+	if update {
+		m.State.UpdateInternalNdf(def)
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package cmd
+
+import (
+	"bytes"
+	"gitlab.com/elixxir/primitives/states"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/elixxir/registration/storage/node"
+	"gitlab.com/elixxir/registration/storage/round"
+	"gitlab.com/xx_network/comms/connect"
+	"gitlab.com/xx_network/primitives/id"
+	"testing"
+	"time"
+)
+
+// Starts registration with the nodes of the codes registered, returning their
+// IDs
+func setupNodeDeletionTest(t *testing.T, codes ...string) (*RegistrationImpl, []*id.ID) {
+	var err error
+	var dc func() error
+	storage.PermissioningDb, dc, err = storage.NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	t.Cleanup(func() { _ = dc() })
+	err = storage.PermissioningDb.InsertEphemeralLength(
+		&storage.EphemeralLength{Length: 8, Timestamp: time.Now()})
+	if err != nil {
+		t.Errorf("Failed to insert ephemeral length into database: %+v", err)
+	}
+	infos := make([]node.Info, len(codes))
+	for i, code := range codes {
+		infos[i] = node.Info{RegCode: code, Order: "US"}
+	}
+	storage.PopulateNodeRegistrationCodes(infos)
+
+	impl, err := StartRegistration(testParams)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	t.Cleanup(impl.Comms.Shutdown)
+
+	nids := make([]*id.ID, len(codes))
+	for i, code := range codes {
+		salt := bytes.Repeat([]byte{byte(i + 1)}, 32)
+		err = impl.RegisterNode(salt, nodeAddr, string(nodeCert), nodeAddr,
+			string(nodeCert), code)
+		if err != nil {
+			t.Fatalf("Failed to register node: %+v", err)
+		}
+		info, err := storage.PermissioningDb.GetNode(code)
+		if err != nil {
+			t.Fatalf("Failed to get node: %+v", err)
+		}
+		if nids[i], err = id.Unmarshal(info.Id); err != nil {
+			t.Fatalf("Failed to unmarshal node ID: %+v", err)
+		}
+	}
+	return impl, nids
+}
+
+// Tests that DeleteNodeRegistration() removes the node from Storage, the host
+// map, the node map and the NDF, leaving other nodes in place, and that nodes
+// in a round are not deleted.
+func TestRegistrationImpl_DeleteNodeRegistration(t *testing.T) {
+	dblck.Lock()
+	defer dblck.Unlock()
+	impl, nids := setupNodeDeletionTest(t, "AAAA", "BBBB")
+	deleted, kept := nids[0], nids[1]
+	ns := impl.State.GetNodeMap().GetNode(deleted)
+
+	permHost, err := connect.NewHost(&id.Permissioning, "", nil,
+		connect.GetDefaultHostParams())
+	if err != nil {
+		t.Fatalf("Failed to create host: %+v", err)
+	}
+	auth := &connect.Auth{IsAuthenticated: true, Sender: permHost}
+
+	if err = impl.DeleteNodeRegistration(&connect.Auth{Sender: permHost},
+		"AAAA", true); err == nil {
+		t.Errorf("Unauthenticated deletion was allowed.")
+	}
+	if err = impl.DeleteNodeRegistration(auth, "CCCC", true); err == nil {
+		t.Errorf("Deleted a node with an unknown code.")
+	}
+	if err = impl.DeleteNodeRegistration(auth, "AAAA", true); err != nil {
+		t.Fatalf("Failed to delete node: %+v", err)
+	}
+
+	if _, err = storage.PermissioningDb.GetNode("AAAA"); err == nil {
+		t.Errorf("Node remains in Storage.")
+	}
+	if _, exists := impl.Comms.GetHost(deleted); exists {
+		t.Errorf("Node remains in the host map.")
+	}
+	if impl.State.GetNodeMap().GetNode(deleted) != nil || !ns.IsRemoved() {
+		t.Errorf("Node was not removed from the node map.")
+	}
+	if impl.State.IsPruned(deleted) {
+		t.Errorf("Node remains in the prune list.")
+	}
+	def := impl.State.GetUnprunedNdf()
+	if len(def.Nodes) != 1 || len(def.Gateways) != 1 ||
+		!bytes.Equal(def.Nodes[0].ID, kept.Bytes()) {
+		t.Errorf("Only the remaining node should be in the NDF: %+v", def.Nodes)
+	}
+
+	// The remaining node cannot be deleted while it is in a round
+	r := round.NewState_Testing(7, states.PRECOMPUTING,
+		connect.NewCircuit([]*id.ID{kept}), t)
+	if err = impl.State.GetNodeMap().GetNode(kept).SetRound(r); err != nil {
+		t.Fatalf("Failed to set round: %+v", err)
+	}
+	if err = impl.DeleteNodeRegistration(auth, "BBBB", false); err == nil {
+		t.Errorf("Node in a round was deleted.")
+	}
+
+	if _, err = storage.PermissioningDb.GetNode("BBBB"); err != nil {
+		t.Errorf("Remaining node was deleted: %+v", err)
+	}
+	if impl.State.GetNodeMap().GetNode(kept) == nil {
+		t.Errorf("Remaining node was removed from the node map.")
+	}
+	if _, exists := impl.Comms.GetHost(kept); !exists {
+		t.Errorf("Remaining node was removed from the host map.")
+	}
+}
@@ -0,0 +1,57 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Contains the administrative functions for managing node groups
+
+package cmd
+
+import (
+	"github.com/pkg/errors"
+	jww "github.com/spf13/jwalterweatherman"
+	"gitlab.com/xx_network/comms/connect"
+	"gitlab.com/xx_network/primitives/id"
+)
+
+// DefineNodeGroup creates or replaces the named node group. Every member must
+// be a node registered with permissioning. When the scheduler is configured
+// with the group's name, every team is drawn from its members.
+func (m *RegistrationImpl) DefineNodeGroup(auth *connect.Auth, name string,
+	members []*id.ID) error {
+	if err := checkAdminAuth(auth); err != nil {
+		return err
+	}
+
+	for _, nid := range members {
+		if m.State.GetNodeMap().GetNode(nid) == nil {
+			return errors.Errorf("Cannot add node %s to node group %s: "+
+				"node is not registered", nid, name)
+		}
+	}
+
+	err := m.State.SetNodeGroup(name, members)
+	if err != nil {
+		return err
+	}
+
+	jww.INFO.Printf("Defined node group %s with %d members", name, len(members))
+	return nil
+}
+
+// RemoveNodeGroup deletes the named node group.
+func (m *RegistrationImpl) RemoveNodeGroup(auth *connect.Auth, name string) error {
+	if err := checkAdminAuth(auth); err != nil {
+		return err
+	}
+
+	err := m.State.DeleteNodeGroup(name)
+	if err != nil {
+		return err
+	}
+
+	jww.INFO.Printf("Removed node group %s", name)
+	return nil
+}
@@ -0,0 +1,90 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package cmd
+
+import (
+	"crypto/rand"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/xx_network/comms/connect"
+	"gitlab.com/xx_network/crypto/signature/rsa"
+	"gitlab.com/xx_network/primitives/id"
+	"gitlab.com/xx_network/primitives/region"
+	"testing"
+)
+
+// Tests that DefineNodeGroup only accepts registered nodes, that groups can be
+// removed and that only the permissioning server can manage them.
+func TestRegistrationImpl_DefineNodeGroup(t *testing.T) {
+	var err error
+	storage.PermissioningDb, _, err = storage.NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	privKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	testState, err := storage.NewState(privKey, 8, "", "", region.GetCountryBins())
+	if err != nil {
+		t.Fatalf("Failed to create test state: %v", err)
+	}
+	impl := &RegistrationImpl{State: testState}
+
+	registered := id.NewIdFromString("registered", id.Node, t)
+	err = testState.GetNodeMap().AddNode(registered, "US", "", "", 0)
+	if err != nil {
+		t.Fatalf("Couldn't add node: %v", err)
+	}
+	unregistered := id.NewIdFromString("unregistered", id.Node, t)
+
+	permHost, err := connect.NewHost(&id.Permissioning, "", nil,
+		connect.GetDefaultHostParams())
+	if err != nil {
+		t.Fatalf("Failed to create host: %+v", err)
+	}
+	nodeHost, err := connect.NewHost(registered, "", nil,
+		connect.GetDefaultHostParams())
+	if err != nil {
+		t.Fatalf("Failed to create host: %+v", err)
+	}
+	nodeAuth := &connect.Auth{IsAuthenticated: true, Sender: nodeHost}
+	auth := &connect.Auth{IsAuthenticated: true, Sender: permHost}
+
+	if err = impl.DefineNodeGroup(nodeAuth, "group", []*id.ID{registered}); err == nil {
+		t.Errorf("Node was able to define a node group.")
+	}
+	if _, exists := testState.GetNodeGroup("group"); exists {
+		t.Errorf("Unauthenticated group should not be stored.")
+	}
+
+	err = impl.DefineNodeGroup(auth, "group", []*id.ID{registered, unregistered})
+	if err == nil {
+		t.Errorf("DefineNodeGroup() should reject unregistered nodes.")
+	}
+	if _, exists := testState.GetNodeGroup("group"); exists {
+		t.Errorf("Rejected group should not be stored.")
+	}
+
+	err = impl.DefineNodeGroup(auth, "group", []*id.ID{registered})
+	if err != nil {
+		t.Fatalf("DefineNodeGroup() returned an error: %+v", err)
+	}
+	members, exists := testState.GetNodeGroup("group")
+	if !exists || len(members) != 1 || !members[0].Cmp(registered) {
+		t.Errorf("Node group not defined correctly: %v", members)
+	}
+
+	if err = impl.RemoveNodeGroup(nodeAuth, "group"); err == nil {
+		t.Errorf("Node was able to remove a node group.")
+	}
+	err = impl.RemoveNodeGroup(auth, "group")
+	if err != nil {
+		t.Fatalf("RemoveNodeGroup() returned an error: %+v", err)
+	}
+	if _, exists = testState.GetNodeGroup("group"); exists {
+		t.Errorf("Node group not removed.")
+	}
+}
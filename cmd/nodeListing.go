@@ -0,0 +1,105 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Contains the administrative function for listing the registered nodes a page
+// at a time
+
+package cmd
+
+import (
+	"github.com/pkg/errors"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/xx_network/comms/connect"
+	"gitlab.com/xx_network/primitives/region"
+	"sort"
+)
+
+// Largest page of nodes ListNodes returns
+const maxNodeListingPageSize = 1000
+
+// NodeListing is a page of the nodes matching a filter.
+type NodeListing struct {
+	// Nodes in the page, ordered by registration code
+	Nodes []*storage.Node
+	// Number of nodes matching the filter across all pages
+	Total int
+	// Registration code the next page starts after, empty on the last page
+	NextCode string
+}
+
+// ListNodes returns up to limit of the registered nodes matching the filter
+// whose registration code comes after afterCode. If geographic bins are given,
+// only nodes in the countries of one of them are listed, in addition to the
+// filter.
+func (m *RegistrationImpl) ListNodes(auth *connect.Auth,
+	filter storage.NodeFilter, bins []region.GeoBin, afterCode string,
+	limit int) (*NodeListing, error) {
+	if err := checkAdminAuth(auth); err != nil {
+		return nil, err
+	}
+	if limit <= 0 || limit > maxNodeListingPageSize {
+		return nil, errors.Errorf("Cannot list nodes: page size %d is not "+
+			"between 1 and %d", limit, maxNodeListingPageSize)
+	}
+
+	if len(bins) > 0 {
+		countries := binCountries(m.State.GetGeoBins(), bins)
+		if len(filter.Sequences) > 0 {
+			countries = intersectStrings(filter.Sequences, countries)
+		}
+		// No country is in the bins, so no node can match
+		if len(countries) == 0 {
+			return &NodeListing{}, nil
+		}
+		filter.Sequences = countries
+	}
+
+	nodes, total, err := storage.PermissioningDb.GetNodesPage(filter,
+		afterCode, limit)
+	if err != nil {
+		return nil, errors.WithMessage(err, "Failed to list nodes")
+	}
+
+	listing := &NodeListing{Nodes: nodes, Total: total}
+	if len(nodes) == limit {
+		listing.NextCode = nodes[len(nodes)-1].Code
+	}
+	return listing, nil
+}
+
+// binCountries returns the countries which are in one of the bins, sorted.
+func binCountries(geoBins map[string]region.GeoBin, bins []region.GeoBin) []string {
+	wanted := make(map[region.GeoBin]bool, len(bins))
+	for _, bin := range bins {
+		wanted[bin] = true
+	}
+
+	var countries []string
+	for country, bin := range geoBins {
+		if wanted[bin] {
+			countries = append(countries, country)
+		}
+	}
+	sort.Strings(countries)
+	return countries
+}
+
+// intersectStrings returns the strings in a which are also in b.
+func intersectStrings(a, b []string) []string {
+	inB := make(map[string]bool, len(b))
+	for _, s := range b {
+		inB[s] = true
+	}
+
+	var both []string
+	for _, s := range a {
+		if inB[s] {
+			both = append(both, s)
+		}
+	}
+	return both
+}
@@ -0,0 +1,122 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package cmd
+
+import (
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/xx_network/comms/connect"
+	"gitlab.com/xx_network/primitives/id"
+	"gitlab.com/xx_network/primitives/region"
+	"reflect"
+	"testing"
+)
+
+// Tests that only the permissioning server can list nodes, that pages link
+// through NextCode and that nodes can be filtered by geographic bin.
+func TestRegistrationImpl_ListNodes(t *testing.T) {
+	var err error
+	var dc func() error
+	storage.PermissioningDb, dc, err = storage.NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("Failed to create database: %+v", err)
+	}
+	t.Cleanup(func() { _ = dc() })
+	state, err := storage.NewState(getTestKey(), 8, "", "", region.GetCountryBins())
+	if err != nil {
+		t.Fatalf("Unable to create state: %+v", err)
+	}
+	impl := &RegistrationImpl{State: state}
+
+	sequences := map[string]string{"AAAA": "US", "BBBB": "GB", "CCCC": "FR",
+		"DDDD": "US", "EEEE": "GB"}
+	appId := uint64(1)
+	for _, code := range []string{"AAAA", "BBBB", "CCCC", "DDDD", "EEEE"} {
+		err = storage.PermissioningDb.InsertApplication(
+			&storage.Application{Id: appId},
+			&storage.Node{Code: code, Sequence: sequences[code], ApplicationId: appId})
+		if err != nil {
+			t.Fatalf("Failed to insert node: %+v", err)
+		}
+		appId++
+	}
+
+	permHost, err := connect.NewHost(&id.Permissioning, "", nil,
+		connect.GetDefaultHostParams())
+	if err != nil {
+		t.Fatalf("Failed to create host: %+v", err)
+	}
+	nodeHost, err := connect.NewHost(id.NewIdFromString("node", id.Node, t), "",
+		nil, connect.GetDefaultHostParams())
+	if err != nil {
+		t.Fatalf("Failed to create host: %+v", err)
+	}
+
+	_, err = impl.ListNodes(&connect.Auth{IsAuthenticated: true, Sender: nodeHost},
+		storage.NodeFilter{}, nil, "", 10)
+	if err == nil {
+		t.Errorf("Node was able to list nodes.")
+	}
+
+	auth := &connect.Auth{IsAuthenticated: true, Sender: permHost}
+	var pages [][]string
+	afterCode := ""
+	for {
+		listing, err := impl.ListNodes(auth, storage.NodeFilter{}, nil, afterCode, 2)
+		if err != nil {
+			t.Fatalf("ListNodes() returned an error: %+v", err)
+		}
+		if listing.Total != len(sequences) {
+			t.Errorf("Unexpected total.\nexpected: %d\nreceived: %d",
+				len(sequences), listing.Total)
+		}
+		var page []string
+		for _, n := range listing.Nodes {
+			page = append(page, n.Code)
+		}
+		pages = append(pages, page)
+		if listing.NextCode == "" {
+			break
+		}
+		afterCode = listing.NextCode
+	}
+	expected := [][]string{{"AAAA", "BBBB"}, {"CCCC", "DDDD"}, {"EEEE"}}
+	if !reflect.DeepEqual(expected, pages) {
+		t.Errorf("Unexpected pages.\nexpected: %v\nreceived: %v", expected, pages)
+	}
+
+	listing, err := impl.ListNodes(auth, storage.NodeFilter{},
+		[]region.GeoBin{region.WesternEurope}, "", 10)
+	if err != nil {
+		t.Fatalf("ListNodes() returned an error: %+v", err)
+	}
+	var codes []string
+	for _, n := range listing.Nodes {
+		codes = append(codes, n.Code)
+	}
+	if inBin := []string{"BBBB", "CCCC", "EEEE"}; !reflect.DeepEqual(inBin, codes) ||
+		listing.Total != len(inBin) {
+		t.Errorf("Unexpected nodes in bin.\nexpected: %v\nreceived: %v (total %d)",
+			inBin, codes, listing.Total)
+	}
+
+	// Countries outside the bins are excluded even if named by the filter
+	listing, err = impl.ListNodes(auth, storage.NodeFilter{Sequences: []string{"US"}},
+		[]region.GeoBin{region.WesternEurope}, "", 10)
+	if err != nil {
+		t.Fatalf("ListNodes() returned an error: %+v", err)
+	}
+	if len(listing.Nodes) != 0 || listing.Total != 0 {
+		t.Errorf("Nodes outside the bin were listed: %+v", listing.Nodes)
+	}
+
+	for _, limit := range []int{0, maxNodeListingPageSize + 1} {
+		if _, err = impl.ListNodes(auth, storage.NodeFilter{}, nil, "", limit); err == nil {
+			t.Errorf("Expected an error for page size %d.", limit)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Contains the periodic deletion of node metrics past their retention
+
+package cmd
+
+import (
+	jww "github.com/spf13/jwalterweatherman"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/xx_network/comms/connect"
+	"sync"
+	"time"
+)
+
+// NodeMetricPruneStats counts the node metrics deleted past their retention.
+type NodeMetricPruneStats struct {
+	// Number of times node metrics were pruned and the node metrics deleted
+	// across them
+	Runs    uint64
+	Deleted uint64
+
+	// Time of the last pruning and the node metrics it deleted
+	LastRun     time.Time
+	LastDeleted int64
+}
+
+// nodeMetricPrunes tracks the pruning of node metrics.
+type nodeMetricPrunes struct {
+	stats NodeMetricPruneStats
+	mux   sync.Mutex
+}
+
+// PruneNodeMetrics deletes the node metrics which ended more than the node
+// metric retention ago every interval, until signaled to quit.
+func PruneNodeMetrics(impl *RegistrationImpl, quitChan chan struct{}, interval time.Duration) {
+	jww.DEBUG.Printf("Beginning pruning of node metrics older than %s "+
+		"every %s...", impl.params.nodeMetricRetention, interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-quitChan:
+			return
+		case now := <-ticker.C:
+			impl.pruneNodeMetrics(now)
+		}
+	}
+}
+
+// pruneNodeMetrics deletes the node metrics which ended more than the node
+// metric retention before now and records the number deleted.
+func (m *RegistrationImpl) pruneNodeMetrics(now time.Time) {
+	cutoff := now.Add(-m.params.nodeMetricRetention)
+	deleted, err := storage.PermissioningDb.DeleteNodeMetricsBefore(cutoff)
+	if err != nil {
+		jww.ERROR.Printf("Failed to prune node metrics ending before %s "+
+			"after deleting %d: %+v", cutoff, deleted, err)
+	} else {
+		jww.INFO.Printf("Pruned %d node metrics ending before %s", deleted,
+			cutoff)
+	}
+
+	m.nodeMetricPrunes.mux.Lock()
+	defer m.nodeMetricPrunes.mux.Unlock()
+	m.nodeMetricPrunes.stats.Runs++
+	m.nodeMetricPrunes.stats.Deleted += uint64(deleted)
+	m.nodeMetricPrunes.stats.LastRun = now
+	m.nodeMetricPrunes.stats.LastDeleted = deleted
+}
+
+// GetNodeMetricPruneStats returns the number of node metrics deleted past the
+// node metric retention.
+func (m *RegistrationImpl) GetNodeMetricPruneStats(auth *connect.Auth) (NodeMetricPruneStats, error) {
+	if err := checkAdminAuth(auth); err != nil {
+		return NodeMetricPruneStats{}, err
+	}
+
+	m.nodeMetricPrunes.mux.Lock()
+	defer m.nodeMetricPrunes.mux.Unlock()
+	return m.nodeMetricPrunes.stats, nil
+}
@@ -0,0 +1,83 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package cmd
+
+import (
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/xx_network/comms/connect"
+	"gitlab.com/xx_network/primitives/id"
+	"testing"
+	"time"
+)
+
+// Tests that the node metric pruner deletes only the node metrics past the
+// retention and reports the number it deleted.
+func TestPruneNodeMetrics(t *testing.T) {
+	var err error
+	var dc func() error
+	storage.PermissioningDb, dc, err = storage.NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("Failed to create database: %+v", err)
+	}
+	t.Cleanup(func() { _ = dc() })
+	impl := &RegistrationImpl{params: &Params{nodeMetricRetention: 24 * time.Hour}}
+
+	nid := id.NewIdFromString("node", id.Node, t)
+	err = storage.PermissioningDb.InsertApplication(&storage.Application{Id: 1},
+		&storage.Node{Code: "code", Id: nid.Marshal(), ApplicationId: 1})
+	if err != nil {
+		t.Fatalf("Failed to insert node: %+v", err)
+	}
+	now := time.Now()
+	for i, end := range []time.Time{now.Add(-72 * time.Hour),
+		now.Add(-48 * time.Hour), now.Add(-25 * time.Hour),
+		now.Add(-time.Hour), now} {
+		err = storage.PermissioningDb.InsertNodeMetric(&storage.NodeMetric{
+			NodeId: nid.Marshal(), StartTime: end.Add(-time.Minute),
+			EndTime: end, NumPings: uint64(i)})
+		if err != nil {
+			t.Fatalf("Failed to insert node metric: %+v", err)
+		}
+	}
+
+	permHost, err := connect.NewHost(&id.Permissioning, "", nil,
+		connect.GetDefaultHostParams())
+	if err != nil {
+		t.Fatalf("Failed to create host: %+v", err)
+	}
+	if _, err = impl.GetNodeMetricPruneStats(&connect.Auth{Sender: permHost}); err == nil {
+		t.Errorf("Unauthenticated sender was able to get node metric pruning stats.")
+	}
+	auth := &connect.Auth{IsAuthenticated: true, Sender: permHost}
+
+	quit := make(chan struct{})
+	go PruneNodeMetrics(impl, quit, 10*time.Millisecond)
+	var stats NodeMetricPruneStats
+	for i := 0; stats.Runs < 2; i++ {
+		if i == 500 {
+			t.Fatalf("Node metrics were not pruned: %+v", stats)
+		}
+		time.Sleep(10 * time.Millisecond)
+		if stats, err = impl.GetNodeMetricPruneStats(auth); err != nil {
+			t.Fatalf("GetNodeMetricPruneStats() returned an error: %+v", err)
+		}
+	}
+	quit <- struct{}{}
+
+	if stats.Deleted != 3 || stats.LastDeleted != 0 || stats.LastRun.Before(now) {
+		t.Errorf("Unexpected node metric pruning stats: %+v", stats)
+	}
+	remaining, err := storage.PermissioningDb.DeleteNodeMetricsBefore(
+		now.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to delete node metrics: %+v", err)
+	}
+	if remaining != 2 {
+		t.Errorf("%d node metrics remained, expected %d.", remaining, 2)
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/pkg/errors"
 	jww "github.com/spf13/jwalterweatherman"
 	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/xx_network/comms/connect"
 	"gitlab.com/xx_network/primitives/id"
 	"gitlab.com/xx_network/primitives/utils"
 	"time"
@@ -30,6 +31,9 @@ func TrackNodeMetrics(impl *RegistrationImpl, quitChan chan struct{}, nodeMetric
 	nodeTicker := time.NewTicker(nodeMetricInterval)
 	onlyScheduleActive := impl.params.onlyScheduleActive
 
+	// Find the uptimes of nodes from the metrics stored before starting
+	impl.updateNodeUptimes(time.Now())
+
 	for {
 		// Store the metric start time
 		startTime := time.Now()
@@ -103,26 +107,33 @@ func TrackNodeMetrics(impl *RegistrationImpl, quitChan chan struct{}, nodeMetric
 					NodeId:    nodeState.GetID().Bytes(),
 					StartTime: startTime,
 					EndTime:   currentTime,
-					NumPings:  nodeState.GetAndResetNumPolls(),
+					NumPings:  nodeState.AdvancePollInterval(),
 				}
 
 				// set the node to prune if it has not contacted
-				if metric.NumPings == 0 || (onlyScheduleActive && !active[*nodeState.GetID()]) {
-					toPrune[*nodeState.GetID()] = false
+				nid := nodeState.GetID()
+				if metric.NumPings == 0 || (onlyScheduleActive && !active[*nid]) {
+					toPrune[*nid] = false
 				} else {
 					nodeState.SetLastActive()
-					toUpdate = append(toUpdate, nodeState.GetID())
+					toUpdate = append(toUpdate, nid)
+				}
+				clamped := nodeState.ClampLastActive(currentTime,
+					impl.params.lastActiveFutureTolerance)
+				if !clamped.IsZero() {
+					jww.WARN.Printf("Node %s was last active at %s, more "+
+						"than %s in the future, clamping it to %s",
+						nid, clamped,
+						impl.params.lastActiveFutureTolerance, currentTime)
 				}
-				if time.Since(nodeState.GetLastActive()) > impl.params.pruneRetentionLimit {
-					toPrune[*nodeState.GetID()] = true
+				snapshot := nodeState.Snapshot()
+				if time.Since(snapshot.LastActive) > impl.params.pruneRetentionLimit {
+					toPrune[*nid] = true
 				}
 
 				// Store the NodeMetric
-				if !onlyScheduleActive || active[*nodeState.GetID()] {
-					err = storage.PermissioningDb.InsertNodeMetric(metric)
-					if err != nil {
-						jww.FATAL.Panicf("Unable to store node metric: %+v", err)
-					}
+				if !onlyScheduleActive || active[*nid] {
+					impl.storeNodeMetric(metric)
 				}
 			}
 
@@ -132,6 +143,8 @@ func TrackNodeMetrics(impl *RegistrationImpl, quitChan chan struct{}, nodeMetric
 				jww.ERROR.Printf("TrackNodeMetrics: Could not update last active: %v", err)
 			}
 
+			impl.updateNodeUptimes(time.Now())
+
 			if !impl.params.disableNDFPruning {
 				// add disabled nodes to the prune list
 				jww.DEBUG.Printf("Setting %d pruned nodes", len(toPrune))
@@ -149,6 +162,10 @@ func TrackNodeMetrics(impl *RegistrationImpl, quitChan chan struct{}, nodeMetric
 				jww.ERROR.Printf("Failed to trigger NDF output: %+v", err)
 			}
 
+			// Update the safe update watermark published with the
+			// earliest rounds
+			impl.checkGatewayAcks()
+
 			paramsCopy := impl.schedulingParams.SafeCopy()
 
 			clientCutoff := impl.params.messageRetentionLimit + paramsCopy.RealtimeTimeout
@@ -183,6 +200,48 @@ func TrackNodeMetrics(impl *RegistrationImpl, quitChan chan struct{}, nodeMetric
 	}
 }
 
+// storeNodeMetric writes the NodeMetric to storage. While the database cannot
+// be reached, the metric is buffered by storage until it can be. If the write
+// otherwise fails after all retries, the metric is dropped and the error is
+// logged.
+func (m *RegistrationImpl) storeNodeMetric(metric *storage.NodeMetric) {
+	err := storage.PermissioningDb.InsertNodeMetricWithRetry(metric)
+	if err != nil {
+		jww.ERROR.Printf("Dropping node metric which could not be "+
+			"stored: %+v", err)
+	}
+}
+
+// updateNodeUptimes sets the uptime of each node to the fraction of its node
+// metrics within the uptime window in which it polled. Nodes without node
+// metrics in the window have no uptime. Uptimes which cannot be found are left
+// as they were.
+func (m *RegistrationImpl) updateNodeUptimes(now time.Time) {
+	uptimes, err := storage.PermissioningDb.GetNodeUptimes(
+		now.Add(-m.params.uptimeWindow))
+	if err != nil {
+		jww.ERROR.Printf("Failed to get node uptimes: %+v", err)
+		return
+	}
+	for _, n := range m.State.GetNodeMap().GetNodeStates() {
+		n.SetUptime(uptimes[*n.GetID()])
+	}
+}
+
+// GetNodePollHistory returns the number of polls the node made in each of the
+// last node metric intervals, oldest first.
+func (m *RegistrationImpl) GetNodePollHistory(auth *connect.Auth,
+	nodeId *id.ID) ([]uint64, error) {
+	if err := checkAdminAuth(auth); err != nil {
+		return nil, err
+	}
+	n := m.State.GetNodeMap().GetNode(nodeId)
+	if n == nil {
+		return nil, errors.Errorf("Node %s is not registered", nodeId)
+	}
+	return n.GetPollHistory(), nil
+}
+
 // GetActiveNodeIDs gets the active nodes from the database and returns the list
 // of unmarshalled node IDs.
 func GetActiveNodeIDs() (map[id.ID]bool, error) {
@@ -13,9 +13,11 @@ import (
 	"gitlab.com/elixxir/registration/scheduling"
 	"gitlab.com/elixxir/registration/storage"
 	"gitlab.com/elixxir/registration/storage/node"
+	"gitlab.com/xx_network/comms/connect"
 	"gitlab.com/xx_network/primitives/id"
 	"gitlab.com/xx_network/primitives/ndf"
 	"gitlab.com/xx_network/primitives/region"
+	"reflect"
 	"strconv"
 	"sync"
 	"sync/atomic"
@@ -102,7 +104,8 @@ func TestTrackNodeMetrics(t *testing.T) {
 		if err != nil {
 			t.Fatalf("Failed to insert application: %+v", err)
 		}
-		err = storage.PermissioningDb.RegisterNode(nodeIds[i-1], nil, regCode, "", "", "", "")
+		err = storage.PermissioningDb.RegisterNode(nodeIds[i-1], nil, regCode, "", "", "", "",
+			storage.SelfServeRegistration)
 		if err != nil {
 			t.Fatalf("Failed to prepopulate database: %+v", err)
 		}
@@ -172,8 +175,188 @@ func TestTrackNodeMetrics(t *testing.T) {
 		}
 	}
 
+	// The polls of the active node were counted in the first interval only
+	history := activeNode.GetPollHistory()
+	if len(history) < 2 || history[0] != 25 || history[1] != 0 {
+		t.Errorf("Unexpected poll history of the active node: %v", history)
+	}
+}
+
+// Tests that a node which stopped polling with a last active time far in the
+// future is clamped to the current time and pruned once the retention limit
+// passes, rather than remaining in the NDF indefinitely.
+func TestTrackNodeMetrics_FutureLastActive(t *testing.T) {
+	kill := make(chan struct{})
+	defer quit(kill)
+	interval := 200 * time.Millisecond
+
+	var err error
+	storage.PermissioningDb, _, err = storage.NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	storage.PermissioningDb.SetMetricRetry(1, time.Millisecond)
+	state, err := storage.NewState(getTestKey(), 8, "", "", region.GetCountryBins())
+	if err != nil {
+		t.Fatalf("Unable to create state: %+v", err)
+	}
+
+	nid := id.NewIdFromString("future", id.Node, t)
+	if err = state.GetNodeMap().AddNode(nid, "", "", "", 0); err != nil {
+		t.Fatalf("Failed to add node to state: %+v", err)
+	}
+	future := time.Now().Add(240 * time.Hour)
+	n := state.GetNodeMap().GetNode(nid)
+	n.SetLastActiveTesting(future, t)
+	state.UpdateInternalNdf(&ndf.NetworkDefinition{
+		Nodes:    []ndf.Node{{ID: nid.Bytes()}},
+		Gateways: []ndf.Gateway{{ID: nid.Bytes()}},
+	})
+
+	impl := &RegistrationImpl{
+		params: &Params{pruneRetentionLimit: interval,
+			lastActiveFutureTolerance: time.Minute},
+		State:                state,
+		earliestRoundTracker: atomic.Value{},
+		schedulingParams: &scheduling.SafeParams{
+			Params: &scheduling.Params{}},
+	}
+	go TrackNodeMetrics(impl, kill, interval)
+
+	time.Sleep(interval * 4)
+	if !n.GetLastActive().Before(future) {
+		t.Errorf("Future last active time was not clamped: %s",
+			n.GetLastActive())
+	}
+	if nodes := state.GetFullNdf().Get().Nodes; len(nodes) != 0 {
+		t.Errorf("Node with a future last active time was not pruned "+
+			"from the NDF: %+v", nodes)
+	}
+}
+
+// Tests that storeNodeMetric drops a metric which cannot be stored rather
+// than panicking.
+func TestRegistrationImpl_storeNodeMetric_Failure(t *testing.T) {
+	var err error
+	storage.PermissioningDb, _, err = storage.NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	storage.PermissioningDb.SetMetricRetry(1, time.Millisecond)
+
+	// The node is not in storage, so the metric write breaks a foreign key
+	metric := &storage.NodeMetric{
+		NodeId:    id.NewIdFromString("unknown", id.Node, t).Bytes(),
+		StartTime: time.Now(),
+		EndTime:   time.Now(),
+		NumPings:  10,
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			t.Errorf("storeNodeMetric() panicked on a failed write: %v", r)
+		}
+	}()
+	impl := &RegistrationImpl{params: &Params{}}
+	impl.storeNodeMetric(metric)
 }
 
 func quit(kill chan struct{}) {
 	kill <- struct{}{}
 }
+
+// Tests that the poll history of a node can be queried by the permissioning
+// server only, and that it holds the counts of the advanced intervals.
+func TestRegistrationImpl_GetNodePollHistory(t *testing.T) {
+	impl, ns := setupObservedAddressTest(false, t)
+	ns.SetNumPollsTesting(3, t)
+	ns.AdvancePollInterval()
+	ns.SetNumPollsTesting(5, t)
+	ns.AdvancePollInterval()
+
+	permHost, err := connect.NewHost(&id.Permissioning, "", nil,
+		connect.GetDefaultHostParams())
+	if err != nil {
+		t.Fatalf("Failed to create host: %+v", err)
+	}
+	nodeHost, err := connect.NewHost(ns.GetID(), "", nil,
+		connect.GetDefaultHostParams())
+	if err != nil {
+		t.Fatalf("Failed to create host: %+v", err)
+	}
+
+	_, err = impl.GetNodePollHistory(
+		&connect.Auth{IsAuthenticated: true, Sender: nodeHost}, ns.GetID())
+	if err == nil {
+		t.Errorf("Node was able to get its poll history.")
+	}
+	auth := &connect.Auth{IsAuthenticated: true, Sender: permHost}
+	history, err := impl.GetNodePollHistory(auth, ns.GetID())
+	if err != nil {
+		t.Fatalf("GetNodePollHistory() returned an error: %+v", err)
+	}
+	if !reflect.DeepEqual(history, []uint64{3, 5}) {
+		t.Errorf("Unexpected poll history: %v", history)
+	}
+	_, err = impl.GetNodePollHistory(auth, id.NewIdFromString("missing", id.Node, t))
+	if err == nil {
+		t.Errorf("Expected an error for an unknown node.")
+	}
+}
+
+// Tests that the uptime of each node is set from its node metrics within the
+// uptime window, and that nodes without any have no uptime.
+func TestRegistrationImpl_updateNodeUptimes(t *testing.T) {
+	var err error
+	var dc func() error
+	storage.PermissioningDb, dc, err = storage.NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("Failed to create database: %+v", err)
+	}
+	t.Cleanup(func() { _ = dc() })
+	state, err := storage.NewState(getTestKey(), 8, "", "", region.GetCountryBins())
+	if err != nil {
+		t.Fatalf("Failed to create state: %+v", err)
+	}
+	impl := &RegistrationImpl{State: state,
+		params: &Params{uptimeWindow: 24 * time.Hour}}
+
+	now := time.Now()
+	reliable := id.NewIdFromUInt(1, id.Node, t)
+	unreliable := id.NewIdFromUInt(2, id.Node, t)
+	unmonitored := id.NewIdFromUInt(3, id.Node, t)
+	for i, nid := range []*id.ID{reliable, unreliable, unmonitored} {
+		err = storage.PermissioningDb.InsertApplication(
+			&storage.Application{Id: uint64(i + 1)},
+			&storage.Node{Code: nid.String(), Id: nid.Marshal(),
+				ApplicationId: uint64(i + 1)})
+		if err != nil {
+			t.Fatalf("Failed to insert node: %+v", err)
+		}
+		if err = state.GetNodeMap().AddNode(nid, "US", "", "", 0); err != nil {
+			t.Fatalf("Failed to add node: %+v", err)
+		}
+	}
+	state.GetNodeMap().GetNode(unmonitored).SetUptime(1)
+	for j := 0; j < 4; j++ {
+		end := now.Add(-time.Duration(j) * time.Hour)
+		for nid, numPings := range map[*id.ID]uint64{reliable: 1,
+			unreliable: uint64(j % 2)} {
+			err = storage.PermissioningDb.InsertNodeMetric(&storage.NodeMetric{
+				NodeId: nid.Marshal(), StartTime: end.Add(-time.Hour),
+				EndTime: end, NumPings: numPings})
+			if err != nil {
+				t.Fatalf("Failed to insert node metric: %+v", err)
+			}
+		}
+	}
+
+	impl.updateNodeUptimes(now)
+	for nid, uptime := range map[*id.ID]float64{reliable: 1, unreliable: 0.5,
+		unmonitored: 0} {
+		if n := state.GetNodeMap().GetNode(nid); n.GetUptime() != uptime {
+			t.Errorf("Node %s has uptime %f, expected %f.", nid,
+				n.GetUptime(), uptime)
+		}
+	}
+}
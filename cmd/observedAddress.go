@@ -0,0 +1,135 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Contains the cross-check between the address a node advertises and the
+// address its polls are observed to come from
+
+package cmd
+
+import (
+	"github.com/pkg/errors"
+	jww "github.com/spf13/jwalterweatherman"
+	"gitlab.com/elixxir/registration/storage/node"
+	"gitlab.com/xx_network/comms/connect"
+	"gitlab.com/xx_network/primitives/id"
+	"time"
+)
+
+// checkObservedAddress records the IP address the node's poll was received
+// from on the node state and warns when it starts to differ from the address
+// the node advertises, which usually indicates a NAT misconfiguration.
+func checkObservedAddress(n *node.State, observedIp string) {
+	_, wasMismatched := n.GetObservedAddress()
+	if n.SetObservedAddress(observedIp) && !wasMismatched {
+		observed, _ := n.GetObservedAddress()
+		jww.WARN.Printf("Node %s advertises address %s but polls from %s, "+
+			"check its NAT configuration", n.GetID(), n.GetNodeAddresses(),
+			observed)
+	}
+}
+
+// checkPollSourceThreshold returns an error if the poll source threshold is
+// negative or could never be exceeded, as only MaxPollSources are kept for
+// each node.
+func checkPollSourceThreshold(threshold int) error {
+	if threshold < 0 || threshold >= node.MaxPollSources {
+		return errors.Errorf("Poll source threshold of %d must be between "+
+			"0 and %d", threshold, node.MaxPollSources-1)
+	}
+	return nil
+}
+
+// checkPollSources records the IP address the node's poll was received from
+// among the distinct sources of its polls over the poll source window. When
+// pollSourceThreshold is set, warns once when more distinct sources than it
+// were seen, as polls alternating between sources usually indicate a
+// misconfigured load balancer in front of the node.
+func (m *RegistrationImpl) checkPollSources(n *node.State, observedIp string, now time.Time) {
+	count := n.RecordPollSource(observedIp, now, m.params.pollSourceWindow)
+	if m.params.pollSourceThreshold == 0 {
+		return
+	}
+
+	anomalous := count > m.params.pollSourceThreshold
+	if !n.SetPollSourceAnomaly(anomalous) {
+		return
+	}
+	if anomalous {
+		jww.WARN.Printf("Node %s polled from %d distinct addresses within %s, "+
+			"check for a misconfigured load balancer: %v", n.GetID(), count,
+			m.params.pollSourceWindow, n.GetPollSources())
+	} else {
+		jww.INFO.Printf("Node %s polls from %d distinct addresses within "+
+			"%s again", n.GetID(), count, m.params.pollSourceWindow)
+	}
+}
+
+// GetNodePollSources returns the distinct addresses the node's polls were
+// received from over the poll source window, least recently seen first.
+func (m *RegistrationImpl) GetNodePollSources(auth *connect.Auth, nodeId *id.ID) ([]node.PollSource, error) {
+	if err := checkAdminAuth(auth); err != nil {
+		return nil, err
+	}
+	n := m.State.GetNodeMap().GetNode(nodeId)
+	if n == nil {
+		return nil, errors.Errorf("Node %s is not registered", nodeId)
+	}
+	return n.GetPollSources(), nil
+}
+
+// resolveNodeAddress determines whether the node can be contacted. If it is
+// not reachable at its advertised address, preferObservedAddress is set, and
+// its polls come from a different address which reachable reports as
+// contactable, then the observed address is substituted for the advertised
+// one in the NDF. Returns true if the node is reachable at either address and
+// the address it is reachable at.
+func (m *RegistrationImpl) resolveNodeAddress(n *node.State,
+	advertisedReachable bool, reachable func(address string) bool) (bool, string) {
+
+	if advertisedReachable {
+		return true, n.GetNodeAddresses()
+	}
+
+	// The polls of dual address nodes may come from either network, so their
+	// inter-node address is never replaced by the observed one
+	observed, mismatched := n.GetObservedAddress()
+	if !m.params.preferObservedAddress || !mismatched ||
+		n.GetPublicAddress() != "" || !reachable(observed) {
+		return false, ""
+	}
+
+	err := m.substituteNodeAddress(n, observed)
+	if err != nil {
+		jww.ERROR.Printf("Failed to substitute observed address %s for "+
+			"node %s: %+v", observed, n.GetID(), err)
+		return false, ""
+	}
+
+	jww.INFO.Printf("Node %s cannot be contacted at its advertised address "+
+		"%s, using its observed address %s in the NDF", n.GetID(),
+		n.GetNodeAddresses(), observed)
+	return true, observed
+}
+
+// substituteNodeAddress replaces the address of the node in the internal NDF
+// without changing the address it advertises.
+func (m *RegistrationImpl) substituteNodeAddress(n *node.State, address string) error {
+	m.State.InternalNdfLock.Lock()
+	defer m.State.InternalNdfLock.Unlock()
+
+	currentNDF := m.State.GetUnprunedNdf()
+	if currentNDF == nil {
+		return errors.New("Received nil ndf from m.State.GetUnprunedNdf")
+	}
+
+	if err := updateNdfNodeAddr(n.GetID(), address, currentNDF); err != nil {
+		return err
+	}
+
+	m.State.UpdateInternalNdf(currentNDF)
+	return nil
+}
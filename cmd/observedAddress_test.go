@@ -0,0 +1,211 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package cmd
+
+import (
+	"crypto/rand"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/elixxir/registration/storage/node"
+	"gitlab.com/xx_network/comms/connect"
+	"gitlab.com/xx_network/crypto/signature/rsa"
+	"gitlab.com/xx_network/primitives/id"
+	"gitlab.com/xx_network/primitives/ndf"
+	"gitlab.com/xx_network/primitives/region"
+	"testing"
+	"time"
+)
+
+const (
+	testAdvertisedAddr = "1.2.3.4:11420"
+	testObservedIp     = "5.6.7.8"
+	testObservedAddr   = "5.6.7.8:11420"
+)
+
+// Builds a RegistrationImpl with a single node, advertising
+// testAdvertisedAddr, in its state and internal NDF
+func setupObservedAddressTest(preferObserved bool, t *testing.T) (
+	*RegistrationImpl, *node.State) {
+	var err error
+	storage.PermissioningDb, _, err = storage.NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	privKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	testState, err := storage.NewState(privKey, 8, "", "", region.GetCountryBins())
+	if err != nil {
+		t.Fatalf("Failed to create test state: %v", err)
+	}
+
+	nid := id.NewIdFromString("node", id.Node, t)
+	err = testState.GetNodeMap().AddNode(nid, "US", testAdvertisedAddr, "", 0)
+	if err != nil {
+		t.Fatalf("Couldn't add node: %v", err)
+	}
+	testState.UpdateInternalNdf(&ndf.NetworkDefinition{
+		Nodes: []ndf.Node{{ID: nid.Marshal(), Address: testAdvertisedAddr}},
+	})
+
+	impl := &RegistrationImpl{
+		State:  testState,
+		params: &Params{preferObservedAddress: preferObserved},
+	}
+	return impl, testState.GetNodeMap().GetNode(nid)
+}
+
+// Tests that a node polling from the address it advertises is not flagged.
+func TestCheckObservedAddress_Match(t *testing.T) {
+	impl, n := setupObservedAddressTest(true, t)
+
+	checkObservedAddress(n, "1.2.3.4")
+	if _, mismatched := n.GetObservedAddress(); mismatched {
+		t.Errorf("Node polling from its advertised address was flagged.")
+	}
+
+	reachable, _ := impl.resolveNodeAddress(n, false,
+		func(string) bool { return true })
+	if reachable {
+		t.Errorf("Node without a mismatched address should not be " +
+			"reachable when its advertised address is not.")
+	}
+}
+
+// Tests that a mismatched node which is reachable at its advertised address is
+// flagged but keeps its advertised address in the NDF.
+func TestResolveNodeAddress_AdvertisedReachable(t *testing.T) {
+	impl, n := setupObservedAddressTest(true, t)
+
+	checkObservedAddress(n, testObservedIp)
+	observed, mismatched := n.GetObservedAddress()
+	if !mismatched || observed != testObservedAddr {
+		t.Fatalf("Mismatch not recorded: %s, %t", observed, mismatched)
+	}
+
+	reachable, address := impl.resolveNodeAddress(n, true,
+		func(string) bool {
+			t.Errorf("Observed address should not be checked.")
+			return true
+		})
+	if !reachable || address != testAdvertisedAddr {
+		t.Errorf("Unexpected result.\nexpected: %t, %s\nreceived: %t, %s",
+			true, testAdvertisedAddr, reachable, address)
+	}
+	if ndfAddr := impl.State.GetUnprunedNdf().Nodes[0].Address; ndfAddr != testAdvertisedAddr {
+		t.Errorf("NDF address changed.\nexpected: %s\nreceived: %s",
+			testAdvertisedAddr, ndfAddr)
+	}
+}
+
+// Tests that the observed address is substituted in the NDF when the
+// advertised address is unreachable and the observed one is reachable, only
+// when preferObservedAddress is set.
+func TestResolveNodeAddress_Fallback(t *testing.T) {
+	for _, prefer := range []bool{false, true} {
+		impl, n := setupObservedAddressTest(prefer, t)
+		checkObservedAddress(n, testObservedIp)
+
+		var checked string
+		reachable, address := impl.resolveNodeAddress(n, false,
+			func(address string) bool {
+				checked = address
+				return true
+			})
+
+		expectedAddr := testAdvertisedAddr
+		if prefer {
+			expectedAddr = testObservedAddr
+			if !reachable || address != testObservedAddr ||
+				checked != testObservedAddr {
+				t.Errorf("Observed address not used: %t, %s", reachable, address)
+			}
+		} else if reachable {
+			t.Errorf("Observed address used without preferObservedAddress.")
+		}
+
+		ndfAddr := impl.State.GetUnprunedNdf().Nodes[0].Address
+		if ndfAddr != expectedAddr {
+			t.Errorf("Unexpected NDF address (prefer %t).\nexpected: %s"+
+				"\nreceived: %s", prefer, expectedAddr, ndfAddr)
+		}
+		if n.GetNodeAddresses() != testAdvertisedAddr {
+			t.Errorf("Advertised address changed: %s", n.GetNodeAddresses())
+		}
+	}
+}
+
+// Tests that polls from more distinct sources than the threshold within the
+// window are flagged, that the flag clears once they fall out of the window
+// and that the sources can be queried by the permissioning server only.
+func TestRegistrationImpl_CheckPollSources(t *testing.T) {
+	impl, ns := setupObservedAddressTest(false, t)
+	impl.params.pollSourceWindow = 10 * time.Minute
+	impl.params.pollSourceThreshold = 2
+
+	now := time.Now()
+	for i, ip := range []string{"1.1.1.1", "2.2.2.2", "1.1.1.1", "2.2.2.2"} {
+		impl.checkPollSources(ns, ip, now.Add(time.Duration(i)*time.Second))
+	}
+	if ns.SetPollSourceAnomaly(false) {
+		t.Errorf("Node alternating between 2 sources was flagged.")
+	}
+
+	impl.checkPollSources(ns, "3.3.3.3", now.Add(5*time.Second))
+	if ns.SetPollSourceAnomaly(true) {
+		t.Errorf("Node polling from 3 sources was not flagged.")
+	}
+
+	// The earlier sources fall out of the window
+	impl.checkPollSources(ns, "3.3.3.3", now.Add(20*time.Minute))
+	if ns.SetPollSourceAnomaly(false) {
+		t.Errorf("Node polling from a single source remains flagged.")
+	}
+
+	permHost, err := connect.NewHost(&id.Permissioning, "", nil,
+		connect.GetDefaultHostParams())
+	if err != nil {
+		t.Fatalf("Failed to create host: %+v", err)
+	}
+	nodeHost, err := connect.NewHost(ns.GetID(), "", nil,
+		connect.GetDefaultHostParams())
+	if err != nil {
+		t.Fatalf("Failed to create host: %+v", err)
+	}
+
+	_, err = impl.GetNodePollSources(
+		&connect.Auth{IsAuthenticated: true, Sender: nodeHost}, ns.GetID())
+	if err == nil {
+		t.Errorf("Node was able to get its poll sources.")
+	}
+	auth := &connect.Auth{IsAuthenticated: true, Sender: permHost}
+	sources, err := impl.GetNodePollSources(auth, ns.GetID())
+	if err != nil {
+		t.Fatalf("GetNodePollSources() returned an error: %+v", err)
+	}
+	if len(sources) != 1 || sources[0].Address != "3.3.3.3" {
+		t.Errorf("Unexpected poll sources: %+v", sources)
+	}
+	if _, err = impl.GetNodePollSources(auth, id.NewIdFromString("missing", id.Node, t)); err == nil {
+		t.Errorf("Expected an error for an unknown node.")
+	}
+}
+
+// Tests that checkPollSourceThreshold rejects thresholds which are negative or
+// could never be exceeded with the sources kept for each node.
+func TestCheckPollSourceThreshold(t *testing.T) {
+	for _, threshold := range []int{0, 2, node.MaxPollSources - 1} {
+		if err := checkPollSourceThreshold(threshold); err != nil {
+			t.Errorf("Poll source threshold of %d was rejected: %+v",
+				threshold, err)
+		}
+	}
+	for _, threshold := range []int{-1, node.MaxPollSources, node.MaxPollSources + 1} {
+		if checkPollSourceThreshold(threshold) == nil {
+			t.Errorf("Poll source threshold of %d was accepted.", threshold)
+		}
+	}
+}
@@ -55,6 +55,222 @@ type Params struct {
 
 	geoIPDBFile string
 
+	// Regular expressions matching content redacted from round errors in
+	// addition to IP addresses and absolute paths
+	errorRedactionPatterns []string
+
+	// Fraction of the network an Application's avoid-list may exclude before
+	// a warning is raised
+	avoidListWarnFraction float64
+
+	// Use the address a node's polls come from in the NDF when the node
+	// cannot be contacted at the address it advertises
+	preferObservedAddress bool
+
+	// Validate the certificates of every node on startup, and optionally
+	// exclude nodes with invalid certificates from the NDF
+	validateNodeCerts       bool
+	excludeInvalidCertNodes bool
+
+	// Number of updates held for each NDF stream subscriber, 0 disables the
+	// NDF stream
+	ndfStreamBuffer int
+
+	// Number of published NDFs kept for rollback and the directory they are
+	// kept in, empty to keep them in memory only
+	ndfHistoryLimit int
+	ndfHistoryPath  string
+
+	// Number of NDF change records kept and whether they are persisted to
+	// Storage
+	ndfChangelogLimit   int
+	ndfChangelogPersist bool
+
+	// Whether each marshalled NDF must unmarshal back to its source before it
+	// is published
+	ndfRoundTripCheck bool
+
+	// How long NDF output file writes are held for so that NDFs published in
+	// quick succession are written once, zero to write each at once, and the
+	// most random time added to the window
+	ndfWriteDebounce time.Duration
+	ndfWriteJitter   time.Duration
+
+	// Whether a snapshot of each published NDF is kept in Storage and how
+	// long, zero to keep them forever
+	ndfSnapshots         bool
+	ndfSnapshotRetention time.Duration
+
+	// Window the delivery of non-critical NDF changes to nodes is spread
+	// over, zero to deliver every change at once, and the kinds of change
+	// which are never spread
+	ndfSpreadWindow   time.Duration
+	ndfSpreadCritical []string
+
+	// Number of failed node registrations from a source after which it is
+	// locked out, 0 to never lock out, and how long it is locked out for
+	registrationAttemptLimit uint
+	registrationLockout      time.Duration
+
+	// How far a node registration's timestamp may be from the current time
+	registrationTimestampTolerance time.Duration
+
+	// Whether the last known connectivity of nodes is kept in Storage and
+	// restored on startup, and the window the restored connectivity is
+	// checked again over
+	persistConnectivity       bool
+	connectivityReprobeWindow time.Duration
+
+	// Number of times a failed connectivity probe is retried, and the delay
+	// between attempts, before a port is concluded to have failed
+	connectivityProbeRetries    uint
+	connectivityProbeRetryDelay time.Duration
+
+	// Most connectivity checks run at once, zero for no limit, and the number
+	// of checks waiting for a worker past which checks are dropped
+	connectivityCheckWorkers   int
+	connectivityCheckQueueSize int
+
+	// Longest a node's polling lock is held once its update is handed to the
+	// scheduler, zero to hold it until the update is handled
+	pollingLockTimeout time.Duration
+
+	// Most polls handled at once, zero for no limit. Polls past the limit are
+	// told to retry later
+	maxConcurrentPolls uint
+
+	// Polls per second allowed from the nodes of each application together,
+	// zero for no limit, and the most polls allowed at once
+	appPollRate  float64
+	appPollBurst uint
+
+	// Fraction of polls whose raw messages are captured for debugging, and
+	// the number of captured polls kept
+	pollCaptureFraction float64
+	pollCaptureSize     int
+
+	// Whether the error payloads of polls reporting an ERROR activity are
+	// validated as soon as the activity is read
+	earlyErrorValidation bool
+
+	// Time waited between the nodes re-binned by the geo re-binning job
+	geoRebinInterval time.Duration
+
+	// Path of the JSON file mapping countries to the bins nodes are binned
+	// into, used instead of the built-in mapping
+	geoBinsPath string
+
+	// Least number, and least fraction of the registered nodes, which must be
+	// present for the network to report itself ready to clients, the fraction
+	// of them which may go missing before it reports itself degraded again,
+	// and whether the NDF is withheld from clients while it is degraded
+	readinessMinNodes        uint32
+	readinessMinNodeFraction float64
+	readinessHysteresis      float64
+	readinessStrict          bool
+
+	// Which side is fixed when the NDF and Storage disagree, one of "report",
+	// "db" or "ndf"
+	ndfReconcilePolicy string
+
+	// Window the distinct addresses a node polls from are counted over, and
+	// the number of them above which a warning is raised, 0 to never warn
+	pollSourceWindow    time.Duration
+	pollSourceThreshold int
+
+	// Whether a registered node may register again under a new key with its
+	// registration code, replacing its old ID
+	allowNodeKeyChange bool
+
+	// Whether nodes may register and run without a public gateway
+	allowGatewaylessNodes bool
+
+	// How far in the future a node's last active time may be before it is
+	// clamped to the current time, so it cannot evade pruning
+	lastActiveFutureTolerance time.Duration
+
+	// Whether banned nodes which poll are sent a ban notice with the reason
+	// instead of an error, the reason sent, and the minimum interval between
+	// answered polls from a banned node, 0 to answer every poll
+	bannedPollNotice   bool
+	bannedPollReason   string
+	bannedPollInterval time.Duration
+
+	// Whether the update ID nodes report having received must not be ahead of
+	// the newest update or go backwards
+	requireMonotonicUpdates bool
+
+	// Path the signed partial NDF in the legacy format is output to, empty to
+	// not output it
+	legacyNdfOutputPath string
+
+	// Window the round failure rate is measured over
+	roundHealthWindow time.Duration
+
+	// Time a node update may wait to be handled by the scheduler before a
+	// warning is logged
+	updateLagThreshold time.Duration
+
+	// Times since their last poll past which nodes are reported as stale and
+	// dead, zero for the defaults
+	nodeStaleThreshold time.Duration
+	nodeDeadThreshold  time.Duration
+
+	// Number of round updates a node's gateway acknowledgment may lag the
+	// newest update by before a warning is logged, zero to never warn
+	gatewayAckLagThreshold uint64
+
+	// Versions a banned node must report to be automatically unbanned, empty
+	// for none, and the number of consecutive connectivity probes it must
+	// pass to be, zero for none
+	autoUnbanServerVersion      string
+	autoUnbanGatewayVersion     string
+	autoUnbanConnectivityProbes uint
+
+	// Path to a list of node IDs banned on startup and on SIGHUP, and whether
+	// nodes removed from it are unbanned
+	blacklistPath  string
+	blacklistUnban bool
+
+	// URL alerts of nodes demoted to the diagnostic state are posted to,
+	// empty for none
+	diagnosticWebhook string
+
+	// CIDR ranges and domains the addresses nodes report must belong to, and
+	// whether they must be on the hosts the nodes are registered with
+	addressAllowlist           []string
+	requireRegisteredAddresses bool
+
+	// Whether nodes reporting a critical health error are kept out of
+	// scheduling until they report healthy
+	removeCriticalNodes bool
+
+	// How long nodes meeting the minimum versions in force before they were
+	// last raised are warned instead of rejected, zero to reject them at once
+	versionGraceWindow time.Duration
+	// Version floors in force before they were last raised and when their
+	// grace window closes, guarded by versionLock
+	versionGrace versionGrace
+
+	// Path to an experimental transition table used in place of the default
+	// node state machine, empty to use the default
+	experimentalTransitionTable string
+
+	// Rounds and base64 encoded node IDs whose logging is elevated to INFO
+	debugRounds []int
+	debugNodes  []string
+
+	// How long between storing node metrics
+	nodeMetricInterval time.Duration
+
+	// How long node metrics are kept for, zero to keep them forever, and how
+	// long between deleting the node metrics past it
+	nodeMetricRetention     time.Duration
+	nodeMetricPruneInterval time.Duration
+
+	// How far back the node metrics a node's uptime is found from go
+	uptimeWindow time.Duration
+
 	clientRegistrationAddress string
 
 	versionLock sync.RWMutex
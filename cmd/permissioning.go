@@ -23,8 +23,8 @@ import (
 	"gitlab.com/xx_network/crypto/xx"
 	"gitlab.com/xx_network/primitives/id"
 	"gitlab.com/xx_network/primitives/ndf"
-	"gitlab.com/xx_network/primitives/region"
 	"sync/atomic"
+	"time"
 )
 
 // Handle registration check attempt by node. We assume
@@ -65,9 +65,24 @@ func (m *RegistrationImpl) CheckNodeRegistration(msg *mixmessages.RegisteredNode
 var curNodeReg = uint32(0)
 var curNodeRegPtr = &curNodeReg
 
-// Handle registration attempt by a Node
+// Handle registration attempt by a Node. The comms endpoint does not pass
+// the replay protection proof, so registrations through it are not checked
+// against replays until it does; see RegisterNodeMessage.
 func (m *RegistrationImpl) RegisterNode(salt []byte, serverAddr, serverTlsCert, gatewayAddr,
 	gatewayTlsCert, registrationCode string) error {
+	return m.registerNode(salt, serverAddr, serverTlsCert, gatewayAddr,
+		gatewayTlsCert, registrationCode)
+}
+
+// registerNode registers a Node.
+func (m *RegistrationImpl) registerNode(salt []byte, serverAddr, serverTlsCert, gatewayAddr,
+	gatewayTlsCert, registrationCode string) error {
+
+	// Refuse sources which have failed too many registrations
+	attemptSource := registrationSource(serverAddr)
+	if err := m.registrationAttempts.check(attemptSource, time.Now()); err != nil {
+		return err
+	}
 
 	// If disableRegCodes is set, we atomically increase curNodeReg and use the previous code in the sequence
 	if disableRegCodes {
@@ -75,9 +90,14 @@ func (m *RegistrationImpl) RegisterNode(salt []byte, serverAddr, serverTlsCert,
 		registrationCode = regCodeInfos[regNum-1].RegCode
 	}
 
+	if err := m.checkGatewayFields(gatewayAddr, gatewayTlsCert); err != nil {
+		return err
+	}
+
 	// Check that the node hasn't already been registered
 	nodeInfo, err := storage.PermissioningDb.GetNode(registrationCode)
 	if err != nil {
+		m.registrationAttempts.fail(attemptSource, time.Now())
 		return errors.Errorf(
 			"Registration code %+v is invalid or not currently enabled: %+v", registrationCode, err)
 	}
@@ -96,15 +116,36 @@ func (m *RegistrationImpl) RegisterNode(salt []byte, serverAddr, serverTlsCert,
 		return errors.Errorf("Unable to generate Node ID with salt %v: %+v", salt, err)
 	}
 
+	// Blacklisted nodes may not join the network
+	if m.isBlacklisted(nodeId) {
+		return errors.Errorf("Node %s is blacklisted", nodeId)
+	}
+
 	// Handle various re-registration cases
+	source := storage.SelfServeRegistration
 	if len(nodeInfo.Id) != 0 {
+		source = storage.ReRegistration
+
+		// A registered node may move to a new key when allowed
+		if m.params.allowNodeKeyChange && len(nodeInfo.Salt) != 0 &&
+			!bytes.Equal(nodeInfo.Id, nodeId.Marshal()) {
+			err = m.reRegisterNode(nodeInfo, nodeId, salt, serverAddr,
+				serverTlsCert, gatewayAddr, gatewayTlsCert, registrationCode)
+			if err != nil {
+				return err
+			}
+			m.registrationAttempts.succeed(attemptSource)
+			return nil
+		}
 
 		// Ensure that generated ID matches stored ID
 		// Ensure that salt is not already stored
 		if !bytes.Equal(nodeInfo.Id, nodeId.Marshal()) {
+			m.registrationAttempts.fail(attemptSource, time.Now())
 			return errors.Errorf("Generated ID %+v does not match stored ID: %+v", nodeId.Marshal(), nodeInfo.Id)
 
 		} else if len(nodeInfo.Salt) != 0 {
+			m.registrationAttempts.fail(attemptSource, time.Now())
 			return errors.Errorf(
 				"Node with registration code %s has already been registered", registrationCode)
 		}
@@ -112,12 +153,13 @@ func (m *RegistrationImpl) RegisterNode(salt []byte, serverAddr, serverTlsCert,
 
 	// Attempt to insert Node into the database
 	err = storage.PermissioningDb.RegisterNode(nodeId, salt, registrationCode, serverAddr,
-		serverTlsCert, gatewayAddr, gatewayTlsCert)
+		serverTlsCert, gatewayAddr, gatewayTlsCert, source)
 	if err != nil {
 		return errors.Errorf("unable to insert node: %+v", err)
 	}
-	jww.DEBUG.Printf("Inserted node %s into the database with code %s",
-		nodeId.String(), registrationCode)
+	jww.DEBUG.Printf("Inserted node %s into the database with code %s (%s)",
+		nodeId.String(), registrationCode, source)
+	m.registrationAttempts.succeed(attemptSource)
 
 	//add the node to the host object for authenticated communications
 	_, err = m.Comms.AddHost(nodeId, serverAddr, []byte(serverTlsCert), connect.GetDefaultHostParams())
@@ -125,12 +167,20 @@ func (m *RegistrationImpl) RegisterNode(salt []byte, serverAddr, serverTlsCert,
 		return errors.Errorf("Could not register host for Server %s: %+v", serverAddr, err)
 	}
 
-	//add the node to the node map to track its state
-	err = m.State.GetNodeMap().AddNode(nodeId, nodeInfo.Sequence, serverAddr, gatewayAddr, nodeInfo.ApplicationId)
-	if err != nil {
-		return errors.WithMessage(err, "Could not register node with "+
-			"state tracker")
+	//add the node to the node map to track its state; a node registering
+	//again under its ID starts its poll sources over
+	if existing := m.State.GetNodeMap().GetNode(nodeId); source == storage.ReRegistration && existing != nil {
+		existing.ClearPollSources()
+	} else {
+		err = m.State.GetNodeMap().AddNode(nodeId, nodeInfo.Sequence, serverAddr, gatewayAddr, nodeInfo.ApplicationId)
+		if err != nil {
+			return errors.WithMessage(err, "Could not register node with "+
+				"state tracker")
+		}
 	}
+	m.State.GetNodeMap().GetNode(nodeId).SetGatewayless(
+		m.isGatewayless(gatewayTlsCert))
+	m.startProbation(m.State.GetNodeMap().GetNode(nodeId))
 
 	// Notify registration thread
 	return m.completeNodeRegistration(registrationCode)
@@ -144,55 +194,81 @@ type protoHost struct {
 
 var newHosts []protoHost
 
+// Number of nodes loaded from Storage at a time on startup, a variable so that
+// tests can load in small pages
+var nodeLoadPageSize = 1000
+
 // Loads all registered nodes and puts them into the host object and node map.
-// Should be run on startup.
+// Nodes are read from Storage a page at a time. Should be run on startup.
 func (m *RegistrationImpl) LoadAllRegisteredNodes() ([]*connect.Host, error) {
 	// TODO: This code could probably use some cleanup
 	// TODO: We might consider refactoring the ban timer code and this code to share stuff, they might have similar goals.
 	hosts := make([]*connect.Host, 0)
 
-	nodes, err := storage.PermissioningDb.GetNodesByStatus(node.Active)
+	// Only the IDs and connectivity are kept to restore connectivity after
+	// every page is loaded
+	var restorable []*storage.Node
+	activeFilter := storage.NodeFilter{Statuses: []node.Status{node.Active}}
+	err := storage.PermissioningDb.ForEachNodePage(activeFilter, nodeLoadPageSize,
+		func(nodes []*storage.Node) error {
+			for _, n := range nodes {
+				if !m.hasValidCertificates(n) {
+					continue
+				}
+
+				nid, err := id.Unmarshal(n.Id)
+
+				h, _ := connect.NewHost(nid, n.ServerAddress, []byte(n.NodeCertificate), connect.GetDefaultHostParams())
+				hosts = append(hosts, h)
+				//add the node to the node map to track its state
+				err = m.State.GetNodeMap().AddNode(nid, n.Sequence, n.ServerAddress, n.GatewayAddress, n.ApplicationId)
+				if err != nil {
+					return errors.WithMessage(err, "Could not register node with "+
+						"state tracker")
+				}
+				nodeState := m.State.GetNodeMap().GetNode(nid)
+				nodeState.SetPublicAddress(n.PublicAddress)
+				nodeState.SetGatewayClientPort(uint16(n.GatewayClientPort))
+				nodeState.SetGatewayless(m.isGatewayless(n.GatewayCertificate))
+				nodeState.SetProbation(n.OnProbation, n.ProbationRounds,
+					n.ProbationSince)
+
+				err = m.completeNodeRegistration(n.Code)
+				if err != nil {
+					return err
+				}
+				restorable = append(restorable,
+					&storage.Node{Id: n.Id, Connectivity: n.Connectivity})
+			}
+			return nil
+		})
 	if err != nil {
 		return nil, err
 	}
-
-	for _, n := range nodes {
-		nid, err := id.Unmarshal(n.Id)
-
-		h, _ := connect.NewHost(nid, n.ServerAddress, []byte(n.NodeCertificate), connect.GetDefaultHostParams())
-		hosts = append(hosts, h)
-		//add the node to the node map to track its state
-		err = m.State.GetNodeMap().AddNode(nid, n.Sequence, n.ServerAddress, n.GatewayAddress, n.ApplicationId)
-		if err != nil {
-			return nil, errors.WithMessage(err, "Could not register node with "+
-				"state tracker")
-		}
-
-		err = m.completeNodeRegistration(n.Code)
-		if err != nil {
-			return nil, err
-		}
-	}
-
-	bannedNodes, err := storage.PermissioningDb.GetNodesByStatus(node.Banned)
+	m.restoreConnectivity(restorable, time.Now())
+
+	bannedFilter := storage.NodeFilter{Statuses: []node.Status{node.Banned}}
+	err = storage.PermissioningDb.ForEachNodePage(bannedFilter, nodeLoadPageSize,
+		func(nodes []*storage.Node) error {
+			for _, n := range nodes {
+				nid, err := id.Unmarshal(n.Id)
+
+				h, _ := connect.NewHost(nid, n.ServerAddress, []byte(n.NodeCertificate), connect.GetDefaultHostParams())
+				hosts = append(hosts, h)
+
+				//add the node to the node map to track its state
+				err = m.State.GetNodeMap().AddBannedNode(nid, n.Sequence, n.ServerAddress, n.GatewayAddress)
+				if err != nil {
+					return errors.WithMessage(err, "Could not register node with "+
+						"state tracker")
+				}
+			}
+			return nil
+		})
 	if err != nil {
 		return nil, err
 	}
 
-	for _, n := range bannedNodes {
-		nid, err := id.Unmarshal(n.Id)
-
-		h, _ := connect.NewHost(nid, n.ServerAddress, []byte(n.NodeCertificate), connect.GetDefaultHostParams())
-		hosts = append(hosts, h)
-
-		//add the node to the node map to track its state
-		err = m.State.GetNodeMap().AddBannedNode(nid, n.Sequence, n.ServerAddress, n.GatewayAddress)
-		if err != nil {
-			return nil, errors.WithMessage(err, "Could not register node with "+
-				"state tracker")
-		}
-	}
-
 	return hosts, nil
 }
 
@@ -212,7 +288,7 @@ func (m *RegistrationImpl) completeNodeRegistration(regCode string) error {
 	// Add the new node to the topology
 	m.State.InternalNdfLock.Lock()
 	networkDef := m.State.GetUnprunedNdf()
-	gateway, n, regTime, err := assembleNdf(regCode)
+	gateway, n, regTime, err := m.assembleNdf(regCode)
 	if err != nil {
 		m.State.InternalNdfLock.Unlock()
 		err := errors.Errorf("unable to assemble topology: %+v", err)
@@ -299,7 +375,7 @@ func (m *RegistrationImpl) insertNdf(definition *ndf.NetworkDefinition, g ndf.Ga
 }
 
 // Assemble information for the given registration code
-func assembleNdf(code string) (ndf.Gateway, ndf.Node, int64, error) {
+func (m *RegistrationImpl) assembleNdf(code string) (ndf.Gateway, ndf.Node, int64, error) {
 
 	// Get node information for each registration code
 	nodeInfo, err := storage.PermissioningDb.GetNode(code)
@@ -325,7 +401,7 @@ func assembleNdf(code string) (ndf.Gateway, ndf.Node, int64, error) {
 	gwID := nodeID.DeepCopy()
 	gwID.SetType(id.Gateway)
 
-	bin, exists := region.GetCountryBin(nodeInfo.Sequence)
+	bin, exists := m.State.GetCountryBin(nodeInfo.Sequence)
 	if !exists {
 		return ndf.Gateway{}, ndf.Node{}, 0,
 			errors.Errorf("Error parsing node sequence %s, countru does not exist", nodeInfo.Sequence)
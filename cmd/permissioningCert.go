@@ -0,0 +1,38 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Contains the endpoint serving the keys permissioning signs with
+
+package cmd
+
+import (
+	"github.com/pkg/errors"
+)
+
+// PermissioningKeys are the public keys the NDF and round updates are signed
+// with.
+type PermissioningKeys struct {
+	// PEM encoded TLS certificate holding the RSA key
+	Certificate string
+	// Elliptic curve key round updates are also signed with
+	EllipticPubKey string
+}
+
+// GetPermissioningKeys returns the public certificate and keys of
+// permissioning, so that they can be fetched to verify the NDF and round
+// updates instead of being distributed out of band. They are public, so no
+// authentication is required.
+func (m *RegistrationImpl) GetPermissioningKeys() (*PermissioningKeys, error) {
+	if m.permissioningCert == nil || m.certFromFile == "" {
+		return nil, errors.New("Permissioning is not running with a " +
+			"certificate")
+	}
+	return &PermissioningKeys{
+		Certificate:    m.certFromFile,
+		EllipticPubKey: m.State.GetEllipticPublicKey().MarshalText(),
+	}, nil
+}
@@ -0,0 +1,73 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package cmd
+
+import (
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/xx_network/comms/signature"
+	"gitlab.com/xx_network/crypto/tls"
+	"gitlab.com/xx_network/primitives/utils"
+	"testing"
+)
+
+// Tests that GetPermissioningKeys() returns the configured certificate, and
+// that the NDF verifies against it.
+func TestRegistrationImpl_GetPermissioningKeys(t *testing.T) {
+	dblck.Lock()
+	defer dblck.Unlock()
+	var err error
+	var dc func() error
+	storage.PermissioningDb, dc, err = storage.NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	t.Cleanup(func() { _ = dc() })
+
+	impl, err := StartRegistration(testParams)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	t.Cleanup(impl.Comms.Shutdown)
+
+	keys, err := impl.GetPermissioningKeys()
+	if err != nil {
+		t.Fatalf("Failed to get permissioning keys: %+v", err)
+	}
+	expected, err := utils.ReadFile(testParams.CertPath)
+	if err != nil {
+		t.Fatalf("Failed to read certificate: %+v", err)
+	}
+	if keys.Certificate != string(expected) {
+		t.Errorf("Returned certificate does not match the configured one."+
+			"\nexpected: %s\nreceived: %s", expected, keys.Certificate)
+	}
+	if keys.EllipticPubKey != impl.State.GetUnprunedNdf().Registration.EllipticPubKey {
+		t.Errorf("Returned elliptic curve key does not match the NDF's.")
+	}
+
+	cert, err := tls.LoadCertificate(keys.Certificate)
+	if err != nil {
+		t.Fatalf("Failed to load returned certificate: %+v", err)
+	}
+	pubKey, err := tls.ExtractPublicKey(cert)
+	if err != nil {
+		t.Fatalf("Failed to extract key: %+v", err)
+	}
+	if err = impl.State.UpdateOutputNdf(); err != nil {
+		t.Fatalf("Failed to output NDF: %+v", err)
+	}
+	if err = signature.VerifyRsa(impl.State.GetFullNdf().GetPb(), pubKey); err != nil {
+		t.Errorf("NDF does not verify against the returned key: %+v", err)
+	}
+
+	// No keys are returned when running without a certificate
+	impl.permissioningCert = nil
+	if _, err = impl.GetPermissioningKeys(); err == nil {
+		t.Errorf("Keys were returned without a certificate.")
+	}
+}
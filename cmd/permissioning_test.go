@@ -14,10 +14,100 @@ import (
 	"gitlab.com/xx_network/comms/connect"
 	"gitlab.com/xx_network/primitives/id"
 	"gitlab.com/xx_network/primitives/utils"
+	"reflect"
 	"testing"
 	"time"
 )
 
+// Tests that loading the registered nodes in small pages loads the same active
+// and banned nodes as listing them from Storage all at once.
+func TestLoadAllRegisteredNodes_Paged(t *testing.T) {
+	var err error
+	dblck.Lock()
+	defer dblck.Unlock()
+
+	var dc func() error
+	storage.PermissioningDb, dc, err = storage.NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	defer func() { _ = dc() }()
+	err = storage.PermissioningDb.InsertEphemeralLength(
+		&storage.EphemeralLength{Length: 8, Timestamp: time.Now()})
+	if err != nil {
+		t.Errorf("Failed to insert ephemeral length into database: %+v", err)
+	}
+
+	codes := []string{"EEEE", "BBBB", "AAAA", "DDDD", "CCCC"}
+	infos := make([]node.Info, len(codes))
+	for i, code := range codes {
+		infos[i] = node.Info{RegCode: code, Order: "US"}
+	}
+	storage.PopulateNodeRegistrationCodes(infos)
+
+	crt, err := utils.ReadFile(testkeys.GetCACertPath())
+	if err != nil {
+		t.Fatalf("Failed to read certificate: %+v", err)
+	}
+	for i, code := range codes {
+		nid := id.NewIdFromUInt(uint64(i), id.Node, t)
+		err = storage.PermissioningDb.RegisterNode(nid, []byte("salt"), code,
+			"0.0.0.0", string(crt), "0.0.0.0", string(crt),
+			storage.SelfServeRegistration)
+		if err != nil {
+			t.Fatalf("Failed to register node: %+v", err)
+		}
+	}
+	err = storage.PermissioningDb.GetDatabaseImpl(t).BannedNode(
+		id.NewIdFromUInt(3, id.Node, t), t)
+	if err != nil {
+		t.Fatalf("Failed to ban node: %+v", err)
+	}
+
+	expected := make(map[id.ID]bool)
+	for _, status := range []node.Status{node.Active, node.Banned} {
+		nodes, err := storage.PermissioningDb.GetNodesByStatus(status)
+		if err != nil {
+			t.Fatalf("Failed to get nodes: %+v", err)
+		}
+		for _, n := range nodes {
+			nid, err := id.Unmarshal(n.Id)
+			if err != nil {
+				t.Fatalf("Failed to unmarshal node ID: %+v", err)
+			}
+			expected[*nid] = true
+		}
+	}
+
+	impl, err := StartRegistration(testParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer impl.Comms.Shutdown()
+
+	defer func(pageSize int) { nodeLoadPageSize = pageSize }(nodeLoadPageSize)
+	nodeLoadPageSize = 2
+	hosts, err := impl.LoadAllRegisteredNodes()
+	if err != nil {
+		t.Fatalf("LoadAllRegisteredNodes returned an error: %+v", err)
+	}
+
+	loaded := make(map[id.ID]bool)
+	for _, h := range hosts {
+		loaded[*h.GetId()] = true
+		if impl.State.GetNodeMap().GetNode(h.GetId()) == nil {
+			t.Errorf("Node %s is not in the node map.", h.GetId())
+		}
+	}
+	if len(loaded) != len(hosts) || !reflect.DeepEqual(expected, loaded) {
+		t.Errorf("Paged load differs from the unpaged listing."+
+			"\nexpected: %v\nreceived: %v", expected, loaded)
+	}
+	if !impl.State.GetNodeMap().GetNode(id.NewIdFromUInt(3, id.Node, t)).IsBanned() {
+		t.Errorf("Banned node was not loaded as banned.")
+	}
+}
+
 // Happy path: tests that the function loads active and banned nodes into the maps
 func TestLoadAllRegisteredNodes(t *testing.T) {
 	// region Database setup
@@ -51,7 +141,8 @@ func TestLoadAllRegisteredNodes(t *testing.T) {
 	// Create a new ID and store a new active node into the database
 	activeNodeId := id.NewIdFromUInt(0, id.Node, t)
 	err = storage.PermissioningDb.RegisterNode(activeNodeId, []byte("test1"),
-		"AAAA", "0.0.0.0", string(crt), "0.0.0.0", string(crt))
+		"AAAA", "0.0.0.0", string(crt), "0.0.0.0", string(crt),
+		storage.SelfServeRegistration)
 	if err != nil {
 		t.Error(err)
 	}
@@ -60,7 +151,8 @@ func TestLoadAllRegisteredNodes(t *testing.T) {
 	// Create a new ID and store a new *banned* node into the database
 	bannedNodeId := id.NewIdFromUInt(1, id.Node, t)
 	err = storage.PermissioningDb.RegisterNode(bannedNodeId, []byte("test2"),
-		"BBBB", "0.0.0.0", string(crt), "0.0.0.0", string(crt))
+		"BBBB", "0.0.0.0", string(crt), "0.0.0.0", string(crt),
+		storage.SelfServeRegistration)
 	if err != nil {
 		t.Error(err)
 	}
@@ -69,7 +161,8 @@ func TestLoadAllRegisteredNodes(t *testing.T) {
 	// Create a new ID and store a new *banned* node into the database
 	altNodeID := id.NewIdFromString("alt", id.Node, t)
 	err = storage.PermissioningDb.RegisterNode(altNodeID, []byte("test3"),
-		"CCCC", "0.0.0.0", string(crt), "0.0.0.0", string(crt))
+		"CCCC", "0.0.0.0", string(crt), "0.0.0.0", string(crt),
+		storage.SelfServeRegistration)
 	if err != nil {
 		t.Error(err)
 	}
@@ -0,0 +1,145 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Handles assembling the permissioning policy document fetched by nodes and
+// the policy version hint sent in poll responses
+
+package cmd
+
+import (
+	"encoding/json"
+	"github.com/pkg/errors"
+	jww "github.com/spf13/jwalterweatherman"
+	pb "gitlab.com/elixxir/comms/mixmessages"
+	"gitlab.com/xx_network/comms/connect"
+	"gitlab.com/xx_network/comms/signature"
+	"google.golang.org/protobuf/encoding/protowire"
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+// policyVersionPollField is the field number of the policy version hint in
+// the PermissionPollResponse message. It is sent as a varint in the message's
+// unknown fields until the comms message declares the field. Nodes fetch the
+// policy again when it differs from the version of their policy document.
+const policyVersionPollField protowire.Number = 13
+
+// policyValues are the permissioning policies which apply to every node.
+type policyValues struct {
+	MinGatewayVersion string
+	MinServerVersion  string
+	MinClientVersion  string
+
+	// How often node metrics are recorded and the disabled node list is
+	// reloaded
+	NodeMetricInterval        time.Duration
+	DisabledNodesPollInterval time.Duration
+
+	PruneRetentionLimit   time.Duration
+	MessageRetentionLimit time.Duration
+	DisableNDFPruning     bool
+
+	// Set when nodes and gateways must be reachable at the ports they
+	// advertise
+	CheckPorts bool
+}
+
+// PolicyDocument is the versioned permissioning policy document sent to a
+// node. The version changes whenever any of the policy values change.
+type PolicyDocument struct {
+	Version uint64
+	policyValues
+
+	// Ports the requesting node and its gateway must be reachable at
+	RequiredPorts []string
+}
+
+// currentPolicyValues assembles the policy values from the live params.
+func (m *RegistrationImpl) currentPolicyValues() policyValues {
+	m.params.versionLock.RLock()
+	values := policyValues{
+		MinGatewayVersion: m.params.minGatewayVersion.String(),
+		MinServerVersion:  m.params.minServerVersion.String(),
+		MinClientVersion:  m.params.minClientVersion.String(),
+	}
+	m.params.versionLock.RUnlock()
+
+	values.NodeMetricInterval = m.params.nodeMetricInterval
+	values.DisabledNodesPollInterval = disabledNodesPollDuration
+	values.PruneRetentionLimit = m.params.pruneRetentionLimit
+	values.MessageRetentionLimit = m.params.GetMessageRetention()
+	values.DisableNDFPruning = m.params.disableNDFPruning
+	values.CheckPorts = !m.params.disablePing
+	return values
+}
+
+// getPolicyVersion returns the version of the current policy values.
+func (m *RegistrationImpl) getPolicyVersion() uint64 {
+	return atomic.LoadUint64(&m.policyVersion)
+}
+
+// updatePolicyVersion increments the policy version if the policy values
+// differ from the values before a runtime update.
+func (m *RegistrationImpl) updatePolicyVersion(previous policyValues) {
+	if m.currentPolicyValues() == previous {
+		return
+	}
+	jww.INFO.Printf("Permissioning policy changed, now at version %d",
+		atomic.AddUint64(&m.policyVersion, 1))
+}
+
+// setPolicyHint adds the current policy version to the poll response.
+func (m *RegistrationImpl) setPolicyHint(response *pb.PermissionPollResponse) {
+	hint := protowire.AppendTag(nil, policyVersionPollField, protowire.VarintType)
+	hint = protowire.AppendVarint(hint, m.getPolicyVersion())
+	response.ProtoReflect().SetUnknown(hint)
+}
+
+// GetPolicy returns the permissioning policy document for the authenticated
+// node, JSON encoded and signed by the permissioning server in the returned
+// message. Nodes fetch it at startup and whenever the policy version hint in a
+// poll response differs from the version of their document.
+func (m *RegistrationImpl) GetPolicy(auth *connect.Auth) (*pb.NDF, error) {
+	if !auth.IsAuthenticated {
+		return nil, connect.AuthError(auth.Sender.GetId())
+	}
+
+	nid := auth.Sender.GetId()
+	n := m.State.GetNodeMap().GetNode(nid)
+	if n == nil {
+		return nil, errors.Errorf("Node %s could not be found in internal "+
+			"state tracker", nid)
+	}
+
+	// The version is read first so that a change made while the document is
+	// assembled results in the node fetching it again
+	doc := PolicyDocument{Version: m.getPolicyVersion()}
+	doc.policyValues = m.currentPolicyValues()
+	doc.RequiredPorts = make([]string, 0, 2)
+	if doc.CheckPorts {
+		for _, address := range []string{n.GetNodeAddresses(), n.GetGatewayAddress()} {
+			_, port, err := net.SplitHostPort(address)
+			if err != nil {
+				continue
+			}
+			doc.RequiredPorts = append(doc.RequiredPorts, port)
+		}
+	}
+
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return nil, errors.Errorf("Failed to marshal policy document: %+v", err)
+	}
+
+	policyMsg := &pb.NDF{Ndf: data}
+	err = signature.SignRsa(policyMsg, m.State.GetPrivateKey())
+	if err != nil {
+		return nil, errors.Errorf("Failed to sign policy document: %+v", err)
+	}
+	return policyMsg, nil
+}
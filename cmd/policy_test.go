@@ -0,0 +1,186 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package cmd
+
+import (
+	"encoding/json"
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+	pb "gitlab.com/elixxir/comms/mixmessages"
+	"gitlab.com/elixxir/primitives/version"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/xx_network/comms/connect"
+	"gitlab.com/xx_network/comms/signature"
+	"gitlab.com/xx_network/primitives/id"
+	"gitlab.com/xx_network/primitives/region"
+	"google.golang.org/protobuf/encoding/protowire"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// Returns the policy version hint in the poll response, or fails the test
+func getPolicyHint(t *testing.T, response *pb.PermissionPollResponse) uint64 {
+	unknown := response.ProtoReflect().GetUnknown()
+	num, typ, n := protowire.ConsumeTag(unknown)
+	if n < 0 || num != policyVersionPollField || typ != protowire.VarintType {
+		t.Fatalf("Poll response does not hold a policy hint: %v", unknown)
+	}
+	v, m := protowire.ConsumeVarint(unknown[n:])
+	if m < 0 {
+		t.Fatalf("Malformed policy hint: %v", unknown)
+	}
+	return v
+}
+
+// Fetches and verifies the policy document for the node, or fails the test
+func getPolicyDocument(t *testing.T, impl *RegistrationImpl, auth *connect.Auth) PolicyDocument {
+	policyMsg, err := impl.GetPolicy(auth)
+	if err != nil {
+		t.Fatalf("Failed to get policy: %+v", err)
+	}
+	err = signature.VerifyRsa(policyMsg, impl.State.GetPrivateKey().GetPublic())
+	if err != nil {
+		t.Fatalf("Failed to verify policy signature: %+v", err)
+	}
+
+	var doc PolicyDocument
+	if err = json.Unmarshal(policyMsg.Ndf, &doc); err != nil {
+		t.Fatalf("Failed to unmarshal policy document: %+v", err)
+	}
+	return doc
+}
+
+// Returns a network state holding a node and a host for that node
+func newPolicyTestState(t *testing.T) (*storage.NetworkState, *connect.Host) {
+	var err error
+	var dc func() error
+	storage.PermissioningDb, dc, err = storage.NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("Failed to create database: %+v", err)
+	}
+	t.Cleanup(func() { _ = dc() })
+	state, err := storage.NewState(getTestKey(), 8, "", "", region.GetCountryBins())
+	if err != nil {
+		t.Fatalf("Failed to create state: %+v", err)
+	}
+
+	nid := id.NewIdFromString("node", id.Node, t)
+	err = state.GetNodeMap().AddNode(nid, "", "0.0.0.0:11420", "0.0.0.0:22840", 1)
+	if err != nil {
+		t.Fatalf("Failed to add node: %+v", err)
+	}
+	host, err := connect.NewHost(nid, "", nil, connect.GetDefaultHostParams())
+	if err != nil {
+		t.Fatalf("Failed to create host: %+v", err)
+	}
+	return state, host
+}
+
+// Tests that changing a policy value at runtime updates the hint sent in
+// poll responses and the contents of the policy document.
+func TestRegistrationImpl_GetPolicy(t *testing.T) {
+	state, host := newPolicyTestState(t)
+	auth := &connect.Auth{IsAuthenticated: true, Sender: host}
+
+	minVersion, _ := version.ParseVersion("1.1.0")
+	impl := &RegistrationImpl{
+		State: state,
+		params: &Params{
+			minGatewayVersion:     minVersion,
+			minServerVersion:      minVersion,
+			minClientVersion:      minVersion,
+			nodeMetricInterval:    time.Minute,
+			pruneRetentionLimit:   24 * time.Hour,
+			messageRetentionLimit: 48 * time.Hour,
+		},
+		policyVersion: 1,
+	}
+
+	// An unauthenticated poll fails but still carries the hint
+	response, _ := impl.Poll(&pb.PermissioningPoll{}, &connect.Auth{Sender: host})
+	if hint := getPolicyHint(t, response); hint != 1 {
+		t.Errorf("Unexpected policy hint.\nexpected: %d\nreceived: %d", 1, hint)
+	}
+
+	doc := getPolicyDocument(t, impl, auth)
+	expected := PolicyDocument{
+		Version: 1,
+		policyValues: policyValues{
+			MinGatewayVersion:         "1.1.0",
+			MinServerVersion:          "1.1.0",
+			MinClientVersion:          "1.1.0",
+			NodeMetricInterval:        time.Minute,
+			DisabledNodesPollInterval: disabledNodesPollDuration,
+			PruneRetentionLimit:       24 * time.Hour,
+			MessageRetentionLimit:     48 * time.Hour,
+			CheckPorts:                true,
+		},
+		RequiredPorts: []string{"11420", "22840"},
+	}
+	if !reflect.DeepEqual(doc, expected) {
+		t.Errorf("Unexpected policy document.\nexpected: %+v\nreceived: %+v",
+			expected, doc)
+	}
+
+	// A config reload which does not change any policy keeps the version
+	viper.Set("minGatewayVersion", "1.1.0")
+	viper.Set("minServerVersion", "1.1.0")
+	viper.Set("minClientVersion", "1.1.0")
+	viper.Set("messageRetentionLimit", 48*time.Hour)
+	defer viper.Reset()
+	impl.update(fsnotify.Event{})
+	if v := impl.getPolicyVersion(); v != 1 {
+		t.Errorf("Policy version changed without a policy change: %d", v)
+	}
+
+	// Raising the minimum server version changes the hint and the document
+	viper.Set("minServerVersion", "2.0.0")
+	impl.update(fsnotify.Event{})
+
+	response, _ = impl.Poll(&pb.PermissioningPoll{}, &connect.Auth{Sender: host})
+	if hint := getPolicyHint(t, response); hint != 2 {
+		t.Errorf("Policy hint not updated.\nexpected: %d\nreceived: %d", 2, hint)
+	}
+	expected.Version = 2
+	expected.MinServerVersion = "2.0.0"
+	doc = getPolicyDocument(t, impl, auth)
+	if !reflect.DeepEqual(doc, expected) {
+		t.Errorf("Unexpected policy document.\nexpected: %+v\nreceived: %+v",
+			expected, doc)
+	}
+}
+
+// Tests that no ports are required while port checking is disabled.
+func TestRegistrationImpl_GetPolicy_DisablePing(t *testing.T) {
+	state, host := newPolicyTestState(t)
+	impl := &RegistrationImpl{State: state, params: &Params{disablePing: true}}
+	doc := getPolicyDocument(t, impl, &connect.Auth{IsAuthenticated: true, Sender: host})
+	if doc.CheckPorts || len(doc.RequiredPorts) != 0 {
+		t.Errorf("Ports required while port checking is disabled: %+v", doc)
+	}
+}
+
+// Error path: tests that unauthenticated and unknown nodes cannot get the
+// policy.
+func TestRegistrationImpl_GetPolicy_Error(t *testing.T) {
+	state, _ := newPolicyTestState(t)
+	host, err := connect.NewHost(id.NewIdFromString("unknown", id.Node, t), "",
+		nil, connect.GetDefaultHostParams())
+	if err != nil {
+		t.Fatalf("Failed to create host: %+v", err)
+	}
+	impl := &RegistrationImpl{State: state, params: &Params{}}
+
+	if _, err = impl.GetPolicy(&connect.Auth{Sender: host}); err == nil {
+		t.Errorf("Unauthenticated node got the policy.")
+	}
+	if _, err = impl.GetPolicy(&connect.Auth{IsAuthenticated: true, Sender: host}); err == nil {
+		t.Errorf("Unknown node got the policy.")
+	}
+}
@@ -23,15 +23,25 @@ import (
 	"gitlab.com/xx_network/primitives/id"
 	"gitlab.com/xx_network/primitives/ndf"
 	"gitlab.com/xx_network/primitives/utils"
-	"math/rand"
 	"sync/atomic"
+	"time"
 )
 
 // Server->Permissioning unified poll function
 func (m *RegistrationImpl) Poll(msg *pb.PermissioningPoll, auth *connect.Auth) (*pb.PermissionPollResponse, error) {
+	return m.limitPolls(func() (*pb.PermissionPollResponse, error) {
+		return m.poll(msg, auth)
+	})
+}
+
+// poll handles a unified poll once it is within the concurrent poll limit
+func (m *RegistrationImpl) poll(msg *pb.PermissioningPoll, auth *connect.Auth) (*pb.PermissionPollResponse, error) {
 
 	// Initialize the response
 	response := &pb.PermissionPollResponse{}
+	m.setPolicyHint(response)
+	drainStatus := m.State.GetDrainStatus()
+	setDrainStatus(response, drainStatus)
 	earliestClientRound, earliestGwRound, earliestGwRoundTs, err := m.GetEarliestRoundInfo()
 	if err != nil {
 		response.EarliestRoundErr = err.Error()
@@ -40,6 +50,7 @@ func (m *RegistrationImpl) Poll(msg *pb.PermissioningPoll, auth *connect.Auth) (
 		response.EarliestGatewayRound = earliestGwRound
 		response.EarliestRoundTimestamp = earliestGwRoundTs
 	}
+	m.setSafeUpdateWatermark(response)
 
 	//do edge check to ensure the message is not nil
 	if msg == nil {
@@ -52,6 +63,9 @@ func (m *RegistrationImpl) Poll(msg *pb.PermissioningPoll, auth *connect.Auth) (
 		return response, connect.AuthError(auth.Sender.GetId())
 	}
 
+	// Capture the raw poll if it is sampled or the node is targeted
+	m.pollCapture.capture(auth.Sender.GetId(), msg, time.Now())
+
 	// Check for correct version
 	err = checkVersion(m.params, msg)
 	if err != nil {
@@ -67,13 +81,43 @@ func (m *RegistrationImpl) Poll(msg *pb.PermissioningPoll, auth *connect.Auth) (
 		return response, err
 	}
 
+	// Throttle the node if its application polls too often
+	if !m.appPollLimits.allow(n.GetAppID(), nid, time.Now()) {
+		return response, errors.New(ApplicationPollsLimited)
+	}
+
+	// Read the node's state once, so that it is not read under a separate
+	// lock acquisition per field
+	snapshot := n.Snapshot()
+
 	// Check if the node has been deemed out of network
-	if n.IsBanned() {
-		return response, errors.Errorf("Node %s has been banned from the network", nid)
+	if snapshot.Status == node.Banned {
+		// Versions of banned nodes are kept for automatic unbanning
+		recordVersions(n, msg)
+		return m.respondBanned(response, n, time.Now())
 	}
 
 	activity := current.Activity(msg.Activity)
 
+	// Correlate the poll's log lines with the node and its current round
+	var roundID id.Round
+	if hasRound, r := snapshot.InRound(); hasRound {
+		roundID = r.GetRoundID()
+	}
+	trace := m.State.Trace(roundID, nid, msg.LastUpdate)
+	trace.Debugf("Received poll with activity %s", activity)
+
+	// Reject malformed error payloads before anything else is done with the
+	// poll, if configured to
+	if m.params.earlyErrorValidation {
+		if err = validateErrorPayload(m, msg, snapshot); err != nil {
+			err = errors.WithMessagef(err, "A malformed error was received "+
+				"from %s", nid)
+			trace.Warnf("%v", err)
+			return response, err
+		}
+	}
+
 	// update ip addresses if necessary
 	err = checkIPAddresses(m, n, msg, auth.Sender)
 	if err != nil {
@@ -81,6 +125,30 @@ func (m *RegistrationImpl) Poll(msg *pb.PermissioningPoll, auth *connect.Auth) (
 		return response, err
 	}
 
+	// Compare the address the poll came from against the advertised address
+	checkObservedAddress(n, auth.IpAddress)
+	m.checkPollSources(n, auth.IpAddress, time.Now())
+
+	// Store the node's advisory capacity hint, if it sent one
+	updateCapacity(n, msg)
+
+	// Record the versions the node is running, if they changed
+	recordVersions(n, msg)
+
+	// Record the round updates the node's gateway confirmed processing
+	if ack, reported := getGatewayAck(msg); reported {
+		n.SetGatewayAck(ack)
+	}
+
+	// Record whether the node relays its updates to its gateway
+	n.SetRelaysUpdates(getRelaysUpdates(msg))
+
+	// Store the health error the node reported outside of any round, if any
+	err = m.handleHealthReport(n, msg, time.Now())
+	if err != nil {
+		return response, err
+	}
+
 	// Check the node's connectivity
 	continuePoll, err := m.checkConnectivity(n, auth.IpAddress, activity)
 	if err != nil || !continuePoll {
@@ -96,36 +164,55 @@ func (m *RegistrationImpl) Poll(msg *pb.PermissioningPoll, auth *connect.Auth) (
 		return response, errors.New(ndf.NO_NDF)
 	}
 
-	// Return updated NDF if provided hash does not match current NDF hash
-	if isSame := m.State.GetFullNdf().CompareHash(msg.Full.Hash); !isSame {
-		jww.TRACE.Printf("Returning a new NDF to a back-end server!")
+	// Return updated NDF if provided hash does not match current NDF hash,
+	// unless its delivery to the node is being spread
+	published := m.State.GetPollSnapshot()
+	if isSame := bytes.Equal(published.FullNdfHash, msg.Full.Hash); !isSame &&
+		!m.ndfSpread.withhold(nid, published, msg.Full.Hash, time.Now()) {
+		trace.Tracef("Returning a new NDF to a back-end server!")
 
 		// Return the updated NDFs
-		response.FullNDF = m.State.GetFullNdf().GetPb()
-		response.PartialNDF = m.State.GetPartialNdf().GetPb()
+		response.FullNDF = published.FullNdf
+		response.PartialNDF = published.PartialNdf
 	}
 
 	// Fetch the latest round updates
-	response.Updates, err = m.State.GetUpdates(int(msg.LastUpdate))
+	lastUpdate, err := m.checkUpdateCursor(n, msg.LastUpdate)
+	if err != nil {
+		return response, err
+	}
+	response.Updates, err = m.getNodeUpdates(n, lastUpdate)
 	if err != nil {
 		return response, err
 	}
 
 	// Commit updates reported by the node if node involved in the current round
-	jww.TRACE.Printf("Updating state for node %s: %+v",
-		auth.Sender.GetId(), msg)
+	trace.Tracef("Updating state: %+v", msg)
 
 	//catch edge case with malformed error and return it to the node
 	if current.Activity(msg.Activity) == current.ERROR && msg.Error == nil {
 		err = errors.Errorf("A malformed error was received from %s "+
 			"with a nil error payload", nid)
-		jww.WARN.Println(err)
+		trace.Warnf("%v", err)
 		return response, err
 	}
 
+	// A drained network takes no more node updates, as if round creation
+	// was stopped
+	stopped := atomic.LoadUint32(m.Stopped) == 1 ||
+		drainStatus == storage.Drained
+
+	// Let waiting nodes know roughly how long until they are scheduled
+	if activity == current.WAITING {
+		m.setWaitEstimate(response, n,
+			stopped || drainStatus == storage.Draining, time.Now())
+	}
+
+	// Let nodes in a round know their position in its topology
+	setTopologyPosition(response, n)
+
 	// If round creation stopped OR if the node is in not started state,
 	// return early before we get the polling lock
-	stopped := atomic.LoadUint32(m.Stopped) == 1
 	if activity == current.NOT_STARTED || stopped {
 		return response, nil
 	}
@@ -136,6 +223,13 @@ func (m *RegistrationImpl) Poll(msg *pb.PermissioningPoll, auth *connect.Auth) (
 		return response, err
 	}
 
+	// Redact sensitive information from the verified error before it can be
+	// published in round info
+	sanitizedError, err := m.State.SanitizeRoundError(msg.Error)
+	if err != nil {
+		return response, err
+	}
+
 	//check if the node is pruned if it is, bail
 	if m.State.IsPruned(n.GetID()) {
 		return response, err
@@ -144,9 +238,18 @@ func (m *RegistrationImpl) Poll(msg *pb.PermissioningPoll, auth *connect.Auth) (
 	// when a node poll is received, the nodes polling lock is taken here. If
 	// there is no update, it is released in this endpoint, otherwise it is
 	// released in the scheduling algorithm which blocks all future polls until
-	// processing completes
+	// processing completes. It is also released if the update cannot be sent to
+	// the scheduler or is not handled within the polling lock timeout
 	n.GetPollingLock().Lock()
 
+	// The node may have been removed while the poll waited for the lock, in
+	// which case the scheduler can no longer handle its update
+	if n.IsRemoved() {
+		n.GetPollingLock().Unlock()
+		return response, errors.Errorf("Node %s is no longer registered",
+			n.GetID())
+	}
+
 	// update does edge checking. It ensures the state change received was a
 	// valid one and the state of the node and
 	// any associated round allows for that change. If the change was not
@@ -158,11 +261,16 @@ func (m *RegistrationImpl) Poll(msg *pb.PermissioningPoll, auth *connect.Auth) (
 		return response, err
 	}
 
-	// If updating to an error state, attach the error to the update
+	// If updating to an error state, attach the sanitized error to the update
 	if updateNotification.ToActivity == current.ERROR {
-		updateNotification.Error = msg.Error
+		updateNotification.Error = sanitizedError
+		if msg.Error != nil {
+			updateNotification.RawError = msg.Error.Error
+		}
 	}
 	updateNotification.ClientErrors = msg.ClientErrors
+	trace.Debugf("Reporting update from %s to %s",
+		updateNotification.FromActivity, updateNotification.ToActivity)
 
 	// Update occurred, report it to the control thread
 	return response, m.State.SendUpdateNotification(updateNotification)
@@ -177,25 +285,49 @@ func (m *RegistrationImpl) PollNdf(theirNdfHash []byte) (*pb.NDF, error) {
 		return nil, errors.New(ndf.NO_NDF)
 	}
 
+	// Withhold the NDF entirely while the network is degraded in strict mode
+	readiness := m.readiness.check(m.State, time.Now())
+	if !readiness.Ready && m.readiness.strict {
+		return nil, errors.New(ndf.NO_NDF)
+	}
+
+	// Select the format of the NDF to return
+	format, theirNdfHash := parseNdfFormatRequest(theirNdfHash)
+	published := m.State.GetPartialNdf()
+	if format != "" {
+		if published = m.State.GetFormatNdf(format); published == nil {
+			return nil, errors.Errorf("Unknown NDF format %q", format)
+		}
+	}
+
 	// Do not return NDF if backend hash matches
-	if isSame := m.State.GetPartialNdf().CompareHash(theirNdfHash); isSame {
+	if isSame := published.CompareHash(theirNdfHash); isSame {
+		if m.readiness != nil {
+			return withReadiness(&pb.NDF{}, readiness.Ready), nil
+		}
 		return &pb.NDF{}, nil
 	}
 
 	//Send the json of the ndf
 	jww.TRACE.Printf("Returning a new NDF to a back-end server!")
-	return m.State.GetPartialNdf().GetPb(), nil
+	if m.readiness != nil {
+		return withReadiness(published.GetPb(), readiness.Ready), nil
+	}
+	return published.GetPb(), nil
 }
 
 // checkVersion checks if the PermissioningPoll message server and gateway
-// versions are compatible with the required version.
+// versions are compatible with the required version, or with the previous
+// required version while its grace window is open.
 func checkVersion(p *Params, msg *pb.PermissioningPoll) error {
 
 	// Pull the versions
 	p.versionLock.RLock()
 	requiredGateway := p.minGatewayVersion
 	requiredServer := p.minServerVersion
+	grace := p.versionGrace
 	p.versionLock.RUnlock()
+	now := time.Now()
 
 	// Skip checking gateway if the server is polled before gateway resulting in
 	// a blank gateway version
@@ -208,7 +340,9 @@ func checkVersion(p *Params, msg *pb.PermissioningPoll) error {
 		}
 
 		// Check that the gateway version is compatible with the required version
-		if !version.IsCompatible(requiredGateway, gatewayVersion) {
+		if !version.IsCompatible(requiredGateway, gatewayVersion) &&
+			!grace.allows("gateway", grace.gateway, requiredGateway,
+				gatewayVersion, now) {
 			return errors.Errorf("The gateway version %#v is incompatible with "+
 				"the required version %#v.",
 				gatewayVersion.String(), requiredGateway.String())
@@ -226,7 +360,9 @@ func checkVersion(p *Params, msg *pb.PermissioningPoll) error {
 	}
 
 	// Check that the server version is compatible with the required version
-	if !version.IsCompatible(requiredServer, serverVersion) {
+	if !version.IsCompatible(requiredServer, serverVersion) &&
+		!grace.allows("server", grace.server, requiredServer, serverVersion,
+			now) {
 		return errors.Errorf("The server version %#v is incompatible with "+
 			"the required version %#v.",
 			serverVersion.String(), requiredServer.String())
@@ -235,6 +371,25 @@ func checkVersion(p *Params, msg *pb.PermissioningPoll) error {
 	return nil
 }
 
+// recordVersions stores the server and gateway versions reported in the poll
+// when they differ from the last ones the node reported, so that upgrade
+// progress across the network can be followed.
+func recordVersions(n *node.State, msg *pb.PermissioningPoll) {
+	if !n.UpdateVersions(msg.GetServerVersion(), msg.GetGatewayVersion()) {
+		return
+	}
+
+	serverVersion, gatewayVersion := n.GetVersions()
+	jww.INFO.Printf("Node %s reported server version %q and gateway version %q",
+		n.GetID(), serverVersion, gatewayVersion)
+	err := storage.PermissioningDb.UpdateNodeVersions(n.GetID(), serverVersion,
+		gatewayVersion, time.Now())
+	if err != nil {
+		jww.WARN.Printf("Failed to store versions of node %s: %+v",
+			n.GetID(), err)
+	}
+}
+
 func updateNdfEd25519(nid *id.ID, ed []byte, ndf *ndf.NetworkDefinition) error {
 	for i, n := range ndf.Nodes {
 		if bytes.Equal(n.ID, nid[:]) {
@@ -299,20 +454,28 @@ func verifyError(msg *pb.PermissioningPoll, n *node.State, m *RegistrationImpl)
 			}
 		}
 
-		//check the error is signed by the node that created it
-		errorNodeId, err := id.Unmarshal(msg.Error.NodeId)
-		if err != nil {
-			return errors.WithMessage(err, "Could not unmarshal node ID from error in poll")
-		}
-		h, ok := m.Comms.GetHost(errorNodeId)
-		if !ok {
-			return errors.Errorf("Host %+v was not found in host map", errorNodeId)
-		}
-		nodePK := h.GetPubKey()
-		err = signature.VerifyRsa(msg.Error, nodePK)
-		if err != nil {
-			return errors.WithMessage(err, "Failed to verify error signature")
-		}
+		return verifyErrorSignature(m, msg.Error)
+	}
+	return nil
+}
+
+// verifyErrorSignature checks the error is signed by the node that created it
+func verifyErrorSignature(m *RegistrationImpl, roundErr *pb.RoundError) error {
+	errorNodeId, err := id.Unmarshal(roundErr.NodeId)
+	if err != nil {
+		return errors.WithMessage(err, "Could not unmarshal node ID from error in poll")
+	}
+	h, ok := m.Comms.GetHost(errorNodeId)
+	if !ok {
+		return errors.Errorf("Host %+v was not found in host map", errorNodeId)
+	}
+	if len(roundErr.GetSignature().GetNonce()) == 0 {
+		return errors.Errorf("Error from %s is not signed", errorNodeId)
+	}
+	nodePK := h.GetPubKey()
+	err = signature.VerifyRsa(roundErr, nodePK)
+	if err != nil {
+		return errors.WithMessage(err, "Failed to verify error signature")
 	}
 	return nil
 }
@@ -322,6 +485,11 @@ func checkIPAddresses(m *RegistrationImpl, n *node.State,
 
 	// Pull the addresses out of the message
 	gatewayAddress, nodeAddress := msg.GatewayAddress, msg.ServerAddress
+	publicAddress := getPublicAddress(msg)
+	gatewayClientPort, err := getGatewayClientPort(msg)
+	if err != nil {
+		return err
+	}
 
 	// Prevent adding same address for both Node and Gateway
 	if nodeAddress == gatewayAddress && len(nodeAddress) > 0 {
@@ -329,6 +497,20 @@ func checkIPAddresses(m *RegistrationImpl, n *node.State,
 			"gateway and node address of: %s and %s", nodeAddress, gatewayAddress)
 	}
 
+	// Gateway-less nodes have no gateway whose address could be reported
+	if n.IsGatewayless() && (gatewayAddress != "" || gatewayClientPort != 0) {
+		return errors.Errorf("Cannot handle gateway address %s reported by "+
+			"node %s which was registered without a gateway", gatewayAddress,
+			n.GetID())
+	}
+
+	// Refuse addresses which fail verification before they reach the state
+	err = m.verifyReportedAddresses(n, nodeAddress, gatewayAddress,
+		publicAddress)
+	if err != nil {
+		return err
+	}
+
 	// Update server and gateway addresses in state, if necessary
 	nodeUpdate, err := n.UpdateNodeAddresses(nodeAddress)
 	if err != nil {
@@ -342,9 +524,11 @@ func checkIPAddresses(m *RegistrationImpl, n *node.State,
 	if err != nil {
 		return err
 	}
+	publicUpdate := n.SetPublicAddress(publicAddress)
+	clientPortUpdate := n.SetGatewayClientPort(gatewayClientPort)
 
 	// If state required changes, then check the NDF
-	if nodeUpdate || gatewayUpdate || edUpdate {
+	if nodeUpdate || gatewayUpdate || edUpdate || publicUpdate || clientPortUpdate {
 		jww.TRACE.Printf("UPDATING gateway and node update: %s, %s", msg.ServerAddress,
 			gatewayAddress)
 
@@ -362,11 +546,31 @@ func checkIPAddresses(m *RegistrationImpl, n *node.State,
 			}
 		}
 
+		if publicUpdate && publicAddress != "" && !utils.IsIP(publicAddress) {
+			err := utils.IsDomainName(publicAddress)
+			if err != nil {
+				return err
+			}
+		}
+
 		// Update address information in Storage
 		err := storage.PermissioningDb.UpdateNodeAddresses(nodeHost.GetId(), nodeAddress, gatewayAddress)
 		if err != nil {
 			return err
 		}
+		if publicUpdate {
+			err = storage.PermissioningDb.UpdateNodePublicAddress(nodeHost.GetId(), publicAddress)
+			if err != nil {
+				return err
+			}
+		}
+		if clientPortUpdate {
+			err = storage.PermissioningDb.UpdateNodeGatewayClientPort(nodeHost.GetId(),
+				uint32(gatewayClientPort))
+			if err != nil {
+				return err
+			}
+		}
 
 		m.State.InternalNdfLock.Lock()
 		currentNDF := m.State.GetUnprunedNdf()
@@ -378,6 +582,7 @@ func checkIPAddresses(m *RegistrationImpl, n *node.State,
 		}
 
 		n.SetConnectivity(node.PortUnknown)
+		m.storeConnectivity(n, node.PortUnknown)
 
 		if nodeUpdate {
 			nodeHost.UpdateAddress(nodeAddress)
@@ -409,6 +614,10 @@ func checkIPAddresses(m *RegistrationImpl, n *node.State,
 	return nil
 }
 
+// Time after which the connectivity of a node which failed its check is
+// checked again
+const failedConnectivityRecheck = 4 * time.Minute
+
 // checkConnectivity handles the responses to the different connectivity states
 // of a node. If the returned boolean is true, then the poll should continue.
 // The nodeIpAddr is the IP of the node when it connects to permissioning; it
@@ -416,6 +625,11 @@ func checkIPAddresses(m *RegistrationImpl, n *node.State,
 func (m *RegistrationImpl) checkConnectivity(n *node.State, nodeIpAddr string,
 	activity current.Activity) (bool, error) {
 
+	// Connectivity restored on startup or failed is checked again once it is
+	// due
+	n.CheckReprobe(time.Now())
+	snapshot := n.Snapshot()
+
 	switch n.GetConnectivity() {
 	case node.PortUnknown:
 		err := m.setNodeSequence(n, nodeIpAddr)
@@ -423,48 +637,11 @@ func (m *RegistrationImpl) checkConnectivity(n *node.State, nodeIpAddr string,
 			return false, err
 		}
 		// If we are not sure on whether the port has been forwarded
-		// Ping the server and attempt on that port
-		go func() {
-			var nodePing, gwPing bool
-			if m.params.disablePing {
-				nodePing, gwPing = true, true
-			} else {
-				//ping the node
-				nodeHost, exists := m.Comms.GetHost(n.GetID())
-				_, isOnline := nodeHost.IsOnline()
-				nodePing = exists &&
-					(utils.IsPublicAddress(nodeHost.GetAddress()) == nil || m.params.allowLocalIPs) &&
-					isOnline
-
-				//build gateway host
-				gwID := nodeHost.GetId().DeepCopy()
-				gwID.SetType(id.Gateway)
-				params := connect.GetDefaultHostParams()
-				params.AuthEnabled = false
-				nDb, err := storage.PermissioningDb.GetNodeById(n.GetID())
-				gwHost, err := connect.NewHost(gwID, n.GetGatewayAddress(), []byte(nDb.GatewayCertificate), params)
-
-				//ping the gateway
-				_, isOnline = gwHost.IsOnline()
-				gwPing = (err == nil) &&
-					(utils.IsPublicAddress(n.GetGatewayAddress()) == nil || m.params.allowLocalIPs) &&
-					isOnline
-			}
-
-			if nodePing && gwPing {
-				// If connection was successful, mark the port as forwarded
-				n.SetConnectivity(node.PortSuccessful)
-			} else if !nodePing && gwPing {
-				// If connection to Gateway was successful but Node was not
-				n.SetConnectivity(node.NodePortFailed)
-			} else if nodePing && !gwPing {
-				// If connection to Node was successful but Gateway was not
-				n.SetConnectivity(node.GatewayPortFailed)
-			} else {
-				// If we cannot connect to either address, mark the node as failed
-				n.SetConnectivity(node.PortFailed)
-			}
-		}()
+		// Ping the server and attempt on that port, once a connectivity
+		// check worker is free
+		m.connectivityChecks.submit(n, func() {
+			m.probeConnectivity(n, snapshot)
+		})
 		// Check that the node hasn't errored out
 		if activity == current.ERROR {
 			return true, nil
@@ -481,47 +658,136 @@ func (m *RegistrationImpl) checkConnectivity(n *node.State, nodeIpAddr string,
 		return true, nil
 	case node.NodePortFailed:
 
-		// this will approximately force a recheck of the node state every 3~5
-		// minutes
-		if rand.Uint64()%211 == 13 {
-			n.SetConnectivity(node.PortUnknown)
-		}
+		// Check the node again once the recheck interval has passed
+		n.ScheduleReprobe(time.Now().Add(failedConnectivityRecheck))
 		nodeAddress := "unknown"
-		if nodeHost, exists := m.Comms.GetHost(n.GetID()); exists {
+		if nodeHost, exists := m.Comms.GetHost(snapshot.ID); exists {
 			nodeAddress = nodeHost.GetAddress()
 		}
 		// If only the Node port has been marked as failed,
 		// we send an error informing the node of such
 		return false, errors.Errorf("Node %s at %s cannot be contacted "+
-			"by Permissioning, are ports properly forwarded?", n.GetID(), nodeAddress)
+			"by Permissioning, are ports properly forwarded?", snapshot.ID, nodeAddress)
 	case node.GatewayPortFailed:
-		// this will approximately force a recheck of the node state every 3~5
-		// minutes
-		if rand.Uint64()%211 == 13 {
-			n.SetConnectivity(node.PortUnknown)
-		}
-		gwID := n.GetID().DeepCopy()
+		// Check the node again once the recheck interval has passed
+		n.ScheduleReprobe(time.Now().Add(failedConnectivityRecheck))
+		gwID := snapshot.ID.DeepCopy()
 		gwID.SetType(id.Gateway)
 		// If only the Gateway port has been marked as failed,
 		// we send an error informing the node of such
 		return false, errors.Errorf("Gateway %s with address %s cannot be contacted "+
-			"by Permissioning, are ports properly forwarded?", gwID, n.GetGatewayAddress())
+			"by Permissioning, are ports properly forwarded?", gwID, snapshot.GatewayAddress)
 	case node.PortFailed:
-		// this will approximately force a recheck of the node state every 3~5
-		// minutes
-		if rand.Uint64()%211 == 13 {
-			n.SetConnectivity(node.PortUnknown)
-		}
+		// Check the node again once the recheck interval has passed
+		n.ScheduleReprobe(time.Now().Add(failedConnectivityRecheck))
 		nodeAddress := "unknown"
-		if nodeHost, exists := m.Comms.GetHost(n.GetID()); exists {
+		if nodeHost, exists := m.Comms.GetHost(snapshot.ID); exists {
 			nodeAddress = nodeHost.GetAddress()
 		}
 		// If the port has been marked as failed,
 		// we send an error informing the node of such
 		return false, errors.Errorf("Both Node %s at %s and Gateway with address %s "+
 			"cannot be contacted by Permissioning, are ports properly forwarded?",
-			n.GetID(), nodeAddress, n.GetGatewayAddress())
+			snapshot.ID, nodeAddress, snapshot.GatewayAddress)
 	}
 
 	return false, nil
 }
+
+// probeConnectivity pings the node and its gateway and marks the ports of the
+// node as forwarded or failed.
+func (m *RegistrationImpl) probeConnectivity(n *node.State, snapshot node.Snapshot) {
+	var nodePing, gwPing bool
+	if m.params.disablePing {
+		nodePing, gwPing = true, true
+	} else {
+		// The node and its certificates are needed to ping it; without
+		// them its connectivity is left unknown to be checked again on
+		// its next poll
+		nodeHost, exists := m.Comms.GetHost(snapshot.ID)
+		if !exists {
+			jww.WARN.Printf("Cannot check the connectivity of node %s: "+
+				"it has no host", snapshot.ID)
+			n.SetConnectivity(node.PortUnknown)
+			return
+		}
+		nDb, err := storage.PermissioningDb.GetNodeById(snapshot.ID)
+		if err != nil {
+			jww.WARN.Printf("Cannot check the connectivity of node %s: "+
+				"failed to get it from storage: %+v", snapshot.ID, err)
+			n.SetConnectivity(node.PortUnknown)
+			return
+		}
+
+		//ping the node
+		isOnline := m.probeHost(nodeHost)
+		nodePing = (utils.IsPublicAddress(clientFacingAddress(n, nodeHost)) == nil || m.params.allowLocalIPs) &&
+			isOnline
+
+		//build gateway host
+		gwID := nodeHost.GetId().DeepCopy()
+		gwID.SetType(id.Gateway)
+		params := connect.GetDefaultHostParams()
+		params.AuthEnabled = false
+
+		// Dual address nodes must also be reachable at their
+		// public address
+		if nodePing && snapshot.PublicAddress != "" {
+			nodePing = m.isHostOnline(nodeHost.GetId(),
+				snapshot.PublicAddress, []byte(nDb.NodeCertificate), params)
+		}
+
+		// If the node cannot be contacted where it advertises,
+		// fall back to where its polls come from
+		var nodeAddress string
+		nodePing, nodeAddress = m.resolveNodeAddress(n, nodePing,
+			func(address string) bool {
+				if utils.IsPublicAddress(address) != nil &&
+					!m.params.allowLocalIPs {
+					return false
+				}
+				return m.isHostOnline(nodeHost.GetId(), address,
+					[]byte(nDb.NodeCertificate), params)
+			})
+		if nodePing && nodeAddress != nodeHost.GetAddress() {
+			nodeHost.UpdateAddress(nodeAddress)
+		}
+
+		if snapshot.Gatewayless {
+			// There is no gateway to ping
+			gwPing = true
+		} else {
+			gwHost, err := connect.NewHost(gwID, snapshot.GatewayAddress, []byte(nDb.GatewayCertificate), params)
+
+			//ping the gateway
+			isOnline = err == nil && m.probeHost(gwHost)
+			gwPing = (err == nil) &&
+				(utils.IsPublicAddress(snapshot.GatewayAddress) == nil || m.params.allowLocalIPs) &&
+				isOnline
+
+			// Gateways with a separate client port must also be
+			// reachable by clients on it
+			if gwPing && snapshot.GatewayClientPort != 0 {
+				gwPing = m.isHostOnline(gwID, snapshot.GatewayClientAddress(),
+					[]byte(nDb.GatewayCertificate), params)
+			}
+		}
+	}
+
+	var connectivity uint32
+	if nodePing && gwPing {
+		// If connection was successful, mark the port as forwarded
+		connectivity = node.PortSuccessful
+	} else if !nodePing && gwPing {
+		// If connection to Gateway was successful but Node was not
+		connectivity = node.NodePortFailed
+	} else if nodePing && !gwPing {
+		// If connection to Node was successful but Gateway was not
+		connectivity = node.GatewayPortFailed
+	} else {
+		// If we cannot connect to either address, mark the node as failed
+		connectivity = node.PortFailed
+	}
+	n.SetConnectivity(connectivity)
+	m.storeConnectivity(n, connectivity)
+}
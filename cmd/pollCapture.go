@@ -0,0 +1,175 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Handles capturing the raw poll messages of a sampled fraction of polls, or
+// of every poll of targeted nodes, for debugging
+
+package cmd
+
+import (
+	"github.com/pkg/errors"
+	jww "github.com/spf13/jwalterweatherman"
+	pb "gitlab.com/elixxir/comms/mixmessages"
+	"gitlab.com/xx_network/comms/connect"
+	"gitlab.com/xx_network/primitives/id"
+	"google.golang.org/protobuf/proto"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Number of captured polls kept when no size is given
+const defaultPollCaptureSize = 256
+
+// CapturedPoll is a poll message as a node sent it.
+type CapturedPoll struct {
+	Node     *id.ID
+	Received time.Time
+	// The serialized poll message, including any fields this server does not
+	// know
+	Message []byte
+}
+
+// pollCapture keeps the most recently captured polls in a ring buffer
+type pollCapture struct {
+	// Fraction of all polls which are captured
+	fraction float64
+
+	// Nodes whose every poll is captured, and their number, which is read
+	// without the lock so that polls are not slowed while nothing is captured
+	nodes    map[id.ID]bool
+	numNodes int32
+
+	// Captured polls and the index the next poll is written to
+	polls []CapturedPoll
+	next  int
+	full  bool
+
+	mux sync.Mutex
+}
+
+// newPollCapture returns a poll capture which captures the fraction of polls
+// and keeps at most size of them. A size of 0 selects the default.
+func newPollCapture(fraction float64, size int) (*pollCapture, error) {
+	if fraction < 0 || fraction > 1 {
+		return nil, errors.Errorf("Poll capture fraction %f is not between "+
+			"0 and 1", fraction)
+	} else if size < 0 {
+		return nil, errors.Errorf("Poll capture size %d is negative", size)
+	} else if size == 0 {
+		size = defaultPollCaptureSize
+	}
+	if fraction > 0 {
+		jww.WARN.Printf("Capturing %.4f of poll messages for debugging",
+			fraction)
+	}
+	return &pollCapture{
+		fraction: fraction,
+		nodes:    make(map[id.ID]bool),
+		polls:    make([]CapturedPoll, size),
+	}, nil
+}
+
+// capture stores the poll if it is sampled or the node is targeted.
+func (pc *pollCapture) capture(nodeID *id.ID, msg *pb.PermissioningPoll,
+	received time.Time) {
+	if pc == nil {
+		return
+	}
+	sampled := pc.fraction > 0 && rand.Float64() < pc.fraction
+	if !sampled && atomic.LoadInt32(&pc.numNodes) == 0 {
+		return
+	}
+
+	pc.mux.Lock()
+	defer pc.mux.Unlock()
+	if !sampled && !pc.nodes[*nodeID] {
+		return
+	}
+
+	data, err := proto.Marshal(msg)
+	if err != nil {
+		jww.WARN.Printf("Failed to capture poll from node %s: %+v",
+			nodeID, err)
+		return
+	}
+	pc.polls[pc.next] = CapturedPoll{
+		Node:     nodeID.DeepCopy(),
+		Received: received,
+		Message:  data,
+	}
+	pc.next++
+	if pc.next == len(pc.polls) {
+		pc.next = 0
+		pc.full = true
+	}
+}
+
+// setNode starts capturing every poll of the node when enabled is true, and
+// stops otherwise.
+func (pc *pollCapture) setNode(nodeID *id.ID, enabled bool) {
+	pc.mux.Lock()
+	defer pc.mux.Unlock()
+	if enabled {
+		pc.nodes[*nodeID] = true
+	} else {
+		delete(pc.nodes, *nodeID)
+	}
+	atomic.StoreInt32(&pc.numNodes, int32(len(pc.nodes)))
+}
+
+// get returns the captured polls of the node, or of every node if it is nil,
+// oldest first.
+func (pc *pollCapture) get(nodeID *id.ID) []CapturedPoll {
+	pc.mux.Lock()
+	defer pc.mux.Unlock()
+
+	ordered := pc.polls[:pc.next]
+	if pc.full {
+		ordered = append(append([]CapturedPoll{}, pc.polls[pc.next:]...),
+			pc.polls[:pc.next]...)
+	}
+
+	var polls []CapturedPoll
+	for _, p := range ordered {
+		if nodeID == nil || p.Node.Cmp(nodeID) {
+			polls = append(polls, p)
+		}
+	}
+	return polls
+}
+
+// SetPollCapture starts capturing every poll message of the node when enabled
+// is true, and stops otherwise.
+func (m *RegistrationImpl) SetPollCapture(auth *connect.Auth, nodeID *id.ID,
+	enabled bool) error {
+	if err := checkAdminAuth(auth); err != nil {
+		return err
+	}
+	if nodeID == nil {
+		return errors.New("Cannot set poll capture of a nil node ID")
+	} else if m.pollCapture == nil {
+		return errors.New("Poll capture is not set up")
+	}
+	m.pollCapture.setNode(nodeID, enabled)
+	jww.INFO.Printf("Poll capture of node %s set to %t", nodeID, enabled)
+	return nil
+}
+
+// GetCapturedPolls returns the captured poll messages of the node, or of every
+// node if it is nil, oldest first.
+func (m *RegistrationImpl) GetCapturedPolls(auth *connect.Auth,
+	nodeID *id.ID) ([]CapturedPoll, error) {
+	if err := checkAdminAuth(auth); err != nil {
+		return nil, err
+	}
+	if m.pollCapture == nil {
+		return nil, nil
+	}
+	return m.pollCapture.get(nodeID), nil
+}
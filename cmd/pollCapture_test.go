@@ -0,0 +1,141 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package cmd
+
+import (
+	pb "gitlab.com/elixxir/comms/mixmessages"
+	"gitlab.com/xx_network/comms/connect"
+	"gitlab.com/xx_network/primitives/id"
+	"google.golang.org/protobuf/proto"
+	"testing"
+	"time"
+)
+
+// Tests that every poll of a node targeted for capture is recorded with its
+// raw message, including fields unknown to this server, while the polls of
+// other nodes are not.
+func TestRegistrationImpl_SetPollCapture(t *testing.T) {
+	state, host := newPolicyTestState(t)
+	other := id.NewIdFromString("other", id.Node, t)
+	if err := state.GetNodeMap().AddNode(other, "", "", "", 2); err != nil {
+		t.Fatalf("Failed to add node: %+v", err)
+	}
+	otherHost, err := connect.NewHost(other, "", nil, connect.GetDefaultHostParams())
+	if err != nil {
+		t.Fatalf("Failed to create host: %+v", err)
+	}
+
+	capture, err := newPollCapture(0, 0)
+	if err != nil {
+		t.Fatalf("Failed to create poll capture: %+v", err)
+	}
+	impl := &RegistrationImpl{State: state, params: &Params{},
+		pollCapture: capture}
+
+	permHost, err := connect.NewHost(&id.Permissioning, "", nil,
+		connect.GetDefaultHostParams())
+	if err != nil {
+		t.Fatalf("Failed to create host: %+v", err)
+	}
+	admin := &connect.Auth{IsAuthenticated: true, Sender: permHost}
+	if err = impl.SetPollCapture(&connect.Auth{IsAuthenticated: true, Sender: host},
+		host.GetId(), true); err == nil {
+		t.Errorf("Node was able to capture polls.")
+	}
+	if err = impl.SetPollCapture(admin, host.GetId(), true); err != nil {
+		t.Fatalf("Failed to capture polls of node: %+v", err)
+	}
+
+	// The polls fail the version check once captured, which is enough here
+	for i := 0; i < 3; i++ {
+		msg := &pb.PermissioningPoll{Activity: uint32(i),
+			ServerVersion: "debug"}
+		msg.ProtoReflect().SetUnknown([]byte{0x80, 0x01, byte(i)})
+		_, _ = impl.Poll(msg, &connect.Auth{IsAuthenticated: true, Sender: host})
+		_, _ = impl.Poll(msg, &connect.Auth{IsAuthenticated: true,
+			Sender: otherHost})
+	}
+
+	polls, err := impl.GetCapturedPolls(admin, nil)
+	if err != nil {
+		t.Fatalf("Failed to get captured polls: %+v", err)
+	}
+	if len(polls) != 3 {
+		t.Fatalf("Expected 3 captured polls, received %d.", len(polls))
+	}
+	for i, p := range polls {
+		if !p.Node.Cmp(host.GetId()) {
+			t.Errorf("Poll %d captured from untargeted node %s.", i, p.Node)
+		}
+		msg := &pb.PermissioningPoll{}
+		if err = proto.Unmarshal(p.Message, msg); err != nil {
+			t.Fatalf("Failed to decode captured poll %d: %+v", i, err)
+		}
+		unknown := msg.ProtoReflect().GetUnknown()
+		if msg.Activity != uint32(i) || msg.ServerVersion != "debug" ||
+			len(unknown) != 3 || unknown[2] != byte(i) {
+			t.Errorf("Captured poll %d does not match the sent poll: %+v",
+				i, msg)
+		}
+	}
+
+	// Capture stops once disabled
+	if err = impl.SetPollCapture(admin, host.GetId(), false); err != nil {
+		t.Fatalf("Failed to stop capturing polls of node: %+v", err)
+	}
+	_, _ = impl.Poll(&pb.PermissioningPoll{},
+		&connect.Auth{IsAuthenticated: true, Sender: host})
+	if polls, _ = impl.GetCapturedPolls(admin, host.GetId()); len(polls) != 3 {
+		t.Errorf("Poll captured after capture was stopped.")
+	}
+	if polls, _ = impl.GetCapturedPolls(admin, other); len(polls) != 0 {
+		t.Errorf("Polls of untargeted node were captured: %d", len(polls))
+	}
+}
+
+// Tests that sampled polls are captured from every node and that only the
+// most recent polls are kept, oldest first.
+func TestPollCapture_Sampled(t *testing.T) {
+	capture, err := newPollCapture(1, 4)
+	if err != nil {
+		t.Fatalf("Failed to create poll capture: %+v", err)
+	}
+	start := time.Now()
+	for i := 0; i < 10; i++ {
+		nid := id.NewIdFromUInt(uint64(i), id.Node, t)
+		capture.capture(nid, &pb.PermissioningPoll{Activity: uint32(i)},
+			start.Add(time.Duration(i)*time.Second))
+	}
+
+	polls := capture.get(nil)
+	if len(polls) != 4 {
+		t.Fatalf("Expected 4 captured polls, received %d.", len(polls))
+	}
+	for i, p := range polls {
+		expected := id.NewIdFromUInt(uint64(6+i), id.Node, t)
+		if !p.Node.Cmp(expected) ||
+			!p.Received.Equal(start.Add(time.Duration(6+i)*time.Second)) {
+			t.Errorf("Captured poll %d is from %s at %s.", i, p.Node,
+				p.Received)
+		}
+	}
+
+	// Nothing is captured while nothing is sampled or targeted
+	idle, _ := newPollCapture(0, 4)
+	idle.capture(id.NewIdFromUInt(1, id.Node, t), &pb.PermissioningPoll{},
+		start)
+	if polls = idle.get(nil); len(polls) != 0 {
+		t.Errorf("Poll captured while capture is idle.")
+	}
+
+	for _, fraction := range []float64{-0.1, 1.5} {
+		if _, err = newPollCapture(fraction, 0); err == nil {
+			t.Errorf("Fraction %f was accepted.", fraction)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package cmd
+
+// Contains the early validation of the error payloads of polls reporting an
+// ERROR activity
+
+import (
+	"github.com/pkg/errors"
+	pb "gitlab.com/elixxir/comms/mixmessages"
+	"gitlab.com/elixxir/primitives/current"
+	"gitlab.com/elixxir/registration/storage/node"
+	"gitlab.com/xx_network/primitives/id"
+)
+
+// validateErrorPayload checks the structure of the error payload of a poll
+// reporting an ERROR activity, so that malformed payloads are rejected before
+// the poll goes on to take any locks. The signature itself is verified later
+// by verifyError. Polls reporting any other activity are not checked.
+func validateErrorPayload(m *RegistrationImpl, msg *pb.PermissioningPoll,
+	snapshot node.Snapshot) error {
+	if current.Activity(msg.Activity) != current.ERROR {
+		return nil
+	}
+
+	roundErr := msg.Error
+	if roundErr == nil {
+		return errors.New("Error payload is nil")
+	}
+
+	// The error must name the node which signed it
+	if len(roundErr.NodeId) == 0 {
+		return errors.New("Error payload has no node ID")
+	}
+	errorNodeId, err := id.Unmarshal(roundErr.NodeId)
+	if err != nil {
+		return errors.Errorf("Error payload has an unparseable node ID: %+v",
+			err)
+	}
+	if errorNodeId.GetType() != id.Node {
+		return errors.Errorf("Error payload node ID %s is not a node",
+			errorNodeId)
+	}
+
+	// The error may only be about the round the node is participating in
+	if roundErr.Id != 0 {
+		hasRound, r := snapshot.InRound()
+		if !hasRound {
+			return errors.Errorf("Error payload is for round %d while the "+
+				"node is not in a round", roundErr.Id)
+		} else if roundErr.Id != uint64(r.GetRoundID()) {
+			return errors.Errorf("Error payload is for round %d while the "+
+				"node is in round %d", roundErr.Id, r.GetRoundID())
+		}
+	}
+
+	// The signature must be complete for the key of the node which signed it
+	sig := roundErr.GetSignature()
+	if len(sig.GetNonce()) == 0 || len(sig.GetSignature()) == 0 {
+		return errors.Errorf("Error payload from %s is not signed",
+			errorNodeId)
+	}
+	h, ok := m.Comms.GetHost(errorNodeId)
+	if !ok {
+		return errors.Errorf("Error payload is from unknown node %s",
+			errorNodeId)
+	}
+	if pk := h.GetPubKey(); pk != nil {
+		if size := pk.GetGoRSA().Size(); len(sig.GetSignature()) != size {
+			return errors.Errorf("Error payload signature from %s is %d "+
+				"bytes, expected %d", errorNodeId, len(sig.GetSignature()),
+				size)
+		}
+	}
+
+	return nil
+}
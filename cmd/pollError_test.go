@@ -0,0 +1,226 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package cmd
+
+import (
+	pb "gitlab.com/elixxir/comms/mixmessages"
+	"gitlab.com/elixxir/comms/registration"
+	"gitlab.com/elixxir/comms/testkeys"
+	"gitlab.com/elixxir/primitives/current"
+	"gitlab.com/elixxir/primitives/version"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/elixxir/registration/storage/node"
+	"gitlab.com/elixxir/registration/storage/round"
+	"gitlab.com/xx_network/comms/connect"
+	"gitlab.com/xx_network/comms/signature"
+	"gitlab.com/xx_network/crypto/signature/rsa"
+	"gitlab.com/xx_network/primitives/id"
+	"gitlab.com/xx_network/primitives/ndf"
+	"gitlab.com/xx_network/primitives/region"
+	"gitlab.com/xx_network/primitives/utils"
+	"google.golang.org/protobuf/proto"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newErrorPayloadTestImpl returns a registration impl holding a node in round
+// 5 whose host is added with its key, and a valid error payload signed by the
+// node for that round.
+func newErrorPayloadTestImpl(t *testing.T) (*RegistrationImpl, *id.ID, *pb.RoundError) {
+	var err error
+	var closeDb func() error
+	storage.PermissioningDb, closeDb, err = storage.NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("Failed to create database: %+v", err)
+	}
+	t.Cleanup(func() { _ = closeDb() })
+	state, err := storage.NewState(getTestKey(), 8, "", "", region.GetCountryBins())
+	if err != nil {
+		t.Fatalf("Failed to create state: %+v", err)
+	}
+
+	testVersion, _ := version.ParseVersion("0.0.0")
+	ndfReady := uint32(0)
+	impl := &RegistrationImpl{
+		State:    state,
+		NdfReady: &ndfReady,
+		params: &Params{
+			minGatewayVersion:    testVersion,
+			minServerVersion:     testVersion,
+			earlyErrorValidation: true,
+		},
+		Comms: &registration.Comms{
+			ProtoComms: &connect.ProtoComms{
+				Manager: connect.NewManagerTesting(t),
+			},
+		},
+	}
+
+	cert, err := utils.ReadFile(testkeys.GetNodeCertPath())
+	if err != nil {
+		t.Fatalf("Failed to read node cert: %+v", err)
+	}
+	keyPem, err := utils.ReadFile(testkeys.GetNodeKeyPath())
+	if err != nil {
+		t.Fatalf("Failed to read node key: %+v", err)
+	}
+	key, err := rsa.LoadPrivateKeyFromPem(keyPem)
+	if err != nil {
+		t.Fatalf("Failed to load node key: %+v", err)
+	}
+
+	nid := id.NewIdFromString("node", id.Node, t)
+	params := connect.GetDefaultHostParams()
+	params.AuthEnabled = false
+	if _, err = impl.Comms.AddHost(nid, "0.0.0.0:8000", cert, params); err != nil {
+		t.Fatalf("Failed to add host: %+v", err)
+	}
+	if err = state.GetNodeMap().AddNode(nid, "", "", "", 0); err != nil {
+		t.Fatalf("Failed to add node: %+v", err)
+	}
+	r, err := round.NewStateMap().AddRound(5, 4, 8, 5*time.Minute,
+		connect.NewCircuit([]*id.ID{nid}))
+	if err != nil {
+		t.Fatalf("Failed to add round: %+v", err)
+	}
+	if err = state.GetNodeMap().GetNode(nid).SetRound(r); err != nil {
+		t.Fatalf("Failed to set round: %+v", err)
+	}
+
+	roundErr := &pb.RoundError{Id: 5, NodeId: nid.Marshal(), Error: "test err"}
+	if err = signature.SignRsa(roundErr, key); err != nil {
+		t.Fatalf("Failed to sign error: %+v", err)
+	}
+	return impl, nid, roundErr
+}
+
+// Tests that validateErrorPayload accepts a well formed error payload and
+// polls not reporting an error, and rejects each kind of malformed payload
+// with a message naming the problem.
+func TestValidateErrorPayload(t *testing.T) {
+	impl, nid, valid := newErrorPayloadTestImpl(t)
+	snapshot := impl.State.GetNodeMap().GetNode(nid).Snapshot()
+
+	// modified returns a copy of the valid payload changed by the function
+	modified := func(modify func(e *pb.RoundError)) *pb.RoundError {
+		e := proto.Clone(valid).(*pb.RoundError)
+		modify(e)
+		return e
+	}
+
+	tests := []struct {
+		name     string
+		activity current.Activity
+		payload  *pb.RoundError
+		expected string
+	}{
+		{"Valid", current.ERROR, valid, ""},
+		{"NotError", current.WAITING, nil, ""},
+		{"NoRound", current.ERROR,
+			modified(func(e *pb.RoundError) { e.Id = 0 }), ""},
+		{"NilPayload", current.ERROR, nil, "Error payload is nil"},
+		{"NilNodeId", current.ERROR,
+			modified(func(e *pb.RoundError) { e.NodeId = nil }),
+			"Error payload has no node ID"},
+		{"UnparseableNodeId", current.ERROR,
+			modified(func(e *pb.RoundError) { e.NodeId = []byte{1, 2, 3} }),
+			"Error payload has an unparseable node ID"},
+		{"GatewayNodeId", current.ERROR,
+			modified(func(e *pb.RoundError) {
+				e.NodeId = nid.Marshal()
+				e.NodeId[id.ArrIDLen-1] = byte(id.Gateway)
+			}),
+			"is not a node"},
+		{"UnknownNode", current.ERROR,
+			modified(func(e *pb.RoundError) {
+				e.NodeId = id.NewIdFromString("other", id.Node, t).Marshal()
+			}),
+			"Error payload is from unknown node"},
+		{"BadRoundId", current.ERROR,
+			modified(func(e *pb.RoundError) { e.Id = 6 }),
+			"Error payload is for round 6 while the node is in round 5"},
+		{"Unsigned", current.ERROR,
+			modified(func(e *pb.RoundError) { e.Signature = nil }),
+			"is not signed"},
+		{"NoNonce", current.ERROR,
+			modified(func(e *pb.RoundError) { e.Signature.Nonce = nil }),
+			"is not signed"},
+		{"TruncatedSignature", current.ERROR,
+			modified(func(e *pb.RoundError) {
+				e.Signature.Signature = e.Signature.Signature[:10]
+			}),
+			"signature from " + nid.String() + " is 10 bytes"},
+	}
+
+	for _, tt := range tests {
+		msg := &pb.PermissioningPoll{
+			Activity: uint32(tt.activity),
+			Error:    tt.payload,
+		}
+		err := validateErrorPayload(impl, msg, snapshot)
+		if tt.expected == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %+v", tt.name, err)
+			}
+		} else if err == nil || !strings.Contains(err.Error(), tt.expected) {
+			t.Errorf("%s: expected error containing %q, received: %v",
+				tt.name, tt.expected, err)
+		}
+	}
+
+	// A payload for a round when the node is in none is rejected
+	idle := impl.State.GetNodeMap().GetNode(nid)
+	idle.ClearRound()
+	msg := &pb.PermissioningPoll{Activity: uint32(current.ERROR), Error: valid}
+	err := validateErrorPayload(impl, msg, idle.Snapshot())
+	if err == nil || !strings.Contains(err.Error(), "node is not in a round") {
+		t.Errorf("Payload for a round was accepted from a node in none: %v",
+			err)
+	}
+}
+
+// Tests that Poll rejects a malformed error payload with its specific message
+// before any later check, when early validation is enabled, and otherwise
+// leaves it to be caught later.
+func TestRegistrationImpl_Poll_EarlyErrorValidation(t *testing.T) {
+	impl, nid, valid := newErrorPayloadTestImpl(t)
+	nodeHost, err := connect.NewHost(nid, "0.0.0.0:8000", nil,
+		connect.GetDefaultHostParams())
+	if err != nil {
+		t.Fatalf("Failed to create host: %+v", err)
+	}
+	auth := &connect.Auth{IsAuthenticated: true, Sender: nodeHost}
+	impl.State.GetNodeMap().GetNode(nid).SetConnectivity(node.PortSuccessful)
+
+	payload := proto.Clone(valid).(*pb.RoundError)
+	payload.NodeId = nil
+	msg := &pb.PermissioningPoll{
+		Full:          &pb.NDFHash{Hash: []byte("test")},
+		Partial:       &pb.NDFHash{Hash: []byte("test")},
+		Activity:      uint32(current.ERROR),
+		Error:         payload,
+		ServerVersion: "0.0.0",
+	}
+
+	_, err = impl.Poll(msg, auth)
+	if err == nil || !strings.Contains(err.Error(),
+		"A malformed error was received from "+nid.String()+
+			": Error payload has no node ID") {
+		t.Errorf("Malformed payload was not rejected early: %v", err)
+	}
+
+	// With early validation disabled the poll goes on to the NDF check, as
+	// the NDF is not ready
+	impl.params.earlyErrorValidation = false
+	_, err = impl.Poll(msg, auth)
+	if err == nil || err.Error() != ndf.NO_NDF {
+		t.Errorf("Expected the poll to fail on the NDF check, received: %v",
+			err)
+	}
+}
@@ -0,0 +1,163 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Handles limiting the number of polls handled at once and the rate at which
+// the nodes of each application poll
+
+package cmd
+
+import (
+	"github.com/pkg/errors"
+	pb "gitlab.com/elixxir/comms/mixmessages"
+	"gitlab.com/xx_network/primitives/id"
+	"math"
+	"sync"
+	"time"
+)
+
+// Error returned to polls past the concurrent poll limit, telling the node to
+// poll again later
+const TooManyPolls = "Permissioning is handling too many polls, retry later"
+
+// limitPolls handles the poll if fewer than the maximum number of concurrent
+// polls are being handled. Otherwise, the poll is answered at once with
+// TooManyPolls instead of waiting for a slot.
+func (m *RegistrationImpl) limitPolls(
+	handle func() (*pb.PermissionPollResponse, error)) (*pb.PermissionPollResponse, error) {
+	if m.pollSlots == nil {
+		return handle()
+	}
+
+	select {
+	case m.pollSlots <- struct{}{}:
+	default:
+		return &pb.PermissionPollResponse{}, errors.New(TooManyPolls)
+	}
+	defer func() { <-m.pollSlots }()
+	return handle()
+}
+
+// Error returned to polls past their application's poll rate limit, telling
+// the node to poll again later
+const ApplicationPollsLimited = "Polls of the node's application are over " +
+	"its rate limit, retry later"
+
+// appPollLimiter limits the rate at which the nodes of each application poll,
+// counting the polls of all of an application's nodes together against one
+// token bucket. When the bucket runs low, the nodes which used less than
+// their share of it are let through ahead of those which used more, so that
+// one node polling too often cannot lock its siblings out.
+type appPollLimiter struct {
+	// Polls per second each application's bucket leaks, and the most polls
+	// it holds
+	rate  float64
+	burst float64
+
+	buckets map[uint64]*appPollBucket
+	mux     sync.Mutex
+}
+
+// appPollBucket counts the recent polls of an application's nodes
+type appPollBucket struct {
+	level      float64
+	lastUpdate time.Time
+
+	// Recent polls of each node of the application which polled within the
+	// time the bucket takes to leak empty
+	nodes map[id.ID]*appNodePolls
+}
+
+// appNodePolls counts the recent polls of one node of an application
+type appNodePolls struct {
+	level    float64
+	lastPoll time.Time
+}
+
+// newAppPollLimiter returns a limiter letting through the given number of
+// polls per second from each application, up to burst at once. A burst of 0
+// holds one second of polls. No limiter is returned for a rate of 0.
+func newAppPollLimiter(rate float64, burst uint) (*appPollLimiter, error) {
+	if rate < 0 {
+		return nil, errors.Errorf("Application poll rate %f is negative", rate)
+	} else if rate == 0 {
+		return nil, nil
+	}
+
+	b := float64(burst)
+	if burst == 0 {
+		b = math.Max(1, math.Ceil(rate))
+	}
+	return &appPollLimiter{
+		rate:    rate,
+		burst:   b,
+		buckets: make(map[uint64]*appPollBucket),
+	}, nil
+}
+
+// allow counts the poll of the node of the given application at the given
+// time, returning false if it is over the application's limit. A poll is
+// over the limit if the application's bucket is full, or if the node used
+// more than its share of the bucket and what is left is held for the
+// application's other recently polling nodes. A nil limiter allows all polls.
+func (apl *appPollLimiter) allow(appID uint64, nid *id.ID, now time.Time) bool {
+	if apl == nil {
+		return true
+	}
+
+	apl.mux.Lock()
+	defer apl.mux.Unlock()
+	b, exists := apl.buckets[appID]
+	if !exists {
+		b = &appPollBucket{lastUpdate: now, nodes: make(map[id.ID]*appNodePolls)}
+		apl.buckets[appID] = b
+	}
+	apl.leak(b, now)
+
+	np, exists := b.nodes[*nid]
+	if !exists {
+		np = &appNodePolls{}
+		b.nodes[*nid] = np
+	}
+	np.lastPoll = now
+
+	// Tokens held for the nodes which have not used up their share
+	share := apl.burst / float64(len(b.nodes))
+	var held float64
+	for other, onp := range b.nodes {
+		if other != *nid && onp.level < share {
+			held += share - onp.level
+		}
+	}
+
+	if b.level+1 > apl.burst || (np.level+1 > share && b.level+1+held > apl.burst) {
+		return false
+	}
+	b.level++
+	np.level++
+	return true
+}
+
+// leak removes the polls leaked from the bucket since it was last updated,
+// sharing the leak out among its nodes, and forgets the nodes which have not
+// polled for as long as the full bucket takes to leak.
+func (apl *appPollLimiter) leak(b *appPollBucket, now time.Time) {
+	leaked := now.Sub(b.lastUpdate).Seconds() * apl.rate
+	if leaked <= 0 {
+		return
+	}
+	b.lastUpdate = now
+	b.level = math.Max(0, b.level-leaked)
+
+	window := time.Duration(apl.burst / apl.rate * float64(time.Second))
+	nodeLeaked := leaked / float64(len(b.nodes))
+	for nid, np := range b.nodes {
+		np.level = math.Max(0, np.level-nodeLeaked)
+		if np.level == 0 && now.Sub(np.lastPoll) >= window {
+			delete(b.nodes, nid)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package cmd
+
+import (
+	pb "gitlab.com/elixxir/comms/mixmessages"
+	"gitlab.com/elixxir/primitives/current"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/xx_network/comms/connect"
+	"gitlab.com/xx_network/primitives/id"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Tests that many concurrent polls are handled at most the limit at a time,
+// with the overflow told to retry later.
+func TestRegistrationImpl_limitPolls(t *testing.T) {
+	const limit, polls = 5, 50
+	impl := &RegistrationImpl{pollSlots: make(chan struct{}, limit)}
+
+	var active, maxActive, handled, retried int32
+	release := make(chan struct{})
+	entered := make(chan struct{}, polls)
+	handle := func() (*pb.PermissionPollResponse, error) {
+		n := atomic.AddInt32(&active, 1)
+		for m := atomic.LoadInt32(&maxActive); n > m; m = atomic.LoadInt32(&maxActive) {
+			if atomic.CompareAndSwapInt32(&maxActive, m, n) {
+				break
+			}
+		}
+		entered <- struct{}{}
+		<-release
+		atomic.AddInt32(&active, -1)
+		atomic.AddInt32(&handled, 1)
+		return &pb.PermissionPollResponse{}, nil
+	}
+
+	var wg sync.WaitGroup
+	var overflow sync.WaitGroup
+	overflow.Add(polls - limit)
+	for i := 0; i < polls; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := impl.limitPolls(handle)
+			if err != nil {
+				if err.Error() != TooManyPolls {
+					t.Errorf("Unexpected error for an overflow poll: %+v", err)
+				}
+				atomic.AddInt32(&retried, 1)
+				overflow.Done()
+			}
+		}()
+	}
+
+	// Hold the handled polls until every overflow poll was answered, so that
+	// none of them could have taken a freed slot
+	for i := 0; i < limit; i++ {
+		<-entered
+	}
+	overflow.Wait()
+	close(release)
+	wg.Wait()
+
+	if maxActive > limit {
+		t.Errorf("%d polls were handled at once, past the limit of %d.",
+			maxActive, limit)
+	}
+	if handled != limit || retried != polls-limit {
+		t.Errorf("Expected %d polls handled and %d told to retry, "+
+			"received %d and %d.", limit, polls-limit, handled, retried)
+	}
+	if len(impl.pollSlots) != 0 {
+		t.Errorf("%d poll slots were not released.", len(impl.pollSlots))
+	}
+}
+
+// Tests that Poll() answers at once with the retry response while every slot
+// is held.
+func TestRegistrationImpl_Poll_TooManyPolls(t *testing.T) {
+	impl := &RegistrationImpl{pollSlots: make(chan struct{}, 1)}
+	impl.pollSlots <- struct{}{}
+
+	response, err := impl.Poll(&pb.PermissioningPoll{},
+		&connect.Auth{IsAuthenticated: true})
+	if err == nil || err.Error() != TooManyPolls || response == nil {
+		t.Errorf("Poll past the limit was not told to retry later: %v, %+v",
+			response, err)
+	}
+}
+
+// Tests that the polls of an application's nodes are limited together, that
+// nodes which used less than their share are let through first once the
+// bucket leaks, and that other applications are not limited by it.
+func TestAppPollLimiter_allow(t *testing.T) {
+	apl, err := newAppPollLimiter(1, 8)
+	if err != nil {
+		t.Fatalf("Failed to create limiter: %+v", err)
+	}
+	nodes := make([]*id.ID, 4)
+	for i := range nodes {
+		nodes[i] = id.NewIdFromUInt(uint64(i), id.Node, t)
+	}
+	now := time.Now()
+
+	// A node polling alone may use the whole bucket
+	for i := 0; i < 8; i++ {
+		if !apl.allow(1, nodes[0], now) {
+			t.Fatalf("Poll %d of the only polling node was throttled.", i)
+		}
+	}
+	for _, nid := range nodes {
+		if apl.allow(1, nid, now) {
+			t.Errorf("Poll of node %s allowed past the application limit.", nid)
+		}
+	}
+	if !apl.allow(2, nodes[0], now) {
+		t.Errorf("Poll of another application was throttled.")
+	}
+
+	// Once half the bucket leaks, it goes to the nodes under their share of
+	// two polls, not the node which used the whole bucket
+	now = now.Add(4 * time.Second)
+	if apl.allow(1, nodes[0], now) {
+		t.Errorf("Node over its share was let through ahead of its siblings.")
+	}
+	allowed := make(map[int]int)
+	for i := 0; i < 3; i++ {
+		for j := 1; j < len(nodes); j++ {
+			if apl.allow(1, nodes[j], now) {
+				allowed[j]++
+			}
+		}
+	}
+	if allowed[1] != 2 || allowed[2] != 1 || allowed[3] != 1 {
+		t.Errorf("Unexpected polls allowed: %v", allowed)
+	}
+
+	// Nodes which stop polling are forgotten
+	now = now.Add(time.Minute)
+	apl.allow(1, nodes[0], now)
+	if n := len(apl.buckets[1].nodes); n != 1 {
+		t.Errorf("%d nodes tracked after the others stopped polling.", n)
+	}
+
+	if _, err = newAppPollLimiter(-1, 0); err == nil {
+		t.Errorf("Negative poll rate accepted.")
+	}
+	if apl, err = newAppPollLimiter(0, 10); apl != nil || err != nil {
+		t.Errorf("Limiter created without a rate: %v, %+v", apl, err)
+	}
+	if !apl.allow(1, nodes[0], now) {
+		t.Errorf("Nil limiter throttled a poll.")
+	}
+	if apl, _ = newAppPollLimiter(2.5, 0); apl.burst != 3 {
+		t.Errorf("Default burst is %f, expected 3.", apl.burst)
+	}
+}
+
+// Tests that polls of nodes of one application past its limit are answered
+// with ApplicationPollsLimited, while nodes of other applications still poll.
+func TestRegistrationImpl_Poll_AppPollLimit(t *testing.T) {
+	var err error
+	dblck.Lock()
+	defer dblck.Unlock()
+
+	var dc func() error
+	storage.PermissioningDb, dc, err = storage.NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	defer func() { _ = dc() }()
+	err = storage.PermissioningDb.InsertEphemeralLength(
+		&storage.EphemeralLength{Length: 8, Timestamp: time.Now()})
+	if err != nil {
+		t.Errorf("Failed to insert ephemeral length into database: %+v", err)
+	}
+
+	impl, err := StartRegistration(testParams)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer impl.Comms.Shutdown()
+	atomic.CompareAndSwapUint32(impl.NdfReady, 0, 1)
+	impl.appPollLimits, _ = newAppPollLimiter(0.001, 2)
+
+	// Banned nodes are answered without a full network; the first three are
+	// of the same application
+	auths := make([]*connect.Auth, 4)
+	for i := range auths {
+		nid := id.NewIdFromUInt(uint64(i), id.Node, t)
+		appID := uint64(1)
+		if i == 3 {
+			appID = 2
+		}
+		if err = impl.State.GetNodeMap().AddNode(nid, "", "", "", appID); err != nil {
+			t.Fatalf("Failed to add node: %+v", err)
+		}
+		if _, err = impl.State.GetNodeMap().GetNode(nid).Ban(); err != nil {
+			t.Fatalf("Failed to ban node: %+v", err)
+		}
+		host, _ := connect.NewHost(nid, "test", nil, connect.GetDefaultHostParams())
+		auths[i] = &connect.Auth{IsAuthenticated: true, Sender: host}
+	}
+	msg := &pb.PermissioningPoll{
+		Full:           &pb.NDFHash{Hash: []byte("test")},
+		Partial:        &pb.NDFHash{Hash: []byte("test")},
+		Activity:       uint32(current.WAITING),
+		GatewayVersion: "1.1.0",
+		ServerVersion:  "1.1.0",
+	}
+
+	for i, auth := range auths {
+		_, err = impl.Poll(msg, auth)
+		if i == 2 {
+			if err == nil || err.Error() != ApplicationPollsLimited {
+				t.Errorf("Poll past the application limit was not "+
+					"throttled: %+v", err)
+			}
+		} else if err != nil && err.Error() == ApplicationPollsLimited {
+			t.Errorf("Poll %d within the application limit was throttled.", i)
+		}
+	}
+}
@@ -790,6 +790,75 @@ func TestCheckVersion_InvalidVersionGatewayAndServer(t *testing.T) {
 	}
 }
 
+// Tests that recordVersions stores the versions reported across polls and
+// accumulates a history of their changes.
+func TestRecordVersions(t *testing.T) {
+	var err error
+	var closeDb func() error
+	storage.PermissioningDb, closeDb, err = storage.NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("Failed to create database: %+v", err)
+	}
+	t.Cleanup(func() { _ = closeDb() })
+
+	nid := id.NewIdFromString("node", id.Node, t)
+	storage.PopulateNodeRegistrationCodes([]node.Info{{RegCode: "AAAA", Order: "US"}})
+	err = storage.PermissioningDb.RegisterNode(nid, []byte("salt"), "AAAA",
+		"0.0.0.0", "", "0.0.0.0", "", storage.SelfServeRegistration)
+	if err != nil {
+		t.Fatalf("Failed to register node: %+v", err)
+	}
+	nodeMap := node.NewStateMap()
+	if err = nodeMap.AddNode(nid, "US", "", "", 0); err != nil {
+		t.Fatalf("Failed to add node: %+v", err)
+	}
+	n := nodeMap.GetNode(nid)
+
+	// The server polls before its gateway is up, then both are upgraded
+	polls := []*pb.PermissioningPoll{
+		{ServerVersion: "1.0.0"},
+		{ServerVersion: "1.0.0", GatewayVersion: "1.0.0"},
+		{ServerVersion: "1.0.0", GatewayVersion: "1.0.0"},
+		{ServerVersion: "1.1.0", GatewayVersion: "1.0.0"},
+		{ServerVersion: "1.1.0", GatewayVersion: "1.1.0"},
+	}
+	for _, msg := range polls {
+		recordVersions(n, msg)
+	}
+
+	dbNode, err := storage.PermissioningDb.GetNodeById(nid)
+	if err != nil {
+		t.Fatalf("Failed to get node: %+v", err)
+	}
+	if dbNode.ServerVersion != "1.1.0" || dbNode.GatewayVersion != "1.1.0" {
+		t.Errorf("Stored versions not updated: %q and %q",
+			dbNode.ServerVersion, dbNode.GatewayVersion)
+	}
+
+	history, err := storage.PermissioningDb.GetNodeVersionHistory(nid)
+	if err != nil {
+		t.Fatalf("Failed to get version history: %+v", err)
+	}
+	expected := [][2]string{{"1.0.0", ""}, {"1.0.0", "1.0.0"},
+		{"1.1.0", "1.0.0"}, {"1.1.0", "1.1.0"}}
+	if len(history) != len(expected) {
+		t.Fatalf("Expected %d versions in the history, got %+v",
+			len(expected), history)
+	}
+	for i, versions := range expected {
+		if history[i].ServerVersion != versions[0] ||
+			history[i].GatewayVersion != versions[1] {
+			t.Errorf("Unexpected version %d in the history: %+v", i, history[i])
+		}
+	}
+
+	// A server polling without its gateway keeps the stored gateway version
+	recordVersions(n, &pb.PermissioningPoll{ServerVersion: "1.1.0"})
+	if history, _ = storage.PermissioningDb.GetNodeVersionHistory(nid); len(history) != len(expected) {
+		t.Errorf("Missing gateway version recorded as a change: %+v", history)
+	}
+}
+
 /*func TestUpdateNDF(t *testing.T) {
 	testID := id.NewIdFromUInt(0, id.Node, t)
 	testString := "test"
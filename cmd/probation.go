@@ -0,0 +1,40 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Contains the probation of newly registered nodes
+
+package cmd
+
+import (
+	jww "github.com/spf13/jwalterweatherman"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/elixxir/registration/storage/node"
+	"time"
+)
+
+// startProbation puts a newly registered node on probation, if probation is
+// enabled in the scheduling params.
+func (m *RegistrationImpl) startProbation(n *node.State) {
+	if m.schedulingParams == nil {
+		return
+	}
+	params := m.schedulingParams.SafeCopy()
+	if params.ProbationRounds == 0 && params.ProbationDuration == 0 {
+		return
+	}
+
+	n.SetProbation(true, 0, time.Time{})
+	err := storage.PermissioningDb.UpdateNodeProbation(n.GetID(), true, 0,
+		time.Time{})
+	if err != nil {
+		jww.WARN.Printf("Failed to store probation of node %s: %+v",
+			n.GetID(), err)
+	}
+	jww.INFO.Printf("Node %s is on probation until it has completed %d "+
+		"rounds over %s", n.GetID(), params.ProbationRounds,
+		params.ProbationDuration*time.Millisecond)
+}
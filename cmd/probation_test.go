@@ -0,0 +1,51 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package cmd
+
+import (
+	"gitlab.com/elixxir/registration/scheduling"
+	"gitlab.com/elixxir/registration/storage"
+	"testing"
+)
+
+// Tests that newly registered nodes are only put on probation, in memory and
+// in Storage, when probation is enabled in the scheduling params.
+func TestRegistrationImpl_startProbation(t *testing.T) {
+	impl, n := setupObservedAddressTest(false, t)
+	err := storage.PermissioningDb.InsertApplication(
+		&storage.Application{Id: 1}, &storage.Node{Code: "AAAA",
+			Id: n.GetID().Marshal(), ApplicationId: 1})
+	if err != nil {
+		t.Fatalf("Failed to insert node: %+v", err)
+	}
+
+	// No scheduling params loaded yet
+	impl.startProbation(n)
+	if n.IsOnProbation() {
+		t.Errorf("Node put on probation without scheduling params.")
+	}
+
+	impl.schedulingParams = &scheduling.SafeParams{Params: &scheduling.Params{}}
+	impl.startProbation(n)
+	if n.IsOnProbation() {
+		t.Errorf("Node put on probation with probation disabled.")
+	}
+
+	impl.schedulingParams.ProbationRounds = 3
+	impl.startProbation(n)
+	if !n.IsOnProbation() {
+		t.Errorf("Node not put on probation.")
+	}
+	nDb, err := storage.PermissioningDb.GetNode("AAAA")
+	if err != nil {
+		t.Fatalf("Failed to get node: %+v", err)
+	}
+	if !nDb.OnProbation {
+		t.Errorf("Probation not stored.")
+	}
+}
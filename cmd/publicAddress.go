@@ -0,0 +1,82 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Contains the handling of nodes which report a public address separate from
+// their inter-node address
+
+package cmd
+
+import (
+	pb "gitlab.com/elixxir/comms/mixmessages"
+	"gitlab.com/elixxir/registration/storage/node"
+	"gitlab.com/xx_network/comms/connect"
+	"gitlab.com/xx_network/primitives/id"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// publicAddressPollField is the field number of the public address in the
+// PermissioningPoll message. Nodes with a private inter-node network send
+// their public, client facing address as a string, with ServerAddress holding
+// the inter-node address; until the comms message declares the field, it is
+// read from the message's unknown fields.
+const publicAddressPollField protowire.Number = 13
+
+// getPublicAddress returns the public address sent in the poll, or an empty
+// string if the node reported only its inter-node address.
+func getPublicAddress(msg *pb.PermissioningPoll) string {
+	unknown := msg.ProtoReflect().GetUnknown()
+	var address string
+	for len(unknown) > 0 {
+		num, typ, n := protowire.ConsumeTag(unknown)
+		if n < 0 {
+			return ""
+		}
+		unknown = unknown[n:]
+
+		if num == publicAddressPollField && typ == protowire.BytesType {
+			v, m := protowire.ConsumeString(unknown)
+			if m < 0 {
+				return ""
+			}
+			address = v
+			unknown = unknown[m:]
+			continue
+		}
+
+		m := protowire.ConsumeFieldValue(num, typ, unknown)
+		if m < 0 {
+			return ""
+		}
+		unknown = unknown[m:]
+	}
+
+	// A public address the same as the inter-node one is a single address
+	if address == msg.ServerAddress {
+		return ""
+	}
+	return address
+}
+
+// clientFacingAddress returns the address the node must be publicly reachable
+// at. Dual address nodes must be public at their public address, while their
+// inter-node address may be on a private network.
+func clientFacingAddress(n *node.State, nodeHost *connect.Host) string {
+	if public := n.GetPublicAddress(); public != "" {
+		return public
+	}
+	return nodeHost.GetAddress()
+}
+
+// isHostOnline returns true if the host can be contacted at the address.
+func (m *RegistrationImpl) isHostOnline(hid *id.ID, address string, cert []byte,
+	params connect.HostParams) bool {
+	h, err := connect.NewHost(hid, address, cert, params)
+	if err != nil {
+		return false
+	}
+	return m.probeHost(h)
+}
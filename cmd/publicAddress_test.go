@@ -0,0 +1,155 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package cmd
+
+import (
+	pb "gitlab.com/elixxir/comms/mixmessages"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/elixxir/registration/storage/node"
+	"gitlab.com/xx_network/comms/connect"
+	"google.golang.org/protobuf/encoding/protowire"
+	"testing"
+)
+
+const testPublicAddr = "9.8.7.6:11420"
+
+// Builds a poll reporting the inter-node address and, if it is not empty, the
+// public address
+func newAddressPoll(serverAddress, publicAddress string) *pb.PermissioningPoll {
+	msg := &pb.PermissioningPoll{ServerAddress: serverAddress}
+	if publicAddress != "" {
+		field := protowire.AppendTag(nil, publicAddressPollField,
+			protowire.BytesType)
+		field = protowire.AppendString(field, publicAddress)
+		msg.ProtoReflect().SetUnknown(field)
+	}
+	return msg
+}
+
+// Tests that the public address is read from the poll, and that a poll without
+// one or with one matching the inter-node address reports a single address.
+func TestGetPublicAddress(t *testing.T) {
+	tests := map[string]struct {
+		msg      *pb.PermissioningPoll
+		expected string
+	}{
+		"single":   {newAddressPoll(testAdvertisedAddr, ""), ""},
+		"dual":     {newAddressPoll(testAdvertisedAddr, testPublicAddr), testPublicAddr},
+		"matching": {newAddressPoll(testAdvertisedAddr, testAdvertisedAddr), ""},
+	}
+	for name, tt := range tests {
+		if address := getPublicAddress(tt.msg); address != tt.expected {
+			t.Errorf("Unexpected public address for %s poll."+
+				"\nexpected: %q\nreceived: %q", name, tt.expected, address)
+		}
+	}
+}
+
+// Tests that a node reporting a public address has it stored and published in
+// the partial NDF, with its inter-node address kept in the full NDF, and that
+// it goes back to a single address node once it stops reporting one.
+func TestCheckIPAddresses_PublicAddress(t *testing.T) {
+	impl, n := setupObservedAddressTest(false, t)
+	err := storage.PermissioningDb.InsertApplication(
+		&storage.Application{Id: 1}, &storage.Node{Code: "AAAA",
+			Id: n.GetID().Marshal(), ServerAddress: testAdvertisedAddr,
+			ApplicationId: 1})
+	if err != nil {
+		t.Fatalf("Failed to insert node: %+v", err)
+	}
+	nodeHost, err := connect.NewHost(n.GetID(), testAdvertisedAddr, nil,
+		connect.GetDefaultHostParams())
+	if err != nil {
+		t.Fatalf("Failed to create host: %+v", err)
+	}
+	n.SetConnectivity(node.PortSuccessful)
+
+	err = checkIPAddresses(impl, n,
+		newAddressPoll(testAdvertisedAddr, testPublicAddr), nodeHost)
+	if err != nil {
+		t.Fatalf("checkIPAddresses() produced an error: %+v", err)
+	}
+	if n.GetPublicAddress() != testPublicAddr ||
+		n.GetNodeAddresses() != testAdvertisedAddr {
+		t.Errorf("Unexpected node addresses: public %q, inter-node %q",
+			n.GetPublicAddress(), n.GetNodeAddresses())
+	}
+	if n.GetConnectivity() != node.PortUnknown {
+		t.Errorf("Connectivity was not checked again for the new address.")
+	}
+	nDb, err := storage.PermissioningDb.GetNode("AAAA")
+	if err != nil {
+		t.Fatalf("Failed to get node: %+v", err)
+	}
+	if nDb.PublicAddress != testPublicAddr {
+		t.Errorf("Public address not stored: %q", nDb.PublicAddress)
+	}
+
+	if err = impl.State.UpdateOutputNdf(); err != nil {
+		t.Fatalf("Failed to update output NDF: %+v", err)
+	}
+	if addr := impl.State.GetFullNdf().Get().Nodes[0].Address; addr != testAdvertisedAddr {
+		t.Errorf("Full NDF has address %q instead of the inter-node address.", addr)
+	}
+	if addr := impl.State.GetPartialNdf().Get().Nodes[0].Address; addr != testPublicAddr {
+		t.Errorf("Partial NDF has address %q instead of the public address.", addr)
+	}
+
+	// The node goes back to a single address
+	err = checkIPAddresses(impl, n, newAddressPoll(testAdvertisedAddr, ""),
+		nodeHost)
+	if err != nil {
+		t.Fatalf("checkIPAddresses() produced an error: %+v", err)
+	}
+	if n.GetPublicAddress() != "" {
+		t.Errorf("Public address kept: %q", n.GetPublicAddress())
+	}
+	if err = impl.State.UpdateOutputNdf(); err != nil {
+		t.Fatalf("Failed to update output NDF: %+v", err)
+	}
+	if addr := impl.State.GetPartialNdf().Get().Nodes[0].Address; addr != "" {
+		t.Errorf("Partial NDF has address %q for a single address node.", addr)
+	}
+}
+
+// Tests that dual address nodes must be public at their public address and
+// single address nodes at their only address.
+func TestClientFacingAddress(t *testing.T) {
+	_, n := setupObservedAddressTest(false, t)
+	nodeHost, err := connect.NewHost(n.GetID(), testAdvertisedAddr, nil,
+		connect.GetDefaultHostParams())
+	if err != nil {
+		t.Fatalf("Failed to create host: %+v", err)
+	}
+
+	if addr := clientFacingAddress(n, nodeHost); addr != testAdvertisedAddr {
+		t.Errorf("Single address node's client facing address is %q.", addr)
+	}
+	n.SetPublicAddress(testPublicAddr)
+	if addr := clientFacingAddress(n, nodeHost); addr != testPublicAddr {
+		t.Errorf("Dual address node's client facing address is %q.", addr)
+	}
+}
+
+// Tests that the inter-node address of a dual address node is never replaced
+// by the address its polls are observed from.
+func TestResolveNodeAddress_DualAddress(t *testing.T) {
+	impl, n := setupObservedAddressTest(true, t)
+	n.SetPublicAddress(testPublicAddr)
+	checkObservedAddress(n, testObservedIp)
+
+	reachable, _ := impl.resolveNodeAddress(n, false,
+		func(string) bool { return true })
+	if reachable {
+		t.Errorf("Dual address node was reachable at its observed address.")
+	}
+	if ndfAddr := impl.State.GetUnprunedNdf().Nodes[0].Address; ndfAddr != testAdvertisedAddr {
+		t.Errorf("NDF address changed.\nexpected: %s\nreceived: %s",
+			testAdvertisedAddr, ndfAddr)
+	}
+}
@@ -0,0 +1,125 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Handles a registered node registering again under a new key, such as after
+// its keys were wiped
+
+package cmd
+
+import (
+	"bytes"
+	"github.com/pkg/errors"
+	jww "github.com/spf13/jwalterweatherman"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/elixxir/registration/storage/node"
+	"gitlab.com/xx_network/comms/connect"
+	"gitlab.com/xx_network/primitives/id"
+	"gitlab.com/xx_network/primitives/ndf"
+)
+
+// reRegisterNode moves the node registered with the code to the ID generated
+// from its new key and salt. Storage, the comms host, the node map and the NDF
+// are all moved to the new ID, so that nothing is left behind under the old
+// one. Banned nodes and nodes still in a round cannot register again.
+func (m *RegistrationImpl) reRegisterNode(nodeInfo *storage.Node, newId *id.ID,
+	salt []byte, serverAddr, serverTlsCert, gatewayAddr, gatewayTlsCert,
+	registrationCode string) error {
+	oldId, err := id.Unmarshal(nodeInfo.Id)
+	if err != nil {
+		return errors.Errorf("Could not unmarshal stored ID of node with "+
+			"registration code %s: %+v", registrationCode, err)
+	}
+	if node.Status(nodeInfo.Status) == node.Banned {
+		return errors.Errorf("Node %s with registration code %s is banned "+
+			"and cannot register again", oldId, registrationCode)
+	}
+	oldState := m.State.GetNodeMap().GetNode(oldId)
+	if oldState != nil {
+		if inRound, r := oldState.GetCurrentRound(); inRound {
+			return errors.Errorf("Node %s with registration code %s cannot "+
+				"register again while in round %d", oldId, registrationCode,
+				r.GetRoundID())
+		}
+	}
+
+	err = storage.PermissioningDb.ReRegisterNode(oldId, newId, salt,
+		registrationCode, serverAddr, serverTlsCert, gatewayAddr, gatewayTlsCert)
+	if err != nil {
+		return errors.Errorf("unable to move node %s to %s: %+v", oldId,
+			newId, err)
+	}
+	jww.INFO.Printf("Node %s with registration code %s registered again "+
+		"under a new key as %s", oldId, registrationCode, newId)
+
+	// Replace the host so that the new key is used to authenticate the node
+	m.Comms.RemoveHost(oldId)
+	_, err = m.Comms.AddHost(newId, serverAddr, []byte(serverTlsCert),
+		connect.GetDefaultHostParams())
+	if err != nil {
+		return errors.Errorf("Could not register host for Server %s: %+v",
+			serverAddr, err)
+	}
+
+	nodeMap := m.State.GetNodeMap()
+	if oldState != nil {
+		if err = nodeMap.RemoveNode(oldId); err != nil {
+			return errors.WithMessage(err, "Could not remove old node from "+
+				"state tracker")
+		}
+	}
+	err = nodeMap.AddNode(newId, nodeInfo.Sequence, serverAddr, gatewayAddr,
+		nodeInfo.ApplicationId)
+	if err != nil {
+		return errors.WithMessage(err, "Could not register node with "+
+			"state tracker")
+	}
+	nodeMap.GetNode(newId).SetGatewayless(m.isGatewayless(gatewayTlsCert))
+
+	return m.replaceNdfNode(oldId, newId, registrationCode)
+}
+
+// replaceNdfNode replaces the entry of the node with the old ID in the NDF with
+// the one now stored for the registration code, keeping its place in
+// registration order.
+func (m *RegistrationImpl) replaceNdfNode(oldId, newId *id.ID, code string) error {
+	m.registrationLock.Lock()
+	defer m.registrationLock.Unlock()
+	m.State.InternalNdfLock.Lock()
+	defer m.State.InternalNdfLock.Unlock()
+
+	def := m.State.GetUnprunedNdf()
+	gateway, n, regTime, err := m.assembleNdf(code)
+	if err != nil {
+		return errors.Errorf("unable to assemble topology: %+v", err)
+	}
+
+	nodes := make([]ndf.Node, 0, len(def.Nodes))
+	gateways := make([]ndf.Gateway, 0, len(def.Gateways))
+	for i := range def.Nodes {
+		if bytes.Equal(def.Nodes[i].ID, oldId.Bytes()) {
+			continue
+		}
+		nodes = append(nodes, def.Nodes[i])
+		gateways = append(gateways, def.Gateways[i])
+	}
+	def.Nodes, def.Gateways = nodes, gateways
+
+	delete(m.registrationTimes, *oldId)
+	m.registrationTimes[*newId] = regTime
+	if err = m.insertNdf(def, gateway, n, regTime); err != nil {
+		return errors.WithMessage(err, "Failed to insert nodes in definition")
+	}
+
+	// As with a new registration, the node must be online to be scheduled
+	m.State.RemovePrunedNode(oldId)
+	if !m.params.disableNDFPruning {
+		m.State.SetPrunedNode(newId)
+	}
+
+	m.State.UpdateInternalNdf(def)
+	return nil
+}
@@ -0,0 +1,216 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package cmd
+
+import (
+	"bytes"
+	gorsa "crypto/rsa"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/elixxir/registration/storage/node"
+	"gitlab.com/xx_network/crypto/signature/rsa"
+	"gitlab.com/xx_network/crypto/tls"
+	"gitlab.com/xx_network/crypto/xx"
+	"gitlab.com/xx_network/primitives/id"
+	"testing"
+	"time"
+)
+
+// Tests that a registered node which registers again under a new key is moved
+// to its new ID in Storage, the host map, the node map and the NDF, without
+// leaving anything behind under its old ID.
+func TestRegistrationImpl_RegisterNode_NewKey(t *testing.T) {
+	var err error
+	dblck.Lock()
+	defer dblck.Unlock()
+
+	var dc func() error
+	storage.PermissioningDb, dc, err = storage.NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	defer func() { _ = dc() }()
+	err = storage.PermissioningDb.InsertEphemeralLength(
+		&storage.EphemeralLength{Length: 8, Timestamp: time.Now()})
+	if err != nil {
+		t.Errorf("Failed to insert ephemeral length into database: %+v", err)
+	}
+	storage.PopulateNodeRegistrationCodes([]node.Info{
+		{RegCode: "AAAA", Order: "US"}, {RegCode: "BBBB", Order: "GB"}})
+
+	impl, err := StartRegistration(testParams)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer impl.Comms.Shutdown()
+	impl.params.allowNodeKeyChange = true
+
+	salt := []byte("testtesttesttesttesttesttesttest")
+	err = impl.RegisterNode(salt, nodeAddr, string(nodeCert), nodeAddr,
+		string(nodeCert), "AAAA")
+	if err != nil {
+		t.Fatalf("Failed to register node: %+v", err)
+	}
+	time.Sleep(time.Millisecond)
+	err = impl.RegisterNode([]byte("salttesttesttesttesttesttesttest"),
+		"0.0.0.0:6901", string(nodeCert), "0.0.0.0:6901", string(nodeCert), "BBBB")
+	if err != nil {
+		t.Fatalf("Failed to register node: %+v", err)
+	}
+	oldInfo, err := storage.PermissioningDb.GetNode("AAAA")
+	if err != nil {
+		t.Fatalf("Failed to get node: %+v", err)
+	}
+	oldId, err := id.Unmarshal(oldInfo.Id)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal node ID: %+v", err)
+	}
+
+	impl.State.GetNodeMap().GetNode(oldId).RecordPollSource("1.1.1.1",
+		time.Now(), time.Minute)
+
+	// Register again after a wipe, with a new key and salt
+	newSalt := []byte("wipetesttesttesttesttesttesttest")
+	newAddr := "0.0.0.0:6902"
+	err = impl.RegisterNode(newSalt, newAddr, string(gatewayCert), newAddr,
+		string(gatewayCert), "AAAA")
+	if err != nil {
+		t.Fatalf("Failed to register node under a new key: %+v", err)
+	}
+
+	tlsCert, err := tls.LoadCertificate(string(gatewayCert))
+	if err != nil {
+		t.Fatalf("Failed to load certificate: %+v", err)
+	}
+	newKey := &rsa.PublicKey{PublicKey: *tlsCert.PublicKey.(*gorsa.PublicKey)}
+	newId, err := xx.NewID(newKey, newSalt, id.Node)
+	if err != nil {
+		t.Fatalf("Failed to generate node ID: %+v", err)
+	}
+
+	newInfo, err := storage.PermissioningDb.GetNode("AAAA")
+	if err != nil {
+		t.Fatalf("Failed to get node: %+v", err)
+	}
+	if !bytes.Equal(newInfo.Id, newId.Marshal()) {
+		t.Errorf("Stored ID was not replaced.\nexpected: %s\nreceived: %v",
+			newId, newInfo.Id)
+	}
+	if !bytes.Equal(newInfo.Salt, newSalt) || newInfo.ServerAddress != newAddr ||
+		newInfo.NodeCertificate != string(gatewayCert) {
+		t.Errorf("Stored salt, address or certificate was not replaced: %+v",
+			newInfo)
+	}
+	if newInfo.RegistrationSource != storage.NewKeyRegistration {
+		t.Errorf("Unexpected registration source.\nexpected: %s\nreceived: %s",
+			storage.NewKeyRegistration, newInfo.RegistrationSource)
+	}
+
+	if _, exists := impl.Comms.GetHost(oldId); exists {
+		t.Errorf("Host of the old ID remains in the host map.")
+	}
+	h, exists := impl.Comms.GetHost(newId)
+	if !exists {
+		t.Fatalf("No host for the new ID in the host map.")
+	}
+	if h.GetAddress() != newAddr {
+		t.Errorf("Unexpected host address.\nexpected: %s\nreceived: %s",
+			newAddr, h.GetAddress())
+	}
+	if h.GetPubKey() == nil || h.GetPubKey().GetN().Cmp(newKey.GetN()) != 0 {
+		t.Errorf("Host does not hold the new public key.")
+	}
+
+	if impl.State.GetNodeMap().GetNode(oldId) != nil {
+		t.Errorf("Old ID remains in the node map.")
+	}
+	ns := impl.State.GetNodeMap().GetNode(newId)
+	if ns == nil {
+		t.Fatalf("New ID is not in the node map.")
+	}
+	if len(ns.GetPollSources()) != 0 {
+		t.Errorf("Poll sources of the old ID were kept: %+v", ns.GetPollSources())
+	}
+	if ns.GetNodeAddresses() != newAddr || ns.GetAppID() != oldInfo.ApplicationId {
+		t.Errorf("Unexpected node state for the new ID: %s, %d",
+			ns.GetNodeAddresses(), ns.GetAppID())
+	}
+
+	def := impl.State.GetUnprunedNdf()
+	if len(def.Nodes) != 2 || len(def.Gateways) != 2 {
+		t.Fatalf("Unexpected number of nodes in the NDF: %d", len(def.Nodes))
+	}
+	if !bytes.Equal(def.Nodes[0].ID, newId.Bytes()) {
+		t.Errorf("New ID did not keep the place of the old ID in the NDF.")
+	}
+	if def.Nodes[0].Address != newAddr || def.Gateways[0].Address != newAddr {
+		t.Errorf("Unexpected NDF addresses for the new ID: %s, %s",
+			def.Nodes[0].Address, def.Gateways[0].Address)
+	}
+
+	// Moving to a new key is refused when it is not allowed
+	impl.params.allowNodeKeyChange = false
+	err = impl.RegisterNode(salt, nodeAddr, string(nodeCert), nodeAddr,
+		string(nodeCert), "AAAA")
+	if err == nil {
+		t.Errorf("Node moved to a new key when it is not allowed.")
+	}
+}
+
+// Tests that a banned node cannot register again under a new key.
+func TestRegistrationImpl_RegisterNode_NewKeyBanned(t *testing.T) {
+	var err error
+	dblck.Lock()
+	defer dblck.Unlock()
+
+	var dc func() error
+	storage.PermissioningDb, dc, err = storage.NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	defer func() { _ = dc() }()
+	err = storage.PermissioningDb.InsertEphemeralLength(
+		&storage.EphemeralLength{Length: 8, Timestamp: time.Now()})
+	if err != nil {
+		t.Errorf("Failed to insert ephemeral length into database: %+v", err)
+	}
+	storage.PopulateNodeRegistrationCodes([]node.Info{{RegCode: "AAAA", Order: "US"}})
+
+	impl, err := StartRegistration(testParams)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer impl.Comms.Shutdown()
+	impl.params.allowNodeKeyChange = true
+
+	err = impl.RegisterNode([]byte("testtesttesttesttesttesttesttest"),
+		nodeAddr, string(nodeCert), nodeAddr, string(nodeCert), "AAAA")
+	if err != nil {
+		t.Fatalf("Failed to register node: %+v", err)
+	}
+	info, err := storage.PermissioningDb.GetNode("AAAA")
+	if err != nil {
+		t.Fatalf("Failed to get node: %+v", err)
+	}
+	oldId, err := id.Unmarshal(info.Id)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal node ID: %+v", err)
+	}
+	err = storage.PermissioningDb.GetDatabaseImpl(t).BannedNode(oldId, t)
+	if err != nil {
+		t.Fatalf("Failed to ban node: %+v", err)
+	}
+
+	err = impl.RegisterNode([]byte("wipetesttesttesttesttesttesttest"),
+		nodeAddr, string(gatewayCert), nodeAddr, string(gatewayCert), "AAAA")
+	if err == nil {
+		t.Errorf("Banned node registered again under a new key.")
+	}
+	if _, exists := impl.Comms.GetHost(oldId); !exists {
+		t.Errorf("Host of the banned node was removed.")
+	}
+}
@@ -0,0 +1,199 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Handles gating when the network reports itself ready for clients on enough
+// nodes being present, such as after mass restarts
+
+package cmd
+
+import (
+	"github.com/pkg/errors"
+	jww "github.com/spf13/jwalterweatherman"
+	pb "gitlab.com/elixxir/comms/mixmessages"
+	"gitlab.com/elixxir/primitives/current"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/elixxir/registration/storage/node"
+	"gitlab.com/xx_network/comms/connect"
+	"google.golang.org/protobuf/encoding/protowire"
+	"math"
+	"sync"
+	"time"
+)
+
+// ndfReadinessField is the field number of the readiness flag in the NDF
+// message returned by PollNdf. It is sent as a varint, 1 when the network is
+// ready and 0 when it is degraded, in the message's unknown fields until the
+// comms message declares the field.
+const ndfReadinessField protowire.Number = 3
+
+// Time the network's readiness is reused for before it is evaluated again, so
+// that client polls do not each walk the node map
+const readinessCheckInterval = time.Second
+
+// NetworkReadiness is whether the network is ready for clients and the counts
+// it was decided from.
+type NetworkReadiness struct {
+	// False while the network is degraded
+	Ready bool
+	// Time the network last became ready or degraded
+	Since time.Time
+
+	// Nodes which polled since the server started and are not failing, and
+	// the number of them required for the network to become ready
+	PresentNodes  int
+	RequiredNodes int
+	// Nodes which are not banned
+	RegisteredNodes int
+	// Rounds completed since the server started
+	RoundsCompleted uint64
+}
+
+// networkReadiness tracks whether the network is ready for clients. The
+// network starts degraded and becomes ready once enough nodes are present and
+// a round completed since it was last degraded. It only becomes degraded again
+// once the present nodes drop below the required number by the hysteresis, so
+// that it does not flap around the threshold.
+type networkReadiness struct {
+	// Least number, and least fraction of the registered nodes, which must be
+	// present for the network to become ready
+	minNodes        uint32
+	minNodeFraction float64
+	// Fraction of the required nodes which may be missing before a ready
+	// network becomes degraded
+	hysteresis float64
+	// Whether the NDF is withheld from clients while the network is degraded
+	strict bool
+
+	status  NetworkReadiness
+	checked time.Time
+	// Rounds completed when the network last became degraded
+	degradedCompleted uint64
+
+	mux sync.Mutex
+}
+
+// newNetworkReadiness returns the readiness gate for the thresholds, or nil if
+// no threshold is set and the network is always ready.
+func newNetworkReadiness(minNodes uint32, minNodeFraction, hysteresis float64,
+	strict bool) (*networkReadiness, error) {
+	if minNodeFraction < 0 || minNodeFraction > 1 {
+		return nil, errors.Errorf("Readiness node fraction %f is not "+
+			"between 0 and 1", minNodeFraction)
+	} else if hysteresis < 0 || hysteresis >= 1 {
+		return nil, errors.Errorf("Readiness hysteresis %f is not at least "+
+			"0 and below 1", hysteresis)
+	} else if minNodes == 0 && minNodeFraction == 0 {
+		return nil, nil
+	}
+	return &networkReadiness{
+		minNodes:        minNodes,
+		minNodeFraction: minNodeFraction,
+		hysteresis:      hysteresis,
+		strict:          strict,
+		status:          NetworkReadiness{Since: time.Now()},
+	}, nil
+}
+
+// required returns the number of present nodes required for the network to
+// become ready.
+func (r *networkReadiness) required(registered int) int {
+	required := int(math.Ceil(r.minNodeFraction * float64(registered)))
+	if required < int(r.minNodes) {
+		required = int(r.minNodes)
+	}
+	return required
+}
+
+// check returns whether the network is ready, evaluating it from the state if
+// it was not evaluated within the readiness check interval. A nil gate is
+// always ready.
+func (r *networkReadiness) check(s *storage.NetworkState, now time.Time) NetworkReadiness {
+	if r == nil {
+		return NetworkReadiness{Ready: true}
+	}
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	if !r.checked.IsZero() && now.Sub(r.checked) < readinessCheckInterval {
+		return r.status
+	}
+	r.checked = now
+
+	present, registered := countPresentNodes(s)
+	r.update(present, registered, s.GetRoundsCompleted(), now)
+	return r.status
+}
+
+// update moves the network between ready and degraded from the node and round
+// counts. Must be called with the lock held.
+func (r *networkReadiness) update(present, registered int, completed uint64,
+	now time.Time) {
+	status := &r.status
+	status.PresentNodes = present
+	status.RegisteredNodes = registered
+	status.RequiredNodes = r.required(registered)
+	status.RoundsCompleted = completed
+
+	if status.Ready {
+		if float64(present) >= float64(status.RequiredNodes)*(1-r.hysteresis) {
+			return
+		}
+		status.Ready = false
+		status.Since = now
+		r.degradedCompleted = completed
+		jww.WARN.Printf("Network is degraded: %d of the %d required nodes "+
+			"are present", present, status.RequiredNodes)
+	} else if present >= status.RequiredNodes && completed > r.degradedCompleted {
+		status.Ready = true
+		status.Since = now
+		jww.INFO.Printf("Network is ready: %d of the %d required nodes are "+
+			"present", present, status.RequiredNodes)
+	}
+}
+
+// countPresentNodes returns the number of active nodes which polled since the
+// server started and are not failing, along with the number of nodes which are
+// not banned.
+func countPresentNodes(s *storage.NetworkState) (present, registered int) {
+	for _, n := range s.GetNodeMap().GetNodeStates() {
+		status := n.GetStatus()
+		if status == node.Banned {
+			continue
+		}
+		registered++
+		if status != node.Active {
+			continue
+		}
+		switch n.GetActivity() {
+		case current.NOT_STARTED, current.ERROR, current.CRASH:
+		default:
+			present++
+		}
+	}
+	return present, registered
+}
+
+// withReadiness returns a copy of the NDF message carrying the readiness flag,
+// leaving the published message untouched.
+func withReadiness(msg *pb.NDF, ready bool) *pb.NDF {
+	flagged := &pb.NDF{Ndf: msg.GetNdf(), Signature: msg.GetSignature()}
+	flag := uint64(0)
+	if ready {
+		flag = 1
+	}
+	unknown := protowire.AppendTag(nil, ndfReadinessField, protowire.VarintType)
+	flagged.ProtoReflect().SetUnknown(protowire.AppendVarint(unknown, flag))
+	return flagged
+}
+
+// GetNetworkReadiness returns whether the network is ready for clients and the
+// counts it was decided from.
+func (m *RegistrationImpl) GetNetworkReadiness(auth *connect.Auth) (NetworkReadiness, error) {
+	if err := checkAdminAuth(auth); err != nil {
+		return NetworkReadiness{}, err
+	}
+	return m.readiness.check(m.State, time.Now()), nil
+}
@@ -0,0 +1,206 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package cmd
+
+import (
+	pb "gitlab.com/elixxir/comms/mixmessages"
+	"gitlab.com/elixxir/primitives/current"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/xx_network/comms/connect"
+	"gitlab.com/xx_network/primitives/id"
+	"gitlab.com/xx_network/primitives/ndf"
+	"gitlab.com/xx_network/primitives/region"
+	"google.golang.org/protobuf/encoding/protowire"
+	"testing"
+	"time"
+)
+
+// Returns the readiness flag of the NDF message, and false if none was sent
+func getReadinessFlag(t *testing.T, msg *pb.NDF) (bool, bool) {
+	unknown := msg.ProtoReflect().GetUnknown()
+	for len(unknown) > 0 {
+		num, typ, n := protowire.ConsumeTag(unknown)
+		if n < 0 {
+			t.Fatalf("Failed to consume tag of NDF field")
+		}
+		unknown = unknown[n:]
+		if num == ndfReadinessField && typ == protowire.VarintType {
+			v, _ := protowire.ConsumeVarint(unknown)
+			return v == 1, true
+		}
+		unknown = unknown[protowire.ConsumeFieldValue(num, typ, unknown):]
+	}
+	return false, false
+}
+
+// Tests that the network becomes ready once enough nodes are present and a
+// round completed, and is only degraded again once the present nodes drop
+// past the hysteresis.
+func TestNetworkReadiness_update(t *testing.T) {
+	r, err := newNetworkReadiness(10, 0, 0.2, false)
+	if err != nil {
+		t.Fatalf("Failed to create readiness gate: %+v", err)
+	}
+	now := time.Now()
+
+	for i, step := range []struct {
+		present   int
+		completed uint64
+		ready     bool
+	}{
+		{5, 0, false},
+		// Enough nodes, but no round completed yet
+		{12, 0, false},
+		{12, 1, true},
+		// Within the hysteresis
+		{8, 1, true},
+		{7, 1, false},
+		// Back above the threshold, but no round completed since degrading
+		{10, 1, false},
+		{9, 2, false},
+		{10, 2, true},
+	} {
+		r.update(step.present, 20, step.completed, now.Add(time.Duration(i)))
+		if r.status.Ready != step.ready {
+			t.Errorf("Step %d: expected ready %t with %d nodes and %d "+
+				"rounds completed.", i, step.ready, step.present,
+				step.completed)
+		}
+	}
+	if r.status.RequiredNodes != 10 || r.status.RegisteredNodes != 20 ||
+		!r.status.Since.Equal(now.Add(7)) {
+		t.Errorf("Unexpected readiness: %+v", r.status)
+	}
+
+	// The fraction of the registered nodes is required when it is larger
+	r, _ = newNetworkReadiness(10, 0.5, 0, false)
+	if required := r.required(30); required != 15 {
+		t.Errorf("Expected 15 required nodes, received %d.", required)
+	}
+	if required := r.required(4); required != 10 {
+		t.Errorf("Expected 10 required nodes, received %d.", required)
+	}
+
+	if r, err = newNetworkReadiness(0, 0, 0.1, true); r != nil || err != nil {
+		t.Errorf("Readiness gate created without a threshold: %v, %+v", r, err)
+	}
+	if status := r.check(nil, now); !status.Ready {
+		t.Errorf("Network is not ready without a readiness gate.")
+	}
+	for _, p := range [][2]float64{{-0.1, 0}, {1.5, 0}, {0.5, -0.1}, {0.5, 1}} {
+		if _, err = newNetworkReadiness(1, p[0], p[1], false); err == nil {
+			t.Errorf("Fraction %f and hysteresis %f were accepted.", p[0], p[1])
+		}
+	}
+}
+
+// Tests that PollNdf() flags the NDF as degraded until enough nodes polled
+// and a round completed, and that strict mode withholds the NDF until then.
+func TestRegistrationImpl_PollNdf_Readiness(t *testing.T) {
+	var err error
+	var dc func() error
+	storage.PermissioningDb, dc, err = storage.NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("Failed to create database: %+v", err)
+	}
+	t.Cleanup(func() { _ = dc() })
+	state, err := storage.NewState(getTestKey(), 8, "", "", region.GetCountryBins())
+	if err != nil {
+		t.Fatalf("Failed to create state: %+v", err)
+	}
+	readiness, err := newNetworkReadiness(2, 0, 0.5, false)
+	if err != nil {
+		t.Fatalf("Failed to create readiness gate: %+v", err)
+	}
+	ndfReady := uint32(1)
+	impl := &RegistrationImpl{State: state, NdfReady: &ndfReady,
+		readiness: readiness}
+
+	state.UpdateInternalNdf(&ndf.NetworkDefinition{
+		Nodes: []ndf.Node{{ID: id.NewIdFromString("node", id.Node, t).Marshal(),
+			Address: "10.0.0.1:11420"}},
+	})
+	if err = state.UpdateOutputNdf(); err != nil {
+		t.Fatalf("Failed to publish NDF: %+v", err)
+	}
+
+	nodes := make([]*id.ID, 3)
+	for i := range nodes {
+		nodes[i] = id.NewIdFromUInt(uint64(i), id.Node, t)
+		if err = state.GetNodeMap().AddNode(nodes[i], "", "", "", 0); err != nil {
+			t.Fatalf("Failed to add node: %+v", err)
+		}
+	}
+	poll := func(nid *id.ID, activity current.Activity) {
+		if _, _, err := state.GetNodeMap().GetNode(nid).Update(activity); err != nil {
+			t.Fatalf("Failed to update node %s: %+v", nid, err)
+		}
+	}
+	pollNdf := func(expectReady bool) {
+		readiness.checked = time.Time{}
+		msg, err := impl.PollNdf(nil)
+		if err != nil {
+			t.Fatalf("Failed to poll the NDF: %+v", err)
+		}
+		if len(msg.Ndf) == 0 {
+			t.Errorf("NDF was not sent.")
+		}
+		if ready, sent := getReadinessFlag(t, msg); !sent || ready != expectReady {
+			t.Errorf("Expected readiness flag %t, received %t (sent %t).",
+				expectReady, ready, sent)
+		}
+	}
+
+	// Registered nodes which have not polled are not present
+	pollNdf(false)
+	poll(nodes[0], current.WAITING)
+	poll(nodes[1], current.WAITING)
+	pollNdf(false)
+	state.RecordRoundCompleted(time.Now())
+	pollNdf(true)
+
+	// The published NDF is left without the flag
+	if _, sent := getReadinessFlag(t, state.GetPartialNdf().GetPb()); sent {
+		t.Errorf("Readiness flag added to the published NDF.")
+	}
+
+	// Losing one of the two nodes is within the hysteresis
+	poll(nodes[1], current.ERROR)
+	pollNdf(true)
+	poll(nodes[0], current.ERROR)
+	pollNdf(false)
+
+	// In strict mode, the NDF is withheld while degraded
+	readiness.strict = true
+	readiness.checked = time.Time{}
+	if _, err = impl.PollNdf(nil); err == nil || err.Error() != ndf.NO_NDF {
+		t.Errorf("NDF was not withheld while degraded: %+v", err)
+	}
+	poll(nodes[2], current.WAITING)
+	poll(nodes[0], current.WAITING)
+	state.RecordRoundCompleted(time.Now())
+	pollNdf(true)
+
+	permHost, err := connect.NewHost(&id.Permissioning, "", nil,
+		connect.GetDefaultHostParams())
+	if err != nil {
+		t.Fatalf("Failed to create host: %+v", err)
+	}
+	status, err := impl.GetNetworkReadiness(
+		&connect.Auth{IsAuthenticated: true, Sender: permHost})
+	if err != nil {
+		t.Fatalf("Failed to get network readiness: %+v", err)
+	}
+	if !status.Ready || status.PresentNodes != 2 || status.RequiredNodes != 2 ||
+		status.RegisteredNodes != 3 || status.RoundsCompleted != 2 {
+		t.Errorf("Unexpected network readiness: %+v", status)
+	}
+	if _, err = impl.GetNetworkReadiness(&connect.Auth{Sender: permHost}); err == nil {
+		t.Errorf("Unauthenticated sender was able to get network readiness.")
+	}
+}
@@ -0,0 +1,107 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Handles locking out sources which repeatedly fail node registration, to
+// harden registration codes against brute forcing
+
+package cmd
+
+import (
+	"github.com/pkg/errors"
+	jww "github.com/spf13/jwalterweatherman"
+	"net"
+	"sync"
+	"time"
+)
+
+// registrationAttempts tracks the failed node registrations of each source
+type registrationAttempts struct {
+	// Number of failures within the lockout window after which a source is
+	// locked out, 0 disables the lockout
+	limit uint
+	// How long a source is locked out for, and how long its failures are
+	// counted for
+	lockout time.Duration
+
+	sources map[string]*sourceAttempts
+	mux     sync.Mutex
+}
+
+// Failed registrations of a single source
+type sourceAttempts struct {
+	failures     uint
+	firstFailure time.Time
+	lockedUntil  time.Time
+}
+
+// registrationSource returns the source a registration is tracked by, which
+// is the host of the server address the node registers with.
+func registrationSource(serverAddr string) string {
+	host, _, err := net.SplitHostPort(serverAddr)
+	if err != nil {
+		return serverAddr
+	}
+	return host
+}
+
+// check returns an error if the source is locked out.
+func (ra *registrationAttempts) check(source string, now time.Time) error {
+	if ra.limit == 0 {
+		return nil
+	}
+
+	ra.mux.Lock()
+	defer ra.mux.Unlock()
+
+	sa, exists := ra.sources[source]
+	if !exists || sa.lockedUntil.IsZero() {
+		return nil
+	}
+	if now.Before(sa.lockedUntil) {
+		return errors.Errorf("Registration from %s is locked out until %s "+
+			"after %d failed attempts", source,
+			sa.lockedUntil.Format(time.RFC3339), ra.limit)
+	}
+
+	// The lockout has passed
+	delete(ra.sources, source)
+	return nil
+}
+
+// fail records a failed registration from the source, locking it out once it
+// reaches the limit within the lockout window.
+func (ra *registrationAttempts) fail(source string, now time.Time) {
+	if ra.limit == 0 {
+		return
+	}
+
+	ra.mux.Lock()
+	defer ra.mux.Unlock()
+
+	if ra.sources == nil {
+		ra.sources = make(map[string]*sourceAttempts)
+	}
+	sa, exists := ra.sources[source]
+	if !exists || now.Sub(sa.firstFailure) > ra.lockout {
+		sa = &sourceAttempts{firstFailure: now}
+		ra.sources[source] = sa
+	}
+
+	sa.failures++
+	if sa.failures >= ra.limit && sa.lockedUntil.IsZero() {
+		sa.lockedUntil = now.Add(ra.lockout)
+		jww.WARN.Printf("Locking out node registration from %s for %s "+
+			"after %d failed attempts", source, ra.lockout, sa.failures)
+	}
+}
+
+// succeed clears the failed registrations of the source.
+func (ra *registrationAttempts) succeed(source string) {
+	ra.mux.Lock()
+	defer ra.mux.Unlock()
+	delete(ra.sources, source)
+}
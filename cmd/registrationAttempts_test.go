@@ -0,0 +1,128 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package cmd
+
+import (
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/elixxir/registration/storage/node"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Tests that a source is locked out once it reaches the attempt limit, that
+// other sources are not affected and that the lockout clears after the window.
+func TestRegistrationAttempts_Lockout(t *testing.T) {
+	ra := &registrationAttempts{limit: 3, lockout: time.Minute}
+	start := time.Now()
+
+	for i := 0; i < 2; i++ {
+		ra.fail("1.2.3.4", start)
+		if err := ra.check("1.2.3.4", start); err != nil {
+			t.Fatalf("Locked out after %d failures: %+v", i+1, err)
+		}
+	}
+	ra.fail("1.2.3.4", start)
+	if err := ra.check("1.2.3.4", start.Add(59*time.Second)); err == nil {
+		t.Errorf("Not locked out after reaching the limit.")
+	}
+	if err := ra.check("5.6.7.8", start); err != nil {
+		t.Errorf("Other source locked out: %+v", err)
+	}
+
+	if err := ra.check("1.2.3.4", start.Add(time.Minute)); err != nil {
+		t.Errorf("Still locked out after the window: %+v", err)
+	}
+
+	// The failures were cleared with the lockout
+	ra.fail("1.2.3.4", start.Add(time.Minute))
+	if err := ra.check("1.2.3.4", start.Add(time.Minute)); err != nil {
+		t.Errorf("Locked out after a single failure: %+v", err)
+	}
+}
+
+// Tests that failures older than the window and failures followed by a
+// successful registration are not counted.
+func TestRegistrationAttempts_Reset(t *testing.T) {
+	ra := &registrationAttempts{limit: 2, lockout: time.Minute}
+	start := time.Now()
+
+	ra.fail("1.2.3.4", start)
+	ra.fail("1.2.3.4", start.Add(2*time.Minute))
+	if err := ra.check("1.2.3.4", start.Add(2*time.Minute)); err != nil {
+		t.Errorf("Locked out by failures outside of the window: %+v", err)
+	}
+
+	ra.succeed("1.2.3.4")
+	ra.fail("1.2.3.4", start.Add(2*time.Minute))
+	if err := ra.check("1.2.3.4", start.Add(2*time.Minute)); err != nil {
+		t.Errorf("Locked out by failures before a success: %+v", err)
+	}
+
+	// Disabled without a limit
+	disabled := &registrationAttempts{lockout: time.Minute}
+	for i := 0; i < 10; i++ {
+		disabled.fail("1.2.3.4", start)
+	}
+	if err := disabled.check("1.2.3.4", start); err != nil {
+		t.Errorf("Locked out without a limit: %+v", err)
+	}
+}
+
+// Tests that RegisterNode locks out a server address which repeatedly uses
+// invalid registration codes, even once it uses a valid one, until the
+// lockout has passed.
+func TestRegistrationImpl_RegisterNode_Lockout(t *testing.T) {
+	var err error
+	dblck.Lock()
+	defer dblck.Unlock()
+
+	var dc func() error
+	storage.PermissioningDb, dc, err = storage.NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	defer func() { _ = dc() }()
+	err = storage.PermissioningDb.InsertEphemeralLength(
+		&storage.EphemeralLength{Length: 8, Timestamp: time.Now()})
+	if err != nil {
+		t.Errorf("Failed to insert ephemeral length into database: %+v", err)
+	}
+	storage.PopulateNodeRegistrationCodes([]node.Info{{RegCode: "AAAA", Order: "US"}})
+
+	params := testParams
+	params.registrationAttemptLimit = 3
+	params.registrationLockout = 500 * time.Millisecond
+	impl, err := StartRegistration(params)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer impl.Comms.Shutdown()
+
+	salt := []byte("testtesttesttesttesttesttesttest")
+	for _, code := range []string{"BBBB", "CCCC", "DDDD"} {
+		err = impl.RegisterNode(salt, nodeAddr, string(nodeCert), nodeAddr,
+			string(nodeCert), code)
+		if err == nil || strings.Contains(err.Error(), "locked out") {
+			t.Fatalf("Unexpected error for invalid code %s: %v", code, err)
+		}
+	}
+
+	err = impl.RegisterNode(salt, nodeAddr, string(nodeCert), nodeAddr,
+		string(nodeCert), "AAAA")
+	if err == nil || !strings.Contains(err.Error(), "locked out") {
+		t.Fatalf("Expected a lockout error, received: %v", err)
+	}
+
+	time.Sleep(params.registrationLockout)
+	err = impl.RegisterNode(salt, nodeAddr, string(nodeCert), nodeAddr,
+		string(nodeCert), "AAAA")
+	if err != nil {
+		t.Errorf("Failed to register after the lockout: %+v", err)
+	}
+}
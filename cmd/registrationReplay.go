@@ -0,0 +1,254 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Handles the replay protection of node registration, which requires each
+// registration to carry a fresh nonce and timestamp signed by the node's key
+
+package cmd
+
+import (
+	"crypto"
+	gorsa "crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	jww "github.com/spf13/jwalterweatherman"
+	pb "gitlab.com/elixxir/comms/mixmessages"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/xx_network/crypto/signature/rsa"
+	"gitlab.com/xx_network/crypto/tls"
+	"google.golang.org/protobuf/encoding/protowire"
+	"sync"
+	"time"
+)
+
+// Field numbers of the replay protection proof in the NodeRegistration
+// message. Until the comms message declares the fields, they are read from the
+// message's unknown fields.
+const (
+	registrationNonceField     protowire.Number = 9  // bytes
+	registrationTimestampField protowire.Number = 10 // varint, Unix nanoseconds
+	registrationSignatureField protowire.Number = 11 // bytes
+)
+
+// Key prefix of the consumed nonces of each registration code in the State
+// table
+const registrationNoncesKeyPrefix = "registration_nonces_"
+
+var (
+	errRegistrationProofMissing = errors.New("node registration has no " +
+		"signed nonce and timestamp")
+	errRegistrationProofInvalid = errors.New("node registration nonce and " +
+		"timestamp are not signed by the node's key")
+	errRegistrationStale = errors.New("node registration timestamp is " +
+		"outside the accepted window")
+	errRegistrationReplayed = errors.New("node registration nonce was " +
+		"already used")
+)
+
+// registrationProof is the nonce and timestamp a node signs to register.
+type registrationProof struct {
+	nonce     []byte
+	timestamp time.Time
+	signature []byte
+}
+
+// getRegistrationProof returns the replay protection proof sent in the
+// registration message, or nil if it has none.
+func getRegistrationProof(msg *pb.NodeRegistration) *registrationProof {
+	unknown := msg.ProtoReflect().GetUnknown()
+	proof := &registrationProof{}
+	var hasTimestamp bool
+	for len(unknown) > 0 {
+		num, typ, n := protowire.ConsumeTag(unknown)
+		if n < 0 {
+			return nil
+		}
+		unknown = unknown[n:]
+
+		var m int
+		switch {
+		case num == registrationNonceField && typ == protowire.BytesType:
+			proof.nonce, m = protowire.ConsumeBytes(unknown)
+		case num == registrationSignatureField && typ == protowire.BytesType:
+			proof.signature, m = protowire.ConsumeBytes(unknown)
+		case num == registrationTimestampField && typ == protowire.VarintType:
+			var v uint64
+			v, m = protowire.ConsumeVarint(unknown)
+			proof.timestamp = time.Unix(0, int64(v))
+			hasTimestamp = true
+		default:
+			m = protowire.ConsumeFieldValue(num, typ, unknown)
+		}
+		if m < 0 {
+			return nil
+		}
+		unknown = unknown[m:]
+	}
+
+	if len(proof.nonce) == 0 || !hasTimestamp || len(proof.signature) == 0 {
+		return nil
+	}
+	return proof
+}
+
+// registrationProofHash returns the hash of the data signed for the proof,
+// which binds the nonce and timestamp to the registration code.
+func registrationProofHash(code string, nonce []byte, timestamp time.Time) []byte {
+	h := sha256.New()
+	h.Write([]byte(code))
+	h.Write(nonce)
+	ts := make([]byte, 8)
+	binary.BigEndian.PutUint64(ts, uint64(timestamp.UnixNano()))
+	h.Write(ts)
+	return h.Sum(nil)
+}
+
+// registrationNonces tracks the nonces consumed by the registrations of each
+// code until they expire. Nonces are persisted to Storage so that a restart
+// does not reopen them to replay.
+type registrationNonces struct {
+	// Nonces of each loaded code, mapped to when they expire
+	codes map[string]map[string]time.Time
+	mux   sync.Mutex
+}
+
+// consume records the nonce as used for the code, returning
+// errRegistrationReplayed if it was already used and has not expired.
+func (rn *registrationNonces) consume(code string, nonce []byte, now time.Time,
+	ttl time.Duration) error {
+	rn.mux.Lock()
+	defer rn.mux.Unlock()
+
+	if rn.codes == nil {
+		rn.codes = make(map[string]map[string]time.Time)
+	}
+	nonces, loaded := rn.codes[code]
+	if !loaded {
+		nonces = loadRegistrationNonces(code)
+		rn.codes[code] = nonces
+	}
+
+	for n, expiry := range nonces {
+		if !now.Before(expiry) {
+			delete(nonces, n)
+		}
+	}
+
+	key := base64.StdEncoding.EncodeToString(nonce)
+	if _, used := nonces[key]; used {
+		return errRegistrationReplayed
+	}
+	nonces[key] = now.Add(ttl)
+	storeRegistrationNonces(code, nonces)
+	return nil
+}
+
+// loadRegistrationNonces returns the consumed nonces of the code stored in
+// Storage, or none if there are none or they cannot be read.
+func loadRegistrationNonces(code string) map[string]time.Time {
+	nonces := make(map[string]time.Time)
+	value, err := storage.PermissioningDb.GetStateValue(
+		registrationNoncesKeyPrefix + code)
+	if err != nil {
+		return nonces
+	}
+	stored := make(map[string]int64)
+	if err = json.Unmarshal([]byte(value), &stored); err != nil {
+		jww.WARN.Printf("Failed to decode the registration nonces of code "+
+			"%s: %+v", code, err)
+		return nonces
+	}
+	for n, expiry := range stored {
+		nonces[n] = time.Unix(0, expiry)
+	}
+	return nonces
+}
+
+// storeRegistrationNonces stores the consumed nonces of the code in Storage.
+func storeRegistrationNonces(code string, nonces map[string]time.Time) {
+	stored := make(map[string]int64, len(nonces))
+	for n, expiry := range nonces {
+		stored[n] = expiry.UnixNano()
+	}
+	value, err := json.Marshal(stored)
+	if err == nil {
+		err = storage.PermissioningDb.UpsertState(&storage.State{
+			Key:   registrationNoncesKeyPrefix + code,
+			Value: string(value),
+		})
+	}
+	if err != nil {
+		jww.WARN.Printf("Failed to store the registration nonces of code "+
+			"%s: %+v", code, err)
+	}
+}
+
+// checkRegistrationProof verifies that the proof is signed by the key of the
+// server certificate, that its timestamp is within the tolerance of now and
+// that its nonce was not used before. Returns the reason the proof fails
+// replay protection, or nil if it passes.
+func (m *RegistrationImpl) checkRegistrationProof(code, serverTlsCert string,
+	proof *registrationProof, now time.Time) error {
+	if proof == nil {
+		return errRegistrationProofMissing
+	}
+
+	// The proof must be signed by the key the node registers with
+	tlsCert, err := tls.LoadCertificate(serverTlsCert)
+	if err != nil {
+		return errors.Errorf("Could not decode server certificate into a "+
+			"tls cert: %v", err)
+	}
+	pubKey, ok := tlsCert.PublicKey.(*gorsa.PublicKey)
+	if !ok {
+		return errors.New("Server certificate does not hold an RSA key")
+	}
+	err = rsa.Verify(&rsa.PublicKey{PublicKey: *pubKey}, crypto.SHA256,
+		registrationProofHash(code, proof.nonce, proof.timestamp),
+		proof.signature, nil)
+	if err != nil {
+		return errors.Wrap(errRegistrationProofInvalid, err.Error())
+	}
+
+	tolerance := m.params.registrationTimestampTolerance
+	if proof.timestamp.Before(now.Add(-tolerance)) ||
+		proof.timestamp.After(now.Add(tolerance)) {
+		return errors.Wrapf(errRegistrationStale, "timestamp %s is more "+
+			"than %s from %s", proof.timestamp.Format(time.RFC3339Nano),
+			tolerance, now.Format(time.RFC3339Nano))
+	}
+
+	// A nonce only needs to be remembered while its timestamp is accepted
+	return m.registrationNonces.consume(code, proof.nonce, now, 2*tolerance)
+}
+
+// RegisterNodeMessage handles a node registration message, checking the signed
+// nonce and timestamp it carries against replays before registering the node
+// as RegisterNode does. serverAddr is the address of the node inferred from
+// its connection. The comms endpoint passes RegisterNode only the message's
+// declared fields, so until it passes the message here, registrations through
+// it carry no proof and are not checked.
+func (m *RegistrationImpl) RegisterNodeMessage(serverAddr string,
+	msg *pb.NodeRegistration) error {
+	err := m.checkRegistrationProof(msg.GetRegistrationCode(),
+		msg.GetServerTlsCert(), getRegistrationProof(msg), time.Now())
+	if err != nil {
+		return err
+	}
+
+	// The gateway address is built as the comms endpoint builds it
+	gatewayAddr := msg.GetGatewayAddress()
+	if gatewayAddr != "" {
+		gatewayAddr = fmt.Sprintf("%s:%d", gatewayAddr, msg.GetGatewayPort())
+	}
+	return m.registerNode(msg.GetSalt(), serverAddr, msg.GetServerTlsCert(),
+		gatewayAddr, msg.GetGatewayTlsCert(), msg.GetRegistrationCode())
+}
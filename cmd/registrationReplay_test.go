@@ -0,0 +1,228 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package cmd
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"github.com/pkg/errors"
+	pb "gitlab.com/elixxir/comms/mixmessages"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/elixxir/registration/storage/node"
+	"gitlab.com/xx_network/crypto/signature/rsa"
+	"google.golang.org/protobuf/encoding/protowire"
+	"testing"
+	"time"
+)
+
+// Builds a registration message for the code carrying a proof with the nonce
+// and timestamp signed by the key of the test node certificate
+func newSignedRegistration(code string, nonce []byte, timestamp time.Time,
+	t *testing.T) *pb.NodeRegistration {
+	key, err := rsa.LoadPrivateKeyFromPem(nodeKey)
+	if err != nil {
+		t.Fatalf("Failed to load node key: %+v", err)
+	}
+	sig, err := rsa.Sign(rand.Reader, key, crypto.SHA256,
+		registrationProofHash(code, nonce, timestamp), nil)
+	if err != nil {
+		t.Fatalf("Failed to sign registration proof: %+v", err)
+	}
+
+	msg := &pb.NodeRegistration{
+		Salt:             bytes.Repeat([]byte{1}, 32),
+		ServerTlsCert:    string(nodeCert),
+		GatewayTlsCert:   string(gatewayCert),
+		GatewayAddress:   "0.0.0.0",
+		GatewayPort:      6901,
+		RegistrationCode: code,
+	}
+	field := protowire.AppendTag(nil, registrationNonceField,
+		protowire.BytesType)
+	field = protowire.AppendBytes(field, nonce)
+	field = protowire.AppendTag(field, registrationTimestampField,
+		protowire.VarintType)
+	field = protowire.AppendVarint(field, uint64(timestamp.UnixNano()))
+	field = protowire.AppendTag(field, registrationSignatureField,
+		protowire.BytesType)
+	field = protowire.AppendBytes(field, sig)
+	msg.ProtoReflect().SetUnknown(field)
+	return msg
+}
+
+// Creates a registration server checking registration proofs against an empty
+// database
+func setupRegistrationReplayTest(t *testing.T) *RegistrationImpl {
+	var err error
+	var dc func() error
+	storage.PermissioningDb, dc, err = storage.NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	t.Cleanup(func() { _ = dc() })
+	return &RegistrationImpl{params: &Params{
+		registrationTimestampTolerance: time.Minute}}
+}
+
+// Tests that a proof is read from the registration message, and that a message
+// without a complete proof has none.
+func TestGetRegistrationProof(t *testing.T) {
+	now := time.Now()
+	msg := newSignedRegistration("AAAA", []byte("nonce"), now, t)
+	proof := getRegistrationProof(msg)
+	if proof == nil || !bytes.Equal(proof.nonce, []byte("nonce")) ||
+		!proof.timestamp.Equal(time.Unix(0, now.UnixNano())) ||
+		len(proof.signature) == 0 {
+		t.Errorf("Unexpected proof: %+v", proof)
+	}
+
+	if proof = getRegistrationProof(&pb.NodeRegistration{}); proof != nil {
+		t.Errorf("Proof read from a message without one: %+v", proof)
+	}
+	partial := &pb.NodeRegistration{}
+	partial.ProtoReflect().SetUnknown(protowire.AppendBytes(
+		protowire.AppendTag(nil, registrationNonceField, protowire.BytesType),
+		[]byte("nonce")))
+	if proof = getRegistrationProof(partial); proof != nil {
+		t.Errorf("Proof read from a message with only a nonce: %+v", proof)
+	}
+}
+
+// Tests that a valid proof passes once, that its replay is refused, and that
+// the refusal holds for a server restarted on the same database.
+func TestRegistrationImpl_checkRegistrationProof_Replayed(t *testing.T) {
+	impl := setupRegistrationReplayTest(t)
+	now := time.Now()
+	proof := getRegistrationProof(
+		newSignedRegistration("AAAA", []byte("nonce"), now, t))
+
+	err := impl.checkRegistrationProof("AAAA", string(nodeCert), proof, now)
+	if err != nil {
+		t.Fatalf("Valid proof was refused: %+v", err)
+	}
+	err = impl.checkRegistrationProof("AAAA", string(nodeCert), proof,
+		now.Add(time.Second))
+	if !errors.Is(err, errRegistrationReplayed) {
+		t.Errorf("Replayed proof was not refused as replayed: %+v", err)
+	}
+
+	// The nonce is consumed across a restart
+	restarted := &RegistrationImpl{params: impl.params}
+	err = restarted.checkRegistrationProof("AAAA", string(nodeCert), proof,
+		now.Add(time.Second))
+	if !errors.Is(err, errRegistrationReplayed) {
+		t.Errorf("Replayed proof was not refused after a restart: %+v", err)
+	}
+
+	// The nonce is only consumed for its code
+	other := getRegistrationProof(
+		newSignedRegistration("BBBB", []byte("nonce"), now, t))
+	err = impl.checkRegistrationProof("BBBB", string(nodeCert), other, now)
+	if err != nil {
+		t.Errorf("Nonce of another code was refused: %+v", err)
+	}
+}
+
+// Tests that proofs with timestamps outside the tolerance are refused as stale.
+func TestRegistrationImpl_checkRegistrationProof_Stale(t *testing.T) {
+	impl := setupRegistrationReplayTest(t)
+	now := time.Now()
+	for i, ts := range []time.Time{now.Add(-2 * time.Minute), now.Add(2 * time.Minute)} {
+		proof := getRegistrationProof(
+			newSignedRegistration("AAAA", []byte{byte(i)}, ts, t))
+		err := impl.checkRegistrationProof("AAAA", string(nodeCert), proof, now)
+		if !errors.Is(err, errRegistrationStale) {
+			t.Errorf("Proof with timestamp %s was not refused as stale: %+v",
+				ts, err)
+		}
+	}
+}
+
+// Tests that a missing proof or one not signed by the node's key is refused.
+func TestRegistrationImpl_checkRegistrationProof_Invalid(t *testing.T) {
+	impl := setupRegistrationReplayTest(t)
+	now := time.Now()
+
+	err := impl.checkRegistrationProof("AAAA", string(nodeCert), nil, now)
+	if !errors.Is(err, errRegistrationProofMissing) {
+		t.Errorf("Missing proof was not refused as missing: %+v", err)
+	}
+
+	// A proof signed for another code does not verify
+	proof := getRegistrationProof(
+		newSignedRegistration("BBBB", []byte("nonce"), now, t))
+	err = impl.checkRegistrationProof("AAAA", string(nodeCert), proof, now)
+	if !errors.Is(err, errRegistrationProofInvalid) {
+		t.Errorf("Proof for another code was not refused as invalid: %+v", err)
+	}
+}
+
+// Tests that a node registers with a signed registration message, that a
+// replay of the message is refused without changing the registration, and
+// that messages without a proof are refused.
+func TestRegistrationImpl_RegisterNodeMessage(t *testing.T) {
+	dblck.Lock()
+	defer dblck.Unlock()
+	var err error
+	var dc func() error
+	storage.PermissioningDb, dc, err = storage.NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	t.Cleanup(func() { _ = dc() })
+	err = storage.PermissioningDb.InsertEphemeralLength(
+		&storage.EphemeralLength{Length: 8, Timestamp: time.Now()})
+	if err != nil {
+		t.Errorf("Failed to insert ephemeral length into database: %+v", err)
+	}
+	storage.PopulateNodeRegistrationCodes([]node.Info{
+		{RegCode: "AAAA", Order: "US"}, {RegCode: "BBBB", Order: "US"}})
+
+	impl, err := StartRegistration(testParams)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	t.Cleanup(impl.Comms.Shutdown)
+	impl.params.registrationTimestampTolerance = time.Minute
+
+	msg := newSignedRegistration("AAAA", []byte("nonce"), time.Now(), t)
+	if err = impl.RegisterNodeMessage(nodeAddr, msg); err != nil {
+		t.Fatalf("Failed to register node: %+v", err)
+	}
+	info, err := storage.PermissioningDb.GetNode("AAAA")
+	if err != nil {
+		t.Fatalf("Failed to get node: %+v", err)
+	}
+	if len(info.Id) == 0 || info.ServerAddress != nodeAddr ||
+		info.GatewayAddress != "0.0.0.0:6901" {
+		t.Errorf("Node was not registered as expected: %+v", info)
+	}
+
+	// The captured message is refused as a replay, even from a new address
+	err = impl.RegisterNodeMessage("1.2.3.4:11420", msg)
+	if !errors.Is(err, errRegistrationReplayed) {
+		t.Errorf("Replayed registration was not refused as replayed: %+v", err)
+	}
+	replayed, err := storage.PermissioningDb.GetNode("AAAA")
+	if err != nil {
+		t.Fatalf("Failed to get node: %+v", err)
+	}
+	if replayed.ServerAddress != nodeAddr {
+		t.Errorf("Replay changed the registered address to %s.",
+			replayed.ServerAddress)
+	}
+
+	// Messages without a proof are refused
+	unsigned := newSignedRegistration("BBBB", []byte("nonce"), time.Now(), t)
+	unsigned.ProtoReflect().SetUnknown(nil)
+	err = impl.RegisterNodeMessage(nodeAddr, unsigned)
+	if !errors.Is(err, errRegistrationProofMissing) {
+		t.Errorf("Registration without a proof was not refused: %+v", err)
+	}
+}
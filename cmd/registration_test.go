@@ -8,6 +8,7 @@
 package cmd
 
 import (
+	gorsa "crypto/rsa"
 	"fmt"
 	jww "github.com/spf13/jwalterweatherman"
 	pb "gitlab.com/elixxir/comms/mixmessages"
@@ -17,6 +18,9 @@ import (
 	"gitlab.com/elixxir/registration/storage/node"
 	"gitlab.com/elixxir/registration/testkeys"
 	"gitlab.com/xx_network/comms/connect"
+	"gitlab.com/xx_network/crypto/signature/rsa"
+	"gitlab.com/xx_network/crypto/tls"
+	"gitlab.com/xx_network/crypto/xx"
 	"gitlab.com/xx_network/primitives/id"
 	"gitlab.com/xx_network/primitives/utils"
 	"os"
@@ -553,3 +557,94 @@ func TestCheckRegistration_InvalidID(t *testing.T) {
 		t.Errorf("Expected error path. Should not be able to marshall an invalid ID")
 	}
 }
+
+// Tests that RegisterNode records whether a node registered with an imported
+// registration code or registered again with the ID stored for its code
+func TestRegistrationImpl_RegisterNode_Source(t *testing.T) {
+	var err error
+	dblck.Lock()
+	defer dblck.Unlock()
+
+	var dc func() error
+	storage.PermissioningDb, dc, err = storage.NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	defer func() { _ = dc() }()
+	err = storage.PermissioningDb.InsertEphemeralLength(
+		&storage.EphemeralLength{Length: 8, Timestamp: time.Now()})
+	if err != nil {
+		t.Errorf("Failed to insert ephemeral length into database: %+v", err)
+	}
+
+	// Store the ID the node generates for BBBB without its salt, as left by
+	// a registration which did not complete
+	salt := []byte("testtesttesttesttesttesttesttest")
+	reRegSalt := []byte("salttesttesttesttesttesttesttest")
+	tlsCert, err := tls.LoadCertificate(string(nodeCert))
+	if err != nil {
+		t.Fatalf("Failed to load node certificate: %+v", err)
+	}
+	nodePubKey := &rsa.PublicKey{PublicKey: *tlsCert.PublicKey.(*gorsa.PublicKey)}
+	reRegId, err := xx.NewID(nodePubKey, reRegSalt, id.Node)
+	if err != nil {
+		t.Fatalf("Failed to generate node ID: %+v", err)
+	}
+
+	storage.PopulateNodeRegistrationCodes([]node.Info{{RegCode: "AAAA", Order: "US"}})
+	err = storage.PermissioningDb.InsertApplication(&storage.Application{Id: 2},
+		&storage.Node{Code: "BBBB", Sequence: "GB", Id: reRegId.Marshal(),
+			ApplicationId: 2, RegistrationSource: storage.BulkImportRegistration})
+	if err != nil {
+		t.Fatalf("Failed to insert application: %+v", err)
+	}
+
+	impl, err := StartRegistration(testParams)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer impl.Comms.Shutdown()
+
+	err = impl.RegisterNode(salt, nodeAddr, string(nodeCert), nodeAddr,
+		string(nodeCert), "AAAA")
+	if err != nil {
+		t.Fatalf("Failed to register node: %+v", err)
+	}
+
+	// Leave poll sources from before the registration in the state
+	err = impl.State.GetNodeMap().AddNode(reRegId, "GB", "0.0.0.0:6901",
+		"0.0.0.0:6901", 2)
+	if err != nil {
+		t.Fatalf("Failed to add node to the state: %+v", err)
+	}
+	reRegNode := impl.State.GetNodeMap().GetNode(reRegId)
+	reRegNode.RecordPollSource("1.1.1.1", time.Now(), time.Minute)
+	reRegNode.SetPollSourceAnomaly(true)
+
+	err = impl.RegisterNode(reRegSalt, "0.0.0.0:6901", string(nodeCert),
+		"0.0.0.0:6901", string(nodeCert), "BBBB")
+	if err != nil {
+		t.Fatalf("Failed to re-register node: %+v", err)
+	}
+	if sources := reRegNode.GetPollSources(); len(sources) != 0 {
+		t.Errorf("Poll sources were kept across re-registration: %+v", sources)
+	}
+	if !reRegNode.SetPollSourceAnomaly(true) {
+		t.Errorf("Poll source anomaly was kept across re-registration.")
+	}
+
+	expected := map[string]string{
+		"AAAA": storage.SelfServeRegistration,
+		"BBBB": storage.ReRegistration,
+	}
+	for code, source := range expected {
+		n, err := storage.PermissioningDb.GetNode(code)
+		if err != nil {
+			t.Fatalf("Failed to get node %s: %+v", code, err)
+		}
+		if n.RegistrationSource != source {
+			t.Errorf("Unexpected registration source for %s."+
+				"\nexpected: %s\nreceived: %s", code, source, n.RegistrationSource)
+		}
+	}
+}
@@ -40,6 +40,7 @@ var (
 	RegParams            Params
 	disablePermissioning bool
 	disabledNodesPath    string
+	allowGroupChange     bool
 
 	// Storage of registration codes from file so it can be loaded from disableRegCodes
 	regCodeInfos    []node.Info
@@ -126,6 +127,12 @@ var rootCmd = &cobra.Command{
 		localAddress := fmt.Sprintf("0.0.0.0:%d", viper.GetInt("port"))
 		fullNdfOutputPath := viper.GetString("fullNdfOutputPath")
 		signedPartialNdfOutputPath := viper.GetString("signedPartialNDFOutputPath")
+
+		err = checkGroups(cmix, e2e, getGroupPrimeBits(), fullNdfOutputPath,
+			allowGroupChange)
+		if err != nil {
+			jww.FATAL.Panicf("Failed to verify groups: %+v", err)
+		}
 		whitelistedIdsPath := viper.GetString("whitelistedIdsPath")
 		whitelistedIpAddressesPath := viper.GetString("whitelistedIpAddressesPath")
 
@@ -158,6 +165,22 @@ var rootCmd = &cobra.Command{
 			jww.FATAL.Panicf("Unable to initialize storage: %+v", err)
 		}
 
+		// Configure retrying of failed metric writes
+		storage.PermissioningDb.SetMetricRetry(
+			viper.GetUint("metricWriteAttempts"),
+			viper.GetDuration("metricWriteBackoff"))
+
+		// Limit the number of errors stored for a single round
+		storage.PermissioningDb.SetRoundErrorLimit(viper.GetUint("roundErrorLimit"))
+
+		// Monitor the database connection and run in degraded mode while it
+		// cannot be reached
+		dbHealthQuitChan := make(chan struct{})
+		storage.PermissioningDb.StartHealthMonitor(
+			viper.GetDuration("dbHealthCheckInterval"),
+			viper.GetDuration("dbReconnectMaxBackoff"),
+			viper.GetInt("dbDegradedWriteBuffer"), dbHealthQuitChan)
+
 		// Populate Node registration codes into the database
 		RegCodesFilePath := viper.GetString("regCodesFilePath")
 		if RegCodesFilePath != "" {
@@ -238,6 +261,8 @@ var rootCmd = &cobra.Command{
 
 		viper.SetDefault("messageRetentionLimit", defaultMessageRetention)
 
+		viper.SetDefault("avoidListWarnFraction", storage.DefaultAvoidListWarnFraction)
+
 		// Get rate limiting values
 		capacity := viper.GetUint32("RateLimiting.Capacity")
 		if capacity == 0 {
@@ -253,6 +278,67 @@ var rootCmd = &cobra.Command{
 		}
 		leakedDurations = leakedDurations * uint64(time.Millisecond)
 
+		// Determine how long between storing Node metrics
+		nodeMetricInterval := time.Duration(
+			viper.GetInt64("nodeMetricInterval")) * time.Second
+
+		// Determine how long sources failing node registration are locked out
+		registrationLockout := viper.GetDuration("registrationLockout")
+		if registrationLockout == 0 {
+			registrationLockout = 15 * time.Minute
+		}
+
+		viper.SetDefault("registrationTimestampTolerance", 5*time.Minute)
+
+		// A tenth of the nodes required for readiness may go missing before
+		// the network is degraded again
+		viper.SetDefault("readinessHysteresis", 0.1)
+
+		// Check NDFs unmarshal back to their source unless disabled
+		viper.SetDefault("ndfRoundTripCheck", true)
+
+		// Prune node metrics hourly once a retention is set
+		viper.SetDefault("nodeMetricPruneInterval", time.Hour)
+
+		// Find node uptimes from the last day of node metrics
+		viper.SetDefault("uptimeWindow", 24*time.Hour)
+
+		// Keep NDF snapshots for 30 days
+		viper.SetDefault("ndfSnapshotRetention", 30*24*time.Hour)
+
+		// Release polling locks whose updates were not handled in 5 minutes
+		viper.SetDefault("pollingLockTimeout", 5*time.Minute)
+
+		// Bound the connectivity checks run at once
+		viper.SetDefault("connectivityCheckWorkers", 64)
+
+		// Re-bin ten nodes a second
+		viper.SetDefault("geoRebinInterval", 100*time.Millisecond)
+
+		// Determine the window restored node connectivity is checked again over
+		connectivityReprobeWindow := viper.GetDuration("connectivityReprobeWindow")
+		if connectivityReprobeWindow == 0 {
+			connectivityReprobeWindow = 10 * time.Minute
+		}
+
+		// Determine the delay between connectivity probe attempts
+		connectivityProbeRetryDelay := viper.GetDuration("connectivityProbeRetryDelay")
+		if connectivityProbeRetryDelay == 0 {
+			connectivityProbeRetryDelay = 500 * time.Millisecond
+		}
+
+		// Determine the window the addresses nodes poll from are counted over
+		pollSourceWindow := viper.GetDuration("pollSourceWindow")
+		if pollSourceWindow == 0 {
+			pollSourceWindow = 10 * time.Minute
+		}
+
+		// Determine how far a node's last active time may be in the future
+		lastActiveFutureTolerance := viper.GetDuration("lastActiveFutureTolerance")
+		if lastActiveFutureTolerance == 0 {
+			lastActiveFutureTolerance = time.Minute
+		}
+
 		// Populate params
 		RegParams = Params{
 			Address:                    localAddress,
@@ -295,11 +381,115 @@ var rootCmd = &cobra.Command{
 			leakedCapacity: capacity,
 			leakedTokens:   leakedTokens,
 			leakedDuration: leakedDurations,
-		}
 
-		// Determine how long between storing Node metrics
-		nodeMetricInterval := time.Duration(
-			viper.GetInt64("nodeMetricInterval")) * time.Second
+			errorRedactionPatterns: viper.GetStringSlice("errorRedactionPatterns"),
+
+			avoidListWarnFraction: viper.GetFloat64("avoidListWarnFraction"),
+			preferObservedAddress: viper.GetBool("preferObservedAddress"),
+
+			validateNodeCerts:       viper.GetBool("validateNodeCerts"),
+			excludeInvalidCertNodes: viper.GetBool("excludeInvalidCertNodes"),
+
+			ndfStreamBuffer: viper.GetInt("ndfStreamBuffer"),
+
+			ndfHistoryLimit: viper.GetInt("ndfHistoryLimit"),
+			ndfHistoryPath:  viper.GetString("ndfHistoryPath"),
+
+			ndfChangelogLimit:   viper.GetInt("ndfChangelogLimit"),
+			ndfChangelogPersist: viper.GetBool("ndfChangelogPersist"),
+			ndfRoundTripCheck:   viper.GetBool("ndfRoundTripCheck"),
+			ndfWriteDebounce:    viper.GetDuration("ndfWriteDebounce"),
+			ndfWriteJitter:      viper.GetDuration("ndfWriteJitter"),
+
+			ndfSnapshots:         viper.GetBool("ndfSnapshots"),
+			ndfSnapshotRetention: viper.GetDuration("ndfSnapshotRetention"),
+
+			ndfSpreadWindow:   viper.GetDuration("ndfSpreadWindow"),
+			ndfSpreadCritical: viper.GetStringSlice("ndfSpreadCritical"),
+
+			registrationAttemptLimit: viper.GetUint("registrationAttemptLimit"),
+			registrationLockout:      registrationLockout,
+
+			registrationTimestampTolerance: viper.GetDuration("registrationTimestampTolerance"),
+
+			persistConnectivity:       viper.GetBool("persistConnectivity"),
+			connectivityReprobeWindow: connectivityReprobeWindow,
+
+			connectivityProbeRetries:    viper.GetUint("connectivityProbeRetries"),
+			connectivityProbeRetryDelay: connectivityProbeRetryDelay,
+			pollingLockTimeout:          viper.GetDuration("pollingLockTimeout"),
+			maxConcurrentPolls:          viper.GetUint("maxConcurrentPolls"),
+			appPollRate:                 viper.GetFloat64("appPollRate"),
+			appPollBurst:                viper.GetUint("appPollBurst"),
+
+			connectivityCheckWorkers:   viper.GetInt("connectivityCheckWorkers"),
+			connectivityCheckQueueSize: viper.GetInt("connectivityCheckQueueSize"),
+
+			pollCaptureFraction: viper.GetFloat64("pollCaptureFraction"),
+			pollCaptureSize:     viper.GetInt("pollCaptureSize"),
+
+			earlyErrorValidation: viper.GetBool("earlyErrorValidation"),
+
+			geoRebinInterval: viper.GetDuration("geoRebinInterval"),
+			geoBinsPath:      viper.GetString("geoBinsPath"),
+
+			readinessMinNodes:        viper.GetUint32("readinessMinNodes"),
+			readinessMinNodeFraction: viper.GetFloat64("readinessMinNodeFraction"),
+			readinessHysteresis:      viper.GetFloat64("readinessHysteresis"),
+			readinessStrict:          viper.GetBool("readinessStrict"),
+
+			ndfReconcilePolicy: viper.GetString("ndfReconcilePolicy"),
+
+			pollSourceWindow:    pollSourceWindow,
+			pollSourceThreshold: viper.GetInt("pollSourceThreshold"),
+
+			allowNodeKeyChange: viper.GetBool("allowNodeKeyChange"),
+
+			allowGatewaylessNodes: viper.GetBool("allowGatewaylessNodes"),
+
+			lastActiveFutureTolerance: lastActiveFutureTolerance,
+
+			bannedPollNotice:   viper.GetBool("bannedPollNotice"),
+			bannedPollReason:   viper.GetString("bannedPollReason"),
+			bannedPollInterval: viper.GetDuration("bannedPollInterval"),
+
+			requireMonotonicUpdates: viper.GetBool("requireMonotonicUpdates"),
+
+			legacyNdfOutputPath: viper.GetString("legacyNdfOutputPath"),
+
+			roundHealthWindow:  viper.GetDuration("roundHealthWindow"),
+			updateLagThreshold: viper.GetDuration("updateLagThreshold"),
+			nodeStaleThreshold: viper.GetDuration("nodeStaleThreshold"),
+			nodeDeadThreshold:  viper.GetDuration("nodeDeadThreshold"),
+
+			gatewayAckLagThreshold: viper.GetUint64("gatewayAckLagThreshold"),
+
+			autoUnbanServerVersion:      viper.GetString("autoUnbanServerVersion"),
+			autoUnbanGatewayVersion:     viper.GetString("autoUnbanGatewayVersion"),
+			autoUnbanConnectivityProbes: viper.GetUint("autoUnbanConnectivityProbes"),
+
+			blacklistPath:  viper.GetString("blacklistPath"),
+			blacklistUnban: viper.GetBool("blacklistUnban"),
+
+			diagnosticWebhook: viper.GetString("diagnosticWebhook"),
+
+			addressAllowlist:           viper.GetStringSlice("addressAllowlist"),
+			requireRegisteredAddresses: viper.GetBool("requireRegisteredAddresses"),
+
+			removeCriticalNodes: viper.GetBool("removeCriticalNodes"),
+
+			versionGraceWindow: viper.GetDuration("versionGraceWindow"),
+
+			experimentalTransitionTable: viper.GetString("experimentalTransitionTable"),
+
+			debugRounds: viper.GetIntSlice("debugRounds"),
+			debugNodes:  viper.GetStringSlice("debugNodes"),
+
+			nodeMetricInterval:      nodeMetricInterval,
+			nodeMetricRetention:     viper.GetDuration("nodeMetricRetention"),
+			nodeMetricPruneInterval: viper.GetDuration("nodeMetricPruneInterval"),
+			uptimeWindow:            viper.GetDuration("uptimeWindow"),
+		}
 
 		jww.INFO.Println("Starting Permissioning Server...")
 		jww.INFO.Printf("Params: %+v", RegParams)
@@ -337,6 +527,19 @@ var rootCmd = &cobra.Command{
 				"disabled Node list polling.")
 		}
 
+		// Ban the blacklisted nodes and reload the blacklist on SIGHUP
+		if err = impl.loadBlacklist(); err != nil {
+			jww.FATAL.Panicf("Failed to load node blacklist: %+v", err)
+		}
+		ReceiveHUPSignal(func() {
+			if err := impl.loadBlacklist(); err != nil {
+				jww.ERROR.Printf("Failed to reload node blacklist: %+v", err)
+			}
+			if err := impl.LoadGeoBins(); err != nil {
+				jww.ERROR.Printf("%+v", err)
+			}
+		})
+
 		// Parse params JSON
 		params := scheduling.ParseParams(SchedulingConfig)
 
@@ -351,6 +554,13 @@ var rootCmd = &cobra.Command{
 		metricTrackerQuitChan := make(chan struct{})
 		go TrackNodeMetrics(impl, metricTrackerQuitChan, nodeMetricInterval)
 
+		// Prune node metrics past their retention, if one is set
+		metricPrunerQuitChan := make(chan struct{})
+		if impl.params.nodeMetricRetention > 0 {
+			go PruneNodeMetrics(impl, metricPrunerQuitChan,
+				impl.params.nodeMetricPruneInterval)
+		}
+
 		// Run address space updater until stopped
 		viper.SetDefault("addressSpaceSizeUpdateInterval", 5*time.Minute)
 		addressSpaceSizeUpdateInterval := viper.GetDuration("addressSpaceSizeUpdateInterval")
@@ -371,9 +581,14 @@ var rootCmd = &cobra.Command{
 				case <-ticker.C:
 					// Keep track of banned nodes
 					err = BannedNodeTracker(impl)
-					if err != nil {
+					if err != nil && storage.PermissioningDb.IsDegraded() {
+						jww.WARN.Printf("Skipping banned node check while "+
+							"storage is degraded: %v", err)
+					} else if err != nil {
 						jww.FATAL.Panicf("BannedNodeTracker failed: %v", err)
 					}
+					// Unban the banned nodes meeting an unban condition
+					impl.checkAutoUnban()
 				case <-quitChan:
 					break nodeTrackerLoop
 				}
@@ -435,12 +650,23 @@ var rootCmd = &cobra.Command{
 			// Stop round metrics tracker
 			metricTrackerQuitChan <- struct{}{}
 
+			// Stop node metrics pruning
+			if impl.params.nodeMetricRetention > 0 {
+				metricPrunerQuitChan <- struct{}{}
+			}
+
 			// Stop polling for disabled Nodes
 			disabledNodePollQuitChan <- struct{}{}
 
 			// Stop address space tracker
 			addressSpaceTrackerQuitChan <- struct{}{}
 
+			// Stop database health monitor
+			dbHealthQuitChan <- struct{}{}
+
+			// Write out any NDF held by the write debounce
+			impl.State.FlushNdfWrites()
+
 			// Close GeoIP2 reader
 			impl.geoIPDBStatus.ToStopped()
 			err := impl.geoIPDB.Close()
@@ -527,6 +753,10 @@ func init() {
 	rootCmd.Flags().BoolVarP(&disablePermissioning, "disablePermissioning", "",
 		false, "Disables registration server checking for ndf updates")
 
+	rootCmd.Flags().BoolVar(&allowGroupChange, "allow-group-change", false,
+		"Allows starting with cMix or E2E groups which differ from the "+
+			"groups in the previously output NDF")
+
 	err := viper.BindPFlag("closeTimeout",
 		rootCmd.Flags().Lookup("close-timeout"))
 	if err != nil {
@@ -619,9 +849,12 @@ func initConfig() {
 }
 
 func (m *RegistrationImpl) update(in fsnotify.Event) {
+	previousPolicy := m.currentPolicyValues()
 	m.updateVersions()
 	m.updateRateLimiting()
 	m.updateEarliestRound()
+	m.updatePolicyVersion(previousPolicy)
+	m.checkGroupReload()
 
 }
 
@@ -666,7 +899,7 @@ func (m *RegistrationImpl) updateRateLimiting() {
 func (m *RegistrationImpl) updateVersions() {
 	// Parse version strings
 	clientVersion := viper.GetString("minClientVersion")
-	_, err := version.ParseVersion(clientVersion)
+	minClientVersion, err := version.ParseVersion(clientVersion)
 	if err != nil {
 		jww.FATAL.Panicf("Attempted client version update is invalid: %v", err)
 	}
@@ -693,10 +926,10 @@ func (m *RegistrationImpl) updateVersions() {
 	m.State.UpdateInternalNdf(updateNDF)
 	m.State.InternalNdfLock.Unlock()
 
-	// Modify server and gateway versions
+	// Modify server, gateway and client versions
 	m.params.versionLock.Lock()
-	m.params.minGatewayVersion = minGatewayVersion
-	m.params.minServerVersion = minServerVersion
+	m.params.setMinVersions(minGatewayVersion, minServerVersion, time.Now())
+	m.params.minClientVersion = minClientVersion
 	m.params.versionLock.Unlock()
 }
 
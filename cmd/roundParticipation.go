@@ -0,0 +1,42 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Contains the administrative function for reporting how many rounds a node
+// took part in
+
+package cmd
+
+import (
+	"github.com/pkg/errors"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/xx_network/comms/connect"
+	"gitlab.com/xx_network/primitives/id"
+	"time"
+)
+
+// GetNodeRoundParticipation returns the number of rounds ending at or after
+// start and before end that the node was part of the team of.
+func (m *RegistrationImpl) GetNodeRoundParticipation(auth *connect.Auth,
+	nodeId *id.ID, start, end time.Time) (uint64, error) {
+	if err := checkAdminAuth(auth); err != nil {
+		return 0, err
+	}
+	if nodeId == nil {
+		return 0, errors.New("Cannot get round participation: no node ID given")
+	}
+	if !end.After(start) {
+		return 0, errors.Errorf("Cannot get round participation: end %s is "+
+			"not after start %s", end, start)
+	}
+
+	count, err := storage.PermissioningDb.GetNodeRoundParticipation(nodeId, start, end)
+	if err != nil {
+		return 0, errors.WithMessagef(err, "Failed to get round "+
+			"participation of node %s", nodeId)
+	}
+	return count, nil
+}
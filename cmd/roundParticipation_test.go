@@ -0,0 +1,75 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package cmd
+
+import (
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/xx_network/comms/connect"
+	"gitlab.com/xx_network/primitives/id"
+	"testing"
+	"time"
+)
+
+// Tests that only the permissioning server can get the round participation of
+// a node and that it counts the rounds within the window.
+func TestRegistrationImpl_GetNodeRoundParticipation(t *testing.T) {
+	var err error
+	var dc func() error
+	storage.PermissioningDb, dc, err = storage.NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("Failed to create database: %+v", err)
+	}
+	t.Cleanup(func() { _ = dc() })
+	impl := &RegistrationImpl{}
+
+	nid := id.NewIdFromString("node", id.Node, t)
+	err = storage.PermissioningDb.InsertApplication(&storage.Application{Id: 1},
+		&storage.Node{Code: "AAAA", Id: nid.Marshal()})
+	if err != nil {
+		t.Fatalf("Failed to insert node: %+v", err)
+	}
+	now := time.Now()
+	for i, roundEnd := range []time.Time{now, now.Add(-time.Minute), now.Add(-2 * time.Hour)} {
+		err = storage.PermissioningDb.InsertRoundMetric(&storage.RoundMetric{
+			Id: uint64(i + 1), RoundEnd: roundEnd}, [][]byte{nid.Marshal()})
+		if err != nil {
+			t.Fatalf("Failed to insert round metric: %+v", err)
+		}
+	}
+
+	permHost, err := connect.NewHost(&id.Permissioning, "", nil,
+		connect.GetDefaultHostParams())
+	if err != nil {
+		t.Fatalf("Failed to create host: %+v", err)
+	}
+	nodeHost, err := connect.NewHost(nid, "", nil, connect.GetDefaultHostParams())
+	if err != nil {
+		t.Fatalf("Failed to create host: %+v", err)
+	}
+	start, end := now.Add(-time.Hour), now.Add(time.Minute)
+
+	_, err = impl.GetNodeRoundParticipation(
+		&connect.Auth{IsAuthenticated: true, Sender: nodeHost}, nid, start, end)
+	if err == nil {
+		t.Errorf("Node was able to get round participation.")
+	}
+
+	auth := &connect.Auth{IsAuthenticated: true, Sender: permHost}
+	count, err := impl.GetNodeRoundParticipation(auth, nid, start, end)
+	if err != nil {
+		t.Fatalf("GetNodeRoundParticipation() returned an error: %+v", err)
+	}
+	if count != 2 {
+		t.Errorf("Unexpected participation count.\nexpected: %d\nreceived: %d",
+			2, count)
+	}
+
+	if _, err = impl.GetNodeRoundParticipation(auth, nid, end, start); err == nil {
+		t.Errorf("Expected an error for a window ending before it starts.")
+	}
+}
@@ -0,0 +1,126 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Contains the administrative functions for inspecting rounds in progress and
+// the scheduler handling them
+
+package cmd
+
+import (
+	"gitlab.com/elixxir/primitives/current"
+	"gitlab.com/elixxir/primitives/states"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/elixxir/registration/storage/node"
+	"gitlab.com/xx_network/comms/connect"
+	"gitlab.com/xx_network/primitives/id"
+	"time"
+)
+
+// GetRoundStateCounts returns the number of members of the round's team in
+// each round state, to find the members holding up a round which has not
+// transitioned.
+func (m *RegistrationImpl) GetRoundStateCounts(auth *connect.Auth,
+	roundId id.Round) ([states.NUM_STATES]uint32, error) {
+	if err := checkAdminAuth(auth); err != nil {
+		return [states.NUM_STATES]uint32{}, err
+	}
+	return m.State.GetRoundStateCounts(roundId)
+}
+
+// GetRoundHealth returns the number of rounds which completed and failed
+// across the network within the configured round health window, for an
+// at-a-glance view of whether the network is healthy.
+func (m *RegistrationImpl) GetRoundHealth(auth *connect.Auth) (storage.RoundHealth, error) {
+	if err := checkAdminAuth(auth); err != nil {
+		return storage.RoundHealth{}, err
+	}
+	return m.State.GetRoundHealth(time.Now()), nil
+}
+
+// GetRoundLatencyPercentiles returns the 50th, 95th and 99th percentile of the
+// realtime durations of the rounds which finished realtime within the window,
+// to show the tail latency averages hide.
+func (m *RegistrationImpl) GetRoundLatencyPercentiles(auth *connect.Auth,
+	window time.Duration) (p50, p95, p99 time.Duration, err error) {
+	if err = checkAdminAuth(auth); err != nil {
+		return 0, 0, 0, err
+	}
+	return storage.PermissioningDb.GetRoundLatencyPercentiles(window)
+}
+
+// GetRoundTopology returns the team of the stored round in the order it ran
+// in. Rounds stored without their topology have an empty team.
+func (m *RegistrationImpl) GetRoundTopology(auth *connect.Auth,
+	roundId id.Round) ([]*id.ID, error) {
+	if err := checkAdminAuth(auth); err != nil {
+		return nil, err
+	}
+	return storage.PermissioningDb.GetRoundTopology(roundId)
+}
+
+// GetRoundSchedulingConfig returns the JSON encoded scheduling params the
+// stored round was formed with, to correlate its performance with the params
+// that produced it. Rounds stored without them have a nil config.
+func (m *RegistrationImpl) GetRoundSchedulingConfig(auth *connect.Auth,
+	roundId id.Round) ([]byte, error) {
+	if err := checkAdminAuth(auth); err != nil {
+		return nil, err
+	}
+	config, err := storage.PermissioningDb.GetRoundSchedulingConfig(roundId)
+	if err != nil || config == nil {
+		return nil, err
+	}
+	return []byte(config.Config), nil
+}
+
+// GetUpdateLagStats returns how long node updates waited between being
+// produced by a poll and being handled by the scheduler, to find when the
+// scheduler falls behind the nodes.
+func (m *RegistrationImpl) GetUpdateLagStats(auth *connect.Auth) (storage.UpdateLagStats, error) {
+	if err := checkAdminAuth(auth); err != nil {
+		return storage.UpdateLagStats{}, err
+	}
+	return m.State.GetUpdateLagStats(), nil
+}
+
+// GetSchedulingExclusions returns the number of times nodes were left out of
+// the teams formed by the scheduler for each reason across the network,
+// indexed by node.ExclusionReason. The breakdown for each node is in the node
+// map snapshot.
+func (m *RegistrationImpl) GetSchedulingExclusions(auth *connect.Auth) ([node.NumExclusionReasons]uint64, error) {
+	if err := checkAdminAuth(auth); err != nil {
+		return [node.NumExclusionReasons]uint64{}, err
+	}
+	return m.State.GetExclusions(), nil
+}
+
+// GetNodesByActivity returns the IDs of the nodes in each activity, to see
+// where the nodes are held up when rounds are not being scheduled. Activities
+// no node is in are left out.
+func (m *RegistrationImpl) GetNodesByActivity(auth *connect.Auth) (map[current.Activity][]*id.ID, error) {
+	if err := checkAdminAuth(auth); err != nil {
+		return nil, err
+	}
+	nodes := make(map[current.Activity][]*id.ID)
+	for a := current.Activity(0); a < current.NUM_STATES; a++ {
+		if ids := m.State.GetNodeMap().GetNodesByActivity(a); len(ids) > 0 {
+			nodes[a] = ids
+		}
+	}
+	return nodes, nil
+}
+
+// GetNodeStaleness returns the time since the node last polled and whether it
+// is fresh, stale or dead by the configured thresholds, for a quick read on
+// its liveness.
+func (m *RegistrationImpl) GetNodeStaleness(auth *connect.Auth,
+	nodeId *id.ID) (time.Duration, storage.StalenessClass, error) {
+	if err := checkAdminAuth(auth); err != nil {
+		return 0, storage.Dead, err
+	}
+	return m.State.GetNodeStaleness(nodeId)
+}
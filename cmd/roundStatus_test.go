@@ -0,0 +1,469 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package cmd
+
+import (
+	"gitlab.com/elixxir/primitives/current"
+	"gitlab.com/elixxir/primitives/states"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/elixxir/registration/storage/node"
+	"gitlab.com/xx_network/comms/connect"
+	"gitlab.com/xx_network/primitives/id"
+	"gitlab.com/xx_network/primitives/region"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// Tests that only the permissioning server can get the state counts of a
+// round and that they match the team's activities.
+func TestRegistrationImpl_GetRoundStateCounts(t *testing.T) {
+	var err error
+	var dc func() error
+	storage.PermissioningDb, dc, err = storage.NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("Failed to create database: %+v", err)
+	}
+	t.Cleanup(func() { _ = dc() })
+	state, err := storage.NewState(getTestKey(), 8, "", "", region.GetCountryBins())
+	if err != nil {
+		t.Fatalf("Failed to create state: %+v", err)
+	}
+	impl := &RegistrationImpl{State: state}
+
+	team := []*id.ID{id.NewIdFromUInt(1, id.Node, t), id.NewIdFromUInt(2, id.Node, t)}
+	for _, nid := range team {
+		if err = state.GetNodeMap().AddNode(nid, "", "", "", 0); err != nil {
+			t.Fatalf("Failed to add node: %+v", err)
+		}
+	}
+	r, err := state.GetRoundMap().AddRound(1, 32, 8, time.Minute,
+		connect.NewCircuit(team))
+	if err != nil {
+		t.Fatalf("Failed to add round: %+v", err)
+	}
+	for i, activity := range []current.Activity{current.STANDBY, current.PRECOMPUTING} {
+		if err = state.GetNodeMap().GetNode(team[i]).ResumeRound(r, activity); err != nil {
+			t.Fatalf("Failed to place node in round: %+v", err)
+		}
+	}
+
+	permHost, err := connect.NewHost(&id.Permissioning, "", nil,
+		connect.GetDefaultHostParams())
+	if err != nil {
+		t.Fatalf("Failed to create host: %+v", err)
+	}
+	nodeHost, err := connect.NewHost(team[0], "", nil, connect.GetDefaultHostParams())
+	if err != nil {
+		t.Fatalf("Failed to create host: %+v", err)
+	}
+
+	_, err = impl.GetRoundStateCounts(
+		&connect.Auth{IsAuthenticated: true, Sender: nodeHost}, 1)
+	if err == nil {
+		t.Errorf("Node was able to get round state counts.")
+	}
+
+	counts, err := impl.GetRoundStateCounts(
+		&connect.Auth{IsAuthenticated: true, Sender: permHost}, 1)
+	if err != nil {
+		t.Fatalf("GetRoundStateCounts() returned an error: %+v", err)
+	}
+	if counts[states.STANDBY] != 1 || counts[states.PRECOMPUTING] != 1 {
+		t.Errorf("Unexpected state counts: %v", counts)
+	}
+}
+
+// Tests that only the permissioning server can get the round health and that
+// it reports the rounds which ended within the window.
+func TestRegistrationImpl_GetRoundHealth(t *testing.T) {
+	var err error
+	var dc func() error
+	storage.PermissioningDb, dc, err = storage.NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("Failed to create database: %+v", err)
+	}
+	t.Cleanup(func() { _ = dc() })
+	state, err := storage.NewState(getTestKey(), 8, "", "", region.GetCountryBins())
+	if err != nil {
+		t.Fatalf("Failed to create state: %+v", err)
+	}
+	impl := &RegistrationImpl{State: state}
+	state.SetRoundHealthWindow(time.Minute)
+	state.RecordRoundFailed(time.Now().Add(-2 * time.Minute))
+	state.RecordRoundCompleted(time.Now())
+	state.RecordRoundFailed(time.Now())
+
+	nodeHost, err := connect.NewHost(id.NewIdFromUInt(1, id.Node, t), "", nil,
+		connect.GetDefaultHostParams())
+	if err != nil {
+		t.Fatalf("Failed to create host: %+v", err)
+	}
+	_, err = impl.GetRoundHealth(&connect.Auth{IsAuthenticated: true, Sender: nodeHost})
+	if err == nil {
+		t.Errorf("Node was able to get the round health.")
+	}
+
+	permHost, err := connect.NewHost(&id.Permissioning, "", nil,
+		connect.GetDefaultHostParams())
+	if err != nil {
+		t.Fatalf("Failed to create host: %+v", err)
+	}
+	health, err := impl.GetRoundHealth(&connect.Auth{IsAuthenticated: true, Sender: permHost})
+	if err != nil {
+		t.Fatalf("Failed to get the round health: %+v", err)
+	}
+	if health.Window != time.Minute || health.Completed != 1 || health.Failed != 1 {
+		t.Errorf("Unexpected round health: %+v", health)
+	}
+}
+
+// Tests that only the permissioning server can get the scheduling exclusions
+// and that they are the network totals.
+func TestRegistrationImpl_GetSchedulingExclusions(t *testing.T) {
+	var err error
+	var dc func() error
+	storage.PermissioningDb, dc, err = storage.NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("Failed to create database: %+v", err)
+	}
+	t.Cleanup(func() { _ = dc() })
+	state, err := storage.NewState(getTestKey(), 8, "", "", region.GetCountryBins())
+	if err != nil {
+		t.Fatalf("Failed to create state: %+v", err)
+	}
+	impl := &RegistrationImpl{State: state}
+	for i := uint64(0); i < 2; i++ {
+		nid := id.NewIdFromUInt(i, id.Node, t)
+		if err = state.GetNodeMap().AddNode(nid, "US", "", "", i); err != nil {
+			t.Fatalf("Failed to add node: %+v", err)
+		}
+		state.RecordExclusion(state.GetNodeMap().GetNode(nid),
+			node.ExcludedOffline, 1)
+	}
+
+	nodeHost, err := connect.NewHost(id.NewIdFromUInt(1, id.Node, t), "", nil,
+		connect.GetDefaultHostParams())
+	if err != nil {
+		t.Fatalf("Failed to create host: %+v", err)
+	}
+	_, err = impl.GetSchedulingExclusions(&connect.Auth{IsAuthenticated: true, Sender: nodeHost})
+	if err == nil {
+		t.Errorf("Node was able to get the scheduling exclusions.")
+	}
+
+	permHost, err := connect.NewHost(&id.Permissioning, "", nil,
+		connect.GetDefaultHostParams())
+	if err != nil {
+		t.Fatalf("Failed to create host: %+v", err)
+	}
+	totals, err := impl.GetSchedulingExclusions(&connect.Auth{IsAuthenticated: true, Sender: permHost})
+	if err != nil {
+		t.Fatalf("Failed to get the scheduling exclusions: %+v", err)
+	}
+	if totals[node.ExcludedOffline] != 2 {
+		t.Errorf("Unexpected scheduling exclusions: %v", totals)
+	}
+}
+
+// Tests that only the permissioning server can list the nodes in each
+// activity and that every node is listed under its activity.
+func TestRegistrationImpl_GetNodesByActivity(t *testing.T) {
+	var err error
+	var dc func() error
+	storage.PermissioningDb, dc, err = storage.NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("Failed to create database: %+v", err)
+	}
+	t.Cleanup(func() { _ = dc() })
+	state, err := storage.NewState(getTestKey(), 8, "", "", region.GetCountryBins())
+	if err != nil {
+		t.Fatalf("Failed to create state: %+v", err)
+	}
+	impl := &RegistrationImpl{State: state}
+
+	team := []*id.ID{id.NewIdFromUInt(1, id.Node, t),
+		id.NewIdFromUInt(2, id.Node, t), id.NewIdFromUInt(3, id.Node, t)}
+	idle := id.NewIdFromUInt(4, id.Node, t)
+	for _, nid := range append(team, idle) {
+		if err = state.GetNodeMap().AddNode(nid, "", "", "", 0); err != nil {
+			t.Fatalf("Failed to add node: %+v", err)
+		}
+	}
+	r, err := state.GetRoundMap().AddRound(1, 32, 8, time.Minute,
+		connect.NewCircuit(team))
+	if err != nil {
+		t.Fatalf("Failed to add round: %+v", err)
+	}
+	activities := []current.Activity{current.REALTIME, current.PRECOMPUTING,
+		current.REALTIME}
+	for i, activity := range activities {
+		if err = state.GetNodeMap().GetNode(team[i]).ResumeRound(r, activity); err != nil {
+			t.Fatalf("Failed to place node in round: %+v", err)
+		}
+	}
+
+	nodeHost, err := connect.NewHost(team[0], "", nil,
+		connect.GetDefaultHostParams())
+	if err != nil {
+		t.Fatalf("Failed to create host: %+v", err)
+	}
+	_, err = impl.GetNodesByActivity(&connect.Auth{IsAuthenticated: true, Sender: nodeHost})
+	if err == nil {
+		t.Errorf("Node was able to list the nodes by activity.")
+	}
+
+	permHost, err := connect.NewHost(&id.Permissioning, "", nil,
+		connect.GetDefaultHostParams())
+	if err != nil {
+		t.Fatalf("Failed to create host: %+v", err)
+	}
+	nodes, err := impl.GetNodesByActivity(&connect.Auth{IsAuthenticated: true, Sender: permHost})
+	if err != nil {
+		t.Fatalf("Failed to list the nodes by activity: %+v", err)
+	}
+
+	idleActivity := state.GetNodeMap().GetNode(idle).GetActivity()
+	expected := map[current.Activity][]*id.ID{
+		current.REALTIME:     {team[0], team[2]},
+		current.PRECOMPUTING: {team[1]},
+		idleActivity:         {idle},
+	}
+	if len(nodes) != len(expected) {
+		t.Errorf("Expected nodes in %d activities, received %d: %v",
+			len(expected), len(nodes), nodes)
+	}
+	for activity, ids := range expected {
+		if len(nodes[activity]) != len(ids) {
+			t.Errorf("Expected %d nodes in %s, received %v.", len(ids),
+				activity, nodes[activity])
+			continue
+		}
+		for _, nid := range ids {
+			found := false
+			for _, listed := range nodes[activity] {
+				found = found || listed.Cmp(nid)
+			}
+			if !found {
+				t.Errorf("Node %s not listed in %s.", nid, activity)
+			}
+		}
+	}
+}
+
+// Tests that only the permissioning server can get the round latency
+// percentiles and that they are computed from the stored rounds.
+func TestRegistrationImpl_GetRoundLatencyPercentiles(t *testing.T) {
+	var err error
+	var dc func() error
+	storage.PermissioningDb, dc, err = storage.NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("Failed to create database: %+v", err)
+	}
+	t.Cleanup(func() { _ = dc() })
+	impl := &RegistrationImpl{}
+	now := time.Now()
+	for i := 1; i <= 4; i++ {
+		start := now.Add(-time.Duration(i) * time.Second)
+		err = storage.PermissioningDb.InsertRoundMetric(&storage.RoundMetric{
+			Id:            uint64(i),
+			PrecompStart:  start,
+			PrecompEnd:    start,
+			RealtimeStart: start,
+			RealtimeEnd:   now,
+			RoundEnd:      now,
+		}, nil)
+		if err != nil {
+			t.Fatalf("Failed to insert round metric: %+v", err)
+		}
+	}
+
+	nodeHost, err := connect.NewHost(id.NewIdFromUInt(1, id.Node, t), "", nil,
+		connect.GetDefaultHostParams())
+	if err != nil {
+		t.Fatalf("Failed to create host: %+v", err)
+	}
+	_, _, _, err = impl.GetRoundLatencyPercentiles(
+		&connect.Auth{IsAuthenticated: true, Sender: nodeHost}, time.Minute)
+	if err == nil {
+		t.Errorf("Node was able to get the round latency percentiles.")
+	}
+
+	permHost, err := connect.NewHost(&id.Permissioning, "", nil,
+		connect.GetDefaultHostParams())
+	if err != nil {
+		t.Fatalf("Failed to create host: %+v", err)
+	}
+	p50, p95, p99, err := impl.GetRoundLatencyPercentiles(
+		&connect.Auth{IsAuthenticated: true, Sender: permHost}, time.Minute)
+	if err != nil {
+		t.Fatalf("Failed to get the round latency percentiles: %+v", err)
+	}
+	if p50 != 2*time.Second || p95 != 4*time.Second || p99 != 4*time.Second {
+		t.Errorf("Unexpected percentiles: %s, %s, %s", p50, p95, p99)
+	}
+}
+
+// Tests that only the permissioning server can get the topology of a stored
+// round, and that it is returned in order.
+func TestRegistrationImpl_GetRoundTopology(t *testing.T) {
+	var err error
+	var dc func() error
+	storage.PermissioningDb, dc, err = storage.NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("Failed to create database: %+v", err)
+	}
+	t.Cleanup(func() { _ = dc() })
+	impl := &RegistrationImpl{}
+	team := []*id.ID{id.NewIdFromUInt(2, id.Node, t),
+		id.NewIdFromUInt(1, id.Node, t), id.NewIdFromUInt(3, id.Node, t)}
+	topology := make([][]byte, len(team))
+	for i, nid := range team {
+		topology[i] = nid.Marshal()
+		err = storage.PermissioningDb.InsertApplication(
+			&storage.Application{Id: uint64(i + 1)}, &storage.Node{
+				Code: "code" + nid.String(), Id: nid.Marshal()})
+		if err != nil {
+			t.Fatalf("Failed to insert node: %+v", err)
+		}
+	}
+	err = storage.PermissioningDb.InsertRoundMetric(
+		&storage.RoundMetric{Id: 7, RoundEnd: time.Now()}, topology)
+	if err != nil {
+		t.Fatalf("Failed to insert round metric: %+v", err)
+	}
+
+	nodeHost, err := connect.NewHost(team[0], "", nil,
+		connect.GetDefaultHostParams())
+	if err != nil {
+		t.Fatalf("Failed to create host: %+v", err)
+	}
+	_, err = impl.GetRoundTopology(
+		&connect.Auth{IsAuthenticated: true, Sender: nodeHost}, 7)
+	if err == nil {
+		t.Errorf("Node was able to get the round topology.")
+	}
+
+	permHost, err := connect.NewHost(&id.Permissioning, "", nil,
+		connect.GetDefaultHostParams())
+	if err != nil {
+		t.Fatalf("Failed to create host: %+v", err)
+	}
+	received, err := impl.GetRoundTopology(
+		&connect.Auth{IsAuthenticated: true, Sender: permHost}, 7)
+	if err != nil {
+		t.Fatalf("Failed to get the round topology: %+v", err)
+	}
+	if !reflect.DeepEqual(received, team) {
+		t.Errorf("Unexpected round topology.\nexpected: %v\nreceived: %v",
+			team, received)
+	}
+}
+
+// Tests that only the permissioning server can get the scheduling config of a
+// stored round, and that rounds stored without one have a nil config.
+func TestRegistrationImpl_GetRoundSchedulingConfig(t *testing.T) {
+	var err error
+	var dc func() error
+	storage.PermissioningDb, dc, err = storage.NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("Failed to create database: %+v", err)
+	}
+	t.Cleanup(func() { _ = dc() })
+	impl := &RegistrationImpl{}
+	config := `{"teamSize":3,"batchSize":32}`
+	configId, err := storage.PermissioningDb.InsertSchedulingConfig([]byte(config))
+	if err != nil {
+		t.Fatalf("Failed to insert scheduling config: %+v", err)
+	}
+	err = storage.PermissioningDb.InsertRoundMetric(&storage.RoundMetric{Id: 7,
+		RoundEnd: time.Now(), SchedulingConfigId: &configId}, nil)
+	if err != nil {
+		t.Fatalf("Failed to insert round metric: %+v", err)
+	}
+	err = storage.PermissioningDb.InsertRoundMetric(
+		&storage.RoundMetric{Id: 8, RoundEnd: time.Now()}, nil)
+	if err != nil {
+		t.Fatalf("Failed to insert round metric: %+v", err)
+	}
+
+	nodeHost, err := connect.NewHost(id.NewIdFromUInt(1, id.Node, t), "", nil,
+		connect.GetDefaultHostParams())
+	if err != nil {
+		t.Fatalf("Failed to create host: %+v", err)
+	}
+	_, err = impl.GetRoundSchedulingConfig(
+		&connect.Auth{IsAuthenticated: true, Sender: nodeHost}, 7)
+	if err == nil {
+		t.Errorf("Node was able to get the round scheduling config.")
+	}
+
+	permHost, err := connect.NewHost(&id.Permissioning, "", nil,
+		connect.GetDefaultHostParams())
+	if err != nil {
+		t.Fatalf("Failed to create host: %+v", err)
+	}
+	auth := &connect.Auth{IsAuthenticated: true, Sender: permHost}
+	received, err := impl.GetRoundSchedulingConfig(auth, 7)
+	if err != nil {
+		t.Fatalf("Failed to get the round scheduling config: %+v", err)
+	}
+	if string(received) != config {
+		t.Errorf("Unexpected round scheduling config."+
+			"\nexpected: %s\nreceived: %s", config, received)
+	}
+	if received, err = impl.GetRoundSchedulingConfig(auth, 8); err != nil || received != nil {
+		t.Errorf("Unexpected scheduling config of round without one: %s, %+v",
+			received, err)
+	}
+}
+
+// Tests that only the permissioning server can get the staleness of a node and
+// that it is classified by the configured thresholds.
+func TestRegistrationImpl_GetNodeStaleness(t *testing.T) {
+	var err error
+	var dc func() error
+	storage.PermissioningDb, dc, err = storage.NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("Failed to create database: %+v", err)
+	}
+	t.Cleanup(func() { _ = dc() })
+	state, err := storage.NewState(getTestKey(), 8, "", "", region.GetCountryBins())
+	if err != nil {
+		t.Fatalf("Failed to create state: %+v", err)
+	}
+	if err = state.SetNodeStalenessThresholds(time.Minute, time.Hour); err != nil {
+		t.Fatalf("Failed to set thresholds: %+v", err)
+	}
+	impl := &RegistrationImpl{State: state}
+
+	nid := id.NewIdFromUInt(1, id.Node, t)
+	if err = state.GetNodeMap().AddNode(nid, "US", "", "", 0); err != nil {
+		t.Fatalf("Failed to add node: %+v", err)
+	}
+	state.GetNodeMap().GetNode(nid).SetLastPoll(time.Now().Add(-2*time.Minute), t)
+
+	permHost, err := connect.NewHost(&id.Permissioning, "", nil,
+		connect.GetDefaultHostParams())
+	if err != nil {
+		t.Fatalf("Failed to create host: %+v", err)
+	}
+	if _, _, err = impl.GetNodeStaleness(&connect.Auth{Sender: permHost}, nid); err == nil {
+		t.Errorf("Unauthenticated sender was able to get node staleness.")
+	}
+
+	auth := &connect.Auth{IsAuthenticated: true, Sender: permHost}
+	sincePoll, class, err := impl.GetNodeStaleness(auth, nid)
+	if err != nil {
+		t.Fatalf("GetNodeStaleness() returned an error: %+v", err)
+	}
+	if class != storage.Stale || sincePoll < 2*time.Minute {
+		t.Errorf("Node last polled 2m ago is %s %s ago, expected %s.",
+			class, sincePoll, storage.Stale)
+	}
+}
@@ -0,0 +1,80 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Contains the administrative functions for adjusting scheduling parameters
+// while the scheduler is running
+
+package cmd
+
+import (
+	"github.com/pkg/errors"
+	"gitlab.com/xx_network/comms/connect"
+	"gitlab.com/xx_network/primitives/id"
+	"time"
+)
+
+// checkAdminAuth ensures the sender is authenticated as the permissioning
+// server itself, which only holders of the permissioning key can do.
+func checkAdminAuth(auth *connect.Auth) error {
+	if !auth.IsAuthenticated || !auth.Sender.GetId().Cmp(&id.Permissioning) {
+		return connect.AuthError(auth.Sender.GetId())
+	}
+	return nil
+}
+
+// checkSchedulingParams returns an error if the scheduling params have not
+// been loaded yet.
+func (m *RegistrationImpl) checkSchedulingParams() error {
+	if m.schedulingParams == nil {
+		return errors.New("Scheduling parameters have not been loaded")
+	}
+	return nil
+}
+
+// SetTeamSize changes the number of nodes in each team formed by the
+// scheduler. The team size must be positive and no larger than the number of
+// nodes which are not banned. Takes effect on the next round created.
+func (m *RegistrationImpl) SetTeamSize(auth *connect.Auth, teamSize uint32) error {
+	if err := checkAdminAuth(auth); err != nil {
+		return err
+	}
+	if err := m.checkSchedulingParams(); err != nil {
+		return err
+	}
+
+	available := 0
+	for _, n := range m.State.GetNodeMap().GetNodeStates() {
+		if !n.IsBanned() {
+			available++
+		}
+	}
+	return m.schedulingParams.SetTeamSize(teamSize, available)
+}
+
+// SetBatchSize changes the number of slots in each batch. The batch size must
+// be positive. Takes effect on the next round created.
+func (m *RegistrationImpl) SetBatchSize(auth *connect.Auth, batchSize uint32) error {
+	if err := checkAdminAuth(auth); err != nil {
+		return err
+	}
+	if err := m.checkSchedulingParams(); err != nil {
+		return err
+	}
+	return m.schedulingParams.SetBatchSize(batchSize)
+}
+
+// SetMinimumDelay changes the minimum time between assigning rounds. The delay
+// must not be negative. Takes effect on the next round created.
+func (m *RegistrationImpl) SetMinimumDelay(auth *connect.Auth, delay time.Duration) error {
+	if err := checkAdminAuth(auth); err != nil {
+		return err
+	}
+	if err := m.checkSchedulingParams(); err != nil {
+		return err
+	}
+	return m.schedulingParams.SetMinimumDelay(delay)
+}
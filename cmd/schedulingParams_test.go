@@ -0,0 +1,93 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package cmd
+
+import (
+	"gitlab.com/elixxir/registration/scheduling"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/xx_network/comms/connect"
+	"gitlab.com/xx_network/primitives/id"
+	"gitlab.com/xx_network/primitives/region"
+	"testing"
+)
+
+// Tests that the team size can only be changed by the permissioning server
+// and is limited to the number of nodes which are not banned.
+func TestRegistrationImpl_SetTeamSize(t *testing.T) {
+	var err error
+	var dc func() error
+	storage.PermissioningDb, dc, err = storage.NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("Failed to create database: %+v", err)
+	}
+	t.Cleanup(func() { _ = dc() })
+	state, err := storage.NewState(getTestKey(), 8, "", "", region.GetCountryBins())
+	if err != nil {
+		t.Fatalf("Failed to create state: %+v", err)
+	}
+	for i := uint64(0); i < 3; i++ {
+		err = state.GetNodeMap().AddNode(id.NewIdFromUInt(i, id.Node, t), "", "", "", 0)
+		if err != nil {
+			t.Fatalf("Failed to add node: %+v", err)
+		}
+	}
+	err = state.GetNodeMap().AddBannedNode(id.NewIdFromUInt(3, id.Node, t), "", "", "")
+	if err != nil {
+		t.Fatalf("Failed to add banned node: %+v", err)
+	}
+
+	impl := &RegistrationImpl{
+		State:            state,
+		schedulingParams: &scheduling.SafeParams{Params: &scheduling.Params{TeamSize: 2}},
+	}
+
+	permHost, err := connect.NewHost(&id.Permissioning, "", nil,
+		connect.GetDefaultHostParams())
+	if err != nil {
+		t.Fatalf("Failed to create host: %+v", err)
+	}
+	nodeHost, err := connect.NewHost(id.NewIdFromUInt(0, id.Node, t), "", nil,
+		connect.GetDefaultHostParams())
+	if err != nil {
+		t.Fatalf("Failed to create host: %+v", err)
+	}
+
+	if err = impl.SetTeamSize(&connect.Auth{IsAuthenticated: true, Sender: nodeHost}, 3); err == nil {
+		t.Errorf("Node changed the team size.")
+	}
+	if err = impl.SetTeamSize(&connect.Auth{Sender: permHost}, 3); err == nil {
+		t.Errorf("Unauthenticated sender changed the team size.")
+	}
+
+	adminAuth := &connect.Auth{IsAuthenticated: true, Sender: permHost}
+	if err = impl.SetTeamSize(adminAuth, 4); err == nil {
+		t.Errorf("Team size including a banned node accepted.")
+	}
+	if err = impl.SetTeamSize(adminAuth, 3); err != nil {
+		t.Fatalf("Failed to set team size: %+v", err)
+	}
+	if teamSize := impl.schedulingParams.SafeCopy().TeamSize; teamSize != 3 {
+		t.Errorf("Unexpected team size.\nexpected: %d\nreceived: %d", 3, teamSize)
+	}
+}
+
+// Error path: tests that the scheduling params cannot be changed before they
+// are loaded.
+func TestRegistrationImpl_SetBatchSize_NotLoaded(t *testing.T) {
+	permHost, err := connect.NewHost(&id.Permissioning, "", nil,
+		connect.GetDefaultHostParams())
+	if err != nil {
+		t.Fatalf("Failed to create host: %+v", err)
+	}
+
+	impl := &RegistrationImpl{}
+	err = impl.SetBatchSize(&connect.Auth{IsAuthenticated: true, Sender: permHost}, 32)
+	if err == nil {
+		t.Errorf("Batch size set before the scheduling params were loaded.")
+	}
+}
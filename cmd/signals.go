@@ -9,6 +9,7 @@
 
 // signals.go handles signals specific to the permissioning server:
 //   - SIGUSR1, which stops round creation
+//   - SIGHUP, which reloads the node blacklist
 //   - SIGTERM/SIGINT, which stops round creation and exits
 //
 // The functions are set up to receive arbitrary functions that handle
@@ -55,6 +56,12 @@ func ReceiveUSR2Signal(usr1Fn func()) {
 	ReceiveSignal(usr1Fn, syscall.SIGUSR2)
 }
 
+// ReceiveHUPSignal calls the provided function when receiving SIGHUP.
+// It will call the provided function every time it receives it
+func ReceiveHUPSignal(hupFn func()) {
+	ReceiveSignal(hupFn, syscall.SIGHUP)
+}
+
 // ReceiveExitSignal signals a stop chan when it receives
 // SIGTERM or SIGINT
 func ReceiveExitSignal() chan os.Signal {
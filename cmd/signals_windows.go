@@ -9,6 +9,7 @@
 
 // signals.go handles signals specific to the permissioning server:
 //   - SIGUSR1, which stops round creation
+//   - SIGHUP, which reloads the node blacklist
 //   - SIGTERM/SIGINT, which stops round creation and exits
 //
 // The functions are set up to receive arbitrary functions that handle
@@ -41,6 +42,12 @@ func ReceiveUSR2Signal(usr1Fn func()) {
 	jww.WARN.Printf("Windows does not support SIGUSR2 signals, ignored!")
 }
 
+// ReceiveHUPSignal is a dummy function because windows doesn't have the
+// support we need.
+func ReceiveHUPSignal(hupFn func()) {
+	jww.WARN.Printf("Windows does not support SIGHUP signals, ignored!")
+}
+
 // ReceiveExitSignal calls the provided exit function and exits
 // with the provided exit status when the program receives
 // SIGTERM or SIGINT
@@ -0,0 +1,37 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Contains reporting the position of a node in its current round's topology
+
+package cmd
+
+import (
+	pb "gitlab.com/elixxir/comms/mixmessages"
+	"gitlab.com/elixxir/registration/storage/node"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// topologyPositionPollField is the field number of the node's position in the
+// topology of its current round in the PermissionPollResponse message. It is
+// sent as a varint in the message's unknown fields, only while the node is in
+// a round, until the comms message declares the field.
+const topologyPositionPollField protowire.Number = 16
+
+// setTopologyPosition adds the index of the node in the topology of its
+// current round to the poll response, if it is in one.
+func setTopologyPosition(response *pb.PermissionPollResponse, n *node.State) {
+	position, ok := n.GetTopologyPosition()
+	if !ok {
+		return
+	}
+
+	unknown := response.ProtoReflect().GetUnknown()
+	unknown = protowire.AppendTag(unknown, topologyPositionPollField,
+		protowire.VarintType)
+	unknown = protowire.AppendVarint(unknown, uint64(position))
+	response.ProtoReflect().SetUnknown(unknown)
+}
@@ -0,0 +1,73 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package cmd
+
+import (
+	pb "gitlab.com/elixxir/comms/mixmessages"
+	"gitlab.com/elixxir/primitives/states"
+	"gitlab.com/elixxir/registration/storage/node"
+	"gitlab.com/elixxir/registration/storage/round"
+	"gitlab.com/xx_network/comms/connect"
+	"gitlab.com/xx_network/primitives/id"
+	"google.golang.org/protobuf/encoding/protowire"
+	"testing"
+)
+
+// Reads the topology position sent in the poll response, returning false if
+// there is none
+func getTopologyPosition(t *testing.T, response *pb.PermissionPollResponse) (uint64, bool) {
+	unknown := response.ProtoReflect().GetUnknown()
+	for len(unknown) > 0 {
+		num, typ, n := protowire.ConsumeTag(unknown)
+		if n < 0 {
+			t.Fatalf("Malformed unknown fields: %v", unknown)
+		}
+		unknown = unknown[n:]
+		if num == topologyPositionPollField && typ == protowire.VarintType {
+			v, _ := protowire.ConsumeVarint(unknown)
+			return v, true
+		}
+		unknown = unknown[protowire.ConsumeFieldValue(num, typ, unknown):]
+	}
+	return 0, false
+}
+
+// Tests that each node in a round is sent its position in the round's
+// topology, and that nodes outside of a round are sent none.
+func TestSetTopologyPosition(t *testing.T) {
+	nsm := node.NewStateMap()
+	ids := make([]*id.ID, 4)
+	for i := range ids {
+		ids[i] = id.NewIdFromUInt(uint64(i), id.Node, t)
+		if err := nsm.AddNode(ids[i], "", "", "", 0); err != nil {
+			t.Fatalf("Failed to add node: %+v", err)
+		}
+	}
+	topology := connect.NewCircuit([]*id.ID{ids[1], ids[2], ids[0]})
+	r := round.NewState_Testing(7, states.PRECOMPUTING, topology, t)
+
+	for i, expected := range []uint64{2, 0, 1} {
+		n := nsm.GetNode(ids[i])
+		if err := n.SetRound(r); err != nil {
+			t.Fatalf("Failed to set round: %+v", err)
+		}
+		response := &pb.PermissionPollResponse{}
+		setTopologyPosition(response, n)
+		position, ok := getTopologyPosition(t, response)
+		if !ok || position != expected {
+			t.Errorf("Node %d was sent position %d, %t instead of %d.",
+				i, position, ok, expected)
+		}
+	}
+
+	response := &pb.PermissionPollResponse{}
+	setTopologyPosition(response, nsm.GetNode(ids[3]))
+	if _, ok := getTopologyPosition(t, response); ok {
+		t.Errorf("Node outside of a round was sent a position.")
+	}
+}
@@ -0,0 +1,73 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Contains serving round updates to nodes from the updates last served to
+// them, and the relay flag nodes report for their gateways
+
+package cmd
+
+import (
+	pb "gitlab.com/elixxir/comms/mixmessages"
+	"gitlab.com/elixxir/registration/storage/node"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// relayUpdatesPollField is the field number of the update relay flag in the
+// PermissioningPoll message. Nodes which pass the round updates they are
+// served on to their gateway send it as a varint of 1; until the comms message
+// declares the field, it is read from the message's unknown fields.
+const relayUpdatesPollField protowire.Number = 15
+
+// getRelaysUpdates returns true if the node reported in the poll that it
+// relays its round updates to its gateway.
+func getRelaysUpdates(msg *pb.PermissioningPoll) bool {
+	unknown := msg.ProtoReflect().GetUnknown()
+	relays := false
+	for len(unknown) > 0 {
+		num, typ, n := protowire.ConsumeTag(unknown)
+		if n < 0 {
+			return false
+		}
+		unknown = unknown[n:]
+
+		if num == relayUpdatesPollField && typ == protowire.VarintType {
+			v, m := protowire.ConsumeVarint(unknown)
+			if m < 0 {
+				return false
+			}
+			relays = v != 0
+			unknown = unknown[m:]
+			continue
+		}
+
+		m := protowire.ConsumeFieldValue(num, typ, unknown)
+		if m < 0 {
+			return false
+		}
+		unknown = unknown[m:]
+	}
+	return relays
+}
+
+// getNodeUpdates returns the round updates after lastUpdate to serve the node.
+// A node repeating the request it last made, as nodes with a stale update
+// cursor do, is answered with the updates it was last served as long as no
+// update was added since.
+func (m *RegistrationImpl) getNodeUpdates(n *node.State,
+	lastUpdate int) ([]*pb.RoundInfo, error) {
+	newest := m.State.GetLastUpdateID()
+	if updates, ok := n.GetCachedUpdates(lastUpdate, newest); ok {
+		return updates, nil
+	}
+
+	updates, err := m.State.GetUpdates(lastUpdate)
+	if err != nil {
+		return nil, err
+	}
+	n.SetCachedUpdates(lastUpdate, newest, updates)
+	return updates, nil
+}
@@ -0,0 +1,154 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package cmd
+
+import (
+	pb "gitlab.com/elixxir/comms/mixmessages"
+	"gitlab.com/elixxir/primitives/states"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/elixxir/registration/storage/node"
+	"gitlab.com/xx_network/primitives/id"
+	"gitlab.com/xx_network/primitives/region"
+	"google.golang.org/protobuf/encoding/protowire"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// Builds a state holding a node, returning it
+func setupUpdateCacheTest(t testing.TB) (*RegistrationImpl, *node.State) {
+	var err error
+	var closeDb func() error
+	storage.PermissioningDb, closeDb, err = storage.NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("Failed to create database: %+v", err)
+	}
+	t.Cleanup(func() { _ = closeDb() })
+	state, err := storage.NewState(getTestKey(), 8, "", "", region.GetCountryBins())
+	if err != nil {
+		t.Fatalf("Failed to create state: %+v", err)
+	}
+
+	nid := id.NewIdFromString("node", id.Node, t)
+	if err = state.GetNodeMap().AddNode(nid, "US", "", "", 0); err != nil {
+		t.Fatalf("Failed to add node: %+v", err)
+	}
+	return &RegistrationImpl{State: state, params: &Params{}},
+		state.GetNodeMap().GetNode(nid)
+}
+
+// Adds round updates for the given rounds and waits for them to be published
+func addCacheTestUpdates(t testing.TB, state *storage.NetworkState,
+	rounds ...uint64) {
+	expected := state.GetLastUpdateID() + len(rounds)
+	for _, rid := range rounds {
+		err := state.AddRoundUpdate(&pb.RoundInfo{ID: rid,
+			State:      uint32(states.PRECOMPUTING),
+			Topology:   [][]byte{id.NewIdFromUInt(rid, id.Node, t).Marshal()},
+			Timestamps: make([]uint64, states.NUM_STATES)})
+		if err != nil {
+			t.Fatalf("Failed to add round update: %+v", err)
+		}
+	}
+	for i := 0; state.GetLastUpdateID() < expected; i++ {
+		if i == 100 {
+			t.Fatalf("Round updates were not published: newest update %d",
+				state.GetLastUpdateID())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// Tests that stale, current and future update IDs are served the same
+// updates as the buffer holds, that a repeated request is served the cached
+// updates, and that the cache is invalidated once a new update is added.
+func TestRegistrationImpl_getNodeUpdates(t *testing.T) {
+	impl, n := setupUpdateCacheTest(t)
+	addCacheTestUpdates(t, impl.State, 1, 2, 3, 4, 5)
+	newest := impl.State.GetLastUpdateID()
+
+	for name, lastUpdate := range map[string]int{"stale": newest - 3,
+		"current": newest, "future": newest + 5} {
+		expected, _ := impl.State.GetUpdates(lastUpdate)
+		updates, err := impl.getNodeUpdates(n, lastUpdate)
+		if err != nil {
+			t.Fatalf("getNodeUpdates() returned an error: %+v", err)
+		}
+		if !reflect.DeepEqual(updates, expected) {
+			t.Errorf("Unexpected updates for a %s update ID."+
+				"\nexpected: %v\nreceived: %v", name, expected, updates)
+		}
+		if cached, ok := n.GetCachedUpdates(lastUpdate, newest); !ok ||
+			!reflect.DeepEqual(cached, expected) {
+			t.Errorf("Updates for a %s update ID were not cached.", name)
+		}
+	}
+
+	// A repeated stale request is served from the cache
+	stale, _ := impl.getNodeUpdates(n, newest-3)
+	repeated, _ := impl.getNodeUpdates(n, newest-3)
+	if len(stale) != 3 || &repeated[0] != &stale[0] {
+		t.Errorf("Repeated request was not served from the cache.")
+	}
+
+	// The cache is invalidated by a new update
+	addCacheTestUpdates(t, impl.State, 6)
+	updates, err := impl.getNodeUpdates(n, newest-3)
+	if err != nil {
+		t.Fatalf("getNodeUpdates() returned an error: %+v", err)
+	}
+	if len(updates) != 4 || updates[3].ID != 6 {
+		t.Errorf("Cached updates served after a new update: %v", updates)
+	}
+}
+
+// Tests that the relay flag is read from the poll.
+func TestGetRelaysUpdates(t *testing.T) {
+	msg := &pb.PermissioningPoll{}
+	if getRelaysUpdates(msg) {
+		t.Errorf("Poll without the relay flag reported relaying.")
+	}
+	field := protowire.AppendTag(nil, relayUpdatesPollField,
+		protowire.VarintType)
+	msg.ProtoReflect().SetUnknown(protowire.AppendVarint(field, 1))
+	if !getRelaysUpdates(msg) {
+		t.Errorf("Poll with the relay flag did not report relaying.")
+	}
+}
+
+// Benchmarks serving a node which repeats its request from the cache.
+func BenchmarkRegistrationImpl_getNodeUpdates(b *testing.B) {
+	impl, n := setupUpdateCacheTest(b)
+	rounds := make([]uint64, 100)
+	for i := range rounds {
+		rounds[i] = uint64(i + 1)
+	}
+	addCacheTestUpdates(b, impl.State, rounds...)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, _ = impl.getNodeUpdates(n, 0)
+	}
+}
+
+// Benchmarks serving a node from the update buffer, for comparison.
+func BenchmarkNetworkState_GetUpdates(b *testing.B) {
+	impl, _ := setupUpdateCacheTest(b)
+	rounds := make([]uint64, 100)
+	for i := range rounds {
+		rounds[i] = uint64(i + 1)
+	}
+	addCacheTestUpdates(b, impl.State, rounds...)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, _ = impl.State.GetUpdates(0)
+	}
+}
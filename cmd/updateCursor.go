@@ -0,0 +1,42 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Contains the validation of the update cursor reported in node polls
+
+package cmd
+
+import (
+	"github.com/pkg/errors"
+	jww "github.com/spf13/jwalterweatherman"
+	"gitlab.com/elixxir/registration/storage/node"
+)
+
+// checkUpdateCursor returns the update ID to serve the node's updates after,
+// given the update ID it reported having received. Unless
+// requireMonotonicUpdates is set the reported ID is used as is. Otherwise, an
+// ID ahead of the newest update is rejected, as no update could be served for
+// it, and an ID lower than the node reported before is raised to that one.
+func (m *RegistrationImpl) checkUpdateCursor(n *node.State,
+	lastUpdate uint64) (int, error) {
+	if !m.params.requireMonotonicUpdates {
+		return int(lastUpdate), nil
+	}
+
+	newest := m.State.GetLastUpdateID()
+	if newest < 0 || lastUpdate > uint64(newest) {
+		return 0, errors.Errorf("Node %s reported update %d, which is ahead "+
+			"of the newest update %d", n.GetID(), lastUpdate, newest)
+	}
+
+	cursor, raised := n.AdvanceUpdateCursor(lastUpdate)
+	if raised {
+		jww.WARN.Printf("Node %s reported update %d, which is before the "+
+			"update %d it reported previously, serving updates after that "+
+			"instead", n.GetID(), lastUpdate, cursor)
+	}
+	return int(cursor), nil
+}
@@ -0,0 +1,73 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package cmd
+
+import (
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/xx_network/primitives/id"
+	"gitlab.com/xx_network/primitives/region"
+	"math"
+	"testing"
+)
+
+// Tests that nodes reporting update IDs which are negative as an int, huge or
+// decreasing are rejected or served only the updates they are missing, and
+// that the reported ID is used as is when validation is disabled.
+func TestRegistrationImpl_checkUpdateCursor(t *testing.T) {
+	var err error
+	var closeDb func() error
+	storage.PermissioningDb, closeDb, err = storage.NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("Failed to create database: %+v", err)
+	}
+	t.Cleanup(func() { _ = closeDb() })
+	state, err := storage.NewState(getTestKey(), 8, "", "", region.GetCountryBins())
+	if err != nil {
+		t.Fatalf("Failed to create state: %+v", err)
+	}
+	impl := &RegistrationImpl{State: state,
+		params: &Params{requireMonotonicUpdates: true}}
+
+	addCacheTestUpdates(t, state, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10)
+	newest := state.GetLastUpdateID()
+
+	nid := id.NewIdFromString("gateway", id.Node, t)
+	if err = state.GetNodeMap().AddNode(nid, "US", "", "", 0); err != nil {
+		t.Fatalf("Failed to add node: %+v", err)
+	}
+	n := state.GetNodeMap().GetNode(nid)
+
+	// IDs ahead of the newest update, including those negative as an int,
+	// are rejected
+	for _, lastUpdate := range []uint64{math.MaxUint64, math.MaxInt64 + 1,
+		uint64(newest) + 1} {
+		if _, err = impl.checkUpdateCursor(n, lastUpdate); err == nil {
+			t.Errorf("Update ID %d ahead of the newest update %d was "+
+				"accepted.", lastUpdate, newest)
+		}
+	}
+
+	cursor, err := impl.checkUpdateCursor(n, uint64(newest-2))
+	if err != nil || cursor != newest-2 {
+		t.Fatalf("Unexpected cursor: %d, %+v", cursor, err)
+	}
+	if updates, _ := state.GetUpdates(cursor); len(updates) != 2 {
+		t.Errorf("Expected 2 updates, received %d.", len(updates))
+	}
+
+	// A decreasing ID does not resend the updates the node already has
+	cursor, err = impl.checkUpdateCursor(n, 0)
+	if err != nil || cursor != newest-2 {
+		t.Errorf("Decreasing ID was not raised: %d, %+v", cursor, err)
+	}
+
+	impl.params.requireMonotonicUpdates = false
+	if cursor, err = impl.checkUpdateCursor(n, 0); err != nil || cursor != 0 {
+		t.Errorf("ID changed with validation disabled: %d, %+v", cursor, err)
+	}
+}
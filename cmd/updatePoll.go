@@ -0,0 +1,61 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Contains the lightweight poll for nodes and gateways which only need round
+// updates
+
+package cmd
+
+import (
+	"github.com/pkg/errors"
+	pb "gitlab.com/elixxir/comms/mixmessages"
+	"gitlab.com/xx_network/comms/connect"
+	"gitlab.com/xx_network/primitives/id"
+)
+
+// PollUpdates returns the round updates after lastUpdate to a node or its
+// gateway. Unlike Poll, it compares no NDF, checks no addresses or
+// connectivity, records no activity and takes no polling lock. Nodes are
+// served as Poll serves them, while gateways are served from the update buffer
+// without moving their node's update cursor.
+func (m *RegistrationImpl) PollUpdates(lastUpdate uint64,
+	auth *connect.Auth) ([]*pb.RoundInfo, error) {
+	if auth == nil || auth.Sender == nil {
+		return nil, errors.New("Cannot poll updates without authentication")
+	}
+	if !auth.IsAuthenticated {
+		return nil, connect.AuthError(auth.Sender.GetId())
+	}
+
+	sender := auth.Sender.GetId()
+	nid := sender
+	if sender.GetType() == id.Gateway {
+		nid = sender.DeepCopy()
+		nid.SetType(id.Node)
+	} else if sender.GetType() != id.Node {
+		return nil, errors.Errorf("%s is not a node or gateway", sender)
+	}
+
+	n := m.State.GetNodeMap().GetNode(nid)
+	if n == nil {
+		return nil, errors.Errorf("Node %s could not be found in internal "+
+			"state tracker", nid)
+	}
+	if n.IsBanned() {
+		return nil, errors.Errorf("Node %s has been banned from the network",
+			nid)
+	}
+
+	if sender.GetType() == id.Gateway {
+		return m.State.GetUpdates(int(lastUpdate))
+	}
+	cursor, err := m.checkUpdateCursor(n, lastUpdate)
+	if err != nil {
+		return nil, err
+	}
+	return m.getNodeUpdates(n, cursor)
+}
@@ -0,0 +1,83 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package cmd
+
+import (
+	"gitlab.com/xx_network/comms/connect"
+	"gitlab.com/xx_network/primitives/id"
+	"reflect"
+	"testing"
+)
+
+// Tests that PollUpdates() serves a node and its gateway the same updates as
+// the update buffer holds, without taking the polling lock or recording any
+// activity or connectivity.
+func TestRegistrationImpl_PollUpdates(t *testing.T) {
+	impl, n := setupUpdateCacheTest(t)
+	addCacheTestUpdates(t, impl.State, 1, 2, 3)
+	expected, _ := impl.State.GetUpdates(1)
+
+	gwId := n.GetID().DeepCopy()
+	gwId.SetType(id.Gateway)
+	connectivity := n.GetRawConnectivity()
+	lastPoll := n.GetLastPoll()
+
+	// Hold the polling lock to show it is not taken
+	n.GetPollingLock().Lock()
+	defer n.GetPollingLock().Unlock()
+	for _, sender := range []*id.ID{n.GetID(), gwId} {
+		h, err := connect.NewHost(sender, "", nil, connect.GetDefaultHostParams())
+		if err != nil {
+			t.Fatalf("Failed to create host: %+v", err)
+		}
+		updates, err := impl.PollUpdates(1,
+			&connect.Auth{IsAuthenticated: true, Sender: h})
+		if err != nil {
+			t.Fatalf("PollUpdates() returned an error for %s: %+v", sender, err)
+		}
+		if !reflect.DeepEqual(updates, expected) {
+			t.Errorf("Unexpected updates for %s.\nexpected: %v\nreceived: %v",
+				sender, expected, updates)
+		}
+	}
+
+	if n.GetNumPolls() != 0 || !n.GetLastPoll().Equal(lastPoll) {
+		t.Errorf("PollUpdates() recorded a poll.")
+	}
+	if n.GetRawConnectivity() != connectivity {
+		t.Errorf("PollUpdates() changed the node's connectivity from %d to %d.",
+			connectivity, n.GetRawConnectivity())
+	}
+}
+
+// Tests that PollUpdates() refuses unauthenticated and unknown senders.
+func TestRegistrationImpl_PollUpdates_Unauthorized(t *testing.T) {
+	impl, n := setupUpdateCacheTest(t)
+
+	h, err := connect.NewHost(n.GetID(), "", nil, connect.GetDefaultHostParams())
+	if err != nil {
+		t.Fatalf("Failed to create host: %+v", err)
+	}
+	if _, err = impl.PollUpdates(0,
+		&connect.Auth{IsAuthenticated: false, Sender: h}); err == nil {
+		t.Errorf("PollUpdates() served an unauthenticated node.")
+	}
+	if _, err = impl.PollUpdates(0, nil); err == nil {
+		t.Errorf("PollUpdates() served a poll without authentication.")
+	}
+
+	unknown, err := connect.NewHost(id.NewIdFromString("unknown", id.Node, t),
+		"", nil, connect.GetDefaultHostParams())
+	if err != nil {
+		t.Fatalf("Failed to create host: %+v", err)
+	}
+	if _, err = impl.PollUpdates(0,
+		&connect.Auth{IsAuthenticated: true, Sender: unknown}); err == nil {
+		t.Errorf("PollUpdates() served an unknown node.")
+	}
+}
@@ -0,0 +1,60 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Handles the grace window in which nodes which have not yet upgraded past a
+// newly raised version floor are still accepted
+
+package cmd
+
+import (
+	jww "github.com/spf13/jwalterweatherman"
+	"gitlab.com/elixxir/primitives/version"
+	"time"
+)
+
+// versionGrace holds the minimum versions in force before they were last
+// raised, which nodes may still meet until the grace window closes.
+type versionGrace struct {
+	gateway version.Version
+	server  version.Version
+	until   time.Time
+}
+
+// setMinVersions replaces the minimum gateway and server versions. When either
+// is raised and a grace window is configured, nodes meeting the previous
+// minimums are accepted with a warning until the window closes. Must be called
+// with the version lock held.
+func (p *Params) setMinVersions(gateway, server version.Version, now time.Time) {
+	raised := version.Cmp(gateway, p.minGatewayVersion) > 0 ||
+		version.Cmp(server, p.minServerVersion) > 0
+	if raised && p.versionGraceWindow > 0 {
+		p.versionGrace = versionGrace{
+			gateway: p.minGatewayVersion,
+			server:  p.minServerVersion,
+			until:   now.Add(p.versionGraceWindow),
+		}
+		jww.INFO.Printf("Minimum versions raised to gateway %s and server "+
+			"%s, accepting nodes meeting gateway %s and server %s until %s",
+			gateway, server, p.minGatewayVersion, p.minServerVersion,
+			p.versionGrace.until)
+	}
+	p.minGatewayVersion, p.minServerVersion = gateway, server
+}
+
+// allows returns true if the version, which is incompatible with the current
+// minimum, meets the previous minimum while the grace window is open, in which
+// case a warning is logged.
+func (vg versionGrace) allows(kind string, previous, current,
+	reported version.Version, now time.Time) bool {
+	if !now.Before(vg.until) || !version.IsCompatible(previous, reported) {
+		return false
+	}
+	jww.WARN.Printf("The %s version %#v is incompatible with the required "+
+		"version %#v, accepting it until the grace window closes at %s",
+		kind, reported.String(), current.String(), vg.until)
+	return true
+}
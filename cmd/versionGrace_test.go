@@ -0,0 +1,92 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package cmd
+
+import (
+	pb "gitlab.com/elixxir/comms/mixmessages"
+	"gitlab.com/elixxir/primitives/version"
+	"testing"
+	"time"
+)
+
+// Tests that after the version floor is raised, nodes meeting the previous
+// floor pass the version check during the grace window and are rejected once
+// it closes, while nodes below the previous floor are always rejected.
+func TestCheckVersion_GraceWindow(t *testing.T) {
+	previous, _ := version.ParseVersion("1.3.0")
+	raised, _ := version.ParseVersion("1.4.0")
+	p := &Params{
+		minGatewayVersion:  previous,
+		minServerVersion:   previous,
+		versionGraceWindow: time.Minute,
+	}
+	p.setMinVersions(raised, raised, time.Now())
+
+	straggler := &pb.PermissioningPoll{ServerVersion: "1.3.5",
+		GatewayVersion: "1.3.5"}
+	if err := checkVersion(p, straggler); err != nil {
+		t.Errorf("Node meeting the previous floor was rejected during the "+
+			"grace window: %+v", err)
+	}
+	upgraded := &pb.PermissioningPoll{ServerVersion: "1.4.0",
+		GatewayVersion: "1.3.5"}
+	if err := checkVersion(p, upgraded); err != nil {
+		t.Errorf("Node with a straggling gateway was rejected during the "+
+			"grace window: %+v", err)
+	}
+	outdated := &pb.PermissioningPoll{ServerVersion: "1.2.0",
+		GatewayVersion: "1.4.0"}
+	if err := checkVersion(p, outdated); err == nil {
+		t.Errorf("Node below the previous floor passed during the grace " +
+			"window.")
+	}
+
+	// Once the window closes the stragglers are rejected
+	p.versionGrace.until = time.Now().Add(-time.Second)
+	if err := checkVersion(p, straggler); err == nil {
+		t.Errorf("Node meeting only the previous floor passed after the " +
+			"grace window closed.")
+	}
+	if err := checkVersion(p, &pb.PermissioningPoll{ServerVersion: "1.4.1",
+		GatewayVersion: "1.4.0"}); err != nil {
+		t.Errorf("Upgraded node was rejected: %+v", err)
+	}
+}
+
+// Tests that no grace window is opened when none is configured or the floor
+// is not raised.
+func TestParams_setMinVersions(t *testing.T) {
+	previous, _ := version.ParseVersion("1.3.0")
+	raised, _ := version.ParseVersion("1.4.0")
+	now := time.Now()
+
+	p := &Params{minGatewayVersion: previous, minServerVersion: previous}
+	p.setMinVersions(raised, raised, now)
+	if !p.versionGrace.until.IsZero() {
+		t.Errorf("Grace window opened when none is configured.")
+	}
+	if !version.Equal(p.minGatewayVersion, raised) ||
+		!version.Equal(p.minServerVersion, raised) {
+		t.Errorf("Minimum versions were not raised: %s, %s",
+			p.minGatewayVersion, p.minServerVersion)
+	}
+
+	p.versionGraceWindow = time.Minute
+	p.setMinVersions(raised, raised, now)
+	if !p.versionGrace.until.IsZero() {
+		t.Errorf("Grace window opened when the floor was not raised.")
+	}
+
+	p.setMinVersions(raised, previous, now)
+	p.setMinVersions(raised, raised, now)
+	if !p.versionGrace.until.Equal(now.Add(time.Minute)) ||
+		!version.Equal(p.versionGrace.server, previous) {
+		t.Errorf("Grace window was not opened for the raised server "+
+			"version: %+v", p.versionGrace)
+	}
+}
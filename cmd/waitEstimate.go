@@ -0,0 +1,45 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Contains the waiting time estimate sent to waiting nodes in poll responses
+
+package cmd
+
+import (
+	pb "gitlab.com/elixxir/comms/mixmessages"
+	"gitlab.com/elixxir/registration/storage/node"
+	"google.golang.org/protobuf/encoding/protowire"
+	"time"
+)
+
+// waitEstimatePollField is the field number of the waiting time estimate in
+// the PermissionPollResponse message. It is sent as a zigzag encoded varint
+// of seconds in the message's unknown fields until the comms message declares
+// the field.
+const waitEstimatePollField protowire.Number = 14
+
+// WaitEstimateUnknown is the waiting time estimate sent when no estimate can
+// be made, such as while round creation is stopped or paused.
+const WaitEstimateUnknown int64 = -1
+
+// setWaitEstimate adds a rough estimate of the seconds the waiting node has
+// left before it is scheduled to the poll response.
+func (m *RegistrationImpl) setWaitEstimate(response *pb.PermissionPollResponse,
+	n *node.State, stopped bool, now time.Time) {
+	seconds := WaitEstimateUnknown
+	if !stopped {
+		if estimate, ok := m.State.EstimateWait(n, now); ok {
+			seconds = int64(estimate.Round(time.Second) / time.Second)
+		}
+	}
+
+	unknown := response.ProtoReflect().GetUnknown()
+	unknown = protowire.AppendTag(unknown, waitEstimatePollField,
+		protowire.VarintType)
+	unknown = protowire.AppendVarint(unknown, protowire.EncodeZigZag(seconds))
+	response.ProtoReflect().SetUnknown(unknown)
+}
@@ -0,0 +1,93 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package cmd
+
+import (
+	pb "gitlab.com/elixxir/comms/mixmessages"
+	"gitlab.com/elixxir/primitives/current"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/xx_network/primitives/id"
+	"gitlab.com/xx_network/primitives/region"
+	"google.golang.org/protobuf/encoding/protowire"
+	"testing"
+	"time"
+)
+
+// Reads the waiting time estimate sent in the poll response
+func getWaitEstimate(t *testing.T, response *pb.PermissionPollResponse) int64 {
+	unknown := response.ProtoReflect().GetUnknown()
+	for len(unknown) > 0 {
+		num, typ, n := protowire.ConsumeTag(unknown)
+		if n < 0 {
+			t.Fatalf("Malformed unknown fields: %v", unknown)
+		}
+		unknown = unknown[n:]
+		if num == waitEstimatePollField && typ == protowire.VarintType {
+			v, _ := protowire.ConsumeVarint(unknown)
+			return protowire.DecodeZigZag(v)
+		}
+		unknown = unknown[protowire.ConsumeFieldValue(num, typ, unknown):]
+	}
+	t.Fatalf("No waiting time estimate in the poll response.")
+	return 0
+}
+
+// Tests that the estimate is added alongside the policy hint, and that the
+// sentinel is sent while round creation is stopped or paused.
+func TestRegistrationImpl_setWaitEstimate(t *testing.T) {
+	var err error
+	var closeDb func() error
+	storage.PermissioningDb, closeDb, err = storage.NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("Failed to create database: %+v", err)
+	}
+	t.Cleanup(func() { _ = closeDb() })
+	state, err := storage.NewState(getTestKey(), 8, "", "", region.GetCountryBins())
+	if err != nil {
+		t.Fatalf("Failed to create state: %+v", err)
+	}
+	impl := &RegistrationImpl{State: state, policyVersion: 1}
+
+	nid := id.NewIdFromString("node", id.Node, t)
+	if err = state.GetNodeMap().AddNode(nid, "US", "", "", 0); err != nil {
+		t.Fatalf("Failed to add node: %+v", err)
+	}
+	n := state.GetNodeMap().GetNode(nid)
+	if _, _, err = n.Update(current.WAITING); err != nil {
+		t.Fatalf("Failed to update node: %+v", err)
+	}
+	now := time.Now()
+	for i := 0; i < 100; i++ {
+		state.RecordScheduledTeam(6, now)
+	}
+
+	response := &pb.PermissionPollResponse{}
+	impl.setPolicyHint(response)
+	impl.setWaitEstimate(response, n, false, now)
+	if hint := getPolicyHint(t, response); hint != 1 {
+		t.Errorf("Policy hint lost: %d", hint)
+	}
+	if seconds := getWaitEstimate(t, response); seconds != 1 {
+		t.Errorf("Unexpected estimate: %d", seconds)
+	}
+
+	response = &pb.PermissionPollResponse{}
+	impl.setWaitEstimate(response, n, true, now)
+	if seconds := getWaitEstimate(t, response); seconds != WaitEstimateUnknown {
+		t.Errorf("Sentinel not sent while round creation is stopped: %d",
+			seconds)
+	}
+
+	response = &pb.PermissionPollResponse{}
+	state.SetSchedulingPaused(true)
+	impl.setWaitEstimate(response, n, false, now)
+	if seconds := getWaitEstimate(t, response); seconds != WaitEstimateUnknown {
+		t.Errorf("Sentinel not sent while round creation is paused: %d",
+			seconds)
+	}
+}
@@ -0,0 +1,99 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package scheduling
+
+import (
+	"github.com/pkg/errors"
+	jww "github.com/spf13/jwalterweatherman"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/elixxir/registration/storage/node"
+	"gitlab.com/xx_network/primitives/id"
+)
+
+// avoidLists.go contains the logic to keep Nodes whose Applications are on
+// each other's avoid-lists out of the same team
+
+// errAvoidListConflict is returned when hard avoid-list enforcement is on and
+// no conflict free team can be formed
+var errAvoidListConflict = errors.New("unable to form a team which " +
+	"satisfies all avoid-lists")
+
+// applyAvoidLists swaps out members of the team on each other's avoid-lists
+// for nodes from the pool for which eligible returns true, or any node if it
+// is nil. If conflicts remain and the params enforce avoid-lists, the team is
+// returned to the pool and errAvoidListConflict is returned; otherwise the
+// conflicted team is kept with a warning.
+func applyAvoidLists(params Params, team []*node.State, pool *waitingPool,
+	state *storage.NetworkState, roundID id.Round,
+	eligible func(*node.State) bool) ([]*node.State, error) {
+	team, conflicted := resolveAvoidListConflicts(team, pool, state, roundID,
+		eligible)
+	if !conflicted {
+		return team, nil
+	}
+
+	if params.HardAvoidLists {
+		for _, n := range team {
+			pool.Add(n)
+		}
+		return nil, errAvoidListConflict
+	}
+	jww.WARN.Printf("Round %d contains nodes on each other's "+
+		"avoid-lists as no alternatives are available", roundID)
+	return team, nil
+}
+
+// resolveAvoidListConflicts replaces members of the team which conflict with
+// another member with conflict free nodes from the pool for which eligible
+// returns true, or any node if it is nil. Replaced nodes are returned to the
+// pool and counted as excluded from the round. Returns the new team and
+// whether any conflicts remain.
+func resolveAvoidListConflicts(team []*node.State, pool *waitingPool,
+	state *storage.NetworkState, roundID id.Round,
+	eligible func(*node.State) bool) ([]*node.State, bool) {
+
+	for i := range team {
+		if !conflictsWithTeam(team, i, team[i], state) {
+			continue
+		}
+
+		replacement := pool.PickMatching(func(candidate *node.State) bool {
+			return (eligible == nil || eligible(candidate)) &&
+				!conflictsWithTeam(team, i, candidate, state)
+		})
+		if replacement == nil {
+			continue
+		}
+
+		jww.DEBUG.Printf("Replacing node %s with %s in team due to an "+
+			"avoid-list conflict", team[i].GetID(), replacement.GetID())
+		state.RecordExclusion(team[i], node.ExcludedAvoidList, roundID)
+		pool.Add(team[i])
+		team[i] = replacement
+	}
+
+	for i := range team {
+		if conflictsWithTeam(team, i, team[i], state) {
+			return team, true
+		}
+	}
+	return team, false
+}
+
+// conflictsWithTeam returns true if the candidate's Application is on an
+// avoid-list with the Application of any team member other than the one at
+// index skip.
+func conflictsWithTeam(team []*node.State, skip int, candidate *node.State,
+	state *storage.NetworkState) bool {
+	for j, member := range team {
+		if j != skip && state.Avoids(candidate.GetAppID(), member.GetAppID()) {
+			return true
+		}
+	}
+	return false
+}
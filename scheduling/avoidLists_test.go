@@ -0,0 +1,257 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package scheduling
+
+import (
+	"crypto/rand"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/elixxir/registration/storage/node"
+	"gitlab.com/xx_network/crypto/signature/rsa"
+	"gitlab.com/xx_network/primitives/id"
+	"gitlab.com/xx_network/primitives/region"
+	"testing"
+)
+
+// Builds a network state with the given number of nodes, each belonging to
+// the application with the same number, all in the pool
+func setupAvoidListTest(numNodes int, t *testing.T) (*storage.NetworkState,
+	*waitingPool) {
+	var err error
+	storage.PermissioningDb, _, err = storage.NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("Failed to create database: %+v", err)
+	}
+
+	privKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	testState, err := storage.NewState(privKey, 8, "", "", region.GetCountryBins())
+	if err != nil {
+		t.Fatalf("Failed to create test state: %v", err)
+	}
+
+	pool := NewWaitingPool()
+	for i := 0; i < numNodes; i++ {
+		nid := id.NewIdFromUInt(uint64(i), id.Node, t)
+		err = testState.GetNodeMap().AddNode(nid, "US", "", "", uint64(i))
+		if err != nil {
+			t.Fatalf("Couldn't add node: %v", err)
+		}
+		pool.Add(testState.GetNodeMap().GetNode(nid))
+	}
+
+	return testState, pool
+}
+
+// Returns true if any two members of the round are on an avoid-list
+func roundHasConflict(r protoRound, state *storage.NetworkState) bool {
+	for i, a := range r.NodeStateList {
+		for _, b := range r.NodeStateList[i+1:] {
+			if state.Avoids(a.GetAppID(), b.GetAppID()) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Tests that secure teaming never places nodes on each other's avoid-lists in
+// the same team when alternatives are in the pool.
+func TestCreateSecureRound_AvoidLists(t *testing.T) {
+	testState, pool := setupAvoidListTest(6, t)
+
+	// Applications 0 through 2 all avoid each other
+	if err := testState.SetAvoidList(0, []uint64{1, 2}); err != nil {
+		t.Fatalf("Failed to set avoid-list: %+v", err)
+	}
+	if err := testState.SetAvoidList(1, []uint64{2}); err != nil {
+		t.Fatalf("Failed to set avoid-list: %+v", err)
+	}
+
+	testParams := Params{
+		TeamSize:       3,
+		BatchSize:      32,
+		HardAvoidLists: true,
+	}
+
+	for i := 0; i < 20; i++ {
+		newRound, err := createSecureRound(testParams, pool, 0, id.Round(i),
+			testState, rand.Reader)
+		if err != nil {
+			t.Fatalf("Failed to create round %d: %+v", i, err)
+		}
+		if roundHasConflict(newRound, testState) {
+			t.Fatalf("Round %d contains nodes on each other's avoid-lists.", i)
+		}
+
+		for _, n := range newRound.NodeStateList {
+			pool.Add(n)
+		}
+	}
+}
+
+// Tests that with soft enforcement, a team is still formed when the only
+// available nodes are on each other's avoid-lists.
+func TestCreateSecureRound_AvoidLists_SoftFallback(t *testing.T) {
+	testState, pool := setupAvoidListTest(3, t)
+
+	if err := testState.SetAvoidList(0, []uint64{1}); err != nil {
+		t.Fatalf("Failed to set avoid-list: %+v", err)
+	}
+
+	testParams := Params{
+		TeamSize:  3,
+		BatchSize: 32,
+	}
+
+	newRound, err := createSecureRound(testParams, pool, 0, 1, testState,
+		rand.Reader)
+	if err != nil {
+		t.Fatalf("Soft enforcement should still form a team: %+v", err)
+	}
+	if len(newRound.NodeStateList) != 3 {
+		t.Errorf("Unexpected team size.\nexpected: %d\nreceived: %d",
+			3, len(newRound.NodeStateList))
+	}
+}
+
+// Error path: tests that with hard enforcement, no team is formed when the
+// only available nodes are on each other's avoid-lists, and that the picked
+// nodes are returned to the pool.
+func TestCreateSecureRound_AvoidLists_HardFailure(t *testing.T) {
+	testState, pool := setupAvoidListTest(3, t)
+
+	if err := testState.SetAvoidList(0, []uint64{1}); err != nil {
+		t.Fatalf("Failed to set avoid-list: %+v", err)
+	}
+
+	testParams := Params{
+		TeamSize:       3,
+		BatchSize:      32,
+		HardAvoidLists: true,
+	}
+
+	_, err := createSecureRound(testParams, pool, 0, 1, testState, rand.Reader)
+	if err != errAvoidListConflict {
+		t.Fatalf("Unexpected error.\nexpected: %v\nreceived: %v",
+			errAvoidListConflict, err)
+	}
+	if pool.Len() != 3 {
+		t.Errorf("Picked nodes were not returned to the pool."+
+			"\nexpected: %d\nreceived: %d", 3, pool.Len())
+	}
+}
+
+// Tests that resolveAvoidListConflicts() swaps a conflicting member for a
+// node from the pool and returns the replaced node to the pool.
+func TestResolveAvoidListConflicts(t *testing.T) {
+	testState, pool := setupAvoidListTest(4, t)
+
+	if err := testState.SetAvoidList(0, []uint64{1}); err != nil {
+		t.Fatalf("Failed to set avoid-list: %+v", err)
+	}
+
+	// Pick applications 0 through 2, leaving only application 3 in the pool
+	nodes := testState.GetNodeMap()
+	team := make([]*node.State, 3)
+	for i := range team {
+		team[i] = nodes.GetNode(id.NewIdFromUInt(uint64(i), id.Node, t))
+	}
+	if _, err := pool.PickNFromList(team, 3); err != nil {
+		t.Fatalf("Failed to pick team: %+v", err)
+	}
+
+	team, conflicted := resolveAvoidListConflicts(team, pool, testState, 1, nil)
+	if conflicted {
+		t.Fatalf("Conflict should have been resolved.")
+	}
+	if team[0].GetAppID() != 3 {
+		t.Errorf("Conflicting node not replaced by the pool node."+
+			"\nexpected: %d\nreceived: %d", 3, team[0].GetAppID())
+	}
+	if pool.Len() != 1 || pool.CountAvailable(
+		[]*node.State{nodes.GetNode(id.NewIdFromUInt(0, id.Node, t))}) != 1 {
+		t.Errorf("Replaced node was not returned to the pool.")
+	}
+}
+
+// Tests that group teaming swaps a member on an avoid-list for another member
+// of the group, and never for a node outside it.
+func TestCreateGroupRound_AvoidLists(t *testing.T) {
+	testState, pool := setupAvoidListTest(5, t)
+
+	// Applications 0 and 1 avoid each other, and the group holds 0 through 3
+	if err := testState.SetAvoidList(0, []uint64{1}); err != nil {
+		t.Fatalf("Failed to set avoid-list: %+v", err)
+	}
+	group := make([]*id.ID, 4)
+	for i := range group {
+		group[i] = id.NewIdFromUInt(uint64(i), id.Node, t)
+	}
+	if err := testState.SetNodeGroup("alpha", group); err != nil {
+		t.Fatalf("Failed to set node group: %+v", err)
+	}
+
+	testParams := Params{
+		TeamSize:       3,
+		BatchSize:      32,
+		NodeGroup:      "alpha",
+		HardAvoidLists: true,
+	}
+
+	newRound, err := createGroupRound(testParams, pool, 0, 1, testState, nil)
+	if err != nil {
+		t.Fatalf("Failed to create round: %+v", err)
+	}
+	if roundHasConflict(newRound, testState) {
+		t.Errorf("Round contains nodes on each other's avoid-lists.")
+	}
+	for _, n := range newRound.NodeStateList {
+		if n.GetAppID() == 4 {
+			t.Errorf("Node outside the group was placed in its team.")
+		}
+	}
+}
+
+// Tests that round-robin teaming swaps out nodes on each other's avoid-lists
+// and fails with hard enforcement when no alternative is in the pool.
+func TestRoundRobin_createRound_AvoidLists(t *testing.T) {
+	testState, pool := setupAvoidListTest(4, t)
+
+	if err := testState.SetAvoidList(0, []uint64{1}); err != nil {
+		t.Fatalf("Failed to set avoid-list: %+v", err)
+	}
+
+	testParams := Params{
+		TeamSize:       3,
+		BatchSize:      32,
+		Mode:           RoundRobinMode,
+		HardAvoidLists: true,
+	}
+
+	rr := &roundRobin{}
+	newRound, err := rr.createRound(testParams, pool, 0, 1, testState, nil)
+	if err != nil {
+		t.Fatalf("Failed to create round: %+v", err)
+	}
+	if roundHasConflict(newRound, testState) {
+		t.Errorf("Round contains nodes on each other's avoid-lists.")
+	}
+
+	// With only the conflicting applications left, no team is formed
+	for _, n := range newRound.NodeStateList {
+		if n.GetAppID() != 0 && n.GetAppID() != 1 {
+			continue
+		}
+		pool.Add(n)
+	}
+	testParams.TeamSize = 2
+	_, err = rr.createRound(testParams, pool, 0, 2, testState, nil)
+	if err != errAvoidListConflict {
+		t.Errorf("Unexpected error.\nexpected: %v\nreceived: %v",
+			errAvoidListConflict, err)
+	}
+}
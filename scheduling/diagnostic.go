@@ -0,0 +1,115 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package scheduling
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	jww "github.com/spf13/jwalterweatherman"
+	"gitlab.com/elixxir/primitives/states"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/elixxir/registration/storage/node"
+	"gitlab.com/elixxir/registration/storage/round"
+	"math"
+	"time"
+)
+
+// diagnostic.go contains the logic to demote Nodes which repeatedly fail rounds
+// before reporting STANDBY to the diagnostic state, and to recover them
+
+// diagnosticEnabled returns true if Nodes failing rounds before STANDBY are
+// demoted to the diagnostic state.
+func (p Params) diagnosticEnabled() bool {
+	return p.PrecompFailureThreshold > 0
+}
+
+// validateDiagnostic returns an error if Nodes failing rounds before STANDBY
+// are demoted without a window to count the failures in, as no failures would
+// ever be counted.
+func validateDiagnostic(params Params) error {
+	if params.diagnosticEnabled() && params.PrecompFailureWindow == 0 {
+		return errors.Errorf("precomp failure threshold of %d is set "+
+			"without a precomp failure window", params.PrecompFailureThreshold)
+	}
+	return nil
+}
+
+// diagnosticHold returns how long demoted Nodes are held out of teams. Nodes
+// which only recover manually are held until they are reactivated.
+func (p Params) diagnosticHold() time.Duration {
+	if p.DiagnosticRecoveryRounds == 0 {
+		return time.Duration(math.MaxInt64)
+	}
+	return p.PrecompFailureWindow * time.Millisecond
+}
+
+// recordRoundFailure records the failure of the round against the Nodes of its
+// team. If it failed while precomputing, it counts against each Node which had
+// not yet reported STANDBY. The clean round streak of Nodes in the diagnostic
+// state is restarted.
+func recordRoundFailure(state *storage.NetworkState, r *round.State,
+	inPrecomp bool, now time.Time) {
+	topology := r.GetTopology()
+	for i := 0; i < topology.Len(); i++ {
+		nid := topology.GetNodeAtIndex(i)
+		n := state.GetNodeMap().GetNode(nid)
+		if n == nil {
+			continue
+		}
+		if inPrecomp && !r.HasPhaseReport(nid, states.STANDBY) {
+			n.RecordPrecompFailure(now)
+		} else if n.IsDiagnostic() {
+			n.FailDiagnosticRound()
+		}
+	}
+}
+
+// demoteFailingNodes demotes the Nodes which failed at least the threshold of
+// rounds before reporting STANDBY within the window to the diagnostic state.
+// Nodes already in it are demoted again by any such failure. Each demoted Node
+// has its connectivity rechecked and is reported to the demotion handler.
+func demoteFailingNodes(params Params, state *storage.NetworkState,
+	now time.Time) {
+	window := params.PrecompFailureWindow * time.Millisecond
+	for _, n := range state.GetNodeMap().GetNodeStates() {
+		if n.IsRemoved() {
+			continue
+		}
+
+		threshold := int(params.PrecompFailureThreshold)
+		if n.IsDiagnostic() {
+			threshold = 1
+		}
+		failures := n.CountPrecompFailures(now.Add(-window))
+		if failures < threshold {
+			continue
+		}
+
+		reason := fmt.Sprintf("Failed %d rounds before reporting %s within %s",
+			failures, states.STANDBY, window)
+		n.Demote(now, reason)
+		n.SetConnectivity(node.PortUnknown)
+		jww.ERROR.Printf("Node %s demoted to the diagnostic state: %s",
+			n.GetID(), reason)
+		state.NodeDemoted(n, reason)
+	}
+}
+
+// recordDiagnosticRound records a clean round completed by the Node, taking it
+// out of the diagnostic state once it has completed enough in a row.
+func (sc *stateChanger) recordDiagnosticRound(n *node.State) {
+	if !n.IsDiagnostic() {
+		return
+	}
+
+	if n.CompleteDiagnosticRound(sc.diagnosticRecoveryRounds) {
+		_, since, rounds := n.GetDiagnostic()
+		jww.INFO.Printf("Node %s recovered from the diagnostic state after "+
+			"%d clean rounds since %s", n.GetID(), rounds, since)
+	}
+}
@@ -0,0 +1,190 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package scheduling
+
+import (
+	"gitlab.com/elixxir/comms/mixmessages"
+	"gitlab.com/elixxir/primitives/current"
+	"gitlab.com/elixxir/primitives/states"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/elixxir/registration/storage/node"
+	"gitlab.com/elixxir/registration/storage/round"
+	"gitlab.com/xx_network/comms/connect"
+	"gitlab.com/xx_network/primitives/id"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// Tests that a node which repeatedly fails rounds before reporting STANDBY,
+// while the rest of its team reports it, is demoted to the diagnostic state:
+// it is held out of the pool, its connectivity is rechecked, its operator is
+// alerted and its rejection log records the demotion. It then returns to
+// teams once the hold passes and recovers after enough clean rounds, while a
+// further failure before STANDBY demotes it again.
+func TestDemoteFailingNodes(t *testing.T) {
+	var err error
+	storage.PermissioningDb, _, err = storage.NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("Failed to create database: %+v", err)
+	}
+
+	params := Params{
+		PrecompFailureThreshold:  3,
+		PrecompFailureWindow:     60000,
+		DiagnosticRecoveryRounds: 2,
+	}
+	testState := setupNodeMap(t)
+	testPool := NewWaitingPool()
+	nodeList := make([]*id.ID, 3)
+	for i := range nodeList {
+		nodeList[i] = id.NewIdFromUInt(uint64(i), id.Node, t)
+		err = testState.GetNodeMap().AddNode(nodeList[i], strconv.Itoa(i), "", "", 0)
+		if err != nil {
+			t.Fatalf("Failed to add node: %+v", err)
+		}
+	}
+	stuck := testState.GetNodeMap().GetNode(nodeList[0])
+	stuck.SetConnectivity(node.PortSuccessful)
+
+	var demoted []*id.ID
+	testState.SetNodeDemotionHandler(func(n *node.State, reason string) {
+		demoted = append(demoted, n.GetID())
+	})
+
+	// Fail rounds in precomputation which every node but the stuck one
+	// finished
+	failRound := func(roundID id.Round) {
+		r := round.NewState_Testing(roundID, 0, connect.NewCircuit(nodeList), t)
+		for _, nid := range nodeList[1:] {
+			r.RecordPhaseReport(nid, states.STANDBY, time.Now())
+		}
+		re := &mixmessages.RoundError{Id: uint64(roundID), Error: "timeout"}
+		if err = killRound(testState, r, re, "", NewRoundTracker()); err != nil {
+			t.Fatalf("Failed to kill round %d: %+v", roundID, err)
+		}
+	}
+	for i := 1; i < int(params.PrecompFailureThreshold); i++ {
+		failRound(id.Round(i))
+	}
+	demoteFailingNodes(params, testState, time.Now())
+	if stuck.IsDiagnostic() || len(demoted) != 0 {
+		t.Fatalf("Node was demoted below the failure threshold.")
+	}
+
+	failRound(id.Round(params.PrecompFailureThreshold))
+	demoteFailingNodes(params, testState, time.Now())
+	if !stuck.IsDiagnostic() {
+		t.Fatalf("Node failing rounds before STANDBY was not demoted.")
+	}
+	for _, nid := range nodeList[1:] {
+		if testState.GetNodeMap().GetNode(nid).IsDiagnostic() {
+			t.Errorf("Node %s which reported STANDBY was demoted.", nid)
+		}
+	}
+	if len(demoted) != 1 || !demoted[0].Cmp(nodeList[0]) {
+		t.Errorf("Demotion handler was not called for the node: %v", demoted)
+	}
+	if stuck.GetRawConnectivity() != node.PortUnknown {
+		t.Errorf("Connectivity of the demoted node is not rechecked.")
+	}
+	if rejections := stuck.GetRejections(); len(rejections) != 1 {
+		t.Errorf("Rejection log has %d entries, expected 1.", len(rejections))
+	}
+
+	// The demoted node is swept from the pool and held out while waiting
+	if _, _, err = stuck.Update(current.WAITING); err != nil {
+		t.Fatalf("Failed to update node activity: %+v", err)
+	}
+	testPool.Add(stuck)
+	testPool.SweepDiagnostic(params.diagnosticHold())
+	if testPool.pool.Has(stuck) || !testPool.diagnostic.Has(stuck) {
+		t.Errorf("Demoted node was not held out of the pool.")
+	}
+	testPool.Add(stuck)
+	if testPool.pool.Has(stuck) {
+		t.Errorf("Demoted node was added to the pool.")
+	}
+
+	// Once the hold passes it returns to the pool, and a failure before
+	// STANDBY demotes it again
+	testPool.SweepDiagnostic(0)
+	if !testPool.pool.Has(stuck) || testPool.diagnostic.Len() != 0 {
+		t.Errorf("Demoted node did not return to the pool after its hold.")
+	}
+	failRound(id.Round(params.PrecompFailureThreshold + 1))
+	demoteFailingNodes(params, testState, time.Now())
+	if len(demoted) != 2 || len(stuck.GetRejections()) != 2 {
+		t.Errorf("Failure of a demoted node did not demote it again.")
+	}
+
+	// A failed clean round streak is restarted, and the node recovers after
+	// completing enough clean rounds in a row
+	sc := &stateChanger{diagnosticRecoveryRounds: params.DiagnosticRecoveryRounds}
+	sc.recordDiagnosticRound(stuck)
+	r := round.NewState_Testing(100, 0, connect.NewCircuit(nodeList), t)
+	for _, nid := range nodeList {
+		r.RecordPhaseReport(nid, states.STANDBY, time.Now())
+	}
+	if err = killRound(testState, r, nil, "realtime", NewRoundTracker()); err != nil {
+		t.Fatalf("Failed to kill round: %+v", err)
+	}
+	sc.recordDiagnosticRound(stuck)
+	if !stuck.IsDiagnostic() {
+		t.Errorf("Node recovered although its clean rounds were interrupted.")
+	}
+	sc.recordDiagnosticRound(stuck)
+	if stuck.IsDiagnostic() {
+		t.Errorf("Node did not recover after %d clean rounds.",
+			params.DiagnosticRecoveryRounds)
+	}
+	demoteFailingNodes(params, testState, time.Now())
+	if stuck.IsDiagnostic() {
+		t.Errorf("Recovered node was demoted without new failures.")
+	}
+}
+
+// Tests that demoted nodes which only recover manually are held out of the
+// pool until they are reactivated.
+func TestWaitingPool_SweepDiagnostic_ManualRecovery(t *testing.T) {
+	params := Params{PrecompFailureThreshold: 1, PrecompFailureWindow: 1}
+	testState := setupNodeMap(t)
+	testPool := NewWaitingPool()
+	ns := setupNode(t, testState, 0)
+	if _, _, err := ns.Update(current.WAITING); err != nil {
+		t.Fatalf("Failed to update node activity: %+v", err)
+	}
+	ns.Demote(time.Now().Add(-time.Hour), "test")
+
+	testPool.Add(ns)
+	testPool.SweepDiagnostic(params.diagnosticHold())
+	if testPool.pool.Has(ns) {
+		t.Errorf("Node awaiting manual reactivation was added to the pool.")
+	}
+
+	ns.Reactivate()
+	testPool.SweepDiagnostic(params.diagnosticHold())
+	if !testPool.pool.Has(ns) {
+		t.Errorf("Reactivated node did not return to the pool.")
+	}
+}
+
+// Tests that validateDiagnostic() rejects a precomp failure threshold set
+// without a window only.
+func TestValidateDiagnostic(t *testing.T) {
+	valid := []Params{{}, {PrecompFailureWindow: 60000},
+		{PrecompFailureThreshold: 3, PrecompFailureWindow: 60000}}
+	for _, params := range valid {
+		if err := validateDiagnostic(params); err != nil {
+			t.Errorf("Params %+v were rejected: %+v", params, err)
+		}
+	}
+	if err := validateDiagnostic(Params{PrecompFailureThreshold: 3}); err == nil {
+		t.Errorf("Precomp failure threshold without a window was accepted.")
+	}
+}
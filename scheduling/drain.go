@@ -0,0 +1,30 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package scheduling
+
+import (
+	jww "github.com/spf13/jwalterweatherman"
+	"gitlab.com/elixxir/registration/storage"
+)
+
+// checkDrain returns true if the network is draining or drained, in which
+// case no new rounds may be created. A draining network is marked drained
+// once no rounds are active or waiting to be started.
+func checkDrain(state *storage.NetworkState, roundTracker *RoundTracker,
+	queued int) bool {
+	switch state.GetDrainStatus() {
+	case storage.NotDraining:
+		return false
+	case storage.Draining:
+		if roundTracker.Len() == 0 && queued == 0 && state.MarkDrained() {
+			jww.WARN.Printf("Network is drained, all rounds in flight " +
+				"finished and no new rounds will be created")
+		}
+	}
+	return true
+}
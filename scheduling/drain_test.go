@@ -0,0 +1,99 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package scheduling
+
+import (
+	"crypto/rand"
+	"gitlab.com/elixxir/primitives/current"
+	"gitlab.com/elixxir/primitives/states"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/xx_network/crypto/signature/rsa"
+	"gitlab.com/xx_network/primitives/id"
+	"testing"
+	"time"
+)
+
+// Tests that rounds in flight when the network starts draining complete,
+// that no new rounds may be created while it drains, and that it is drained
+// once the last round and any round waiting to start are done.
+func TestCheckDrain(t *testing.T) {
+	var err error
+	storage.PermissioningDb, _, err = storage.NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("Failed to create database: %+v", err)
+	}
+	privKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+
+	nodes := make([]*id.ID, 3)
+	for i := range nodes {
+		nodes[i] = id.NewIdFromUInt(uint64(i), id.Node, t)
+	}
+
+	// Put a round in flight
+	oldState := newResumeTestState(privKey, nodes, t)
+	startResumeTestRound(oldState, nodes, 3, states.QUEUED, t)
+	state := newResumeTestState(privKey, nodes, t)
+	sc := &stateChanger{
+		realtimeTimeout:  time.Minute,
+		pool:             NewWaitingPool(),
+		state:            state,
+		roundTracker:     NewRoundTracker(),
+		roundTimeoutChan: make(chan id.Round, 10),
+	}
+	if err = sc.resumeRounds(); err != nil {
+		t.Fatalf("resumeRounds() returned an error: %+v", err)
+	}
+	r, _ := state.GetRoundMap().GetRound(3)
+
+	if checkDrain(state, sc.roundTracker, 0) {
+		t.Errorf("Round creation blocked before draining started.")
+	}
+	if err = state.StartDrain(); err != nil {
+		t.Fatalf("Failed to start draining: %+v", err)
+	}
+	if !checkDrain(state, sc.roundTracker, 0) {
+		t.Errorf("Round creation allowed while draining.")
+	}
+	if status := state.GetDrainStatus(); status != storage.Draining {
+		t.Errorf("Network with a round in flight is %s.", status)
+	}
+
+	// The round in flight completes
+	for _, activity := range []current.Activity{current.REALTIME, current.COMPLETED} {
+		for _, nid := range nodes {
+			n := state.GetNodeMap().GetNode(nid)
+			n.GetPollingLock().Lock()
+			isUpdate, nun, err := n.Update(activity)
+			if err != nil || !isUpdate {
+				n.GetPollingLock().Unlock()
+				t.Fatalf("Node %s could not report %s: %v", nid, activity, err)
+			}
+			if err = sc.HandleNodeUpdates(nun); err != nil {
+				t.Fatalf("Failed to handle %s update: %+v", activity, err)
+			}
+		}
+	}
+	if r.GetRoundState() != states.COMPLETED {
+		t.Errorf("Round in flight did not complete: %s", r.GetRoundState())
+	}
+
+	// A round waiting to start keeps the network draining
+	if !checkDrain(state, sc.roundTracker, 1) {
+		t.Errorf("Round creation allowed while draining.")
+	}
+	if status := state.GetDrainStatus(); status != storage.Draining {
+		t.Errorf("Network with a round waiting to start is %s.", status)
+	}
+
+	if !checkDrain(state, sc.roundTracker, 0) {
+		t.Errorf("Round creation allowed once drained.")
+	}
+	if status := state.GetDrainStatus(); status != storage.Drained {
+		t.Errorf("Network without rounds in flight is %s.", status)
+	}
+}
@@ -0,0 +1,79 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package scheduling
+
+import (
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/elixxir/registration/storage/node"
+	"gitlab.com/xx_network/primitives/id"
+)
+
+// exclusions.go contains the logic to count why Nodes are left out of the
+// teams formed by the scheduler
+
+// recordExclusions counts the reason each Node not on the team of the round
+// with the given ID was left out of it. The team is empty if no round was
+// formed. Nodes replaced in the team were counted as they were replaced, and
+// Nodes which are banned, in another round or between rounds are not counted.
+func recordExclusions(state *storage.NetworkState, pool *waitingPool,
+	team []*node.State, roundID id.Round) {
+	pool.mux.RLock()
+	defer pool.mux.RUnlock()
+
+	for _, ns := range state.GetNodeMap().GetNodeStates() {
+		if onTeam(team, ns) {
+			continue
+		}
+		if reason, excluded := exclusionReason(state, pool, ns); excluded {
+			state.RecordExclusion(ns, reason, roundID)
+		}
+	}
+}
+
+// onTeam returns true if the Node is a member of the team
+func onTeam(team []*node.State, ns *node.State) bool {
+	for _, member := range team {
+		if member == ns {
+			return true
+		}
+	}
+	return false
+}
+
+// exclusionReason returns the reason the Node was not available for a team,
+// and false if it is not counted as excluded. Must be called with the pool
+// lock held.
+func exclusionReason(state *storage.NetworkState, pool *waitingPool,
+	ns *node.State) (node.ExclusionReason, bool) {
+	if ns.GetStatus() == node.Banned {
+		return 0, false
+	}
+	if inRound, _ := ns.GetCurrentRound(); inRound {
+		return 0, false
+	}
+
+	switch {
+	case pool.pool.Has(ns):
+		return node.ExcludedNotSelected, true
+	case pool.unhealthy.Has(ns):
+		return node.ExcludedUnhealthy, true
+	case pool.lowUptime.Has(ns):
+		return node.ExcludedLowUptime, true
+	case pool.diagnostic.Has(ns):
+		return node.ExcludedDiagnostic, true
+	case pool.offline.Has(ns), ns.GetStatus() == node.Inactive,
+		state.IsPruned(ns.GetID()):
+		return node.ExcludedOffline, true
+	}
+
+	switch ns.GetRawConnectivity() {
+	case node.NodePortFailed, node.GatewayPortFailed, node.PortFailed:
+		return node.ExcludedConnectivity, true
+	}
+	return 0, false
+}
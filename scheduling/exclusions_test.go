@@ -0,0 +1,107 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package scheduling
+
+import (
+	"gitlab.com/elixxir/primitives/states"
+	"gitlab.com/elixxir/registration/storage/node"
+	"gitlab.com/elixxir/registration/storage/round"
+	"gitlab.com/xx_network/comms/connect"
+	"gitlab.com/xx_network/primitives/id"
+	"testing"
+	"time"
+)
+
+// Tests that every node left out of a team is counted once for the reason it
+// was left out, both for the node and across the network.
+func TestRecordExclusions(t *testing.T) {
+	testState, pool := setupAvoidListTest(11, t)
+	nodes := make([]*node.State, 11)
+	for i := range nodes {
+		nodes[i] = testState.GetNodeMap().GetNode(id.NewIdFromUInt(uint64(i), id.Node, t))
+		if i > 2 {
+			pool.Ban(nodes[i])
+		}
+	}
+	bannedId := id.NewIdFromUInt(100, id.Node, t)
+	if err := testState.GetNodeMap().AddBannedNode(bannedId, "US", "", ""); err != nil {
+		t.Fatalf("Failed to add banned node: %+v", err)
+	}
+
+	// Node 0 is replaced for an avoid-list conflict with node 1, and node 1
+	// for being the second node on probation with node 2
+	if err := testState.SetAvoidList(0, []uint64{1}); err != nil {
+		t.Fatalf("Failed to set avoid-list: %+v", err)
+	}
+	team, err := pool.PickNFromList(nodes[:2], 2)
+	if err != nil {
+		t.Fatalf("Failed to pick team: %+v", err)
+	}
+	team, _ = resolveAvoidListConflicts(team, pool, testState, 1, nil)
+	nodes[1].SetProbation(true, 0, time.Now())
+	nodes[2].SetProbation(true, 0, time.Now())
+	team, conflicted := resolveProbationConflicts(team, pool, testState, 1)
+	if conflicted || team[0] != nodes[2] || team[1] != nodes[0] {
+		t.Fatalf("Unexpected team: %v", team)
+	}
+
+	// Node 3 waits without being picked, node 4 reported a critical health
+	// error, nodes 5 and 6 are offline, node 7 failed its connectivity check,
+	// node 8 is in a round and nodes 9 and 10 have not yet waited
+	pool.Add(nodes[3])
+	nodes[4].SetHealthCritical(true)
+	pool.Add(nodes[4])
+	nodes[5].SetInactive()
+	testState.SetPrunedNode(nodes[6].GetID())
+	nodes[7].SetConnectivity(node.PortFailed)
+	r := round.NewState_Testing(5, states.PRECOMPUTING,
+		connect.NewCircuit([]*id.ID{nodes[8].GetID()}), t)
+	if err = nodes[8].SetRound(r); err != nil {
+		t.Fatalf("Failed to set round: %+v", err)
+	}
+
+	recordExclusions(testState, pool, team, 1)
+	recordExclusions(testState, pool, team, 1)
+
+	expected := map[int]node.ExclusionReason{
+		0: node.ExcludedAvoidList,
+		1: node.ExcludedProbation,
+		3: node.ExcludedNotSelected,
+		4: node.ExcludedUnhealthy,
+		5: node.ExcludedOffline,
+		6: node.ExcludedOffline,
+		7: node.ExcludedConnectivity,
+	}
+	for i, ns := range nodes {
+		var want [node.NumExclusionReasons]uint64
+		if reason, exists := expected[i]; exists {
+			want[reason] = 1
+		}
+		if got := ns.GetExclusions(); got != want {
+			t.Errorf("Unexpected exclusions of node %d.\nexpected: %v"+
+				"\nreceived: %v", i, want, got)
+		}
+	}
+	banned := testState.GetNodeMap().GetNode(bannedId).GetExclusions()
+	if banned != [node.NumExclusionReasons]uint64{} {
+		t.Errorf("Banned node was counted: %v", banned)
+	}
+
+	totals := [node.NumExclusionReasons]uint64{1, 2, 1, 1, 1, 1}
+	if got := testState.GetExclusions(); got != totals {
+		t.Errorf("Unexpected network totals.\nexpected: %v\nreceived: %v",
+			totals, got)
+	}
+
+	// Each round is counted separately
+	recordExclusions(testState, pool, nil, 2)
+	if got := nodes[1].GetExclusions()[node.ExcludedNotSelected]; got != 1 {
+		t.Errorf("Node returned to the pool was not counted in the next "+
+			"round: %d", got)
+	}
+}
@@ -0,0 +1,100 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Contains the selection bias which corrects how often the nodes of each
+// geographic bin are teamed
+
+package scheduling
+
+import (
+	"encoding/binary"
+	"github.com/pkg/errors"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/elixxir/registration/storage/node"
+	"io"
+	"math"
+	"sort"
+)
+
+// fairnessWeights returns the selection weight of each bin. A bin teamed at
+// the given fraction of its share of the active nodes is weighted by the
+// inverse square of that ratio, so that under-served bins are picked more
+// often, bounded between 1/correction and correction. Bins not yet teamed
+// within the window receive the maximum weight.
+func fairnessWeights(state *storage.NetworkState, correction float64) map[string]float64 {
+	weights := make(map[string]float64)
+	for _, f := range state.GetBinFairness() {
+		if f.PoolShare == 0 {
+			continue
+		}
+
+		weight := correction
+		if f.Ratio > 0 {
+			weight = 1 / (f.Ratio * f.Ratio)
+		}
+		weights[f.Bin] = math.Max(1/correction, math.Min(correction, weight))
+	}
+	return weights
+}
+
+// PickNWeightedAtThreshold collects n nodes from the pool at random, where a
+// node's chance of being picked is proportional to its weight, and returns
+// those nodes.
+// If there are not enough nodes, either from the threshold or
+// the requested nodes, this function errors
+func (wp *waitingPool) PickNWeightedAtThreshold(thresh, n int,
+	weight func(ns *node.State) float64, rng io.Reader) ([]*node.State, error) {
+	wp.mux.Lock()
+	defer wp.mux.Unlock()
+
+	// Check that the pool meets the threshold requirement
+	if wp.pool.Len() < thresh {
+		return nil, errors.Errorf("Number of stored nodes (%v) does not reach threshold", wp.pool.Len())
+	}
+
+	// Check that the pool has enough nodes to satisfy n
+	if wp.pool.Len() < n {
+		return nil, errors.Errorf("Number of stored nodes (%v) not enough"+
+			" to pick %v nodes", wp.pool.Len(), n)
+	}
+
+	// Key each node by ln(u)/weight for a uniform u in (0, 1]; the nodes with
+	// the largest keys are a weighted random sample without replacement
+	type keyedNode struct {
+		ns  *node.State
+		key float64
+	}
+	keyed := make([]keyedNode, 0, wp.pool.Len())
+	var readErr error
+	buf := make([]byte, 8)
+	wp.pool.Do(func(face interface{}) {
+		if readErr != nil {
+			return
+		}
+		if _, readErr = io.ReadFull(rng, buf); readErr != nil {
+			return
+		}
+		u := float64(binary.BigEndian.Uint64(buf)>>11+1) / (1 << 53)
+		ns := face.(*node.State)
+		keyed = append(keyed, keyedNode{ns, math.Log(u) / weight(ns)})
+	})
+	if readErr != nil {
+		return nil, errors.Errorf("Failed to generate random weights: %v", readErr)
+	}
+
+	sort.Slice(keyed, func(i, j int) bool {
+		return keyed[i].key > keyed[j].key
+	})
+
+	nodeList := make([]*node.State, n)
+	for i := range nodeList {
+		nodeList[i] = keyed[i].ns
+		wp.pool.Remove(keyed[i].ns)
+	}
+
+	return nodeList, nil
+}
@@ -0,0 +1,236 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package scheduling
+
+import (
+	"crypto/rand"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/elixxir/registration/storage/node"
+	"gitlab.com/xx_network/crypto/signature/rsa"
+	"gitlab.com/xx_network/primitives/id"
+	"gitlab.com/xx_network/primitives/region"
+	mathRand "math/rand"
+	"strings"
+	"testing"
+)
+
+// Builds a network state backed by a database of its own
+func newFairnessTestState(t *testing.T) *storage.NetworkState {
+	var err error
+	var closeDb func() error
+	storage.PermissioningDb, closeDb, err = storage.NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("Failed to create database: %+v", err)
+	}
+	t.Cleanup(func() { _ = closeDb() })
+
+	privKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	testState, err := storage.NewState(privKey, 8, "", "", region.GetCountryBins())
+	if err != nil {
+		t.Fatalf("Failed to create test state: %v", err)
+	}
+	return testState
+}
+
+// Runs rounds on a skewed pool, where every North American node always waits
+// in the pool but only a few of the equally many Central European nodes do,
+// and returns the fairness ratio of the Central European bin.
+func runSkewedPool(t *testing.T, correction float64) float64 {
+	testParams := Params{
+		TeamSize:           4,
+		BatchSize:          32,
+		Threshold:          1,
+		FairnessCorrection: correction,
+		FairnessWindow:     1000,
+	}
+
+	testState := newFairnessTestState(t)
+
+	testPool := NewWaitingPool()
+	var europe []*node.State
+	for i := uint64(0); i < 20; i++ {
+		nid := id.NewIdFromUInt(i, id.Node, t)
+		ordering := "US"
+		if i >= 10 {
+			ordering = "DE"
+		}
+		err := testState.GetNodeMap().AddNode(nid, ordering, "", "", 0)
+		if err != nil {
+			t.Fatalf("Couldn't add node: %v", err)
+		}
+		nodeState := testState.GetNodeMap().GetNode(nid)
+		if ordering == "US" {
+			testPool.Add(nodeState)
+		} else {
+			europe = append(europe, nodeState)
+		}
+	}
+
+	prng := mathRand.New(mathRand.NewSource(42))
+	for i := 0; i < 3000; i++ {
+		// A different few of the European nodes are waiting each round
+		waiting := prng.Perm(len(europe))[:4]
+		for _, j := range waiting {
+			testPool.Add(europe[j])
+		}
+
+		roundID, err := testState.GetRoundID()
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, err := createSecureRound(testParams, testPool,
+			int(testParams.TeamSize), roundID, testState, prng)
+		if err != nil {
+			t.Fatalf("Failed to create round: %+v", err)
+		}
+		testState.RecordTeamBins(r.NodeStateList, int(testParams.FairnessWindow))
+
+		// Return the teamed North American nodes and take the European ones
+		// out of the pool
+		for _, ns := range r.NodeStateList {
+			if ns.GetOrdering() == "US" {
+				testPool.Add(ns)
+			}
+		}
+		for _, j := range waiting {
+			testPool.Ban(europe[j])
+		}
+	}
+
+	for _, f := range testState.GetBinFairness() {
+		if f.Bin == "CentralEurope" {
+			return f.Ratio
+		}
+	}
+	t.Fatalf("No report for the CentralEurope bin.")
+	return 0
+}
+
+// Tests that with a fairness correction, the fairness ratio of a bin which is
+// under-represented in the pool converges closer to 1 than without it.
+func TestCreateRound_FairnessCorrection(t *testing.T) {
+	var uncorrected, corrected float64
+	t.Run("Uncorrected", func(t *testing.T) {
+		uncorrected = runSkewedPool(t, 0)
+	})
+	t.Run("Corrected", func(t *testing.T) {
+		corrected = runSkewedPool(t, 4)
+	})
+
+	if uncorrected < 0.5 || uncorrected > 0.65 {
+		t.Errorf("Unexpected ratio without correction: %f", uncorrected)
+	}
+	if corrected < 0.72 || corrected > 1.1 {
+		t.Errorf("Ratio with correction not within tolerance: %f "+
+			"(%f without correction)", corrected, uncorrected)
+	}
+}
+
+// Tests that fairnessWeights favors under-served bins within the bounds of the
+// correction.
+func TestFairnessWeights(t *testing.T) {
+	testState := newFairnessTestState(t)
+
+	var nodes []*node.State
+	for i, ordering := range []string{"US", "US", "DE", "DE"} {
+		nid := id.NewIdFromUInt(uint64(i), id.Node, t)
+		err := testState.GetNodeMap().AddNode(nid, ordering, "", "", 0)
+		if err != nil {
+			t.Fatalf("Couldn't add node: %v", err)
+		}
+		nodes = append(nodes, testState.GetNodeMap().GetNode(nid))
+	}
+
+	// Bins not yet teamed receive the maximum weight
+	weights := fairnessWeights(testState, 4)
+	if weights["NorthAmerica"] != 4 || weights["CentralEurope"] != 4 {
+		t.Errorf("Unexpected weights before any round: %v", weights)
+	}
+
+	// North America holds three of the four slots at half of the pool
+	testState.RecordTeamBins([]*node.State{nodes[0], nodes[1], nodes[2],
+		nodes[0]}, 0)
+	weights = fairnessWeights(testState, 4)
+	if w := weights["CentralEurope"]; w != 4 {
+		t.Errorf("Weight of the under-served bin not bound by the "+
+			"correction.\nexpected: %f\nreceived: %f", 4.0, w)
+	}
+	if w := weights["NorthAmerica"]; w < 0.444 || w > 0.445 {
+		t.Errorf("Unexpected weight of the over-served bin."+
+			"\nexpected: %f\nreceived: %f", 1/(1.5*1.5), w)
+	}
+}
+
+// Tests that PickNWeightedAtThreshold picks heavily weighted nodes more often
+// and removes the picked nodes from the pool.
+func TestWaitingPool_PickNWeightedAtThreshold(t *testing.T) {
+	testPool := NewWaitingPool()
+	heavy := make(map[*node.State]bool)
+	for i := 0; i < 10; i++ {
+		ns := &node.State{}
+		heavy[ns] = i < 5
+		testPool.Add(ns)
+	}
+	weight := func(ns *node.State) float64 {
+		if heavy[ns] {
+			return 10
+		}
+		return 1
+	}
+
+	prng := mathRand.New(mathRand.NewSource(42))
+	pickedHeavy := 0
+	for i := 0; i < 100; i++ {
+		picked, err := testPool.PickNWeightedAtThreshold(5, 2, weight, prng)
+		if err != nil {
+			t.Fatalf("Failed to pick nodes: %+v", err)
+		}
+		if testPool.Len() != 8 || picked[0] == picked[1] {
+			t.Fatalf("Unexpected picked nodes: %v (pool of %d)",
+				picked, testPool.Len())
+		}
+		for _, ns := range picked {
+			if heavy[ns] {
+				pickedHeavy++
+			}
+			testPool.Add(ns)
+		}
+	}
+	if pickedHeavy < 160 {
+		t.Errorf("Heavily weighted nodes picked %d out of 200 times.",
+			pickedHeavy)
+	}
+}
+
+// Tests that PickNWeightedAtThreshold errors when the pool does not reach the
+// threshold or cannot supply the requested nodes.
+func TestWaitingPool_PickNWeightedAtThreshold_Error(t *testing.T) {
+	testPool := NewWaitingPool()
+	for i := 0; i < 3; i++ {
+		testPool.Add(&node.State{})
+	}
+	weight := func(*node.State) float64 { return 1 }
+	prng := mathRand.New(mathRand.NewSource(42))
+
+	_, err := testPool.PickNWeightedAtThreshold(4, 2, weight, prng)
+	if err == nil || !strings.Contains(err.Error(), "threshold") {
+		t.Errorf("Expected a threshold error, received: %v", err)
+	}
+	_, err = testPool.PickNWeightedAtThreshold(2, 4, weight, prng)
+	if err == nil || !strings.Contains(err.Error(), "not enough") {
+		t.Errorf("Expected an error for too few nodes, received: %v", err)
+	}
+	_, err = testPool.PickNWeightedAtThreshold(2, 2, weight,
+		strings.NewReader(""))
+	if err == nil {
+		t.Errorf("Expected an error for a failed random read.")
+	}
+	if testPool.Len() != 3 {
+		t.Errorf("Nodes removed from the pool on error: %d left", testPool.Len())
+	}
+}
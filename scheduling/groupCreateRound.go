@@ -0,0 +1,124 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package scheduling
+
+import (
+	"github.com/pkg/errors"
+	jww "github.com/spf13/jwalterweatherman"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/elixxir/registration/storage/node"
+	"gitlab.com/xx_network/primitives/id"
+	"io"
+)
+
+// groupCreateRound.go contains the logic to construct a team from a named
+// node group rather than the general pool, used for controlled experiments
+
+// createGroupRound builds the team for a round from the members of the node
+// group named in the params. Members are picked in the order the group was
+// defined and that order is kept as the round's topology.
+func createGroupRound(params Params, pool *waitingPool, _ int, roundID id.Round,
+	state *storage.NetworkState, _ io.Reader) (protoRound, error) {
+
+	members, err := getNodeGroupStates(params.NodeGroup, state)
+	if err != nil {
+		return protoRound{}, err
+	}
+
+	nodes, err := pool.PickNFromList(members, int(params.TeamSize))
+	if err != nil {
+		return protoRound{}, errors.Errorf("Failed to pick team from node "+
+			"group %s: %v", params.NodeGroup, err)
+	}
+
+	// Swap out nodes on each other's avoid-lists for other members of the
+	// group where possible
+	isMember := make(map[id.ID]bool, len(members))
+	for _, n := range members {
+		isMember[*n.GetID()] = true
+	}
+	nodes, err = applyAvoidLists(params, nodes, pool, state, roundID,
+		func(candidate *node.State) bool { return isMember[*candidate.GetID()] })
+	if err != nil {
+		return protoRound{}, err
+	}
+
+	team := make([]*id.ID, 0, len(nodes))
+	for _, n := range nodes {
+		team = append(team, n.GetID())
+	}
+
+	newRound := createProtoRound(params, state, team, roundID)
+
+	jww.TRACE.Printf("Built round %d from node group %s", roundID, params.NodeGroup)
+	return newRound, nil
+}
+
+// canFieldGroupTeam returns true if enough members of the node group named in
+// the params are in the pool to build a full team. If not, the reason is
+// returned as an error.
+func canFieldGroupTeam(params Params, pool *waitingPool,
+	state *storage.NetworkState) error {
+	members, err := getNodeGroupStates(params.NodeGroup, state)
+	if err != nil {
+		return err
+	}
+
+	available := pool.CountAvailable(members)
+	if available < int(params.TeamSize) {
+		return errors.Errorf("node group %s can only field %d of %d nodes",
+			params.NodeGroup, available, params.TeamSize)
+	}
+	return nil
+}
+
+// getNodeGroupStates returns the node states of all members of the named node
+// group which are known to the node map.
+func getNodeGroupStates(name string, state *storage.NetworkState) ([]*node.State, error) {
+	group, exists := state.GetNodeGroup(name)
+	if !exists {
+		return nil, errors.Errorf("Node group %s does not exist", name)
+	}
+
+	members := make([]*node.State, 0, len(group))
+	for _, nid := range group {
+		if n := state.GetNodeMap().GetNode(nid); n != nil {
+			members = append(members, n)
+		}
+	}
+	return members, nil
+}
+
+// groupShortage tracks whether the node group named in the params could last
+// field a full team, so that the scheduler only logs when that changes rather
+// than on every iteration
+type groupShortage struct {
+	short bool
+}
+
+// canField returns true if the node group named in the params can field a
+// full team, logging when the group becomes unable to and when it recovers.
+func (g *groupShortage) canField(params Params, pool *waitingPool,
+	state *storage.NetworkState) bool {
+	err := canFieldGroupTeam(params, pool, state)
+	if err != nil {
+		if !g.short {
+			jww.INFO.Printf("Skipping round creation until the node group "+
+				"can field a team: %v", err)
+		}
+		g.short = true
+		return false
+	}
+
+	if g.short {
+		jww.INFO.Printf("Node group %s can field a team again, resuming "+
+			"round creation", params.NodeGroup)
+	}
+	g.short = false
+	return true
+}
@@ -0,0 +1,187 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package scheduling
+
+import (
+	"crypto/rand"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/xx_network/crypto/signature/rsa"
+	"gitlab.com/xx_network/primitives/id"
+	"gitlab.com/xx_network/primitives/region"
+	"testing"
+)
+
+// Builds a network state with the given number of nodes all in the pool
+func setupGroupRoundTest(numNodes int, t *testing.T) (*storage.NetworkState,
+	*waitingPool, []*id.ID) {
+	var err error
+	storage.PermissioningDb, _, err = storage.NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("Failed to create database: %+v", err)
+	}
+
+	privKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	testState, err := storage.NewState(privKey, 8, "", "", region.GetCountryBins())
+	if err != nil {
+		t.Fatalf("Failed to create test state: %v", err)
+	}
+
+	pool := NewWaitingPool()
+	nodeList := make([]*id.ID, numNodes)
+	for i := range nodeList {
+		nodeList[i] = id.NewIdFromUInt(uint64(i), id.Node, t)
+		err = testState.GetNodeMap().AddNode(nodeList[i], "US", "", "", 0)
+		if err != nil {
+			t.Fatalf("Couldn't add node: %v", err)
+		}
+		pool.Add(testState.GetNodeMap().GetNode(nodeList[i]))
+	}
+
+	return testState, pool, nodeList
+}
+
+// Happy path: tests that repeated rounds built in group mode contain exactly
+// the group's nodes in the group's order.
+func TestCreateGroupRound(t *testing.T) {
+	testState, pool, nodeList := setupGroupRoundTest(10, t)
+
+	group := []*id.ID{nodeList[7], nodeList[2], nodeList[5]}
+	err := testState.SetNodeGroup("experiment", group)
+	if err != nil {
+		t.Fatalf("Failed to set node group: %+v", err)
+	}
+
+	testParams := Params{
+		TeamSize:  3,
+		BatchSize: 32,
+		NodeGroup: "experiment",
+	}
+
+	for roundID := id.Round(1); roundID <= 3; roundID++ {
+		err = canFieldGroupTeam(testParams, pool, testState)
+		if err != nil {
+			t.Fatalf("Group should be able to field a team: %+v", err)
+		}
+
+		newRound, err := createGroupRound(testParams, pool, 0, roundID, testState, nil)
+		if err != nil {
+			t.Fatalf("Failed to create group round: %+v", err)
+		}
+
+		if newRound.Topology.Len() != len(group) {
+			t.Fatalf("Unexpected team size.\nexpected: %d\nreceived: %d",
+				len(group), newRound.Topology.Len())
+		}
+		for i, nid := range group {
+			if !newRound.Topology.GetNodeAtIndex(i).Cmp(nid) {
+				t.Errorf("Node %d of round %d is not from the group."+
+					"\nexpected: %s\nreceived: %s", i, roundID, nid,
+					newRound.Topology.GetNodeAtIndex(i))
+			}
+		}
+
+		if pool.Len() != len(nodeList)-len(group) {
+			t.Errorf("Group nodes not removed from the pool.")
+		}
+
+		// Return the team to the pool for the next round
+		for _, ns := range newRound.NodeStateList {
+			pool.Add(ns)
+		}
+	}
+}
+
+// Tests that a round is not built when the group cannot field a full team.
+func TestCreateGroupRound_NotEnoughMembers(t *testing.T) {
+	testState, pool, nodeList := setupGroupRoundTest(10, t)
+
+	err := testState.SetNodeGroup("experiment", nodeList[:3])
+	if err != nil {
+		t.Fatalf("Failed to set node group: %+v", err)
+	}
+
+	// Remove a group member from the pool
+	pool.Ban(testState.GetNodeMap().GetNode(nodeList[1]))
+
+	testParams := Params{
+		TeamSize:  3,
+		BatchSize: 32,
+		NodeGroup: "experiment",
+	}
+
+	err = canFieldGroupTeam(testParams, pool, testState)
+	if err == nil {
+		t.Errorf("Group should not be able to field a team.")
+	}
+
+	_, err = createGroupRound(testParams, pool, 0, 1, testState, nil)
+	if err == nil {
+		t.Errorf("createGroupRound() should error when the group cannot " +
+			"field a team.")
+	}
+
+	if pool.Len() != len(nodeList)-1 {
+		t.Errorf("Nodes should not be removed from the pool on failure.")
+	}
+}
+
+// Tests that groupShortage reports whether the group can field a team and
+// tracks when it becomes unable to and recovers.
+func TestGroupShortage_CanField(t *testing.T) {
+	testState, pool, nodeList := setupGroupRoundTest(10, t)
+
+	err := testState.SetNodeGroup("experiment", nodeList[:3])
+	if err != nil {
+		t.Fatalf("Failed to set node group: %+v", err)
+	}
+
+	testParams := Params{
+		TeamSize:  3,
+		BatchSize: 32,
+		NodeGroup: "experiment",
+	}
+
+	var g groupShortage
+	if !g.canField(testParams, pool, testState) || g.short {
+		t.Errorf("Group should be able to field a team.")
+	}
+
+	// Remove a group member from the pool
+	n := testState.GetNodeMap().GetNode(nodeList[1])
+	pool.Ban(n)
+	for i := 0; i < 2; i++ {
+		if g.canField(testParams, pool, testState) || !g.short {
+			t.Errorf("Group should not be able to field a team.")
+		}
+	}
+
+	pool.Add(n)
+	if !g.canField(testParams, pool, testState) || g.short {
+		t.Errorf("Group should be able to field a team once the member " +
+			"is back in the pool.")
+	}
+}
+
+// Error path: tests that a round cannot be built from a group which does
+// not exist.
+func TestCreateGroupRound_UnknownGroup(t *testing.T) {
+	testState, pool, _ := setupGroupRoundTest(3, t)
+
+	testParams := Params{
+		TeamSize:  3,
+		BatchSize: 32,
+		NodeGroup: "missing",
+	}
+
+	if err := canFieldGroupTeam(testParams, pool, testState); err == nil {
+		t.Errorf("canFieldGroupTeam() should error for an unknown group.")
+	}
+	if _, err := createGroupRound(testParams, pool, 0, 1, testState, nil); err == nil {
+		t.Errorf("createGroupRound() should error for an unknown group.")
+	}
+}
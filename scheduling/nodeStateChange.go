@@ -24,13 +24,24 @@ import (
 )
 
 type stateChanger struct {
-	lastRealtime time.Time
+	spacing realtimeSpacing
 
 	realtimeDelay time.Duration
 	realtimeDelta time.Duration
+	// How far past its earliest start a realtime start may be pushed back,
+	// and how long without realtime starts resets the spacing
+	realtimeMaxLead time.Duration
+	realtimeIdleGap time.Duration
 
 	realtimeTimeout time.Duration
 
+	// Rounds and duration Nodes on probation must complete to graduate
+	probationRounds   uint32
+	probationDuration time.Duration
+
+	// Clean rounds Nodes in the diagnostic state must complete to recover
+	diagnosticRecoveryRounds uint32
+
 	pool *waitingPool
 
 	state *storage.NetworkState
@@ -40,6 +51,17 @@ type stateChanger struct {
 	roundTimeoutChan chan id.Round
 }
 
+// getRealtimeParams returns the realtime delay and timeout the round was
+// formed with, falling back to those of the state changer for rounds which
+// were not formed by it.
+func (sc *stateChanger) getRealtimeParams(r *round.State) (time.Duration, time.Duration) {
+	delay, timeout := r.GetRealtimeParams()
+	if timeout == 0 {
+		delay, timeout = sc.realtimeDelay, sc.realtimeTimeout
+	}
+	return delay, timeout
+}
+
 // HandleNodeUpdates handles the node state changes.
 //
 //	A node in waiting is added to the pool in preparation for precomputing.
@@ -47,19 +69,30 @@ type stateChanger struct {
 //	A node in completed waits for all other nodes in the team to transition
 //	 before the round is updated.
 func (sc *stateChanger) HandleNodeUpdates(update node.UpdateNotification) error {
+	sc.state.RecordUpdateLag(update, time.Now())
+
 	// Check the round's error state
 	n := sc.state.GetNodeMap().GetNode(update.Node)
 	// when a node poll is received, the nodes polling lock is taken.  If there
 	// is no update, it is released in the endpoint, otherwise it is released
 	// here which blocks all future polls until processing completes
-	defer n.GetPollingLock().Unlock()
+	defer n.ReleasePollingLock(update.PollingHold)
 	hasRound, r := n.GetCurrentRound()
 
+	// Correlate the update's log lines with the node and its round
+	var roundID id.Round
+	if hasRound {
+		roundID = r.GetRoundID()
+	}
+	trace := sc.state.Trace(roundID, update.Node, 0)
+	trace.Debugf("Handling update from %s to %s", update.FromActivity,
+		update.ToActivity)
+
 	// Enforce that only error updates are allowed for a failed round
 	roundErrored := hasRound == true && r.GetRoundState() == states.FAILED && update.ToActivity != current.ERROR
 	if roundErrored {
-		jww.WARN.Printf("Round %d has failed, state for %s cannot be updated to %s, moving to %s",
-			r.GetRoundID(), update.Node.String(), update.ToActivity.String(), current.ERROR)
+		trace.Warnf("Round has failed, state cannot be updated to %s, moving to %s",
+			update.ToActivity.String(), current.ERROR)
 		update.ToActivity = current.ERROR
 	}
 
@@ -79,7 +112,7 @@ func (sc *stateChanger) HandleNodeUpdates(update node.UpdateNotification) error
 				return errors.Errorf("Failed to sign error message for banned node %s: %+v", update.Node, err)
 			}
 			n.ClearRound()
-			return killRound(sc.state, r, banError, sc.roundTracker)
+			return killRound(sc.state, r, banError, "", sc.roundTracker)
 		} else {
 			sc.pool.Ban(n)
 			return nil
@@ -113,10 +146,15 @@ func (sc *stateChanger) HandleNodeUpdates(update node.UpdateNotification) error
 			return errors.Errorf("Node %s without round should "+
 				"not be in %s state", update.Node, states.PRECOMPUTING)
 		}
+		// Record when the node finished precomputation to find stragglers
+		r.RecordPhaseReport(update.Node, states.STANDBY, time.Now())
+
 		// Check if the round is ready for all the nodes
 		// in order to transition
 		stateComplete := r.NodeIsReadyForTransition()
 		if stateComplete {
+			trace.Debugf("All nodes have finished precomputation")
+
 			// Update the round for end of precomp transition
 			err := r.Update(states.STANDBY, time.Now())
 
@@ -129,18 +167,13 @@ func (sc *stateChanger) HandleNodeUpdates(update node.UpdateNotification) error
 			// This signals the end of the precomp timeout,
 			// followed by initiating the realtime timeout.
 			r.DenoteRoundCompleted()
+			realtimeDelay, realtimeTimeout := sc.getRealtimeParams(r)
 			go waitForRoundTimeout(sc.roundTimeoutChan, sc.state, r,
-				sc.realtimeTimeout, true)
-
-			startTime := time.Now().Add(sc.realtimeDelay)
-			nextRoundMinimum := sc.lastRealtime.Add(sc.realtimeDelta)
-			if nextRoundMinimum.After(startTime) {
-				startTime = nextRoundMinimum
-			}
-
-			sc.lastRealtime = startTime
+				realtimeTimeout, true)
 
 			// Update the round for realtime transition
+			startTime := sc.spacing.schedule(r, time.Now(), realtimeDelay,
+				sc.realtimeDelta, sc.realtimeMaxLead, sc.realtimeIdleGap)
 			err = r.Update(states.QUEUED, startTime)
 
 			if err != nil {
@@ -168,14 +201,21 @@ func (sc *stateChanger) HandleNodeUpdates(update node.UpdateNotification) error
 		// increments on the first report, not when every node reports in
 		// order to avoid distributed synchronicity issues
 		if r.GetRoundState() != states.REALTIME {
-
-			err := r.Update(states.REALTIME, time.Now())
+			now := time.Now()
+			err := r.Update(states.REALTIME, now)
 
 			if err != nil {
 				return errors.WithMessagef(err,
 					"Could not move round %v from %s to %s",
 					r.GetRoundID(), states.QUEUED, states.REALTIME)
 			}
+
+			// Later starts are spaced from when realtime actually started
+			sc.spacing.started(r.GetRoundID(), now)
+
+			// No round update is issued for this transition, so save it
+			// directly in case the round needs to be resumed
+			sc.state.SaveActiveRound(r.BuildRoundInfo())
 		}
 	case current.COMPLETED:
 		// Check that node in standby actually does have a round
@@ -186,17 +226,25 @@ func (sc *stateChanger) HandleNodeUpdates(update node.UpdateNotification) error
 
 		// Clear the round
 		n.ClearRound()
+		sc.recordProbationRound(n)
+		sc.recordDiagnosticRound(n)
 
 		// Keep track of when the first node reached the completed state
 		if r.GetTopology().IsLastNode(n.GetID()) {
 			r.SetRealtimeCompletedTs(time.Now().UnixNano())
 		}
 
+		// Record when the node finished realtime to find stragglers. Each
+		// node reports this once, as reporting clears the node's round
+		r.RecordPhaseReport(update.Node, states.COMPLETED, time.Now())
+
 		// Check if the round is ready for all the nodes
 		// in order to transition
 		stateComplete := r.NodeIsReadyForTransition()
 
 		if stateComplete {
+			trace.Debugf("All nodes have finished realtime")
+
 			// Update the round for realtime transition
 			err := r.Update(states.COMPLETED, time.Now())
 			if err != nil {
@@ -204,6 +252,7 @@ func (sc *stateChanger) HandleNodeUpdates(update node.UpdateNotification) error
 					"Could not move round %v from %s to %s",
 					r.GetRoundID(), states.REALTIME, states.COMPLETED)
 			}
+			sc.state.RecordRoundCompleted(time.Now())
 
 			// Build the round info and add to the networkState
 			roundInfo := r.BuildRoundInfo()
@@ -219,7 +268,9 @@ func (sc *stateChanger) HandleNodeUpdates(update node.UpdateNotification) error
 			sc.roundTracker.RemoveActiveRound(r.GetRoundID())
 
 			// Store round metric in another thread for completed round
-			go StoreRoundMetric(roundInfo, r.GetRoundState(), r.GetRealtimeCompletedTs())
+			go StoreRoundMetric(roundInfo, r.GetRoundState(),
+				r.GetRealtimeCompletedTs(), r.GetStraggler(states.STANDBY),
+				r.GetStraggler(states.COMPLETED), r.GetSchedulingConfig())
 
 			// Commit metrics about the round to storage
 			return nil
@@ -231,12 +282,13 @@ func (sc *stateChanger) HandleNodeUpdates(update node.UpdateNotification) error
 		if hasRound {
 			// Clear the round from the node state
 			n.ClearRound()
+			sc.extendProbation(n)
 
 			// Signal the round as completed to disable the timeout
 			r.DenoteRoundCompleted()
 
 			// Fail the round and make accompanying round state updates
-			err = killRound(sc.state, r, update.Error, sc.roundTracker)
+			err = killRound(sc.state, r, update.Error, update.RawError, sc.roundTracker)
 		}
 		return err
 	}
@@ -244,16 +296,37 @@ func (sc *stateChanger) HandleNodeUpdates(update node.UpdateNotification) error
 	return nil
 }
 
-// Insert metrics about the newly-completed round into storage
-func StoreRoundMetric(roundInfo *pb.RoundInfo, roundEnd states.Round, realtimeTs int64) {
+// Insert metrics about the newly-completed round into storage, along with the
+// slowest nodes to finish precomputation and realtime and the scheduling config
+// the round was formed with, if known
+func StoreRoundMetric(roundInfo *pb.RoundInfo, roundEnd states.Round, realtimeTs int64,
+	precompStraggler, realtimeStraggler *round.Straggler, schedulingConfig []byte) {
+	// A malformed round info may not have a timestamp for every state
+	if len(roundInfo.Timestamps) < int(states.NUM_STATES) {
+		jww.WARN.Printf("Round %d has %d timestamps instead of %d, missing "+
+			"timestamps are stored as zero", roundInfo.GetRoundId(),
+			len(roundInfo.Timestamps), states.NUM_STATES)
+	}
+
 	metric := &storage.RoundMetric{
 		Id:            roundInfo.ID,
-		PrecompStart:  time.Unix(0, int64(roundInfo.Timestamps[states.PRECOMPUTING])),
-		PrecompEnd:    time.Unix(0, int64(roundInfo.Timestamps[states.STANDBY])),
-		RealtimeStart: time.Unix(0, int64(roundInfo.Timestamps[states.REALTIME])),
+		PrecompStart:  roundTimestamp(roundInfo, states.PRECOMPUTING),
+		PrecompEnd:    roundTimestamp(roundInfo, states.STANDBY),
+		RealtimeStart: roundTimestamp(roundInfo, states.REALTIME),
 		RealtimeEnd:   time.Unix(0, realtimeTs),
-		RoundEnd:      time.Unix(0, int64(roundInfo.Timestamps[roundEnd])),
+		RoundEnd:      roundTimestamp(roundInfo, roundEnd),
 		BatchSize:     roundInfo.BatchSize,
+
+		SchedulingConfigId: storeSchedulingConfig(id.Round(roundInfo.ID),
+			schedulingConfig),
+	}
+	if precompStraggler != nil {
+		metric.PrecompStraggler = precompStraggler.NodeId.Marshal()
+		metric.PrecompStragglerDelta = precompStraggler.Delta
+	}
+	if realtimeStraggler != nil {
+		metric.RealtimeStraggler = realtimeStraggler.NodeId.Marshal()
+		metric.RealtimeStragglerDelta = realtimeStraggler.Delta
 	}
 
 	precompDuration := metric.PrecompEnd.Sub(metric.PrecompStart)
@@ -262,24 +335,59 @@ func StoreRoundMetric(roundInfo *pb.RoundInfo, roundEnd states.Round, realtimeTs
 	jww.TRACE.Printf("Precomp for round %v took: %v", roundInfo.GetRoundId(), precompDuration)
 	jww.TRACE.Printf("Realtime for round %v took: %v", roundInfo.GetRoundId(), realTimeDuration)
 
-	err := storage.PermissioningDb.InsertRoundMetric(metric, roundInfo.Topology)
+	err := storage.PermissioningDb.InsertRoundMetricWithRetry(metric,
+		roundInfo.Topology)
 	if err != nil {
 		jww.ERROR.Printf("Failed to insert metric for round %d: %+v",
 			roundInfo.GetRoundId(), err)
 	}
 }
 
+// roundTimestamp returns the time the round entered the given state. If the
+// round info has no timestamp for the state, the zero timestamp of a state the
+// round never reached is returned.
+func roundTimestamp(roundInfo *pb.RoundInfo, state states.Round) time.Time {
+	if int(state) >= len(roundInfo.Timestamps) {
+		return time.Unix(0, 0)
+	}
+	return time.Unix(0, int64(roundInfo.Timestamps[state]))
+}
+
 // killRound updates the round.State to states.FAILED, stores the round metric,
-// and clears the round from round.StateMap if all nodes are finished.
+// and clears the round from round.StateMap if all nodes are finished. The round
+// error is sanitized before it is published or stored; rawError is the
+// unsanitized text stored for debugging, and if empty the text of roundError
+// is used.
 func killRound(state *storage.NetworkState, r *round.State,
-	roundError *pb.RoundError, roundTracker *RoundTracker) error {
+	roundError *pb.RoundError, rawError string, roundTracker *RoundTracker) error {
 
-	// Append the error to and update the round state
 	roundId := r.GetRoundID()
-	r.AppendError(roundError)
-	err := r.Update(states.FAILED, time.Now())
+	if roundError != nil && rawError == "" {
+		rawError = roundError.Error
+	}
+
+	// Correlate the log lines with the round and the node which killed it
+	var nodeId *id.ID
+	if roundError != nil {
+		nodeId, _ = id.Unmarshal(roundError.NodeId)
+	}
+	trace := state.Trace(roundId, nodeId, 0)
+
+	// Redact sensitive information before the error is published
+	roundError, err := state.SanitizeRoundError(roundError)
+	if err != nil {
+		return errors.WithMessagef(err, "Could not sanitize error "+
+			"to kill round %v", roundId)
+	}
+
+	// Append the error to and update the round state
+	r.AppendError(roundError, storage.PermissioningDb.GetRoundErrorLimit())
+	inPrecomp := r.GetRoundState() <= states.PRECOMPUTING
+	err = r.Update(states.FAILED, time.Now())
 	if err == nil {
 		roundTracker.RemoveActiveRound(roundId)
+		state.RecordRoundFailed(time.Now())
+		recordRoundFailure(state, r, inPrecomp, time.Now())
 	}
 
 	// Build the new round info and update the network state
@@ -297,12 +405,20 @@ func killRound(state *storage.NetworkState, r *round.State,
 	for i := 0; i < topologyLen; i++ {
 		nId := topology.GetNodeAtIndex(i)
 		nodeState := state.GetNodeMap().GetNode(nId)
+		if nodeState == nil {
+			// A node which is not registered cannot hold the round
+			numClearedNodes += 1
+			continue
+		}
 		hasRound, roundState := nodeState.GetCurrentRound()
 		if !hasRound || roundState.GetRoundID() != roundId {
 			numClearedNodes += 1
 		}
 	}
 
+	trace.Debugf("Round killed, %d of %d nodes cleared", numClearedNodes,
+		topologyLen)
+
 	if allNodesCleared := numClearedNodes == topologyLen; allNodesCleared {
 		// Ensure that every member of the round topology is done with the round
 		// inside the NodeMap before finally removing it in order to prevent
@@ -313,31 +429,52 @@ func killRound(state *storage.NetworkState, r *round.State,
 		// the round in order to prevent pointless duplicate inserts.
 		go func() {
 			// Attempt to insert the RoundMetric for the failed round
-			StoreRoundMetric(roundInfo, r.GetRoundState(), 0)
-
-			// Return early if there is no roundError
-			if roundError == nil {
-				return
-			}
-
-			nid, err := id.Unmarshal(roundError.NodeId)
-			var idStr string
-			if err != nil {
-				idStr = "N/A"
-			} else {
-				idStr = nid.String()
-			}
-
-			formattedError := fmt.Sprintf("Round Error from %s: %s", idStr, roundError.Error)
-			jww.INFO.Print(formattedError)
+			StoreRoundMetric(roundInfo, r.GetRoundState(), 0,
+				r.GetStraggler(states.STANDBY), r.GetStraggler(states.COMPLETED),
+				r.GetSchedulingConfig())
 
 			// Next, attempt to insert the error for the failed round
-			err = storage.PermissioningDb.InsertRoundError(roundId, formattedError)
-			if err != nil {
-				jww.WARN.Printf("Could not insert round error: %+v", err)
-			}
+			storeRoundError(roundId, nodeId, roundError, rawError)
+
+			// Finally, store the errors of the nodes which cleared the round
+			// while the metric was being stored
+			r.SetMetricStored()
 		}()
 	}
 
+	// Errors reported by nodes clearing the round later are stored as well,
+	// once the metric they reference is
+	if numClearedNodes > 1 {
+		r.AfterMetricStored(func() {
+			storeRoundError(roundId, nodeId, roundError, rawError)
+		})
+	}
+
 	return nil
 }
+
+// storeRoundError inserts the error the node reported for the failed round
+// into storage, if there is one. The node is passed separately as a sanitized
+// error is attributed to permissioning. The round metric must already be
+// stored.
+func storeRoundError(roundId id.Round, nodeId *id.ID, roundError *pb.RoundError,
+	rawError string) {
+	if roundError == nil {
+		return
+	}
+
+	idStr := "N/A"
+	if nodeId != nil {
+		idStr = nodeId.String()
+	}
+
+	formattedError := fmt.Sprintf("Round Error from %s: %s", idStr, roundError.Error)
+	formattedRawError := fmt.Sprintf("Round Error from %s: %s", idStr, rawError)
+	jww.INFO.Print(formattedError)
+
+	err := storage.PermissioningDb.InsertRoundErrorWithRetry(roundId,
+		formattedError, formattedRawError)
+	if err != nil {
+		jww.WARN.Printf("Could not insert round error: %+v", err)
+	}
+}
@@ -11,10 +11,12 @@ import (
 	"crypto/rand"
 	"gitlab.com/elixxir/comms/mixmessages"
 	"gitlab.com/elixxir/primitives/current"
+	"gitlab.com/elixxir/primitives/states"
 	"gitlab.com/elixxir/registration/storage"
 	"gitlab.com/elixxir/registration/storage/node"
 	"gitlab.com/elixxir/registration/storage/round"
 	"gitlab.com/xx_network/comms/connect"
+	"gitlab.com/xx_network/comms/signature"
 	"gitlab.com/xx_network/crypto/signature/rsa"
 	"gitlab.com/xx_network/primitives/id"
 	"gitlab.com/xx_network/primitives/region"
@@ -76,7 +78,6 @@ func TestHandleNodeStateChance_Waiting(t *testing.T) {
 	timeoutCh := make(chan id.Round, 1)
 
 	sc := &stateChanger{
-		lastRealtime:     time.Unix(0, 0),
 		realtimeDelay:    0,
 		realtimeDelta:    0,
 		realtimeTimeout:  15 * time.Second,
@@ -145,7 +146,6 @@ func TestHandleNodeStateChance_Waiting_SetNodeToOnline(t *testing.T) {
 	timeoutCh := make(chan id.Round, 1)
 
 	sc := &stateChanger{
-		lastRealtime:     time.Unix(0, 0),
 		realtimeDelay:    0,
 		realtimeDelta:    0,
 		realtimeTimeout:  15 * time.Second,
@@ -219,7 +219,6 @@ func TestHandleNodeStateChance_Standby(t *testing.T) {
 		timeoutCh := make(chan id.Round, 1)
 
 		sc := &stateChanger{
-			lastRealtime:     time.Unix(0, 0),
 			realtimeDelay:    0,
 			realtimeDelta:    0,
 			realtimeTimeout:  15 * time.Second,
@@ -248,7 +247,6 @@ func TestHandleNodeStateChance_Standby(t *testing.T) {
 		testState.GetNodeMap().GetNode(nodeList[i]).GetPollingLock().Lock()
 		timeoutCh := make(chan id.Round, 1)
 		sc := &stateChanger{
-			lastRealtime:     time.Unix(0, 0),
 			realtimeDelay:    0,
 			realtimeDelta:    0,
 			realtimeTimeout:  15 * time.Second,
@@ -314,7 +312,6 @@ func TestHandleNodeStateChance_Standby_NoRound(t *testing.T) {
 		timeoutCh := make(chan id.Round, 1)
 
 		sc := &stateChanger{
-			lastRealtime:     time.Unix(0, 0),
 			realtimeDelay:    0,
 			realtimeDelta:    0,
 			realtimeTimeout:  15 * time.Second,
@@ -391,7 +388,6 @@ func TestHandleNodeUpdates_Completed(t *testing.T) {
 		timeoutCh := make(chan id.Round, 1)
 
 		sc := &stateChanger{
-			lastRealtime:     time.Unix(0, 0),
 			realtimeDelay:    0,
 			realtimeDelta:    0,
 			realtimeTimeout:  15 * time.Second,
@@ -421,7 +417,6 @@ func TestHandleNodeUpdates_Completed(t *testing.T) {
 		timeoutCh := make(chan id.Round, 1)
 
 		sc := &stateChanger{
-			lastRealtime:     time.Unix(0, 0),
 			realtimeDelay:    0,
 			realtimeDelta:    0,
 			realtimeTimeout:  15 * time.Second,
@@ -481,7 +476,6 @@ func TestHandleNodeUpdates_Completed_NoRound(t *testing.T) {
 		timeoutCh := make(chan id.Round, 1)
 
 		sc := &stateChanger{
-			lastRealtime:     time.Unix(0, 0),
 			realtimeDelay:    0,
 			realtimeDelta:    0,
 			realtimeTimeout:  15 * time.Second,
@@ -559,7 +553,6 @@ func TestHandleNodeUpdates_Error(t *testing.T) {
 	timeoutCh := make(chan id.Round, 1)
 
 	sc := &stateChanger{
-		lastRealtime:     time.Unix(0, 0),
 		realtimeDelay:    0,
 		realtimeDelta:    0,
 		realtimeTimeout:  15 * time.Second,
@@ -621,7 +614,6 @@ func TestHandleNodeUpdates_BannedNode(t *testing.T) {
 	timeoutCh := make(chan id.Round, 1)
 
 	sc := &stateChanger{
-		lastRealtime:     time.Unix(0, 0),
 		realtimeDelay:    0,
 		realtimeDelta:    0,
 		realtimeTimeout:  15 * time.Second,
@@ -679,6 +671,12 @@ func TestHandleNodeUpdates_BannedNode(t *testing.T) {
 
 // Happy path
 func TestKillRound(t *testing.T) {
+	var err error
+	storage.PermissioningDb, _, err = storage.NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("Failed to create database: %+v", err)
+	}
+
 	testParams := Params{
 		TeamSize:  5,
 		BatchSize: 32,
@@ -723,12 +721,118 @@ func TestKillRound(t *testing.T) {
 
 	tesTracker := NewRoundTracker()
 
-	err = killRound(testState, r, re, tesTracker)
+	err = killRound(testState, r, re, "", tesTracker)
 	if err != nil {
 		t.Errorf("Unexpected error in happy path: %v", err)
 	}
 }
 
+// Tests that killRound() publishes a sanitized copy of the round error signed
+// by permissioning rather than the raw error text.
+func TestKillRound_SanitizedError(t *testing.T) {
+	var err error
+	storage.PermissioningDb, _, err = storage.NewDatabase("", "", "", "", "")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	privKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+
+	testState, err := storage.NewState(privKey, 8, "", "", region.GetCountryBins())
+	if err != nil {
+		t.Fatalf("Failed to create test state: %v", err)
+	}
+
+	nodeList := make([]*id.ID, 3)
+	for i := uint64(0); i < uint64(len(nodeList)); i++ {
+		nodeList[i] = id.NewIdFromUInt(i, id.Node, t)
+		err := testState.GetNodeMap().AddNode(nodeList[i], strconv.Itoa(int(i)), "", "", 0)
+		if err != nil {
+			t.Fatalf("Couldn't add node: %v", err)
+		}
+	}
+
+	r := round.NewState_Testing(42, 0, connect.NewCircuit(nodeList), t)
+
+	rawText := "Failed to contact gateway at 10.1.2.3:8443: open /etc/xx/gw.crt"
+	re := &mixmessages.RoundError{
+		Id:     42,
+		NodeId: nodeList[0].Marshal(),
+		Error:  rawText,
+	}
+
+	err = killRound(testState, r, re, "", NewRoundTracker())
+	if err != nil {
+		t.Fatalf("Unexpected error in happy path: %v", err)
+	}
+
+	roundInfo := r.BuildRoundInfo()
+	if len(roundInfo.Errors) != 1 {
+		t.Fatalf("Expected 1 round error, received %d", len(roundInfo.Errors))
+	}
+	published := roundInfo.Errors[0]
+	expected := storage.SanitizeError(rawText, nil)
+	if published.Error != expected {
+		t.Errorf("Published round error not sanitized."+
+			"\n\tExpected: %q\n\tReceived: %q", expected, published.Error)
+	}
+
+	err = signature.VerifyRsa(published, privKey.GetPublic())
+	if err != nil {
+		t.Errorf("Sanitized round error not signed by permissioning: %+v", err)
+	}
+}
+
+// Tests that when more nodes report errors for a round than the limit on
+// stored errors, killRound() only publishes the limit and counts the rest.
+func TestKillRound_RoundErrorLimit(t *testing.T) {
+	var err error
+	storage.PermissioningDb, _, err = storage.NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	storage.PermissioningDb.SetRoundErrorLimit(2)
+	storage.PermissioningDb.SetMetricRetry(1, time.Millisecond)
+
+	privKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	testState, err := storage.NewState(privKey, 8, "", "", region.GetCountryBins())
+	if err != nil {
+		t.Fatalf("Failed to create test state: %v", err)
+	}
+
+	nodeList := make([]*id.ID, 5)
+	for i := range nodeList {
+		nodeList[i] = id.NewIdFromUInt(uint64(i), id.Node, t)
+		err = testState.GetNodeMap().AddNode(nodeList[i], strconv.Itoa(i), "", "", 0)
+		if err != nil {
+			t.Fatalf("Couldn't add node: %v", err)
+		}
+	}
+
+	r := round.NewState_Testing(42, 0, connect.NewCircuit(nodeList), t)
+	roundTracker := NewRoundTracker()
+	for i, nid := range nodeList {
+		re := &mixmessages.RoundError{
+			Id:     42,
+			NodeId: nid.Marshal(),
+			Error:  "error from node " + strconv.Itoa(i),
+		}
+		err = killRound(testState, r, re, "", roundTracker)
+		if err != nil {
+			t.Fatalf("Failed to kill round for node %d: %+v", i, err)
+		}
+	}
+
+	if errs := r.BuildRoundInfo().Errors; len(errs) != 2 {
+		t.Errorf("Expected 2 published round errors, received %d: %+v",
+			len(errs), errs)
+	}
+	if r.GetSuppressedErrors() != 3 {
+		t.Errorf("Unexpected number of suppressed errors."+
+			"\nexpected: %d\nreceived: %d", 3, r.GetSuppressedErrors())
+	}
+}
+
 // Tests that the Precomputing case of HandleNodeUpdates produces the correct
 // error when there is no round.
 func TestHandleNodeUpdates_Precomputing_RoundError(t *testing.T) {
@@ -772,7 +876,6 @@ func TestHandleNodeUpdates_Precomputing_RoundError(t *testing.T) {
 	timeoutCh := make(chan id.Round, 1)
 
 	sc := &stateChanger{
-		lastRealtime:     time.Unix(0, 0),
 		realtimeDelay:    0,
 		realtimeDelta:    0,
 		realtimeTimeout:  15 * time.Second,
@@ -838,7 +941,6 @@ func TestHandleNodeUpdates_Realtime(t *testing.T) {
 	timeoutCh := make(chan id.Round, 1)
 
 	sc := &stateChanger{
-		lastRealtime:     time.Unix(0, 0),
 		realtimeDelay:    0,
 		realtimeDelta:    0,
 		realtimeTimeout:  15 * time.Second,
@@ -897,7 +999,6 @@ func TestHandleNodeUpdates_Realtime_RoundError(t *testing.T) {
 	timeoutCh := make(chan id.Round, 1)
 
 	sc := &stateChanger{
-		lastRealtime:     time.Unix(0, 0),
 		realtimeDelay:    0,
 		realtimeDelta:    0,
 		realtimeTimeout:  15 * time.Second,
@@ -967,7 +1068,6 @@ func TestHandleNodeUpdates_Realtime_UpdateError(t *testing.T) {
 	timeoutCh := make(chan id.Round, 1)
 
 	sc := &stateChanger{
-		lastRealtime:     time.Unix(0, 0),
 		realtimeDelay:    0,
 		realtimeDelta:    0,
 		realtimeTimeout:  15 * time.Second,
@@ -1034,7 +1134,6 @@ func TestHandleNodeUpdates_RoundErrored(t *testing.T) {
 	timeoutCh := make(chan id.Round, 1)
 
 	sc := &stateChanger{
-		lastRealtime:     time.Unix(0, 0),
 		realtimeDelay:    0,
 		realtimeDelta:    0,
 		realtimeTimeout:  15 * time.Second,
@@ -1083,7 +1182,6 @@ func TestHandleNodeUpdates_NOT_STARTED(t *testing.T) {
 	testState.GetNodeMap().GetNode(nodeList[0]).GetPollingLock().Lock()
 	timeoutCh := make(chan id.Round, 1)
 	sc := &stateChanger{
-		lastRealtime:     time.Unix(0, 0),
 		realtimeDelay:    0,
 		realtimeDelta:    0,
 		realtimeTimeout:  15 * time.Second,
@@ -1098,3 +1196,270 @@ func TestHandleNodeUpdates_NOT_STARTED(t *testing.T) {
 		t.Errorf("Happy path received error: %v", err)
 	}
 }
+
+// Tests that when nodes report finishing precomputation and realtime at
+// staggered times, the last node to report each phase is stored as the
+// round's straggler along with how long it trailed the first node.
+func TestHandleNodeUpdates_Stragglers(t *testing.T) {
+	var err error
+	storage.PermissioningDb, _, err = storage.NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	privKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	testState, err := storage.NewState(privKey, 8, "", "", region.GetCountryBins())
+	if err != nil {
+		t.Fatalf("Failed to create test state: %v", err)
+	}
+
+	nodeList := make([]*id.ID, 3)
+	for i := range nodeList {
+		nodeList[i] = id.NewIdFromUInt(uint64(i), id.Node, t)
+		err = testState.GetNodeMap().AddNode(nodeList[i], "", "", "", uint64(i+1))
+		if err != nil {
+			t.Fatalf("Couldn't add node: %v", err)
+		}
+		err = storage.PermissioningDb.InsertApplication(
+			&storage.Application{Id: uint64(i + 1)},
+			&storage.Node{Code: strconv.Itoa(i), Id: nodeList[i].Marshal(),
+				ApplicationId: uint64(i + 1)})
+		if err != nil {
+			t.Fatalf("Failed to insert node: %+v", err)
+		}
+	}
+
+	roundState, err := testState.GetRoundMap().AddRound(1, 32, 8,
+		5*time.Minute, connect.NewCircuit(nodeList))
+	if err != nil {
+		t.Fatalf("Failed to add round: %v", err)
+	}
+	if err = roundState.Update(states.PRECOMPUTING, time.Now()); err != nil {
+		t.Fatalf("Failed to update round: %v", err)
+	}
+
+	sc := &stateChanger{
+		realtimeTimeout:  15 * time.Second,
+		pool:             NewWaitingPool(),
+		state:            testState,
+		roundTracker:     NewRoundTracker(),
+		roundTimeoutChan: make(chan id.Round, 1),
+	}
+
+	// Reports each node's activity in the given order, with the last node
+	// reporting well after the others
+	report := func(order []int, activity current.Activity) {
+		for i, idx := range order {
+			if i == len(order)-1 {
+				time.Sleep(50 * time.Millisecond)
+			}
+			n := testState.GetNodeMap().GetNode(nodeList[idx])
+			n.GetPollingLock().Lock()
+			err := sc.HandleNodeUpdates(node.UpdateNotification{
+				Node:       nodeList[idx],
+				ToActivity: activity,
+			})
+			if err != nil {
+				t.Fatalf("Failed to handle %s update of node %d: %+v",
+					activity, idx, err)
+			}
+		}
+	}
+
+	for _, nid := range nodeList {
+		_ = testState.GetNodeMap().GetNode(nid).SetRound(roundState)
+	}
+	report([]int{0, 2, 1}, current.STANDBY)
+
+	if err = roundState.Update(states.REALTIME, time.Now()); err != nil {
+		t.Fatalf("Failed to update round: %v", err)
+	}
+	report([]int{1, 2, 0}, current.COMPLETED)
+
+	// The round metric is stored in another thread
+	var stats []*storage.StragglerStats
+	for i := 0; i < 100 && len(stats) == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+		stats, err = storage.PermissioningDb.GetStragglerStats(time.Unix(0, 0))
+		if err != nil {
+			t.Fatalf("GetStragglerStats() returned an error: %+v", err)
+		}
+	}
+
+	if len(stats) != 2 {
+		t.Fatalf("Expected stats for two stragglers, received %d", len(stats))
+	}
+	for _, s := range stats {
+		switch {
+		case nodeList[1].Cmp(mustUnmarshalID(s.NodeId, t)):
+			if s.PrecompCount != 1 || s.RealtimeCount != 0 ||
+				s.AvgPrecompDelta < 50*time.Millisecond {
+				t.Errorf("Unexpected precomp straggler stats: %+v", s)
+			}
+		case nodeList[0].Cmp(mustUnmarshalID(s.NodeId, t)):
+			if s.RealtimeCount != 1 || s.PrecompCount != 0 ||
+				s.AvgRealtimeDelta < 50*time.Millisecond {
+				t.Errorf("Unexpected realtime straggler stats: %+v", s)
+			}
+		default:
+			t.Errorf("Unexpected straggler: %+v", s)
+		}
+	}
+}
+
+// Tests that rounds completed and failed through HandleNodeUpdates() are
+// counted towards the round failure rate.
+func TestHandleNodeUpdates_RoundHealth(t *testing.T) {
+	var err error
+	storage.PermissioningDb, _, err = storage.NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	privKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	testState, err := storage.NewState(privKey, 8, "", "", region.GetCountryBins())
+	if err != nil {
+		t.Fatalf("Failed to create test state: %v", err)
+	}
+
+	nodeList := make([]*id.ID, 3)
+	for i := range nodeList {
+		nodeList[i] = id.NewIdFromUInt(uint64(i), id.Node, t)
+		err = testState.GetNodeMap().AddNode(nodeList[i], strconv.Itoa(i), "", "", 0)
+		if err != nil {
+			t.Fatalf("Couldn't add node: %v", err)
+		}
+	}
+	sc := &stateChanger{
+		realtimeTimeout:  15 * time.Second,
+		pool:             NewWaitingPool(),
+		state:            testState,
+		roundTracker:     NewRoundTracker(),
+		roundTimeoutChan: make(chan id.Round, 1),
+	}
+
+	// Runs a round in which every node reports the activity
+	runRound := func(roundID id.Round, activity current.Activity) {
+		roundState, err := testState.GetRoundMap().AddRound(roundID, 32, 8,
+			5*time.Minute, connect.NewCircuit(nodeList))
+		if err != nil {
+			t.Fatalf("Failed to add round: %v", err)
+		}
+		for _, nid := range nodeList {
+			n := testState.GetNodeMap().GetNode(nid)
+			_ = n.SetRound(roundState)
+			n.GetPollingLock().Lock()
+			err = sc.HandleNodeUpdates(node.UpdateNotification{Node: nid,
+				FromActivity: current.REALTIME, ToActivity: activity,
+				Error: &mixmessages.RoundError{Id: uint64(roundID),
+					NodeId: nid.Marshal(), Error: "test"}})
+			if err != nil {
+				t.Fatalf("Failed to handle %s update: %+v", activity, err)
+			}
+		}
+	}
+	for rid := id.Round(1); rid <= 3; rid++ {
+		runRound(rid, current.COMPLETED)
+	}
+	runRound(4, current.ERROR)
+
+	health := testState.GetRoundHealth(time.Now())
+	if health.Completed != 3 || health.Failed != 1 {
+		t.Errorf("Unexpected round health: %+v", health)
+	}
+	if rate := health.FailureRate(); rate != 0.25 {
+		t.Errorf("Unexpected failure rate.\nexpected: %v\nreceived: %v",
+			0.25, rate)
+	}
+
+	// Rounds which ended before the window are not counted
+	health = testState.GetRoundHealth(time.Now().Add(storage.DefaultRoundHealthWindow + time.Second))
+	if health.Completed != 0 || health.Failed != 0 || health.FailureRate() != 0 {
+		t.Errorf("Rounds outside the window were counted: %+v", health)
+	}
+}
+
+func mustUnmarshalID(b []byte, t *testing.T) *id.ID {
+	nid, err := id.Unmarshal(b)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal ID: %+v", err)
+	}
+	return nid
+}
+
+// Tests that StoreRoundMetric() does not panic on a round info with too few
+// timestamps, and stores the timestamps it has.
+func TestStoreRoundMetric_TruncatedTimestamps(t *testing.T) {
+	var err error
+	storage.PermissioningDb, _, err = storage.NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	storage.PermissioningDb.SetMetricRetry(1, time.Millisecond)
+
+	precompStart := time.Now().Add(-time.Minute)
+	roundInfo := &mixmessages.RoundInfo{
+		ID:         1,
+		BatchSize:  32,
+		Timestamps: []uint64{0, uint64(precompStart.UnixNano()), 0},
+	}
+	realtimeTs := time.Now().UnixNano()
+	StoreRoundMetric(roundInfo, states.COMPLETED, realtimeTs, nil, nil, nil)
+
+	roundId, realtimeStart, err := storage.PermissioningDb.GetEarliestRound(time.Hour)
+	if err != nil {
+		t.Fatalf("Round metric was not stored: %+v", err)
+	}
+	if roundId != 1 {
+		t.Errorf("Unexpected round stored: %d", roundId)
+	}
+	if !realtimeStart.Equal(time.Unix(0, 0)) {
+		t.Errorf("Missing timestamp was not stored as zero: %s", realtimeStart)
+	}
+
+	if ts := roundTimestamp(roundInfo, states.PRECOMPUTING); !ts.Equal(precompStart) {
+		t.Errorf("Unexpected timestamp for a present state."+
+			"\nexpected: %s\nreceived: %s", precompStart, ts)
+	}
+	if ts := roundTimestamp(&mixmessages.RoundInfo{}, states.COMPLETED); !ts.Equal(time.Unix(0, 0)) {
+		t.Errorf("Unexpected timestamp without timestamps: %s", ts)
+	}
+}
+
+// Tests that the update of a round killed in precomputation tells clients
+// nothing was lost, while the update of one killed in realtime tells them to
+// resend.
+func TestKillRound_FailurePhase(t *testing.T) {
+	var err error
+	storage.PermissioningDb, _, err = storage.NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("Failed to create database: %+v", err)
+	}
+	privKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	testState, err := storage.NewState(privKey, 8, "", "", region.GetCountryBins())
+	if err != nil {
+		t.Fatalf("Failed to create test state: %v", err)
+	}
+	nodeList := []*id.ID{id.NewIdFromUInt(0, id.Node, t)}
+	if err = testState.GetNodeMap().AddNode(nodeList[0], "0", "", "", 0); err != nil {
+		t.Fatalf("Couldn't add node: %v", err)
+	}
+
+	resends := make(map[states.Round]bool)
+	for i, s := range []states.Round{states.PRECOMPUTING, states.REALTIME} {
+		r := round.NewState_Testing(id.Round(i+1), s, connect.NewCircuit(nodeList), t)
+		re := &mixmessages.RoundError{Id: uint64(i + 1), Error: "test"}
+		if err = killRound(testState, r, re, "", NewRoundTracker()); err != nil {
+			t.Fatalf("Failed to kill round in %s: %v", s, err)
+		}
+
+		failedIn, resend, ok := round.GetFailure(r.BuildRoundInfo())
+		if !ok || failedIn != s {
+			t.Errorf("Round killed in %s describes a failure in %s (%t).",
+				s, failedIn, ok)
+		}
+		resends[s] = resend
+	}
+	if resends[states.PRECOMPUTING] || !resends[states.REALTIME] {
+		t.Errorf("Unexpected resends of killed rounds: %v", resends)
+	}
+}
@@ -0,0 +1,194 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Contains the setters used to change scheduling parameters while the
+// scheduler is running
+
+package scheduling
+
+import (
+	"github.com/pkg/errors"
+	jww "github.com/spf13/jwalterweatherman"
+	"gitlab.com/elixxir/registration/storage"
+	"strconv"
+	"time"
+)
+
+// SetTeamSize changes the number of nodes in a team. The team size must be
+// positive and no larger than maxTeamSize, the number of nodes teams can be
+// drawn from. Takes effect on the next round created.
+func (s *SafeParams) SetTeamSize(teamSize uint32, maxTeamSize int) error {
+	if teamSize == 0 {
+		return errors.New("Team size must be positive")
+	}
+	if int(teamSize) > maxTeamSize {
+		return errors.Errorf("Team size of %d exceeds the %d nodes teams "+
+			"can be drawn from", teamSize, maxTeamSize)
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	jww.INFO.Printf("Updating scheduling team size from %d to %d",
+		s.TeamSize, teamSize)
+	s.TeamSize = teamSize
+	s.version++
+	return nil
+}
+
+// SetBatchSize changes the number of slots in a batch. The batch size must be
+// positive. Takes effect on the next round created.
+func (s *SafeParams) SetBatchSize(batchSize uint32) error {
+	if batchSize == 0 {
+		return errors.New("Batch size must be positive")
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	jww.INFO.Printf("Updating scheduling batch size from %d to %d",
+		s.BatchSize, batchSize)
+	s.BatchSize = batchSize
+	s.version++
+	return nil
+}
+
+// SetMinimumDelay changes the minimum time between assigning rounds. The
+// delay must not be negative and is stored in milliseconds, like every time in
+// the Params. Takes effect on the next round created.
+func (s *SafeParams) SetMinimumDelay(delay time.Duration) error {
+	if delay < 0 {
+		return errors.Errorf("Minimum delay of %s is negative", delay)
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	jww.INFO.Printf("Updating scheduling minimum delay from %s to %s",
+		s.MinimumDelay*time.Millisecond, delay)
+	s.MinimumDelay = delay / time.Millisecond
+	s.version++
+	return nil
+}
+
+// versionedCopy returns a copy of the Params along with their version, which
+// changes whenever the Params are modified.
+func (s *SafeParams) versionedCopy() (Params, uint64) {
+	s.RLock()
+	defer s.RUnlock()
+	return *s.Params, s.version
+}
+
+// getVersion returns the version of the Params.
+func (s *SafeParams) getVersion() uint64 {
+	s.RLock()
+	defer s.RUnlock()
+	return s.version
+}
+
+// stateParams are the scheduling params stored in the State table. Times are
+// in ms, as in the Params.
+type stateParams struct {
+	teamSize        uint32
+	batchSize       uint32
+	precompTimeout  time.Duration
+	realtimeTimeout time.Duration
+	minDelay        time.Duration
+	realtimeDelay   time.Duration
+	threshold       float64
+}
+
+// loadStateParams reads the scheduling params from the State table.
+func loadStateParams() (stateParams, error) {
+	keys := []string{storage.TeamSize, storage.BatchSize,
+		storage.PrecompTimeout, storage.RealtimeTimeout, storage.MinDelay,
+		storage.AdvertisementTimeout}
+	values := make([]uint64, len(keys))
+	for i, key := range keys {
+		value, err := storage.PermissioningDb.GetStateInt(key)
+		if err != nil {
+			return stateParams{}, err
+		}
+		values[i] = value
+	}
+
+	valueStr, err := storage.PermissioningDb.GetStateValue(storage.PoolThreshold)
+	if err != nil {
+		return stateParams{}, errors.Errorf("Unable to find %s: %+v",
+			storage.PoolThreshold, err)
+	}
+	threshold, err := strconv.ParseFloat(valueStr, 64)
+	if err != nil {
+		return stateParams{}, errors.Errorf("Unable to decode %s: %+v",
+			valueStr, err)
+	}
+
+	return stateParams{
+		teamSize:        uint32(values[0]),
+		batchSize:       uint32(values[1]),
+		precompTimeout:  time.Duration(values[2]),
+		realtimeTimeout: time.Duration(values[3]),
+		minDelay:        time.Duration(values[4]),
+		realtimeDelay:   time.Duration(values[5]),
+		threshold:       threshold,
+	}, nil
+}
+
+// applyStateParams applies each of the params read from the State table which
+// changed since they were last read, or every param if they have not been
+// read before. Params set since by an admin setter or a scheduling profile
+// are otherwise kept. The version only changes if a param does. Returns true
+// if any param changed.
+func (s *SafeParams) applyStateParams(current stateParams, last *stateParams) bool {
+	s.Lock()
+	defer s.Unlock()
+
+	first := last == nil
+	if first {
+		last = &stateParams{}
+	}
+	changed := false
+	if (first || current.teamSize != last.teamSize) &&
+		s.TeamSize != current.teamSize {
+		s.TeamSize = current.teamSize
+		changed = true
+	}
+	if (first || current.batchSize != last.batchSize) &&
+		s.BatchSize != current.batchSize {
+		s.BatchSize = current.batchSize
+		changed = true
+	}
+	if (first || current.precompTimeout != last.precompTimeout) &&
+		s.PrecomputationTimeout != current.precompTimeout {
+		s.PrecomputationTimeout = current.precompTimeout
+		changed = true
+	}
+	if (first || current.realtimeTimeout != last.realtimeTimeout) &&
+		s.RealtimeTimeout != current.realtimeTimeout {
+		s.RealtimeTimeout = current.realtimeTimeout
+		changed = true
+	}
+	if (first || current.minDelay != last.minDelay) &&
+		s.MinimumDelay != current.minDelay {
+		s.MinimumDelay = current.minDelay
+		changed = true
+	}
+	if (first || current.realtimeDelay != last.realtimeDelay) &&
+		s.RealtimeDelay != current.realtimeDelay {
+		s.RealtimeDelay = current.realtimeDelay
+		changed = true
+	}
+	if (first || current.threshold != last.threshold) &&
+		s.Threshold != current.threshold {
+		s.Threshold = current.threshold
+		changed = true
+	}
+
+	if changed {
+		jww.INFO.Printf("Updated scheduling params from the State table: "+
+			"%+v", current)
+		s.version++
+	}
+	return changed
+}
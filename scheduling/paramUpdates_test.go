@@ -0,0 +1,180 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package scheduling
+
+import (
+	"crypto/rand"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/xx_network/crypto/signature/rsa"
+	"gitlab.com/xx_network/primitives/id"
+	"gitlab.com/xx_network/primitives/region"
+	mathRand "math/rand"
+	"testing"
+	"time"
+)
+
+// Tests that changing the team size at runtime changes the size of the
+// rounds created afterwards.
+func TestSafeParams_SetTeamSize(t *testing.T) {
+	var err error
+	storage.PermissioningDb, _, err = storage.NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("Failed to create database: %+v", err)
+	}
+	privKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	testState, err := storage.NewState(privKey, 8, "", "", region.GetCountryBins())
+	if err != nil {
+		t.Fatalf("Failed to create test state: %+v", err)
+	}
+
+	const numNodes = 8
+	fillPool := func() *waitingPool {
+		pool := NewWaitingPool()
+		for _, n := range testState.GetNodeMap().GetNodeStates() {
+			pool.Add(n)
+		}
+		return pool
+	}
+	for i := uint64(0); i < numNodes; i++ {
+		err = testState.GetNodeMap().AddNode(id.NewIdFromUInt(i, id.Node, t),
+			"US", "", "", 0)
+		if err != nil {
+			t.Fatalf("Couldn't add node: %+v", err)
+		}
+	}
+
+	params := &SafeParams{Params: &Params{TeamSize: 3, BatchSize: 32}}
+	paramsCopy, version := params.versionedCopy()
+	prng := mathRand.New(mathRand.NewSource(42))
+
+	r, err := createSecureRound(paramsCopy, fillPool(), 0, 1, testState, prng)
+	if err != nil {
+		t.Fatalf("Failed to create round: %+v", err)
+	}
+	if len(r.NodeStateList) != 3 {
+		t.Errorf("Unexpected team size.\nexpected: %d\nreceived: %d",
+			3, len(r.NodeStateList))
+	}
+
+	if err = params.SetTeamSize(5, numNodes); err != nil {
+		t.Fatalf("Failed to set team size: %+v", err)
+	}
+	if params.getVersion() == version {
+		t.Fatalf("Params version not changed by setting the team size.")
+	}
+
+	paramsCopy, _ = params.versionedCopy()
+	r, err = createSecureRound(paramsCopy, fillPool(), 0, 2, testState, prng)
+	if err != nil {
+		t.Fatalf("Failed to create round: %+v", err)
+	}
+	if len(r.NodeStateList) != 5 || r.Topology.Len() != 5 {
+		t.Errorf("Round created after the change does not use the new "+
+			"team size.\nexpected: %d\nreceived: %d", 5, len(r.NodeStateList))
+	}
+}
+
+// Error path: tests that invalid team sizes are rejected and leave the
+// params unchanged.
+func TestSafeParams_SetTeamSize_Invalid(t *testing.T) {
+	params := &SafeParams{Params: &Params{TeamSize: 3}}
+
+	if err := params.SetTeamSize(0, 10); err == nil {
+		t.Errorf("Team size of zero accepted.")
+	}
+	if err := params.SetTeamSize(11, 10); err == nil {
+		t.Errorf("Team size larger than the pool accepted.")
+	}
+	if params.TeamSize != 3 || params.getVersion() != 0 {
+		t.Errorf("Params changed by invalid team sizes: %+v", params.Params)
+	}
+}
+
+// Tests that the batch size is validated and updated.
+func TestSafeParams_SetBatchSize(t *testing.T) {
+	params := &SafeParams{Params: &Params{BatchSize: 32}}
+
+	if err := params.SetBatchSize(0); err == nil {
+		t.Errorf("Batch size of zero accepted.")
+	}
+	if err := params.SetBatchSize(64); err != nil {
+		t.Fatalf("Failed to set batch size: %+v", err)
+	}
+	if params.SafeCopy().BatchSize != 64 {
+		t.Errorf("Batch size not updated: %d", params.SafeCopy().BatchSize)
+	}
+}
+
+// Tests that the minimum delay is validated and stored in milliseconds.
+func TestSafeParams_SetMinimumDelay(t *testing.T) {
+	params := &SafeParams{Params: &Params{MinimumDelay: 100}}
+
+	if err := params.SetMinimumDelay(-time.Second); err == nil {
+		t.Errorf("Negative minimum delay accepted.")
+	}
+	if err := params.SetMinimumDelay(2 * time.Second); err != nil {
+		t.Fatalf("Failed to set minimum delay: %+v", err)
+	}
+	if params.SafeCopy().MinimumDelay != 2000 {
+		t.Errorf("Unexpected minimum delay.\nexpected: %d\nreceived: %d",
+			2000, params.SafeCopy().MinimumDelay)
+	}
+}
+
+// Tests that params read from the State table only replace those set by an
+// admin setter once they change in the table, and that the version only
+// changes when a param does.
+func TestSafeParams_applyStateParams(t *testing.T) {
+	params := &SafeParams{Params: &Params{TeamSize: 4, BatchSize: 32,
+		Threshold: 0.3}}
+	stored := stateParams{teamSize: 5, batchSize: 32, threshold: 0.3,
+		minDelay: 100, realtimeDelay: 1000}
+
+	// Every param is applied the first time the table is read
+	if !params.applyStateParams(stored, nil) {
+		t.Errorf("Params read for the first time were not applied.")
+	}
+	if params.TeamSize != 5 || params.MinimumDelay != 100 ||
+		params.RealtimeDelay != 1000 || params.getVersion() != 1 {
+		t.Errorf("Unexpected params after the first read: %+v, version %d",
+			*params.Params, params.getVersion())
+	}
+
+	// Unchanged params in the table keep those set by an admin setter
+	if err := params.SetTeamSize(3, 8); err != nil {
+		t.Fatalf("Failed to set team size: %+v", err)
+	}
+	last := stored
+	if params.applyStateParams(stored, &last) {
+		t.Errorf("Unchanged params in the table were reported as changed.")
+	}
+	if params.TeamSize != 3 || params.getVersion() != 2 {
+		t.Errorf("Unchanged params in the table replaced those set by an "+
+			"admin: team size %d, version %d", params.TeamSize,
+			params.getVersion())
+	}
+
+	// A change in the table is applied without touching the others
+	stored.batchSize = 64
+	if !params.applyStateParams(stored, &last) {
+		t.Errorf("Changed param in the table was not applied.")
+	}
+	if params.BatchSize != 64 || params.TeamSize != 3 ||
+		params.getVersion() != 3 {
+		t.Errorf("Unexpected params after a change in the table: %+v, "+
+			"version %d", *params.Params, params.getVersion())
+	}
+
+	// A change in the table to the value already in use changes nothing
+	last = stored
+	stored.teamSize = 3
+	if params.applyStateParams(stored, &last) || params.getVersion() != 3 {
+		t.Errorf("Change in the table to the current value bumped the "+
+			"version to %d.", params.getVersion())
+	}
+}
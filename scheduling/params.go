@@ -25,6 +25,13 @@ type SafeParams struct {
 
 	// Hold a reference to the actual Params
 	*Params
+
+	// Name of the scheduling profile the Params were last switched to
+	activeProfile string
+
+	// Incremented whenever the Params are modified, so that the scheduler
+	// knows to form new rounds with the modified Params
+	version uint64
 }
 
 // Allows for safe duplication of the current internal Params object
@@ -48,18 +55,93 @@ type Params struct {
 	MinimumDelay time.Duration
 	// Delay for a realtime round to start
 	RealtimeDelay time.Duration
+	// How far past RealtimeDelay a round's realtime start may be pushed back
+	// to keep MinimumDelay between starts, unlimited when zero
+	MaxRealtimeLead time.Duration
+	// Time without realtime starts after which starts are no longer spaced
+	// from the last one, never when zero
+	RealtimeIdleGap time.Duration
 	// Time between cleaning up offline nodes
 	NodeCleanUpInterval time.Duration
 	// Time until round precomputation times out
 	PrecomputationTimeout time.Duration
 	// Time until round realtime times out
 	RealtimeTimeout time.Duration
+	// Time a node may stay precomputing its round before it is reported as
+	// stuck and the round's precomputation is timed out. Disabled when zero
+	StuckPrecompThreshold time.Duration
 	//Debug flag used to cause regular prints about the state of the network
 	DebugTrackRounds bool
 
 	//SECURE ONLY
 	// Minimum percentage of nodes in the waiting pool before secure teaming wil create a team
 	Threshold float64
+	// Time the pool may stay below the Threshold before the scheduler acts.
+	// When RelaxThreshold is set, the threshold is relaxed down to the team
+	// size until the pool reaches it again; otherwise, an error is logged
+	// every ThresholdTimeout. Disabled when zero
+	ThresholdTimeout time.Duration
+	RelaxThreshold   bool
+
+	// Teaming mode, empty for secure teaming. RoundRobinMode cycles through
+	// the nodes in registration order and ignores geography, ordering and
+	// the Threshold; it is unsafe for production
+	Mode string
+
+	// Name of a node group to build every team from. When set, teams are
+	// drawn only from the group's members instead of the general pool
+	NodeGroup string
+
+	// When set, a team is never formed with Nodes whose Applications are on
+	// each other's avoid-lists and the round is skipped instead. Otherwise,
+	// such teams are only formed when no alternative Node is available
+	HardAvoidLists bool
+
+	// When set, rounds with a batch size of at least CapacityBatchSize are
+	// built from the Nodes reporting the highest capacity in the pool
+	CapacityAware     bool
+	CapacityBatchSize uint32
+
+	// When greater than 1, nodes from geographic bins teamed less than their
+	// share of the active nodes are picked more often, and nodes from bins
+	// teamed more are picked less often, by at most this factor. Bins are
+	// compared over the last FairnessWindow rounds
+	FairnessCorrection float64
+	FairnessWindow     uint32
+
+	// When either is set, newly registered nodes are on probation until they
+	// have completed ProbationRounds rounds and ProbationDuration has passed
+	// since the first of them. Teams have at most one node on probation, and
+	// a failed round restarts the node's probation
+	ProbationRounds   uint32
+	ProbationDuration time.Duration
+
+	// Least fraction of the recent node metric intervals a node must have
+	// polled in to be picked for a secure team. Nodes below it stay waiting
+	// but are held out of teams until their uptime reaches it. Disabled when
+	// zero
+	MinUptime float64
+
+	// Number of rounds a node may fail before reporting STANDBY within
+	// PrecompFailureWindow before it is demoted to the diagnostic state.
+	// Demoted nodes are held out of teams for PrecompFailureWindow, then are
+	// demoted again by any failure before STANDBY until they recover.
+	// Disabled when zero
+	PrecompFailureThreshold uint32
+	// Window in which rounds failed before STANDBY are counted (in ms)
+	PrecompFailureWindow time.Duration
+	// Number of clean rounds in a row a node in the diagnostic state must
+	// complete to recover. Nodes are only reactivated manually when zero
+	DiagnosticRecoveryRounds uint32
+
+	// Fraction of the active nodes with checked connectivity which must be
+	// reachable for rounds to be created. Below it, round creation is paused
+	// until reachability recovers. Disabled when zero
+	ReachabilityThreshold float64
+
+	// Optional set of profiles which replace these Params during their
+	// daily window. The windows must cover the whole day without overlapping
+	Profiles []Profile
 }
 
 //internal structure which describes a round to be created
@@ -69,4 +151,13 @@ type protoRound struct {
 	NodeStateList        []*node.State
 	BatchSize            uint32
 	ResourceQueueTimeout time.Duration
+
+	// Timeouts and delays of the params the round was formed with, so that
+	// later changes to the params do not affect it
+	PrecomputationTimeout time.Duration
+	RealtimeTimeout       time.Duration
+	RealtimeDelay         time.Duration
+
+	// JSON encoded scheduling config of the params the round was formed with
+	SchedulingConfig []byte
 }
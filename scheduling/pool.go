@@ -12,8 +12,11 @@ import (
 	"github.com/pkg/errors"
 	jww "github.com/spf13/jwalterweatherman"
 	"gitlab.com/elixxir/crypto/shuffle"
+	"gitlab.com/elixxir/primitives/current"
 	"gitlab.com/elixxir/registration/storage/node"
+	"sort"
 	"sync"
+	"time"
 )
 
 // pool.go contains logic for the secure teaming algorithm's
@@ -25,9 +28,24 @@ import (
 //   case offline nodes are placed in the offline set
 // Offline holds nodes found to be offline. Nodes need
 //   to be manually set back to online with a function call
+// Unhealthy holds waiting nodes which reported a critical
+//   health error until they report healthy
+// LowUptime holds waiting nodes whose uptime is below minUptime
+//   until it reaches it
+// Diagnostic holds waiting nodes in the diagnostic state until
+//   diagnosticHold has passed since they were demoted
 type waitingPool struct {
-	pool    *set.Set
-	offline *set.Set
+	pool       *set.Set
+	offline    *set.Set
+	unhealthy  *set.Set
+	lowUptime  *set.Set
+	diagnostic *set.Set
+
+	// Least uptime of nodes in the online pool, no minimum when zero
+	minUptime float64
+	// How long nodes are held out of the online pool after being demoted
+	//   to the diagnostic state
+	diagnosticHold time.Duration
 
 	mux sync.RWMutex
 }
@@ -35,8 +53,11 @@ type waitingPool struct {
 // NewWaitingPool is a constructor for the waiting pool object
 func NewWaitingPool() *waitingPool {
 	return &waitingPool{
-		pool:    set.New(),
-		offline: set.New(),
+		pool:       set.New(),
+		offline:    set.New(),
+		unhealthy:  set.New(),
+		lowUptime:  set.New(),
+		diagnostic: set.New(),
 	}
 }
 
@@ -54,21 +75,180 @@ func (wp *waitingPool) OfflineLen() int {
 	return wp.offline.Len()
 }
 
-// Add inserts a node into the online pool
+// Add inserts a node into the online pool, or holds it aside if it
+//   reported a critical health error, was recently demoted to the
+//   diagnostic state or its uptime is below the minimum
 func (wp *waitingPool) Add(n *node.State) {
 	wp.mux.Lock()
-	wp.pool.Insert(n)
+	wp.insert(n)
 	wp.mux.Unlock()
 }
 
+// insert places the node in the online pool or, if it reported a critical
+//   health error, the unhealthy set, or, if it was recently demoted to the
+//   diagnostic state, the diagnostic set, or, if its uptime is below the
+//   minimum, the low uptime set. Must be called with the lock held
+func (wp *waitingPool) insert(n *node.State) {
+	if n.IsHealthCritical() {
+		wp.pool.Remove(n)
+		wp.lowUptime.Remove(n)
+		wp.diagnostic.Remove(n)
+		wp.unhealthy.Insert(n)
+		return
+	}
+	wp.unhealthy.Remove(n)
+	if wp.isHeldDiagnostic(n, time.Now()) {
+		wp.pool.Remove(n)
+		wp.lowUptime.Remove(n)
+		wp.diagnostic.Insert(n)
+		return
+	}
+	wp.diagnostic.Remove(n)
+	if n.GetUptime() < wp.minUptime {
+		wp.pool.Remove(n)
+		wp.lowUptime.Insert(n)
+		return
+	}
+	wp.lowUptime.Remove(n)
+	wp.pool.Insert(n)
+}
+
 // Removes the node from the pool banning it
 func (wp *waitingPool) Ban(n *node.State) {
 	wp.mux.Lock()
 	wp.pool.Remove(n)
 	wp.offline.Remove(n)
+	wp.unhealthy.Remove(n)
+	wp.lowUptime.Remove(n)
+	wp.diagnostic.Remove(n)
 	wp.mux.Unlock()
 }
 
+// SweepUnhealthy holds aside the nodes in the online pool which reported a
+//   critical health error, and returns held nodes which reported healthy
+//   since to the online pool. Held nodes which are no longer waiting are
+//   dropped, as they are added again once they next wait
+func (wp *waitingPool) SweepUnhealthy() {
+	wp.mux.Lock()
+	defer wp.mux.Unlock()
+
+	var moved []*node.State
+	wp.pool.Do(func(face interface{}) {
+		if ns := face.(*node.State); ns.IsHealthCritical() {
+			moved = append(moved, ns)
+		}
+	})
+	wp.unhealthy.Do(func(face interface{}) {
+		if ns := face.(*node.State); !ns.IsHealthCritical() {
+			moved = append(moved, ns)
+		}
+	})
+
+	for _, ns := range moved {
+		if !ns.IsHealthCritical() && ns.GetActivity() != current.WAITING {
+			wp.unhealthy.Remove(ns)
+			continue
+		}
+		wp.insert(ns)
+	}
+}
+
+// SweepLowUptime sets the least uptime of nodes in the online pool, holding
+//   aside the nodes below it and returning held nodes which reached it since
+//   to the online pool. Held nodes which are no longer waiting are dropped,
+//   as they are added again once they next wait. A minimum of zero returns
+//   every held node
+func (wp *waitingPool) SweepLowUptime(minUptime float64) {
+	wp.mux.Lock()
+	defer wp.mux.Unlock()
+
+	wp.minUptime = minUptime
+	var moved, dropped []*node.State
+	wp.pool.Do(func(face interface{}) {
+		if ns := face.(*node.State); ns.GetUptime() < minUptime {
+			moved = append(moved, ns)
+		}
+	})
+	wp.lowUptime.Do(func(face interface{}) {
+		ns := face.(*node.State)
+		if ns.GetActivity() != current.WAITING {
+			dropped = append(dropped, ns)
+		} else if ns.GetUptime() >= minUptime {
+			moved = append(moved, ns)
+		}
+	})
+
+	for _, ns := range dropped {
+		wp.lowUptime.Remove(ns)
+	}
+	for _, ns := range moved {
+		wp.insert(ns)
+	}
+}
+
+// SweepDiagnostic sets how long nodes are held out of the online pool after
+//   being demoted to the diagnostic state, holding aside the nodes demoted
+//   since less than it ago and returning held nodes which are due another
+//   chance, or were reactivated, to the online pool. Held nodes which are
+//   no longer waiting are dropped, as they are added again once they next
+//   wait
+func (wp *waitingPool) SweepDiagnostic(hold time.Duration) {
+	wp.mux.Lock()
+	defer wp.mux.Unlock()
+
+	wp.diagnosticHold = hold
+	now := time.Now()
+	var moved, dropped []*node.State
+	wp.pool.Do(func(face interface{}) {
+		if ns := face.(*node.State); wp.isHeldDiagnostic(ns, now) {
+			moved = append(moved, ns)
+		}
+	})
+	wp.diagnostic.Do(func(face interface{}) {
+		ns := face.(*node.State)
+		if ns.GetActivity() != current.WAITING {
+			dropped = append(dropped, ns)
+		} else if !wp.isHeldDiagnostic(ns, now) {
+			moved = append(moved, ns)
+		}
+	})
+
+	for _, ns := range dropped {
+		wp.diagnostic.Remove(ns)
+	}
+	for _, ns := range moved {
+		wp.insert(ns)
+	}
+}
+
+// isHeldDiagnostic returns true if the node is in the diagnostic state and
+//   was demoted to it less than diagnosticHold ago. Must be called with the
+//   lock held
+func (wp *waitingPool) isHeldDiagnostic(n *node.State, now time.Time) bool {
+	diagnostic, since, _ := n.GetDiagnostic()
+	return diagnostic && now.Sub(since) < wp.diagnosticHold
+}
+
+// SweepRemoved drops the nodes which were removed from the node map, such
+//   as when their registration was deleted, from every set
+func (wp *waitingPool) SweepRemoved() {
+	wp.mux.Lock()
+	defer wp.mux.Unlock()
+
+	for _, s := range []*set.Set{wp.pool, wp.offline, wp.unhealthy,
+		wp.lowUptime, wp.diagnostic} {
+		var removed []*node.State
+		s.Do(func(face interface{}) {
+			if ns := face.(*node.State); ns.IsRemoved() {
+				removed = append(removed, ns)
+			}
+		})
+		for _, ns := range removed {
+			s.Remove(ns)
+		}
+	}
+}
+
 // SetNodeToOnline removes a node from the offline pool and
 //  inserts it into the online pool
 func (wp *waitingPool) SetNodeToOnline(ns *node.State) {
@@ -77,7 +257,72 @@ func (wp *waitingPool) SetNodeToOnline(ns *node.State) {
 	defer wp.mux.Unlock()
 
 	wp.offline.Remove(ns)
-	wp.pool.Insert(ns)
+	wp.insert(ns)
+}
+
+// CountAvailable returns how many of the given nodes are in the online pool
+func (wp *waitingPool) CountAvailable(nodes []*node.State) int {
+	wp.mux.RLock()
+	defer wp.mux.RUnlock()
+
+	available := 0
+	for _, ns := range nodes {
+		if wp.pool.Has(ns) {
+			available++
+		}
+	}
+	return available
+}
+
+// PickNFromList collects the first n nodes of the given list which are in
+//   the online pool, removes them from the pool, and returns them.
+// If fewer than n of the listed nodes are in the pool, this function
+//   errors and no nodes are removed
+func (wp *waitingPool) PickNFromList(nodes []*node.State, n int) ([]*node.State, error) {
+	wp.mux.Lock()
+	defer wp.mux.Unlock()
+
+	nodeList := make([]*node.State, 0, n)
+	for _, ns := range nodes {
+		if len(nodeList) == n {
+			break
+		}
+		if wp.pool.Has(ns) {
+			nodeList = append(nodeList, ns)
+		}
+	}
+
+	if len(nodeList) < n {
+		return nil, errors.Errorf("Number of listed nodes in the pool (%v) "+
+			"not enough to pick %v nodes", len(nodeList), n)
+	}
+
+	// Remove collected nodes from pool
+	for _, ns := range nodeList {
+		wp.pool.Remove(ns)
+	}
+
+	return nodeList, nil
+}
+
+// PickMatching removes and returns a node from the online pool for which
+//   matches returns true. Returns nil if no node matches
+func (wp *waitingPool) PickMatching(matches func(ns *node.State) bool) *node.State {
+	wp.mux.Lock()
+	defer wp.mux.Unlock()
+
+	var picked *node.State
+	wp.pool.Do(func(face interface{}) {
+		ns := face.(*node.State)
+		if picked == nil && matches(ns) {
+			picked = ns
+		}
+	})
+
+	if picked != nil {
+		wp.pool.Remove(picked)
+	}
+	return picked
 }
 
 // PickNRandAtThreshold collects n nodes at random from the pool and returns
@@ -129,3 +374,51 @@ func (wp *waitingPool) PickNRandAtThreshold(thresh, n int) ([]*node.State, error
 	// Return collected ndoes
 	return nodeList, nil
 }
+
+// PickNByCapacityAtThreshold collects the n nodes in the pool reporting the
+//   highest capacity, choosing at random between nodes of equal capacity,
+//   and returns those nodes.
+// If there are not enough nodes, either from the threshold or
+//   the requested nodes, this function errors
+func (wp *waitingPool) PickNByCapacityAtThreshold(thresh, n int) ([]*node.State, error) {
+	wp.mux.Lock()
+	defer wp.mux.Unlock()
+
+	// Check that the pool meets the threshold requirement
+	if wp.pool.Len() < thresh {
+		return nil, errors.Errorf("Number of stored nodes (%v) does not reach threshold", wp.pool.Len())
+	}
+
+	// Check that the pool has enough nodes to satisfy n
+	if wp.pool.Len() < n {
+		return nil, errors.Errorf("Number of stored nodes (%v) not enough"+
+			" to pick %v nodes", wp.pool.Len(), n)
+	}
+
+	// Shuffle the pool so ties in capacity are broken at random
+	nodes := make([]*node.State, 0, wp.pool.Len())
+	wp.pool.Do(func(face interface{}) {
+		nodes = append(nodes, face.(*node.State))
+	})
+	numList := make([]uint32, len(nodes))
+	for i := range numList {
+		numList[i] = uint32(i)
+	}
+	shuffle.Shuffle32(&numList)
+	shuffled := make([]*node.State, len(nodes))
+	for i, j := range numList {
+		shuffled[i] = nodes[j]
+	}
+
+	sort.SliceStable(shuffled, func(i, j int) bool {
+		return shuffled[i].GetCapacity() > shuffled[j].GetCapacity()
+	})
+	nodeList := shuffled[:n]
+
+	// Remove collected nodes from pool
+	for _, ns := range nodeList {
+		wp.pool.Remove(ns)
+	}
+
+	return nodeList, nil
+}
@@ -10,6 +10,7 @@ package scheduling
 import (
 	"crypto/rand"
 	"github.com/golang-collections/collections/set"
+	"gitlab.com/elixxir/primitives/current"
 	"gitlab.com/elixxir/registration/storage"
 	"gitlab.com/elixxir/registration/storage/node"
 	"gitlab.com/xx_network/crypto/signature/rsa"
@@ -23,8 +24,11 @@ import (
 func TestNewWaitingPool(t *testing.T) {
 
 	expectedPool := &waitingPool{
-		pool:    set.New(),
-		offline: set.New(),
+		pool:       set.New(),
+		offline:    set.New(),
+		unhealthy:  set.New(),
+		lowUptime:  set.New(),
+		diagnostic: set.New(),
 	}
 
 	// Create a pool
@@ -199,6 +203,12 @@ func setupNode(t *testing.T, testState *storage.NetworkState, newId uint64) *nod
 }
 
 func setupNodeMap(t *testing.T) *storage.NetworkState {
+	var err error
+	storage.PermissioningDb, _, err = storage.NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("Failed to create database: %+v", err)
+	}
+
 	// Build network state
 	privKey, _ := rsa.GenerateKey(rand.Reader, 2048)
 
@@ -210,3 +220,257 @@ func setupNodeMap(t *testing.T) *storage.NetworkState {
 
 	return testState
 }
+
+// Tests that PickNFromList picks the first listed nodes which are in the pool
+// and removes them from the pool.
+func TestWaitingPool_PickNFromList(t *testing.T) {
+	testPool := NewWaitingPool()
+	testState := setupNodeMap(t)
+
+	nodes := make([]*node.State, 5)
+	for i := range nodes {
+		nodes[i] = setupNode(t, testState, uint64(i))
+		testPool.Add(nodes[i])
+	}
+	// Remove a listed node from the pool so it is skipped
+	testPool.Ban(nodes[3])
+
+	list := []*node.State{nodes[4], nodes[3], nodes[1], nodes[0]}
+	if available := testPool.CountAvailable(list); available != 3 {
+		t.Errorf("CountAvailable() returned %d, expected 3", available)
+	}
+
+	picked, err := testPool.PickNFromList(list, 2)
+	if err != nil {
+		t.Fatalf("PickNFromList() returned an error: %+v", err)
+	}
+	if !reflect.DeepEqual(picked, []*node.State{nodes[4], nodes[1]}) {
+		t.Errorf("PickNFromList() picked the wrong nodes.")
+	}
+	if testPool.Len() != 2 || testPool.pool.Has(nodes[4]) ||
+		testPool.pool.Has(nodes[1]) {
+		t.Errorf("Picked nodes not removed from the pool.")
+	}
+}
+
+// Error path: tests that PickNFromList errors without removing any nodes when
+// not enough of the listed nodes are in the pool.
+func TestWaitingPool_PickNFromList_NotEnough(t *testing.T) {
+	testPool := NewWaitingPool()
+	testState := setupNodeMap(t)
+
+	nodes := make([]*node.State, 3)
+	for i := range nodes {
+		nodes[i] = setupNode(t, testState, uint64(i))
+	}
+	testPool.Add(nodes[0])
+	testPool.Add(nodes[1])
+
+	_, err := testPool.PickNFromList(nodes, 3)
+	if err == nil {
+		t.Errorf("PickNFromList() should error when too few nodes are listed.")
+	}
+	if testPool.Len() != 2 {
+		t.Errorf("Nodes should not be removed from the pool on error.")
+	}
+}
+
+// Tests that PickMatching removes and returns only a matching node, and
+// returns nil when no node matches.
+func TestWaitingPool_PickMatching(t *testing.T) {
+	testPool := NewWaitingPool()
+	testState := setupNodeMap(t)
+
+	nodes := make([]*node.State, 3)
+	for i := range nodes {
+		nodes[i] = setupNode(t, testState, uint64(i))
+		testPool.Add(nodes[i])
+	}
+
+	picked := testPool.PickMatching(func(ns *node.State) bool {
+		return ns == nodes[1]
+	})
+	if picked != nodes[1] {
+		t.Errorf("PickMatching() picked the wrong node.")
+	}
+	if testPool.Len() != 2 || testPool.pool.Has(nodes[1]) {
+		t.Errorf("Picked node not removed from the pool.")
+	}
+
+	picked = testPool.PickMatching(func(ns *node.State) bool { return false })
+	if picked != nil || testPool.Len() != 2 {
+		t.Errorf("PickMatching() picked a node when none matched.")
+	}
+}
+
+// Tests that nodes which reported a critical health error are held out of the
+// pool, whether added after reporting it or swept once they do, and return to
+// it once they report healthy while still waiting.
+func TestWaitingPool_SweepUnhealthy(t *testing.T) {
+	testPool := NewWaitingPool()
+	testState := setupNodeMap(t)
+
+	nodes := make([]*node.State, 3)
+	for i := range nodes {
+		nodes[i] = setupNode(t, testState, uint64(i))
+		if _, _, err := nodes[i].Update(current.WAITING); err != nil {
+			t.Fatalf("Failed to update node activity: %+v", err)
+		}
+	}
+
+	nodes[0].SetHealthCritical(true)
+	testPool.Add(nodes[0])
+	testPool.Add(nodes[1])
+	testPool.Add(nodes[2])
+	if testPool.pool.Has(nodes[0]) || !testPool.unhealthy.Has(nodes[0]) {
+		t.Errorf("Critical node was added to the pool.")
+	}
+
+	nodes[1].SetHealthCritical(true)
+	testPool.SweepUnhealthy()
+	if testPool.Len() != 1 || !testPool.pool.Has(nodes[2]) {
+		t.Errorf("Critical node was not swept from the pool.")
+	}
+
+	// A node which recovered returns to the pool, while one which stopped
+	// waiting is dropped until it is added again
+	nodes[0].SetHealthCritical(false)
+	nodes[1].SetHealthCritical(false)
+	if _, _, err := nodes[1].Update(current.ERROR); err != nil {
+		t.Fatalf("Failed to update node activity: %+v", err)
+	}
+	testPool.SweepUnhealthy()
+	if testPool.Len() != 2 || !testPool.pool.Has(nodes[0]) {
+		t.Errorf("Recovered node did not return to the pool.")
+	}
+	if testPool.pool.Has(nodes[1]) || testPool.unhealthy.Len() != 0 {
+		t.Errorf("Node which stopped waiting was returned to the pool.")
+	}
+}
+
+// Tests that SweepLowUptime holds aside the nodes below the minimum uptime,
+// including those added after it is set, and returns them to the pool once
+// their uptime reaches it or the minimum is removed.
+func TestWaitingPool_SweepLowUptime(t *testing.T) {
+	testPool := NewWaitingPool()
+	testState := setupNodeMap(t)
+
+	nodes := make([]*node.State, 4)
+	for i := range nodes {
+		nodes[i] = setupNode(t, testState, uint64(i))
+		if _, _, err := nodes[i].Update(current.WAITING); err != nil {
+			t.Fatalf("Failed to update node activity: %+v", err)
+		}
+		nodes[i].SetUptime(float64(i) / 4)
+	}
+	testPool.Add(nodes[0])
+	testPool.Add(nodes[1])
+	testPool.Add(nodes[2])
+
+	testPool.SweepLowUptime(0.5)
+	if testPool.Len() != 1 || !testPool.pool.Has(nodes[2]) {
+		t.Errorf("Nodes below the minimum uptime were not swept from the pool.")
+	}
+	testPool.Add(nodes[3])
+	if testPool.Len() != 2 || testPool.lowUptime.Len() != 2 {
+		t.Errorf("Node above the minimum uptime was not added to the pool.")
+	}
+	testPool.Add(nodes[0])
+	if testPool.pool.Has(nodes[0]) {
+		t.Errorf("Node below the minimum uptime was added to the pool.")
+	}
+
+	// A node which reached the minimum returns to the pool, while one which
+	// stopped waiting is dropped until it is added again
+	nodes[1].SetUptime(0.5)
+	if _, _, err := nodes[0].Update(current.ERROR); err != nil {
+		t.Fatalf("Failed to update node activity: %+v", err)
+	}
+	testPool.SweepLowUptime(0.5)
+	if testPool.Len() != 3 || !testPool.pool.Has(nodes[1]) {
+		t.Errorf("Node which reached the minimum uptime did not return to " +
+			"the pool.")
+	}
+	if testPool.pool.Has(nodes[0]) || testPool.lowUptime.Len() != 0 {
+		t.Errorf("Node which stopped waiting was returned to the pool.")
+	}
+
+	// Raising the minimum holds every node aside, and removing it returns them
+	testPool.SweepLowUptime(0.9)
+	if testPool.Len() != 0 || testPool.lowUptime.Len() != 3 {
+		t.Errorf("Nodes below the raised minimum uptime were not swept.")
+	}
+	testPool.SweepLowUptime(0)
+	if testPool.Len() != 3 || testPool.lowUptime.Len() != 0 {
+		t.Errorf("Held nodes did not return once the minimum was removed.")
+	}
+}
+
+// Tests that PickNByCapacityAtThreshold picks the nodes reporting the highest
+// capacity and removes them from the pool.
+func TestWaitingPool_PickNByCapacityAtThreshold(t *testing.T) {
+	testPool := NewWaitingPool()
+	testState := setupNodeMap(t)
+
+	nodes := make([]*node.State, 10)
+	for i := range nodes {
+		nodes[i] = setupNode(t, testState, uint64(i))
+		nodes[i].SetCapacity(uint32(i) * 100)
+		testPool.Add(nodes[i])
+	}
+
+	picked, err := testPool.PickNByCapacityAtThreshold(5, 3)
+	if err != nil {
+		t.Fatalf("PickNByCapacityAtThreshold() returned an error: %+v", err)
+	}
+
+	expected := map[*node.State]bool{nodes[9]: true, nodes[8]: true, nodes[7]: true}
+	for _, ns := range picked {
+		if !expected[ns] {
+			t.Errorf("Picked node with capacity %d which is not among the "+
+				"highest.", ns.GetCapacity())
+		}
+		if testPool.pool.Has(ns) {
+			t.Errorf("Picked node not removed from the pool.")
+		}
+	}
+	if len(picked) != 3 || testPool.Len() != 7 {
+		t.Errorf("Unexpected number of nodes picked (%d) or left in the "+
+			"pool (%d).", len(picked), testPool.Len())
+	}
+
+	_, err = testPool.PickNByCapacityAtThreshold(8, 3)
+	if err == nil {
+		t.Errorf("PickNByCapacityAtThreshold() did not error when the pool " +
+			"is below the threshold.")
+	}
+}
+
+// Tests that SweepRemoved() drops nodes removed from the node map from every
+// set, keeping the rest.
+func TestWaitingPool_SweepRemoved(t *testing.T) {
+	testPool := NewWaitingPool()
+	testState := setupNodeMap(t)
+
+	nodes := make([]*node.State, 3)
+	for i := range nodes {
+		nodes[i] = setupNode(t, testState, uint64(i))
+	}
+	nodes[1].SetHealthCritical(true)
+	testPool.Add(nodes[0])
+	testPool.Add(nodes[1])
+	testPool.offline.Insert(nodes[2])
+
+	for _, n := range nodes[:2] {
+		if err := testState.GetNodeMap().RemoveNode(n.GetID()); err != nil {
+			t.Fatalf("Failed to remove node: %+v", err)
+		}
+	}
+	testPool.SweepRemoved()
+	if testPool.Len() != 0 || testPool.unhealthy.Len() != 0 {
+		t.Errorf("Removed nodes remain in the pool.")
+	}
+	if !testPool.offline.Has(nodes[2]) {
+		t.Errorf("Node which was not removed was dropped.")
+	}
+}
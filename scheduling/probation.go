@@ -0,0 +1,107 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package scheduling
+
+import (
+	"github.com/pkg/errors"
+	jww "github.com/spf13/jwalterweatherman"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/elixxir/registration/storage/node"
+	"gitlab.com/xx_network/primitives/id"
+	"time"
+)
+
+// probation.go contains the logic to keep more than one newly registered Node
+// on probation out of the same team, and to graduate Nodes from probation
+
+// errProbationConflict is returned when no team with at most one Node on
+// probation can be formed
+var errProbationConflict = errors.New("unable to form a team with at most " +
+	"one node on probation")
+
+// probationEnabled returns true if newly registered Nodes are put on
+// probation.
+func (p Params) probationEnabled() bool {
+	return p.ProbationRounds > 0 || p.ProbationDuration > 0
+}
+
+// resolveProbationConflicts replaces every member of the team on probation but
+// the first with Nodes from the pool which are not on probation and do not
+// conflict with the avoid-lists of the team. Replaced nodes are returned to
+// the pool and counted as excluded from the round. Returns the new team and
+// whether it still has more than one Node on probation.
+func resolveProbationConflicts(team []*node.State, pool *waitingPool,
+	state *storage.NetworkState, roundID id.Round) ([]*node.State, bool) {
+
+	onProbation := 0
+	for i := range team {
+		if !team[i].IsOnProbation() {
+			continue
+		}
+		onProbation++
+		if onProbation == 1 {
+			continue
+		}
+
+		replacement := pool.PickMatching(func(candidate *node.State) bool {
+			return !candidate.IsOnProbation() &&
+				!conflictsWithTeam(team, i, candidate, state)
+		})
+		if replacement == nil {
+			continue
+		}
+
+		jww.DEBUG.Printf("Replacing node %s with %s in team as it already "+
+			"has a node on probation", team[i].GetID(), replacement.GetID())
+		state.RecordExclusion(team[i], node.ExcludedProbation, roundID)
+		pool.Add(team[i])
+		team[i] = replacement
+		onProbation--
+	}
+
+	return team, onProbation > 1
+}
+
+// recordProbationRound records a round completed by the Node, graduating it
+// from probation once it has completed enough rounds over the probation
+// duration.
+func (sc *stateChanger) recordProbationRound(n *node.State) {
+	if !n.IsOnProbation() {
+		return
+	}
+
+	if n.CompleteProbationRound(time.Now(), sc.probationRounds,
+		sc.probationDuration) {
+		_, rounds, since := n.GetProbation()
+		jww.INFO.Printf("Node %s graduated from probation after %d rounds "+
+			"since %s", n.GetID(), rounds, since)
+	}
+	storeProbation(n)
+}
+
+// extendProbation restarts the probation of the Node after it failed a round,
+// instead of the failure counting against it.
+func (sc *stateChanger) extendProbation(n *node.State) {
+	if n.ExtendProbation() {
+		jww.INFO.Printf("Node %s failed a round on probation, restarting its "+
+			"probation", n.GetID())
+		storeProbation(n)
+	}
+}
+
+// storeProbation persists the probation of the Node. Failures are logged, as
+// the probation is kept in memory regardless.
+func storeProbation(n *node.State) {
+	onProbation, rounds, since := n.GetProbation()
+	err := storage.PermissioningDb.UpdateNodeProbation(n.GetID(), onProbation,
+		rounds, since)
+	if err != nil {
+		jww.WARN.Printf("Failed to store probation of node %s: %+v",
+			n.GetID(), err)
+	}
+}
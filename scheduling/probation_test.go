@@ -0,0 +1,124 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package scheduling
+
+import (
+	"crypto/rand"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/xx_network/primitives/id"
+	"testing"
+	"time"
+)
+
+// Returns the number of members of the round on probation
+func countOnProbation(r protoRound) int {
+	count := 0
+	for _, n := range r.NodeStateList {
+		if n.IsOnProbation() {
+			count++
+		}
+	}
+	return count
+}
+
+// Tests that secure teaming never places more than one node on probation in a
+// team, and skips the round when it cannot be avoided.
+func TestCreateSecureRound_Probation(t *testing.T) {
+	testState, pool := setupAvoidListTest(6, t)
+	for i := uint64(0); i < 3; i++ {
+		testState.GetNodeMap().GetNode(id.NewIdFromUInt(i, id.Node, t)).
+			SetProbation(true, 0, time.Time{})
+	}
+
+	testParams := Params{
+		TeamSize:        3,
+		BatchSize:       32,
+		ProbationRounds: 1,
+	}
+
+	for i := 0; i < 20; i++ {
+		newRound, err := createSecureRound(testParams, pool, 0, id.Round(i),
+			testState, rand.Reader)
+		if err != nil {
+			t.Fatalf("Failed to create round %d: %+v", i, err)
+		}
+		if count := countOnProbation(newRound); count > 1 {
+			t.Fatalf("Round %d has %d nodes on probation.", i, count)
+		}
+		for _, n := range newRound.NodeStateList {
+			pool.Add(n)
+		}
+	}
+
+	// With every node on probation no team can be formed
+	for _, n := range testState.GetNodeMap().GetNodeStates() {
+		n.SetProbation(true, 0, time.Time{})
+	}
+	_, err := createSecureRound(testParams, pool, 0, 20, testState, rand.Reader)
+	if err != errProbationConflict {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if pool.Len() != 6 {
+		t.Errorf("Nodes were not returned to the pool: %d", pool.Len())
+	}
+
+	// Probation is not enforced when disabled
+	testParams.ProbationRounds = 0
+	if _, err = createSecureRound(testParams, pool, 0, 21, testState,
+		rand.Reader); err != nil {
+		t.Errorf("Failed to create round with probation disabled: %+v", err)
+	}
+}
+
+// Tests that a node on probation graduates after completing the probation
+// rounds, that a failure restarts its probation, and that both are stored.
+func TestStateChanger_Probation(t *testing.T) {
+	testState, pool := setupAvoidListTest(1, t)
+	nid := id.NewIdFromUInt(0, id.Node, t)
+	err := storage.PermissioningDb.InsertApplication(
+		&storage.Application{Id: 7}, &storage.Node{Code: "AAAA",
+			Id: nid.Marshal(), ApplicationId: 7})
+	if err != nil {
+		t.Fatalf("Failed to insert node: %+v", err)
+	}
+	n := testState.GetNodeMap().GetNode(nid)
+	n.SetProbation(true, 0, time.Time{})
+	sc := &stateChanger{probationRounds: 2, pool: pool, state: testState}
+
+	sc.recordProbationRound(n)
+	if _, rounds, _ := n.GetProbation(); rounds != 1 || !n.IsOnProbation() {
+		t.Fatalf("Unexpected probation after a round: %d, %t", rounds,
+			n.IsOnProbation())
+	}
+
+	// A failure restarts the probation
+	sc.extendProbation(n)
+	sc.recordProbationRound(n)
+	if !n.IsOnProbation() {
+		t.Fatalf("Node graduated despite failing a round on probation.")
+	}
+	nDb, err := storage.PermissioningDb.GetNode("AAAA")
+	if err != nil {
+		t.Fatalf("Failed to get node: %+v", err)
+	}
+	if !nDb.OnProbation || nDb.ProbationRounds != 1 {
+		t.Errorf("Probation not stored: %t, %d", nDb.OnProbation,
+			nDb.ProbationRounds)
+	}
+
+	sc.recordProbationRound(n)
+	if n.IsOnProbation() {
+		t.Errorf("Node did not graduate.")
+	}
+	if nDb, err = storage.PermissioningDb.GetNode("AAAA"); err != nil {
+		t.Fatalf("Failed to get node: %+v", err)
+	}
+	if nDb.OnProbation {
+		t.Errorf("Graduation not stored.")
+	}
+}
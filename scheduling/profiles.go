@@ -0,0 +1,153 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package scheduling
+
+import (
+	"github.com/pkg/errors"
+	jww "github.com/spf13/jwalterweatherman"
+	"time"
+)
+
+// profiles.go contains the logic to swap the scheduling params for the
+// profile whose daily window contains the current time
+
+// Number of minutes in a day, the granularity of profile windows
+const minutesPerDay = 24 * 60
+
+// Profile is a named set of scheduling params which are active between Start
+// and End every day. Both are UTC times in the form "15:04". A window whose
+// End is before its Start wraps past midnight.
+type Profile struct {
+	Name   string
+	Start  string
+	End    string
+	Params Params
+}
+
+// parseWindow returns the start and end of the profile's window in minutes
+// after midnight UTC.
+func (p Profile) parseWindow() (int, int, error) {
+	start, err := time.Parse("15:04", p.Start)
+	if err != nil {
+		return 0, 0, errors.Errorf("invalid start %q for profile %s: %v",
+			p.Start, p.Name, err)
+	}
+	end, err := time.Parse("15:04", p.End)
+	if err != nil {
+		return 0, 0, errors.Errorf("invalid end %q for profile %s: %v",
+			p.End, p.Name, err)
+	}
+	return start.Hour()*60 + start.Minute(), end.Hour()*60 + end.Minute(), nil
+}
+
+// contains returns true if the given minute of the day is in the window.
+func (p Profile) contains(minute int) bool {
+	start, end, err := p.parseWindow()
+	if err != nil {
+		return false
+	}
+	if start <= end {
+		return minute >= start && minute < end
+	}
+	return minute >= start || minute < end
+}
+
+// validateProfiles returns an error if the profiles are not uniquely named or
+// their windows overlap or leave part of the day uncovered. No profiles is
+// valid.
+func validateProfiles(profiles []Profile) error {
+	if len(profiles) == 0 {
+		return nil
+	}
+
+	names := make(map[string]bool, len(profiles))
+	for _, p := range profiles {
+		if p.Name == "" {
+			return errors.New("scheduling profiles must be named")
+		}
+		if names[p.Name] {
+			return errors.Errorf("duplicate scheduling profile %s", p.Name)
+		}
+		names[p.Name] = true
+
+		if len(p.Params.Profiles) != 0 {
+			return errors.Errorf("scheduling profile %s cannot contain "+
+				"profiles", p.Name)
+		}
+
+		start, end, err := p.parseWindow()
+		if err != nil {
+			return err
+		}
+		if start == end {
+			return errors.Errorf("scheduling profile %s has an empty "+
+				"window", p.Name)
+		}
+	}
+
+	// Find which profile covers each minute of the day
+	covered := make([]string, minutesPerDay)
+	for minute := range covered {
+		for _, p := range profiles {
+			if !p.contains(minute) {
+				continue
+			}
+			if covered[minute] != "" {
+				return errors.Errorf("scheduling profiles %s and %s overlap "+
+					"at %s", covered[minute], p.Name, formatMinute(minute))
+			}
+			covered[minute] = p.Name
+		}
+		if covered[minute] == "" {
+			return errors.Errorf("no scheduling profile covers %s",
+				formatMinute(minute))
+		}
+	}
+
+	return nil
+}
+
+// formatMinute returns the minute of the day in the profile window format.
+func formatMinute(minute int) string {
+	return time.Date(0, 1, 1, 0, minute, 0, 0, time.UTC).Format("15:04")
+}
+
+// switchProfile replaces the Params with those of the profile whose window
+// contains the given time, if it is not already active. Returns the name of
+// the active profile and true if it was switched to. Rounds already formed
+// hold their own copy of the params and are not affected.
+func (s *SafeParams) switchProfile(now time.Time) (string, bool) {
+	s.Lock()
+	defer s.Unlock()
+
+	if len(s.Profiles) == 0 {
+		return "", false
+	}
+
+	now = now.UTC()
+	minute := now.Hour()*60 + now.Minute()
+	for _, p := range s.Profiles {
+		if !p.contains(minute) {
+			continue
+		}
+		if p.Name == s.activeProfile {
+			return p.Name, false
+		}
+
+		jww.INFO.Printf("Switching from scheduling profile %q to %q at %s",
+			s.activeProfile, p.Name, now.Format("15:04"))
+		profiles := s.Profiles
+		*s.Params = p.Params
+		s.Profiles = profiles
+		s.activeProfile = p.Name
+		s.version++
+		return p.Name, true
+	}
+
+	return s.activeProfile, false
+}
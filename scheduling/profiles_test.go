@@ -0,0 +1,254 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package scheduling
+
+import (
+	"crypto/rand"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/xx_network/crypto/signature/rsa"
+	"gitlab.com/xx_network/primitives/id"
+	"gitlab.com/xx_network/primitives/region"
+	mathRand "math/rand"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Builds a peak profile during the day and an off-peak profile overnight
+func buildTestProfiles() []Profile {
+	return []Profile{
+		{
+			Name:  "peak",
+			Start: "08:00",
+			End:   "20:00",
+			Params: Params{
+				TeamSize:              3,
+				BatchSize:             32,
+				Threshold:             0.3,
+				PrecomputationTimeout: 10000,
+				RealtimeTimeout:       5000,
+				RealtimeDelay:         1000,
+			},
+		},
+		{
+			Name:  "offPeak",
+			Start: "20:00",
+			End:   "08:00",
+			Params: Params{
+				TeamSize:              3,
+				BatchSize:             1024,
+				Threshold:             0.3,
+				PrecomputationTimeout: 60000,
+				RealtimeTimeout:       30000,
+				RealtimeDelay:         3000,
+			},
+		},
+	}
+}
+
+// Returns the given UTC time of day on an arbitrary date
+func testTimeOfDay(hour, minute int) time.Time {
+	return time.Date(2022, 3, 14, hour, minute, 0, 0, time.UTC)
+}
+
+// Happy path
+func TestValidateProfiles(t *testing.T) {
+	if err := validateProfiles(nil); err != nil {
+		t.Errorf("No profiles should be valid: %v", err)
+	}
+	if err := validateProfiles(buildTestProfiles()); err != nil {
+		t.Errorf("Valid profiles failed validation: %v", err)
+	}
+}
+
+// Error path: tests that invalid profiles are rejected
+func TestValidateProfiles_Error(t *testing.T) {
+	testCases := map[string]func(p []Profile) []Profile{
+		"overlap": func(p []Profile) []Profile {
+			p[0].End = "20:30"
+			return p
+		},
+		"no scheduling profile covers": func(p []Profile) []Profile {
+			p[1].End = "07:00"
+			return p
+		},
+		"empty window": func(p []Profile) []Profile {
+			p[0].End = p[0].Start
+			return p
+		},
+		"invalid start": func(p []Profile) []Profile {
+			p[0].Start = "8am"
+			return p
+		},
+		"duplicate": func(p []Profile) []Profile {
+			p[1].Name = p[0].Name
+			return p
+		},
+		"must be named": func(p []Profile) []Profile {
+			p[0].Name = ""
+			return p
+		},
+		"cannot contain profiles": func(p []Profile) []Profile {
+			p[0].Params.Profiles = buildTestProfiles()
+			return p
+		},
+	}
+
+	for expected, modify := range testCases {
+		err := validateProfiles(modify(buildTestProfiles()))
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("Unexpected error for invalid profiles."+
+				"\nexpected: %s\nreceived: %v", expected, err)
+		}
+	}
+}
+
+// Tests that defaults are applied to the params of each profile
+func TestParseParams_Profiles(t *testing.T) {
+	params := ParseParams([]byte(`{"TeamSize": 3, "BatchSize": 64,
+		"Profiles": [
+			{"Name": "day", "Start": "06:00", "End": "18:00",
+				"Params": {"TeamSize": 3, "BatchSize": 32}},
+			{"Name": "night", "Start": "18:00", "End": "06:00",
+				"Params": {"TeamSize": 5, "BatchSize": 512}}]}`))
+
+	if len(params.Profiles) != 2 {
+		t.Fatalf("Unexpected number of profiles: %d", len(params.Profiles))
+	}
+	for _, p := range params.Profiles {
+		if p.Params.ResourceQueueTimeout != 180000 ||
+			p.Params.PrecomputationTimeout != 60000 ||
+			p.Params.RealtimeTimeout != 15000 {
+			t.Errorf("Defaults not applied to profile %s: %+v", p.Name, p.Params)
+		}
+	}
+}
+
+// Tests that the profile is switched when crossing window boundaries
+func TestSafeParams_switchProfile(t *testing.T) {
+	profiles := buildTestProfiles()
+	params := &SafeParams{Params: &Params{TeamSize: 5, Profiles: profiles}}
+
+	testCases := []struct {
+		now      time.Time
+		profile  string
+		switched bool
+	}{
+		{testTimeOfDay(7, 59), "offPeak", true},
+		{testTimeOfDay(3, 0), "offPeak", false},
+		{testTimeOfDay(8, 0), "peak", true},
+		{testTimeOfDay(19, 59), "peak", false},
+		{testTimeOfDay(20, 0), "offPeak", true},
+		{testTimeOfDay(23, 59), "offPeak", false},
+		{testTimeOfDay(0, 0), "offPeak", false},
+	}
+
+	for i, tc := range testCases {
+		name, switched := params.switchProfile(tc.now)
+		if name != tc.profile || switched != tc.switched {
+			t.Errorf("Unexpected switch at %s (%d)."+
+				"\nexpected: %s, %t\nreceived: %s, %t", tc.now, i,
+				tc.profile, tc.switched, name, switched)
+		}
+
+		paramsCopy := params.SafeCopy()
+		for _, p := range profiles {
+			if p.Name == tc.profile && paramsCopy.BatchSize != p.Params.BatchSize {
+				t.Errorf("Params not switched to profile %s at %s (%d)",
+					p.Name, tc.now, i)
+			}
+		}
+		if len(paramsCopy.Profiles) != len(profiles) {
+			t.Errorf("Profiles lost on switch at %s (%d)", tc.now, i)
+		}
+	}
+}
+
+// Tests that params without profiles are never switched
+func TestSafeParams_switchProfile_NoProfiles(t *testing.T) {
+	params := &SafeParams{Params: &Params{BatchSize: 64}}
+	if _, switched := params.switchProfile(testTimeOfDay(8, 0)); switched {
+		t.Errorf("Switched params without profiles.")
+	}
+	if params.BatchSize != 64 {
+		t.Errorf("Params changed without profiles.")
+	}
+}
+
+// Tests that a round formed before a profile switch keeps the params it was
+// formed with while rounds formed after use the new profile
+func TestSafeParams_switchProfile_InFlightRound(t *testing.T) {
+	profiles := buildTestProfiles()
+	params := &SafeParams{Params: &Params{Profiles: profiles}}
+	params.switchProfile(testTimeOfDay(19, 59))
+
+	var err error
+	storage.PermissioningDb, _, err = storage.NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+
+	privKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	testState, err := storage.NewState(privKey, 8, "", "", region.GetCountryBins())
+	if err != nil {
+		t.Fatalf("Failed to create test state: %v", err)
+	}
+
+	testPool := NewWaitingPool()
+	for i := uint64(0); i < 6; i++ {
+		nid := id.NewIdFromUInt(i, id.Node, t)
+		err = testState.GetNodeMap().AddNode(nid, "US", "", "", 0)
+		if err != nil {
+			t.Fatalf("Couldn't add node: %v", err)
+		}
+		testPool.Add(testState.GetNodeMap().GetNode(nid))
+	}
+
+	roundTracker := NewRoundTracker()
+	prng := mathRand.New(mathRand.NewSource(42))
+	formRound := func(roundID id.Round, p Params) protoRound {
+		newRound, err := createSecureRound(p, testPool, 0, roundID, testState, prng)
+		if err != nil {
+			t.Fatalf("Failed to create round %d: %v", roundID, err)
+		}
+		return newRound
+	}
+
+	peak := formRound(1, params.SafeCopy())
+	r, err := startRound(peak, testState, roundTracker)
+	if err != nil {
+		t.Fatalf("Failed to start round: %v", err)
+	}
+
+	// Cross into the off-peak window while the round is in flight
+	if name, switched := params.switchProfile(testTimeOfDay(20, 0)); !switched ||
+		name != "offPeak" {
+		t.Fatalf("Failed to switch to the off-peak profile: %s, %t",
+			name, switched)
+	}
+	offPeak := formRound(2, params.SafeCopy())
+
+	peakParams := profiles[0].Params
+	if r.BuildRoundInfo().BatchSize != peakParams.BatchSize ||
+		peak.PrecomputationTimeout != peakParams.PrecomputationTimeout*time.Millisecond {
+		t.Errorf("In-flight round changed by profile switch: %+v", peak)
+	}
+	delay, timeout := r.GetRealtimeParams()
+	if delay != peakParams.RealtimeDelay*time.Millisecond ||
+		timeout != peakParams.RealtimeTimeout*time.Millisecond {
+		t.Errorf("In-flight round realtime params changed by profile "+
+			"switch: %s, %s", delay, timeout)
+	}
+
+	offPeakParams := profiles[1].Params
+	if offPeak.BatchSize != offPeakParams.BatchSize ||
+		offPeak.RealtimeTimeout != offPeakParams.RealtimeTimeout*time.Millisecond {
+		t.Errorf("Round formed after the switch does not use the new "+
+			"profile: %+v", offPeak)
+	}
+}
@@ -0,0 +1,106 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Contains the circuit which pauses round creation while too few nodes can be
+// reached by permissioning
+
+package scheduling
+
+import (
+	jww "github.com/spf13/jwalterweatherman"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/elixxir/registration/storage/node"
+	"time"
+)
+
+// How often the scheduler wakes to check reachability while the circuit is
+// enabled, and how often a paused circuit is logged
+const reachabilityCheckInterval = 5 * time.Second
+const reachabilityWarnInterval = time.Minute
+
+// reachabilityCircuit tracks whether round creation is paused for too few
+// reachable nodes
+type reachabilityCircuit struct {
+	// When round creation was paused, zero while it is not paused
+	pausedSince time.Time
+	// When the operator was last warned about the pause
+	lastWarning time.Time
+}
+
+// countReachable returns the number of active nodes whose connectivity was
+// checked and the number of those found reachable on both the node and
+// gateway ports. Nodes whose connectivity is unknown or being checked are not
+// counted, so that nodes not yet checked after a restart do not pause round
+// creation.
+func countReachable(state *storage.NetworkState) (checked, reachable int) {
+	for _, n := range state.GetNodeMap().GetNodeStates() {
+		if n.GetStatus() != node.Active {
+			continue
+		}
+		switch n.GetRawConnectivity() {
+		case node.PortSuccessful:
+			reachable++
+			checked++
+		case node.NodePortFailed, node.GatewayPortFailed, node.PortFailed:
+			checked++
+		}
+	}
+	return checked, reachable
+}
+
+// allowRounds returns false while the fraction of checked nodes which are
+// reachable is below the ReachabilityThreshold, logging an error when round
+// creation is paused and every reachabilityWarnInterval after, until
+// reachability recovers. Always returns true when the threshold is zero.
+func (c *reachabilityCircuit) allowRounds(params Params,
+	state *storage.NetworkState, now time.Time) bool {
+	if params.ReachabilityThreshold <= 0 {
+		if !c.pausedSince.IsZero() {
+			c.pausedSince = time.Time{}
+			state.SetSchedulingPaused(false)
+		}
+		return true
+	}
+
+	checked, reachable := countReachable(state)
+	below := checked > 0 &&
+		float64(reachable) < params.ReachabilityThreshold*float64(checked)
+
+	if !below {
+		if !c.pausedSince.IsZero() {
+			jww.WARN.Printf("Reachability recovered with %d of %d nodes "+
+				"reachable, resuming round creation after %s", reachable,
+				checked, now.Sub(c.pausedSince))
+			c.pausedSince = time.Time{}
+			state.SetSchedulingPaused(false)
+		}
+		return true
+	}
+
+	if c.pausedSince.IsZero() {
+		c.pausedSince = now
+		c.lastWarning = time.Time{}
+		state.SetSchedulingPaused(true)
+	}
+	if now.Sub(c.lastWarning) >= reachabilityWarnInterval {
+		jww.ERROR.Printf("ROUND CREATION PAUSED: only %d of %d nodes are "+
+			"reachable, below the reachability threshold of %.2f, paused "+
+			"for %s", reachable, checked, params.ReachabilityThreshold,
+			now.Sub(c.pausedSince))
+		c.lastWarning = now
+	}
+	return false
+}
+
+// newReachabilityTicker returns a ticker which wakes the scheduler to check
+// reachability, or nil if the circuit is disabled.
+func newReachabilityTicker(params Params) *time.Ticker {
+	if params.ReachabilityThreshold <= 0 {
+		return nil
+	}
+	return time.NewTicker(reachabilityCheckInterval)
+}
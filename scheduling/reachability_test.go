@@ -0,0 +1,98 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package scheduling
+
+import (
+	"gitlab.com/elixxir/registration/storage/node"
+	"gitlab.com/xx_network/primitives/id"
+	"testing"
+	"time"
+)
+
+// Tests that round creation pauses once most nodes fail their connectivity
+// check, and resumes once reachability recovers.
+func TestReachabilityCircuit_allowRounds(t *testing.T) {
+	params := Params{ReachabilityThreshold: 0.6}
+	testState := newFairnessTestState(t)
+
+	var nodes []*node.State
+	for i := uint64(0); i < 10; i++ {
+		nid := id.NewIdFromUInt(i, id.Node, t)
+		err := testState.GetNodeMap().AddNode(nid, "US", "", "", 0)
+		if err != nil {
+			t.Fatalf("Couldn't add node: %v", err)
+		}
+		nodes = append(nodes, testState.GetNodeMap().GetNode(nid))
+	}
+
+	c := &reachabilityCircuit{}
+	start := time.Now()
+
+	// Nodes which were not checked yet do not pause round creation
+	if !c.allowRounds(params, testState, start) {
+		t.Errorf("Round creation paused before any node was checked.")
+	}
+
+	for _, n := range nodes {
+		n.SetConnectivity(node.PortSuccessful)
+	}
+	if !c.allowRounds(params, testState, start) {
+		t.Errorf("Round creation paused while every node is reachable.")
+	}
+
+	// A partition leaves most nodes unreachable
+	for _, n := range nodes[:6] {
+		n.SetConnectivity(node.PortFailed)
+	}
+	nodes[6].SetConnectivity(node.NodePortFailed)
+	for i := time.Duration(0); i < 3; i++ {
+		if c.allowRounds(params, testState, start.Add(i*time.Minute)) {
+			t.Errorf("Round creation not paused with 3 of 10 nodes reachable.")
+		}
+	}
+
+	// Waiting nodes are told their wait is unknown while paused
+	testState.RecordScheduledTeam(3, start)
+	if _, ok := testState.EstimateWait(nodes[7], start); ok {
+		t.Errorf("Waiting time estimated while round creation is paused.")
+	}
+
+	// Nodes being checked again are left out while they are
+	for _, n := range nodes[:4] {
+		n.SetConnectivity(node.PortVerifying)
+	}
+	if c.allowRounds(params, testState, start.Add(4*time.Minute)) {
+		t.Errorf("Round creation not paused with 3 of 6 checked nodes " +
+			"reachable.")
+	}
+
+	// Reachability recovers
+	for _, n := range nodes[:4] {
+		n.SetConnectivity(node.PortSuccessful)
+	}
+	if !c.allowRounds(params, testState, start.Add(5*time.Minute)) {
+		t.Errorf("Round creation not resumed with 7 of 10 nodes reachable.")
+	}
+	if !c.pausedSince.IsZero() {
+		t.Errorf("Circuit still paused after resuming.")
+	}
+	if _, ok := testState.EstimateWait(nodes[7], start); !ok {
+		t.Errorf("Waiting time not estimated after resuming.")
+	}
+
+	// Disabled circuit never pauses
+	for _, n := range nodes {
+		n.SetConnectivity(node.PortFailed)
+	}
+	if !c.allowRounds(Params{}, testState, start.Add(6*time.Minute)) {
+		t.Errorf("Round creation paused with the circuit disabled.")
+	}
+	if newReachabilityTicker(Params{}) != nil {
+		t.Errorf("Ticker created with the circuit disabled.")
+	}
+}
@@ -0,0 +1,135 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Contains the spacing of the realtime start times of rounds
+
+package scheduling
+
+import (
+	"gitlab.com/elixxir/primitives/states"
+	"gitlab.com/elixxir/registration/storage/round"
+	"gitlab.com/xx_network/primitives/id"
+	"sync"
+	"time"
+)
+
+// realtimeSpacing keeps the realtime starts of rounds at least the minimum
+// gap apart. Starts are spaced from rounds which actually reached realtime and
+// rounds still queued to, so that rounds which fail before starting realtime
+// do not push later rounds back.
+type realtimeSpacing struct {
+	// Realtime start of the last round which reached realtime
+	lastStarted time.Time
+
+	// Rounds queued for realtime which have not yet started it, and the
+	// times they are scheduled to start at
+	queued map[id.Round]queuedStart
+
+	// Gap between the last start scheduled and the baseline it was spaced
+	// from, zero if it was not pushed back by the baseline
+	effectiveGap time.Duration
+
+	mux sync.Mutex
+}
+
+// A round queued for realtime and its scheduled start
+type queuedStart struct {
+	round *round.State
+	start time.Time
+}
+
+// baseline returns the latest realtime start which later starts must be
+// spaced from. Queued rounds which are no longer queued are dropped, as they
+// have either failed or been recorded as started. When idleGap is set,
+// starts more than idleGap before now are ignored, so that spacing begins
+// afresh after the network was idle.
+func (rs *realtimeSpacing) baseline(now time.Time, idleGap time.Duration) time.Time {
+	latest := rs.lastStarted
+	for rid, q := range rs.queued {
+		if q.round.GetRoundState() != states.QUEUED {
+			delete(rs.queued, rid)
+			continue
+		}
+		if q.start.After(latest) {
+			latest = q.start
+		}
+	}
+
+	if idleGap > 0 && now.Sub(latest) > idleGap {
+		return time.Time{}
+	}
+	return latest
+}
+
+// schedule returns the realtime start for the round which is becoming queued
+// now, at least delay after now and delta after the baseline, and records it
+// as queued. When maxLead is set, the start is never pushed back more than
+// maxLead past the earliest the round could start.
+func (rs *realtimeSpacing) schedule(r *round.State, now time.Time, delay,
+	delta, maxLead, idleGap time.Duration) time.Time {
+	rs.mux.Lock()
+	defer rs.mux.Unlock()
+
+	earliest := now.Add(delay)
+	start := earliest
+	baseline := rs.baseline(now, idleGap)
+	if !baseline.IsZero() {
+		if nextMinimum := baseline.Add(delta); nextMinimum.After(start) {
+			start = nextMinimum
+		}
+	}
+	if maxLead > 0 && start.Sub(earliest) > maxLead {
+		start = earliest.Add(maxLead)
+	}
+
+	if start.After(earliest) {
+		rs.effectiveGap = start.Sub(baseline)
+	} else {
+		rs.effectiveGap = 0
+	}
+	rs.addQueued(r, start)
+	return start
+}
+
+// addQueued records the round as queued to start realtime at the given time
+func (rs *realtimeSpacing) addQueued(r *round.State, start time.Time) {
+	if rs.queued == nil {
+		rs.queued = make(map[id.Round]queuedStart)
+	}
+	rs.queued[r.GetRoundID()] = queuedStart{round: r, start: start}
+}
+
+// started records that the round reached realtime at the given time.
+func (rs *realtimeSpacing) started(rid id.Round, at time.Time) {
+	rs.mux.Lock()
+	defer rs.mux.Unlock()
+
+	delete(rs.queued, rid)
+	if at.After(rs.lastStarted) {
+		rs.lastStarted = at
+	}
+}
+
+// resume records a round resumed after a restart, which is either queued to
+// start realtime at the given time or has already started it.
+func (rs *realtimeSpacing) resume(r *round.State, start time.Time) {
+	if r.GetRoundState() == states.QUEUED {
+		rs.mux.Lock()
+		rs.addQueued(r, start)
+		rs.mux.Unlock()
+		return
+	}
+	rs.started(r.GetRoundID(), start)
+}
+
+// getEffectiveGap returns the gap the last realtime start was pushed back to
+// from the previous one, or zero if it was not pushed back.
+func (rs *realtimeSpacing) getEffectiveGap() time.Duration {
+	rs.mux.Lock()
+	defer rs.mux.Unlock()
+	return rs.effectiveGap
+}
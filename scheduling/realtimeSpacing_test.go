@@ -0,0 +1,146 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package scheduling
+
+import (
+	"gitlab.com/elixxir/primitives/states"
+	"gitlab.com/elixxir/registration/storage/round"
+	"gitlab.com/xx_network/primitives/id"
+	"testing"
+	"time"
+)
+
+// queueRound schedules the round's realtime start and moves it to QUEUED, as
+// the state changer does.
+func queueRound(rs *realtimeSpacing, r *round.State, now time.Time, delay,
+	delta, maxLead, idleGap time.Duration, t *testing.T) time.Time {
+	start := rs.schedule(r, now, delay, delta, maxLead, idleGap)
+	if err := r.Update(states.QUEUED, start); err != nil {
+		t.Fatalf("Failed to queue round %d: %+v", r.GetRoundID(), err)
+	}
+	return start
+}
+
+// Tests that starts are spaced by the minimum gap from rounds still queued and
+// from the actual start of rounds which reached realtime, and that the
+// effective gap is reported.
+func TestRealtimeSpacing_schedule(t *testing.T) {
+	rs := &realtimeSpacing{}
+	now := time.Unix(1000, 0)
+	delay, delta := time.Second, 5*time.Second
+
+	first := round.NewState_Testing(1, states.STANDBY, nil, t)
+	start := queueRound(rs, first, now, delay, delta, 0, 0, t)
+	if !start.Equal(now.Add(delay)) {
+		t.Errorf("Unexpected first start.\nexpected: %s\nreceived: %s",
+			now.Add(delay), start)
+	}
+	if rs.getEffectiveGap() != 0 {
+		t.Errorf("Unexpected effective gap: %s", rs.getEffectiveGap())
+	}
+
+	second := round.NewState_Testing(2, states.STANDBY, nil, t)
+	start = queueRound(rs, second, now, delay, delta, 0, 0, t)
+	if expected := now.Add(delay + delta); !start.Equal(expected) {
+		t.Errorf("Start was not spaced from the queued round."+
+			"\nexpected: %s\nreceived: %s", expected, start)
+	}
+	if rs.getEffectiveGap() != delta {
+		t.Errorf("Unexpected effective gap.\nexpected: %s\nreceived: %s",
+			delta, rs.getEffectiveGap())
+	}
+
+	// Both rounds started realtime later than scheduled, so the next is spaced
+	// from the actual start
+	actual := now.Add(10 * time.Second)
+	for _, r := range []*round.State{first, second} {
+		if err := r.Update(states.REALTIME, actual); err != nil {
+			t.Fatalf("Failed to update round: %+v", err)
+		}
+		rs.started(r.GetRoundID(), actual)
+	}
+	third := round.NewState_Testing(3, states.STANDBY, nil, t)
+	start = queueRound(rs, third, now.Add(11*time.Second), delay, delta, 0, 0, t)
+	if expected := actual.Add(delta); !start.Equal(expected) {
+		t.Errorf("Start was not spaced from the actual realtime start."+
+			"\nexpected: %s\nreceived: %s", expected, start)
+	}
+}
+
+// Tests that a round which fails after being queued, before starting realtime,
+// does not push back the start of the next round.
+func TestRealtimeSpacing_schedule_FailedRound(t *testing.T) {
+	rs := &realtimeSpacing{}
+	now := time.Unix(1000, 0)
+	delay, delta := time.Second, time.Minute
+
+	failed := round.NewState_Testing(1, states.STANDBY, nil, t)
+	queueRound(rs, failed, now, delay, delta, 0, 0, t)
+	if err := failed.Update(states.FAILED, now); err != nil {
+		t.Fatalf("Failed to fail round: %+v", err)
+	}
+
+	// A round which never made it to QUEUED is dropped as well
+	unqueued := round.NewState_Testing(2, states.STANDBY, nil, t)
+	rs.schedule(unqueued, now, delay, delta, 0, 0)
+
+	next := round.NewState_Testing(3, states.STANDBY, nil, t)
+	start := queueRound(rs, next, now.Add(time.Second), delay, delta, 0, 0, t)
+	if expected := now.Add(time.Second + delay); !start.Equal(expected) {
+		t.Errorf("Start was pushed back by a failed round."+
+			"\nexpected: %s\nreceived: %s", expected, start)
+	}
+	if _, exists := rs.queued[failed.GetRoundID()]; exists {
+		t.Errorf("Failed round is still tracked as queued.")
+	}
+}
+
+// Tests that spacing is reset once no round started realtime within the idle
+// gap.
+func TestRealtimeSpacing_schedule_IdleGap(t *testing.T) {
+	rs := &realtimeSpacing{}
+	now := time.Unix(1000, 0)
+	delay, delta, idleGap := time.Second, 10*time.Minute, time.Minute
+
+	rs.started(id.Round(1), now)
+	r := round.NewState_Testing(2, states.STANDBY, nil, t)
+	later := now.Add(2 * time.Minute)
+	start := queueRound(rs, r, later, delay, delta, 0, idleGap, t)
+	if expected := later.Add(delay); !start.Equal(expected) {
+		t.Errorf("Spacing was not reset after the idle gap."+
+			"\nexpected: %s\nreceived: %s", expected, start)
+	}
+
+	// Within the idle gap, spacing applies
+	rs = &realtimeSpacing{}
+	rs.started(id.Round(1), now)
+	r = round.NewState_Testing(2, states.STANDBY, nil, t)
+	soon := now.Add(30 * time.Second)
+	start = queueRound(rs, r, soon, delay, delta, 0, idleGap, t)
+	if expected := now.Add(delta); !start.Equal(expected) {
+		t.Errorf("Spacing was reset within the idle gap."+
+			"\nexpected: %s\nreceived: %s", expected, start)
+	}
+}
+
+// Tests that a start is never pushed back more than the maximum lead past the
+// earliest the round could start.
+func TestRealtimeSpacing_schedule_MaxLead(t *testing.T) {
+	rs := &realtimeSpacing{}
+	now := time.Unix(1000, 0)
+	delay, delta, maxLead := time.Second, 10*time.Second, 3*time.Second
+
+	for i := 1; i <= 5; i++ {
+		r := round.NewState_Testing(id.Round(i), states.STANDBY, nil, t)
+		start := queueRound(rs, r, now, delay, delta, maxLead, 0, t)
+		if lead := start.Sub(now.Add(delay)); lead > maxLead {
+			t.Errorf("Round %d was pushed back %s, past the cap of %s.",
+				i, lead, maxLead)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package scheduling
+
+// Contains the logic for resuming rounds which were in flight when
+// permissioning last stopped
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	jww "github.com/spf13/jwalterweatherman"
+	pb "gitlab.com/elixxir/comms/mixmessages"
+	"gitlab.com/elixxir/primitives/current"
+	"gitlab.com/elixxir/primitives/states"
+	"gitlab.com/elixxir/registration/storage/node"
+	"gitlab.com/elixxir/registration/storage/round"
+	"gitlab.com/xx_network/comms/signature"
+	"gitlab.com/xx_network/primitives/id"
+	"time"
+)
+
+// resumeRounds loads the rounds which were in flight when permissioning last
+// stopped and places them back into the round map, the round tracker, and the
+// node states of their teams, restarting their realtime timeouts. Only rounds
+// which were QUEUED or in REALTIME can be resumed; all other rounds, and rounds
+// whose team cannot be restored, are killed with a signed error.
+func (sc *stateChanger) resumeRounds() error {
+	roundInfos, err := sc.state.GetActiveRounds()
+	if err != nil {
+		return err
+	}
+
+	for _, ri := range roundInfos {
+		r, err := sc.state.GetRoundMap().LoadRound(ri)
+		if err != nil {
+			jww.ERROR.Printf("Failed to load in-flight round %d, it "+
+				"will not be resumed: %+v", ri.ID, err)
+			continue
+		}
+
+		reason := sc.resumeRound(r)
+		if reason == "" {
+			jww.INFO.Printf("Resumed round %d in state %s", r.GetRoundID(),
+				r.GetRoundState())
+			continue
+		}
+
+		jww.WARN.Printf("Killing in-flight round %d: %s", r.GetRoundID(), reason)
+		resumeError := &pb.RoundError{
+			Id:     uint64(r.GetRoundID()),
+			NodeId: id.Permissioning.Marshal(),
+			Error: fmt.Sprintf("Round %d killed after permissioning "+
+				"restart: %s", r.GetRoundID(), reason),
+		}
+		err = signature.SignRsa(resumeError, sc.state.GetPrivateKey())
+		if err != nil {
+			return errors.Errorf("Failed to sign error message for "+
+				"unresumable round %d: %+v", r.GetRoundID(), err)
+		}
+
+		r.DenoteRoundCompleted()
+		err = killRound(sc.state, r, resumeError, "", sc.roundTracker)
+		if err != nil {
+			return errors.WithMessagef(err, "Failed to kill unresumable "+
+				"round %d", r.GetRoundID())
+		}
+	}
+
+	return nil
+}
+
+// resumeRound attempts to place the round back into the node states and round
+// tracker. Returns the reason the round cannot be resumed, or an empty string
+// on success.
+func (sc *stateChanger) resumeRound(r *round.State) string {
+	// Determine the activity the team had in the round
+	var activity current.Activity
+	switch r.GetRoundState() {
+	case states.QUEUED:
+		activity = current.STANDBY
+	case states.REALTIME:
+		activity = current.REALTIME
+	default:
+		return fmt.Sprintf("round in state %s cannot be resumed",
+			r.GetRoundState())
+	}
+
+	// Ensure every member of the team can be placed back in the round
+	topology := r.GetTopology()
+	team := make([]*node.State, topology.Len())
+	for i := range team {
+		nid := topology.GetNodeAtIndex(i)
+		team[i] = sc.state.GetNodeMap().GetNode(nid)
+		if team[i] == nil {
+			return fmt.Sprintf("node %s is not registered", nid)
+		}
+		if hasRound, _ := team[i].GetCurrentRound(); hasRound {
+			return fmt.Sprintf("node %s is already in a round", nid)
+		}
+	}
+
+	for _, n := range team {
+		if err := n.ResumeRound(r, activity); err != nil {
+			// Only possible if the node was placed in another round
+			// concurrently; clear what was resumed so the kill is clean
+			for _, resumed := range team {
+				if _, cur := resumed.GetCurrentRound(); cur == r {
+					resumed.ClearRound()
+				}
+			}
+			return err.Error()
+		}
+	}
+
+	sc.roundTracker.AddActiveRound(r.GetRoundID())
+	go waitForRoundTimeout(sc.roundTimeoutChan, sc.state, r,
+		sc.realtimeTimeout, true)
+
+	// Keep realtime spacing relative to the resumed round's start
+	queuedTs := time.Unix(0, int64(r.BuildRoundInfo().Timestamps[states.QUEUED]))
+	sc.spacing.resume(r, queuedTs)
+
+	return ""
+}
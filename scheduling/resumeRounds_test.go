@@ -0,0 +1,191 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package scheduling
+
+import (
+	"crypto/rand"
+	"gitlab.com/elixxir/primitives/current"
+	"gitlab.com/elixxir/primitives/states"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/elixxir/registration/storage/round"
+	"gitlab.com/xx_network/crypto/signature/rsa"
+	"gitlab.com/xx_network/primitives/id"
+	"gitlab.com/xx_network/primitives/region"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// Creates a network state with the given nodes added to its node map
+func newResumeTestState(privKey *rsa.PrivateKey, nodes []*id.ID,
+	t *testing.T) *storage.NetworkState {
+	testState, err := storage.NewState(privKey, 8, "", "", region.GetCountryBins())
+	if err != nil {
+		t.Fatalf("Failed to create test state: %v", err)
+	}
+	for i, nid := range nodes {
+		err = testState.GetNodeMap().AddNode(nid, strconv.Itoa(i), "", "", 0)
+		if err != nil {
+			t.Fatalf("Couldn't add node: %v", err)
+		}
+	}
+	return testState
+}
+
+// Starts a round with the given team and moves it to the given state
+func startResumeTestRound(testState *storage.NetworkState, team []*id.ID,
+	roundID id.Round, target states.Round, t *testing.T) *round.State {
+	params := Params{TeamSize: uint32(len(team)), BatchSize: 32}
+	proto := createProtoRound(params, testState, team, roundID)
+	r, err := startRound(proto, testState, NewRoundTracker())
+	if err != nil {
+		t.Fatalf("Failed to start round: %+v", err)
+	}
+	for s := states.STANDBY; s <= target; s++ {
+		if err = r.Update(s, time.Now()); err != nil {
+			t.Fatalf("Failed to update round to %s: %+v", s, err)
+		}
+	}
+	if err = testState.AddRoundUpdate(r.BuildRoundInfo()); err != nil {
+		t.Fatalf("Failed to add round update: %+v", err)
+	}
+	return r
+}
+
+// Tests that a round which was QUEUED when permissioning restarted is resumed
+// and completes when its nodes report REALTIME and COMPLETED, while a round
+// which was still precomputing is killed.
+func TestStateChanger_resumeRounds(t *testing.T) {
+	var err error
+	storage.PermissioningDb, _, err = storage.NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("Failed to create database: %+v", err)
+	}
+	privKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+
+	nodes := make([]*id.ID, 6)
+	for i := range nodes {
+		nodes[i] = id.NewIdFromUInt(uint64(i), id.Node, t)
+	}
+	queuedTeam, precompTeam := nodes[:3], nodes[3:]
+
+	// Run rounds up to the point of the restart
+	oldState := newResumeTestState(privKey, nodes, t)
+	startResumeTestRound(oldState, queuedTeam, 10, states.QUEUED, t)
+	startResumeTestRound(oldState, precompTeam, 11, states.PRECOMPUTING, t)
+
+	// Restart with a fresh state
+	newState := newResumeTestState(privKey, nodes, t)
+	sc := &stateChanger{
+		realtimeTimeout:  time.Minute,
+		pool:             NewWaitingPool(),
+		state:            newState,
+		roundTracker:     NewRoundTracker(),
+		roundTimeoutChan: make(chan id.Round, 10),
+	}
+
+	if err = sc.resumeRounds(); err != nil {
+		t.Fatalf("resumeRounds() returned an error: %+v", err)
+	}
+
+	// The precomputing round must be killed and cleared
+	if _, exists := newState.GetRoundMap().GetRound(11); exists {
+		t.Errorf("Unresumable round was not removed from the round map.")
+	}
+	for _, nid := range precompTeam {
+		if hasRound, _ := newState.GetNodeMap().GetNode(nid).GetCurrentRound(); hasRound {
+			t.Errorf("Node %s of the killed round still has a round.", nid)
+		}
+	}
+
+	// The queued round must be restored
+	r, exists := newState.GetRoundMap().GetRound(10)
+	if !exists {
+		t.Fatalf("Queued round was not resumed.")
+	}
+	if r.GetRoundState() != states.QUEUED {
+		t.Errorf("Resumed round in wrong state: %s", r.GetRoundState())
+	}
+	if sc.roundTracker.Len() != 1 {
+		t.Errorf("Resumed round not added to the round tracker.")
+	}
+
+	// Nodes report REALTIME then COMPLETED after the restart
+	for _, activity := range []current.Activity{current.REALTIME, current.COMPLETED} {
+		for _, nid := range queuedTeam {
+			n := newState.GetNodeMap().GetNode(nid)
+			n.GetPollingLock().Lock()
+			isUpdate, nun, err := n.Update(activity)
+			if err != nil || !isUpdate {
+				n.GetPollingLock().Unlock()
+				t.Fatalf("Node %s could not report %s after restart: %v",
+					nid, activity, err)
+			}
+			if err = sc.HandleNodeUpdates(nun); err != nil {
+				t.Fatalf("Failed to handle %s update: %+v", activity, err)
+			}
+		}
+	}
+
+	if r.GetRoundState() != states.COMPLETED {
+		t.Errorf("Resumed round did not complete: %s", r.GetRoundState())
+	}
+	if sc.roundTracker.Len() != 0 {
+		t.Errorf("Completed round still tracked as active.")
+	}
+
+	remaining, err := newState.GetActiveRounds()
+	if err != nil {
+		t.Fatalf("Failed to get active rounds: %+v", err)
+	}
+	if len(remaining) != 0 {
+		t.Errorf("Finished rounds still stored as active: %d", len(remaining))
+	}
+}
+
+// Tests that a round whose team member is no longer registered is killed
+// rather than resumed.
+func TestStateChanger_resumeRounds_MissingNode(t *testing.T) {
+	var err error
+	storage.PermissioningDb, _, err = storage.NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("Failed to create database: %+v", err)
+	}
+	privKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+
+	nodes := make([]*id.ID, 3)
+	for i := range nodes {
+		nodes[i] = id.NewIdFromUInt(uint64(i), id.Node, t)
+	}
+
+	oldState := newResumeTestState(privKey, nodes, t)
+	startResumeTestRound(oldState, nodes, 5, states.REALTIME, t)
+
+	// Restart without the last node
+	newState := newResumeTestState(privKey, nodes[:2], t)
+	sc := &stateChanger{
+		realtimeTimeout:  time.Minute,
+		pool:             NewWaitingPool(),
+		state:            newState,
+		roundTracker:     NewRoundTracker(),
+		roundTimeoutChan: make(chan id.Round, 10),
+	}
+
+	if err = sc.resumeRounds(); err != nil {
+		t.Fatalf("resumeRounds() returned an error: %+v", err)
+	}
+
+	if _, exists := newState.GetRoundMap().GetRound(5); exists {
+		t.Errorf("Round with a missing node should not be resumed.")
+	}
+	for _, nid := range nodes[:2] {
+		if hasRound, _ := newState.GetNodeMap().GetNode(nid).GetCurrentRound(); hasRound {
+			t.Errorf("Node %s was placed in an unresumable round.", nid)
+		}
+	}
+}
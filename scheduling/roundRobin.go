@@ -0,0 +1,137 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package scheduling
+
+import (
+	"bytes"
+	"github.com/pkg/errors"
+	jww "github.com/spf13/jwalterweatherman"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/elixxir/registration/storage/node"
+	"gitlab.com/xx_network/primitives/id"
+	"io"
+	"sort"
+	"sync"
+)
+
+// roundRobin.go contains the round-robin teaming algorithm, which cycles
+// through the nodes in registration order. It is meant only for development
+// and integration testing networks.
+
+// RoundRobinMode selects the round-robin teaming algorithm. It ignores
+// geography, ordering and the threshold, and so is UNSAFE FOR PRODUCTION.
+const RoundRobinMode = "roundrobin"
+
+// validateMode checks the teaming mode of the params, warning that the
+// round-robin mode is not fit for production.
+func validateMode(params Params) error {
+	switch params.Mode {
+	case "":
+	case RoundRobinMode:
+		jww.WARN.Printf("Scheduling in %s mode, which ignores geography, "+
+			"ordering and the threshold. It is UNSAFE FOR PRODUCTION and "+
+			"only meant for development and integration testing networks",
+			RoundRobinMode)
+	default:
+		return errors.Errorf("unknown scheduling mode %q", params.Mode)
+	}
+	return nil
+}
+
+// roundRobin tracks where the round-robin teaming algorithm is in its cycle
+type roundRobin struct {
+	// Last node placed in a team, nil before the first team
+	last *id.ID
+	mux  sync.Mutex
+}
+
+// createRound builds the team for a round from the first TeamSize nodes in
+// the pool following the last node teamed, in registration order. The team's
+// topology keeps that order.
+func (rr *roundRobin) createRound(params Params, pool *waitingPool, _ int,
+	roundID id.Round, state *storage.NetworkState, _ io.Reader) (protoRound, error) {
+	rr.mux.Lock()
+	defer rr.mux.Unlock()
+
+	ordered := registrationOrder(state)
+	if len(ordered) == 0 {
+		return protoRound{}, errors.New("Failed to pick round-robin team: " +
+			"no nodes are registered")
+	}
+	start := 0
+	if rr.last != nil {
+		for i, n := range ordered {
+			if n.GetID().Cmp(rr.last) {
+				start = i + 1
+				break
+			}
+		}
+	}
+	start %= len(ordered)
+	rotated := append(append([]*node.State{}, ordered[start:]...),
+		ordered[:start]...)
+
+	nodes, err := pool.PickNFromList(rotated, int(params.TeamSize))
+	if err != nil {
+		return protoRound{}, errors.Errorf("Failed to pick round-robin "+
+			"team: %v", err)
+	}
+	last := nodes[len(nodes)-1].GetID()
+
+	// Swap out nodes on each other's avoid-lists where alternatives exist.
+	// The cycle continues from the last node picked in order.
+	nodes, err = applyAvoidLists(params, nodes, pool, state, roundID, nil)
+	if err != nil {
+		return protoRound{}, err
+	}
+
+	team := make([]*id.ID, 0, len(nodes))
+	for _, n := range nodes {
+		team = append(team, n.GetID())
+	}
+	rr.last = last
+
+	jww.TRACE.Printf("Built round %d round-robin", roundID)
+	return createProtoRound(params, state, team, roundID), nil
+}
+
+// registrationOrder returns every node in the node map, in the order they are
+// listed in the NDF, which is the order they registered in. Nodes missing
+// from the NDF follow, ordered by ID.
+func registrationOrder(state *storage.NetworkState) []*node.State {
+	nodeMap := state.GetNodeMap()
+	listed := make(map[id.ID]bool)
+	var ordered []*node.State
+
+	state.InternalNdfLock.RLock()
+	if def := state.GetUnprunedNdf(); def != nil {
+		for _, ndfNode := range def.Nodes {
+			nid, err := id.Unmarshal(ndfNode.ID)
+			if err != nil {
+				continue
+			}
+			if n := nodeMap.GetNode(nid); n != nil && !listed[*nid] {
+				listed[*nid] = true
+				ordered = append(ordered, n)
+			}
+		}
+	}
+	state.InternalNdfLock.RUnlock()
+
+	var unlisted []*node.State
+	for _, n := range nodeMap.GetNodeStates() {
+		if !listed[*n.GetID()] {
+			unlisted = append(unlisted, n)
+		}
+	}
+	sort.Slice(unlisted, func(i, j int) bool {
+		return bytes.Compare(unlisted[i].GetID().Bytes(),
+			unlisted[j].GetID().Bytes()) < 0
+	})
+	return append(ordered, unlisted...)
+}
@@ -0,0 +1,119 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package scheduling
+
+import (
+	"crypto/rand"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/xx_network/crypto/signature/rsa"
+	"gitlab.com/xx_network/primitives/id"
+	"gitlab.com/xx_network/primitives/ndf"
+	"gitlab.com/xx_network/primitives/region"
+	"testing"
+)
+
+// Builds a network state of three nodes without ordering strings, registered
+// in the reverse order of their IDs, all in the pool
+func setupRoundRobinTest(t *testing.T) (*storage.NetworkState, *waitingPool,
+	[]*id.ID) {
+	var err error
+	storage.PermissioningDb, _, err = storage.NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("Failed to create database: %+v", err)
+	}
+	privKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	testState, err := storage.NewState(privKey, 8, "", "", region.GetCountryBins())
+	if err != nil {
+		t.Fatalf("Failed to create test state: %v", err)
+	}
+
+	pool := NewWaitingPool()
+	registered := []*id.ID{id.NewIdFromUInt(3, id.Node, t),
+		id.NewIdFromUInt(2, id.Node, t), id.NewIdFromUInt(1, id.Node, t)}
+	def := &ndf.NetworkDefinition{}
+	for _, nid := range registered {
+		if err = testState.GetNodeMap().AddNode(nid, "", "", "", 0); err != nil {
+			t.Fatalf("Couldn't add node: %v", err)
+		}
+		pool.Add(testState.GetNodeMap().GetNode(nid))
+		def.Nodes = append(def.Nodes, ndf.Node{ID: nid.Marshal()})
+	}
+	testState.UpdateInternalNdf(def)
+	return testState, pool, registered
+}
+
+// Tests that round-robin rounds cycle deterministically through the nodes in
+// registration order.
+func TestRoundRobin_createRound(t *testing.T) {
+	testState, pool, registered := setupRoundRobinTest(t)
+	testParams := Params{TeamSize: 2, BatchSize: 32, Mode: RoundRobinMode}
+	rr := &roundRobin{}
+	createRound := selectRoundCreator(testParams, rr)
+
+	expected := [][]*id.ID{
+		{registered[0], registered[1]},
+		{registered[2], registered[0]},
+		{registered[1], registered[2]},
+		{registered[0], registered[1]},
+	}
+	for i, team := range expected {
+		newRound, err := createRound(testParams, pool, 0, id.Round(i), testState, nil)
+		if err != nil {
+			t.Fatalf("Failed to create round %d: %+v", i, err)
+		}
+		if newRound.Topology.Len() != len(team) {
+			t.Fatalf("Round %d has a team of %d, expected %d.", i,
+				newRound.Topology.Len(), len(team))
+		}
+		for j, nid := range team {
+			if !newRound.Topology.GetNodeAtIndex(j).Cmp(nid) {
+				t.Errorf("Round %d has node %s at %d, expected %s.", i,
+					newRound.Topology.GetNodeAtIndex(j), j, nid)
+			}
+		}
+
+		// The team returns to the pool once its round ends
+		for _, n := range newRound.NodeStateList {
+			pool.Add(n)
+		}
+	}
+}
+
+// Tests that round-robin teaming skips nodes which are not in the pool and
+// fails once too few are.
+func TestRoundRobin_createRound_Unavailable(t *testing.T) {
+	testState, pool, registered := setupRoundRobinTest(t)
+	testParams := Params{TeamSize: 2, BatchSize: 32, Mode: RoundRobinMode}
+	rr := &roundRobin{}
+
+	pool.Ban(testState.GetNodeMap().GetNode(registered[1]))
+	newRound, err := rr.createRound(testParams, pool, 0, 1, testState, nil)
+	if err != nil {
+		t.Fatalf("Failed to create round: %+v", err)
+	}
+	if !newRound.Topology.GetNodeAtIndex(0).Cmp(registered[0]) ||
+		!newRound.Topology.GetNodeAtIndex(1).Cmp(registered[2]) {
+		t.Errorf("Unavailable node was not skipped.")
+	}
+
+	if _, err = rr.createRound(testParams, pool, 0, 2, testState, nil); err == nil {
+		t.Errorf("Round was created without enough nodes in the pool.")
+	}
+}
+
+// Tests that validateMode() accepts the known modes only.
+func TestValidateMode(t *testing.T) {
+	for _, mode := range []string{"", RoundRobinMode} {
+		if err := validateMode(Params{Mode: mode}); err != nil {
+			t.Errorf("Mode %q was rejected: %+v", mode, err)
+		}
+	}
+	if err := validateMode(Params{Mode: "unknown"}); err == nil {
+		t.Errorf("Unknown mode was accepted.")
+	}
+}
@@ -22,7 +22,6 @@ import (
 	"gitlab.com/xx_network/primitives/id"
 	"io"
 	"runtime"
-	"strconv"
 	"sync/atomic"
 	"time"
 )
@@ -49,6 +48,40 @@ func ParseParams(serialParam []byte) *SafeParams {
 		jww.FATAL.Panicf("Scheduling Algorithm exited: Could not extract parameters")
 	}
 
+	setDefaultParams(params.Params)
+	for i := range params.Profiles {
+		setDefaultParams(&params.Profiles[i].Params)
+	}
+
+	err = validateParams(*params.Params)
+	for i := 0; err == nil && i < len(params.Profiles); i++ {
+		err = validateParams(params.Profiles[i].Params)
+	}
+	if err != nil {
+		jww.FATAL.Panicf("Scheduling Algorithm exited: Invalid "+
+			"scheduling params: %v", err)
+	}
+
+	err = validateProfiles(params.Profiles)
+	if err != nil {
+		jww.FATAL.Panicf("Scheduling Algorithm exited: Invalid "+
+			"scheduling profiles: %v", err)
+	}
+
+	return params
+}
+
+// validateParams returns an error if the teaming mode or the demotion of
+// failing Nodes of the params is misconfigured.
+func validateParams(params Params) error {
+	if err := validateMode(params); err != nil {
+		return err
+	}
+	return validateDiagnostic(params)
+}
+
+// setDefaultParams sets the timeouts which have not been set to their defaults
+func setDefaultParams(params *Params) {
 	// If resource queue timeout isn't set, set it to a default of 3 minutes
 	if params.ResourceQueueTimeout == 0 {
 		params.ResourceQueueTimeout = 180000
@@ -60,76 +93,26 @@ func ParseParams(serialParam []byte) *SafeParams {
 	if params.RealtimeTimeout == 0 {
 		params.RealtimeTimeout = 15000
 	}
-
-	return params
 }
 
-// Runs an infinite loop that checks for updates to scheduling parameters
+// Runs an infinite loop that checks for updates to scheduling parameters in
+// the State table. The most recent change to a param takes precedence,
+// whether it was made in the table, by an admin setter or by a switch of
+// scheduling profile, so a param is only applied from the table when its
+// value there has changed since it was last read.
 func UpdateParams(params *SafeParams, updateFreq time.Duration) {
+	var last *stateParams
 	for {
-		newParams := make(map[string]uint64, 0)
-		teamSize, err := storage.PermissioningDb.GetStateInt(storage.TeamSize)
-		if err != nil {
-			jww.ERROR.Printf(err.Error())
-			continue
-		}
-		newParams[storage.TeamSize] = teamSize
-		batchSize, err := storage.PermissioningDb.GetStateInt(storage.BatchSize)
-		if err != nil {
-			jww.ERROR.Printf(err.Error())
-			continue
-		}
-		newParams[storage.BatchSize] = batchSize
-		precompTimeout, err := storage.PermissioningDb.GetStateInt(storage.PrecompTimeout)
+		current, err := loadStateParams()
 		if err != nil {
-			jww.ERROR.Printf(err.Error())
-			continue
+			jww.ERROR.Printf("%+v", err)
+		} else {
+			params.applyStateParams(current, last)
+			last = &current
 		}
-		newParams[storage.PrecompTimeout] = precompTimeout
-		realtimeTimeout, err := storage.PermissioningDb.GetStateInt(storage.RealtimeTimeout)
-		if err != nil {
-			jww.ERROR.Printf(err.Error())
-			continue
-		}
-		newParams[storage.RealtimeTimeout] = realtimeTimeout
-		minDelay, err := storage.PermissioningDb.GetStateInt(storage.MinDelay)
-		if err != nil {
-			jww.ERROR.Printf(err.Error())
-			continue
-		}
-		newParams[storage.MinDelay] = minDelay
-		realtimeDelay, err := storage.PermissioningDb.GetStateInt(storage.AdvertisementTimeout)
-		if err != nil {
-			jww.ERROR.Printf(err.Error())
-			continue
-		}
-		newParams[storage.AdvertisementTimeout] = realtimeDelay
-		valueStr, err := storage.PermissioningDb.GetStateValue(storage.PoolThreshold)
-		if err != nil {
-			jww.ERROR.Printf("Unable to find %s: %+v", storage.PoolThreshold, err)
-			continue
-		}
-		threshold, err := strconv.ParseFloat(valueStr, 64)
-		if err != nil {
-			jww.ERROR.Printf("Unable to decode %s: %+v", valueStr, err)
-			continue
-		}
-
-		jww.INFO.Printf("Preparing to update scheduling params...")
-		params.Lock()
-		jww.INFO.Printf("Updating scheduling params: %+v, %s: %f", newParams, storage.PoolThreshold, threshold)
-		params.TeamSize = uint32(teamSize)
-		params.BatchSize = uint32(batchSize)
-		params.PrecomputationTimeout = time.Duration(precompTimeout)
-		params.RealtimeTimeout = time.Duration(realtimeTimeout)
-		params.MinimumDelay = time.Duration(minDelay)
-		params.RealtimeDelay = time.Duration(realtimeDelay)
-		params.Threshold = threshold
-		params.Unlock()
 
 		time.Sleep(updateFreq)
 	}
-
 }
 
 // Scheduler is a utility function which builds a round by handling a node's
@@ -145,12 +128,12 @@ func Scheduler(params *SafeParams, state *storage.NetworkState, killchan chan ch
 	// Channel to send new rounds over to be created
 	newRoundChan := make(chan protoRound, newRoundChanLen)
 
-	// Select the correct round creator
-	var createRound roundCreator
+	// Activate the scheduling profile for the current time, if any
+	params.switchProfile(time.Now())
 
-	// Set teaming algorithm
-	jww.INFO.Printf("Using Secure Teaming Algorithm")
-	createRound = createSecureRound
+	// Select the correct round creator
+	rr := &roundRobin{}
+	createRound := selectRoundCreator(params.SafeCopy(), rr)
 
 	// Channel to communicate that a round has timed out
 	roundTimeoutTracker := make(chan id.Round, 1000)
@@ -162,12 +145,11 @@ func Scheduler(params *SafeParams, state *storage.NetworkState, killchan chan ch
 
 		lastRound := time.Now()
 
-		paramsCopy := params.SafeCopy()
-		minRoundDelay := (paramsCopy.MinimumDelay * time.Millisecond) / 3
 		var err error
 		for newRound := range newRoundChan {
 
 			// To avoid back-to-back teaming, we make sure to sleep until the minimum delay
+			minRoundDelay := (params.SafeCopy().MinimumDelay * time.Millisecond) / 3
 			if timeDiff := time.Now().Sub(lastRound); timeDiff < minRoundDelay {
 				time.Sleep(minRoundDelay - timeDiff)
 			}
@@ -179,7 +161,7 @@ func Scheduler(params *SafeParams, state *storage.NetworkState, killchan chan ch
 			}
 
 			go waitForRoundTimeout(roundTimeoutTracker, state, ourRound,
-				paramsCopy.PrecomputationTimeout*time.Millisecond, false)
+				newRound.PrecomputationTimeout, false)
 		}
 
 		jww.FATAL.Panicf("Round creation thread should never exit: %v", err)
@@ -189,30 +171,78 @@ func Scheduler(params *SafeParams, state *storage.NetworkState, killchan chan ch
 	var killed chan struct{}
 	iterationsCount := uint32(0)
 
-	// optional debug print which regularly prints the status of rounds and nodes
-	// turned on by setting DebugTrackRounds to true in the scheduling config
-	if params.DebugTrackRounds {
-		go trackRounds(state, pool, roundTracker, &iterationsCount)
-	}
-
-	paramsCopy := params.SafeCopy()
+	paramsCopy, paramsVersion := params.versionedCopy()
 
 	sc := &stateChanger{
-		lastRealtime:     time.Unix(0, 0),
-		realtimeDelay:    paramsCopy.RealtimeDelay * time.Millisecond,
-		realtimeDelta:    paramsCopy.MinimumDelay * time.Millisecond,
-		realtimeTimeout:  paramsCopy.RealtimeTimeout * time.Millisecond,
-		pool:             pool,
-		state:            state,
-		roundTracker:     roundTracker,
-		roundTimeoutChan: roundTimeoutTracker,
+		realtimeDelay:     paramsCopy.RealtimeDelay * time.Millisecond,
+		realtimeDelta:     paramsCopy.MinimumDelay * time.Millisecond,
+		realtimeMaxLead:   paramsCopy.MaxRealtimeLead * time.Millisecond,
+		realtimeIdleGap:   paramsCopy.RealtimeIdleGap * time.Millisecond,
+		realtimeTimeout:   paramsCopy.RealtimeTimeout * time.Millisecond,
+		probationRounds:   paramsCopy.ProbationRounds,
+		probationDuration: paramsCopy.ProbationDuration * time.Millisecond,
+		pool:              pool,
+		state:             state,
+		roundTracker:      roundTracker,
+		roundTimeoutChan:  roundTimeoutTracker,
+
+		diagnosticRecoveryRounds: paramsCopy.DiagnosticRecoveryRounds,
 	}
 
 	jww.INFO.Printf("Initialized state changer with: "+
 		"\n\t realtimeDelay: %s, "+
 		"\n\t realtimeDelta: %s"+
+		"\n\t realtimeMaxLead: %s"+
+		"\n\t realtimeIdleGap: %s"+
 		"\n\t realtimeTimeout: %s", sc.realtimeDelay,
-		sc.realtimeDelta, sc.realtimeTimeout)
+		sc.realtimeDelta, sc.realtimeMaxLead, sc.realtimeIdleGap,
+		sc.realtimeTimeout)
+
+	// optional debug print which regularly prints the status of rounds and nodes
+	// turned on by setting DebugTrackRounds to true in the scheduling config
+	if params.DebugTrackRounds {
+		go trackRounds(state, pool, roundTracker, &sc.spacing, &iterationsCount)
+	}
+
+	// Wake periodically to check whether the pool has been below the team
+	// formation threshold for too long
+	var thresholdWaiter thresholdWait
+	thresholdTicker := newThresholdTicker(paramsCopy)
+	thresholdCheck := func() <-chan time.Time {
+		if thresholdTicker == nil {
+			return nil
+		}
+		return thresholdTicker.C
+	}
+
+	// Wake periodically to check whether enough nodes are reachable for
+	// rounds to be created
+	var circuit reachabilityCircuit
+	reachabilityTicker := newReachabilityTicker(paramsCopy)
+	reachabilityCheck := func() <-chan time.Time {
+		if reachabilityTicker == nil {
+			return nil
+		}
+		return reachabilityTicker.C
+	}
+
+	// Wake periodically to check for nodes stuck in precomputing
+	stuckPrecompTicker := newStuckPrecompTicker(paramsCopy)
+	stuckPrecompCheck := func() <-chan time.Time {
+		if stuckPrecompTicker == nil {
+			return nil
+		}
+		return stuckPrecompTicker.C
+	}
+
+	// Track whether the node group can field a team, when teaming from one
+	var shortage groupShortage
+
+	// Pick back up any rounds in flight when permissioning last stopped
+	err := sc.resumeRounds()
+	if err != nil {
+		return errors.WithMessage(err, "Failed to resume in-flight rounds")
+	}
 
 	// Start receiving updates from nodes
 	for {
@@ -221,6 +251,7 @@ func Scheduler(params *SafeParams, state *storage.NetworkState, killchan chan ch
 		var update node.UpdateNotification
 		var timedOutRoundID id.Round
 		hasUpdate := false
+		isStuckPrecompCheck := false
 
 		select {
 		// Receive a signal to kill the Scheduler
@@ -233,6 +264,17 @@ func Scheduler(params *SafeParams, state *storage.NetworkState, killchan chan ch
 		// Receive a signal indicating that a round has timed out
 		case timedOutRoundID = <-roundTimeoutTracker:
 			isRoundTimeout = true
+		// Check the pool against the threshold timeout
+		case <-thresholdCheck():
+		// Check whether enough nodes are reachable
+		case <-reachabilityCheck():
+		// Check for nodes stuck in precomputing
+		case <-stuckPrecompCheck():
+			isStuckPrecompCheck = true
+		// Stop creating rounds once the network starts draining
+		case <-state.GetDrainChannel():
+			jww.WARN.Printf("Scheduler is draining the network, no new " +
+				"rounds will be created")
 		}
 
 		atomic.AddUint32(&iterationsCount, 1)
@@ -246,13 +288,74 @@ func Scheduler(params *SafeParams, state *storage.NetworkState, killchan chan ch
 			var err error
 
 			// Handle the node's state change
-			err = sc.HandleNodeUpdates(update)
+			err = handleNodeUpdate(state, update, sc.HandleNodeUpdates)
+			if err != nil {
+				return err
+			}
+		} else if isStuckPrecompCheck {
+			// Time out the rounds of nodes stuck in precomputing
+			err := handleStuckPrecomp(paramsCopy, state, roundTracker, time.Now())
 			if err != nil {
 				return err
 			}
 		}
 
+		// Switch to the scheduling profile for the current time, if it
+		// changed, and pick up any params modified at runtime. Only rounds
+		// formed from here on use the new params
+		params.switchProfile(time.Now())
+		if params.getVersion() != paramsVersion {
+			paramsCopy, paramsVersion = params.versionedCopy()
+			createRound = selectRoundCreator(paramsCopy, rr)
+			sc.realtimeDelay = paramsCopy.RealtimeDelay * time.Millisecond
+			sc.realtimeDelta = paramsCopy.MinimumDelay * time.Millisecond
+			sc.realtimeMaxLead = paramsCopy.MaxRealtimeLead * time.Millisecond
+			sc.realtimeIdleGap = paramsCopy.RealtimeIdleGap * time.Millisecond
+			sc.realtimeTimeout = paramsCopy.RealtimeTimeout * time.Millisecond
+			sc.probationRounds = paramsCopy.ProbationRounds
+			sc.probationDuration = paramsCopy.ProbationDuration * time.Millisecond
+			sc.diagnosticRecoveryRounds = paramsCopy.DiagnosticRecoveryRounds
+			if thresholdTicker != nil {
+				thresholdTicker.Stop()
+			}
+			thresholdTicker = newThresholdTicker(paramsCopy)
+			if reachabilityTicker != nil {
+				reachabilityTicker.Stop()
+			}
+			reachabilityTicker = newReachabilityTicker(paramsCopy)
+			if stuckPrecompTicker != nil {
+				stuckPrecompTicker.Stop()
+			}
+			stuckPrecompTicker = newStuckPrecompTicker(paramsCopy)
+		}
+
+		// Keep nodes which reported a critical health error, which were
+		// removed or, when secure teaming, whose uptime is too low out of
+		// teams
+		pool.SweepUnhealthy()
+		pool.SweepRemoved()
+		minUptime := paramsCopy.MinUptime
+		if paramsCopy.Mode == RoundRobinMode {
+			minUptime = 0
+		}
+		pool.SweepLowUptime(minUptime)
+
+		// Demote nodes repeatedly failing rounds before STANDBY and keep
+		// demoted nodes out of teams until they are due another chance
+		if paramsCopy.diagnosticEnabled() {
+			demoteFailingNodes(paramsCopy, state, time.Now())
+		}
+		pool.SweepDiagnostic(paramsCopy.diagnosticHold())
+
+		// Create no rounds while the network drains
+		draining := checkDrain(state, roundTracker, len(newRoundChan))
+
 		for {
+			// Pause round creation while too few nodes are reachable
+			if draining || (killed == nil && !circuit.allowRounds(paramsCopy, state, time.Now())) {
+				break
+			}
+
 			//get the pool of disabled nodes and determine how many
 			//nodes can be scheduled
 			numNodesInPool := pool.Len()
@@ -261,21 +364,46 @@ func Scheduler(params *SafeParams, state *storage.NetworkState, killchan chan ch
 			var teamFormationThreshold int
 			teamSize := int(paramsCopy.TeamSize)
 			teamFormationThreshold = int(paramsCopy.Threshold * float64(state.CountActiveNodes()))
+			teamFormationThreshold = thresholdWaiter.getThreshold(paramsCopy,
+				numNodesInPool, teamFormationThreshold, time.Now())
+			if paramsCopy.Mode == RoundRobinMode {
+				teamFormationThreshold = 0
+			}
 			if numNodesInPool >= teamFormationThreshold && numNodesInPool >= teamSize && killed == nil {
 
-				// Increment round ID
-				currentID, err := state.IncrementRoundID()
-
-				if err != nil {
-					return err
+				// When teaming from a node group, skip the round if the
+				// group cannot field a full team
+				if paramsCopy.NodeGroup != "" &&
+					!shortage.canField(paramsCopy, pool, state) {
+					break
 				}
 
+				// Form the team under the next round ID, only claiming it
+				// once the team is formed so skipped rounds do not use IDs up
+				currentID := state.GetNextRoundID()
 				stream := rng.GetStream()
 				newRound, err := createRound(paramsCopy, pool, teamFormationThreshold, currentID, state, stream)
 				stream.Close()
+				recordExclusions(state, pool, newRound.NodeStateList, currentID)
+				trace := state.Trace(currentID, nil, 0)
+				if err == errAvoidListConflict || err == errProbationConflict {
+					trace.Warnf("Skipping round: %v", err)
+					break
+				} else if err != nil {
+					return err
+				}
+
+				// Increment round ID
+				_, err = state.IncrementRoundID()
 				if err != nil {
 					return err
 				}
+				trace.Debugf("Created round with a team of %d from a pool "+
+					"of %d nodes", newRound.Topology.Len(), numNodesInPool)
+				state.RecordTeamBins(newRound.NodeStateList,
+					int(paramsCopy.FairnessWindow))
+				state.RecordScheduledTeam(len(newRound.NodeStateList), time.Now())
+
 				// Send the round to the new round channel to be created
 				newRoundChan <- newRound
 			} else {
@@ -297,6 +425,22 @@ func Scheduler(params *SafeParams, state *storage.NetworkState, killchan chan ch
 	return errors.New("single Scheduler should never exit")
 }
 
+// selectRoundCreator returns the teaming algorithm for the params. The
+// round-robin algorithm continues its cycle from rr.
+func selectRoundCreator(params Params, rr *roundRobin) roundCreator {
+	if params.Mode == RoundRobinMode {
+		jww.INFO.Printf("Using Round-Robin Teaming Algorithm")
+		return rr.createRound
+	}
+	if params.NodeGroup != "" {
+		jww.INFO.Printf("Using Node Group Teaming Algorithm with group %s",
+			params.NodeGroup)
+		return createGroupRound
+	}
+	jww.INFO.Printf("Using Secure Teaming Algorithm")
+	return createSecureRound
+}
+
 // Helper function which handles when we receive a timed out round
 func timeoutRound(state *storage.NetworkState, timeoutRoundID id.Round,
 	roundTracker *RoundTracker) error {
@@ -335,7 +479,7 @@ func timeoutRound(state *storage.NetworkState, timeoutRoundID id.Round,
 				ourRound.GetRoundID(), err)
 		}
 
-		err = killRound(state, ourRound, timeoutError, roundTracker)
+		err = killRound(state, ourRound, timeoutError, "", roundTracker)
 		if err != nil {
 			return errors.WithMessagef(err, "Failed to kill round %d: %s",
 				ourRound.GetRoundID(), err)
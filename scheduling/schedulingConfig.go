@@ -0,0 +1,90 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package scheduling
+
+// Contains the record of the scheduling params each round was formed with
+
+import (
+	"encoding/json"
+	jww "github.com/spf13/jwalterweatherman"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/xx_network/primitives/id"
+	"time"
+)
+
+// schedulingConfig is the subset of the Params which affect the rounds formed
+// with them, recorded alongside each round so that its performance can be
+// correlated with the params that produced it. Times are in ms, as in the
+// Params.
+type schedulingConfig struct {
+	TeamSize  uint32 `json:"teamSize"`
+	BatchSize uint32 `json:"batchSize"`
+	Mode      string `json:"mode,omitempty"`
+	NodeGroup string `json:"nodeGroup,omitempty"`
+
+	Threshold      float64 `json:"threshold"`
+	RelaxThreshold bool    `json:"relaxThreshold,omitempty"`
+	MinUptime      float64 `json:"minUptime,omitempty"`
+
+	HardAvoidLists     bool    `json:"hardAvoidLists,omitempty"`
+	CapacityAware      bool    `json:"capacityAware,omitempty"`
+	CapacityBatchSize  uint32  `json:"capacityBatchSize,omitempty"`
+	FairnessCorrection float64 `json:"fairnessCorrection,omitempty"`
+
+	ResourceQueueTimeout  time.Duration `json:"resourceQueueTimeout"`
+	MinimumDelay          time.Duration `json:"minimumDelay"`
+	RealtimeDelay         time.Duration `json:"realtimeDelay"`
+	PrecomputationTimeout time.Duration `json:"precomputationTimeout"`
+	RealtimeTimeout       time.Duration `json:"realtimeTimeout"`
+}
+
+// encodeSchedulingConfig returns the JSON encoding of the scheduling config of
+// the Params. Identical configs have identical encodings. Returns nil if the
+// config cannot be encoded.
+func (p Params) encodeSchedulingConfig() []byte {
+	data, err := json.Marshal(schedulingConfig{
+		TeamSize:              p.TeamSize,
+		BatchSize:             p.BatchSize,
+		Mode:                  p.Mode,
+		NodeGroup:             p.NodeGroup,
+		Threshold:             p.Threshold,
+		RelaxThreshold:        p.RelaxThreshold,
+		MinUptime:             p.MinUptime,
+		HardAvoidLists:        p.HardAvoidLists,
+		CapacityAware:         p.CapacityAware,
+		CapacityBatchSize:     p.CapacityBatchSize,
+		FairnessCorrection:    p.FairnessCorrection,
+		ResourceQueueTimeout:  p.ResourceQueueTimeout,
+		MinimumDelay:          p.MinimumDelay,
+		RealtimeDelay:         p.RealtimeDelay,
+		PrecomputationTimeout: p.PrecomputationTimeout,
+		RealtimeTimeout:       p.RealtimeTimeout,
+	})
+	if err != nil {
+		jww.WARN.Printf("Failed to encode scheduling config: %+v", err)
+		return nil
+	}
+	return data
+}
+
+// storeSchedulingConfig stores the scheduling config the round was formed
+// with and returns the ID it is stored under for the round's metric to
+// reference. Returns nil if the round has no config or it cannot be stored, in
+// which case the metric is stored without it.
+func storeSchedulingConfig(roundId id.Round, config []byte) *uint64 {
+	if len(config) == 0 {
+		return nil
+	}
+	configId, err := storage.PermissioningDb.InsertSchedulingConfig(config)
+	if err != nil {
+		jww.WARN.Printf("Failed to store scheduling config of round %d: %+v",
+			roundId, err)
+		return nil
+	}
+	return &configId
+}
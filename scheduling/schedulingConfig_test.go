@@ -0,0 +1,96 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package scheduling
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	pb "gitlab.com/elixxir/comms/mixmessages"
+	"gitlab.com/elixxir/primitives/states"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/xx_network/crypto/signature/rsa"
+	"gitlab.com/xx_network/primitives/id"
+	"testing"
+	"time"
+)
+
+// Tests that each round's metric references the scheduling config of the
+// params it was formed with when the params change between rounds, and that
+// rounds formed with identical params share a config.
+func TestStoreRoundMetric_SchedulingConfig(t *testing.T) {
+	var err error
+	storage.PermissioningDb, _, err = storage.NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("Failed to create database: %+v", err)
+	}
+	privKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+
+	team := []*id.ID{id.NewIdFromUInt(0, id.Node, t),
+		id.NewIdFromUInt(1, id.Node, t)}
+	testState := newResumeTestState(privKey, team, t)
+
+	first := Params{TeamSize: 2, BatchSize: 32, Threshold: 0.3,
+		RealtimeDelay: 3000}
+	second := first
+	second.BatchSize = 64
+	second.Mode = RoundRobinMode
+	roundParams := []Params{first, second, first}
+
+	for i, params := range roundParams {
+		roundID := id.Round(i + 1)
+		proto := createProtoRound(params, testState, team, roundID)
+		r, err := startRound(proto, testState, NewRoundTracker())
+		if err != nil {
+			t.Fatalf("Failed to start round %d: %+v", roundID, err)
+		}
+		for _, n := range proto.NodeStateList {
+			n.ClearRound()
+		}
+		roundInfo := r.BuildRoundInfo()
+		roundInfo.Topology = nil
+		StoreRoundMetric(roundInfo, states.COMPLETED, time.Now().UnixNano(),
+			nil, nil, r.GetSchedulingConfig())
+	}
+
+	configIds := make([]uint64, len(roundParams))
+	for i, params := range roundParams {
+		roundID := id.Round(i + 1)
+		config, err := storage.PermissioningDb.GetRoundSchedulingConfig(roundID)
+		if err != nil || config == nil {
+			t.Fatalf("Failed to get scheduling config of round %d: %v, %+v",
+				roundID, config, err)
+		}
+		configIds[i] = config.Id
+
+		var received schedulingConfig
+		if err = json.Unmarshal([]byte(config.Config), &received); err != nil {
+			t.Fatalf("Failed to decode scheduling config of round %d: %+v",
+				roundID, err)
+		}
+		if received.BatchSize != params.BatchSize ||
+			received.Mode != params.Mode ||
+			received.TeamSize != params.TeamSize ||
+			received.Threshold != params.Threshold ||
+			received.RealtimeDelay != params.RealtimeDelay {
+			t.Errorf("Round %d references the wrong scheduling config: %s",
+				roundID, config.Config)
+		}
+	}
+	if configIds[0] != configIds[2] || configIds[0] == configIds[1] {
+		t.Errorf("Rounds do not share configs only when formed with "+
+			"identical params: %v", configIds)
+	}
+
+	// Rounds formed without a recorded config reference none
+	StoreRoundMetric(&pb.RoundInfo{ID: 4}, states.COMPLETED,
+		time.Now().UnixNano(), nil, nil, nil)
+	config, err := storage.PermissioningDb.GetRoundSchedulingConfig(4)
+	if err != nil || config != nil {
+		t.Errorf("Round without a config references one: %v, %+v", config, err)
+	}
+}
@@ -39,12 +39,47 @@ import (
 func createSecureRound(params Params, pool *waitingPool, threshold int, roundID id.Round,
 	state *storage.NetworkState, rng io.Reader) (protoRound, error) {
 
-	// Pick nodes from the pool
-	nodes, err := pool.PickNRandAtThreshold(threshold, int(params.TeamSize))
+	// Pick nodes from the pool, preferring higher capacity nodes for large
+	// batches when capacity aware, or nodes from under-served bins when
+	// correcting for fairness
+	pick := pool.PickNRandAtThreshold
+	if params.CapacityAware && params.BatchSize >= params.CapacityBatchSize {
+		pick = pool.PickNByCapacityAtThreshold
+	} else if params.FairnessCorrection > 1 {
+		weights := fairnessWeights(state, params.FairnessCorrection)
+		weight := func(ns *node.State) float64 {
+			if w, exists := weights[state.GetNodeBin(ns)]; exists {
+				return w
+			}
+			return 1
+		}
+		pick = func(thresh, n int) ([]*node.State, error) {
+			return pool.PickNWeightedAtThreshold(thresh, n, weight, rng)
+		}
+	}
+	nodes, err := pick(threshold, int(params.TeamSize))
 	if err != nil {
 		return protoRound{}, errors.Errorf("Failed to pick random node group: %v", err)
 	}
 
+	// Swap out nodes on each other's avoid-lists where alternatives exist
+	nodes, err = applyAvoidLists(params, nodes, pool, state, roundID, nil)
+	if err != nil {
+		return protoRound{}, err
+	}
+
+	// Keep more than one node on probation out of the team
+	if params.probationEnabled() {
+		var onProbation bool
+		nodes, onProbation = resolveProbationConflicts(nodes, pool, state, roundID)
+		if onProbation {
+			for _, n := range nodes {
+				pool.Add(n)
+			}
+			return protoRound{}, errProbationConflict
+		}
+	}
+
 	jww.TRACE.Printf("Beginning permutations")
 	start := time.Now()
 
@@ -55,7 +90,7 @@ func createSecureRound(params Params, pool *waitingPool, threshold int, roundID
 		nodeIds = append(nodeIds, n.GetID())
 	}
 
-	optimalTeam, _, err := region.OrderNodeTeam(nodeIds, countries, region.GetCountryBins(),
+	optimalTeam, _, err := region.OrderNodeTeam(nodeIds, countries, state.GetGeoBins(),
 		region.CreateSetLatencyTableWeights(region.CreateLinkTable()), rng)
 	if err != nil {
 		return protoRound{}, errors.WithMessage(err,
@@ -87,6 +122,10 @@ func createProtoRound(params Params, state *storage.NetworkState,
 	newRound.BatchSize = params.BatchSize
 	newRound.NodeStateList = nodeStateList
 	newRound.ResourceQueueTimeout = params.ResourceQueueTimeout * time.Millisecond
+	newRound.PrecomputationTimeout = params.PrecomputationTimeout * time.Millisecond
+	newRound.RealtimeTimeout = params.RealtimeTimeout * time.Millisecond
+	newRound.RealtimeDelay = params.RealtimeDelay * time.Millisecond
+	newRound.SchedulingConfig = params.encodeSchedulingConfig()
 
 	return
 }
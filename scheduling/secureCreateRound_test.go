@@ -9,6 +9,7 @@ package scheduling
 
 import (
 	"crypto/rand"
+	"gitlab.com/elixxir/primitives/current"
 	"gitlab.com/elixxir/registration/storage"
 	"gitlab.com/elixxir/registration/storage/node"
 	"gitlab.com/xx_network/crypto/signature/rsa"
@@ -177,3 +178,239 @@ func TestCreateRound_Error_NotEnoughForThreshold(t *testing.T) {
 		" shouldn't be enough for threshold")
 
 }
+
+// Tests that in capacity aware mode, rounds with a large batch are built from
+// the higher capacity nodes while smaller rounds are not affected.
+func TestCreateRound_CapacityAware(t *testing.T) {
+	var err error
+	storage.PermissioningDb, _, err = storage.NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("Failed to create database: %+v", err)
+	}
+
+	testParams := Params{
+		TeamSize:          4,
+		BatchSize:         1000,
+		Threshold:         1,
+		CapacityAware:     true,
+		CapacityBatchSize: 500,
+	}
+
+	privKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	testState, err := storage.NewState(privKey, 8, "", "", region.GetCountryBins())
+	if err != nil {
+		t.Fatalf("Failed to create test state: %v", err)
+	}
+
+	// Half the nodes report a high capacity, the rest a low one
+	testPool := NewWaitingPool()
+	highCapacity := make(map[*node.State]bool)
+	for i := uint64(0); i < 2*uint64(testParams.TeamSize); i++ {
+		nid := id.NewIdFromUInt(i, id.Node, t)
+		err = testState.GetNodeMap().AddNode(nid, "US", "", "", 0)
+		if err != nil {
+			t.Fatalf("Couldn't add node: %v", err)
+		}
+		nodeState := testState.GetNodeMap().GetNode(nid)
+		if i%2 == 0 {
+			nodeState.SetCapacity(1000)
+			highCapacity[nodeState] = true
+		} else {
+			nodeState.SetCapacity(100)
+		}
+		testPool.Add(nodeState)
+	}
+
+	prng := mathRand.New(mathRand.NewSource(42))
+	for i := 0; i < 10; i++ {
+		roundID, err := testState.GetRoundID()
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, err := createSecureRound(testParams, testPool,
+			int(testParams.TeamSize), roundID, testState, prng)
+		if err != nil {
+			t.Fatalf("Failed to create round: %+v", err)
+		}
+		for _, ns := range r.NodeStateList {
+			if !highCapacity[ns] {
+				t.Errorf("Round %d with a batch size of %d contains node "+
+					"with capacity %d.", i, testParams.BatchSize,
+					ns.GetCapacity())
+			}
+			testPool.Add(ns)
+		}
+	}
+
+	// Rounds below the capacity batch size should pick nodes at random, so
+	// low capacity nodes are eventually picked
+	testParams.BatchSize = 32
+	pickedLow := false
+	for i := 0; i < 20 && !pickedLow; i++ {
+		roundID, err := testState.GetRoundID()
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, err := createSecureRound(testParams, testPool,
+			int(testParams.TeamSize), roundID, testState, prng)
+		if err != nil {
+			t.Fatalf("Failed to create round: %+v", err)
+		}
+		for _, ns := range r.NodeStateList {
+			pickedLow = pickedLow || !highCapacity[ns]
+			testPool.Add(ns)
+		}
+	}
+	if !pickedLow {
+		t.Errorf("Small batch rounds only picked high capacity nodes.")
+	}
+}
+
+// Tests that the scheduler orders teams by the geographic bins set on the
+// state, so that nodes in countries only a custom mapping has bins for are
+// teamed once it is set.
+func TestCreateRound_CustomGeoBins(t *testing.T) {
+	testParams := Params{
+		TeamSize:            4,
+		BatchSize:           32,
+		Threshold:           0.3,
+		NodeCleanUpInterval: 3,
+	}
+	var err error
+	var dc func() error
+	storage.PermissioningDb, dc, err = storage.NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("Failed to create database: %+v", err)
+	}
+	t.Cleanup(func() { _ = dc() })
+	privKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	testState, err := storage.NewState(privKey, 8, "", "", region.GetCountryBins())
+	if err != nil {
+		t.Fatalf("Failed to create test state: %v", err)
+	}
+
+	countries := []string{"ZZ", "ZZ", "YY", "YY"}
+	nodeStates := make([]*node.State, len(countries))
+	testpool := NewWaitingPool()
+	for i, country := range countries {
+		nid := id.NewIdFromUInt(uint64(i), id.Node, t)
+		err = testState.GetNodeMap().AddNode(nid, country, "", "", 0)
+		if err != nil {
+			t.Fatalf("Couldn't add node: %v", err)
+		}
+		nodeStates[i] = testState.GetNodeMap().GetNode(nid)
+		testpool.Add(nodeStates[i])
+	}
+
+	roundID, err := testState.GetRoundID()
+	if err != nil {
+		t.Fatalf("Failed to get round ID: %v", err)
+	}
+	threshold := int(testParams.Threshold * float64(testParams.TeamSize))
+	prng := mathRand.New(mathRand.NewSource(42))
+
+	// The built-in mapping has no bins for the nodes' countries
+	_, err = createSecureRound(testParams, testpool, threshold, roundID,
+		testState, prng)
+	if err == nil {
+		t.Fatalf("Nodes in countries without bins were teamed.")
+	}
+	for _, n := range nodeStates {
+		testpool.Add(n)
+	}
+
+	testState.SetGeoBins(map[string]region.GeoBin{
+		"ZZ": region.Oceania, "YY": region.EasternAsia})
+	r, err := createSecureRound(testParams, testpool, threshold, roundID,
+		testState, prng)
+	if err != nil {
+		t.Fatalf("Failed to team nodes in custom bins: %v", err)
+	}
+	if uint32(len(r.NodeStateList)) != testParams.TeamSize {
+		t.Errorf("Team has %d nodes, expected %d.", len(r.NodeStateList),
+			testParams.TeamSize)
+	}
+	for _, n := range r.NodeStateList {
+		expected := region.Oceania.String()
+		if n.GetOrdering() == "YY" {
+			expected = region.EasternAsia.String()
+		}
+		if bin := testState.GetNodeBin(n); bin != expected {
+			t.Errorf("Node in %s is in bin %s, expected %s.",
+				n.GetOrdering(), bin, expected)
+		}
+	}
+}
+
+// Tests that secure teams are only formed from the nodes in a pool of high
+// and low uptime nodes whose uptime reaches MinUptime.
+func TestCreateRound_MinUptime(t *testing.T) {
+	testParams := Params{
+		TeamSize:            3,
+		BatchSize:           32,
+		Threshold:           0.3,
+		NodeCleanUpInterval: 3,
+		MinUptime:           0.9,
+	}
+	var err error
+	var dc func() error
+	storage.PermissioningDb, dc, err = storage.NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("Failed to create database: %+v", err)
+	}
+	t.Cleanup(func() { _ = dc() })
+	privKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	testState, err := storage.NewState(privKey, 8, "", "", region.GetCountryBins())
+	if err != nil {
+		t.Fatalf("Failed to create test state: %v", err)
+	}
+
+	testpool := NewWaitingPool()
+	for i := 0; i < 12; i++ {
+		nid := id.NewIdFromUInt(uint64(i), id.Node, t)
+		err = testState.GetNodeMap().AddNode(nid, "US", "", "", 0)
+		if err != nil {
+			t.Fatalf("Couldn't add node: %v", err)
+		}
+		ns := testState.GetNodeMap().GetNode(nid)
+		if _, _, err = ns.Update(current.WAITING); err != nil {
+			t.Fatalf("Failed to update node activity: %+v", err)
+		}
+
+		// Every other node polled in fewer than MinUptime of its intervals
+		ns.SetUptime(0.95)
+		if i%2 == 1 {
+			ns.SetUptime(0.5)
+		}
+		testpool.Add(ns)
+	}
+	testpool.SweepLowUptime(testParams.MinUptime)
+	if testpool.Len() != 6 {
+		t.Fatalf("%d nodes are in the pool, expected %d.", testpool.Len(), 6)
+	}
+
+	prng := mathRand.New(mathRand.NewSource(42))
+	for i := 0; i < 2; i++ {
+		roundID, err := testState.IncrementRoundID()
+		if err != nil {
+			t.Fatalf("Failed to get round ID: %v", err)
+		}
+		r, err := createSecureRound(testParams, testpool, 1, roundID,
+			testState, prng)
+		if err != nil {
+			t.Fatalf("Failed to create round %d: %v", i, err)
+		}
+		for _, ns := range r.NodeStateList {
+			if ns.GetUptime() < testParams.MinUptime {
+				t.Errorf("Node %s with uptime %f was teamed.", ns.GetID(),
+					ns.GetUptime())
+			}
+		}
+	}
+
+	// Only low uptime nodes are left, which are not teamed
+	_, err = createSecureRound(testParams, testpool, 1, 10, testState, prng)
+	if err == nil {
+		t.Errorf("Round was formed from nodes below the minimum uptime.")
+	}
+}
@@ -28,6 +28,8 @@ func startRound(round protoRound, state *storage.NetworkState, roundTracker *Rou
 		err = errors.WithMessagef(err, "Failed to create new round %v", round.ID)
 		return nil, err
 	}
+	r.SetRealtimeParams(round.RealtimeDelay, round.RealtimeTimeout)
+	r.SetSchedulingConfig(round.SchedulingConfig)
 
 	// Move the round to precomputing
 	err = r.Update(states.PRECOMPUTING, time.Now())
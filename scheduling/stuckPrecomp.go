@@ -0,0 +1,96 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Contains the detection of nodes which never transition out of precomputing
+
+package scheduling
+
+import (
+	"gitlab.com/elixxir/primitives/current"
+	"gitlab.com/elixxir/primitives/states"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/xx_network/primitives/id"
+	"sort"
+	"time"
+)
+
+// How often the scheduler wakes to check for nodes stuck in precomputing while
+// the check is enabled
+const stuckPrecompCheckInterval = 5 * time.Second
+
+// findStuckPrecomp returns the nodes which have been precomputing their round
+// for at least the threshold as of now, keyed by their round. Only rounds which
+// are still precomputing are included. The time a node entered precomputing is
+// taken from its last activity change, or from the round's precomputing
+// timestamp for nodes whose activity was resumed after a restart.
+func findStuckPrecomp(state *storage.NetworkState, threshold time.Duration,
+	now time.Time) map[id.Round][]*id.ID {
+	stuck := make(map[id.Round][]*id.ID)
+	for _, n := range state.GetNodeMap().GetNodeStates() {
+		s := n.Snapshot()
+		inRound, r := s.InRound()
+		if s.Activity != current.PRECOMPUTING || !inRound ||
+			r.GetRoundState() != states.PRECOMPUTING {
+			continue
+		}
+
+		since := s.LastUpdate
+		if since.IsZero() {
+			ts := r.BuildRoundInfo().Timestamps
+			if len(ts) <= int(states.PRECOMPUTING) || ts[states.PRECOMPUTING] == 0 {
+				continue
+			}
+			since = time.Unix(0, int64(ts[states.PRECOMPUTING]))
+		}
+
+		if now.Sub(since) >= threshold {
+			stuck[r.GetRoundID()] = append(stuck[r.GetRoundID()], s.ID)
+		}
+	}
+	return stuck
+}
+
+// handleStuckPrecomp reports every node stuck in precomputing for longer than
+// the StuckPrecompThreshold and times out its round's precomputation, as the
+// round's precomputation timeout would. Does nothing when the threshold is
+// zero.
+func handleStuckPrecomp(params Params, state *storage.NetworkState,
+	roundTracker *RoundTracker, now time.Time) error {
+	if params.StuckPrecompThreshold <= 0 {
+		return nil
+	}
+	threshold := params.StuckPrecompThreshold * time.Millisecond
+
+	stuck := findStuckPrecomp(state, threshold, now)
+	roundIDs := make([]id.Round, 0, len(stuck))
+	for roundID := range stuck {
+		roundIDs = append(roundIDs, roundID)
+	}
+	sort.Slice(roundIDs, func(i, j int) bool { return roundIDs[i] < roundIDs[j] })
+
+	for _, roundID := range roundIDs {
+		for _, nid := range stuck[roundID] {
+			state.Trace(roundID, nid, 0).Errorf("Node %s has been stuck "+
+				"precomputing round %d for longer than %s, timing out the "+
+				"round", nid, roundID, threshold)
+		}
+		err := timeoutRound(state, roundID, roundTracker)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newStuckPrecompTicker returns a ticker which wakes the scheduler to check
+// for nodes stuck in precomputing, or nil if the check is disabled.
+func newStuckPrecompTicker(params Params) *time.Ticker {
+	if params.StuckPrecompThreshold <= 0 {
+		return nil
+	}
+	return time.NewTicker(stuckPrecompCheckInterval)
+}
@@ -0,0 +1,150 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package scheduling
+
+import (
+	"gitlab.com/elixxir/primitives/current"
+	"gitlab.com/elixxir/primitives/states"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/elixxir/registration/storage/node"
+	"gitlab.com/elixxir/registration/storage/round"
+	"gitlab.com/xx_network/comms/connect"
+	"gitlab.com/xx_network/primitives/id"
+	"testing"
+	"time"
+)
+
+// Adds a round precomputing since the timestamp to the state, with a team of
+// new nodes starting at the first ID
+func addPrecompRound(testState *storage.NetworkState, roundID id.Round,
+	firstID uint64, teamSize int, since time.Time, t *testing.T) (*round.State, []*node.State) {
+	nodeList := make([]*id.ID, teamSize)
+	nodes := make([]*node.State, teamSize)
+	for i := range nodeList {
+		nodeList[i] = id.NewIdFromUInt(firstID+uint64(i), id.Node, t)
+		err := testState.GetNodeMap().AddNode(nodeList[i], "US", "", "", 0)
+		if err != nil {
+			t.Fatalf("Couldn't add node: %v", err)
+		}
+		nodes[i] = testState.GetNodeMap().GetNode(nodeList[i])
+	}
+
+	r := round.NewState_Testing(roundID, states.PENDING,
+		connect.NewCircuit(nodeList), t)
+	if err := r.Update(states.PRECOMPUTING, since); err != nil {
+		t.Fatalf("Failed to update round: %+v", err)
+	}
+	testState.GetRoundMap().AddRound_Testing(r, t)
+	return r, nodes
+}
+
+// Tests that a node held precomputing past the threshold is found, whether it
+// entered precomputing through an update or was resumed after a restart, and
+// that nodes which finished precomputing or are under the threshold are not.
+func TestFindStuckPrecomp(t *testing.T) {
+	testState := newFairnessTestState(t)
+	threshold := time.Minute
+	start := time.Now()
+
+	// The first node entered precomputing through an update, the second
+	// already finished it
+	r1, updated := addPrecompRound(testState, 1, 0, 2, start, t)
+	if _, _, err := updated[0].Update(current.WAITING); err != nil {
+		t.Fatalf("Failed to update node: %+v", err)
+	}
+	if err := updated[0].SetRound(r1); err != nil {
+		t.Fatalf("Failed to set round: %+v", err)
+	}
+	if _, _, err := updated[0].Update(current.PRECOMPUTING); err != nil {
+		t.Fatalf("Failed to update node: %+v", err)
+	}
+	if err := updated[1].ResumeRound(r1, current.STANDBY); err != nil {
+		t.Fatalf("Failed to resume round: %+v", err)
+	}
+
+	// The node was resumed precomputing, so the round says when it started
+	r2, resumed := addPrecompRound(testState, 2, 10, 1, start, t)
+	if err := resumed[0].ResumeRound(r2, current.PRECOMPUTING); err != nil {
+		t.Fatalf("Failed to resume round: %+v", err)
+	}
+
+	if stuck := findStuckPrecomp(testState, threshold, start); len(stuck) != 0 {
+		t.Errorf("Nodes under the threshold found stuck: %v", stuck)
+	}
+
+	stuck := findStuckPrecomp(testState, threshold, time.Now().Add(threshold))
+	if len(stuck) != 2 {
+		t.Fatalf("Expected 2 rounds with stuck nodes, found %d: %v",
+			len(stuck), stuck)
+	}
+	if len(stuck[1]) != 1 || !stuck[1][0].Cmp(updated[0].GetID()) {
+		t.Errorf("Unexpected stuck nodes in round 1: %v", stuck[1])
+	}
+	if len(stuck[2]) != 1 || !stuck[2][0].Cmp(resumed[0].GetID()) {
+		t.Errorf("Unexpected stuck nodes in round 2: %v", stuck[2])
+	}
+}
+
+// Tests that handleStuckPrecomp() times out the round of a node held
+// precomputing past the threshold, leaves a round under the threshold
+// precomputing, and does nothing while disabled.
+func TestHandleStuckPrecomp(t *testing.T) {
+	testState := newFairnessTestState(t)
+	roundTracker := NewRoundTracker()
+	params := Params{StuckPrecompThreshold: 60000}
+	now := time.Now()
+
+	stuckRound, stuckNodes := addPrecompRound(testState, 1, 0, 1, now.Add(-2*time.Minute), t)
+	if err := stuckNodes[0].ResumeRound(stuckRound, current.PRECOMPUTING); err != nil {
+		t.Fatalf("Failed to resume round: %+v", err)
+	}
+	freshRound, freshNodes := addPrecompRound(testState, 2, 10, 1, now.Add(-time.Second), t)
+	if err := freshNodes[0].ResumeRound(freshRound, current.PRECOMPUTING); err != nil {
+		t.Fatalf("Failed to resume round: %+v", err)
+	}
+	roundTracker.AddActiveRound(1)
+	roundTracker.AddActiveRound(2)
+
+	// Nothing is timed out while the check is disabled
+	err := handleStuckPrecomp(Params{}, testState, roundTracker, now)
+	if err != nil {
+		t.Fatalf("handleStuckPrecomp() returned an error: %+v", err)
+	}
+	if stuckRound.GetRoundState() != states.PRECOMPUTING {
+		t.Errorf("Round timed out while the check is disabled.")
+	}
+
+	err = handleStuckPrecomp(params, testState, roundTracker, now)
+	if err != nil {
+		t.Fatalf("handleStuckPrecomp() returned an error: %+v", err)
+	}
+	if stuckRound.GetRoundState() != states.FAILED {
+		t.Errorf("Round of the stuck node is %s instead of failed.",
+			stuckRound.GetRoundState())
+	}
+	if errs := stuckRound.BuildRoundInfo().Errors; len(errs) != 1 {
+		t.Errorf("Expected the round to have 1 error, it has %d: %+v",
+			len(errs), errs)
+	}
+	if freshRound.GetRoundState() != states.PRECOMPUTING {
+		t.Errorf("Round under the threshold is %s instead of precomputing.",
+			freshRound.GetRoundState())
+	}
+	if roundTracker.Len() != 1 {
+		t.Errorf("Expected 1 active round, have %d.", roundTracker.Len())
+	}
+
+	// The failed round is not timed out again
+	err = handleStuckPrecomp(params, testState, roundTracker, now)
+	if err != nil {
+		t.Fatalf("handleStuckPrecomp() returned an error: %+v", err)
+	}
+	if errs := stuckRound.BuildRoundInfo().Errors; len(errs) != 1 {
+		t.Errorf("Round was timed out again: %+v", errs)
+	}
+}
@@ -0,0 +1,79 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Contains the handling of a pool which stays below the team formation
+// threshold for too long
+
+package scheduling
+
+import (
+	jww "github.com/spf13/jwalterweatherman"
+	"time"
+)
+
+// thresholdWait tracks how long the pool has been below the team formation
+// threshold
+type thresholdWait struct {
+	// When the pool fell below the threshold, zero while it is not below it
+	belowSince time.Time
+	// When the operator was last warned about the stalled pool
+	lastWarning time.Time
+}
+
+// getThreshold returns the pool size teams are formed at. Once the pool has
+// been below the threshold for longer than the ThresholdTimeout, the threshold
+// is either relaxed down to the team size until the pool reaches it again or,
+// if RelaxThreshold is not set, an error is logged every ThresholdTimeout.
+func (w *thresholdWait) getThreshold(params Params, poolSize, threshold int,
+	now time.Time) int {
+	timeout := params.ThresholdTimeout * time.Millisecond
+	if timeout <= 0 || poolSize >= threshold {
+		if !w.belowSince.IsZero() && poolSize >= threshold {
+			jww.INFO.Printf("Pool of %d nodes reached the team formation "+
+				"threshold of %d", poolSize, threshold)
+		}
+		w.belowSince = time.Time{}
+		w.lastWarning = time.Time{}
+		return threshold
+	}
+
+	if w.belowSince.IsZero() {
+		w.belowSince = now
+	}
+	waited := now.Sub(w.belowSince)
+	if waited < timeout {
+		return threshold
+	}
+
+	teamSize := int(params.TeamSize)
+	if params.RelaxThreshold && teamSize < threshold {
+		if w.lastWarning.IsZero() {
+			jww.WARN.Printf("Pool of %d nodes has been below the team "+
+				"formation threshold of %d for %s, relaxing the threshold "+
+				"to the team size of %d", poolSize, threshold, waited, teamSize)
+			w.lastWarning = now
+		}
+		return teamSize
+	}
+
+	if !params.RelaxThreshold && now.Sub(w.lastWarning) >= timeout {
+		jww.ERROR.Printf("ROUND CREATION STALLED: pool of %d nodes has "+
+			"been below the team formation threshold of %d for %s, no "+
+			"rounds are being created", poolSize, threshold, waited)
+		w.lastWarning = now
+	}
+	return threshold
+}
+
+// newThresholdTicker returns a ticker which wakes the scheduler to check the
+// threshold timeout, or nil if there is no timeout.
+func newThresholdTicker(params Params) *time.Ticker {
+	if params.ThresholdTimeout <= 0 {
+		return nil
+	}
+	return time.NewTicker(params.ThresholdTimeout * time.Millisecond)
+}
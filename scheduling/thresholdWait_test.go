@@ -0,0 +1,99 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package scheduling
+
+import (
+	"testing"
+	"time"
+)
+
+// Tests that the threshold is relaxed down to the team size once the pool has
+// stayed below it past the timeout, and restored once the pool reaches it.
+func TestThresholdWait_getThreshold_Relax(t *testing.T) {
+	params := Params{TeamSize: 3, ThresholdTimeout: 1000, RelaxThreshold: true}
+	w := &thresholdWait{}
+	start := time.Now()
+
+	if threshold := w.getThreshold(params, 4, 10, start); threshold != 10 {
+		t.Errorf("Threshold relaxed before the timeout: %d", threshold)
+	}
+	if threshold := w.getThreshold(params, 4, 10,
+		start.Add(999*time.Millisecond)); threshold != 10 {
+		t.Errorf("Threshold relaxed before the timeout: %d", threshold)
+	}
+	if threshold := w.getThreshold(params, 4, 10,
+		start.Add(time.Second)); threshold != 3 {
+		t.Errorf("Threshold not relaxed to the team size after the timeout."+
+			"\nexpected: %d\nreceived: %d", 3, threshold)
+	}
+
+	// Stays relaxed while teams formed keep the pool below the threshold
+	if threshold := w.getThreshold(params, 1, 10,
+		start.Add(2*time.Second)); threshold != 3 {
+		t.Errorf("Threshold not kept relaxed.\nexpected: %d\nreceived: %d",
+			3, threshold)
+	}
+
+	// Reaching the threshold resets the wait
+	if threshold := w.getThreshold(params, 10, 10,
+		start.Add(3*time.Second)); threshold != 10 {
+		t.Errorf("Unexpected threshold.\nexpected: %d\nreceived: %d", 10, threshold)
+	}
+	if threshold := w.getThreshold(params, 4, 10,
+		start.Add(4*time.Second)); threshold != 10 {
+		t.Errorf("Threshold relaxed before the timeout after being reached: %d",
+			threshold)
+	}
+}
+
+// Tests that the operator is warned every timeout while the pool stays below
+// the threshold, and that the threshold is kept.
+func TestThresholdWait_getThreshold_Warn(t *testing.T) {
+	params := Params{TeamSize: 3, ThresholdTimeout: 1000}
+	w := &thresholdWait{}
+	start := time.Now()
+
+	w.getThreshold(params, 4, 10, start)
+	if !w.lastWarning.IsZero() {
+		t.Errorf("Warned before the timeout.")
+	}
+
+	warnedAt := start.Add(time.Second)
+	if threshold := w.getThreshold(params, 4, 10, warnedAt); threshold != 10 {
+		t.Errorf("Threshold changed without RelaxThreshold: %d", threshold)
+	}
+	if !w.lastWarning.Equal(warnedAt) {
+		t.Errorf("Not warned after the timeout.")
+	}
+
+	// Not warned again until another timeout passes
+	w.getThreshold(params, 4, 10, warnedAt.Add(500*time.Millisecond))
+	if !w.lastWarning.Equal(warnedAt) {
+		t.Errorf("Warned again before another timeout passed.")
+	}
+	w.getThreshold(params, 4, 10, warnedAt.Add(time.Second))
+	if !w.lastWarning.Equal(warnedAt.Add(time.Second)) {
+		t.Errorf("Not warned again after another timeout passed.")
+	}
+}
+
+// Tests that the threshold is never changed without a timeout.
+func TestThresholdWait_getThreshold_Disabled(t *testing.T) {
+	params := Params{TeamSize: 3, RelaxThreshold: true}
+	w := &thresholdWait{}
+	start := time.Now()
+
+	w.getThreshold(params, 4, 10, start)
+	if threshold := w.getThreshold(params, 4, 10,
+		start.Add(time.Hour)); threshold != 10 {
+		t.Errorf("Threshold relaxed without a timeout: %d", threshold)
+	}
+	if newThresholdTicker(params) != nil {
+		t.Errorf("Ticker created without a timeout.")
+	}
+}
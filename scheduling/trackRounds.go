@@ -24,7 +24,8 @@ import (
 // This isn't included in tests because it is hard to test and is only a data collector for logs.
 // It's used in live environment logs to check stability.
 func trackRounds(state *storage.NetworkState, pool *waitingPool,
-	roundTracker *RoundTracker, schedulerIteration *uint32) {
+	roundTracker *RoundTracker, spacing *realtimeSpacing,
+	schedulerIteration *uint32) {
 	// Period of polling the state map for logs
 	schedulingTicker := time.NewTicker(1 * time.Minute)
 
@@ -145,6 +146,7 @@ func trackRounds(state *storage.NetworkState, pool *waitingPool,
 		jww.INFO.Printf("Teams in precomp: %v", len(precompRounds))
 		jww.INFO.Printf("Teams in queued: %v", len(queuedRounds))
 		jww.INFO.Printf("Teams in realtime: %v", len(realtimeRounds))
+		jww.INFO.Printf("Effective realtime gap: %s", spacing.getEffectiveGap())
 		jww.INFO.Printf("")
 		jww.INFO.Printf("Nodes in waiting: %v", waitingNodes)
 		jww.INFO.Printf("Nodes in precomp: %v", precompNodes)
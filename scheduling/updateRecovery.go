@@ -0,0 +1,40 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package scheduling
+
+// Contains the recovery from panics while handling node updates
+
+import (
+	jww "github.com/spf13/jwalterweatherman"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/elixxir/registration/storage/node"
+	"runtime/debug"
+)
+
+// handleNodeUpdate handles the node's update, recovering from a panic while
+// handling it so that the scheduler keeps running. The update is dropped and
+// the node's polling lock released, which would otherwise be held forever if
+// the panic happened before the handler deferred releasing it.
+func handleNodeUpdate(state *storage.NetworkState, update node.UpdateNotification,
+	handle func(node.UpdateNotification) error) (err error) {
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+		jww.ERROR.Printf("Recovered from a panic handling the update of "+
+			"node %s from %s to %s, dropping the update: %v\n%s",
+			update.Node, update.FromActivity, update.ToActivity, r,
+			debug.Stack())
+		if n := state.GetNodeMap().GetNode(update.Node); n != nil {
+			n.ReleasePollingLock(update.PollingHold)
+		}
+		err = nil
+	}()
+	return handle(update)
+}
@@ -0,0 +1,91 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package scheduling
+
+import (
+	"crypto/rand"
+	"github.com/pkg/errors"
+	"gitlab.com/elixxir/primitives/current"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/elixxir/registration/storage/node"
+	"gitlab.com/xx_network/crypto/signature/rsa"
+	"gitlab.com/xx_network/primitives/id"
+	"gitlab.com/xx_network/primitives/region"
+	"testing"
+	"time"
+)
+
+// Tests that a handler panicking before or after deferring the release of
+// the node's polling lock is recovered from, and that the polling lock is
+// released either way so that the node's next poll is handled.
+func TestHandleNodeUpdate_Panic(t *testing.T) {
+	var err error
+	var dc func() error
+	storage.PermissioningDb, dc, err = storage.NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("Failed to create database: %+v", err)
+	}
+	t.Cleanup(func() { _ = dc() })
+	privKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	state, err := storage.NewState(privKey, 8, "", "", region.GetCountryBins())
+	if err != nil {
+		t.Fatalf("Failed to create state: %+v", err)
+	}
+	nid := id.NewIdFromString("node", id.Node, t)
+	if err = state.GetNodeMap().AddNode(nid, "", "", "", 0); err != nil {
+		t.Fatalf("Failed to add node: %+v", err)
+	}
+	n := state.GetNodeMap().GetNode(nid)
+
+	handlers := map[string]func(node.UpdateNotification) error{
+		"before releasing": func(node.UpdateNotification) error {
+			panic("handler failed")
+		},
+		"after releasing": func(update node.UpdateNotification) error {
+			defer n.ReleasePollingLock(update.PollingHold)
+			panic("handler failed")
+		},
+	}
+	for name, handler := range handlers {
+		// The node polls and its update is handed to the scheduler
+		n.GetPollingLock().Lock()
+		if err = state.SendUpdateNotification(node.UpdateNotification{
+			Node: nid, FromActivity: current.NOT_STARTED,
+			ToActivity: current.WAITING}); err != nil {
+			t.Fatalf("Failed to send update: %+v", err)
+		}
+		update := <-state.GetNodeUpdateChannel()
+
+		if err = handleNodeUpdate(state, update, handler); err != nil {
+			t.Errorf("Handler panicking %s returned an error: %+v", name, err)
+		}
+
+		locked := make(chan struct{})
+		go func() {
+			n.GetPollingLock().Lock()
+			close(locked)
+		}()
+		select {
+		case <-locked:
+			n.GetPollingLock().Unlock()
+		case <-time.After(time.Second):
+			t.Fatalf("Polling lock was not released after the handler "+
+				"panicked %s.", name)
+		}
+	}
+
+	// Errors of handlers which do not panic are returned
+	n.GetPollingLock().Lock()
+	defer n.GetPollingLock().Unlock()
+	handlerErr := errors.New("handler error")
+	err = handleNodeUpdate(state, node.UpdateNotification{Node: nid},
+		func(node.UpdateNotification) error { return handlerErr })
+	if err != handlerErr {
+		t.Errorf("Unexpected error from the handler: %+v", err)
+	}
+}
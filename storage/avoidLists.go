@@ -0,0 +1,169 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Handles Application avoid-lists, which declare other Applications whose
+// Nodes an operator's Node must not be teamed with
+
+package storage
+
+import (
+	"github.com/pkg/errors"
+	jww "github.com/spf13/jwalterweatherman"
+	"sort"
+)
+
+// MaxAvoidListSize is the maximum number of Applications a single
+// Application may avoid
+const MaxAvoidListSize = 32
+
+// DefaultAvoidListWarnFraction is the default fraction of the network an
+// avoid-list may exclude before it is flagged as excessive
+const DefaultAvoidListWarnFraction = 0.1
+
+// LoadAvoidLists replaces the avoid-lists with those persisted to Storage.
+func (s *NetworkState) LoadAvoidLists() error {
+	avoidLists, err := PermissioningDb.GetAvoidLists()
+	if err != nil {
+		return errors.WithMessage(err, "Failed to load avoid-lists")
+	}
+	avoidSets := make(map[uint64]map[uint64]bool, len(avoidLists))
+	for appId, avoided := range avoidLists {
+		avoidSets[appId] = make(map[uint64]bool, len(avoided))
+		for _, avoidedId := range avoided {
+			avoidSets[appId][avoidedId] = true
+		}
+	}
+
+	s.avoidListsMux.Lock()
+	defer s.avoidListsMux.Unlock()
+	s.avoidLists = avoidSets
+	return nil
+}
+
+// SetAvoidList replaces the avoid-list of the Application with the given ID.
+// An empty list clears it. The list is persisted to Storage before it takes
+// effect. If the list excludes more than the warning fraction of the network,
+// a warning is logged; it is still applied.
+func (s *NetworkState) SetAvoidList(applicationId uint64, avoided []uint64) error {
+	if len(avoided) > MaxAvoidListSize {
+		return errors.Errorf("Avoid-list of application %d has %d entries "+
+			"which exceeds the maximum of %d", applicationId, len(avoided),
+			MaxAvoidListSize)
+	}
+
+	avoidSet := make(map[uint64]bool, len(avoided))
+	for _, avoidedId := range avoided {
+		if avoidedId == applicationId {
+			return errors.Errorf("Application %d cannot avoid itself",
+				applicationId)
+		}
+		if avoidSet[avoidedId] {
+			return errors.Errorf("Application %d is listed more than once "+
+				"in the avoid-list of application %d", avoidedId, applicationId)
+		}
+		avoidSet[avoidedId] = true
+	}
+
+	s.avoidListsMux.Lock()
+	err := PermissioningDb.UpsertAvoidList(applicationId, avoided)
+	if err != nil {
+		s.avoidListsMux.Unlock()
+		return errors.WithMessagef(err, "Failed to store avoid-list of "+
+			"application %d", applicationId)
+	}
+	if len(avoidSet) == 0 {
+		delete(s.avoidLists, applicationId)
+	} else {
+		s.avoidLists[applicationId] = avoidSet
+	}
+	warnFraction := s.avoidListWarnFraction
+	s.avoidListsMux.Unlock()
+
+	if excluded := s.GetAvoidListExclusion(applicationId); excluded > warnFraction {
+		jww.WARN.Printf("Avoid-list of application %d excludes %.1f%% of "+
+			"the network, above the %.1f%% warning threshold",
+			applicationId, excluded*100, warnFraction*100)
+	}
+	return nil
+}
+
+// SetAvoidListWarnFraction sets the fraction of the network an avoid-list may
+// exclude before it is flagged as excessive.
+func (s *NetworkState) SetAvoidListWarnFraction(fraction float64) {
+	s.avoidListsMux.Lock()
+	defer s.avoidListsMux.Unlock()
+	s.avoidListWarnFraction = fraction
+}
+
+// GetAvoidList returns the sorted IDs of the Applications avoided by the
+// Application with the given ID.
+func (s *NetworkState) GetAvoidList(applicationId uint64) []uint64 {
+	s.avoidListsMux.RLock()
+	defer s.avoidListsMux.RUnlock()
+
+	avoided := make([]uint64, 0, len(s.avoidLists[applicationId]))
+	for avoidedId := range s.avoidLists[applicationId] {
+		avoided = append(avoided, avoidedId)
+	}
+	sort.Slice(avoided, func(i, j int) bool { return avoided[i] < avoided[j] })
+	return avoided
+}
+
+// Avoids returns true if either Application is on the other's avoid-list,
+// meaning their Nodes should not be teamed together.
+func (s *NetworkState) Avoids(a, b uint64) bool {
+	s.avoidListsMux.RLock()
+	defer s.avoidListsMux.RUnlock()
+	return s.avoidLists[a][b] || s.avoidLists[b][a]
+}
+
+// GetAvoidListExclusion returns the fraction of the other Nodes in the network
+// that the avoid-list of the given Application excludes from its Node's teams.
+func (s *NetworkState) GetAvoidListExclusion(applicationId uint64) float64 {
+	s.avoidListsMux.RLock()
+	avoidSet := s.avoidLists[applicationId]
+	s.avoidListsMux.RUnlock()
+	if len(avoidSet) == 0 {
+		return 0
+	}
+
+	others, excluded := 0, 0
+	for _, n := range s.GetNodeMap().GetNodeStates() {
+		if n.GetAppID() == applicationId {
+			continue
+		}
+		others++
+		if avoidSet[n.GetAppID()] {
+			excluded++
+		}
+	}
+	if others == 0 {
+		return 0
+	}
+	return float64(excluded) / float64(others)
+}
+
+// GetExcessiveAvoidLists returns the Applications whose avoid-lists exclude
+// more than the warning fraction of the network, mapped to the fraction they
+// exclude.
+func (s *NetworkState) GetExcessiveAvoidLists() map[uint64]float64 {
+	s.avoidListsMux.RLock()
+	warnFraction := s.avoidListWarnFraction
+	appIds := make([]uint64, 0, len(s.avoidLists))
+	for appId := range s.avoidLists {
+		appIds = append(appIds, appId)
+	}
+	s.avoidListsMux.RUnlock()
+
+	excessive := make(map[uint64]float64)
+	for _, appId := range appIds {
+		if excluded := s.GetAvoidListExclusion(appId); excluded > warnFraction {
+			excessive[appId] = excluded
+		}
+	}
+	return excessive
+}
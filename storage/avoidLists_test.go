@@ -0,0 +1,146 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package storage
+
+import (
+	"gitlab.com/xx_network/primitives/id"
+	"reflect"
+	"testing"
+)
+
+// Tests that avoid-lists set on the NetworkState are applied symmetrically,
+// can be cleared, and are reloaded from Storage by a new NetworkState.
+func TestNetworkState_SetAvoidList(t *testing.T) {
+	var err error
+	PermissioningDb, _, err = NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("Failed to create database: %+v", err)
+	}
+
+	state, privateKey, err := generateTestNetworkState()
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	if err = state.SetAvoidList(1, []uint64{3, 2}); err != nil {
+		t.Fatalf("SetAvoidList() returned an error: %+v", err)
+	}
+	if err = state.SetAvoidList(4, []uint64{5}); err != nil {
+		t.Fatalf("SetAvoidList() returned an error: %+v", err)
+	}
+
+	if !state.Avoids(1, 2) || !state.Avoids(2, 1) {
+		t.Errorf("Avoids() should be true in both directions.")
+	}
+	if state.Avoids(2, 3) {
+		t.Errorf("Avoids() should be false for applications which do not " +
+			"avoid each other.")
+	}
+	if received := state.GetAvoidList(1); !reflect.DeepEqual(received, []uint64{2, 3}) {
+		t.Errorf("GetAvoidList() returned the wrong list: %v", received)
+	}
+
+	// Clear an avoid-list
+	if err = state.SetAvoidList(4, nil); err != nil {
+		t.Fatalf("SetAvoidList() returned an error: %+v", err)
+	}
+	if state.Avoids(4, 5) {
+		t.Errorf("Cleared avoid-list is still applied.")
+	}
+
+	// Restart and check the avoid-lists were persisted
+	reloaded, err := NewState(privateKey, 8, "", "", state.GetGeoBins())
+	if err != nil {
+		t.Fatalf("Failed to create new state: %+v", err)
+	}
+	if err = reloaded.LoadAvoidLists(); err != nil {
+		t.Fatalf("Failed to load avoid-lists: %+v", err)
+	}
+	if received := reloaded.GetAvoidList(1); !reflect.DeepEqual(received, []uint64{2, 3}) {
+		t.Errorf("Reloaded state has the wrong avoid-list: %v", received)
+	}
+	if reloaded.Avoids(4, 5) {
+		t.Errorf("Reloaded state has a cleared avoid-list.")
+	}
+}
+
+// Error path: tests that invalid avoid-lists are rejected.
+func TestNetworkState_SetAvoidList_Invalid(t *testing.T) {
+	var err error
+	PermissioningDb, _, err = NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("Failed to create database: %+v", err)
+	}
+
+	state, _, err := generateTestNetworkState()
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	if err = state.SetAvoidList(1, []uint64{1}); err == nil {
+		t.Errorf("Expected error for an application avoiding itself.")
+	}
+	if err = state.SetAvoidList(1, []uint64{2, 2}); err == nil {
+		t.Errorf("Expected error for a duplicated entry.")
+	}
+	tooLong := make([]uint64, MaxAvoidListSize+1)
+	for i := range tooLong {
+		tooLong[i] = uint64(i + 2)
+	}
+	if err = state.SetAvoidList(1, tooLong); err == nil {
+		t.Errorf("Expected error for an avoid-list over the size cap.")
+	}
+	if len(state.GetAvoidList(1)) != 0 {
+		t.Errorf("Invalid avoid-list was applied.")
+	}
+}
+
+// Tests that GetExcessiveAvoidLists() reports avoid-lists which exclude more
+// than the warning fraction of the network.
+func TestNetworkState_GetExcessiveAvoidLists(t *testing.T) {
+	var err error
+	PermissioningDb, _, err = NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	state, _, err := generateTestNetworkState()
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	state.SetAvoidListWarnFraction(0.25)
+
+	// Five nodes, one per application
+	for i := uint64(1); i <= 5; i++ {
+		err = state.GetNodeMap().AddNode(
+			id.NewIdFromUInt(i, id.Node, t), "", "", "", i)
+		if err != nil {
+			t.Fatalf("Failed to add node: %+v", err)
+		}
+	}
+
+	// Application 1 excludes half of the other nodes, application 2 only one
+	// quarter
+	if err = state.SetAvoidList(1, []uint64{2, 3}); err != nil {
+		t.Fatalf("SetAvoidList() returned an error: %+v", err)
+	}
+	if err = state.SetAvoidList(2, []uint64{4}); err != nil {
+		t.Fatalf("SetAvoidList() returned an error: %+v", err)
+	}
+
+	if excluded := state.GetAvoidListExclusion(1); excluded != 0.5 {
+		t.Errorf("Unexpected exclusion.\nexpected: %f\nreceived: %f",
+			0.5, excluded)
+	}
+
+	expected := map[uint64]float64{1: 0.5}
+	if received := state.GetExcessiveAvoidLists(); !reflect.DeepEqual(received, expected) {
+		t.Errorf("GetExcessiveAvoidLists() returned unexpected results."+
+			"\nexpected: %v\nreceived: %v", expected, received)
+	}
+}
@@ -0,0 +1,149 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Handles tracking how often the nodes of each geographic bin are teamed
+// relative to their share of the active nodes
+
+package storage
+
+import (
+	"gitlab.com/elixxir/registration/storage/node"
+	"sort"
+	"sync"
+)
+
+// Label of the bin of nodes whose ordering does not map to a geographic bin
+const unknownBin = "Unknown"
+
+// Number of rounds tracked when no window is given
+const defaultFairnessWindow = 1000
+
+// BinFairness reports how often the nodes of a geographic bin were teamed
+// within the tracked rounds relative to the bin's share of the active nodes.
+type BinFairness struct {
+	Bin string
+
+	// Team slots filled by the bin's nodes and their share of all slots
+	Slots              uint64
+	ParticipationShare float64
+
+	// Number of active nodes in the bin and their share of all active nodes
+	ActiveNodes uint64
+	PoolShare   float64
+
+	// ParticipationShare relative to PoolShare. 1 when the bin is teamed in
+	// proportion to its active nodes, less than 1 when it is under-served.
+	// 0 when the bin has no active nodes
+	Ratio float64
+}
+
+// binFairness tracks the bins of the teams of the most recent rounds
+type binFairness struct {
+	// Bins of the team of each tracked round, oldest first
+	rounds [][]string
+	counts map[string]uint64
+	slots  uint64
+	mux    sync.Mutex
+}
+
+// GetNodeBin returns the name of the geographic bin the node's ordering maps
+// to.
+func (s *NetworkState) GetNodeBin(n *node.State) string {
+	bin, exists := s.GetCountryBin(n.GetOrdering())
+	if !exists {
+		return unknownBin
+	}
+	return bin.String()
+}
+
+// RecordTeamBins records the bins of the nodes teamed for a round. Only the
+// most recent window rounds are tracked; 0 selects the default window.
+func (s *NetworkState) RecordTeamBins(team []*node.State, window int) {
+	if window <= 0 {
+		window = defaultFairnessWindow
+	}
+
+	bins := make([]string, len(team))
+	for i, n := range team {
+		bins[i] = s.GetNodeBin(n)
+	}
+
+	bf := &s.binFairness
+	bf.mux.Lock()
+	defer bf.mux.Unlock()
+
+	if bf.counts == nil {
+		bf.counts = make(map[string]uint64)
+	}
+	bf.rounds = append(bf.rounds, bins)
+	for _, bin := range bins {
+		bf.counts[bin]++
+	}
+	bf.slots += uint64(len(bins))
+
+	// Drop the rounds which have left the window
+	for len(bf.rounds) > window {
+		for _, bin := range bf.rounds[0] {
+			bf.counts[bin]--
+			if bf.counts[bin] == 0 {
+				delete(bf.counts, bin)
+			}
+		}
+		bf.slots -= uint64(len(bf.rounds[0]))
+		bf.rounds = bf.rounds[1:]
+	}
+}
+
+// GetBinFairness returns the fairness report of every bin which has active
+// nodes or was teamed within the tracked rounds, sorted by bin.
+func (s *NetworkState) GetBinFairness() []BinFairness {
+	active := make(map[string]uint64)
+	totalActive := uint64(0)
+	for _, n := range s.GetNodeMap().GetNodeStates() {
+		if n.GetStatus() == node.Active {
+			active[s.GetNodeBin(n)]++
+			totalActive++
+		}
+	}
+
+	bf := &s.binFairness
+	bf.mux.Lock()
+	counts := make(map[string]uint64, len(bf.counts))
+	for bin, count := range bf.counts {
+		counts[bin] = count
+	}
+	slots := bf.slots
+	bf.mux.Unlock()
+
+	bins := make(map[string]struct{}, len(active)+len(counts))
+	for bin := range active {
+		bins[bin] = struct{}{}
+	}
+	for bin := range counts {
+		bins[bin] = struct{}{}
+	}
+
+	report := make([]BinFairness, 0, len(bins))
+	for bin := range bins {
+		f := BinFairness{Bin: bin, Slots: counts[bin], ActiveNodes: active[bin]}
+		if slots > 0 {
+			f.ParticipationShare = float64(f.Slots) / float64(slots)
+		}
+		if totalActive > 0 {
+			f.PoolShare = float64(f.ActiveNodes) / float64(totalActive)
+		}
+		if f.PoolShare > 0 {
+			f.Ratio = f.ParticipationShare / f.PoolShare
+		}
+		report = append(report, f)
+	}
+
+	sort.Slice(report, func(i, j int) bool {
+		return report[i].Bin < report[j].Bin
+	})
+	return report
+}
@@ -0,0 +1,123 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package storage
+
+import (
+	"crypto/rand"
+	"gitlab.com/elixxir/registration/storage/node"
+	"gitlab.com/xx_network/crypto/signature/rsa"
+	"gitlab.com/xx_network/primitives/id"
+	"gitlab.com/xx_network/primitives/region"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// Creates a state with three active North American nodes, one active Central
+// European node and one banned Central European node.
+func newBinFairnessTestState(t *testing.T) (*NetworkState, []*node.State) {
+	var closeDb func() error
+	var err error
+	PermissioningDb, closeDb, err = NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("Failed to create database: %+v", err)
+	}
+	t.Cleanup(func() { _ = closeDb() })
+
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate private key: %+v", err)
+	}
+	state, err := NewState(privateKey, 8, "", "", region.GetCountryBins())
+	if err != nil {
+		t.Fatalf("Failed to create state: %+v", err)
+	}
+
+	orderings := []string{"US", "US", "US", "DE", "DE"}
+	nodes := make([]*node.State, len(orderings))
+	for i, ordering := range orderings {
+		nid := id.NewIdFromUInt(uint64(i), id.Node, t)
+		if err = state.GetNodeMap().AddNode(nid, ordering, "", "", 0); err != nil {
+			t.Fatalf("Failed to add node: %+v", err)
+		}
+		nodes[i] = state.GetNodeMap().GetNode(nid)
+	}
+	if _, err = nodes[4].Ban(); err != nil {
+		t.Fatalf("Failed to ban node: %+v", err)
+	}
+
+	return state, nodes
+}
+
+// Tests that GetBinFairness reports the share of the team slots of each bin
+// relative to its share of the active nodes.
+func TestNetworkState_GetBinFairness(t *testing.T) {
+	state, nodes := newBinFairnessTestState(t)
+
+	// Before any round, every bin with active nodes is reported unteamed
+	expected := []BinFairness{
+		{Bin: "CentralEurope", ActiveNodes: 1, PoolShare: 0.25},
+		{Bin: "NorthAmerica", ActiveNodes: 3, PoolShare: 0.75},
+	}
+	if report := state.GetBinFairness(); !reflect.DeepEqual(expected, report) {
+		t.Errorf("Unexpected report.\nexpected: %+v\nreceived: %+v",
+			expected, report)
+	}
+
+	state.RecordTeamBins(nodes[:2], 0)
+	state.RecordTeamBins([]*node.State{nodes[2], nodes[3]}, 0)
+
+	report := state.GetBinFairness()
+	if len(report) != 2 {
+		t.Fatalf("Unexpected number of bins: %+v", report)
+	}
+	ce, na := report[0], report[1]
+	if ce.Slots != 1 || ce.ParticipationShare != 0.25 ||
+		math.Abs(ce.Ratio-1) > 1e-9 {
+		t.Errorf("Unexpected CentralEurope report: %+v", ce)
+	}
+	if na.Slots != 3 || na.ParticipationShare != 0.75 ||
+		math.Abs(na.Ratio-1) > 1e-9 {
+		t.Errorf("Unexpected NorthAmerica report: %+v", na)
+	}
+}
+
+// Tests that RecordTeamBins only tracks the most recent rounds of the window
+// and that teamed bins without active nodes are still reported.
+func TestNetworkState_RecordTeamBins_Window(t *testing.T) {
+	state, nodes := newBinFairnessTestState(t)
+
+	state.RecordTeamBins([]*node.State{nodes[4]}, 2)
+	state.RecordTeamBins([]*node.State{nodes[0]}, 2)
+
+	report := state.GetBinFairness()
+	if report[0].Bin != "CentralEurope" || report[0].Slots != 1 ||
+		report[0].ParticipationShare != 0.5 || report[0].Ratio != 2 {
+		t.Errorf("Unexpected CentralEurope report: %+v", report[0])
+	}
+
+	// The round of the banned node leaves the window
+	state.RecordTeamBins([]*node.State{nodes[1]}, 2)
+	report = state.GetBinFairness()
+	if report[0].Slots != 0 || report[0].Ratio != 0 {
+		t.Errorf("Round outside of the window still tracked: %+v", report[0])
+	}
+	if report[1].Slots != 2 || report[1].ParticipationShare != 1 {
+		t.Errorf("Unexpected NorthAmerica report: %+v", report[1])
+	}
+	if len(state.binFairness.rounds) != 2 {
+		t.Errorf("Unexpected number of tracked rounds: %d",
+			len(state.binFairness.rounds))
+	}
+
+	// Unknown orderings are tracked together
+	state.RecordTeamBins([]*node.State{{}}, 2)
+	if state.binFairness.counts[unknownBin] != 1 {
+		t.Errorf("Node without a bin not tracked as %s.", unknownBin)
+	}
+}
@@ -92,8 +92,10 @@ func NewDatabase(username, password, database, address,
 	// Initialize the Database schema
 	// WARNING: Order is important. Do not change without Database testing
 	models := []interface{}{
-		&State{}, &Application{}, &Node{}, roundMetricTable, &Topology{}, &NodeMetric{},
-		&RoundError{}, EphemeralLength{}, ActiveNode{}, GeoBin{},
+		&State{}, &Application{}, &Node{}, &SchedulingConfig{}, roundMetricTable,
+		&Topology{}, &NodeMetric{}, &RoundError{}, EphemeralLength{}, ActiveNode{},
+		GeoBin{}, NodeGroupMember{}, ActiveRound{}, AvoidedApplication{},
+		NodeVersion{}, NodeHealthEvent{}, NdfSnapshot{},
 	}
 
 	for _, model := range models {
@@ -103,8 +105,20 @@ func NewDatabase(username, password, database, address,
 		}
 	}
 
+	err = backfillDateRegistered(db)
+	if err != nil {
+		return Storage{}, func() error { return nil }, err
+	}
+
+	if !useSqlite {
+		err = allowDetachedNodeMetrics(db)
+		if err != nil {
+			return Storage{}, func() error { return nil }, err
+		}
+	}
+
 	jww.INFO.Println("Database backend initialized successfully!")
-	return Storage{&DatabaseImpl{db: db}}, db.Close, nil
+	return Storage{database: &DatabaseImpl{db: db}}, db.Close, nil
 
 }
 
@@ -120,3 +134,41 @@ func setupSqlite(db *gorm.DB) error {
 	}
 	return nil
 }
+
+// Sets the DateRegistered of Nodes registered before it was recorded to the
+// start of their earliest NodeMetric, for Nodes which have one
+func backfillDateRegistered(db *gorm.DB) error {
+	earliestMetric := "SELECT MIN(node_metrics.start_time) FROM node_metrics " +
+		"WHERE node_metrics.node_id = nodes.id"
+	result := db.Exec("UPDATE nodes SET date_registered = ("+earliestMetric+
+		") WHERE (date_registered IS NULL OR date_registered <= ?) "+
+		"AND id IN (SELECT node_id FROM node_metrics)", time.Time{})
+	if result.Error != nil {
+		return errors.WithMessage(result.Error,
+			"Failed to backfill node registration dates")
+	}
+	if result.RowsAffected > 0 {
+		jww.INFO.Printf("Backfilled the registration date of %d nodes "+
+			"from their earliest metric", result.RowsAffected)
+	}
+	return nil
+}
+
+// Drops the NOT NULL constraint on the Node of NodeMetrics from tables which
+// were created with it, so that metrics can be kept after their Node's
+// registration is deleted. AutoMigrate does not change existing columns
+func allowDetachedNodeMetrics(db *gorm.DB) error {
+	err := db.Exec("ALTER TABLE node_metrics ALTER COLUMN node_id " +
+		"DROP NOT NULL").Error
+	if err != nil {
+		return errors.WithMessage(err,
+			"Failed to allow node metrics without a node")
+	}
+	return nil
+}
+
+// Returns an error if the database cannot be reached. Reconnects to the
+// database if its connections were lost
+func (d *DatabaseImpl) Ping() error {
+	return d.db.DB().Ping()
+}
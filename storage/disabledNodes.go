@@ -116,6 +116,13 @@ func (dnl *disabledNodes) getDisabledNodes() []*id.ID {
 	return dnl.nodes
 }
 
+// ParseNodeIdList parses a text file of base64 encoded Node IDs separated by
+// new lines, in the format of the disabled Node list. IDs which cannot be
+// parsed are skipped and returned together in the error.
+func ParseNodeIdList(idList string) ([]*id.ID, error) {
+	return getDisabledNodes(idList)
+}
+
 // getDisabledNodesSet parses the delineated Node ID string into a Set of Node
 // states. Any ID strings that fail to be base64 decoded, unmarshalled, or found
 // in the StateMap are skipped and an error is recorded. All errors are returned
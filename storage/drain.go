@@ -0,0 +1,87 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Handles tracking the draining of the network for a coordinated shutdown
+
+package storage
+
+import (
+	"github.com/pkg/errors"
+	"strconv"
+	"sync/atomic"
+)
+
+// DrainStatus is how far the network is through being drained.
+type DrainStatus uint32
+
+const (
+	// NotDraining is the status of a network creating rounds as normal
+	NotDraining DrainStatus = iota
+
+	// Draining is the status of a network creating no new rounds while the
+	// rounds in flight finish
+	Draining
+
+	// Drained is the final status of a network whose rounds all finished
+	// after it started draining. No rounds are created again.
+	Drained
+)
+
+// String returns the name of the drain status.
+func (ds DrainStatus) String() string {
+	switch ds {
+	case NotDraining:
+		return "not draining"
+	case Draining:
+		return "draining"
+	case Drained:
+		return "drained"
+	default:
+		return "Unknown drain status: " + strconv.Itoa(int(ds))
+	}
+}
+
+// drain tracks the network's drain status, updated atomically
+type drain struct {
+	status uint32
+	signal chan struct{}
+}
+
+// StartDrain stops the creation of new rounds, letting the rounds in flight
+// finish, after which the network is drained. Draining cannot be undone. An
+// error is returned if the network is already draining or drained.
+func (s *NetworkState) StartDrain() error {
+	if !atomic.CompareAndSwapUint32(&s.drain.status, uint32(NotDraining),
+		uint32(Draining)) {
+		return errors.Errorf("Cannot drain the network: it is already %s",
+			s.GetDrainStatus())
+	}
+
+	// Wake the scheduler in case no rounds are in flight
+	select {
+	case s.drain.signal <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// MarkDrained moves a draining network to drained, returning false if it was
+// not draining.
+func (s *NetworkState) MarkDrained() bool {
+	return atomic.CompareAndSwapUint32(&s.drain.status, uint32(Draining),
+		uint32(Drained))
+}
+
+// GetDrainStatus returns how far the network is through being drained.
+func (s *NetworkState) GetDrainStatus() DrainStatus {
+	return DrainStatus(atomic.LoadUint32(&s.drain.status))
+}
+
+// GetDrainChannel returns the channel signalled when draining starts.
+func (s *NetworkState) GetDrainChannel() <-chan struct{} {
+	return s.drain.signal
+}
@@ -0,0 +1,52 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package storage
+
+import (
+	"testing"
+)
+
+// Tests that the network moves from not draining to draining to drained, and
+// never back, and that the scheduler is signalled when draining starts.
+func TestNetworkState_StartDrain(t *testing.T) {
+	s := &NetworkState{drain: drain{signal: make(chan struct{}, 1)}}
+	if s.GetDrainStatus() != NotDraining {
+		t.Errorf("New network is %s.", s.GetDrainStatus())
+	}
+	if s.MarkDrained() {
+		t.Errorf("Network was drained without draining.")
+	}
+
+	if err := s.StartDrain(); err != nil {
+		t.Fatalf("Failed to start draining: %+v", err)
+	}
+	if s.GetDrainStatus() != Draining {
+		t.Errorf("Network is %s after draining started.", s.GetDrainStatus())
+	}
+	select {
+	case <-s.GetDrainChannel():
+	default:
+		t.Errorf("Scheduler was not signalled to drain.")
+	}
+	if err := s.StartDrain(); err == nil {
+		t.Errorf("Network started draining twice.")
+	}
+
+	if !s.MarkDrained() || s.GetDrainStatus() != Drained {
+		t.Errorf("Network is %s after being drained.", s.GetDrainStatus())
+	}
+	if s.MarkDrained() {
+		t.Errorf("Network was drained twice.")
+	}
+	if err := s.StartDrain(); err == nil {
+		t.Errorf("Drained network started draining again.")
+	}
+	if Drained.String() != "drained" {
+		t.Errorf("Unexpected drain status name: %s", Drained)
+	}
+}
@@ -0,0 +1,78 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Handles redaction of sensitive information from round error text
+
+package storage
+
+import (
+	"github.com/pkg/errors"
+	"regexp"
+)
+
+// Replacement strings inserted in place of redacted content
+const (
+	redactedIp     = "[redacted ip]"
+	redactedPath   = "[redacted path]"
+	redactedCustom = "[redacted]"
+)
+
+// redaction pairs a pattern with the text that replaces any match of it
+type redaction struct {
+	pattern     *regexp.Regexp
+	replacement string
+}
+
+// defaultRedactions are always applied by SanitizeError, before any
+// configured patterns
+var defaultRedactions = []redaction{
+	// IPv4 addresses, with an optional port
+	{regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}(?::\d{1,5})?\b`),
+		redactedIp},
+	// Full IPv6 addresses, optionally bracketed with a port
+	{regexp.MustCompile(`\[?\b(?:[0-9a-fA-F]{1,4}:){7}[0-9a-fA-F]{1,4}\b(?:\]:\d{1,5}|\])?`),
+		redactedIp},
+	// Compressed IPv6 addresses (containing "::"), optionally bracketed
+	{regexp.MustCompile(`\[?(?:\b[0-9a-fA-F]{1,4}(?::[0-9a-fA-F]{1,4})*::(?:[0-9a-fA-F]{1,4}(?::[0-9a-fA-F]{1,4})*\b)?|::[0-9a-fA-F]{1,4}(?::[0-9a-fA-F]{1,4})*\b)(?:\]:\d{1,5}|\])?`),
+		redactedIp},
+	// Absolute unix paths, only when at the start of the text or following
+	// whitespace, a quote, an equals sign, or an opening parenthesis
+	{regexp.MustCompile(`(^|[\s"'=(])/[^\s"'(),:]+`),
+		"${1}" + redactedPath},
+	// Absolute windows paths
+	{regexp.MustCompile(`\b[a-zA-Z]:\\[^\s"'(),:]*`),
+		redactedPath},
+}
+
+// CompileRedactionPatterns compiles the list of regular expressions used to
+// redact additional content from round errors. An error is returned if any
+// pattern fails to compile.
+func CompileRedactionPatterns(patterns []string) ([]*regexp.Regexp, error) {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, errors.Errorf("Failed to compile round error "+
+				"redaction pattern %q: %+v", p, err)
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+// SanitizeError masks IP addresses and absolute paths in the given error text,
+// then masks anything matching the passed in patterns. The sanitized text is
+// safe for publishing in round info and for storage.
+func SanitizeError(errStr string, patterns []*regexp.Regexp) string {
+	for _, r := range defaultRedactions {
+		errStr = r.pattern.ReplaceAllString(errStr, r.replacement)
+	}
+	for _, re := range patterns {
+		errStr = re.ReplaceAllLiteralString(errStr, redactedCustom)
+	}
+	return errStr
+}
@@ -0,0 +1,74 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package storage
+
+import (
+	"testing"
+)
+
+// Tests that SanitizeError() redacts IP addresses and absolute paths while
+// leaving the rest of the error intact.
+func TestSanitizeError(t *testing.T) {
+	testValues := []struct {
+		input, expected string
+	}{
+		{"failed to connect to 1.2.3.4:11420: timeout",
+			"failed to connect to " + redactedIp + ": timeout"},
+		{"dial 10.0.0.1 refused", "dial " + redactedIp + " refused"},
+		{"peer 2001:0db8:85a3:0000:0000:8a2e:0370:7334 unreachable",
+			"peer " + redactedIp + " unreachable"},
+		{"peer [fe80::1]:11420 unreachable", "peer " + redactedIp + " unreachable"},
+		{"loopback ::1 used", "loopback " + redactedIp + " used"},
+		{"open /home/node/.xxnetwork/cmix.key: permission denied",
+			"open " + redactedPath + ": permission denied"},
+		{"/opt/xx/bin/server crashed", redactedPath + " crashed"},
+		{`file="/var/lib/gpu.log" missing`, `file="` + redactedPath + `" missing`},
+		{`cannot read C:\Users\node\key.pem`, "cannot read " + redactedPath},
+		{"Round 42 failed at 12:34:56 on version 1.2.3",
+			"Round 42 failed at 12:34:56 on version 1.2.3"},
+		{"precomp failed: 3/4 slots bad", "precomp failed: 3/4 slots bad"},
+		{"", ""},
+	}
+
+	for i, val := range testValues {
+		sanitized := SanitizeError(val.input, nil)
+		if sanitized != val.expected {
+			t.Errorf("SanitizeError() did not return the expected string "+
+				"(%d).\nexpected: %q\nreceived: %q", i, val.expected, sanitized)
+		}
+	}
+}
+
+// Tests that SanitizeError() redacts matches of the passed in patterns.
+func TestSanitizeError_CustomPatterns(t *testing.T) {
+	patterns, err := CompileRedactionPatterns(
+		[]string{`hostname=[^ ]+`, `(?i)secret\w*`})
+	if err != nil {
+		t.Fatalf("Failed to compile patterns: %+v", err)
+	}
+
+	input := "hostname=node1.example.com failed with SecretToken at 1.1.1.1"
+	expected := redactedCustom + " failed with " + redactedCustom + " at " +
+		redactedIp
+
+	sanitized := SanitizeError(input, patterns)
+	if sanitized != expected {
+		t.Errorf("SanitizeError() did not return the expected string."+
+			"\nexpected: %q\nreceived: %q", expected, sanitized)
+	}
+}
+
+// Error path: tests that CompileRedactionPatterns() returns an error for an
+// invalid regular expression.
+func TestCompileRedactionPatterns_Error(t *testing.T) {
+	_, err := CompileRedactionPatterns([]string{`valid`, `(unclosed`})
+	if err == nil {
+		t.Errorf("CompileRedactionPatterns() did not error on an " +
+			"invalid pattern.")
+	}
+}
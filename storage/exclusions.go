@@ -0,0 +1,36 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Handles counting the reasons nodes are left out of teams by the scheduler
+
+package storage
+
+import (
+	"gitlab.com/elixxir/registration/storage/node"
+	"gitlab.com/xx_network/primitives/id"
+	"sync/atomic"
+)
+
+// RecordExclusion counts the node as left out of the team of the round with
+// the given ID for the reason, both for the node and across the network. A
+// node is only counted once per round.
+func (s *NetworkState) RecordExclusion(n *node.State,
+	reason node.ExclusionReason, roundID id.Round) {
+	if n.RecordExclusion(reason, roundID) {
+		atomic.AddUint64(&s.exclusions[reason], 1)
+	}
+}
+
+// GetExclusions returns the number of times nodes were left out of a team for
+// each reason across the network, indexed by node.ExclusionReason.
+func (s *NetworkState) GetExclusions() [node.NumExclusionReasons]uint64 {
+	var totals [node.NumExclusionReasons]uint64
+	for i := range totals {
+		totals[i] = atomic.LoadUint64(&s.exclusions[i])
+	}
+	return totals
+}
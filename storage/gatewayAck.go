@@ -0,0 +1,66 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Handles tracking how far the gateways of the network have confirmed
+// processing round updates
+
+package storage
+
+import (
+	"gitlab.com/elixxir/registration/storage/node"
+	"gitlab.com/xx_network/primitives/id"
+)
+
+// GatewayAckStatus describes how far the gateways of the active nodes have
+// confirmed processing round updates.
+type GatewayAckStatus struct {
+	// Lowest round update ID confirmed by the gateway of every active node
+	// which reports acknowledgments. Every update up to it, such as the
+	// completion of a round, was received by those gateways
+	Watermark uint64
+	// Number of active nodes reporting acknowledgments. Watermark is only
+	// meaningful when it is not zero
+	Reporting int
+
+	// Newest round update ID
+	LatestUpdate uint64
+	// Active nodes whose acknowledgment lags the newest update by more than
+	// the threshold
+	Lagging []*id.ID
+}
+
+// GetGatewayAckStatus returns the lowest round update ID acknowledged by the
+// gateways of the active nodes, along with the nodes whose acknowledgment
+// lags the newest update by more than lagThreshold updates. Nodes which never
+// reported an acknowledgment are not counted. A threshold of 0 lists no nodes
+// as lagging.
+func (s *NetworkState) GetGatewayAckStatus(lagThreshold uint64) GatewayAckStatus {
+	var status GatewayAckStatus
+	if latest := s.GetLastUpdateID(); latest > 0 {
+		status.LatestUpdate = uint64(latest)
+	}
+
+	for _, n := range s.GetNodeMap().GetNodeStates() {
+		if n.GetStatus() != node.Active {
+			continue
+		}
+		ack, reported := n.GetGatewayAck()
+		if !reported {
+			continue
+		}
+		if status.Reporting == 0 || ack < status.Watermark {
+			status.Watermark = ack
+		}
+		status.Reporting++
+
+		if lagThreshold > 0 && ack < status.LatestUpdate &&
+			status.LatestUpdate-ack > lagThreshold {
+			status.Lagging = append(status.Lagging, n.GetID())
+		}
+	}
+	return status
+}
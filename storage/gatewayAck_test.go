@@ -0,0 +1,89 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package storage
+
+import (
+	pb "gitlab.com/elixxir/comms/mixmessages"
+	"gitlab.com/elixxir/primitives/states"
+	"gitlab.com/elixxir/registration/storage/node"
+	"gitlab.com/xx_network/primitives/id"
+	"testing"
+	"time"
+)
+
+// Tests that the watermark is the lowest acknowledgment of the active nodes
+// reporting one, and that only nodes lagging past the threshold are listed.
+func TestNetworkState_GetGatewayAckStatus(t *testing.T) {
+	state := newNdfHistoryTestState(t, 0, "")
+
+	if status := state.GetGatewayAckStatus(5); status.Reporting != 0 {
+		t.Errorf("Acknowledgments found before any were reported: %+v", status)
+	}
+
+	for i := 0; i < 20; i++ {
+		err := state.AddRoundUpdate(&pb.RoundInfo{
+			ID:         1,
+			State:      uint32(states.PRECOMPUTING),
+			Timestamps: make([]uint64, states.NUM_STATES),
+		})
+		if err != nil {
+			t.Fatalf("Failed to add round update: %+v", err)
+		}
+	}
+	// Updates are published asynchronously
+	next, err := state.GetUpdateID()
+	if err != nil {
+		t.Fatalf("Failed to get update ID: %+v", err)
+	}
+	for i := 0; uint64(state.GetLastUpdateID())+1 < next; i++ {
+		if i == 100 {
+			t.Fatalf("Round updates were not published.")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	latest := next - 1
+
+	acks := []int64{int64(latest), int64(latest) - 3, int64(latest) - 10, -1, 0}
+	nodes := make([]*node.State, len(acks))
+	for i, ack := range acks {
+		nid := id.NewIdFromUInt(uint64(i), id.Node, t)
+		if err := state.GetNodeMap().AddNode(nid, "US", "", "", 0); err != nil {
+			t.Fatalf("Failed to add node: %+v", err)
+		}
+		nodes[i] = state.GetNodeMap().GetNode(nid)
+		// A negative acknowledgment is a node which never reported one
+		if ack >= 0 {
+			nodes[i].SetGatewayAck(uint64(ack))
+		}
+	}
+	// The node furthest behind is not active
+	nodes[4].SetInactive()
+
+	status := state.GetGatewayAckStatus(5)
+	if status.LatestUpdate != latest || status.Reporting != 3 ||
+		status.Watermark != latest-10 {
+		t.Errorf("Unexpected acknowledgment status: %+v", status)
+	}
+	if len(status.Lagging) != 1 || !status.Lagging[0].Cmp(nodes[2].GetID()) {
+		t.Errorf("Unexpected lagging nodes: %v", status.Lagging)
+	}
+
+	// The watermark rises once the lagging gateway catches up
+	nodes[2].SetGatewayAck(latest)
+	status = state.GetGatewayAckStatus(5)
+	if status.Watermark != latest-3 || len(status.Lagging) != 0 {
+		t.Errorf("Unexpected acknowledgment status after catching up: %+v",
+			status)
+	}
+
+	// Nothing lags without a threshold
+	nodes[2].SetGatewayAck(0)
+	if status = state.GetGatewayAckStatus(0); len(status.Lagging) != 0 {
+		t.Errorf("Nodes lagging without a threshold: %v", status.Lagging)
+	}
+}
@@ -0,0 +1,75 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package storage
+
+// Contains the mapping of countries to the geographic bins nodes are binned
+// into, which can be replaced while running
+
+import (
+	"encoding/json"
+	"github.com/pkg/errors"
+	"gitlab.com/xx_network/primitives/region"
+	"sort"
+)
+
+// SetGeoBins replaces the mapping of countries to the geographic bins nodes
+// are binned into. Nodes are binned by the country they are ordered by, so the
+// new mapping applies to every node from then on. The map must not be
+// modified once it is set.
+func (s *NetworkState) SetGeoBins(geoBins map[string]region.GeoBin) {
+	s.geoBinsMux.Lock()
+	defer s.geoBinsMux.Unlock()
+	s.geoBins = geoBins
+}
+
+// GetCountryBin returns the geographic bin the country maps to. Returns false
+// if the country is not mapped to a bin.
+func (s *NetworkState) GetCountryBin(country string) (region.GeoBin, bool) {
+	s.geoBinsMux.RLock()
+	defer s.geoBinsMux.RUnlock()
+	bin, exists := s.geoBins[country]
+	return bin, exists
+}
+
+// GetUnmappedCountries returns the sorted countries nodes in the node map are
+// ordered by which are not mapped to a bin in the given mapping.
+func (s *NetworkState) GetUnmappedCountries(geoBins map[string]region.GeoBin) []string {
+	unmapped := make(map[string]bool)
+	for _, n := range s.GetNodeMap().GetNodeStates() {
+		country := n.GetOrdering()
+		if _, exists := geoBins[country]; !exists && country != "" {
+			unmapped[country] = true
+		}
+	}
+
+	countries := make([]string, 0, len(unmapped))
+	for country := range unmapped {
+		countries = append(countries, country)
+	}
+	sort.Strings(countries)
+	return countries
+}
+
+// ParseGeoBins parses a JSON object mapping alpha-2 country codes to the names
+// of the bins they are in, e.g. {"US": "NorthAmerica", "CA": "NorthAmerica"}.
+func ParseGeoBins(data []byte) (map[string]region.GeoBin, error) {
+	geoBins := make(map[string]region.GeoBin)
+	if err := json.Unmarshal(data, &geoBins); err != nil {
+		return nil, errors.Errorf("Failed to parse GeoBins: %+v", err)
+	}
+	if len(geoBins) == 0 {
+		return nil, errors.New("GeoBins do not map any countries")
+	}
+	for country := range geoBins {
+		if len(country) != 2 {
+			return nil, errors.Errorf("GeoBins map %q, which is not an "+
+				"alpha-2 country code", country)
+		}
+	}
+	return geoBins, nil
+}
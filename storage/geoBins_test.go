@@ -0,0 +1,89 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package storage
+
+import (
+	"crypto/rand"
+	"gitlab.com/xx_network/crypto/signature/rsa"
+	"gitlab.com/xx_network/primitives/id"
+	"gitlab.com/xx_network/primitives/region"
+	"reflect"
+	"testing"
+)
+
+// Tests that ParseGeoBins parses a mapping of countries to bin names and
+// rejects mappings with unknown bins or keys which are not country codes.
+func TestParseGeoBins(t *testing.T) {
+	geoBins, err := ParseGeoBins(
+		[]byte(`{"US": "NorthAmerica", "DE": "NorthAmerica", "JP": "Oceania"}`))
+	if err != nil {
+		t.Fatalf("ParseGeoBins() returned an error: %+v", err)
+	}
+	expected := map[string]region.GeoBin{"US": region.NorthAmerica,
+		"DE": region.NorthAmerica, "JP": region.Oceania}
+	if !reflect.DeepEqual(geoBins, expected) {
+		t.Errorf("Unexpected GeoBins.\nexpected: %v\nreceived: %v",
+			expected, geoBins)
+	}
+
+	for _, data := range []string{`{"US": "Atlantis"}`, `{"USA": "NorthAmerica"}`,
+		`{}`, `["US"]`} {
+		if _, err = ParseGeoBins([]byte(data)); err == nil {
+			t.Errorf("ParseGeoBins() did not return an error for %s.", data)
+		}
+	}
+}
+
+// Tests that replacing the GeoBins changes the bins nodes are in, and that the
+// countries of nodes a mapping does not map are found.
+func TestNetworkState_SetGeoBins(t *testing.T) {
+	var closeDb func() error
+	var err error
+	PermissioningDb, closeDb, err = NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("Failed to create database: %+v", err)
+	}
+	t.Cleanup(func() { _ = closeDb() })
+
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate private key: %+v", err)
+	}
+	state, err := NewState(privateKey, 8, "", "", region.GetCountryBins())
+	if err != nil {
+		t.Fatalf("Failed to create state: %+v", err)
+	}
+	for i, country := range []string{"US", "DE", "JP", "JP"} {
+		nid := id.NewIdFromUInt(uint64(i), id.Node, t)
+		if err = state.GetNodeMap().AddNode(nid, country, "", "", 0); err != nil {
+			t.Fatalf("Failed to add node: %+v", err)
+		}
+	}
+
+	geoBins := map[string]region.GeoBin{"US": region.Oceania, "DE": region.Oceania}
+	if unmapped := state.GetUnmappedCountries(geoBins); !reflect.DeepEqual(
+		unmapped, []string{"JP"}) {
+		t.Errorf("Unexpected unmapped countries: %v", unmapped)
+	}
+	if unmapped := state.GetUnmappedCountries(region.GetCountryBins()); len(unmapped) != 0 {
+		t.Errorf("Built-in GeoBins do not map countries %v.", unmapped)
+	}
+
+	state.SetGeoBins(geoBins)
+	expected := map[string]string{"US": "Oceania", "DE": "Oceania",
+		"JP": unknownBin}
+	for _, n := range state.GetNodeMap().GetNodeStates() {
+		if bin := state.GetNodeBin(n); bin != expected[n.GetOrdering()] {
+			t.Errorf("Node in %s is in bin %s, expected %s.",
+				n.GetOrdering(), bin, expected[n.GetOrdering()])
+		}
+	}
+	if !reflect.DeepEqual(state.GetGeoBins(), geoBins) {
+		t.Errorf("GeoBins were not replaced.")
+	}
+}
@@ -0,0 +1,206 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Handles monitoring the database connection and running in a degraded mode
+// while the database cannot be reached
+
+package storage
+
+import (
+	"github.com/pkg/errors"
+	jww "github.com/spf13/jwalterweatherman"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrStorageUnavailable is returned by reads and writes which cannot be
+// buffered while the database cannot be reached
+var ErrStorageUnavailable = errors.New("storage unavailable: the database " +
+	"cannot be reached")
+
+// Defaults used when the health monitor parameters are not set
+const (
+	defaultHealthCheckInterval   = 10 * time.Second
+	defaultMaxReconnectBackoff   = 2 * time.Minute
+	defaultDegradedWriteCapacity = 10000
+)
+
+// bufferedWrite is a write held while the database cannot be reached
+type bufferedWrite struct {
+	description string
+	write       func() error
+}
+
+// dbHealth tracks whether the database can be reached and holds the writes
+// buffered while it cannot
+type dbHealth struct {
+	// Set to 1 while the database cannot be reached. Only changed while
+	// holding mux so that no write is buffered after the buffer is flushed
+	degraded uint32
+
+	buffer   []bufferedWrite
+	capacity int
+	dropped  uint64
+
+	mux sync.Mutex
+}
+
+// isDegraded returns true if the database cannot currently be reached.
+func (h *dbHealth) isDegraded() bool {
+	return atomic.LoadUint32(&h.degraded) == 1
+}
+
+// bufferIfDegraded buffers the write if the database cannot be reached.
+// Returns true if the write was buffered.
+func (h *dbHealth) bufferIfDegraded(w bufferedWrite) bool {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	if atomic.LoadUint32(&h.degraded) == 0 {
+		return false
+	}
+	h.push(w)
+	return true
+}
+
+// degrade switches into degraded mode and buffers the write which failed.
+func (h *dbHealth) degrade(w bufferedWrite) {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	if atomic.SwapUint32(&h.degraded, 1) == 0 {
+		jww.ERROR.Printf("Failed to write %s: the database cannot be "+
+			"reached, entering degraded mode", w.description)
+	}
+	h.push(w)
+}
+
+// push appends the write to the buffer, dropping the oldest buffered write if
+// the buffer is full. Must be called while holding mux.
+func (h *dbHealth) push(w bufferedWrite) {
+	if len(h.buffer) >= h.capacity {
+		jww.WARN.Printf("Degraded mode write buffer is full, dropping "+
+			"buffered %s", h.buffer[0].description)
+		h.buffer = h.buffer[1:]
+		h.dropped++
+	}
+	h.buffer = append(h.buffer, w)
+}
+
+// flush writes every buffered write in order and leaves degraded mode once the
+// buffer is empty. If a write fails, it is kept at the front of the buffer and
+// the error is returned.
+func (h *dbHealth) flush() error {
+	for {
+		h.mux.Lock()
+		if len(h.buffer) == 0 {
+			atomic.StoreUint32(&h.degraded, 0)
+			h.mux.Unlock()
+			return nil
+		}
+		next := h.buffer[0]
+		h.buffer = h.buffer[1:]
+		h.mux.Unlock()
+
+		if err := next.write(); err != nil {
+			h.mux.Lock()
+			h.buffer = append([]bufferedWrite{next}, h.buffer...)
+			h.mux.Unlock()
+			return errors.WithMessagef(err, "Failed to flush buffered %s",
+				next.description)
+		}
+	}
+}
+
+// StartHealthMonitor wraps the database so that, when it cannot be reached,
+// metric and activity writes are buffered and every other call returns
+// ErrStorageUnavailable instead of reaching it. The database is pinged every
+// interval, and while it cannot be reached reconnection is attempted with a
+// backoff which doubles up to maxBackoff. Once it can be reached, the buffered
+// writes are flushed and normal operation is restored. At most capacity writes
+// are buffered, after which the oldest are dropped. Zero values select the
+// defaults. The monitor runs until the quit channel is signalled. Must be
+// called before the Storage is used concurrently.
+func (s *Storage) StartHealthMonitor(interval, maxBackoff time.Duration,
+	capacity int, quit chan struct{}) {
+	if interval == 0 {
+		interval = defaultHealthCheckInterval
+	}
+	if maxBackoff == 0 {
+		maxBackoff = defaultMaxReconnectBackoff
+	}
+	if capacity == 0 {
+		capacity = defaultDegradedWriteCapacity
+	}
+
+	s.health = &dbHealth{capacity: capacity}
+	db := s.database
+	s.database = &monitoredDatabase{database: db, health: s.health}
+
+	go s.health.monitor(db.Ping, interval, maxBackoff, quit)
+}
+
+// IsDegraded returns true if the health monitor has found that the database
+// cannot be reached and storage is running in degraded mode.
+func (s *Storage) IsDegraded() bool {
+	return s.health != nil && s.health.isDegraded()
+}
+
+// GetDroppedWrites returns the number of writes dropped because the degraded
+// mode write buffer was full.
+func (s *Storage) GetDroppedWrites() uint64 {
+	if s.health == nil {
+		return 0
+	}
+	s.health.mux.Lock()
+	defer s.health.mux.Unlock()
+	return s.health.dropped
+}
+
+// monitor pings the database every interval until the quit channel is
+// signalled, switching into and out of degraded mode as it becomes
+// unreachable and reachable again.
+func (h *dbHealth) monitor(ping func() error, interval, maxBackoff time.Duration,
+	quit chan struct{}) {
+	delay := interval
+	for {
+		timer := time.NewTimer(delay)
+		select {
+		case <-quit:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		err := ping()
+		if err == nil && h.isDegraded() {
+			err = h.flush()
+			if err == nil {
+				jww.INFO.Printf("Database connection restored, leaving " +
+					"degraded mode")
+			}
+		}
+
+		if err == nil {
+			delay = interval
+			continue
+		}
+
+		h.mux.Lock()
+		if atomic.SwapUint32(&h.degraded, 1) == 0 {
+			jww.ERROR.Printf("Database cannot be reached, entering "+
+				"degraded mode: %+v", err)
+		}
+		h.mux.Unlock()
+
+		delay *= 2
+		if delay > maxBackoff {
+			delay = maxBackoff
+		}
+		jww.WARN.Printf("Failed to reach the database, attempting to "+
+			"reconnect in %s: %+v", delay, err)
+	}
+}
@@ -0,0 +1,461 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Handles the database wrapper used while the health monitor is running
+
+package storage
+
+import (
+	"gitlab.com/elixxir/registration/storage/node"
+	"gitlab.com/xx_network/primitives/id"
+	"time"
+)
+
+// monitoredDatabase wraps a database so that calls do not reach it while the
+// health monitor has found it cannot be reached. Writes whose result is not
+// needed by the caller are buffered instead and flushed once it is restored.
+type monitoredDatabase struct {
+	database
+	health *dbHealth
+}
+
+// bufferedWrite calls write unless the database cannot be reached, in which
+// case the write is buffered. A write which fails because the database has
+// become unreachable is also buffered, and the database is switched into
+// degraded mode.
+func (m *monitoredDatabase) bufferedWrite(description string, write func() error) error {
+	w := bufferedWrite{description: description, write: write}
+	if m.health.bufferIfDegraded(w) {
+		return nil
+	}
+
+	err := write()
+	if err != nil && m.database.Ping() != nil {
+		m.health.degrade(w)
+		return nil
+	}
+	return err
+}
+
+// check returns ErrStorageUnavailable if the database cannot be reached.
+func (m *monitoredDatabase) check() error {
+	if m.health.isDegraded() {
+		return ErrStorageUnavailable
+	}
+	return nil
+}
+
+func (m *monitoredDatabase) InsertNodeMetric(metric *NodeMetric) error {
+	return m.bufferedWrite("node metric", func() error {
+		return m.database.InsertNodeMetric(metric)
+	})
+}
+
+func (m *monitoredDatabase) DeleteNodeMetricsBefore(cutoff time.Time) (int64, error) {
+	if err := m.check(); err != nil {
+		return 0, err
+	}
+	return m.database.DeleteNodeMetricsBefore(cutoff)
+}
+
+func (m *monitoredDatabase) GetNodeUptimes(since time.Time) (map[id.ID]float64, error) {
+	if err := m.check(); err != nil {
+		return nil, err
+	}
+	return m.database.GetNodeUptimes(since)
+}
+
+func (m *monitoredDatabase) InsertRoundMetric(metric *RoundMetric, topology [][]byte) error {
+	return m.bufferedWrite("round metric", func() error {
+		return m.database.InsertRoundMetric(metric, topology)
+	})
+}
+
+func (m *monitoredDatabase) InsertRoundError(roundId id.Round, errStr, rawErrStr string) error {
+	return m.bufferedWrite("round error", func() error {
+		return m.database.InsertRoundError(roundId, errStr, rawErrStr)
+	})
+}
+
+func (m *monitoredDatabase) InsertCappedRoundError(roundId id.Round, errStr,
+	rawErrStr string, limit uint) error {
+	return m.bufferedWrite("round error", func() error {
+		return m.database.InsertCappedRoundError(roundId, errStr, rawErrStr, limit)
+	})
+}
+
+func (m *monitoredDatabase) updateLastActive(ids [][]byte, lastActive time.Time) error {
+	return m.bufferedWrite("node activity", func() error {
+		return m.database.updateLastActive(ids, lastActive)
+	})
+}
+
+func (m *monitoredDatabase) UpsertState(state *State) error {
+	if err := m.check(); err != nil {
+		return err
+	}
+	return m.database.UpsertState(state)
+}
+
+func (m *monitoredDatabase) GetStateValue(key string) (string, error) {
+	if err := m.check(); err != nil {
+		return "", err
+	}
+	return m.database.GetStateValue(key)
+}
+
+func (m *monitoredDatabase) GetLatestEphemeralLength() (*EphemeralLength, error) {
+	if err := m.check(); err != nil {
+		return nil, err
+	}
+	return m.database.GetLatestEphemeralLength()
+}
+
+func (m *monitoredDatabase) GetEphemeralLengths() ([]*EphemeralLength, error) {
+	if err := m.check(); err != nil {
+		return nil, err
+	}
+	return m.database.GetEphemeralLengths()
+}
+
+func (m *monitoredDatabase) InsertEphemeralLength(length *EphemeralLength) error {
+	if err := m.check(); err != nil {
+		return err
+	}
+	return m.database.InsertEphemeralLength(length)
+}
+
+func (m *monitoredDatabase) GetEarliestRound(cutoff time.Duration) (id.Round, time.Time, error) {
+	if err := m.check(); err != nil {
+		return 0, time.Time{}, err
+	}
+	return m.database.GetEarliestRound(cutoff)
+}
+
+func (m *monitoredDatabase) getBins() ([]*GeoBin, error) {
+	if err := m.check(); err != nil {
+		return nil, err
+	}
+	return m.database.getBins()
+}
+
+func (m *monitoredDatabase) UpsertActiveRound(activeRound *ActiveRound) error {
+	if err := m.check(); err != nil {
+		return err
+	}
+	return m.database.UpsertActiveRound(activeRound)
+}
+
+func (m *monitoredDatabase) DeleteActiveRound(roundId id.Round) error {
+	if err := m.check(); err != nil {
+		return err
+	}
+	return m.database.DeleteActiveRound(roundId)
+}
+
+func (m *monitoredDatabase) GetActiveRounds() ([]*ActiveRound, error) {
+	if err := m.check(); err != nil {
+		return nil, err
+	}
+	return m.database.GetActiveRounds()
+}
+
+func (m *monitoredDatabase) GetRoundLatencyPercentiles(window time.Duration) (p50, p95, p99 time.Duration, err error) {
+	if err = m.check(); err != nil {
+		return 0, 0, 0, err
+	}
+	return m.database.GetRoundLatencyPercentiles(window)
+}
+
+func (m *monitoredDatabase) GetStragglerStats(since time.Time) ([]*StragglerStats, error) {
+	if err := m.check(); err != nil {
+		return nil, err
+	}
+	return m.database.GetStragglerStats(since)
+}
+
+func (m *monitoredDatabase) GetNodeRoundParticipation(nodeId *id.ID, start, end time.Time) (uint64, error) {
+	if err := m.check(); err != nil {
+		return 0, err
+	}
+	return m.database.GetNodeRoundParticipation(nodeId, start, end)
+}
+
+func (m *monitoredDatabase) GetRoundTopology(roundId id.Round) ([]*id.ID, error) {
+	if err := m.check(); err != nil {
+		return nil, err
+	}
+	return m.database.GetRoundTopology(roundId)
+}
+
+func (m *monitoredDatabase) InsertNdfSnapshot(snapshot *NdfSnapshot) error {
+	if err := m.check(); err != nil {
+		return err
+	}
+	return m.database.InsertNdfSnapshot(snapshot)
+}
+
+func (m *monitoredDatabase) GetNdfSnapshotAt(t time.Time) (*NdfSnapshot, error) {
+	if err := m.check(); err != nil {
+		return nil, err
+	}
+	return m.database.GetNdfSnapshotAt(t)
+}
+
+func (m *monitoredDatabase) GetNdfSnapshotByHash(hash []byte) (*NdfSnapshot, error) {
+	if err := m.check(); err != nil {
+		return nil, err
+	}
+	return m.database.GetNdfSnapshotByHash(hash)
+}
+
+func (m *monitoredDatabase) DeleteNdfSnapshotsBefore(cutoff time.Time) error {
+	if err := m.check(); err != nil {
+		return err
+	}
+	return m.database.DeleteNdfSnapshotsBefore(cutoff)
+}
+
+func (m *monitoredDatabase) InsertSchedulingConfig(config []byte) (uint64, error) {
+	if err := m.check(); err != nil {
+		return 0, err
+	}
+	return m.database.InsertSchedulingConfig(config)
+}
+
+func (m *monitoredDatabase) GetRoundSchedulingConfig(roundId id.Round) (*SchedulingConfig, error) {
+	if err := m.check(); err != nil {
+		return nil, err
+	}
+	return m.database.GetRoundSchedulingConfig(roundId)
+}
+
+func (m *monitoredDatabase) InsertApplication(application *Application, unregisteredNode *Node) error {
+	if err := m.check(); err != nil {
+		return err
+	}
+	return m.database.InsertApplication(application, unregisteredNode)
+}
+
+func (m *monitoredDatabase) GetApplication(appId uint64) (*Application, error) {
+	if err := m.check(); err != nil {
+		return nil, err
+	}
+	return m.database.GetApplication(appId)
+}
+
+func (m *monitoredDatabase) GetApplicationsByTeam(team string) ([]*Application, error) {
+	if err := m.check(); err != nil {
+		return nil, err
+	}
+	return m.database.GetApplicationsByTeam(team)
+}
+
+func (m *monitoredDatabase) GetApplicationsByNetwork(network string) ([]*Application, error) {
+	if err := m.check(); err != nil {
+		return nil, err
+	}
+	return m.database.GetApplicationsByNetwork(network)
+}
+
+func (m *monitoredDatabase) ReRegisterNode(oldId, newId *id.ID, salt []byte, code, serverAddr,
+	serverCert, gatewayAddress, gatewayCert string) error {
+	if err := m.check(); err != nil {
+		return err
+	}
+	return m.database.ReRegisterNode(oldId, newId, salt, code, serverAddr,
+		serverCert, gatewayAddress, gatewayCert)
+}
+
+func (m *monitoredDatabase) DeleteNodeRegistration(code string, purgeMetrics bool) error {
+	if err := m.check(); err != nil {
+		return err
+	}
+	return m.database.DeleteNodeRegistration(code, purgeMetrics)
+}
+
+func (m *monitoredDatabase) RegisterNode(id *id.ID, salt []byte, code, serverAddr,
+	serverCert, gatewayAddress, gatewayCert, source string) error {
+	if err := m.check(); err != nil {
+		return err
+	}
+	return m.database.RegisterNode(id, salt, code, serverAddr, serverCert,
+		gatewayAddress, gatewayCert, source)
+}
+
+func (m *monitoredDatabase) UpdateNodeAddresses(id *id.ID, nodeAddr, gwAddr string) error {
+	if err := m.check(); err != nil {
+		return err
+	}
+	return m.database.UpdateNodeAddresses(id, nodeAddr, gwAddr)
+}
+
+func (m *monitoredDatabase) UpdateNodeSequence(id *id.ID, sequence string) error {
+	if err := m.check(); err != nil {
+		return err
+	}
+	return m.database.UpdateNodeSequence(id, sequence)
+}
+
+func (m *monitoredDatabase) UpdateNodePublicAddress(id *id.ID, publicAddr string) error {
+	if err := m.check(); err != nil {
+		return err
+	}
+	return m.database.UpdateNodePublicAddress(id, publicAddr)
+}
+
+func (m *monitoredDatabase) UpdateNodeGatewayClientPort(id *id.ID, port uint32) error {
+	if err := m.check(); err != nil {
+		return err
+	}
+	return m.database.UpdateNodeGatewayClientPort(id, port)
+}
+
+func (m *monitoredDatabase) UpdateNodeConnectivity(id *id.ID, connectivity uint32) error {
+	if err := m.check(); err != nil {
+		return err
+	}
+	return m.database.UpdateNodeConnectivity(id, connectivity)
+}
+
+func (m *monitoredDatabase) UpdateNodeStatus(id *id.ID, status node.Status) error {
+	if err := m.check(); err != nil {
+		return err
+	}
+	return m.database.UpdateNodeStatus(id, status)
+}
+
+func (m *monitoredDatabase) UpdateNodeProbation(id *id.ID, onProbation bool,
+	rounds uint32, since time.Time) error {
+	if err := m.check(); err != nil {
+		return err
+	}
+	return m.database.UpdateNodeProbation(id, onProbation, rounds, since)
+}
+
+func (m *monitoredDatabase) UpdateNodeVersions(id *id.ID, serverVersion,
+	gatewayVersion string, timestamp time.Time) error {
+	if err := m.check(); err != nil {
+		return err
+	}
+	return m.database.UpdateNodeVersions(id, serverVersion, gatewayVersion,
+		timestamp)
+}
+
+func (m *monitoredDatabase) GetNodeVersionHistory(id *id.ID) ([]*NodeVersion, error) {
+	if err := m.check(); err != nil {
+		return nil, err
+	}
+	return m.database.GetNodeVersionHistory(id)
+}
+
+func (m *monitoredDatabase) InsertNodeHealthEvent(event *NodeHealthEvent) error {
+	if err := m.check(); err != nil {
+		return err
+	}
+	return m.database.InsertNodeHealthEvent(event)
+}
+
+func (m *monitoredDatabase) GetNodeHealthEvents(id *id.ID,
+	since time.Time) ([]*NodeHealthEvent, error) {
+	if err := m.check(); err != nil {
+		return nil, err
+	}
+	return m.database.GetNodeHealthEvents(id, since)
+}
+
+func (m *monitoredDatabase) UpdateGeoIP(appId uint64, location, geoBin, gpsLocation string) error {
+	if err := m.check(); err != nil {
+		return err
+	}
+	return m.database.UpdateGeoIP(appId, location, geoBin, gpsLocation)
+}
+
+func (m *monitoredDatabase) GetNode(code string) (*Node, error) {
+	if err := m.check(); err != nil {
+		return nil, err
+	}
+	return m.database.GetNode(code)
+}
+
+func (m *monitoredDatabase) GetNodes() ([]*Node, error) {
+	if err := m.check(); err != nil {
+		return nil, err
+	}
+	return m.database.GetNodes()
+}
+
+func (m *monitoredDatabase) GetNodeById(id *id.ID) (*Node, error) {
+	if err := m.check(); err != nil {
+		return nil, err
+	}
+	return m.database.GetNodeById(id)
+}
+
+func (m *monitoredDatabase) GetNodesByStatus(status node.Status) ([]*Node, error) {
+	if err := m.check(); err != nil {
+		return nil, err
+	}
+	return m.database.GetNodesByStatus(status)
+}
+
+func (m *monitoredDatabase) GetRegistrationsByTimeRange(start, end time.Time) ([]*Node, error) {
+	if err := m.check(); err != nil {
+		return nil, err
+	}
+	return m.database.GetRegistrationsByTimeRange(start, end)
+}
+
+func (m *monitoredDatabase) GetNodesPage(filter NodeFilter, afterCode string,
+	limit int) ([]*Node, int, error) {
+	if err := m.check(); err != nil {
+		return nil, 0, err
+	}
+	return m.database.GetNodesPage(filter, afterCode, limit)
+}
+
+func (m *monitoredDatabase) GetActiveNodes() ([]*ActiveNode, error) {
+	if err := m.check(); err != nil {
+		return nil, err
+	}
+	return m.database.GetActiveNodes()
+}
+
+func (m *monitoredDatabase) UpsertNodeGroup(name string, members []*id.ID) error {
+	if err := m.check(); err != nil {
+		return err
+	}
+	return m.database.UpsertNodeGroup(name, members)
+}
+
+func (m *monitoredDatabase) DeleteNodeGroup(name string) error {
+	if err := m.check(); err != nil {
+		return err
+	}
+	return m.database.DeleteNodeGroup(name)
+}
+
+func (m *monitoredDatabase) GetNodeGroups() (map[string][]*id.ID, error) {
+	if err := m.check(); err != nil {
+		return nil, err
+	}
+	return m.database.GetNodeGroups()
+}
+
+func (m *monitoredDatabase) UpsertAvoidList(applicationId uint64, avoided []uint64) error {
+	if err := m.check(); err != nil {
+		return err
+	}
+	return m.database.UpsertAvoidList(applicationId, avoided)
+}
+
+func (m *monitoredDatabase) GetAvoidLists() (map[uint64][]uint64, error) {
+	if err := m.check(); err != nil {
+		return nil, err
+	}
+	return m.database.GetAvoidLists()
+}
@@ -0,0 +1,213 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package storage
+
+import (
+	"github.com/pkg/errors"
+	"gitlab.com/xx_network/primitives/id"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// outageDatabase wraps a database which can be taken down and brought back
+type outageDatabase struct {
+	database
+	down uint32
+}
+
+func (o *outageDatabase) setDown(down bool) {
+	if down {
+		atomic.StoreUint32(&o.down, 1)
+	} else {
+		atomic.StoreUint32(&o.down, 0)
+	}
+}
+
+func (o *outageDatabase) fail() error {
+	if atomic.LoadUint32(&o.down) == 1 {
+		return errors.New("connection refused")
+	}
+	return nil
+}
+
+func (o *outageDatabase) Ping() error {
+	return o.fail()
+}
+
+func (o *outageDatabase) InsertNodeMetric(metric *NodeMetric) error {
+	if err := o.fail(); err != nil {
+		return err
+	}
+	return o.database.InsertNodeMetric(metric)
+}
+
+func (o *outageDatabase) GetNodes() ([]*Node, error) {
+	if err := o.fail(); err != nil {
+		return nil, err
+	}
+	return o.database.GetNodes()
+}
+
+// newOutageStorage returns a Storage running the health monitor over a
+// database which can be taken down, along with a node to write metrics for.
+func newOutageStorage(t *testing.T, capacity int) (*Storage, *outageDatabase, *id.ID) {
+	db, dc, err := NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("Failed to create database: %+v", err)
+	}
+
+	nodeId := id.NewIdFromString("node", id.Node, t)
+	err = db.InsertApplication(&Application{Id: 1}, &Node{
+		Code:          "AAAA",
+		Id:            nodeId.Bytes(),
+		ApplicationId: 1,
+	})
+	if err != nil {
+		t.Fatalf("Failed to insert node: %+v", err)
+	}
+
+	outage := &outageDatabase{database: db.database}
+	s := &Storage{database: outage}
+	s.SetMetricRetry(1, time.Millisecond)
+
+	quit := make(chan struct{})
+	t.Cleanup(func() {
+		close(quit)
+		if err := dc(); err != nil {
+			t.Errorf("Failed to close database: %+v", err)
+		}
+	})
+	s.StartHealthMonitor(time.Millisecond, 5*time.Millisecond, capacity, quit)
+	return s, outage, nodeId
+}
+
+// Waits for the Storage to enter or leave degraded mode
+func waitForDegraded(t *testing.T, s *Storage, degraded bool) {
+	for i := 0; i < 1000; i++ {
+		if s.IsDegraded() == degraded {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("Storage did not reach degraded state %t", degraded)
+}
+
+// Returns the number of pings of each node metric in the database
+func storedPings(t *testing.T, outage *outageDatabase) []uint64 {
+	var metrics []NodeMetric
+	err := outage.database.(*DatabaseImpl).db.Order("num_pings ASC").Find(&metrics).Error
+	if err != nil {
+		t.Fatalf("Failed to get node metrics: %+v", err)
+	}
+	pings := make([]uint64, len(metrics))
+	for i, metric := range metrics {
+		pings[i] = metric.NumPings
+	}
+	return pings
+}
+
+// Tests that writes are buffered and reads fail with ErrStorageUnavailable
+// while the database is down, and that the buffered writes are flushed once it
+// comes back.
+func TestStorage_StartHealthMonitor(t *testing.T) {
+	s, outage, nodeId := newOutageStorage(t, 10)
+	newMetric := func(pings uint64) *NodeMetric {
+		return &NodeMetric{NodeId: nodeId.Bytes(), StartTime: time.Now(),
+			EndTime: time.Now(), NumPings: pings}
+	}
+
+	if err := s.InsertNodeMetricWithRetry(newMetric(1)); err != nil {
+		t.Fatalf("Failed to insert node metric: %+v", err)
+	}
+
+	// A write which fails while the database is down is buffered
+	outage.setDown(true)
+	if err := s.InsertNodeMetricWithRetry(newMetric(2)); err != nil {
+		t.Fatalf("Write failed instead of being buffered: %+v", err)
+	}
+	if !s.IsDegraded() {
+		t.Fatalf("Storage not degraded after a failed write.")
+	}
+	if err := s.InsertNodeMetricWithRetry(newMetric(3)); err != nil {
+		t.Fatalf("Write failed instead of being buffered: %+v", err)
+	}
+
+	if _, err := s.GetNodes(); err != ErrStorageUnavailable {
+		t.Errorf("Unexpected error reading while degraded."+
+			"\nexpected: %v\nreceived: %v", ErrStorageUnavailable, err)
+	}
+	if err := s.UpsertState(&State{Key: "key", Value: "value"}); err != ErrStorageUnavailable {
+		t.Errorf("Unexpected error writing while degraded."+
+			"\nexpected: %v\nreceived: %v", ErrStorageUnavailable, err)
+	}
+
+	// Stays degraded while reconnection attempts fail
+	time.Sleep(20 * time.Millisecond)
+	if !s.IsDegraded() {
+		t.Fatalf("Storage left degraded mode while the database is down.")
+	}
+
+	outage.setDown(false)
+	waitForDegraded(t, s, false)
+
+	pings := storedPings(t, outage)
+	if len(pings) != 3 || pings[0] != 1 || pings[1] != 2 || pings[2] != 3 {
+		t.Errorf("Buffered metrics not flushed: %v", pings)
+	}
+	if _, err := s.GetNodes(); err != nil {
+		t.Errorf("Failed to read after the database came back: %+v", err)
+	}
+}
+
+// Tests that the monitor enters degraded mode when the database goes down
+// without any failed writes.
+func TestStorage_StartHealthMonitor_Ping(t *testing.T) {
+	s, outage, _ := newOutageStorage(t, 10)
+
+	outage.setDown(true)
+	waitForDegraded(t, s, true)
+
+	outage.setDown(false)
+	waitForDegraded(t, s, false)
+}
+
+// Tests that the oldest writes are dropped once the buffer is full.
+func TestStorage_StartHealthMonitor_BufferFull(t *testing.T) {
+	s, outage, nodeId := newOutageStorage(t, 2)
+
+	outage.setDown(true)
+	waitForDegraded(t, s, true)
+	for pings := uint64(1); pings <= 4; pings++ {
+		err := s.InsertNodeMetricWithRetry(&NodeMetric{NodeId: nodeId.Bytes(),
+			StartTime: time.Now(), EndTime: time.Now(), NumPings: pings})
+		if err != nil {
+			t.Fatalf("Write failed instead of being buffered: %+v", err)
+		}
+	}
+	if s.GetDroppedWrites() != 2 {
+		t.Errorf("Unexpected number of dropped writes."+
+			"\nexpected: %d\nreceived: %d", 2, s.GetDroppedWrites())
+	}
+
+	outage.setDown(false)
+	waitForDegraded(t, s, false)
+
+	pings := storedPings(t, outage)
+	if len(pings) != 2 || pings[0] != 3 || pings[1] != 4 {
+		t.Errorf("Unexpected metrics flushed: %v", pings)
+	}
+}
+
+// Tests that a Storage without the health monitor is never degraded.
+func TestStorage_IsDegraded_NoMonitor(t *testing.T) {
+	s := &Storage{}
+	if s.IsDegraded() || s.GetDroppedWrites() != 0 {
+		t.Errorf("Storage without a health monitor reported as degraded.")
+	}
+}
@@ -18,32 +18,76 @@ import (
 )
 
 // Interface declaration for Storage methods
+// Methods which reach the database must also be guarded by monitoredDatabase
 type database interface {
+	// Returns an error if the database cannot be reached
+	Ping() error
+
 	// Permissioning methods
 	UpsertState(state *State) error
 	GetStateValue(key string) (string, error)
 	InsertNodeMetric(metric *NodeMetric) error
+	DeleteNodeMetricsBefore(cutoff time.Time) (int64, error)
+	GetNodeUptimes(since time.Time) (map[id.ID]float64, error)
 	InsertRoundMetric(metric *RoundMetric, topology [][]byte) error
-	InsertRoundError(roundId id.Round, errStr string) error
+	InsertRoundError(roundId id.Round, errStr, rawErrStr string) error
+	InsertCappedRoundError(roundId id.Round, errStr, rawErrStr string, limit uint) error
 	GetLatestEphemeralLength() (*EphemeralLength, error)
 	GetEphemeralLengths() ([]*EphemeralLength, error)
 	InsertEphemeralLength(length *EphemeralLength) error
 	GetEarliestRound(cutoff time.Duration) (id.Round, time.Time, error)
 	getBins() ([]*GeoBin, error)
+	UpsertActiveRound(activeRound *ActiveRound) error
+	DeleteActiveRound(roundId id.Round) error
+	GetActiveRounds() ([]*ActiveRound, error)
+	GetStragglerStats(since time.Time) ([]*StragglerStats, error)
+	GetRoundLatencyPercentiles(window time.Duration) (p50, p95, p99 time.Duration, err error)
+	GetNodeRoundParticipation(nodeId *id.ID, start, end time.Time) (uint64, error)
+	GetRoundTopology(roundId id.Round) ([]*id.ID, error)
+	InsertNdfSnapshot(snapshot *NdfSnapshot) error
+	GetNdfSnapshotAt(t time.Time) (*NdfSnapshot, error)
+	GetNdfSnapshotByHash(hash []byte) (*NdfSnapshot, error)
+	DeleteNdfSnapshotsBefore(cutoff time.Time) error
+	InsertSchedulingConfig(config []byte) (uint64, error)
+	GetRoundSchedulingConfig(roundId id.Round) (*SchedulingConfig, error)
 
 	// Node methods
 	InsertApplication(application *Application, unregisteredNode *Node) error
-	RegisterNode(id *id.ID, salt []byte, code, serverAddr, serverCert,
+	GetApplication(appId uint64) (*Application, error)
+	GetApplicationsByTeam(team string) ([]*Application, error)
+	GetApplicationsByNetwork(network string) ([]*Application, error)
+	ReRegisterNode(oldId, newId *id.ID, salt []byte, code, serverAddr, serverCert,
 		gatewayAddress, gatewayCert string) error
+	RegisterNode(id *id.ID, salt []byte, code, serverAddr, serverCert,
+		gatewayAddress, gatewayCert, source string) error
+	DeleteNodeRegistration(code string, purgeMetrics bool) error
 	UpdateNodeAddresses(id *id.ID, nodeAddr, gwAddr string) error
+	UpdateNodePublicAddress(id *id.ID, publicAddr string) error
+	UpdateNodeGatewayClientPort(id *id.ID, port uint32) error
 	UpdateNodeSequence(id *id.ID, sequence string) error
+	UpdateNodeConnectivity(id *id.ID, connectivity uint32) error
+	UpdateNodeStatus(id *id.ID, status node.Status) error
+	UpdateNodeProbation(id *id.ID, onProbation bool, rounds uint32,
+		since time.Time) error
+	UpdateNodeVersions(id *id.ID, serverVersion, gatewayVersion string,
+		timestamp time.Time) error
+	GetNodeVersionHistory(id *id.ID) ([]*NodeVersion, error)
+	InsertNodeHealthEvent(event *NodeHealthEvent) error
+	GetNodeHealthEvents(id *id.ID, since time.Time) ([]*NodeHealthEvent, error)
 	UpdateGeoIP(appId uint64, location, geoBin, gpsLocation string) error
 	updateLastActive(ids [][]byte, lastActive time.Time) error
 	GetNode(code string) (*Node, error)
 	GetNodes() ([]*Node, error)
 	GetNodeById(id *id.ID) (*Node, error)
 	GetNodesByStatus(status node.Status) ([]*Node, error)
+	GetRegistrationsByTimeRange(start, end time.Time) ([]*Node, error)
+	GetNodesPage(filter NodeFilter, afterCode string, limit int) ([]*Node, int, error)
 	GetActiveNodes() ([]*ActiveNode, error)
+	UpsertNodeGroup(name string, members []*id.ID) error
+	DeleteNodeGroup(name string) error
+	GetNodeGroups() (map[string][]*id.ID, error)
+	UpsertAvoidList(applicationId uint64, avoided []uint64) error
+	GetAvoidLists() (map[uint64][]uint64, error)
 }
 
 // Struct implementing the Database Interface with an underlying Map
@@ -73,6 +117,10 @@ const (
 	RoundIdKey  = "RoundId"
 	EllipticKey = "EllipticKey"
 
+	// Version of the last published NDF and the changelog of published NDFs
+	NdfVersionKey   = "NdfVersion"
+	NdfChangelogKey = "NdfChangelog"
+
 	// Provided externally
 	PrecompTimeout       = "timeouts_precomputation"
 	RealtimeTimeout      = "timeouts_realtime"
@@ -132,6 +180,18 @@ type GeoBin struct {
 	Bin     uint8  `gorm:"NOT NULL"`
 }
 
+// Registration sources recorded for a Node
+const (
+	// The Node's registration code was imported but it has not registered
+	BulkImportRegistration = "bulkImport"
+	// The Node registered itself using its registration code
+	SelfServeRegistration = "selfServe"
+	// The Node registered again with the ID already stored for its code
+	ReRegistration = "reRegistration"
+	// The Node registered again under a new ID after changing its key
+	NewKeyRegistration = "newKey"
+)
+
 // Struct representing the Node table in the Database
 type Node struct {
 	// Registration code acts as the primary key
@@ -145,8 +205,14 @@ type Node struct {
 	Salt []byte
 	// Server IP address
 	ServerAddress string
+	// Public, client facing address of the server, if it reported one separate
+	// from its inter-node ServerAddress
+	PublicAddress string
 	// Gateway IP address
 	GatewayAddress string
+	// Port the gateway serves clients on, if it reported one separate from
+	// the port of its GatewayAddress; zero otherwise
+	GatewayClientPort uint32
 	// Node TLS public certificate in PEM string format
 	NodeCertificate string
 	// Gateway TLS public certificate in PEM string format
@@ -154,10 +220,24 @@ type Node struct {
 
 	// Date/time that the node was registered
 	DateRegistered time.Time
+	// How the node's registration was last written, one of the
+	// registration sources. Empty for nodes registered before it was recorded
+	RegistrationSource string
 	// Date/time that the node was last active
 	LastActive time.Time
 	// Node's network status
 	Status uint8 `gorm:"NOT NULL"`
+	// Last known connectivity of the Node, one of the node connectivity
+	// statuses. Only recorded when connectivity is persisted
+	Connectivity uint32
+	// Versions last reported by the Node's server and gateway
+	ServerVersion  string
+	GatewayVersion string
+	// Whether the Node is on probation after registering, the rounds it has
+	// completed on probation and when it completed the first of them
+	OnProbation     bool
+	ProbationRounds uint32
+	ProbationSince  time.Time
 
 	// Unique ID of the Node's Application
 	ApplicationId uint64 `gorm:"UNIQUE_INDEX;NOT NULL;type:bigint REFERENCES applications(id)"`
@@ -169,12 +249,32 @@ type Node struct {
 	Topologies []Topology `gorm:"foreignkey:NodeId;association_foreignkey:Id"`
 }
 
+// Struct representing a Node's membership in a named group of Nodes which
+// rounds can be built from
+type NodeGroupMember struct {
+	// Composite primary key
+	GroupName string `gorm:"primary_key"`
+	NodeId    []byte `gorm:"primary_key"`
+
+	// Position of the Node within the group
+	Order uint32 `gorm:"NOT NULL"`
+}
+
+// Struct representing an Application's declaration that its Node must not be
+// teamed with the Node of another Application
+type AvoidedApplication struct {
+	// Composite primary key
+	ApplicationId        uint64 `gorm:"primary_key;AUTO_INCREMENT:false"`
+	AvoidedApplicationId uint64 `gorm:"primary_key;AUTO_INCREMENT:false"`
+}
+
 // Struct representing Node Metrics table in the Database
 type NodeMetric struct {
 	// Auto-incrementing primary key (Do not set)
 	Id uint64 `gorm:"primary_key;AUTO_INCREMENT:true"`
-	// Node has many NodeMetrics
-	NodeId []byte `gorm:"INDEX;NOT NULL;type:bytea REFERENCES nodes(Id)"`
+	// Node has many NodeMetrics. Null for metrics kept after the Node's
+	// registration was deleted
+	NodeId []byte `gorm:"INDEX;type:bytea REFERENCES nodes(Id)"`
 	// Start time of monitoring period
 	StartTime time.Time `gorm:"NOT NULL"`
 	// End time of monitoring period
@@ -183,6 +283,56 @@ type NodeMetric struct {
 	NumPings uint64 `gorm:"NOT NULL"`
 }
 
+// Struct representing a change in the versions reported by a Node
+type NodeVersion struct {
+	// Auto-incrementing primary key (Do not set)
+	Id uint64 `gorm:"primary_key;AUTO_INCREMENT:true"`
+	// Node has many NodeVersions
+	NodeId []byte `gorm:"INDEX;NOT NULL;type:bytea REFERENCES nodes(Id)"`
+	// Versions reported by the Node's server and gateway
+	ServerVersion  string
+	GatewayVersion string
+	// Time the versions were first reported
+	Timestamp time.Time `gorm:"NOT NULL"`
+}
+
+// Struct representing a health error reported by a Node outside of any round
+type NodeHealthEvent struct {
+	// Auto-incrementing primary key (Do not set)
+	Id uint64 `gorm:"primary_key;AUTO_INCREMENT:true"`
+	// Node has many NodeHealthEvents
+	NodeId []byte `gorm:"INDEX;NOT NULL;type:bytea REFERENCES nodes(Id)"`
+	// Severity the Node reported the error with
+	Severity string `gorm:"NOT NULL"`
+	// Description of the error reported by the Node
+	Message string
+	// Time the error was reported
+	Timestamp time.Time `gorm:"NOT NULL;INDEX"`
+}
+
+// Struct representing an NDF as it was published
+type NdfSnapshot struct {
+	// Auto-incrementing primary key (Do not set)
+	Id uint64 `gorm:"primary_key;AUTO_INCREMENT:true"`
+	// Hash of the published NDF
+	Hash []byte `gorm:"INDEX;NOT NULL"`
+	// Time the NDF was published
+	Timestamp time.Time `gorm:"NOT NULL;INDEX"`
+	// Gzip compressed NDF as published
+	Body []byte `gorm:"NOT NULL"`
+}
+
+// Struct representing the scheduling params rounds were formed with. Rounds
+// formed with identical params share a SchedulingConfig
+type SchedulingConfig struct {
+	// Auto-incrementing primary key (Do not set)
+	Id uint64 `gorm:"primary_key;AUTO_INCREMENT:true"`
+	// Hash of the Config
+	Hash []byte `gorm:"UNIQUE_INDEX;NOT NULL"`
+	// JSON encoded scheduling params
+	Config string `gorm:"NOT NULL"`
+}
+
 // Junction table for the many-to-many relationship between Nodes & RoundMetrics
 type Topology struct {
 	// Composite primary key
@@ -206,6 +356,16 @@ type RoundMetric struct {
 	RoundEnd      time.Time `gorm:"NOT NULL;INDEX;default:to_timestamp(0)"` // Index for TPS calc
 	BatchSize     uint32    `gorm:"NOT NULL"`
 
+	// Slowest Node to finish precomputation and realtime, and how long after
+	// the first Node of the team each reported finishing
+	PrecompStraggler       []byte
+	PrecompStragglerDelta  time.Duration
+	RealtimeStraggler      []byte
+	RealtimeStragglerDelta time.Duration
+
+	// Scheduling params the round was formed with, if recorded
+	SchedulingConfigId *uint64 `gorm:"INDEX;type:bigint REFERENCES scheduling_configs(Id)"`
+
 	// Each RoundMetric has many Nodes participating in each Round
 	Topologies []Topology `gorm:"foreignkey:RoundMetricId;association_foreignkey:Id"`
 
@@ -221,8 +381,42 @@ type RoundError struct {
 	// ID of the round for a given run of the network
 	RoundMetricId uint64 `gorm:"INDEX;NOT NULL;type:bigint REFERENCES round_metrics(Id)"`
 
-	// String of error that occurred during the Round
+	// String of error that occurred during the Round, sanitized of sensitive
+	// information
 	Error string `gorm:"NOT NULL"`
+
+	// Unsanitized error string, restricted to debugging and never published
+	RawError string
+
+	// Number of errors for the round which were not stored because the limit
+	// on stored errors was reached. Non-zero only for the single aggregate
+	// error recording the suppression
+	Suppressed uint64 `gorm:"NOT NULL;DEFAULT:0"`
+}
+
+// Struct representing a round which has not yet completed or failed, kept so
+// in-flight rounds can be resumed after a restart
+type ActiveRound struct {
+	// ID of the round for a given run of the network
+	Id uint64 `gorm:"primary_key;AUTO_INCREMENT:false"`
+
+	// Serialized, unsigned RoundInfo holding the round's state, topology, and
+	// timestamps as of its last transition
+	RoundInfo []byte `gorm:"NOT NULL"`
+}
+
+// Per-Node aggregate of how often, and by how much, a Node was the slowest of
+// its team to finish each phase of a round
+type StragglerStats struct {
+	NodeId []byte
+
+	// Number of rounds the Node was the slowest to finish each phase
+	PrecompCount  uint64
+	RealtimeCount uint64
+
+	// Average time by which the Node trailed the first Node of its team
+	AvgPrecompDelta  time.Duration
+	AvgRealtimeDelta time.Duration
 }
 
 // Struct represegnting the validity period of an ephemeral ID length
@@ -246,6 +440,16 @@ type RoundMetricAlt struct {
 	RoundEnd      time.Time `gorm:"NOT NULL;INDEX;"` // Index for TPS calc
 	BatchSize     uint32    `gorm:"NOT NULL"`
 
+	// Slowest Node to finish precomputation and realtime, and how long after
+	// the first Node of the team each reported finishing
+	PrecompStraggler       []byte
+	PrecompStragglerDelta  time.Duration
+	RealtimeStraggler      []byte
+	RealtimeStragglerDelta time.Duration
+
+	// Scheduling params the round was formed with, if recorded
+	SchedulingConfigId *uint64 `gorm:"INDEX;type:bigint REFERENCES scheduling_configs(Id)"`
+
 	// Each RoundMetric has many Nodes participating in each Round
 	Topologies []Topology `gorm:"foreignkey:RoundMetricId;association_foreignkey:Id"`
 
@@ -264,9 +468,10 @@ func PopulateNodeRegistrationCodes(infos []node.Info) {
 		err := PermissioningDb.InsertApplication(&Application{
 			Id: uint64(i),
 		}, &Node{
-			Code:          info.RegCode,
-			Sequence:      info.Order,
-			ApplicationId: uint64(i),
+			Code:               info.RegCode,
+			Sequence:           info.Order,
+			ApplicationId:      uint64(i),
+			RegistrationSource: BulkImportRegistration,
 		})
 		if err != nil {
 			jww.ERROR.Printf("Unable to populate Node registration code: %+v",
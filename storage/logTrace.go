@@ -0,0 +1,163 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Handles correlating log lines with the round, node and update they relate
+// to, and elevating the logging of rounds and nodes targeted for debugging
+
+package storage
+
+import (
+	"fmt"
+	jww "github.com/spf13/jwalterweatherman"
+	"gitlab.com/xx_network/primitives/id"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// debugTargets tracks the rounds and nodes whose logging is elevated
+type debugTargets struct {
+	rounds map[id.Round]bool
+	nodes  map[id.ID]bool
+	mux    sync.RWMutex
+}
+
+// LogTrace logs lines prefixed with the round, node and update they relate
+// to as key=value fields, so that every line for a single round or node can
+// be found together. When the round or node is targeted for debugging, lines
+// logged at TRACE and DEBUG are elevated to INFO.
+type LogTrace struct {
+	prefix   string
+	targeted bool
+	log      *jww.Notepad
+}
+
+// loggers returns the loggers the network state logs to, the global jww
+// loggers unless it was given its own.
+func (s *NetworkState) loggers() *jww.Notepad {
+	if s.logger != nil {
+		return s.logger
+	}
+	return &jww.Notepad{TRACE: jww.TRACE, DEBUG: jww.DEBUG, INFO: jww.INFO,
+		WARN: jww.WARN, ERROR: jww.ERROR}
+}
+
+// Trace returns a LogTrace for the round, node and update ID. Zero rounds and
+// updates and nil nodes are left out of the prefix.
+func (s *NetworkState) Trace(roundID id.Round, nodeID *id.ID, updateID uint64) *LogTrace {
+	var fields []string
+	if roundID != 0 {
+		fields = append(fields, fmt.Sprintf("round=%d", roundID))
+	}
+	if nodeID != nil {
+		fields = append(fields, "node="+nodeID.String())
+	}
+	if updateID != 0 {
+		fields = append(fields, fmt.Sprintf("update=%d", updateID))
+	}
+
+	lt := &LogTrace{log: s.loggers()}
+	if len(fields) > 0 {
+		lt.prefix = strings.Join(fields, " ") + " "
+	}
+
+	dt := &s.debugTargets
+	dt.mux.RLock()
+	lt.targeted = (roundID != 0 && dt.rounds[roundID]) ||
+		(nodeID != nil && dt.nodes[*nodeID])
+	dt.mux.RUnlock()
+
+	return lt
+}
+
+// Tracef logs at TRACE, or INFO if the round or node is targeted.
+func (lt *LogTrace) Tracef(format string, v ...interface{}) {
+	if lt.targeted {
+		lt.log.INFO.Printf(lt.prefix+"debug=trace "+format, v...)
+		return
+	}
+	lt.log.TRACE.Printf(lt.prefix+format, v...)
+}
+
+// Debugf logs at DEBUG, or INFO if the round or node is targeted.
+func (lt *LogTrace) Debugf(format string, v ...interface{}) {
+	if lt.targeted {
+		lt.log.INFO.Printf(lt.prefix+"debug=debug "+format, v...)
+		return
+	}
+	lt.log.DEBUG.Printf(lt.prefix+format, v...)
+}
+
+// Infof logs at INFO.
+func (lt *LogTrace) Infof(format string, v ...interface{}) {
+	lt.log.INFO.Printf(lt.prefix+format, v...)
+}
+
+// Warnf logs at WARN.
+func (lt *LogTrace) Warnf(format string, v ...interface{}) {
+	lt.log.WARN.Printf(lt.prefix+format, v...)
+}
+
+// Errorf logs at ERROR.
+func (lt *LogTrace) Errorf(format string, v ...interface{}) {
+	lt.log.ERROR.Printf(lt.prefix+format, v...)
+}
+
+// SetDebugRound elevates the logging of the round when enabled is true, and
+// stops elevating it otherwise.
+func (s *NetworkState) SetDebugRound(roundID id.Round, enabled bool) {
+	dt := &s.debugTargets
+	dt.mux.Lock()
+	defer dt.mux.Unlock()
+
+	if !enabled {
+		delete(dt.rounds, roundID)
+		return
+	}
+	if dt.rounds == nil {
+		dt.rounds = make(map[id.Round]bool)
+	}
+	dt.rounds[roundID] = true
+}
+
+// SetDebugNode elevates the logging of the node when enabled is true, and
+// stops elevating it otherwise.
+func (s *NetworkState) SetDebugNode(nodeID *id.ID, enabled bool) {
+	dt := &s.debugTargets
+	dt.mux.Lock()
+	defer dt.mux.Unlock()
+
+	if !enabled {
+		delete(dt.nodes, *nodeID)
+		return
+	}
+	if dt.nodes == nil {
+		dt.nodes = make(map[id.ID]bool)
+	}
+	dt.nodes[*nodeID] = true
+}
+
+// GetDebugTargets returns the rounds, in order, and nodes whose logging is
+// elevated.
+func (s *NetworkState) GetDebugTargets() ([]id.Round, []*id.ID) {
+	dt := &s.debugTargets
+	dt.mux.RLock()
+	defer dt.mux.RUnlock()
+
+	rounds := make([]id.Round, 0, len(dt.rounds))
+	for roundID := range dt.rounds {
+		rounds = append(rounds, roundID)
+	}
+	sort.Slice(rounds, func(i, j int) bool { return rounds[i] < rounds[j] })
+
+	nodes := make([]*id.ID, 0, len(dt.nodes))
+	for nodeID := range dt.nodes {
+		nid := nodeID
+		nodes = append(nodes, &nid)
+	}
+	return rounds, nodes
+}
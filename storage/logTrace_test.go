@@ -0,0 +1,98 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package storage
+
+import (
+	"bytes"
+	jww "github.com/spf13/jwalterweatherman"
+	"gitlab.com/xx_network/primitives/id"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Captures the lines the network state logs at INFO and above by giving it its
+// own loggers, leaving the global loggers untouched
+func captureInfoLogs(state *NetworkState) *bytes.Buffer {
+	buf := &bytes.Buffer{}
+	state.logger = jww.NewNotepad(jww.LevelInfo, jww.LevelFatal, buf,
+		ioutil.Discard, "", 0)
+	return buf
+}
+
+// Tests that the TRACE and DEBUG lines of a targeted round are elevated to
+// INFO while those of other rounds are not.
+func TestNetworkState_Trace_TargetedRound(t *testing.T) {
+	state := &NetworkState{}
+	nid := id.NewIdFromString("node", id.Node, t)
+	state.SetDebugRound(5, true)
+	buf := captureInfoLogs(state)
+
+	for _, roundID := range []id.Round{4, 5, 6} {
+		trace := state.Trace(roundID, nid, 12)
+		trace.Tracef("trace line")
+		trace.Debugf("debug line")
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 elevated lines, received %d:\n%s", len(lines),
+			buf.String())
+	}
+	for _, line := range lines {
+		if !strings.Contains(line, "round=5 node="+nid.String()+" update=12 ") {
+			t.Errorf("Elevated line is not for the targeted round: %s", line)
+		}
+	}
+
+	// Lines of untargeted rounds still carry their fields
+	buf.Reset()
+	state.Trace(4, nil, 0).Infof("info line")
+	if !strings.Contains(buf.String(), "round=4 info line") {
+		t.Errorf("Unexpected line: %s", buf.String())
+	}
+
+	// Disabling the target stops the elevation
+	buf.Reset()
+	state.SetDebugRound(5, false)
+	state.Trace(5, nid, 12).Debugf("debug line")
+	if buf.Len() != 0 {
+		t.Errorf("Line elevated after the target was removed: %s", buf.String())
+	}
+}
+
+// Tests that the lines of a targeted node are elevated whatever round they
+// are for, and that the targets are reported.
+func TestNetworkState_Trace_TargetedNode(t *testing.T) {
+	state := &NetworkState{}
+	targeted := id.NewIdFromString("targeted", id.Node, t)
+	other := id.NewIdFromString("other", id.Node, t)
+	state.SetDebugNode(targeted, true)
+	state.SetDebugRound(9, true)
+	state.SetDebugRound(3, true)
+	buf := captureInfoLogs(state)
+
+	state.Trace(1, targeted, 0).Tracef("targeted node")
+	state.Trace(1, other, 0).Tracef("other node")
+	state.Trace(0, nil, 0).Debugf("no fields")
+
+	if !strings.Contains(buf.String(), "targeted node") ||
+		strings.Contains(buf.String(), "other node") ||
+		strings.Contains(buf.String(), "no fields") {
+		t.Errorf("Unexpected elevated lines:\n%s", buf.String())
+	}
+
+	rounds, nodes := state.GetDebugTargets()
+	if !reflect.DeepEqual(rounds, []id.Round{3, 9}) {
+		t.Errorf("Unexpected debug rounds: %v", rounds)
+	}
+	if len(nodes) != 1 || !nodes[0].Cmp(targeted) {
+		t.Errorf("Unexpected debug nodes: %v", nodes)
+	}
+}
@@ -0,0 +1,104 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Handles retrying of failed metric writes to the database
+
+package storage
+
+import (
+	"github.com/pkg/errors"
+	jww "github.com/spf13/jwalterweatherman"
+	"gitlab.com/xx_network/primitives/id"
+	"time"
+)
+
+// Defaults used when the metric retry parameters are not set
+const (
+	defaultMetricWriteAttempts = 3
+	defaultMetricWriteBackoff  = 500 * time.Millisecond
+
+	// The backoff between attempts is never allowed to exceed this
+	maxMetricWriteBackoff = 30 * time.Second
+)
+
+// SetMetricRetry sets the number of attempts made for each metric write and
+// the delay before the first retry. The delay doubles after each failed
+// attempt. Zero values select the defaults.
+func (s *Storage) SetMetricRetry(attempts uint, backoff time.Duration) {
+	s.metricWriteAttempts = attempts
+	s.metricWriteBackoff = backoff
+}
+
+// InsertNodeMetricWithRetry inserts the NodeMetric, retrying with backoff on
+// failure. An error is only returned once all attempts are exhausted.
+func (s *Storage) InsertNodeMetricWithRetry(metric *NodeMetric) error {
+	return s.retryMetricWrite("node metric", func() error {
+		return s.InsertNodeMetric(metric)
+	})
+}
+
+// InsertRoundMetricWithRetry inserts the RoundMetric and its topology,
+// retrying with backoff on failure. An error is only returned once all
+// attempts are exhausted.
+func (s *Storage) InsertRoundMetricWithRetry(metric *RoundMetric,
+	topology [][]byte) error {
+	return s.retryMetricWrite("round metric", func() error {
+		return s.InsertRoundMetric(metric, topology)
+	})
+}
+
+// InsertRoundErrorWithRetry inserts the round error, retrying with backoff on
+// failure. An error is only returned once all attempts are exhausted. If the
+// round already has the limit of stored errors, the error is counted as
+// suppressed instead.
+func (s *Storage) InsertRoundErrorWithRetry(roundId id.Round,
+	errStr, rawErrStr string) error {
+	return s.retryMetricWrite("round error", func() error {
+		if s.roundErrorLimit > 0 {
+			return s.InsertCappedRoundError(roundId, errStr, rawErrStr,
+				s.roundErrorLimit)
+		}
+		return s.InsertRoundError(roundId, errStr, rawErrStr)
+	})
+}
+
+// retryMetricWrite calls write until it succeeds or the configured number of
+// attempts is reached, sleeping between failed attempts. This blocks the
+// calling thread, so it must never be called from the scheduling thread.
+func (s *Storage) retryMetricWrite(description string, write func() error) error {
+	attempts := s.metricWriteAttempts
+	if attempts == 0 {
+		attempts = defaultMetricWriteAttempts
+	}
+	backoff := s.metricWriteBackoff
+	if backoff == 0 {
+		backoff = defaultMetricWriteBackoff
+	}
+
+	var err error
+	for i := uint(1); ; i++ {
+		err = write()
+		if err == nil {
+			return nil
+		}
+		if i >= attempts {
+			break
+		}
+
+		jww.WARN.Printf("Failed to write %s (attempt %d of %d), retrying "+
+			"in %s: %+v", description, i, attempts, backoff, err)
+		time.Sleep(backoff)
+
+		backoff *= 2
+		if backoff > maxMetricWriteBackoff {
+			backoff = maxMetricWriteBackoff
+		}
+	}
+
+	return errors.WithMessagef(err, "Failed to write %s after %d attempts",
+		description, attempts)
+}
@@ -0,0 +1,158 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package storage
+
+import (
+	"github.com/pkg/errors"
+	"gitlab.com/xx_network/primitives/id"
+	"testing"
+	"time"
+)
+
+// flakyDatabase wraps a database and fails the first failures metric writes
+type flakyDatabase struct {
+	database
+	failures int
+	calls    int
+}
+
+func (f *flakyDatabase) fail() error {
+	f.calls++
+	if f.calls <= f.failures {
+		return errors.New("database is temporarily unavailable")
+	}
+	return nil
+}
+
+func (f *flakyDatabase) InsertNodeMetric(metric *NodeMetric) error {
+	if err := f.fail(); err != nil {
+		return err
+	}
+	return f.database.InsertNodeMetric(metric)
+}
+
+func (f *flakyDatabase) InsertRoundMetric(metric *RoundMetric, topology [][]byte) error {
+	if err := f.fail(); err != nil {
+		return err
+	}
+	return f.database.InsertRoundMetric(metric, topology)
+}
+
+func (f *flakyDatabase) InsertRoundError(roundId id.Round, errStr, rawErrStr string) error {
+	if err := f.fail(); err != nil {
+		return err
+	}
+	return f.database.InsertRoundError(roundId, errStr, rawErrStr)
+}
+
+// newFlakyStorage returns a Storage whose metric writes fail the given number
+// of times before reaching the real database.
+func newFlakyStorage(t *testing.T, failures int) (*Storage, *flakyDatabase) {
+	db, _, err := NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("Failed to create database: %+v", err)
+	}
+	flaky := &flakyDatabase{database: db.database, failures: failures}
+	s := &Storage{database: flaky}
+	s.SetMetricRetry(3, time.Millisecond)
+	return s, flaky
+}
+
+// Tests that InsertNodeMetricWithRetry() writes the metric once the database
+// recovers within the allowed number of attempts.
+func TestStorage_InsertNodeMetricWithRetry(t *testing.T) {
+	s, flaky := newFlakyStorage(t, 2)
+
+	nodeId := id.NewIdFromString("node", id.Node, t)
+	err := s.InsertApplication(&Application{Id: 1}, &Node{
+		Code:          "AAAA",
+		Id:            nodeId.Bytes(),
+		ApplicationId: 1,
+	})
+	if err != nil {
+		t.Fatalf("Failed to insert node: %+v", err)
+	}
+
+	metric := &NodeMetric{
+		NodeId:    nodeId.Bytes(),
+		StartTime: time.Now(),
+		EndTime:   time.Now(),
+		NumPings:  1000,
+	}
+	err = s.InsertNodeMetricWithRetry(metric)
+	if err != nil {
+		t.Fatalf("InsertNodeMetricWithRetry() returned an error: %+v", err)
+	}
+	if flaky.calls != 3 {
+		t.Errorf("Unexpected number of write attempts."+
+			"\nexpected: %d\nreceived: %d", 3, flaky.calls)
+	}
+
+	var metrics []NodeMetric
+	flaky.database.(*DatabaseImpl).db.Find(&metrics)
+	if len(metrics) != 1 || metrics[0].NumPings != metric.NumPings {
+		t.Errorf("Node metric was not written: %+v", metrics)
+	}
+}
+
+// Tests that InsertRoundMetricWithRetry() writes the metric once the database
+// recovers within the allowed number of attempts.
+func TestStorage_InsertRoundMetricWithRetry(t *testing.T) {
+	s, flaky := newFlakyStorage(t, 1)
+
+	nodeId := id.NewIdFromString("node", id.Node, t)
+	err := s.InsertApplication(&Application{Id: 1}, &Node{
+		Code:          "AAAA",
+		Id:            nodeId.Bytes(),
+		ApplicationId: 1,
+	})
+	if err != nil {
+		t.Fatalf("Failed to insert node: %+v", err)
+	}
+
+	metric := &RoundMetric{
+		Id:            42,
+		PrecompStart:  time.Now(),
+		PrecompEnd:    time.Now(),
+		RealtimeStart: time.Now(),
+		RealtimeEnd:   time.Now(),
+		RoundEnd:      time.Now(),
+		BatchSize:     32,
+	}
+	err = s.InsertRoundMetricWithRetry(metric, [][]byte{nodeId.Bytes()})
+	if err != nil {
+		t.Fatalf("InsertRoundMetricWithRetry() returned an error: %+v", err)
+	}
+
+	var metrics []RoundMetric
+	flaky.database.(*DatabaseImpl).db.Find(&metrics)
+	if len(metrics) != 1 || metrics[0].Id != metric.Id {
+		t.Errorf("Round metric was not written: %+v", metrics)
+	}
+}
+
+// Error path: tests that InsertRoundErrorWithRetry() returns an error once
+// all attempts have failed, without writing anything.
+func TestStorage_InsertRoundErrorWithRetry_Exhausted(t *testing.T) {
+	s, flaky := newFlakyStorage(t, 5)
+
+	err := s.InsertRoundErrorWithRetry(1, "error", "raw error")
+	if err == nil {
+		t.Fatalf("InsertRoundErrorWithRetry() did not return an error.")
+	}
+	if flaky.calls != 3 {
+		t.Errorf("Unexpected number of write attempts."+
+			"\nexpected: %d\nreceived: %d", 3, flaky.calls)
+	}
+
+	var roundErrors []RoundError
+	flaky.database.(*DatabaseImpl).db.Find(&roundErrors)
+	if len(roundErrors) != 0 {
+		t.Errorf("Round error was unexpectedly written: %+v", roundErrors)
+	}
+}
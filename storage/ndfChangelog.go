@@ -0,0 +1,335 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Handles the changelog of published NDFs, so that services mirroring the NDF
+// can learn what changed between versions without diffing NDFs themselves
+
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/pkg/errors"
+	jww "github.com/spf13/jwalterweatherman"
+	"gitlab.com/xx_network/primitives/id"
+	"gitlab.com/xx_network/primitives/ndf"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Number of NDF change records kept when no limit is given
+const DefaultNdfChangelogLimit = 100
+
+// NdfChange records what changed in a published NDF from the NDF published
+// before it.
+type NdfChange struct {
+	// Version of the published NDF, as listed in the NDF history
+	Version   uint64
+	Published time.Time
+
+	// Set when the NDF published before is not known, such as for the first
+	// NDF published, in which case every node is listed as added
+	Baseline bool
+
+	NodesAdded     []*id.ID
+	NodesRemoved   []*id.ID
+	AddressChanges []NdfAddressChange
+	StatusChanges  []NdfStatusChange
+
+	// Set when anything in the NDF other than its nodes and gateways
+	// changed, or it is not known whether it did
+	OtherChanged bool `json:",omitempty"`
+}
+
+// Kinds of change a published NDF may make, by which the delivery of the NDF
+// to nodes may be spread
+const (
+	NdfChangeNodesAdded   = "nodesAdded"
+	NdfChangeNodesRemoved = "nodesRemoved"
+	NdfChangeAddress      = "addressChange"
+	NdfChangeStatus       = "statusChange"
+	// Any change not to the published nodes, or to an NDF published after
+	// one which is not known
+	NdfChangeOther = "other"
+)
+
+// NdfChangeKinds lists every kind of change a published NDF may make.
+var NdfChangeKinds = []string{NdfChangeNodesAdded, NdfChangeNodesRemoved,
+	NdfChangeAddress, NdfChangeStatus, NdfChangeOther}
+
+// Kinds returns the kinds of change the record lists. A record listing no
+// change to the nodes is taken to be of a change elsewhere in the NDF.
+func (c *NdfChange) Kinds() []string {
+	if c.Baseline {
+		return []string{NdfChangeOther}
+	}
+
+	var kinds []string
+	if len(c.NodesAdded) > 0 {
+		kinds = append(kinds, NdfChangeNodesAdded)
+	}
+	if len(c.NodesRemoved) > 0 {
+		kinds = append(kinds, NdfChangeNodesRemoved)
+	}
+	if len(c.AddressChanges) > 0 {
+		kinds = append(kinds, NdfChangeAddress)
+	}
+	if len(c.StatusChanges) > 0 {
+		kinds = append(kinds, NdfChangeStatus)
+	}
+	if c.OtherChanged || len(kinds) == 0 {
+		kinds = append(kinds, NdfChangeOther)
+	}
+	return kinds
+}
+
+// NdfAddressChange is a change of the address a node or its gateway is
+// published with.
+type NdfAddressChange struct {
+	Node *id.ID
+	// Set if the address is that of the node's gateway
+	Gateway bool
+	Old     string
+	New     string
+}
+
+// NdfStatusChange is a change of the status a node is published with.
+type NdfStatusChange struct {
+	Node *id.ID
+	Old  ndf.Status
+	New  ndf.Status
+}
+
+// ndfChangelogNode holds the fields of a published node which are compared
+// between NDFs
+type ndfChangelogNode struct {
+	ID             *id.ID
+	Address        string
+	GatewayAddress string
+	Status         ndf.Status
+}
+
+// Format the changelog is stored in
+type ndfChangelogState struct {
+	Records []*NdfChange
+	Nodes   []ndfChangelogNode
+}
+
+// ndfChangelog tracks the changes of the most recently published NDFs
+type ndfChangelog struct {
+	// Change records, oldest first
+	records []*NdfChange
+	limit   int
+
+	// Nodes of the last published NDF, nil if it is not known, and the
+	// encoding of the rest of it, nil if it is not known
+	last      []ndfChangelogNode
+	lastOther []byte
+
+	// Set if the changelog is persisted to Storage
+	persist bool
+
+	mux sync.Mutex
+}
+
+// SetNdfChangelog sets the number of NDF change records kept and whether they
+// are persisted to Storage, loading any records already persisted. A limit of
+// 0 selects the default. Must be called before the first NDF is published.
+func (s *NetworkState) SetNdfChangelog(limit int, persist bool) error {
+	if limit < 0 {
+		return errors.Errorf("NDF changelog limit of %d is negative", limit)
+	} else if limit == 0 {
+		limit = DefaultNdfChangelogLimit
+	}
+
+	c := &s.ndfChangelog
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	c.limit = limit
+	c.persist = persist
+	if !persist {
+		return nil
+	}
+
+	value, err := PermissioningDb.GetStateValue(NdfChangelogKey)
+	if err != nil {
+		// Nothing has been persisted yet
+		return nil
+	}
+	stored := &ndfChangelogState{}
+	if err = json.Unmarshal([]byte(value), stored); err != nil {
+		return errors.Errorf("Failed to decode the NDF changelog: %+v", err)
+	}
+	c.records = stored.Records
+	c.last = stored.Nodes
+	c.trim()
+
+	jww.INFO.Printf("Loaded %d NDF change records", len(c.records))
+	return nil
+}
+
+// GetNdfChanges returns the change records of the NDFs published after the
+// version, oldest first. Returns an error if records after the version are
+// no longer kept, in which case the full NDF must be fetched again.
+func (s *NetworkState) GetNdfChanges(fromVersion uint64) ([]NdfChange, error) {
+	c := &s.ndfChangelog
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	if len(c.records) > 0 && fromVersion+1 < c.records[0].Version {
+		return nil, errors.Errorf("Changes since NDF version %d are no "+
+			"longer kept, the oldest change kept is of version %d",
+			fromVersion, c.records[0].Version)
+	}
+
+	var changes []NdfChange
+	for _, r := range c.records {
+		if r.Version > fromVersion {
+			changes = append(changes, *r)
+		}
+	}
+	return changes, nil
+}
+
+// recordNdfChange adds the change record of the published NDF with the given
+// version, dropping the oldest records past the limit, and returns the record.
+func (s *NetworkState) recordNdfChange(version uint64, published time.Time,
+	def *ndf.NetworkDefinition) *NdfChange {
+	c := &s.ndfChangelog
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	if c.limit == 0 {
+		c.limit = DefaultNdfChangelogLimit
+	}
+
+	nodes := changelogNodes(def)
+	change := diffNdfNodes(c.last, nodes)
+	change.Version = version
+	change.Published = published
+	other := encodeNdfOther(def)
+	change.OtherChanged = c.lastOther == nil || other == nil ||
+		!bytes.Equal(c.lastOther, other)
+	c.records = append(c.records, change)
+	c.last = nodes
+	c.lastOther = other
+	c.trim()
+
+	if c.persist {
+		value, err := json.Marshal(&ndfChangelogState{
+			Records: c.records,
+			Nodes:   c.last,
+		})
+		if err == nil {
+			err = PermissioningDb.UpsertState(&State{
+				Key:   NdfChangelogKey,
+				Value: string(value),
+			})
+		}
+		if err != nil {
+			jww.ERROR.Printf("Failed to store the change record of NDF "+
+				"version %d: %+v", version, err)
+		}
+	}
+	return change
+}
+
+// trim drops the oldest records past the limit. Must be called with the lock
+// held.
+func (c *ndfChangelog) trim() {
+	if len(c.records) > c.limit {
+		c.records = c.records[len(c.records)-c.limit:]
+	}
+}
+
+// changelogNodes returns the compared fields of the nodes in the NDF, sorted
+// by ID.
+func changelogNodes(def *ndf.NetworkDefinition) []ndfChangelogNode {
+	gateways := make(map[id.ID]string, len(def.Gateways))
+	for _, gw := range def.Gateways {
+		gwID, err := id.Unmarshal(gw.ID)
+		if err != nil {
+			continue
+		}
+		gwID.SetType(id.Node)
+		gateways[*gwID] = gw.Address
+	}
+
+	nodes := make([]ndfChangelogNode, 0, len(def.Nodes))
+	for _, n := range def.Nodes {
+		nid, err := id.Unmarshal(n.ID)
+		if err != nil {
+			continue
+		}
+		nodes = append(nodes, ndfChangelogNode{
+			ID:             nid,
+			Address:        n.Address,
+			GatewayAddress: gateways[*nid],
+			Status:         n.Status,
+		})
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return bytes.Compare(nodes[i].ID[:], nodes[j].ID[:]) < 0
+	})
+	return nodes
+}
+
+// encodeNdfOther returns the encoding of everything in the NDF other than its
+// nodes, gateways and timestamp, nil if it cannot be encoded.
+func encodeNdfOther(def *ndf.NetworkDefinition) []byte {
+	other := *def
+	other.Nodes, other.Gateways, other.Timestamp = nil, nil, time.Time{}
+	data, err := json.Marshal(&other)
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+// diffNdfNodes returns the changes from the old nodes to the next ones. A nil
+// list of old nodes produces a baseline record.
+func diffNdfNodes(old, next []ndfChangelogNode) *NdfChange {
+	change := &NdfChange{Baseline: old == nil}
+
+	oldNodes := make(map[id.ID]ndfChangelogNode, len(old))
+	for _, n := range old {
+		oldNodes[*n.ID] = n
+	}
+
+	for _, n := range next {
+		o, exists := oldNodes[*n.ID]
+		if !exists {
+			change.NodesAdded = append(change.NodesAdded, n.ID)
+			continue
+		}
+		delete(oldNodes, *n.ID)
+
+		if o.Address != n.Address {
+			change.AddressChanges = append(change.AddressChanges,
+				NdfAddressChange{Node: n.ID, Old: o.Address, New: n.Address})
+		}
+		if o.GatewayAddress != n.GatewayAddress {
+			change.AddressChanges = append(change.AddressChanges,
+				NdfAddressChange{Node: n.ID, Gateway: true,
+					Old: o.GatewayAddress, New: n.GatewayAddress})
+		}
+		if o.Status != n.Status {
+			change.StatusChanges = append(change.StatusChanges,
+				NdfStatusChange{Node: n.ID, Old: o.Status, New: n.Status})
+		}
+	}
+
+	// Removed nodes are listed in the order of the old NDF
+	for _, n := range old {
+		if _, removed := oldNodes[*n.ID]; removed {
+			change.NodesRemoved = append(change.NodesRemoved, n.ID)
+		}
+	}
+	return change
+}
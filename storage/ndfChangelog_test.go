@@ -0,0 +1,180 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package storage
+
+import (
+	"crypto/rand"
+	"gitlab.com/xx_network/crypto/signature/rsa"
+	"gitlab.com/xx_network/primitives/id"
+	"gitlab.com/xx_network/primitives/ndf"
+	"gitlab.com/xx_network/primitives/region"
+	"testing"
+)
+
+// Creates a state on the current database keeping the given number of NDF
+// change records persisted to Storage, as a server starting on it would
+func newNdfChangelogTestState(t *testing.T, limit int) *NetworkState {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate private key: %+v", err)
+	}
+	state, err := NewState(privateKey, 8, "", "", region.GetCountryBins())
+	if err != nil {
+		t.Fatalf("Failed to create state: %+v", err)
+	}
+	if err = state.SetNdfChangelog(limit, true); err != nil {
+		t.Fatalf("Failed to set NDF changelog: %+v", err)
+	}
+	return state
+}
+
+// Publishes an NDF holding the nodes with the given addresses, each with a
+// gateway at the same host
+func publishChangelogTestNdf(t *testing.T, state *NetworkState,
+	nodes []*id.ID, addresses []string) {
+	def := &ndf.NetworkDefinition{}
+	for i, nid := range nodes {
+		gwID := nid.DeepCopy()
+		gwID.SetType(id.Gateway)
+		def.Nodes = append(def.Nodes, ndf.Node{ID: nid.Marshal(),
+			Address: addresses[i] + ":11420"})
+		def.Gateways = append(def.Gateways, ndf.Gateway{ID: gwID.Marshal(),
+			Address: addresses[i] + ":22840"})
+	}
+	state.InternalNdfLock.Lock()
+	state.UpdateInternalNdf(def)
+	state.InternalNdfLock.Unlock()
+	if err := state.UpdateOutputNdf(); err != nil {
+		t.Fatalf("Failed to publish NDF: %+v", err)
+	}
+}
+
+// Tests that every published NDF is recorded with the nodes added, removed
+// and changed since the NDF before it, and that versions and records continue
+// across a restart.
+func TestNetworkState_GetNdfChanges(t *testing.T) {
+	var closeDb func() error
+	var err error
+	PermissioningDb, closeDb, err = NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("Failed to create database: %+v", err)
+	}
+	t.Cleanup(func() { _ = closeDb() })
+	state := newNdfChangelogTestState(t, 10)
+
+	a := id.NewIdFromString("a", id.Node, t)
+	b := id.NewIdFromString("b", id.Node, t)
+	c := id.NewIdFromString("c", id.Node, t)
+
+	// 1: the first NDF is a baseline
+	publishChangelogTestNdf(t, state, []*id.ID{a, b}, []string{"10.0.0.1", "10.0.0.2"})
+	// 2: a node is added
+	publishChangelogTestNdf(t, state, []*id.ID{a, b, c},
+		[]string{"10.0.0.1", "10.0.0.2", "10.0.0.3"})
+	// 3: a node moves and another is made stale
+	state.SetPrunedNodes(map[id.ID]bool{*b: false})
+	publishChangelogTestNdf(t, state, []*id.ID{a, b, c},
+		[]string{"10.0.0.9", "10.0.0.2", "10.0.0.3"})
+	// 4: a node is removed
+	state.SetPrunedNodes(map[id.ID]bool{})
+	publishChangelogTestNdf(t, state, []*id.ID{a, b}, []string{"10.0.0.9", "10.0.0.2"})
+
+	changes, err := state.GetNdfChanges(0)
+	if err != nil {
+		t.Fatalf("Failed to get NDF changes: %+v", err)
+	}
+	if len(changes) != 4 {
+		t.Fatalf("Expected 4 change records, received %d: %+v",
+			len(changes), changes)
+	}
+	for i, change := range changes {
+		if change.Version != uint64(i+1) {
+			t.Errorf("Change record %d has version %d.", i, change.Version)
+		}
+	}
+
+	if !changes[0].Baseline || len(changes[0].NodesAdded) != 2 {
+		t.Errorf("Unexpected baseline record: %+v", changes[0])
+	}
+	if changes[1].Baseline || len(changes[1].NodesAdded) != 1 ||
+		!changes[1].NodesAdded[0].Cmp(c) || len(changes[1].NodesRemoved) != 0 ||
+		len(changes[1].AddressChanges) != 0 || len(changes[1].StatusChanges) != 0 {
+		t.Errorf("Unexpected record of the added node: %+v", changes[1])
+	}
+
+	moved := changes[2].AddressChanges
+	if len(moved) != 2 || !moved[0].Node.Cmp(a) || moved[0].Gateway ||
+		moved[0].Old != "10.0.0.1:11420" || moved[0].New != "10.0.0.9:11420" ||
+		!moved[1].Gateway || moved[1].New != "10.0.0.9:22840" {
+		t.Errorf("Unexpected address changes: %+v", moved)
+	}
+	stale := changes[2].StatusChanges
+	if len(stale) != 1 || !stale[0].Node.Cmp(b) || stale[0].Old != ndf.Active ||
+		stale[0].New != ndf.Stale {
+		t.Errorf("Unexpected status changes: %+v", stale)
+	}
+
+	if len(changes[3].NodesRemoved) != 1 || !changes[3].NodesRemoved[0].Cmp(c) ||
+		len(changes[3].StatusChanges) != 1 {
+		t.Errorf("Unexpected record of the removed node: %+v", changes[3])
+	}
+
+	// Only the records after the version are returned
+	changes, err = state.GetNdfChanges(3)
+	if err != nil || len(changes) != 1 || changes[0].Version != 4 {
+		t.Errorf("Unexpected changes since version 3: %+v, %+v", changes, err)
+	}
+
+	// A restarted server continues the versions and diffs against the last
+	// NDF published before the restart
+	restarted := newNdfChangelogTestState(t, 10)
+	publishChangelogTestNdf(t, restarted, []*id.ID{a, b, c},
+		[]string{"10.0.0.9", "10.0.0.2", "10.0.0.3"})
+	changes, err = restarted.GetNdfChanges(3)
+	if err != nil {
+		t.Fatalf("Failed to get NDF changes: %+v", err)
+	}
+	if len(changes) != 2 || changes[0].Version != 4 || changes[1].Version != 5 {
+		t.Fatalf("Unexpected changes after the restart: %+v", changes)
+	}
+	if changes[1].Baseline || len(changes[1].NodesAdded) != 1 ||
+		!changes[1].NodesAdded[0].Cmp(c) {
+		t.Errorf("Unexpected record after the restart: %+v", changes[1])
+	}
+	if history := restarted.GetNdfHistory(); history[len(history)-1].Version != 5 {
+		t.Errorf("NDF history did not continue the versions: %+v", history)
+	}
+}
+
+// Tests that only the most recent records are kept and that changes since a
+// version no longer kept are refused.
+func TestNetworkState_GetNdfChanges_Limit(t *testing.T) {
+	state := newNdfHistoryTestState(t, 0, "")
+	if err := state.SetNdfChangelog(2, false); err != nil {
+		t.Fatalf("Failed to set NDF changelog: %+v", err)
+	}
+	for _, addr := range []string{"10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.4"} {
+		publishTestNdf(t, state, addr+":11420")
+	}
+
+	changes, err := state.GetNdfChanges(2)
+	if err != nil || len(changes) != 2 || changes[0].Version != 3 {
+		t.Errorf("Unexpected changes since version 2: %+v, %+v", changes, err)
+	}
+	if _, err = state.GetNdfChanges(1); err == nil {
+		t.Errorf("Changes since a version no longer kept were returned.")
+	}
+	if changes, err = state.GetNdfChanges(4); err != nil || len(changes) != 0 {
+		t.Errorf("Unexpected changes since the latest version: %+v, %+v",
+			changes, err)
+	}
+
+	if err = state.SetNdfChangelog(-1, false); err == nil {
+		t.Errorf("Negative limit was accepted.")
+	}
+}
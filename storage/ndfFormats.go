@@ -0,0 +1,175 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Handles publishing the partial NDF in the formats of consumers which have
+// not migrated to the current schema
+
+package storage
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"github.com/pkg/errors"
+	jww "github.com/spf13/jwalterweatherman"
+	pb "gitlab.com/elixxir/comms/mixmessages"
+	"gitlab.com/elixxir/comms/network/dataStructures"
+	"gitlab.com/xx_network/comms/signature"
+	"gitlab.com/xx_network/primitives/ndf"
+	"google.golang.org/protobuf/proto"
+	"sort"
+	"time"
+)
+
+// LegacyNdfFormat is the name of the compat schema for consumers which read
+// a single address space size and know neither node statuses nor node ed25519
+// keys.
+const LegacyNdfFormat = "legacy"
+
+// ndfFormats maps the name of each alternate NDF format to the function which
+// renders the partial NDF in it.
+var ndfFormats = map[string]func(def *ndf.NetworkDefinition) ([]byte, error){
+	LegacyNdfFormat: marshalLegacyNdf,
+}
+
+// NdfFormats returns the names of the alternate NDF formats, sorted.
+func NdfFormats() []string {
+	names := make([]string, 0, len(ndfFormats))
+	for name := range ndfFormats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// legacyNetworkDefinition is the NDF in the legacy format.
+type legacyNetworkDefinition struct {
+	Timestamp              time.Time
+	Gateways               []ndf.Gateway
+	Nodes                  []legacyNode
+	Registration           ndf.Registration
+	Notification           ndf.Notification
+	UDB                    ndf.UDB   `json:"Udb"`
+	E2E                    ndf.Group `json:"E2e"`
+	CMIX                   ndf.Group `json:"Cmix"`
+	AddressSpaceSize       uint32
+	ClientVersion          string
+	WhitelistedIds         []string
+	WhitelistedIpAddresses []string
+	RateLimits             ndf.RateLimiting
+}
+
+// legacyNode is a node in the legacy format.
+type legacyNode struct {
+	ID             []byte `json:"Id"`
+	Address        string
+	TlsCertificate string `json:"Tls_certificate"`
+}
+
+// marshalLegacyNdf renders the NDF in the legacy format. The address space
+// size is that of the newest address space.
+func marshalLegacyNdf(def *ndf.NetworkDefinition) ([]byte, error) {
+	legacy := &legacyNetworkDefinition{
+		Timestamp:              def.Timestamp,
+		Gateways:               def.Gateways,
+		Registration:           def.Registration,
+		Notification:           def.Notification,
+		UDB:                    def.UDB,
+		E2E:                    def.E2E,
+		CMIX:                   def.CMIX,
+		ClientVersion:          def.ClientVersion,
+		WhitelistedIds:         def.WhitelistedIds,
+		WhitelistedIpAddresses: def.WhitelistedIpAddresses,
+		RateLimits:             def.RateLimits,
+	}
+	for _, n := range def.Nodes {
+		legacy.Nodes = append(legacy.Nodes, legacyNode{ID: n.ID,
+			Address: n.Address, TlsCertificate: n.TlsCertificate})
+	}
+	var newest time.Time
+	for _, as := range def.AddressSpace {
+		if legacy.AddressSpaceSize == 0 || as.Timestamp.After(newest) {
+			legacy.AddressSpaceSize = uint32(as.Size)
+			newest = as.Timestamp
+		}
+	}
+	return json.Marshal(legacy)
+}
+
+// newFormatNdfs returns an empty published NDF for each alternate format.
+func newFormatNdfs() (map[string]*dataStructures.Ndf, error) {
+	formatNdfs := make(map[string]*dataStructures.Ndf, len(ndfFormats))
+	for name := range ndfFormats {
+		formatNdf, err := dataStructures.NewNdf(&ndf.NetworkDefinition{})
+		if err != nil {
+			return nil, err
+		}
+		formatNdfs[name] = formatNdf
+	}
+	return formatNdfs, nil
+}
+
+// GetFormatNdf returns the partial NDF published in the named format, or nil
+// if there is no such format.
+func (s *NetworkState) GetFormatNdf(format string) *dataStructures.Ndf {
+	return s.formatNdfs[format]
+}
+
+// SetFormatNdfOutputPath sets the file the signed partial NDF in the named
+// format is written to each time it is published. Must be called before the
+// NDF is first output.
+func (s *NetworkState) SetFormatNdfOutputPath(format, path string) error {
+	if _, exists := ndfFormats[format]; !exists {
+		return errors.Errorf("Unknown NDF format %q", format)
+	}
+	if s.formatNdfOutputPaths == nil {
+		s.formatNdfOutputPaths = make(map[string]string)
+	}
+	s.formatNdfOutputPaths[format] = path
+	return nil
+}
+
+// updateFormatNdfs renders the partial NDF in each alternate format, signs it
+// the same way the partial NDF is signed, and publishes it. Formats which
+// fail are logged and keep their previous NDF, so that they do not hold back
+// the current one.
+func (s *NetworkState) updateFormatNdfs(partial *ndf.NetworkDefinition) {
+	for name, marshal := range ndfFormats {
+		msg := &pb.NDF{}
+		var err error
+		if msg.Ndf, err = marshal(partial); err != nil {
+			jww.ERROR.Printf("Failed to render the NDF in the %s format: "+
+				"%+v", name, err)
+			continue
+		}
+		if err = signature.SignRsa(msg, s.rsaPrivateKey); err != nil {
+			jww.ERROR.Printf("Failed to sign the NDF in the %s format: %+v",
+				name, err)
+			continue
+		}
+		if err = checkNdfSignatures(partial, msg); err != nil {
+			continue
+		}
+		if err = s.formatNdfs[name].Update(msg); err != nil {
+			jww.ERROR.Printf("Failed to publish the NDF in the %s format: "+
+				"%+v", name, err)
+			continue
+		}
+
+		path := s.formatNdfOutputPaths[name]
+		if path == "" {
+			continue
+		}
+		signed, err := proto.Marshal(msg)
+		if err != nil {
+			jww.ERROR.Printf("Unable to marshal the NDF in the %s format: "+
+				"%+v", name, err)
+			continue
+		}
+		s.ndfWrites.write(path,
+			[]byte(base64.StdEncoding.EncodeToString(signed)))
+	}
+}
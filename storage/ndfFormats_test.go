@@ -0,0 +1,119 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package storage
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	pb "gitlab.com/elixxir/comms/mixmessages"
+	"gitlab.com/elixxir/comms/testkeys"
+	"gitlab.com/xx_network/comms/signature"
+	"gitlab.com/xx_network/primitives/id"
+	"gitlab.com/xx_network/primitives/ndf"
+	"google.golang.org/protobuf/proto"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Tests that UpdateOutputNdf() publishes the current and legacy formats of the
+// partial NDF from the same state, each independently signed and valid.
+func TestNetworkState_UpdateOutputNdf_Formats(t *testing.T) {
+	var err error
+	PermissioningDb, _, err = NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("Failed to create database: %+v", err)
+	}
+	state, _, err := generateTestNetworkState()
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	dir := t.TempDir()
+	legacyPath := filepath.Join(dir, "legacy.ndf")
+	if err = state.SetFormatNdfOutputPath(LegacyNdfFormat, legacyPath); err != nil {
+		t.Fatalf("Failed to set legacy NDF output path: %+v", err)
+	}
+
+	nid := id.NewIdFromString("node", id.Node, t)
+	now := time.Now()
+	state.UpdateInternalNdf(&ndf.NetworkDefinition{
+		Registration: ndf.Registration{
+			TlsCertificate: string(testkeys.LoadFromPath(testkeys.GetNodeCertPath())),
+		},
+		Nodes: []ndf.Node{{ID: nid.Marshal(), Address: "1.2.3.4:11420",
+			Status: ndf.Active}},
+		AddressSpace: []ndf.AddressSpace{
+			{Size: 18, Timestamp: now.Add(-time.Hour)},
+			{Size: 22, Timestamp: now},
+		},
+	})
+	if err = state.UpdateOutputNdf(); err != nil {
+		t.Fatalf("UpdateOutputNdf() unexpectedly produced an error: %+v", err)
+	}
+
+	pubKey := state.GetPrivateKey().GetPublic()
+	current := state.GetPartialNdf().GetPb()
+	if err = signature.VerifyRsa(current, pubKey); err != nil {
+		t.Errorf("Current NDF does not verify: %+v", err)
+	}
+	legacy := state.GetFormatNdf(LegacyNdfFormat).GetPb()
+	if err = signature.VerifyRsa(legacy, pubKey); err != nil {
+		t.Errorf("Legacy NDF does not verify: %+v", err)
+	}
+	if string(legacy.Ndf) == string(current.Ndf) {
+		t.Errorf("Legacy NDF is the same as the current NDF.")
+	}
+
+	def := &legacyNetworkDefinition{}
+	if err = json.Unmarshal(legacy.Ndf, def); err != nil {
+		t.Fatalf("Failed to parse legacy NDF: %+v", err)
+	}
+	if def.AddressSpaceSize != 22 {
+		t.Errorf("Legacy NDF has address space size %d, expected %d.",
+			def.AddressSpaceSize, 22)
+	}
+	if len(def.Nodes) != 1 || !nid.Cmp(id.NewIdFromBytes(def.Nodes[0].ID, t)) {
+		t.Errorf("Legacy NDF has unexpected nodes: %+v", def.Nodes)
+	}
+	var raw map[string]interface{}
+	if err = json.Unmarshal(legacy.Ndf, &raw); err != nil {
+		t.Fatalf("Failed to parse legacy NDF: %+v", err)
+	}
+	if _, exists := raw["AddressSpace"]; exists {
+		t.Errorf("Legacy NDF contains the current address space list.")
+	}
+
+	// The signed legacy NDF is output to its own file
+	data, err := os.ReadFile(legacyPath)
+	if err != nil {
+		t.Fatalf("Failed to read legacy NDF output: %+v", err)
+	}
+	signed, err := base64.StdEncoding.DecodeString(string(data))
+	if err != nil {
+		t.Fatalf("Failed to decode legacy NDF output: %+v", err)
+	}
+	written := &pb.NDF{}
+	if err = proto.Unmarshal(signed, written); err != nil {
+		t.Fatalf("Failed to unmarshal legacy NDF output: %+v", err)
+	}
+	if err = signature.VerifyRsa(written, pubKey); err != nil {
+		t.Errorf("Legacy NDF output does not verify: %+v", err)
+	}
+}
+
+// Tests that SetFormatNdfOutputPath() rejects unknown formats.
+func TestNetworkState_SetFormatNdfOutputPath_Unknown(t *testing.T) {
+	state := &NetworkState{}
+	if err := state.SetFormatNdfOutputPath("unknown", "out.ndf"); err == nil {
+		t.Errorf("SetFormatNdfOutputPath() accepted an unknown format.")
+	}
+	if state.GetFormatNdf("unknown") != nil {
+		t.Errorf("GetFormatNdf() returned an NDF for an unknown format.")
+	}
+}
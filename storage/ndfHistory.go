@@ -0,0 +1,329 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Handles keeping the most recently published NDFs so that a bad NDF update
+// can be rolled back
+
+package storage
+
+import (
+	"encoding/json"
+	"github.com/pkg/errors"
+	jww "github.com/spf13/jwalterweatherman"
+	pb "gitlab.com/elixxir/comms/mixmessages"
+	"gitlab.com/xx_network/primitives/ndf"
+	"gitlab.com/xx_network/primitives/utils"
+	"google.golang.org/protobuf/proto"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Number of published NDFs kept when no limit is given
+const DefaultNdfHistoryLimit = 5
+
+// Extension of the files published NDFs are kept in on disk
+const ndfHistoryExt = ".json"
+
+// NdfVersion is a full NDF which was published.
+type NdfVersion struct {
+	// Counter incremented for every NDF published, including rollbacks
+	Version   uint64
+	Published time.Time
+
+	// The signed full NDF as it was published
+	Full *pb.NDF
+
+	// The internal NDF the full NDF was built from, which is restored on
+	// rollback
+	internal *ndf.NetworkDefinition
+}
+
+// Format published NDFs are kept in on disk
+type ndfVersionFile struct {
+	Version   uint64
+	Published time.Time
+	Full      []byte
+	Internal  json.RawMessage
+}
+
+// ndfHistory tracks the most recently published NDFs
+type ndfHistory struct {
+	// Published NDFs, oldest first
+	versions    []*NdfVersion
+	limit       int
+	nextVersion uint64
+
+	// Set once the version of the last published NDF was read from Storage
+	versionLoaded bool
+
+	// Directory the published NDFs are kept in, empty to keep them in memory
+	// only
+	path string
+
+	// Set once an NDF is rolled back, until the internal state is reconciled
+	// with Storage
+	dirty bool
+
+	mux sync.Mutex
+}
+
+// SetNdfHistory sets the number of published NDFs kept for rollback and
+// the directory they are kept in, loading any NDFs already kept there. An
+// empty path keeps them in memory only and a limit of 0 selects the default.
+// Must be called before the first NDF is published.
+func (s *NetworkState) SetNdfHistory(limit int, path string) error {
+	if limit < 0 {
+		return errors.Errorf("NDF history limit of %d is negative", limit)
+	} else if limit == 0 {
+		limit = DefaultNdfHistoryLimit
+	}
+
+	h := &s.ndfHistory
+	h.mux.Lock()
+	defer h.mux.Unlock()
+
+	h.limit = limit
+	h.path = path
+	if path == "" {
+		return nil
+	}
+
+	versions, err := loadNdfHistory(path)
+	if err != nil {
+		return err
+	}
+	h.versions = versions
+	if len(versions) > 0 {
+		h.nextVersion = versions[len(versions)-1].Version + 1
+	}
+	h.trim()
+
+	jww.INFO.Printf("Loaded %d published NDFs from %s", len(h.versions), path)
+	return nil
+}
+
+// GetNdfHistory returns the published NDFs kept for rollback, oldest first.
+func (s *NetworkState) GetNdfHistory() []NdfVersion {
+	h := &s.ndfHistory
+	h.mux.Lock()
+	defer h.mux.Unlock()
+
+	versions := make([]NdfVersion, len(h.versions))
+	for i, v := range h.versions {
+		versions[i] = NdfVersion{
+			Version:   v.Version,
+			Published: v.Published,
+			Full:      v.Full,
+		}
+	}
+	return versions
+}
+
+// RollbackNdf re-publishes the NDF with the given version. The internal NDF
+// it was built from is restored and output again, so the NDF is re-signed
+// with a fresh timestamp and recorded as a new version, and later updates
+// build on it. Nodes and gateways receive it on their next poll since its
+// hash differs from every NDF they hold.
+//
+// The internal state is marked as needing reconciliation with Storage, since
+// nodes registered or updated after the version was published are missing
+// from it, until MarkNdfReconciled is called.
+func (s *NetworkState) RollbackNdf(version uint64) error {
+	h := &s.ndfHistory
+	h.mux.Lock()
+	var target *NdfVersion
+	for _, v := range h.versions {
+		if v.Version == version {
+			target = v
+			break
+		}
+	}
+	h.mux.Unlock()
+	if target == nil {
+		return errors.Errorf("NDF version %d is not kept for rollback",
+			version)
+	}
+
+	s.InternalNdfLock.Lock()
+	s.UpdateInternalNdf(target.internal.DeepCopy())
+	s.InternalNdfLock.Unlock()
+
+	if err := s.UpdateOutputNdf(); err != nil {
+		return errors.WithMessagef(err, "Failed to re-publish NDF "+
+			"version %d", version)
+	}
+
+	h.mux.Lock()
+	h.dirty = true
+	h.mux.Unlock()
+
+	jww.WARN.Printf("Rolled back the NDF to version %d published at %s, "+
+		"the internal state must be reconciled with Storage", version,
+		target.Published)
+	return nil
+}
+
+// NdfNeedsReconciliation returns true if an NDF was rolled back and the
+// internal state has not been reconciled with Storage since.
+func (s *NetworkState) NdfNeedsReconciliation() bool {
+	s.ndfHistory.mux.Lock()
+	defer s.ndfHistory.mux.Unlock()
+	return s.ndfHistory.dirty
+}
+
+// MarkNdfReconciled records that the internal state was reconciled with
+// Storage after a rollback.
+func (s *NetworkState) MarkNdfReconciled() {
+	s.ndfHistory.mux.Lock()
+	defer s.ndfHistory.mux.Unlock()
+	s.ndfHistory.dirty = false
+}
+
+// recordNdf adds a newly published NDF to the history, dropping the oldest
+// NDFs past the limit, and returns it. Versions continue from the version of
+// the last NDF published before a restart, which is kept in Storage.
+func (s *NetworkState) recordNdf(full *pb.NDF, internal *ndf.NetworkDefinition) *NdfVersion {
+	h := &s.ndfHistory
+	h.mux.Lock()
+	defer h.mux.Unlock()
+
+	if h.limit == 0 {
+		h.limit = DefaultNdfHistoryLimit
+	}
+	if !h.versionLoaded {
+		if stored, err := s.get(NdfVersionKey); err == nil && stored >= h.nextVersion {
+			h.nextVersion = stored + 1
+		}
+		h.versionLoaded = true
+	}
+	if h.nextVersion == 0 {
+		h.nextVersion = 1
+	}
+
+	v := &NdfVersion{
+		Version:   h.nextVersion,
+		Published: time.Now(),
+		Full:      full,
+		internal:  internal,
+	}
+	h.nextVersion++
+	h.versions = append(h.versions, v)
+	if err := s.setId(NdfVersionKey, v.Version); err != nil {
+		jww.ERROR.Printf("Failed to store NDF version %d: %+v", v.Version, err)
+	}
+
+	if h.path != "" {
+		if err := storeNdfVersion(h.path, v); err != nil {
+			jww.ERROR.Printf("Failed to keep NDF version %d on disk: %+v",
+				v.Version, err)
+		}
+	}
+	h.trim()
+	return v
+}
+
+// trim drops the oldest NDFs past the limit. Must be called with the lock
+// held.
+func (h *ndfHistory) trim() {
+	for len(h.versions) > h.limit {
+		if h.path != "" {
+			err := os.Remove(ndfVersionPath(h.path, h.versions[0].Version))
+			if err != nil && !os.IsNotExist(err) {
+				jww.WARN.Printf("Failed to remove NDF version %d from "+
+					"disk: %+v", h.versions[0].Version, err)
+			}
+		}
+		h.versions = h.versions[1:]
+	}
+}
+
+// ndfVersionPath returns the path the NDF version is kept at on disk
+func ndfVersionPath(dir string, version uint64) string {
+	return filepath.Join(dir, strconv.FormatUint(version, 10)+ndfHistoryExt)
+}
+
+// storeNdfVersion writes the NDF version to the directory
+func storeNdfVersion(dir string, v *NdfVersion) error {
+	full, err := proto.Marshal(v.Full)
+	if err != nil {
+		return errors.Errorf("Failed to marshal full NDF: %+v", err)
+	}
+	internal, err := v.internal.Marshal()
+	if err != nil {
+		return errors.Errorf("Failed to marshal internal NDF: %+v", err)
+	}
+
+	data, err := json.Marshal(&ndfVersionFile{
+		Version:   v.Version,
+		Published: v.Published,
+		Full:      full,
+		Internal:  internal,
+	})
+	if err != nil {
+		return err
+	}
+	return utils.WriteFile(ndfVersionPath(dir, v.Version), data,
+		utils.FilePerms, utils.DirPerms)
+}
+
+// loadNdfHistory reads the NDF versions kept in the directory, oldest first.
+// A missing directory holds no versions.
+func loadNdfHistory(dir string) ([]*NdfVersion, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Errorf("Failed to read NDF history from %s: %+v",
+			dir, err)
+	}
+
+	var versions []*NdfVersion
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ndfHistoryExt) {
+			continue
+		}
+
+		path := filepath.Join(dir, entry.Name())
+		data, err := utils.ReadFile(path)
+		if err != nil {
+			return nil, errors.Errorf("Failed to read NDF version from "+
+				"%s: %+v", path, err)
+		}
+		file := &ndfVersionFile{}
+		if err = json.Unmarshal(data, file); err != nil {
+			return nil, errors.Errorf("Failed to decode NDF version from "+
+				"%s: %+v", path, err)
+		}
+		full := &pb.NDF{}
+		if err = proto.Unmarshal(file.Full, full); err != nil {
+			return nil, errors.Errorf("Failed to decode full NDF from "+
+				"%s: %+v", path, err)
+		}
+		internal, err := ndf.Unmarshal(file.Internal)
+		if err != nil {
+			return nil, errors.Errorf("Failed to decode internal NDF from "+
+				"%s: %+v", path, err)
+		}
+
+		versions = append(versions, &NdfVersion{
+			Version:   file.Version,
+			Published: file.Published,
+			Full:      full,
+			internal:  internal,
+		})
+	}
+
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i].Version < versions[j].Version
+	})
+	return versions, nil
+}
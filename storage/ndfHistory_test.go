@@ -0,0 +1,197 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package storage
+
+import (
+	"bytes"
+	"crypto/rand"
+	"gitlab.com/xx_network/comms/signature"
+	"gitlab.com/xx_network/crypto/signature/rsa"
+	"gitlab.com/xx_network/primitives/id"
+	"gitlab.com/xx_network/primitives/ndf"
+	"gitlab.com/xx_network/primitives/region"
+	"os"
+	"strconv"
+	"testing"
+)
+
+// Creates a state backed by a database of its own with the given NDF history
+func newNdfHistoryTestState(t *testing.T, limit int, path string) *NetworkState {
+	var closeDb func() error
+	var err error
+	PermissioningDb, closeDb, err = NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("Failed to create database: %+v", err)
+	}
+	t.Cleanup(func() { _ = closeDb() })
+
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate private key: %+v", err)
+	}
+	state, err := NewState(privateKey, 8, "", "", region.GetCountryBins())
+	if err != nil {
+		t.Fatalf("Failed to create state: %+v", err)
+	}
+	if err = state.SetNdfHistory(limit, path); err != nil {
+		t.Fatalf("Failed to set NDF history: %+v", err)
+	}
+	return state
+}
+
+// Publishes an NDF holding a single node with the given address
+func publishTestNdf(t *testing.T, state *NetworkState, nodeAddress string) {
+	nid := id.NewIdFromString("node", id.Node, t)
+	state.InternalNdfLock.Lock()
+	state.UpdateInternalNdf(&ndf.NetworkDefinition{
+		Nodes:    []ndf.Node{{ID: nid.Marshal(), Address: nodeAddress}},
+		Gateways: []ndf.Gateway{{ID: nid.Marshal(), Address: "1.2.3.4:22840"}},
+	})
+	state.InternalNdfLock.Unlock()
+	if err := state.UpdateOutputNdf(); err != nil {
+		t.Fatalf("Failed to publish NDF: %+v", err)
+	}
+}
+
+// Tests that rolling back to the first of three published NDFs re-publishes
+// it with a new hash and valid signature, and that later updates build on it.
+func TestNetworkState_RollbackNdf(t *testing.T) {
+	state := newNdfHistoryTestState(t, 0, "")
+
+	var hashes [][]byte
+	for i := 1; i <= 3; i++ {
+		publishTestNdf(t, state, "10.0.0."+strconv.Itoa(i)+":11420")
+		hashes = append(hashes, state.GetFullNdf().GetHash())
+	}
+	if state.NdfNeedsReconciliation() {
+		t.Errorf("Reconciliation needed before any rollback.")
+	}
+
+	if err := state.RollbackNdf(1); err != nil {
+		t.Fatalf("Failed to roll back the NDF: %+v", err)
+	}
+
+	published := state.GetFullNdf()
+	if addr := published.Get().Nodes[0].Address; addr != "10.0.0.1:11420" {
+		t.Errorf("Rolled back NDF does not hold the first version."+
+			"\nexpected: %s\nreceived: %s", "10.0.0.1:11420", addr)
+	}
+	if !state.GetPartialNdf().Get().Timestamp.Equal(published.Get().Timestamp) {
+		t.Errorf("Partial NDF not re-published with the rollback.")
+	}
+	err := signature.VerifyRsa(published.GetPb(), state.GetPrivateKey().GetPublic())
+	if err != nil {
+		t.Errorf("Rolled back NDF signature is invalid: %+v", err)
+	}
+
+	// Nodes holding any of the published NDFs must be sent the rollback
+	for i, hash := range hashes {
+		if published.CompareHash(hash) {
+			t.Errorf("Rolled back NDF has the same hash as version %d.", i+1)
+		}
+	}
+
+	history := state.GetNdfHistory()
+	if len(history) != 4 || history[3].Version != 4 {
+		t.Errorf("Rollback not recorded as a new version: %+v", history)
+	}
+	if !state.NdfNeedsReconciliation() {
+		t.Errorf("Reconciliation not needed after a rollback.")
+	}
+	state.MarkNdfReconciled()
+	if state.NdfNeedsReconciliation() {
+		t.Errorf("Reconciliation still needed after being marked reconciled.")
+	}
+
+	// An organic update builds on the rolled back NDF
+	state.InternalNdfLock.Lock()
+	current := state.GetUnprunedNdf()
+	current.Gateways[0].Address = "5.6.7.8:22840"
+	state.UpdateInternalNdf(current)
+	state.InternalNdfLock.Unlock()
+	if err = state.UpdateOutputNdf(); err != nil {
+		t.Fatalf("Failed to publish NDF: %+v", err)
+	}
+	updated := state.GetFullNdf().Get()
+	if updated.Nodes[0].Address != "10.0.0.1:11420" ||
+		updated.Gateways[0].Address != "5.6.7.8:22840" {
+		t.Errorf("Update does not build on the rolled back NDF: %+v / %+v",
+			updated.Nodes[0], updated.Gateways[0])
+	}
+}
+
+// Tests that rolling back to a version which is not kept errors without
+// changing the published NDF.
+func TestNetworkState_RollbackNdf_UnknownVersion(t *testing.T) {
+	state := newNdfHistoryTestState(t, 2, "")
+	for i := 1; i <= 3; i++ {
+		publishTestNdf(t, state, "10.0.0."+strconv.Itoa(i)+":11420")
+	}
+	hash := state.GetFullNdf().GetHash()
+
+	for _, version := range []uint64{1, 4} {
+		if err := state.RollbackNdf(version); err == nil {
+			t.Errorf("No error rolling back to version %d.", version)
+		}
+	}
+	if !bytes.Equal(hash, state.GetFullNdf().GetHash()) {
+		t.Errorf("Published NDF changed by a failed rollback.")
+	}
+	if state.NdfNeedsReconciliation() {
+		t.Errorf("Reconciliation needed after a failed rollback.")
+	}
+
+	if err := state.SetNdfHistory(-1, ""); err == nil {
+		t.Errorf("No error for a negative NDF history limit.")
+	}
+}
+
+// Tests that published NDFs kept on disk are limited and loaded again, so a
+// rollback can be done after a restart.
+func TestNetworkState_SetNdfHistory_Disk(t *testing.T) {
+	dir := t.TempDir()
+	state := newNdfHistoryTestState(t, 2, dir)
+	for i := 1; i <= 3; i++ {
+		publishTestNdf(t, state, "10.0.0."+strconv.Itoa(i)+":11420")
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("Failed to read NDF history directory: %+v", err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("Unexpected number of NDFs kept on disk: %d", len(entries))
+	}
+
+	restarted, err := NewState(state.GetPrivateKey(), 8, "", "",
+		region.GetCountryBins())
+	if err != nil {
+		t.Fatalf("Failed to create state: %+v", err)
+	}
+	if err = restarted.SetNdfHistory(2, dir); err != nil {
+		t.Fatalf("Failed to load NDF history: %+v", err)
+	}
+	history := restarted.GetNdfHistory()
+	if len(history) != 2 || history[0].Version != 2 || history[1].Version != 3 {
+		t.Fatalf("Unexpected NDF history loaded: %+v", history)
+	}
+	if !bytes.Equal(history[1].Full.Ndf, state.GetFullNdf().GetPb().Ndf) {
+		t.Errorf("Loaded NDF does not match the published NDF.")
+	}
+
+	if err = restarted.RollbackNdf(2); err != nil {
+		t.Fatalf("Failed to roll back the NDF: %+v", err)
+	}
+	if addr := restarted.GetFullNdf().Get().Nodes[0].Address; addr != "10.0.0.2:11420" {
+		t.Errorf("Unexpected NDF after rollback.\nexpected: %s\nreceived: %s",
+			"10.0.0.2:11420", addr)
+	}
+	if history = restarted.GetNdfHistory(); history[len(history)-1].Version != 4 {
+		t.Errorf("Version counter not continued after loading: %+v", history)
+	}
+}
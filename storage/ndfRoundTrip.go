@@ -0,0 +1,114 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Handles checking that each marshalled NDF unmarshals back to the NDF it was
+// produced from before it is published
+
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/pkg/errors"
+	jww "github.com/spf13/jwalterweatherman"
+	"gitlab.com/xx_network/primitives/ndf"
+	"sync/atomic"
+)
+
+// Marshals the NDF to publish, replaced in testing
+var marshalNdf = func(def *ndf.NetworkDefinition) ([]byte, error) {
+	return def.Marshal()
+}
+
+// SetNdfRoundTripCheck sets whether each marshalled NDF is unmarshalled and
+// compared against the NDF it was produced from, refusing to publish it if
+// they differ.
+func (s *NetworkState) SetNdfRoundTripCheck(enabled bool) {
+	var v uint32
+	if enabled {
+		v = 1
+	}
+	atomic.StoreUint32(&s.ndfRoundTripCheck, v)
+}
+
+// checkNdfRoundTrip unmarshals the marshalled NDF and compares its key fields
+// against the source, when the round trip check is enabled. An error is
+// returned if it does not unmarshal or differs, so that the NDF is not
+// published.
+func (s *NetworkState) checkNdfRoundTrip(source *ndf.NetworkDefinition,
+	marshalled []byte) error {
+	if atomic.LoadUint32(&s.ndfRoundTripCheck) == 0 {
+		return nil
+	}
+
+	decoded, err := ndf.Unmarshal(marshalled)
+	if err != nil {
+		jww.ERROR.Printf("NDF ROUND TRIP FAILED: refusing to publish an NDF "+
+			"which does not unmarshal: %+v", err)
+		return errors.Errorf("Marshalled NDF does not unmarshal: %+v", err)
+	}
+	if mismatch := ndfMismatch(source, decoded); mismatch != "" {
+		jww.ERROR.Printf("NDF ROUND TRIP FAILED: refusing to publish an NDF "+
+			"whose %s differs once unmarshalled", mismatch)
+		return errors.Errorf("Marshalled NDF differs from its source in %s",
+			mismatch)
+	}
+	return nil
+}
+
+// ndfMismatch returns the first of the key fields in which the two NDFs
+// differ, or an empty string if they do not.
+func ndfMismatch(a, b *ndf.NetworkDefinition) string {
+	switch {
+	case !a.Timestamp.Equal(b.Timestamp):
+		return "timestamp"
+	case a.Registration != b.Registration:
+		return "registration"
+	case a.Notification != b.Notification:
+		return "notification"
+	case !bytes.Equal(a.UDB.ID, b.UDB.ID) || a.UDB.Address != b.UDB.Address ||
+		a.UDB.Cert != b.UDB.Cert || !bytes.Equal(a.UDB.DhPubKey, b.UDB.DhPubKey):
+		return "user discovery"
+	case a.E2E != b.E2E:
+		return "E2E group"
+	case a.CMIX != b.CMIX:
+		return "cMix group"
+	case a.ClientVersion != b.ClientVersion:
+		return "client version"
+	case a.RateLimits != b.RateLimits:
+		return "rate limits"
+	case len(a.AddressSpace) != len(b.AddressSpace):
+		return "address space count"
+	case len(a.Nodes) != len(b.Nodes):
+		return "node count"
+	case len(a.Gateways) != len(b.Gateways):
+		return "gateway count"
+	}
+
+	for i := range a.AddressSpace {
+		if a.AddressSpace[i].Size != b.AddressSpace[i].Size ||
+			!a.AddressSpace[i].Timestamp.Equal(b.AddressSpace[i].Timestamp) {
+			return fmt.Sprintf("address space %d", i)
+		}
+	}
+	for i := range a.Nodes {
+		an, bn := a.Nodes[i], b.Nodes[i]
+		if !bytes.Equal(an.ID, bn.ID) || an.Address != bn.Address ||
+			an.TlsCertificate != bn.TlsCertificate ||
+			!bytes.Equal(an.Ed25519, bn.Ed25519) || an.Status != bn.Status {
+			return fmt.Sprintf("node %d", i)
+		}
+	}
+	for i := range a.Gateways {
+		ag, bg := a.Gateways[i], b.Gateways[i]
+		if !bytes.Equal(ag.ID, bg.ID) || ag.Address != bg.Address ||
+			ag.TlsCertificate != bg.TlsCertificate || ag.Bin != bg.Bin {
+			return fmt.Sprintf("gateway %d", i)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,115 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package storage
+
+import (
+	"bytes"
+	"gitlab.com/xx_network/primitives/id"
+	"gitlab.com/xx_network/primitives/ndf"
+	"strings"
+	"testing"
+)
+
+// Tests that an NDF which unmarshals back to its source is published, and
+// that one whose marshalling drops or breaks fields is refused, leaving the
+// published NDF in place.
+func TestNetworkState_UpdateOutputNdf_RoundTrip(t *testing.T) {
+	state := newNdfHistoryTestState(t, 0, "")
+	state.SetNdfRoundTripCheck(true)
+	publishTestNdf(t, state, "10.0.0.1:11420")
+	published := state.GetFullNdf().GetHash()
+
+	defer func(marshal func(*ndf.NetworkDefinition) ([]byte, error)) {
+		marshalNdf = marshal
+	}(marshalNdf)
+	update := func(address string) error {
+		nid := id.NewIdFromString("node", id.Node, t)
+		state.InternalNdfLock.Lock()
+		state.UpdateInternalNdf(&ndf.NetworkDefinition{
+			Nodes:    []ndf.Node{{ID: nid.Marshal(), Address: address}},
+			Gateways: []ndf.Gateway{{ID: nid.Marshal(), Address: "1.2.3.4:22840"}},
+		})
+		state.InternalNdfLock.Unlock()
+		return state.UpdateOutputNdf()
+	}
+
+	// A marshaller which loses the node addresses
+	marshalNdf = func(def *ndf.NetworkDefinition) ([]byte, error) {
+		corrupted := def.DeepCopy()
+		for i := range corrupted.Nodes {
+			corrupted.Nodes[i].Address = ""
+		}
+		return corrupted.Marshal()
+	}
+	err := update("10.0.0.2:11420")
+	if err == nil || !strings.Contains(err.Error(), "node 0") {
+		t.Errorf("NDF losing node addresses was not refused: %+v", err)
+	}
+
+	// A marshaller producing data which does not unmarshal
+	marshalNdf = func(def *ndf.NetworkDefinition) ([]byte, error) {
+		data, err := def.Marshal()
+		return data[:len(data)/2], err
+	}
+	if err = update("10.0.0.3:11420"); err == nil {
+		t.Errorf("NDF which does not unmarshal was not refused.")
+	}
+	if !bytes.Equal(state.GetFullNdf().GetHash(), published) {
+		t.Errorf("Refused NDF replaced the published NDF.")
+	}
+
+	// Without the check, the broken NDF is published
+	state.SetNdfRoundTripCheck(false)
+	marshalNdf = func(def *ndf.NetworkDefinition) ([]byte, error) {
+		corrupted := def.DeepCopy()
+		corrupted.Gateways = nil
+		return corrupted.Marshal()
+	}
+	if err = update("10.0.0.4:11420"); err != nil {
+		t.Errorf("NDF refused without the round trip check: %+v", err)
+	}
+	if bytes.Equal(state.GetFullNdf().GetHash(), published) {
+		t.Errorf("NDF was not published without the round trip check.")
+	}
+}
+
+// Tests that ndfMismatch() finds differences in the key fields and none
+// between equal NDFs.
+func TestNdfMismatch(t *testing.T) {
+	source := &ndf.NetworkDefinition{
+		Nodes:        []ndf.Node{{ID: []byte("node"), Address: "10.0.0.1:11420"}},
+		Gateways:     []ndf.Gateway{{ID: []byte("gateway"), Address: "10.0.0.1:22840"}},
+		AddressSpace: []ndf.AddressSpace{{Size: 8}},
+		Registration: ndf.Registration{Address: "10.0.0.2:11420"},
+	}
+	data, err := source.Marshal()
+	if err != nil {
+		t.Fatalf("Failed to marshal NDF: %+v", err)
+	}
+	decoded, err := ndf.Unmarshal(data)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal NDF: %+v", err)
+	}
+	if mismatch := ndfMismatch(source, decoded); mismatch != "" {
+		t.Errorf("NDF differs from itself in %s.", mismatch)
+	}
+
+	for expected, corrupt := range map[string]func(*ndf.NetworkDefinition){
+		"registration":    func(d *ndf.NetworkDefinition) { d.Registration.Address = "" },
+		"node count":      func(d *ndf.NetworkDefinition) { d.Nodes = nil },
+		"gateway 0":       func(d *ndf.NetworkDefinition) { d.Gateways[0].ID = nil },
+		"address space 0": func(d *ndf.NetworkDefinition) { d.AddressSpace[0].Size = 3 },
+		"client version":  func(d *ndf.NetworkDefinition) { d.ClientVersion = "1.0.0" },
+	} {
+		corrupted := decoded.DeepCopy()
+		corrupt(corrupted)
+		if mismatch := ndfMismatch(source, corrupted); mismatch != expected {
+			t.Errorf("Expected mismatch in %s, found %q.", expected, mismatch)
+		}
+	}
+}
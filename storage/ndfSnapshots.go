@@ -0,0 +1,138 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Handles keeping snapshots of the published NDFs in Storage, so that the NDF
+// published at a past time can be looked up when diagnosing clients
+
+package storage
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"github.com/jinzhu/gorm"
+	"github.com/pkg/errors"
+	jww "github.com/spf13/jwalterweatherman"
+	"io"
+	"sync"
+	"time"
+)
+
+// ndfSnapshots is the policy for keeping snapshots of the published NDFs
+type ndfSnapshots struct {
+	// Set if a snapshot is kept of each published NDF
+	enabled bool
+
+	// Age past which snapshots are deleted, zero to keep them forever
+	retention time.Duration
+
+	mux sync.Mutex
+}
+
+// SetNdfSnapshots sets whether a snapshot of each published NDF is kept in
+// Storage and how long they are kept for. A retention of 0 keeps them forever.
+// The snapshot of the NDF which was published once the retention ends is kept
+// along with the newer ones, so that every time within the retention can be
+// looked up.
+func (s *NetworkState) SetNdfSnapshots(enabled bool, retention time.Duration) error {
+	if retention < 0 {
+		return errors.Errorf("NDF snapshot retention of %s is negative",
+			retention)
+	}
+
+	s.ndfSnapshots.mux.Lock()
+	defer s.ndfSnapshots.mux.Unlock()
+	s.ndfSnapshots.enabled = enabled
+	s.ndfSnapshots.retention = retention
+	return nil
+}
+
+// GetNdfAt returns the full NDF which was published at the given time, from
+// the snapshots kept in Storage.
+func (s *NetworkState) GetNdfAt(t time.Time) ([]byte, error) {
+	snapshot, err := PermissioningDb.GetNdfSnapshotAt(t)
+	if gorm.IsRecordNotFoundError(err) {
+		return nil, errors.Errorf("No NDF snapshot is kept from %s", t)
+	} else if err != nil {
+		return nil, errors.WithMessagef(err, "Failed to get the NDF "+
+			"snapshot from %s", t)
+	}
+	return decompressNdfSnapshot(snapshot)
+}
+
+// GetNdfByHash returns the full NDF with the given hash, from the snapshots
+// kept in Storage.
+func (s *NetworkState) GetNdfByHash(hash []byte) ([]byte, error) {
+	encoded := base64.StdEncoding.EncodeToString(hash)
+	snapshot, err := PermissioningDb.GetNdfSnapshotByHash(hash)
+	if gorm.IsRecordNotFoundError(err) {
+		return nil, errors.Errorf("No NDF snapshot is kept with hash %s",
+			encoded)
+	} else if err != nil {
+		return nil, errors.WithMessagef(err, "Failed to get the NDF "+
+			"snapshot with hash %s", encoded)
+	}
+	return decompressNdfSnapshot(snapshot)
+}
+
+// storeNdfSnapshot keeps a snapshot of the newly published NDF in Storage and
+// deletes the snapshots past the retention, if snapshots are enabled. Failures
+// are logged rather than returned, since the NDF is already published.
+func (s *NetworkState) storeNdfSnapshot(hash, body []byte, published time.Time) {
+	s.ndfSnapshots.mux.Lock()
+	defer s.ndfSnapshots.mux.Unlock()
+	if !s.ndfSnapshots.enabled {
+		return
+	}
+
+	var compressed bytes.Buffer
+	w := gzip.NewWriter(&compressed)
+	if _, err := w.Write(body); err != nil {
+		jww.ERROR.Printf("Failed to compress NDF snapshot: %+v", err)
+		return
+	}
+	if err := w.Close(); err != nil {
+		jww.ERROR.Printf("Failed to compress NDF snapshot: %+v", err)
+		return
+	}
+
+	err := PermissioningDb.InsertNdfSnapshot(&NdfSnapshot{
+		Hash:      hash,
+		Timestamp: published,
+		Body:      compressed.Bytes(),
+	})
+	if err != nil {
+		jww.ERROR.Printf("Failed to store NDF snapshot %s: %+v",
+			base64.StdEncoding.EncodeToString(hash), err)
+		return
+	}
+
+	if s.ndfSnapshots.retention == 0 {
+		return
+	}
+	err = PermissioningDb.DeleteNdfSnapshotsBefore(
+		published.Add(-s.ndfSnapshots.retention))
+	if err != nil {
+		jww.WARN.Printf("Failed to delete NDF snapshots past the "+
+			"retention of %s: %+v", s.ndfSnapshots.retention, err)
+	}
+}
+
+// decompressNdfSnapshot returns the NDF kept in the snapshot
+func decompressNdfSnapshot(snapshot *NdfSnapshot) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(snapshot.Body))
+	if err != nil {
+		return nil, errors.Errorf("Failed to decompress NDF snapshot "+
+			"from %s: %+v", snapshot.Timestamp, err)
+	}
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return nil, errors.Errorf("Failed to decompress NDF snapshot "+
+			"from %s: %+v", snapshot.Timestamp, err)
+	}
+	return body, nil
+}
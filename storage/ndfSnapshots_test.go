@@ -0,0 +1,103 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package storage
+
+import (
+	"bytes"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// Tests that the NDFs published over several updates are returned by the
+// time they were published at and by their hash.
+func TestNetworkState_GetNdfAt(t *testing.T) {
+	state := newNdfHistoryTestState(t, 0, "")
+	if err := state.SetNdfSnapshots(true, 0); err != nil {
+		t.Fatalf("Failed to set NDF snapshots: %+v", err)
+	}
+
+	before := time.Now()
+	time.Sleep(5 * time.Millisecond)
+	var bodies, hashes [][]byte
+	var times []time.Time
+	for i := 1; i <= 4; i++ {
+		publishTestNdf(t, state, "10.0.0."+strconv.Itoa(i)+":11420")
+		bodies = append(bodies, state.GetFullNdf().GetPb().Ndf)
+		hashes = append(hashes, state.GetFullNdf().GetHash())
+		time.Sleep(5 * time.Millisecond)
+		times = append(times, time.Now())
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	for i := range bodies {
+		body, err := state.GetNdfAt(times[i])
+		if err != nil {
+			t.Fatalf("Failed to get NDF %d by time: %+v", i, err)
+		}
+		if !bytes.Equal(body, bodies[i]) {
+			t.Errorf("NDF at the time of update %d is not the NDF it "+
+				"published.\nexpected: %s\nreceived: %s", i, bodies[i], body)
+		}
+
+		body, err = state.GetNdfByHash(hashes[i])
+		if err != nil {
+			t.Fatalf("Failed to get NDF %d by hash: %+v", i, err)
+		}
+		if !bytes.Equal(body, bodies[i]) {
+			t.Errorf("NDF with the hash of update %d is not the NDF it "+
+				"published.\nexpected: %s\nreceived: %s", i, bodies[i], body)
+		}
+	}
+
+	if _, err := state.GetNdfAt(before); err == nil {
+		t.Errorf("NDF returned from before the first was published.")
+	}
+	if _, err := state.GetNdfByHash([]byte("unknown")); err == nil {
+		t.Errorf("NDF returned for an unknown hash.")
+	}
+}
+
+// Tests that snapshots past the retention are deleted except for the NDF
+// which was still published once the retention ended, and that no snapshots
+// are kept unless enabled.
+func TestNetworkState_SetNdfSnapshots(t *testing.T) {
+	state := newNdfHistoryTestState(t, 0, "")
+	if err := state.SetNdfSnapshots(true, -time.Second); err == nil {
+		t.Errorf("Negative NDF snapshot retention accepted.")
+	}
+	if err := state.SetNdfSnapshots(true, 100*time.Millisecond); err != nil {
+		t.Fatalf("Failed to set NDF snapshots: %+v", err)
+	}
+
+	var hashes [][]byte
+	for i := 1; i <= 3; i++ {
+		if i == 3 {
+			time.Sleep(200 * time.Millisecond)
+		}
+		publishTestNdf(t, state, "10.0.0."+strconv.Itoa(i)+":11420")
+		hashes = append(hashes, state.GetFullNdf().GetHash())
+	}
+
+	if _, err := state.GetNdfByHash(hashes[0]); err == nil {
+		t.Errorf("NDF snapshot past the retention was not deleted.")
+	}
+	for i := 1; i < 3; i++ {
+		if _, err := state.GetNdfByHash(hashes[i]); err != nil {
+			t.Errorf("NDF snapshot %d was deleted: %+v", i, err)
+		}
+	}
+
+	if err := state.SetNdfSnapshots(false, 0); err != nil {
+		t.Fatalf("Failed to set NDF snapshots: %+v", err)
+	}
+	publishTestNdf(t, state, "10.0.0.4:11420")
+	if _, err := state.GetNdfByHash(state.GetFullNdf().GetHash()); err == nil {
+		t.Errorf("NDF snapshot kept while snapshots are disabled.")
+	}
+}
@@ -0,0 +1,160 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Handles pushing NDF and round updates to subscribed gateways as they happen
+
+package storage
+
+import (
+	"github.com/pkg/errors"
+	jww "github.com/spf13/jwalterweatherman"
+	pb "gitlab.com/elixxir/comms/mixmessages"
+	"sync"
+)
+
+// Smallest buffer a subscription may have, which leaves room for the resync
+// update sent when the subscriber falls behind
+const minNdfStreamBuffer = 2
+
+// NdfStreamUpdate is an update pushed to NDF stream subscribers. Either Ndf or
+// Round is set.
+type NdfStreamUpdate struct {
+	Ndf   *pb.NDF
+	Round *pb.RoundInfo
+
+	// Set when updates were dropped because the subscriber fell behind. Ndf
+	// holds the current NDF, if any, and the round updates which were missed
+	// must be fetched by polling.
+	Resync bool
+}
+
+// ndfStream tracks the subscribers to NDF and round updates and the latest
+// NDF sent to them
+type ndfStream struct {
+	subscribers map[uint64]*NdfSubscription
+	nextId      uint64
+	currentNdf  *pb.NDF
+	mux         sync.Mutex
+}
+
+// NdfSubscription receives the NDF and round updates pushed to a subscriber.
+type NdfSubscription struct {
+	id      uint64
+	updates chan *NdfStreamUpdate
+	resyncs uint64
+	stream  *ndfStream
+}
+
+// SubscribeNdfStream subscribes to NDF and round updates. The current NDF, if
+// any, is sent first, followed by every update as it happens. At most buffer
+// updates are held for the subscriber; if it falls further behind, the held
+// updates are dropped and replaced with a resync update holding the current
+// NDF.
+func (s *NetworkState) SubscribeNdfStream(buffer int) (*NdfSubscription, error) {
+	if buffer < minNdfStreamBuffer {
+		return nil, errors.Errorf("NDF stream buffer of %d is less than "+
+			"the minimum of %d", buffer, minNdfStreamBuffer)
+	}
+
+	stream := &s.ndfStream
+	stream.mux.Lock()
+	defer stream.mux.Unlock()
+
+	if stream.subscribers == nil {
+		stream.subscribers = make(map[uint64]*NdfSubscription)
+	}
+
+	sub := &NdfSubscription{
+		id:      stream.nextId,
+		updates: make(chan *NdfStreamUpdate, buffer),
+		stream:  stream,
+	}
+	stream.nextId++
+	stream.subscribers[sub.id] = sub
+
+	if stream.currentNdf != nil {
+		sub.updates <- &NdfStreamUpdate{Ndf: stream.currentNdf}
+	}
+
+	jww.INFO.Printf("NDF stream subscriber %d added, %d subscribed",
+		sub.id, len(stream.subscribers))
+	return sub, nil
+}
+
+// Updates returns the channel updates are pushed on. It is closed when the
+// subscription ends.
+func (sub *NdfSubscription) Updates() <-chan *NdfStreamUpdate {
+	return sub.updates
+}
+
+// GetResyncs returns the number of times updates were dropped because the
+// subscriber fell behind.
+func (sub *NdfSubscription) GetResyncs() uint64 {
+	sub.stream.mux.Lock()
+	defer sub.stream.mux.Unlock()
+	return sub.resyncs
+}
+
+// Unsubscribe stops updates from being pushed to the subscriber and closes
+// its update channel.
+func (sub *NdfSubscription) Unsubscribe() {
+	sub.stream.mux.Lock()
+	defer sub.stream.mux.Unlock()
+
+	if _, exists := sub.stream.subscribers[sub.id]; !exists {
+		return
+	}
+	delete(sub.stream.subscribers, sub.id)
+	close(sub.updates)
+}
+
+// publishNdf pushes the new NDF to every subscriber and keeps it as the
+// current NDF for new subscribers and resyncs.
+func (ns *ndfStream) publishNdf(ndf *pb.NDF) {
+	ns.mux.Lock()
+	defer ns.mux.Unlock()
+	ns.currentNdf = ndf
+	ns.publish(&NdfStreamUpdate{Ndf: ndf})
+}
+
+// publishRound pushes the round update to every subscriber.
+func (ns *ndfStream) publishRound(ri *pb.RoundInfo) {
+	ns.mux.Lock()
+	defer ns.mux.Unlock()
+	ns.publish(&NdfStreamUpdate{Round: ri})
+}
+
+// publish pushes the update to every subscriber without blocking. When a
+// subscriber's buffer is full, its held updates are replaced with a resync.
+// Must be called while holding mux.
+func (ns *ndfStream) publish(update *NdfStreamUpdate) {
+	for _, sub := range ns.subscribers {
+		select {
+		case sub.updates <- update:
+			continue
+		default:
+		}
+
+		// Drop the held updates, which the resync replaces
+	drain:
+		for {
+			select {
+			case <-sub.updates:
+			default:
+				break drain
+			}
+		}
+		sub.resyncs++
+		jww.WARN.Printf("NDF stream subscriber %d fell behind, dropping "+
+			"held updates and resyncing", sub.id)
+
+		sub.updates <- &NdfStreamUpdate{Ndf: ns.currentNdf, Resync: true}
+		if update.Ndf == nil {
+			sub.updates <- update
+		}
+	}
+}
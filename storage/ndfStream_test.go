@@ -0,0 +1,169 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package storage
+
+import (
+	pb "gitlab.com/elixxir/comms/mixmessages"
+	"gitlab.com/elixxir/primitives/states"
+	"gitlab.com/xx_network/primitives/ndf"
+	"testing"
+	"time"
+)
+
+// Receives the next update pushed to the subscriber or fails the test
+func receiveStreamUpdate(t *testing.T, sub *NdfSubscription) *NdfStreamUpdate {
+	select {
+	case update, ok := <-sub.Updates():
+		if !ok {
+			t.Fatalf("Subscription closed unexpectedly.")
+		}
+		return update
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timed out waiting for a pushed update.")
+	}
+	return nil
+}
+
+// Tests that a subscriber receives the current NDF followed by round and NDF
+// updates in the order they happen.
+func TestNetworkState_SubscribeNdfStream(t *testing.T) {
+	var err error
+	PermissioningDb, _, err = NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("Failed to create database: %+v", err)
+	}
+	state, _, err := generateTestNetworkState()
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	updateNdf := func(address string) {
+		state.UpdateInternalNdf(&ndf.NetworkDefinition{
+			Registration: ndf.Registration{Address: address}})
+		if err := state.UpdateOutputNdf(); err != nil {
+			t.Fatalf("Failed to update output NDF: %+v", err)
+		}
+	}
+	ndfAddress := func(update *NdfStreamUpdate) string {
+		if update.Ndf == nil {
+			t.Fatalf("Expected an NDF update, received: %+v", update)
+		}
+		definition, err := ndf.Unmarshal(update.Ndf.Ndf)
+		if err != nil {
+			t.Fatalf("Failed to unmarshal pushed NDF: %+v", err)
+		}
+		return definition.Registration.Address
+	}
+
+	updateNdf("first")
+	sub, err := state.SubscribeNdfStream(10)
+	if err != nil {
+		t.Fatalf("Failed to subscribe: %+v", err)
+	}
+
+	if address := ndfAddress(receiveStreamUpdate(t, sub)); address != "first" {
+		t.Errorf("Current NDF not sent first, received NDF for %s", address)
+	}
+
+	lastUpdateId := uint64(0)
+	for i := 0; i < 3; i++ {
+		err = state.AddRoundUpdate(&pb.RoundInfo{
+			ID:         0,
+			State:      uint32(states.PRECOMPUTING),
+			Timestamps: make([]uint64, states.NUM_STATES),
+		})
+		if err != nil {
+			t.Fatalf("Failed to add round update: %+v", err)
+		}
+		update := receiveStreamUpdate(t, sub)
+		if update.Round == nil || update.Round.UpdateID <= lastUpdateId {
+			t.Fatalf("Round update %d not pushed in order: %+v", i, update)
+		}
+		lastUpdateId = update.Round.UpdateID
+	}
+
+	updateNdf("second")
+	if address := ndfAddress(receiveStreamUpdate(t, sub)); address != "second" {
+		t.Errorf("Updated NDF not pushed, received NDF for %s", address)
+	}
+	if sub.GetResyncs() != 0 {
+		t.Errorf("Subscriber keeping up was resynced %d times",
+			sub.GetResyncs())
+	}
+}
+
+// Tests that a subscriber which falls behind has its held updates replaced
+// with a resync holding the current NDF.
+func TestNdfStream_publish_Resync(t *testing.T) {
+	state := &NetworkState{}
+	stream := &state.ndfStream
+
+	currentNdf := &pb.NDF{Ndf: []byte("current")}
+	stream.publishNdf(currentNdf)
+
+	sub, err := state.SubscribeNdfStream(3)
+	if err != nil {
+		t.Fatalf("Failed to subscribe: %+v", err)
+	}
+
+	// Fill the buffer behind the current NDF, then overflow it
+	for i := uint64(1); i <= 3; i++ {
+		stream.publishRound(&pb.RoundInfo{UpdateID: i})
+	}
+
+	if sub.GetResyncs() != 1 {
+		t.Errorf("Unexpected number of resyncs.\nexpected: %d\nreceived: %d",
+			1, sub.GetResyncs())
+	}
+
+	update := receiveStreamUpdate(t, sub)
+	if !update.Resync || update.Ndf != currentNdf {
+		t.Errorf("Expected a resync with the current NDF, received: %+v", update)
+	}
+	update = receiveStreamUpdate(t, sub)
+	if update.Round == nil || update.Round.UpdateID != 3 {
+		t.Errorf("Expected the update which overflowed after the resync, "+
+			"received: %+v", update)
+	}
+	if len(sub.Updates()) != 0 {
+		t.Errorf("Dropped updates still held: %d", len(sub.Updates()))
+	}
+}
+
+// Tests that an unsubscribed subscriber's channel is closed and that other
+// subscribers still receive updates.
+func TestNdfSubscription_Unsubscribe(t *testing.T) {
+	state := &NetworkState{}
+	first, err := state.SubscribeNdfStream(2)
+	if err != nil {
+		t.Fatalf("Failed to subscribe: %+v", err)
+	}
+	second, err := state.SubscribeNdfStream(2)
+	if err != nil {
+		t.Fatalf("Failed to subscribe: %+v", err)
+	}
+
+	first.Unsubscribe()
+	first.Unsubscribe()
+	state.ndfStream.publishRound(&pb.RoundInfo{UpdateID: 1})
+
+	if _, ok := <-first.Updates(); ok {
+		t.Errorf("Unsubscribed channel received an update.")
+	}
+	if update := receiveStreamUpdate(t, second); update.Round.UpdateID != 1 {
+		t.Errorf("Unexpected update: %+v", update)
+	}
+}
+
+// Error path: tests that a buffer too small to hold a resync is rejected.
+func TestNetworkState_SubscribeNdfStream_BufferTooSmall(t *testing.T) {
+	state := &NetworkState{}
+	if _, err := state.SubscribeNdfStream(1); err == nil {
+		t.Errorf("Subscribed with a buffer smaller than the minimum.")
+	}
+}
@@ -0,0 +1,146 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Handles coalescing the writes of NDFs published in quick succession to their
+// output files
+
+package storage
+
+import (
+	jww "github.com/spf13/jwalterweatherman"
+	"gitlab.com/xx_network/primitives/utils"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// NdfWriteStats counts the NDF output file writes requested and made.
+type NdfWriteStats struct {
+	Requested uint64
+	Written   uint64
+}
+
+// ndfWriter writes NDFs to their output files. When debounced, the writes
+// requested within the window are held and only the latest NDF of each file
+// is written once it ends, so that frequent NDF updates do not contend for
+// the files.
+type ndfWriter struct {
+	// How long writes are held for after the first one is requested, and the
+	// most random time added to each window so that the writes of several
+	// servers sharing the files spread out
+	window time.Duration
+	jitter time.Duration
+
+	// Latest data to write to each file, and the function stopping the timer
+	// writing it
+	pending   map[string][]byte
+	stopTimer func() bool
+	stats     NdfWriteStats
+	mux       sync.Mutex
+
+	// Starts the timer calling the function once the delay has passed and
+	// returns the function stopping it, time.AfterFunc if nil
+	afterFunc func(time.Duration, func()) func() bool
+
+	// Held while writing, so that an older NDF is never written after a newer
+	// one
+	writeMux sync.Mutex
+}
+
+// SetNdfWriteDebounce sets how long the NDF output file writes are held for
+// so that NDFs published within the window are written once, and the most
+// random time added to the window. A window of 0 writes each NDF at once.
+// Must be called before the NDF is first output.
+func (s *NetworkState) SetNdfWriteDebounce(window, jitter time.Duration) {
+	s.ndfWrites.mux.Lock()
+	defer s.ndfWrites.mux.Unlock()
+	s.ndfWrites.window = window
+	s.ndfWrites.jitter = jitter
+}
+
+// FlushNdfWrites writes the held NDFs at once, such as before shutting down.
+func (s *NetworkState) FlushNdfWrites() {
+	s.ndfWrites.mux.Lock()
+	if s.ndfWrites.stopTimer != nil {
+		s.ndfWrites.stopTimer()
+	}
+	s.ndfWrites.mux.Unlock()
+	s.ndfWrites.flush()
+}
+
+// GetNdfWriteStats returns the number of NDF output file writes requested and
+// made.
+func (s *NetworkState) GetNdfWriteStats() NdfWriteStats {
+	s.ndfWrites.mux.Lock()
+	defer s.ndfWrites.mux.Unlock()
+	return s.ndfWrites.stats
+}
+
+// write writes the data to the file, or holds it until the debounce window
+// ends, replacing any data held for the file before it.
+func (w *ndfWriter) write(path string, data []byte) {
+	w.mux.Lock()
+	w.stats.Requested++
+	if w.window == 0 {
+		w.mux.Unlock()
+		w.writeMux.Lock()
+		defer w.writeMux.Unlock()
+		w.writeFile(path, data)
+		return
+	}
+	defer w.mux.Unlock()
+
+	if w.pending == nil {
+		w.pending = make(map[string][]byte)
+	}
+	w.pending[path] = data
+	if w.stopTimer == nil {
+		delay := w.window
+		if w.jitter > 0 {
+			delay += time.Duration(rand.Int63n(int64(w.jitter)))
+		}
+		w.stopTimer = w.startTimer(delay, w.flush)
+	}
+}
+
+// startTimer calls f once the delay has passed and returns the function
+// stopping it. Must be called with mux held.
+func (w *ndfWriter) startTimer(delay time.Duration, f func()) func() bool {
+	if w.afterFunc != nil {
+		return w.afterFunc(delay, f)
+	}
+	return time.AfterFunc(delay, f).Stop
+}
+
+// flush writes the data held for each file.
+func (w *ndfWriter) flush() {
+	w.writeMux.Lock()
+	defer w.writeMux.Unlock()
+
+	w.mux.Lock()
+	pending := w.pending
+	w.pending = nil
+	w.stopTimer = nil
+	w.mux.Unlock()
+
+	for path, data := range pending {
+		w.writeFile(path, data)
+	}
+}
+
+// writeFile writes the data to the file, logging any error. Must be called
+// with writeMux held.
+func (w *ndfWriter) writeFile(path string, data []byte) {
+	err := utils.WriteFile(path, data, utils.FilePerms, utils.DirPerms)
+	if err != nil {
+		jww.ERROR.Printf("Unable to output NDF to file %s: %+v", path, err)
+		return
+	}
+	w.mux.Lock()
+	w.stats.Written++
+	w.mux.Unlock()
+}
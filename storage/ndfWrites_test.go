@@ -0,0 +1,91 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package storage
+
+import (
+	"gitlab.com/xx_network/primitives/ndf"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// Reads the node address of the full NDF output file
+func readOutputNdfAddress(t *testing.T, path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read NDF output file: %+v", err)
+	}
+	def, err := ndf.Unmarshal(data)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal NDF output file: %+v", err)
+	}
+	return def.Nodes[0].Address
+}
+
+// Tests that NDFs published in quick succession are written once the
+// debounce window ends, and that the latest NDF is the one written.
+func TestNetworkState_SetNdfWriteDebounce(t *testing.T) {
+	state := newNdfHistoryTestState(t, 0, "")
+	dir := t.TempDir()
+	state.fullNdfOutputPath = filepath.Join(dir, "ndf.json")
+	state.signedPartialNdfOutputPath = filepath.Join(dir, "partial.b64")
+	state.SetNdfWriteDebounce(50*time.Millisecond, 10*time.Millisecond)
+
+	// The window ends when the test fires the timer
+	var timers []func()
+	var delays []time.Duration
+	state.ndfWrites.afterFunc = func(delay time.Duration, f func()) func() bool {
+		timers = append(timers, f)
+		delays = append(delays, delay)
+		return func() bool { return true }
+	}
+
+	const published = 10
+	for i := 1; i <= published; i++ {
+		publishTestNdf(t, state, "10.0.0."+strconv.Itoa(i)+":11420")
+	}
+	if stats := state.GetNdfWriteStats(); stats.Written != 0 {
+		t.Errorf("NDFs written within the debounce window: %+v", stats)
+	}
+	if len(timers) != 1 || delays[0] < 50*time.Millisecond ||
+		delays[0] >= 60*time.Millisecond {
+		t.Fatalf("Expected a single window of 50ms plus up to 10ms of "+
+			"jitter, received %v", delays)
+	}
+
+	timers[0]()
+	if stats := state.GetNdfWriteStats(); stats.Requested != 2*published || stats.Written != 2 {
+		t.Errorf("Writes were not coalesced: %+v", stats)
+	}
+	if addr := readOutputNdfAddress(t, state.fullNdfOutputPath); addr != "10.0.0.10:11420" {
+		t.Errorf("Latest NDF was not written, found one for %s.", addr)
+	}
+	if _, err := os.Stat(state.signedPartialNdfOutputPath); err != nil {
+		t.Errorf("Signed partial NDF was not written: %+v", err)
+	}
+
+	// Held writes are flushed at once when shutting down
+	state.SetNdfWriteDebounce(time.Hour, 0)
+	publishTestNdf(t, state, "10.0.0.11:11420")
+	state.FlushNdfWrites()
+	if addr := readOutputNdfAddress(t, state.fullNdfOutputPath); addr != "10.0.0.11:11420" {
+		t.Errorf("Flushed NDF was not written, found one for %s.", addr)
+	}
+
+	// Without a window, each NDF is written at once
+	state.SetNdfWriteDebounce(0, 0)
+	publishTestNdf(t, state, "10.0.0.12:11420")
+	if addr := readOutputNdfAddress(t, state.fullNdfOutputPath); addr != "10.0.0.12:11420" {
+		t.Errorf("NDF was not written at once, found one for %s.", addr)
+	}
+	if stats := state.GetNdfWriteStats(); stats.Written != 6 {
+		t.Errorf("Unexpected NDF writes: %+v", stats)
+	}
+}
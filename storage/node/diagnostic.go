@@ -0,0 +1,155 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package node
+
+// Contains the tracking of rounds a Node failed before reporting STANDBY, the
+// diagnostic state Nodes which repeatedly do so are demoted to, and the log of
+// the reasons a Node was held out of the network
+
+import (
+	"time"
+)
+
+// Most failures before STANDBY and rejections kept for a Node
+const (
+	maxPrecompFailures = 64
+	RejectionLogLength = 32
+)
+
+// Rejection is an entry in a Node's rejection log, recording why it was held
+// out of the network.
+type Rejection struct {
+	Timestamp time.Time
+	Reason    string
+}
+
+// RecordPrecompFailure records that a round the Node was on failed at the
+// given time before the Node reported STANDBY. A clean round streak the Node
+// had in the diagnostic state is also restarted.
+func (n *State) RecordPrecompFailure(ts time.Time) {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+
+	n.precompFailures = append(n.precompFailures, ts)
+	if len(n.precompFailures) > maxPrecompFailures {
+		n.precompFailures = n.precompFailures[len(n.precompFailures)-maxPrecompFailures:]
+	}
+	n.diagnosticCleanRounds = 0
+}
+
+// CountPrecompFailures returns the number of rounds the Node failed before
+// reporting STANDBY at or after the given time.
+func (n *State) CountPrecompFailures(since time.Time) int {
+	n.mux.RLock()
+	defer n.mux.RUnlock()
+
+	count := 0
+	for _, ts := range n.precompFailures {
+		if !ts.Before(since) {
+			count++
+		}
+	}
+	return count
+}
+
+// FailDiagnosticRound restarts the clean round streak of a Node in the
+// diagnostic state after a round it was on failed.
+func (n *State) FailDiagnosticRound() {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+
+	n.diagnosticCleanRounds = 0
+}
+
+// Demote puts the Node in the diagnostic state as of the given time, for the
+// given reason, which is recorded in its rejection log. Its recorded failures
+// are cleared, so that only failures after the demotion count against it.
+func (n *State) Demote(ts time.Time, reason string) {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+
+	n.diagnostic = true
+	n.diagnosticSince = ts
+	n.diagnosticCleanRounds = 0
+	n.precompFailures = nil
+	n.recordRejection(ts, reason)
+}
+
+// CompleteDiagnosticRound records a clean round completed by a Node in the
+// diagnostic state, taking it out of the state once it has completed the
+// required number of clean rounds in a row. Returns true if it recovered.
+// Nodes are only taken out of the state manually when required is zero.
+func (n *State) CompleteDiagnosticRound(required uint32) bool {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+
+	if !n.diagnostic {
+		return false
+	}
+	n.diagnosticCleanRounds++
+	if required == 0 || n.diagnosticCleanRounds < required {
+		return false
+	}
+	n.diagnostic = false
+	return true
+}
+
+// Reactivate takes the Node out of the diagnostic state. Returns false if it
+// was not in it.
+func (n *State) Reactivate() bool {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+
+	wasDiagnostic := n.diagnostic
+	n.diagnostic = false
+	n.diagnosticCleanRounds = 0
+	n.precompFailures = nil
+	return wasDiagnostic
+}
+
+// IsDiagnostic returns true if the Node is in the diagnostic state.
+func (n *State) IsDiagnostic() bool {
+	n.mux.RLock()
+	defer n.mux.RUnlock()
+	return n.diagnostic
+}
+
+// GetDiagnostic returns whether the Node is in the diagnostic state, when it
+// was last demoted to it and the clean rounds it has completed in a row since.
+func (n *State) GetDiagnostic() (bool, time.Time, uint32) {
+	n.mux.RLock()
+	defer n.mux.RUnlock()
+	return n.diagnostic, n.diagnosticSince, n.diagnosticCleanRounds
+}
+
+// RecordRejection adds an entry to the Node's rejection log, dropping the
+// oldest entry once the log holds RejectionLogLength entries.
+func (n *State) RecordRejection(ts time.Time, reason string) {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+	n.recordRejection(ts, reason)
+}
+
+// recordRejection adds an entry to the rejection log. Must be called with the
+// lock held.
+func (n *State) recordRejection(ts time.Time, reason string) {
+	n.rejections = append(n.rejections, Rejection{ts, reason})
+	if len(n.rejections) > RejectionLogLength {
+		n.rejections = n.rejections[len(n.rejections)-RejectionLogLength:]
+	}
+}
+
+// GetRejections returns a copy of the Node's rejection log, oldest first.
+func (n *State) GetRejections() []Rejection {
+	n.mux.RLock()
+	defer n.mux.RUnlock()
+
+	rejections := make([]Rejection, len(n.rejections))
+	copy(rejections, n.rejections)
+	return rejections
+}
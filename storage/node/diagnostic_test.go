@@ -0,0 +1,88 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package node
+
+import (
+	"gitlab.com/xx_network/primitives/id"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// Tests that failures before STANDBY are counted within a window, cleared on
+// demotion, and that a demoted node recovers only after completing the
+// required clean rounds in a row.
+func TestState_Demote(t *testing.T) {
+	n := &State{id: id.NewIdFromUInt(0, id.Node, t)}
+	now := time.Now()
+	n.RecordPrecompFailure(now.Add(-time.Hour))
+	n.RecordPrecompFailure(now)
+	n.RecordPrecompFailure(now)
+	if count := n.CountPrecompFailures(now.Add(-time.Minute)); count != 2 {
+		t.Errorf("Counted %d failures in the window, expected 2.", count)
+	}
+	if n.CompleteDiagnosticRound(1) {
+		t.Errorf("Node not in the diagnostic state recovered.")
+	}
+
+	n.Demote(now, "reason")
+	if diagnostic, since, _ := n.GetDiagnostic(); !diagnostic || !since.Equal(now) {
+		t.Errorf("Node was not demoted as of %s.", now)
+	}
+	if count := n.CountPrecompFailures(time.Time{}); count != 0 {
+		t.Errorf("Demotion did not clear the failures, %d remain.", count)
+	}
+
+	if n.CompleteDiagnosticRound(2) {
+		t.Errorf("Node recovered after one of two clean rounds.")
+	}
+	n.FailDiagnosticRound()
+	if n.CompleteDiagnosticRound(2) {
+		t.Errorf("Failed round did not restart the clean round streak.")
+	}
+	if !n.CompleteDiagnosticRound(2) || n.IsDiagnostic() {
+		t.Errorf("Node did not recover after two clean rounds.")
+	}
+
+	n.Demote(now, "reason")
+	for i := 0; i < 10; i++ {
+		if n.CompleteDiagnosticRound(0) {
+			t.Fatalf("Node recovered without manual reactivation.")
+		}
+	}
+	if !n.Reactivate() || n.IsDiagnostic() {
+		t.Errorf("Node was not reactivated.")
+	}
+	if n.Reactivate() {
+		t.Errorf("Node not in the diagnostic state was reactivated.")
+	}
+}
+
+// Tests that the rejection log keeps the most recent RejectionLogLength
+// entries, oldest first.
+func TestState_GetRejections(t *testing.T) {
+	n := &State{}
+	for i := 0; i < RejectionLogLength+5; i++ {
+		n.RecordRejection(time.Unix(int64(i), 0), strconv.Itoa(i))
+	}
+
+	rejections := n.GetRejections()
+	if len(rejections) != RejectionLogLength {
+		t.Fatalf("Log has %d entries, expected %d.", len(rejections),
+			RejectionLogLength)
+	}
+	if rejections[0].Reason != "5" ||
+		rejections[RejectionLogLength-1].Reason != strconv.Itoa(RejectionLogLength+4) {
+		t.Errorf("Log does not hold the most recent entries: %+v", rejections)
+	}
+
+	rejections[0].Reason = "modified"
+	if n.GetRejections()[0].Reason == "modified" {
+		t.Errorf("Returned log is not a copy.")
+	}
+}
@@ -0,0 +1,76 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package node
+
+import "gitlab.com/xx_network/primitives/id"
+
+// Contains the enumeration of the reasons a Node is left out of a team when
+// the scheduler forms a round, and the per Node totals of them.
+
+type ExclusionReason uint8
+
+const (
+	ExcludedNotSelected  = ExclusionReason(iota) // In the pool, but another Node was picked
+	ExcludedOffline                              // Inactive or pruned from the NDF for not polling
+	ExcludedConnectivity                         // Failed its node or gateway connectivity check
+	ExcludedUnhealthy                            // Reported a critical health error
+	ExcludedProbation                            // Replaced to keep a second Node on probation out
+	ExcludedAvoidList                            // Replaced for being on a team member's avoid-list
+	ExcludedLowUptime                            // Waiting, but its uptime is below the minimum
+	ExcludedDiagnostic                           // Demoted for failing rounds before reporting STANDBY
+	NumExclusionReasons
+)
+
+// Stringer for the exclusion reason type
+func (r ExclusionReason) String() string {
+	switch r {
+	case ExcludedNotSelected:
+		return "NotSelected"
+	case ExcludedOffline:
+		return "Offline"
+	case ExcludedConnectivity:
+		return "Connectivity"
+	case ExcludedUnhealthy:
+		return "Unhealthy"
+	case ExcludedProbation:
+		return "Probation"
+	case ExcludedAvoidList:
+		return "AvoidList"
+	case ExcludedLowUptime:
+		return "LowUptime"
+	case ExcludedDiagnostic:
+		return "Diagnostic"
+	default:
+		return "Unknown"
+	}
+}
+
+// RecordExclusion counts the Node as left out of the team of the round with
+// the given ID for the reason. A Node is only counted once per round, for the
+// first reason recorded; returns false if it was already counted for the
+// round.
+func (n *State) RecordExclusion(reason ExclusionReason, roundID id.Round) bool {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+	if (n.excludedBefore && n.lastExclusionRound == roundID) ||
+		reason >= NumExclusionReasons {
+		return false
+	}
+	n.excludedBefore = true
+	n.lastExclusionRound = roundID
+	n.exclusions[reason]++
+	return true
+}
+
+// GetExclusions returns the number of times the Node was left out of a team
+// for each reason, indexed by ExclusionReason.
+func (n *State) GetExclusions() [NumExclusionReasons]uint64 {
+	n.mux.RLock()
+	defer n.mux.RUnlock()
+	return n.exclusions
+}
@@ -0,0 +1,52 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package node
+
+import (
+	"gitlab.com/xx_network/primitives/id"
+	"testing"
+)
+
+// Tests that the stringer of ExclusionReason is correct
+func TestExclusionReason_String(t *testing.T) {
+	expected := []string{"NotSelected", "Offline", "Connectivity",
+		"Unhealthy", "Probation", "AvoidList", "LowUptime", "Diagnostic",
+		"Unknown"}
+
+	for i := range expected {
+		r := ExclusionReason(i)
+		if r.String() != expected[i] {
+			t.Errorf("Stringer of exclusion reason %d incorrect."+
+				"\nexpected: %s\nreceived: %s", i, expected[i], r.String())
+		}
+	}
+}
+
+// Tests that RecordExclusion() counts a Node once per round
+func TestState_RecordExclusion(t *testing.T) {
+	n := &State{id: id.NewIdFromUInt(1, id.Node, t)}
+
+	if !n.RecordExclusion(ExcludedOffline, 0) {
+		t.Errorf("Exclusion in the first round was not counted.")
+	}
+	if n.RecordExclusion(ExcludedNotSelected, 0) {
+		t.Errorf("Second exclusion in the same round was counted.")
+	}
+	if !n.RecordExclusion(ExcludedOffline, 1) {
+		t.Errorf("Exclusion in the next round was not counted.")
+	}
+	if n.RecordExclusion(NumExclusionReasons, 2) {
+		t.Errorf("Exclusion for an unknown reason was counted.")
+	}
+
+	expected := [NumExclusionReasons]uint64{ExcludedOffline: 2}
+	if got := n.GetExclusions(); got != expected {
+		t.Errorf("Unexpected exclusions.\nexpected: %v\nreceived: %v",
+			expected, got)
+	}
+}
@@ -85,6 +85,24 @@ func (nsm *StateMap) AddBannedNode(id *id.ID, ordering, nAddr, gwAddr string) er
 	return nil
 }
 
+// Removes the Node state for the given id, used when a Node registers again
+// under a new ID or its registration is deleted. The state is marked removed
+// so that the scheduler drops it. Returns an error if it does not exist.
+func (nsm *StateMap) RemoveNode(id *id.ID) error {
+	nsm.mux.Lock()
+	defer nsm.mux.Unlock()
+
+	n, ok := nsm.nodeStates[*id]
+	if !ok {
+		return errors.New("cannot remove a Node which does not exist")
+	}
+	n.mux.Lock()
+	n.removed = true
+	n.mux.Unlock()
+	delete(nsm.nodeStates, *id)
+	return nil
+}
+
 // Returns the State object for the given id if it exists
 func (nsm *StateMap) GetNode(id *id.ID) *State {
 	nsm.mux.RLock()
@@ -106,6 +124,20 @@ func (nsm *StateMap) GetNodeStates() []*State {
 	return nodeStates
 }
 
+// GetNodesByActivity returns the IDs of the nodes whose current activity is
+// the given activity.
+func (nsm *StateMap) GetNodesByActivity(a current.Activity) []*id.ID {
+	nsm.mux.RLock()
+	defer nsm.mux.RUnlock()
+	var nodes []*id.ID
+	for _, nodeState := range nsm.nodeStates {
+		if nodeState.GetActivity() == a {
+			nodes = append(nodes, nodeState.GetID())
+		}
+	}
+	return nodes
+}
+
 // Returns the number of elements in the NodeMapo
 func (nsm *StateMap) Len() int {
 	nsm.mux.RLock()
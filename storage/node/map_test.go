@@ -52,6 +52,32 @@ func TestStateMap_AddNode_Happy(t *testing.T) {
 	}
 }
 
+// Tests that a Node is removed from the state map and that removing a Node
+// which does not exist returns an error
+func TestStateMap_RemoveNode(t *testing.T) {
+	sm := NewStateMap()
+
+	nid := id.NewIdFromUInt(2, id.Node, t)
+	if err := sm.AddNode(nid, "", "", "", 0); err != nil {
+		t.Fatalf("Failed to add Node: %s", err)
+	}
+
+	n := sm.GetNode(nid)
+	if err := sm.RemoveNode(nid); err != nil {
+		t.Errorf("Error returned on valid removal of Node: %s", err)
+	}
+	if sm.GetNode(nid) != nil {
+		t.Errorf("Node remains in the state map after removal")
+	}
+	if !n.IsRemoved() {
+		t.Errorf("Removed Node was not marked removed")
+	}
+
+	if err := sm.RemoveNode(nid); err == nil {
+		t.Errorf("No error returned on removal of a missing Node")
+	}
+}
+
 //Tests a Node is added correctly to the state map when it is
 func TestStateMap_AddNode_Invalid(t *testing.T) {
 	sm := &StateMap{
@@ -203,3 +229,37 @@ func TestStateMap_GetNodeStates(t *testing.T) {
 		t.Errorf("Incorrect number of nodes returned, got %d", len(nodeStates))
 	}
 }
+
+// Tests that only the nodes in the requested activity are returned.
+func TestStateMap_GetNodesByActivity(t *testing.T) {
+	sm := NewStateMap()
+	activities := []current.Activity{current.WAITING, current.REALTIME,
+		current.WAITING, current.PRECOMPUTING, current.REALTIME}
+	for i, a := range activities {
+		nid := id.NewIdFromUInt(uint64(i), id.Node, t)
+		if err := sm.AddNode(nid, "", "", "", 0); err != nil {
+			t.Fatalf("Unable to add node: %+v", err)
+		}
+		sm.GetNode(nid).activity = a
+	}
+
+	for a := current.Activity(0); a < current.NUM_STATES; a++ {
+		expected := make(map[id.ID]bool)
+		for i, nodeActivity := range activities {
+			if nodeActivity == a {
+				expected[*id.NewIdFromUInt(uint64(i), id.Node, t)] = true
+			}
+		}
+
+		nodes := sm.GetNodesByActivity(a)
+		if len(nodes) != len(expected) {
+			t.Errorf("Expected %d nodes in %s, received %d.", len(expected), a,
+				len(nodes))
+		}
+		for _, nid := range nodes {
+			if !expected[*nid] {
+				t.Errorf("Node %s is not in %s.", nid, a)
+			}
+		}
+	}
+}
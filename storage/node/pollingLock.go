@@ -0,0 +1,113 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package node
+
+// Contains the node's polling lock, which is handed off from the poll to the
+// scheduler along with the update the poll produced
+
+import (
+	jww "github.com/spf13/jwalterweatherman"
+	"sync"
+	"time"
+)
+
+// PollingLock is held by a node's poll and, if the poll produced an update,
+// until the scheduler handled it, so that the node's polls are handled one at
+// a time. Each time it is taken it is given a new hold, so that a hold
+// released by its watchdog is not released again once its update is handled.
+type PollingLock struct {
+	lock sync.Mutex
+
+	// Current hold of the lock, zero while it is not held, and the last hold
+	// given out
+	hold     uint64
+	lastHold uint64
+
+	// Releases the current hold if it is not released in time after it was
+	// handed off
+	watchdog *time.Timer
+
+	mux sync.Mutex
+}
+
+// Lock takes the polling lock, waiting until it is released.
+func (pl *PollingLock) Lock() {
+	pl.lock.Lock()
+
+	pl.mux.Lock()
+	defer pl.mux.Unlock()
+	pl.lastHold++
+	pl.hold = pl.lastHold
+}
+
+// Unlock releases the current hold of the polling lock. Unlike a sync.Mutex,
+// it does nothing if the lock is not held, since its hold may already have
+// been released by its watchdog.
+func (pl *PollingLock) Unlock() {
+	pl.release(0)
+}
+
+// handOff returns the current hold of the polling lock, zero if it is not
+// held, so that it can be released once the update it is handed off with is
+// handled. If the timeout is not zero, the hold is released after the timeout
+// and expired is called, unless it was released already.
+func (pl *PollingLock) handOff(timeout time.Duration, expired func()) uint64 {
+	pl.mux.Lock()
+	defer pl.mux.Unlock()
+
+	hold := pl.hold
+	if hold == 0 || timeout == 0 {
+		return hold
+	}
+	if pl.watchdog != nil {
+		pl.watchdog.Stop()
+	}
+	pl.watchdog = time.AfterFunc(timeout, func() {
+		if pl.release(hold) {
+			expired()
+		}
+	})
+	return hold
+}
+
+// release releases the given hold of the polling lock, or the current hold if
+// it is zero. Returns false if the hold was no longer held.
+func (pl *PollingLock) release(hold uint64) bool {
+	pl.mux.Lock()
+	defer pl.mux.Unlock()
+
+	if pl.hold == 0 || (hold != 0 && pl.hold != hold) {
+		return false
+	}
+	if pl.watchdog != nil {
+		pl.watchdog.Stop()
+		pl.watchdog = nil
+	}
+	pl.hold = 0
+	pl.lock.Unlock()
+	return true
+}
+
+// HandOffPollingLock returns the current hold of the node's polling lock, to
+// be sent with the update the poll holding it produced. If the timeout is not
+// zero and the hold is not released within it, it is released so that the
+// node's polls are not blocked forever by an update which was never handled.
+func (n *State) HandOffPollingLock(timeout time.Duration) uint64 {
+	return n.pollingLock.handOff(timeout, func() {
+		jww.ERROR.Printf("Polling lock of node %s was not released within "+
+			"%s of handing off its update, releasing it so that its polls "+
+			"are not blocked", n.GetID(), timeout)
+	})
+}
+
+// ReleasePollingLock releases the hold of the node's polling lock which was
+// handed off with an update, or its current hold if the hold is zero. Returns
+// false if the hold was already released.
+func (n *State) ReleasePollingLock(hold uint64) bool {
+	return n.pollingLock.release(hold)
+}
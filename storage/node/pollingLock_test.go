@@ -0,0 +1,92 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package node
+
+import (
+	"gitlab.com/xx_network/primitives/id"
+	"testing"
+	"time"
+)
+
+// newPollingLockTestNode returns a node whose polling lock is tested.
+func newPollingLockTestNode(t *testing.T) *State {
+	sm := NewStateMap()
+	nid := id.NewIdFromString("node", id.Node, t)
+	if err := sm.AddNode(nid, "", "", "", 0); err != nil {
+		t.Fatalf("Failed to add node: %+v", err)
+	}
+	return sm.GetNode(nid)
+}
+
+// lockWithin returns true if the polling lock is taken within the timeout.
+func lockWithin(pl *PollingLock, timeout time.Duration) bool {
+	locked := make(chan struct{})
+	go func() {
+		pl.Lock()
+		close(locked)
+	}()
+	select {
+	case <-locked:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// Tests that a hold which is handed off and not released in time is released
+// by its watchdog, and that releasing it once its update is handled does not
+// release the hold of the next poll.
+func TestState_HandOffPollingLock_Watchdog(t *testing.T) {
+	n := newPollingLockTestNode(t)
+
+	n.GetPollingLock().Lock()
+	hold := n.HandOffPollingLock(20 * time.Millisecond)
+	if hold == 0 {
+		t.Fatalf("Held polling lock was handed off without a hold.")
+	}
+	if !lockWithin(n.GetPollingLock(), time.Second) {
+		t.Fatalf("Polling lock was not released by its watchdog.")
+	}
+
+	if n.ReleasePollingLock(hold) {
+		t.Errorf("Hold released by its watchdog was released again.")
+	}
+	if lockWithin(n.GetPollingLock(), 50*time.Millisecond) {
+		t.Errorf("Stale release released the hold of the next poll.")
+	}
+	n.GetPollingLock().Unlock()
+}
+
+// Tests that a hold released in time is not released again by its watchdog
+// once the lock is taken by the next poll.
+func TestState_ReleasePollingLock(t *testing.T) {
+	n := newPollingLockTestNode(t)
+
+	n.GetPollingLock().Lock()
+	hold := n.HandOffPollingLock(20 * time.Millisecond)
+	if !n.ReleasePollingLock(hold) {
+		t.Fatalf("Handed off hold was not released.")
+	}
+
+	n.GetPollingLock().Lock()
+	if lockWithin(n.GetPollingLock(), 100*time.Millisecond) {
+		t.Errorf("Watchdog of a released hold released the next hold.")
+	}
+	n.GetPollingLock().Unlock()
+
+	// Releasing a lock which is not held does nothing
+	n = newPollingLockTestNode(t)
+	n.GetPollingLock().Unlock()
+	if n.ReleasePollingLock(0) {
+		t.Errorf("Polling lock which is not held was released.")
+	}
+	if hold = n.HandOffPollingLock(time.Millisecond); hold != 0 {
+		t.Errorf("Polling lock which is not held was handed off with "+
+			"hold %d.", hold)
+	}
+}
@@ -16,6 +16,10 @@ import (
 type Info struct {
 	RegCode string
 	Order   string
+
+	// IDs of the Applications whose Nodes the Node must not be teamed with,
+	// where each Application's ID is its position in the file starting at 1
+	Avoid []uint64
 }
 
 // LoadInfo opens a JSON file and marshals it into a slice of Info. An error is
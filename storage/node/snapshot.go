@@ -0,0 +1,109 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package node
+
+import (
+	"gitlab.com/elixxir/primitives/current"
+	"gitlab.com/elixxir/registration/storage/round"
+	"gitlab.com/xx_network/primitives/id"
+	"sync/atomic"
+	"time"
+)
+
+// Contains a consistent view of the commonly read fields of a node.State,
+// so that readers need not take its lock once per field.
+
+// Snapshot holds the commonly read fields of a State as they were at one
+// point in time. It is a copy and does not change with the State.
+type Snapshot struct {
+	ID            *id.ID
+	ApplicationID uint64
+
+	Status   Status
+	Activity current.Activity
+	// When the Node last changed activity
+	LastUpdate time.Time
+	// Raw connectivity, without moving it from unknown to verifying
+	Connectivity uint32
+
+	// Polls made during the current monitoring period
+	NumPolls   uint64
+	LastPoll   time.Time
+	LastActive time.Time
+
+	// Round the Node is in, nil if it is not in a round
+	CurrentRound *round.State
+
+	NodeAddress    string
+	PublicAddress  string
+	GatewayAddress string
+	Ordering       string
+
+	// Client facing port of the gateway, zero if it has only one address
+	GatewayClientPort uint16
+
+	// Address the Node's polls are observed to come from, and whether it
+	// differs from the advertised one
+	ObservedAddress string
+	AddressMismatch bool
+
+	OnProbation    bool
+	Diagnostic     bool
+	HealthCritical bool
+	Gatewayless    bool
+	Removed        bool
+}
+
+// Snapshot returns the commonly read fields of the Node, captured under a
+// single acquisition of its lock.
+func (n *State) Snapshot() Snapshot {
+	n.mux.RLock()
+	defer n.mux.RUnlock()
+	return Snapshot{
+		ID:            n.id,
+		ApplicationID: n.applicationID,
+
+		Status:       n.status,
+		Activity:     n.activity,
+		LastUpdate:   n.lastUpdate,
+		Connectivity: atomic.LoadUint32(n.connectivity),
+
+		NumPolls:   atomic.LoadUint64(n.numPolls),
+		LastPoll:   n.lastPoll,
+		LastActive: n.lastActive,
+
+		CurrentRound: n.currentRound,
+
+		NodeAddress:    n.nodeAddress,
+		PublicAddress:  n.publicAddress,
+		GatewayAddress: n.gatewayAddress,
+		Ordering:       n.ordering,
+
+		GatewayClientPort: n.gatewayClientPort,
+
+		ObservedAddress: n.observedAddress,
+		AddressMismatch: n.addressMismatch,
+
+		OnProbation:    n.onProbation,
+		Diagnostic:     n.diagnostic,
+		HealthCritical: n.healthCritical,
+		Gatewayless:    n.gatewayless,
+		Removed:        n.removed,
+	}
+}
+
+// InRound returns true if the Node was in a round, and the round.
+func (s Snapshot) InRound() (bool, *round.State) {
+	return s.CurrentRound != nil, s.CurrentRound
+}
+
+// GatewayClientAddress returns the address clients reached the Node's gateway
+// at.
+func (s Snapshot) GatewayClientAddress() string {
+	return gatewayClientAddress(s.GatewayAddress, s.GatewayClientPort)
+}
@@ -0,0 +1,157 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package node
+
+import (
+	"gitlab.com/elixxir/primitives/current"
+	"gitlab.com/elixxir/primitives/states"
+	"gitlab.com/elixxir/registration/storage/round"
+	"gitlab.com/xx_network/comms/connect"
+	"gitlab.com/xx_network/primitives/id"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Tests that Snapshot() captures the fields of the Node.
+func TestState_Snapshot(t *testing.T) {
+	nsm := NewStateMap()
+	nid := id.NewIdFromUInt(1, id.Node, t)
+	if err := nsm.AddNode(nid, "US", "1.2.3.4:11420", "1.2.3.4:22840", 7); err != nil {
+		t.Fatalf("Failed to add node: %+v", err)
+	}
+	n := nsm.GetNode(nid)
+	n.SetConnectivity(PortSuccessful)
+	n.IncrementNumPolls()
+	n.SetPublicAddress("5.6.7.8:11420")
+	n.SetProbation(true, 0, time.Now())
+	n.SetObservedAddress("9.9.9.9")
+	r := round.NewState_Testing(3, states.PRECOMPUTING,
+		connect.NewCircuit([]*id.ID{nid}), t)
+	if err := n.SetRound(r); err != nil {
+		t.Fatalf("Failed to set round: %+v", err)
+	}
+
+	s := n.Snapshot()
+	if !s.ID.Cmp(nid) || s.ApplicationID != 7 || s.Status != Active ||
+		s.Connectivity != PortSuccessful || s.NumPolls != 1 ||
+		s.NodeAddress != "1.2.3.4:11420" ||
+		s.GatewayAddress != "1.2.3.4:22840" ||
+		s.PublicAddress != "5.6.7.8:11420" || s.Ordering != "US" ||
+		s.ObservedAddress != "9.9.9.9:11420" || !s.AddressMismatch ||
+		!s.OnProbation || s.HealthCritical || s.Gatewayless || s.Removed {
+		t.Errorf("Snapshot does not match the node: %+v", s)
+	}
+	if inRound, sr := s.InRound(); !inRound || sr != r {
+		t.Errorf("Snapshot does not hold the node's round.")
+	}
+
+	// The snapshot does not change with the node
+	n.ClearRound()
+	if inRound, _ := s.InRound(); !inRound {
+		t.Errorf("Snapshot changed with the node.")
+	}
+}
+
+// Tests that snapshots never observe a torn combination of fields which are
+// changed together, while the Node is mutated concurrently. Run with the race
+// detector to also check the fields are read safely.
+func TestState_Snapshot_Concurrent(t *testing.T) {
+	nsm := NewStateMap()
+	nid := id.NewIdFromUInt(1, id.Node, t)
+	if err := nsm.AddNode(nid, "US", "", "", 0); err != nil {
+		t.Fatalf("Failed to add node: %+v", err)
+	}
+	n := nsm.GetNode(nid)
+	circuit := connect.NewCircuit([]*id.ID{nid})
+	precomputing := round.NewState_Testing(1, states.PRECOMPUTING, circuit, t)
+	realtime := round.NewState_Testing(2, states.REALTIME, circuit, t)
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			_ = n.ResumeRound(precomputing, current.PRECOMPUTING)
+			n.ClearRound()
+			_ = n.ResumeRound(realtime, current.REALTIME)
+			n.ClearRound()
+			n.IncrementNumPolls()
+			n.SetPublicAddress("5.6.7.8:11420")
+		}
+	}()
+
+	for i := 0; i < 10000; i++ {
+		s := n.Snapshot()
+		if (s.CurrentRound == precomputing && s.Activity != current.PRECOMPUTING) ||
+			(s.CurrentRound == realtime && s.Activity != current.REALTIME) {
+			t.Fatalf("Snapshot observed round %d with activity %s.",
+				s.CurrentRound.GetRoundID(), s.Activity)
+		}
+	}
+	close(stop)
+	wg.Wait()
+}
+
+// Reads the fields the poll path reads through individual getters, returning
+// the number of lock acquisitions this takes. GetID(), GetRawConnectivity() and
+// GetGatewayAddress() do not take the lock.
+func readPollFields(n *State) int {
+	_ = n.IsBanned()
+	_, _ = n.GetCurrentRound()
+	_ = n.GetID()
+	_ = n.GetRawConnectivity()
+	_ = n.GetPublicAddress()
+	_ = n.GetGatewayAddress()
+	_ = n.IsGatewayless()
+	return 4
+}
+
+// Benchmarks reading the fields the poll path reads through individual
+// getters, for comparison with BenchmarkState_Snapshot.
+func BenchmarkState_Getters(b *testing.B) {
+	nsm := NewStateMap()
+	nid := id.NewIdFromUInt(1, id.Node, b)
+	if err := nsm.AddNode(nid, "US", "", "", 0); err != nil {
+		b.Fatalf("Failed to add node: %+v", err)
+	}
+	n := nsm.GetNode(nid)
+
+	locks := 0
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		locks = readPollFields(n)
+	}
+	b.ReportMetric(float64(locks), "locks/op")
+}
+
+// Benchmarks reading the fields the poll path reads from a snapshot, which
+// takes the lock once.
+func BenchmarkState_Snapshot(b *testing.B) {
+	nsm := NewStateMap()
+	nid := id.NewIdFromUInt(1, id.Node, b)
+	if err := nsm.AddNode(nid, "US", "", "", 0); err != nil {
+		b.Fatalf("Failed to add node: %+v", err)
+	}
+	n := nsm.GetNode(nid)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s := n.Snapshot()
+		_, _ = s.InRound()
+	}
+	b.ReportMetric(1, "locks/op")
+}
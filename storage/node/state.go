@@ -10,6 +10,7 @@ package node
 import (
 	"bytes"
 	"github.com/pkg/errors"
+	pb "gitlab.com/elixxir/comms/mixmessages"
 	"gitlab.com/elixxir/crypto/nike"
 	"gitlab.com/elixxir/crypto/nike/ecdh"
 	"gitlab.com/elixxir/primitives/current"
@@ -17,6 +18,8 @@ import (
 	"gitlab.com/elixxir/registration/storage/round"
 	"gitlab.com/elixxir/registration/transition"
 	"gitlab.com/xx_network/primitives/id"
+	"net"
+	"strconv"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -25,6 +28,24 @@ import (
 
 const ipUpdateTimeout = 30 * time.Minute
 
+// MaxCapacity is the largest capacity hint a Node may report. Larger reports
+// are clamped to it so a Node cannot claim arbitrary capacity.
+const MaxCapacity uint32 = 1 << 14
+
+// MaxPollSources is the most distinct addresses a Node's polls are kept as
+// having come from, bounding the memory a Node alternating sources can use
+const MaxPollSources = 16
+
+// PollHistoryLength is the number of past monitoring periods a Node's poll
+// counts are kept for
+const PollHistoryLength = 12
+
+// PollSource is a distinct address a Node's polls were received from.
+type PollSource struct {
+	Address  string
+	LastSeen time.Time
+}
+
 // Enumeration of connectivity statuses for a node
 const (
 	PortUnknown uint32 = iota
@@ -61,6 +82,12 @@ type State struct {
 	// Number of polls made by the node during the current monitoring period
 	numPolls *uint64
 
+	// Ring of the poll counts of the last PollHistoryLength monitoring
+	// periods, and the index the next count is written to
+	pollHistory     [PollHistoryLength]uint64
+	pollHistoryNext int
+	pollHistorySize int
+
 	// Order string to be used in team configuration
 	ordering string
 
@@ -78,10 +105,101 @@ type State struct {
 	nodeAddress      string
 	lastNodeUpdateTS time.Time
 
+	// Public, client facing address of the node, if it reported one separate
+	// from its inter-node address
+	publicAddress string
+
 	// Address of gateway
 	gatewayAddress      string
 	lastGatewayUpdateTS time.Time
 
+	// Port the gateway serves clients on, if it reported one separate from
+	// the port of its node facing address; zero otherwise
+	gatewayClientPort uint16
+
+	// Address the Node's polls are observed to come from, with the port of
+	// its advertised address, and whether it differs from the advertised one
+	observedAddress string
+	addressMismatch bool
+
+	// Distinct addresses the Node's polls were received from within the
+	// source window, least recently seen first, and whether more were seen
+	// than expected
+	pollSources       []PollSource
+	pollSourceAnomaly bool
+
+	// Advisory batch size the Node reports it can process, used to prefer
+	// higher capacity Nodes for larger batches
+	capacity uint32
+
+	// Fraction of the recent node metric intervals in which the Node polled,
+	// used to keep unreliable Nodes out of teams
+	uptime float64
+
+	// Whether the Node is on probation after registering, the rounds it has
+	// completed on probation and when it completed the first of them
+	onProbation     bool
+	probationRounds uint32
+	probationSince  time.Time
+
+	// Highest update ID the Node reported having received, used to keep its
+	// update cursor from going backwards
+	updateCursor uint64
+
+	// Round updates last served to the Node, the update ID they were served
+	// after and the newest update ID when they were served, so that a Node
+	// repeating its request is answered without reading the update buffer
+	// again
+	cachedUpdates      []*pb.RoundInfo
+	cachedUpdatesAfter int
+	cachedUpdatesUntil int
+
+	// Whether the Node reported it relays the updates it is served to its
+	// gateway
+	relaysUpdates bool
+
+	// Highest round update ID the Node reported its gateway confirmed
+	// processing, and whether it reported one
+	gatewayAck         uint64
+	gatewayAckReported bool
+
+	// Whether the Node runs without a public gateway, so that it has no
+	// gateway to probe or publish in the NDF
+	gatewayless bool
+
+	// When the Node last polled while banned and the poll was answered, used
+	// to rate limit banned Nodes which keep polling
+	lastBannedPoll time.Time
+
+	// Versions last reported by the Node's server and gateway
+	serverVersion  string
+	gatewayVersion string
+
+	// Whether the Node reported a critical health error and has not reported
+	// healthy since, which keeps it out of scheduling
+	healthCritical bool
+
+	// Whether the Node was removed from the node map, so that the scheduler
+	// drops it from the waiting pool
+	removed bool
+
+	// Times the Node was left out of a team for each reason, and the round
+	// it was last counted for, if it was ever counted
+	exclusions         [NumExclusionReasons]uint64
+	lastExclusionRound id.Round
+	excludedBefore     bool
+
+	// Times the Node's most recent rounds failed before it reported STANDBY,
+	// whether it was demoted to the diagnostic state for them, when, and the
+	// clean rounds it has completed since
+	precompFailures       []time.Time
+	diagnostic            bool
+	diagnosticSince       time.Time
+	diagnosticCleanRounds uint32
+
+	// Most recent reasons the Node was held out of the network, oldest first
+	rejections []Rejection
+
 	// when a Node poll is received, this nodes polling lock is. If
 	// there is no update, it is released in this endpoint, otherwise it is
 	// released in the scheduling algorithm which blocks all future polls until
@@ -89,11 +207,14 @@ type State struct {
 	//FIXME: it is possible that polling lock and registration lock
 	// do the same job and could conflict. reconsideration of this logic
 	// may be fruitful
-	pollingLock sync.Mutex
+	pollingLock PollingLock
 
 	// Status of node's connectivity, i.e. whether the node
 	// has port forwarding
 	connectivity *uint32
+	// When restored or failed connectivity is next checked again, zero once
+	// it has been or if no check is scheduled
+	reprobeAt time.Time
 
 	ed25519 nike.PublicKey
 }
@@ -108,6 +229,36 @@ func (n *State) GetAndResetNumPolls() uint64 {
 	return atomic.SwapUint64(n.numPolls, 0)
 }
 
+// AdvancePollInterval ends the current monitoring period, returning the number
+// of polls made during it and recording it in the poll history. Each poll is
+// counted in exactly one period.
+func (n *State) AdvancePollInterval() uint64 {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+
+	count := atomic.SwapUint64(n.numPolls, 0)
+	n.pollHistory[n.pollHistoryNext] = count
+	n.pollHistoryNext = (n.pollHistoryNext + 1) % PollHistoryLength
+	if n.pollHistorySize < PollHistoryLength {
+		n.pollHistorySize++
+	}
+	return count
+}
+
+// GetPollHistory returns the poll counts of up to the last PollHistoryLength
+// monitoring periods, oldest first.
+func (n *State) GetPollHistory() []uint64 {
+	n.mux.RLock()
+	defer n.mux.RUnlock()
+
+	history := make([]uint64, n.pollHistorySize)
+	start := n.pollHistoryNext - n.pollHistorySize + PollHistoryLength
+	for i := range history {
+		history[i] = n.pollHistory[(start+i)%PollHistoryLength]
+	}
+	return history
+}
+
 func (n *State) SetNumPollsTesting(num int, x interface{}) {
 	// Ensure that this function is only run in testing environments
 	switch x.(type) {
@@ -154,6 +305,7 @@ func (n *State) Ban() (UpdateNotification, error) {
 		ToStatus:     n.status,
 		FromActivity: n.activity,
 		ToActivity:   n.activity,
+		Created:      time.Now(),
 	}
 
 	return nun, nil
@@ -237,6 +389,7 @@ func (n *State) Update(newActivity current.Activity) (bool, UpdateNotification,
 		ToStatus:     n.status,
 		FromActivity: oldActivity,
 		ToActivity:   newActivity,
+		Created:      n.lastUpdate,
 	}
 
 	return true, nun, nil
@@ -256,6 +409,19 @@ func (n *State) GetStatus() Status {
 	return n.status
 }
 
+// Unban lifts the Node's ban. The Node is left inactive, so it only returns to
+// scheduling once it polls waiting.
+func (n *State) Unban() error {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+
+	if n.status != Banned {
+		return errors.New("cannot unban a Node which is not banned")
+	}
+	n.status = Inactive
+	return nil
+}
+
 // Gets if the Node is banned from the network
 func (n *State) IsBanned() bool {
 	n.mux.RLock()
@@ -285,6 +451,86 @@ func (n *State) SetConnectivity(c uint32) {
 	atomic.StoreUint32(n.connectivity, c)
 }
 
+// RestoreConnectivity sets the connectivity of the node to the last known
+// connectivity c, which is trusted until reprobeAt. Only the results of a
+// connectivity check are restored.
+func (n *State) RestoreConnectivity(c uint32, reprobeAt time.Time) {
+	switch c {
+	case PortSuccessful, NodePortFailed, GatewayPortFailed, PortFailed:
+	default:
+		return
+	}
+
+	n.mux.Lock()
+	defer n.mux.Unlock()
+	n.reprobeAt = reprobeAt
+	atomic.StoreUint32(n.connectivity, c)
+}
+
+// CheckReprobe marks restored or failed connectivity as unknown once its
+// reprobe time has passed, so that it is checked again. Returns true if it was.
+func (n *State) CheckReprobe(now time.Time) bool {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+	if n.reprobeAt.IsZero() || now.Before(n.reprobeAt) {
+		return false
+	}
+	n.reprobeAt = time.Time{}
+	atomic.StoreUint32(n.connectivity, PortUnknown)
+	return true
+}
+
+// ScheduleReprobe marks the connectivity as unknown at the given time, so that
+// a failed connectivity check is repeated once it has passed. A reprobe which
+// is already scheduled is kept.
+func (n *State) ScheduleReprobe(at time.Time) {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+	if n.reprobeAt.IsZero() {
+		n.reprobeAt = at
+	}
+}
+
+// SetHealthCritical sets whether the Node is kept out of scheduling for a
+// critical health error. Returns true if it changed.
+func (n *State) SetHealthCritical(critical bool) bool {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+	changed := n.healthCritical != critical
+	n.healthCritical = critical
+	return changed
+}
+
+// IsHealthCritical returns true if the Node reported a critical health error
+// and has not reported healthy since.
+func (n *State) IsHealthCritical() bool {
+	n.mux.RLock()
+	defer n.mux.RUnlock()
+	return n.healthCritical
+}
+
+// IsRemoved returns true if the Node was removed from the node map.
+func (n *State) IsRemoved() bool {
+	n.mux.RLock()
+	defer n.mux.RUnlock()
+	return n.removed
+}
+
+// GetTopologyPosition returns the index of the Node in the topology of its
+// current round, and false if it is not in a round.
+func (n *State) GetTopologyPosition() (int, bool) {
+	n.mux.RLock()
+	defer n.mux.RUnlock()
+	if n.currentRound == nil {
+		return 0, false
+	}
+	position := n.currentRound.GetTopology().GetNodeLocation(n.id)
+	if position < 0 {
+		return 0, false
+	}
+	return position, true
+}
+
 // Designates the node as offline
 func (n *State) SetInactive() {
 	n.mux.RLock()
@@ -318,6 +564,21 @@ func (n *State) SetLastActive() {
 	n.lastActive = time.Now()
 }
 
+// ClampLastActive sets the last active time to now if it is more than the
+// tolerance after now, so that a future last active time cannot keep the node
+// from being pruned. Returns the last active time it was clamped from, or the
+// zero time if it was not clamped.
+func (n *State) ClampLastActive(now time.Time, tolerance time.Duration) time.Time {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+	if n.lastActive.Sub(now) <= tolerance {
+		return time.Time{}
+	}
+	clamped := n.lastActive
+	n.lastActive = now
+	return clamped
+}
+
 func (n *State) SetLastActiveTesting(tm time.Time, x interface{}) {
 	// Ensure that this function is only run in testing environments
 	switch x.(type) {
@@ -331,7 +592,7 @@ func (n *State) SetLastActiveTesting(tm time.Time, x interface{}) {
 }
 
 // Returns the polling lock
-func (n *State) GetPollingLock() *sync.Mutex {
+func (n *State) GetPollingLock() *PollingLock {
 	return &n.pollingLock
 }
 
@@ -363,6 +624,393 @@ func (n *State) GetNodeAddresses() string {
 	return n.nodeAddress
 }
 
+// SetPublicAddress stores the public address reported by the Node, empty if it
+// reports only its inter-node address. Returns true if it changed.
+func (n *State) SetPublicAddress(address string) bool {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+
+	changed := n.publicAddress != address
+	n.publicAddress = address
+	return changed
+}
+
+// GetPublicAddress returns the public address reported by the Node, or an
+// empty string if it has only its inter-node address.
+func (n *State) GetPublicAddress() string {
+	n.mux.RLock()
+	defer n.mux.RUnlock()
+
+	return n.publicAddress
+}
+
+// SetGatewayClientPort stores the client facing port reported for the Node's
+// gateway, zero if the gateway serves clients on its node facing address.
+// Returns true if it changed.
+func (n *State) SetGatewayClientPort(port uint16) bool {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+
+	changed := n.gatewayClientPort != port
+	n.gatewayClientPort = port
+	return changed
+}
+
+// GetGatewayClientPort returns the client facing port reported for the Node's
+// gateway, or zero if it has only its node facing address.
+func (n *State) GetGatewayClientPort() uint16 {
+	n.mux.RLock()
+	defer n.mux.RUnlock()
+
+	return n.gatewayClientPort
+}
+
+// GetGatewayClientAddress returns the address clients reach the Node's gateway
+// at, which is its node facing address unless it reported a client port.
+func (n *State) GetGatewayClientAddress() string {
+	n.mux.RLock()
+	defer n.mux.RUnlock()
+
+	return gatewayClientAddress(n.gatewayAddress, n.gatewayClientPort)
+}
+
+// gatewayClientAddress returns the gateway address with its port replaced by
+// the client port. The address is returned unchanged if there is no client
+// port or it has no port to replace.
+func gatewayClientAddress(address string, clientPort uint16) string {
+	if clientPort == 0 {
+		return address
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return address
+	}
+	return net.JoinHostPort(host, strconv.Itoa(int(clientPort)))
+}
+
+// SetObservedAddress records the IP address the Node's poll was received from
+// and compares it against the Node's advertised address. The advertised port
+// is kept for the observed address. Returns true if the advertised address is
+// an IP which differs from the observed one. Advertised domain names are
+// never flagged as mismatched.
+func (n *State) SetObservedAddress(observedIp string) bool {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+
+	advertisedHost, port, err := net.SplitHostPort(n.nodeAddress)
+	if err != nil {
+		advertisedHost, port = n.nodeAddress, ""
+	}
+
+	if port != "" {
+		n.observedAddress = net.JoinHostPort(observedIp, port)
+	} else {
+		n.observedAddress = observedIp
+	}
+
+	advertisedIp, observed := net.ParseIP(advertisedHost), net.ParseIP(observedIp)
+	n.addressMismatch = advertisedIp != nil && observed != nil &&
+		!advertisedIp.Equal(observed)
+	return n.addressMismatch
+}
+
+// GetObservedAddress returns the address the Node's polls are observed to
+// come from and whether it differs from the Node's advertised address.
+func (n *State) GetObservedAddress() (string, bool) {
+	n.mux.RLock()
+	defer n.mux.RUnlock()
+
+	return n.observedAddress, n.addressMismatch
+}
+
+// RecordPollSource records that a poll was received from the address and
+// drops the sources not seen within the window. When MaxPollSources are
+// already kept, the least recently seen is dropped. Returns the number of
+// distinct sources seen within the window.
+func (n *State) RecordPollSource(address string, now time.Time, window time.Duration) int {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+
+	cutoff := now.Add(-window)
+	kept := n.pollSources[:0]
+	for _, source := range n.pollSources {
+		if source.Address != address && source.LastSeen.After(cutoff) {
+			kept = append(kept, source)
+		}
+	}
+	kept = append(kept, PollSource{Address: address, LastSeen: now})
+	if len(kept) > MaxPollSources {
+		kept = kept[len(kept)-MaxPollSources:]
+	}
+	n.pollSources = kept
+	return len(kept)
+}
+
+// GetPollSources returns the distinct addresses the Node's polls were received
+// from, least recently seen first.
+func (n *State) GetPollSources() []PollSource {
+	n.mux.RLock()
+	defer n.mux.RUnlock()
+
+	sources := make([]PollSource, len(n.pollSources))
+	copy(sources, n.pollSources)
+	return sources
+}
+
+// SetPollSourceAnomaly records whether the Node's polls came from more
+// sources than expected. Returns true if this changed.
+func (n *State) SetPollSourceAnomaly(anomalous bool) bool {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+
+	changed := n.pollSourceAnomaly != anomalous
+	n.pollSourceAnomaly = anomalous
+	return changed
+}
+
+// ClearPollSources forgets the addresses the Node's polls were received from
+// and whether they were anomalous, such as when the Node registers again.
+func (n *State) ClearPollSources() {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+
+	n.pollSources = nil
+	n.pollSourceAnomaly = false
+}
+
+// SetCapacity stores the capacity hint reported by the Node, clamped to
+// MaxCapacity. Returns the stored capacity.
+func (n *State) SetCapacity(capacity uint32) uint32 {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+
+	if capacity > MaxCapacity {
+		capacity = MaxCapacity
+	}
+	n.capacity = capacity
+	return capacity
+}
+
+// GetCapacity returns the capacity hint reported by the Node, or zero if it
+// has not reported one.
+func (n *State) GetCapacity() uint32 {
+	n.mux.RLock()
+	defer n.mux.RUnlock()
+
+	return n.capacity
+}
+
+// SetUptime stores the fraction of the recent node metric intervals in which
+// the Node polled.
+func (n *State) SetUptime(uptime float64) {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+
+	n.uptime = uptime
+}
+
+// GetUptime returns the fraction of the recent node metric intervals in which
+// the Node polled, or zero if none were recorded.
+func (n *State) GetUptime() float64 {
+	n.mux.RLock()
+	defer n.mux.RUnlock()
+
+	return n.uptime
+}
+
+// SetProbation sets whether the Node is on probation, the rounds it has
+// completed on probation and when it completed the first of them.
+func (n *State) SetProbation(onProbation bool, rounds uint32, since time.Time) {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+
+	n.onProbation = onProbation
+	n.probationRounds = rounds
+	n.probationSince = since
+}
+
+// GetProbation returns whether the Node is on probation, the rounds it has
+// completed on probation and when it completed the first of them.
+func (n *State) GetProbation() (bool, uint32, time.Time) {
+	n.mux.RLock()
+	defer n.mux.RUnlock()
+
+	return n.onProbation, n.probationRounds, n.probationSince
+}
+
+// IsOnProbation returns true if the Node is on probation.
+func (n *State) IsOnProbation() bool {
+	n.mux.RLock()
+	defer n.mux.RUnlock()
+
+	return n.onProbation
+}
+
+// CompleteProbationRound records a round the Node on probation completed. The
+// Node graduates once it has completed the given number of rounds and the
+// given duration has passed since the first of them. Returns true if the Node
+// graduated.
+func (n *State) CompleteProbationRound(now time.Time, rounds uint32,
+	duration time.Duration) bool {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+
+	if !n.onProbation {
+		return false
+	}
+	if n.probationSince.IsZero() {
+		n.probationSince = now
+	}
+	n.probationRounds++
+	if n.probationRounds < rounds || now.Sub(n.probationSince) < duration {
+		return false
+	}
+
+	n.onProbation = false
+	return true
+}
+
+// ExtendProbation restarts the probation of the Node after it failed a round,
+// so that its rounds and duration are counted again from its next round.
+// Returns true if the Node was on probation.
+func (n *State) ExtendProbation() bool {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+
+	if !n.onProbation {
+		return false
+	}
+	n.probationRounds = 0
+	n.probationSince = time.Time{}
+	return true
+}
+
+// AdvanceUpdateCursor records the update ID the Node reported having received
+// and returns the one to serve updates after, which never goes below the
+// highest reported so far. Returns true if the reported ID was lower and so
+// was raised to it.
+func (n *State) AdvanceUpdateCursor(cursor uint64) (uint64, bool) {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+
+	if cursor < n.updateCursor {
+		return n.updateCursor, true
+	}
+	n.updateCursor = cursor
+	return cursor, false
+}
+
+// GetCachedUpdates returns the round updates last served to the Node if they
+// were served after the given update ID while the given update ID was the
+// newest, so that no update has been added since.
+func (n *State) GetCachedUpdates(after, newest int) ([]*pb.RoundInfo, bool) {
+	n.mux.RLock()
+	defer n.mux.RUnlock()
+	if n.cachedUpdates == nil || n.cachedUpdatesAfter != after ||
+		n.cachedUpdatesUntil != newest {
+		return nil, false
+	}
+	return n.cachedUpdates, true
+}
+
+// SetCachedUpdates records the round updates served to the Node after the
+// given update ID while the given update ID was the newest.
+func (n *State) SetCachedUpdates(after, newest int, updates []*pb.RoundInfo) {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+	n.cachedUpdates = updates
+	n.cachedUpdatesAfter = after
+	n.cachedUpdatesUntil = newest
+}
+
+// SetRelaysUpdates records whether the Node relays the updates it is served to
+// its gateway.
+func (n *State) SetRelaysUpdates(relays bool) {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+	n.relaysUpdates = relays
+}
+
+// RelaysUpdates returns true if the Node reported it relays the updates it is
+// served to its gateway.
+func (n *State) RelaysUpdates() bool {
+	n.mux.RLock()
+	defer n.mux.RUnlock()
+	return n.relaysUpdates
+}
+
+// SetGatewayAck records the highest round update ID the Node reported its
+// gateway confirmed processing.
+func (n *State) SetGatewayAck(updateID uint64) {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+	n.gatewayAck = updateID
+	n.gatewayAckReported = true
+}
+
+// GetGatewayAck returns the highest round update ID the Node reported its
+// gateway confirmed processing, and false if it never reported one.
+func (n *State) GetGatewayAck() (uint64, bool) {
+	n.mux.RLock()
+	defer n.mux.RUnlock()
+	return n.gatewayAck, n.gatewayAckReported
+}
+
+// SetGatewayless records whether the Node runs without a public gateway.
+func (n *State) SetGatewayless(gatewayless bool) {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+	n.gatewayless = gatewayless
+}
+
+// IsGatewayless returns true if the Node runs without a public gateway.
+func (n *State) IsGatewayless() bool {
+	n.mux.RLock()
+	defer n.mux.RUnlock()
+	return n.gatewayless
+}
+
+// AllowBannedPoll records a poll from the banned Node at the given time and
+// returns true if it is to be answered, which it is if no answered poll came
+// within the interval before it. Polls which are not answered are not
+// recorded, so a Node polling continuously is still answered once an interval.
+func (n *State) AllowBannedPoll(now time.Time, interval time.Duration) bool {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+
+	if !n.lastBannedPoll.IsZero() && now.Sub(n.lastBannedPoll) < interval {
+		return false
+	}
+	n.lastBannedPoll = now
+	return true
+}
+
+// UpdateVersions stores the server and gateway versions reported by the Node.
+// An empty gateway version, sent when the server polls before its gateway is
+// up, keeps the previous one. Returns true if either version changed.
+func (n *State) UpdateVersions(serverVersion, gatewayVersion string) bool {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+
+	if gatewayVersion == "" {
+		gatewayVersion = n.gatewayVersion
+	}
+	if n.serverVersion == serverVersion && n.gatewayVersion == gatewayVersion {
+		return false
+	}
+	n.serverVersion, n.gatewayVersion = serverVersion, gatewayVersion
+	return true
+}
+
+// GetVersions returns the server and gateway versions last reported by the
+// Node.
+func (n *State) GetVersions() (string, string) {
+	n.mux.RLock()
+	defer n.mux.RUnlock()
+
+	return n.serverVersion, n.gatewayVersion
+}
+
 // UpdateGatewayAddresses updates the address if it is warranted
 func (n *State) UpdateGatewayAddresses(gateway string) (bool, error) {
 	n.mux.Lock()
@@ -460,6 +1108,23 @@ func (n *State) SetRound(r *round.State) error {
 	return nil
 }
 
+// ResumeRound places the Node back in a round it was in before a restart,
+// along with the activity the Node had in that round. It errors if the Node is
+// already in a round.
+func (n *State) ResumeRound(r *round.State, activity current.Activity) error {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+	if n.currentRound != nil {
+		return errors.Errorf("could not resume round %v for Node %s when "+
+			"it is already in round %v", r.GetRoundID(), n.id,
+			n.currentRound.GetRoundID())
+	}
+
+	n.currentRound = r
+	n.activity = activity
+	return nil
+}
+
 // Handles the node update in the case of a node with an inactive state
 func (n *State) updateInactive(newActivity current.Activity) (bool, UpdateNotification, error) {
 	switch newActivity {
@@ -473,6 +1138,7 @@ func (n *State) updateInactive(newActivity current.Activity) (bool, UpdateNotifi
 			ToStatus:     Active,
 			FromActivity: oldActivity,
 			ToActivity:   newActivity,
+			Created:      time.Now(),
 		}
 		return true, nun, nil
 	case current.ERROR:
@@ -8,9 +8,11 @@
 package node
 
 import (
+	"fmt"
 	"gitlab.com/elixxir/primitives/current"
 	"gitlab.com/elixxir/primitives/states"
 	"gitlab.com/elixxir/registration/storage/round"
+	"gitlab.com/xx_network/comms/connect"
 	"gitlab.com/xx_network/primitives/id"
 	"math"
 	"reflect"
@@ -78,6 +80,79 @@ func TestState_SetConnectivity(t *testing.T) {
 	}
 }
 
+// Tests that only the results of a connectivity check are restored, and that
+// restored connectivity is marked unknown once its reprobe time passes.
+func TestState_RestoreConnectivity(t *testing.T) {
+	con := PortUnknown
+	ns := State{
+		connectivity: &con,
+	}
+	now := time.Now()
+
+	ns.RestoreConnectivity(PortVerifying, now)
+	if ns.GetRawConnectivity() != PortUnknown {
+		t.Errorf("Connectivity restored from a check in progress")
+	}
+
+	ns.RestoreConnectivity(GatewayPortFailed, now.Add(time.Minute))
+	if ns.GetRawConnectivity() != GatewayPortFailed {
+		t.Errorf("Connectivity of State is not GatewayPortFailed")
+	}
+	if ns.CheckReprobe(now) {
+		t.Errorf("Connectivity checked again before its reprobe time")
+	}
+	if !ns.CheckReprobe(now.Add(time.Minute)) {
+		t.Errorf("Connectivity not checked again at its reprobe time")
+	}
+	if ns.GetRawConnectivity() != PortUnknown {
+		t.Errorf("Connectivity of State is not PortUnknown")
+	}
+	if ns.CheckReprobe(now.Add(time.Hour)) {
+		t.Errorf("Connectivity checked again a second time")
+	}
+}
+
+// Tests that failed connectivity is checked again once the scheduled time has
+// passed, and that rescheduling keeps the first time.
+func TestState_ScheduleReprobe(t *testing.T) {
+	con := PortFailed
+	ns := State{
+		connectivity: &con,
+	}
+	now := time.Now()
+
+	ns.ScheduleReprobe(now.Add(time.Minute))
+	ns.ScheduleReprobe(now.Add(time.Hour))
+	if ns.CheckReprobe(now) {
+		t.Errorf("Connectivity checked again before its reprobe time")
+	}
+	if !ns.CheckReprobe(now.Add(time.Minute)) {
+		t.Errorf("Connectivity not checked again at the first reprobe time")
+	}
+	if ns.GetRawConnectivity() != PortUnknown {
+		t.Errorf("Connectivity of State is not PortUnknown")
+	}
+}
+
+// Tests that UpdateVersions reports changes and keeps the gateway version
+// when none is reported.
+func TestState_UpdateVersions(t *testing.T) {
+	ns := State{}
+
+	if !ns.UpdateVersions("1.0.0", "") {
+		t.Errorf("First versions not reported as changed")
+	}
+	if !ns.UpdateVersions("1.0.0", "2.0.0") {
+		t.Errorf("New gateway version not reported as changed")
+	}
+	if ns.UpdateVersions("1.0.0", "") || ns.UpdateVersions("1.0.0", "2.0.0") {
+		t.Errorf("Unchanged versions reported as changed")
+	}
+	if server, gateway := ns.GetVersions(); server != "1.0.0" || gateway != "2.0.0" {
+		t.Errorf("Unexpected versions: %s and %s", server, gateway)
+	}
+}
+
 // Check that an error is returned for a valid state change while an invalid one
 // does error using the Update command
 func TestState_UpdateStateChangeError(t *testing.T) {
@@ -93,6 +168,74 @@ func TestState_UpdateStateChangeError(t *testing.T) {
 	}
 }
 
+// Tests that each advanced interval returns the polls made since the last one
+// and that the history keeps the last PollHistoryLength of them, oldest first.
+func TestState_AdvancePollInterval(t *testing.T) {
+	numPolls := uint64(0)
+	s := State{
+		numPolls: &numPolls,
+	}
+
+	if history := s.GetPollHistory(); len(history) != 0 {
+		t.Errorf("New state has a poll history: %v", history)
+	}
+
+	for i := 1; i <= PollHistoryLength+3; i++ {
+		for j := 0; j < i; j++ {
+			s.IncrementNumPolls()
+		}
+		if count := s.AdvancePollInterval(); count != uint64(i) {
+			t.Errorf("Interval %d counted %d polls.", i, count)
+		}
+		if s.GetNumPolls() != 0 {
+			t.Errorf("Polls of interval %d counted in the next.", i)
+		}
+	}
+
+	history := s.GetPollHistory()
+	if len(history) != PollHistoryLength {
+		t.Fatalf("Poll history has %d intervals instead of %d.",
+			len(history), PollHistoryLength)
+	}
+	for i, count := range history {
+		if expected := uint64(i + 4); count != expected {
+			t.Errorf("Interval %d of the history has %d polls instead of %d.",
+				i, count, expected)
+		}
+	}
+}
+
+// Tests that polls made while intervals are advanced are each counted in
+// exactly one interval.
+func TestState_AdvancePollInterval_Concurrent(t *testing.T) {
+	numPolls := uint64(0)
+	s := State{
+		numPolls: &numPolls,
+	}
+
+	const polls = 10000
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < polls; i++ {
+			s.IncrementNumPolls()
+		}
+		close(done)
+	}()
+
+	total := uint64(0)
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		default:
+		}
+		total += s.AdvancePollInterval()
+	}
+	if total != polls {
+		t.Errorf("Intervals counted %d polls instead of %d.", total, polls)
+	}
+}
+
 // tests that State update functions properly when the state it is updated
 // to is not the one it is at
 func TestNodeState_Update_Same(t *testing.T) {
@@ -490,6 +633,44 @@ func TestNodeState_SetRound_Valid(t *testing.T) {
 	}
 }
 
+// Tests that each Node in a round reports its index in the round's topology,
+// and that Nodes outside of a round or its topology report none.
+func TestState_GetTopologyPosition(t *testing.T) {
+	nsm := NewStateMap()
+	ids := make([]*id.ID, 4)
+	for i := range ids {
+		ids[i] = id.NewIdFromUInt(uint64(i), id.Node, t)
+		if err := nsm.AddNode(ids[i], "", "", "", 0); err != nil {
+			t.Fatalf("Failed to add node: %+v", err)
+		}
+	}
+
+	// The topology orders the first three Nodes in reverse
+	topology := connect.NewCircuit([]*id.ID{ids[2], ids[1], ids[0]})
+	r := round.NewState_Testing(42, states.PRECOMPUTING, topology, t)
+	for _, nid := range ids {
+		if err := nsm.GetNode(nid).SetRound(r); err != nil {
+			t.Fatalf("Failed to set round: %+v", err)
+		}
+	}
+
+	for i, expected := range []int{2, 1, 0} {
+		position, ok := nsm.GetNode(ids[i]).GetTopologyPosition()
+		if !ok || position != expected {
+			t.Errorf("Node %d reported position %d, %t instead of %d.",
+				i, position, ok, expected)
+		}
+	}
+	if _, ok := nsm.GetNode(ids[3]).GetTopologyPosition(); ok {
+		t.Errorf("Node outside of the topology reported a position.")
+	}
+
+	nsm.GetNode(ids[0]).ClearRound()
+	if _, ok := nsm.GetNode(ids[0]).GetTopologyPosition(); ok {
+		t.Errorf("Node outside of a round reported a position.")
+	}
+}
+
 //tests that clear round does not set the tracked roundID errors when one is set
 func TestNodeState_SetRound_Invalid(t *testing.T) {
 	r := round.NewState_Testing(42, 0, nil, t)
@@ -567,6 +748,27 @@ func TestState_Ban(t *testing.T) {
 
 }
 
+// Tests that Unban() leaves a banned node inactive and refuses to unban a node
+// which is not banned.
+func TestState_Unban(t *testing.T) {
+	ns := State{id: id.NewIdFromUInt(50, id.Node, t)}
+	if err := ns.Unban(); err == nil {
+		t.Errorf("Should not be able to unban a node which is not banned")
+	}
+
+	if _, err := ns.Ban(); err != nil {
+		t.Fatalf("Failed to ban node: %+v", err)
+	}
+	if err := ns.Unban(); err != nil {
+		t.Errorf("Failed to unban node: %+v", err)
+	}
+	if ns.status != Inactive {
+		t.Errorf("Node status not updated after unbanning."+
+			"\n\tExpected: %v"+
+			"\n\tReceived: %v", Inactive, ns.status)
+	}
+}
+
 func TestState_IsBanned(t *testing.T) {
 	testID := id.NewIdFromUInt(50, id.Node, t)
 	ns := State{
@@ -611,6 +813,10 @@ func TestState_UpdateInactive(t *testing.T) {
 		ToStatus:     Active,
 		FromActivity: oldActivity,
 		ToActivity:   current.WAITING,
+		Created:      receivedNun.Created,
+	}
+	if receivedNun.Created.IsZero() {
+		t.Errorf("Update notification was not timestamped.")
 	}
 
 	// Check that the node's status has been updated
@@ -707,3 +913,301 @@ func TestState_UpdateInactive_InvalidActivity(t *testing.T) {
 	}
 
 }
+
+// Tests that ResumeRound sets the round and activity so that the node can
+// continue the round, and that it will not replace an existing round.
+func TestState_ResumeRound(t *testing.T) {
+	ns := &State{}
+	r := round.NewState_Testing(42, states.QUEUED, nil, t)
+
+	if err := ns.ResumeRound(r, current.STANDBY); err != nil {
+		t.Fatalf("ResumeRound() returned an error: %+v", err)
+	}
+
+	if hasRound, cur := ns.GetCurrentRound(); !hasRound || cur != r {
+		t.Errorf("ResumeRound() did not set the round.")
+	}
+	if ns.GetActivity() != current.STANDBY {
+		t.Errorf("ResumeRound() did not set the activity: %s", ns.GetActivity())
+	}
+
+	other := round.NewState_Testing(43, states.QUEUED, nil, t)
+	if err := ns.ResumeRound(other, current.STANDBY); err == nil {
+		t.Errorf("ResumeRound() should error when already in a round.")
+	}
+}
+
+// Tests that SetObservedAddress only flags a mismatch when the advertised
+// address is an IP which differs from the observed one, and keeps the
+// advertised port.
+func TestState_SetObservedAddress(t *testing.T) {
+	testValues := []struct {
+		advertised, observedIp string
+		expectedAddr           string
+		mismatch               bool
+	}{
+		{"1.2.3.4:11420", "1.2.3.4", "1.2.3.4:11420", false},
+		{"1.2.3.4:11420", "5.6.7.8", "5.6.7.8:11420", true},
+		{"[::1]:11420", "::1", "[::1]:11420", false},
+		{"node.example.com:11420", "5.6.7.8", "5.6.7.8:11420", false},
+		{"1.2.3.4", "5.6.7.8", "5.6.7.8", true},
+	}
+
+	for i, val := range testValues {
+		ns := &State{nodeAddress: val.advertised}
+		mismatch := ns.SetObservedAddress(val.observedIp)
+		observed, flagged := ns.GetObservedAddress()
+
+		if mismatch != val.mismatch || flagged != val.mismatch {
+			t.Errorf("Unexpected mismatch flag (%d).\nexpected: %t"+
+				"\nreceived: %t, %t", i, val.mismatch, mismatch, flagged)
+		}
+		if observed != val.expectedAddr {
+			t.Errorf("Unexpected observed address (%d).\nexpected: %s"+
+				"\nreceived: %s", i, val.expectedAddr, observed)
+		}
+	}
+}
+
+// Tests that RecordPollSource counts distinct sources within the window,
+// drops sources not seen within it and keeps at most MaxPollSources.
+func TestState_RecordPollSource(t *testing.T) {
+	ns := &State{}
+	now := time.Now()
+	window := 10 * time.Minute
+
+	for i, address := range []string{"1.1.1.1", "2.2.2.2", "1.1.1.1"} {
+		count := ns.RecordPollSource(address, now.Add(time.Duration(i)*time.Second), window)
+		if expected := []int{1, 2, 2}[i]; count != expected {
+			t.Errorf("Unexpected source count (%d).\nexpected: %d\nreceived: %d",
+				i, expected, count)
+		}
+	}
+	sources := ns.GetPollSources()
+	if len(sources) != 2 || sources[0].Address != "2.2.2.2" ||
+		sources[1].Address != "1.1.1.1" {
+		t.Errorf("Unexpected sources: %+v", sources)
+	}
+
+	// Both sources fall out of the window
+	if count := ns.RecordPollSource("3.3.3.3", now.Add(window+time.Minute), window); count != 1 {
+		t.Errorf("Sources outside the window were counted: %d", count)
+	}
+
+	for i := 0; i < 2*MaxPollSources; i++ {
+		ns.RecordPollSource(fmt.Sprintf("10.0.0.%d", i), now.Add(window+time.Minute), window)
+	}
+	sources = ns.GetPollSources()
+	if len(sources) != MaxPollSources {
+		t.Errorf("Unexpected number of sources kept.\nexpected: %d\nreceived: %d",
+			MaxPollSources, len(sources))
+	}
+	if last := fmt.Sprintf("10.0.0.%d", 2*MaxPollSources-1); sources[len(sources)-1].Address != last {
+		t.Errorf("Most recent source was not kept.\nexpected: %s\nreceived: %s",
+			last, sources[len(sources)-1].Address)
+	}
+}
+
+// Tests that SetPollSourceAnomaly only reports changes.
+func TestState_SetPollSourceAnomaly(t *testing.T) {
+	ns := &State{}
+	for i, val := range []struct{ anomalous, changed bool }{
+		{false, false}, {true, true}, {true, false}, {false, true}} {
+		if changed := ns.SetPollSourceAnomaly(val.anomalous); changed != val.changed {
+			t.Errorf("Unexpected change (%d).\nexpected: %t\nreceived: %t",
+				i, val.changed, changed)
+		}
+	}
+}
+
+// Tests that ClearPollSources forgets the sources and the anomaly.
+func TestState_ClearPollSources(t *testing.T) {
+	ns := &State{}
+	ns.RecordPollSource("1.1.1.1", time.Now(), time.Minute)
+	ns.SetPollSourceAnomaly(true)
+
+	ns.ClearPollSources()
+	if sources := ns.GetPollSources(); len(sources) != 0 {
+		t.Errorf("Sources were kept: %+v", sources)
+	}
+	if !ns.SetPollSourceAnomaly(true) {
+		t.Errorf("Anomaly was kept.")
+	}
+}
+
+// Tests that SetCapacity stores the capacity and clamps it to MaxCapacity.
+func TestState_SetCapacity(t *testing.T) {
+	ns := &State{}
+	if ns.GetCapacity() != 0 {
+		t.Errorf("New state has a non-zero capacity: %d", ns.GetCapacity())
+	}
+
+	if stored := ns.SetCapacity(512); stored != 512 || ns.GetCapacity() != 512 {
+		t.Errorf("Capacity not stored.\nexpected: %d\nreceived: %d, %d",
+			512, stored, ns.GetCapacity())
+	}
+
+	if stored := ns.SetCapacity(MaxCapacity + 1); stored != MaxCapacity ||
+		ns.GetCapacity() != MaxCapacity {
+		t.Errorf("Capacity not clamped.\nexpected: %d\nreceived: %d, %d",
+			MaxCapacity, stored, ns.GetCapacity())
+	}
+}
+
+// Tests that ClampLastActive only clamps last active times more than the
+// tolerance in the future.
+func TestState_ClampLastActive(t *testing.T) {
+	ns := &State{}
+	now := time.Now()
+	tolerance := time.Minute
+
+	for _, lastActive := range []time.Time{now.Add(-time.Hour), now,
+		now.Add(tolerance)} {
+		ns.SetLastActiveTesting(lastActive, t)
+		if clamped := ns.ClampLastActive(now, tolerance); !clamped.IsZero() {
+			t.Errorf("Last active time %s was clamped.", lastActive)
+		}
+		if !ns.GetLastActive().Equal(lastActive) {
+			t.Errorf("Last active time changed.\nexpected: %s\nreceived: %s",
+				lastActive, ns.GetLastActive())
+		}
+	}
+
+	future := now.Add(tolerance + time.Second)
+	ns.SetLastActiveTesting(future, t)
+	if clamped := ns.ClampLastActive(now, tolerance); !clamped.Equal(future) {
+		t.Errorf("Unexpected clamped time.\nexpected: %s\nreceived: %s",
+			future, clamped)
+	}
+	if !ns.GetLastActive().Equal(now) {
+		t.Errorf("Last active time not clamped.\nexpected: %s\nreceived: %s",
+			now, ns.GetLastActive())
+	}
+}
+
+// Tests that AllowBannedPoll only answers one poll per interval.
+func TestState_AllowBannedPoll(t *testing.T) {
+	ns := &State{}
+	now := time.Now()
+	interval := time.Minute
+
+	if !ns.AllowBannedPoll(now, interval) {
+		t.Errorf("First banned poll was not allowed.")
+	}
+	if ns.AllowBannedPoll(now.Add(interval/2), interval) {
+		t.Errorf("Banned poll within the interval was allowed.")
+	}
+	if !ns.AllowBannedPoll(now.Add(interval), interval) {
+		t.Errorf("Banned poll after the interval was not allowed.")
+	}
+	if !ns.AllowBannedPoll(now.Add(interval), 0) {
+		t.Errorf("Banned poll was not allowed without an interval.")
+	}
+}
+
+// Tests that SetPublicAddress stores the public address separately from the
+// inter-node address and reports changes.
+func TestState_SetPublicAddress(t *testing.T) {
+	ns := &State{nodeAddress: "10.0.0.1:11420"}
+	if ns.GetPublicAddress() != "" {
+		t.Errorf("New state has public address %q.", ns.GetPublicAddress())
+	}
+
+	if !ns.SetPublicAddress("1.2.3.4:11420") {
+		t.Errorf("Setting a new public address reported no change.")
+	}
+	if ns.SetPublicAddress("1.2.3.4:11420") {
+		t.Errorf("Setting the same public address reported a change.")
+	}
+	if ns.GetPublicAddress() != "1.2.3.4:11420" {
+		t.Errorf("Public address is %q.", ns.GetPublicAddress())
+	}
+	if ns.GetNodeAddresses() != "10.0.0.1:11420" {
+		t.Errorf("Inter-node address changed to %q.", ns.GetNodeAddresses())
+	}
+}
+
+// Tests that SetGatewayClientPort reports changes, and that the gateway client
+// address only differs from the gateway address when a client port is set.
+func TestState_SetGatewayClientPort(t *testing.T) {
+	ns := &State{gatewayAddress: "1.2.3.4:22840"}
+	if addr := ns.GetGatewayClientAddress(); addr != "1.2.3.4:22840" {
+		t.Errorf("Gateway without a client port has client address %q.", addr)
+	}
+
+	if !ns.SetGatewayClientPort(8443) {
+		t.Errorf("Setting a new client port reported no change.")
+	}
+	if ns.SetGatewayClientPort(8443) {
+		t.Errorf("Setting the same client port reported a change.")
+	}
+	if addr := ns.GetGatewayClientAddress(); addr != "1.2.3.4:8443" {
+		t.Errorf("Gateway client address is %q.", addr)
+	}
+	if ns.GetGatewayAddress() != "1.2.3.4:22840" {
+		t.Errorf("Gateway address changed to %q.", ns.GetGatewayAddress())
+	}
+
+	// An address without a port is left as it is
+	ns.gatewayAddress = "gateway.example.com"
+	if addr := ns.GetGatewayClientAddress(); addr != "gateway.example.com" {
+		t.Errorf("Gateway address without a port became %q.", addr)
+	}
+}
+
+// Tests that AdvanceUpdateCursor never lets the cursor go backwards.
+func TestState_AdvanceUpdateCursor(t *testing.T) {
+	ns := &State{}
+
+	if cursor, raised := ns.AdvanceUpdateCursor(5); cursor != 5 || raised {
+		t.Errorf("Unexpected cursor: %d, %t", cursor, raised)
+	}
+	if cursor, raised := ns.AdvanceUpdateCursor(5); cursor != 5 || raised {
+		t.Errorf("Unexpected cursor for a repeated ID: %d, %t", cursor, raised)
+	}
+	if cursor, raised := ns.AdvanceUpdateCursor(2); cursor != 5 || !raised {
+		t.Errorf("Decreasing ID was not raised: %d, %t", cursor, raised)
+	}
+	if cursor, raised := ns.AdvanceUpdateCursor(9); cursor != 9 || raised {
+		t.Errorf("Unexpected cursor: %d, %t", cursor, raised)
+	}
+}
+
+// Tests that a Node on probation graduates once it has completed enough rounds
+// over the probation duration, and that a failure restarts its probation.
+func TestState_CompleteProbationRound(t *testing.T) {
+	ns := &State{}
+	now := time.Now()
+	if ns.CompleteProbationRound(now, 0, 0) {
+		t.Errorf("Node not on probation graduated.")
+	}
+
+	ns.SetProbation(true, 0, time.Time{})
+	if ns.CompleteProbationRound(now, 2, time.Hour) {
+		t.Errorf("Node graduated after its first round.")
+	}
+	if ns.CompleteProbationRound(now.Add(time.Minute), 2, time.Hour) {
+		t.Errorf("Node graduated before the probation duration passed.")
+	}
+
+	// A failure restarts the rounds and the duration
+	if !ns.ExtendProbation() {
+		t.Errorf("Probation of a node on probation was not extended.")
+	}
+	if onProbation, rounds, since := ns.GetProbation(); !onProbation ||
+		rounds != 0 || !since.IsZero() {
+		t.Errorf("Probation not restarted: %t, %d, %s", onProbation, rounds,
+			since)
+	}
+	later := now.Add(2 * time.Hour)
+	ns.CompleteProbationRound(later, 2, time.Hour)
+	if ns.CompleteProbationRound(later.Add(time.Minute), 2, time.Hour) {
+		t.Errorf("Node graduated before the restarted duration passed.")
+	}
+	if !ns.CompleteProbationRound(later.Add(time.Hour), 2, time.Hour) {
+		t.Errorf("Node did not graduate.")
+	}
+	if ns.IsOnProbation() || ns.ExtendProbation() {
+		t.Errorf("Node is still on probation after graduating.")
+	}
+}
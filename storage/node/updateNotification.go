@@ -13,6 +13,7 @@ import (
 	"gitlab.com/elixxir/comms/mixmessages"
 	"gitlab.com/elixxir/primitives/current"
 	"gitlab.com/xx_network/primitives/id"
+	"time"
 )
 
 // UpdateNotification structure used to notify the control thread that the
@@ -24,5 +25,13 @@ type UpdateNotification struct {
 	FromActivity current.Activity
 	ToActivity   current.Activity
 	Error        *mixmessages.RoundError
+	// Unsanitized text of Error, kept for debugging
+	RawError     string
 	ClientErrors []*mixmessages.ClientError
+	// When the update was produced, for measuring how long it waited to be
+	// handled
+	Created time.Time
+	// Hold of the node's polling lock handed off with the update, released
+	// once it is handled
+	PollingHold uint64
 }
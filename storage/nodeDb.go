@@ -10,6 +10,7 @@
 package storage
 
 import (
+	"github.com/jinzhu/gorm"
 	"github.com/pkg/errors"
 	jww "github.com/spf13/jwalterweatherman"
 	"gitlab.com/elixxir/registration/storage/node"
@@ -37,6 +38,18 @@ func (d *DatabaseImpl) UpdateNodeAddresses(id *id.ID, nodeAddr, gwAddr string) e
 	}).Error
 }
 
+// Update the public address field for the Node with the given id
+func (d *DatabaseImpl) UpdateNodePublicAddress(id *id.ID, publicAddr string) error {
+	return d.db.Model(Node{}).Where("id = ?", id.Marshal()).
+		Update("public_address", publicAddr).Error
+}
+
+// Update the gateway client port field for the Node with the given id
+func (d *DatabaseImpl) UpdateNodeGatewayClientPort(id *id.ID, port uint32) error {
+	return d.db.Model(Node{}).Where("id = ?", id.Marshal()).
+		Update("gateway_client_port", port).Error
+}
+
 // Update the sequence field for the Node with the given id
 func (d *DatabaseImpl) UpdateNodeSequence(id *id.ID, sequence string) error {
 	newNode := Node{
@@ -45,6 +58,84 @@ func (d *DatabaseImpl) UpdateNodeSequence(id *id.ID, sequence string) error {
 	return d.db.Take(&newNode, "id = ?", id.Marshal()).Update("sequence", sequence).Error
 }
 
+// Update the connectivity field for the Node with the given id
+func (d *DatabaseImpl) UpdateNodeConnectivity(id *id.ID, connectivity uint32) error {
+	return d.db.Model(Node{}).Where("id = ?", id.Marshal()).
+		Update("connectivity", connectivity).Error
+}
+
+// Update the status field for the Node with the given id
+func (d *DatabaseImpl) UpdateNodeStatus(id *id.ID, status node.Status) error {
+	return d.db.Model(Node{}).Where("id = ?", id.Marshal()).
+		Update("status", uint8(status)).Error
+}
+
+// Update the probation fields for the Node with the given id
+func (d *DatabaseImpl) UpdateNodeProbation(id *id.ID, onProbation bool,
+	rounds uint32, since time.Time) error {
+	return d.db.Model(Node{}).Where("id = ?", id.Marshal()).
+		Updates(map[string]interface{}{
+			"on_probation":     onProbation,
+			"probation_rounds": rounds,
+			"probation_since":  since,
+		}).Error
+}
+
+// Update the versions last reported by the Node with the given id, recording
+// them in its version history if they changed
+func (d *DatabaseImpl) UpdateNodeVersions(id *id.ID, serverVersion,
+	gatewayVersion string, timestamp time.Time) error {
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		current := &Node{}
+		err := tx.Select("server_version, gateway_version").
+			Take(current, "id = ?", id.Marshal()).Error
+		if err != nil {
+			return err
+		}
+		if current.ServerVersion == serverVersion &&
+			current.GatewayVersion == gatewayVersion {
+			return nil
+		}
+
+		err = tx.Model(Node{}).Where("id = ?", id.Marshal()).
+			Updates(map[string]interface{}{
+				"server_version":  serverVersion,
+				"gateway_version": gatewayVersion,
+			}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Create(&NodeVersion{
+			NodeId:         id.Marshal(),
+			ServerVersion:  serverVersion,
+			GatewayVersion: gatewayVersion,
+			Timestamp:      timestamp,
+		}).Error
+	})
+}
+
+// Return the versions reported by the Node with the given id, oldest first
+func (d *DatabaseImpl) GetNodeVersionHistory(id *id.ID) ([]*NodeVersion, error) {
+	var versions []*NodeVersion
+	err := d.db.Where("node_id = ?", id.Marshal()).
+		Order("timestamp, id").Find(&versions).Error
+	return versions, err
+}
+
+// Insert a health error reported by a Node
+func (d *DatabaseImpl) InsertNodeHealthEvent(event *NodeHealthEvent) error {
+	return d.db.Create(event).Error
+}
+
+// Return the health errors reported by the Node with the given id since the
+// given time, oldest first
+func (d *DatabaseImpl) GetNodeHealthEvents(id *id.ID, since time.Time) ([]*NodeHealthEvent, error) {
+	var events []*NodeHealthEvent
+	err := d.db.Where("node_id = ? AND timestamp >= ?", id.Marshal(), since).
+		Order("timestamp, id").Find(&events).Error
+	return events, err
+}
+
 // Update the given applicationId with the given GeoIP information
 func (d *DatabaseImpl) UpdateGeoIP(appId uint64, location, geoBin, gpsLocation string) error {
 	app := &Application{
@@ -72,9 +163,10 @@ func (d *DatabaseImpl) updateLastActive(ids [][]byte, lastActive time.Time) erro
 		Update("last_active", lastActive).Error
 }
 
-// If Node registration code is valid, add Node information
+// If Node registration code is valid, add Node information, recording the
+// given registration source
 func (d *DatabaseImpl) RegisterNode(id *id.ID, salt []byte, code, serverAddr, serverCert,
-	gatewayAddress, gatewayCert string) error {
+	gatewayAddress, gatewayCert, source string) error {
 	newNode := Node{
 		Code:               code,
 		Id:                 id.Marshal(),
@@ -85,10 +177,169 @@ func (d *DatabaseImpl) RegisterNode(id *id.ID, salt []byte, code, serverAddr, se
 		GatewayCertificate: gatewayCert,
 		Status:             uint8(node.Active),
 		DateRegistered:     time.Now(),
+		RegistrationSource: source,
 	}
 	return d.db.Model(&newNode).Update(&newNode).Error
 }
 
+// Move the Node registered with the code from oldId to newId after it
+// registered again with a new key, replacing its salt, addresses and
+// certificates. Its metrics, versions, health events, round history and group
+// memberships are moved to the new ID and its last known connectivity is
+// cleared
+func (d *DatabaseImpl) ReRegisterNode(oldId, newId *id.ID, salt []byte, code, serverAddr,
+	serverCert, gatewayAddress, gatewayCert string) error {
+	oldBytes, newBytes := oldId.Marshal(), newId.Marshal()
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		// Rows referencing the Node must be removed before its ID can change
+		// and are inserted again under the new ID afterwards
+		var metrics []NodeMetric
+		var versions []NodeVersion
+		var healthEvents []NodeHealthEvent
+		var topologies []Topology
+		for _, rows := range []interface{}{&metrics, &versions, &healthEvents, &topologies} {
+			err := tx.Where("node_id = ?", oldBytes).Find(rows).Error
+			if err != nil {
+				return err
+			}
+		}
+		for _, table := range []interface{}{&NodeMetric{}, &NodeVersion{},
+			&NodeHealthEvent{}, &Topology{}} {
+			err := tx.Where("node_id = ?", oldBytes).Delete(table).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		result := tx.Model(&Node{}).Where("code = ? AND id = ?", code, oldBytes).
+			Updates(map[string]interface{}{
+				"id":                  newBytes,
+				"salt":                salt,
+				"server_address":      serverAddr,
+				"gateway_address":     gatewayAddress,
+				"node_certificate":    serverCert,
+				"gateway_certificate": gatewayCert,
+				"registration_source": NewKeyRegistration,
+				"connectivity":        uint32(node.PortUnknown),
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected != 1 {
+			return errors.Errorf("No node %s is registered with code %s",
+				oldId, code)
+		}
+
+		for i := range metrics {
+			metrics[i].NodeId = newBytes
+			if err := tx.Create(&metrics[i]).Error; err != nil {
+				return err
+			}
+		}
+		for i := range versions {
+			versions[i].NodeId = newBytes
+			if err := tx.Create(&versions[i]).Error; err != nil {
+				return err
+			}
+		}
+		for i := range healthEvents {
+			healthEvents[i].NodeId = newBytes
+			if err := tx.Create(&healthEvents[i]).Error; err != nil {
+				return err
+			}
+		}
+		for i := range topologies {
+			topologies[i].NodeId = newBytes
+			if err := tx.Create(&topologies[i]).Error; err != nil {
+				return err
+			}
+		}
+
+		err := tx.Model(&NodeGroupMember{}).Where("node_id = ?", oldBytes).
+			Update("node_id", newBytes).Error
+		if err != nil {
+			return err
+		}
+		for _, column := range []string{"precomp_straggler", "realtime_straggler"} {
+			err = tx.Model(&RoundMetric{}).Where(column+" = ?", oldBytes).
+				Update(column, newBytes).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Delete the Node registered with the given code, the rows referencing it and
+// its Application if no other Node belongs to it. If purgeMetrics is set the
+// Node's NodeMetrics are deleted and it is cleared as the straggler of its
+// rounds, otherwise its NodeMetrics are kept without a Node. Its Topology rows
+// are always deleted, as the Node is part of their primary key
+func (d *DatabaseImpl) DeleteNodeRegistration(code string, purgeMetrics bool) error {
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		n := &Node{}
+		err := tx.Select("id, application_id").Take(n, "code = ?", code).Error
+		if gorm.IsRecordNotFoundError(err) {
+			return errors.Errorf("No node is registered with code %s", code)
+		} else if err != nil {
+			return err
+		}
+
+		// Nodes which never registered have no rows referencing them
+		if n.Id != nil {
+			tables := []interface{}{&NodeVersion{}, &NodeHealthEvent{},
+				&NodeGroupMember{}, &Topology{}}
+			if purgeMetrics {
+				tables = append(tables, &NodeMetric{})
+			} else {
+				err = tx.Model(&NodeMetric{}).Where("node_id = ?", n.Id).
+					Update("node_id", nil).Error
+				if err != nil {
+					return err
+				}
+			}
+			for _, table := range tables {
+				err = tx.Where("node_id = ?", n.Id).Delete(table).Error
+				if err != nil {
+					return err
+				}
+			}
+			if purgeMetrics {
+				for _, column := range []string{"precomp_straggler", "realtime_straggler"} {
+					err = tx.Model(&RoundMetric{}).Where(column+" = ?", n.Id).
+						Update(column, nil).Error
+					if err != nil {
+						return err
+					}
+				}
+			}
+			err = tx.Where("id = ?", n.Id).Delete(&ActiveNode{}).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		err = tx.Where("code = ?", code).Delete(&Node{}).Error
+		if err != nil {
+			return err
+		}
+
+		var others int
+		err = tx.Model(&Node{}).Where("application_id = ?", n.ApplicationId).
+			Count(&others).Error
+		if err != nil || others > 0 {
+			return err
+		}
+		err = tx.Where("application_id = ? OR avoided_application_id = ?",
+			n.ApplicationId, n.ApplicationId).Delete(&AvoidedApplication{}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Where("id = ?", n.ApplicationId).Delete(&Application{}).Error
+	})
+}
+
 // Get Node information for the given Node registration code
 func (d *DatabaseImpl) GetNode(code string) (*Node, error) {
 	newNode := &Node{}
@@ -96,6 +347,40 @@ func (d *DatabaseImpl) GetNode(code string) (*Node, error) {
 	return newNode, err
 }
 
+// Return all Applications in Storage assigned to the given team, along with
+// their Nodes. An empty team matches no Applications
+func (d *DatabaseImpl) GetApplicationsByTeam(team string) ([]*Application, error) {
+	return d.getApplicationsWhere("team", team)
+}
+
+// Return the Application with the given ID
+func (d *DatabaseImpl) GetApplication(appId uint64) (*Application, error) {
+	app := &Application{}
+	err := d.db.First(app, "id = ?", appId).Error
+	if err != nil {
+		return nil, errors.WithMessagef(err, "Failed to find application with id %d", appId)
+	}
+	return app, nil
+}
+
+// Return all Applications in Storage in the given network, along with their
+// Nodes. An empty network matches no Applications
+func (d *DatabaseImpl) GetApplicationsByNetwork(network string) ([]*Application, error) {
+	return d.getApplicationsWhere("network", network)
+}
+
+// Helper for returning the Applications, ordered by ID, whose given column
+// holds the given non-empty value
+func (d *DatabaseImpl) getApplicationsWhere(column, value string) ([]*Application, error) {
+	applications := make([]*Application, 0)
+	if value == "" {
+		return applications, nil
+	}
+	err := d.db.Preload("Node").Where(column+" = ?", value).
+		Order("id ASC").Find(&applications).Error
+	return applications, err
+}
+
 // Return all nodes in Storage
 func (d *DatabaseImpl) GetNodes() ([]*Node, error) {
 	var nodes []*Node
@@ -119,6 +404,73 @@ func (d *DatabaseImpl) GetNodesByStatus(status node.Status) ([]*Node, error) {
 	return nodes, err
 }
 
+// NodeFilter selects the nodes listed by GetNodesPage. Fields left empty or
+// zero match every node.
+type NodeFilter struct {
+	// Statuses the node must have one of
+	Statuses []node.Status
+	// Order strings the node must have one of, such as the countries of a
+	// geographic bin
+	Sequences []string
+	// Application the node must belong to
+	ApplicationId uint64
+	// Time the node must have registered after
+	RegisteredAfter time.Time
+}
+
+// where restricts the query to the nodes matching the filter
+func (f NodeFilter) where(db *gorm.DB) *gorm.DB {
+	if len(f.Statuses) > 0 {
+		statuses := make([]int, len(f.Statuses))
+		for i, status := range f.Statuses {
+			statuses[i] = int(status)
+		}
+		db = db.Where("status IN (?)", statuses)
+	}
+	if len(f.Sequences) > 0 {
+		db = db.Where("sequence IN (?)", f.Sequences)
+	}
+	if f.ApplicationId != 0 {
+		db = db.Where("application_id = ?", f.ApplicationId)
+	}
+	if !f.RegisteredAfter.IsZero() {
+		db = db.Where("date_registered > ?", f.RegisteredAfter)
+	}
+	return db
+}
+
+// GetNodesPage returns up to limit nodes matching the filter whose
+// registration code comes after afterCode, ordered by registration code, along
+// with the total number of nodes matching the filter. An empty afterCode
+// starts from the first node, and the code of the last node returned continues
+// from where the page ended.
+func (d *DatabaseImpl) GetNodesPage(filter NodeFilter, afterCode string,
+	limit int) ([]*Node, int, error) {
+	if limit <= 0 {
+		return nil, 0, errors.Errorf("Invalid page size %d", limit)
+	}
+
+	var total int
+	err := filter.where(d.db.Model(&Node{})).Count(&total).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var nodes []*Node
+	err = filter.where(d.db).Where("code > ?", afterCode).
+		Order("code ASC").Limit(limit).Find(&nodes).Error
+	return nodes, total, err
+}
+
+// Return all nodes in Storage registered at or after start and before end,
+// ordered by the date they registered
+func (d *DatabaseImpl) GetRegistrationsByTimeRange(start, end time.Time) ([]*Node, error) {
+	var nodes []*Node
+	err := d.db.Where("date_registered >= ? AND date_registered < ?", start, end).
+		Order("date_registered ASC").Find(&nodes).Error
+	return nodes, err
+}
+
 // Return all ActiveNodes in Storage
 func (d *DatabaseImpl) GetActiveNodes() ([]*ActiveNode, error) {
 	var activeNodes []*ActiveNode
@@ -126,6 +478,91 @@ func (d *DatabaseImpl) GetActiveNodes() ([]*ActiveNode, error) {
 	return activeNodes, err
 }
 
+// Replace the members of the node group with the given name
+func (d *DatabaseImpl) UpsertNodeGroup(name string, members []*id.ID) error {
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("group_name = ?", name).Delete(&NodeGroupMember{}).Error
+		if err != nil {
+			return err
+		}
+		for i, nid := range members {
+			member := &NodeGroupMember{
+				GroupName: name,
+				NodeId:    nid.Marshal(),
+				Order:     uint32(i),
+			}
+			if err = tx.Create(member).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Remove all members of the node group with the given name
+func (d *DatabaseImpl) DeleteNodeGroup(name string) error {
+	return d.db.Where("group_name = ?", name).Delete(&NodeGroupMember{}).Error
+}
+
+// Return all node groups in Storage, mapping the group name to its members
+// in order
+func (d *DatabaseImpl) GetNodeGroups() (map[string][]*id.ID, error) {
+	var members []*NodeGroupMember
+	err := d.db.Order("group_name, \"order\"").Find(&members).Error
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make(map[string][]*id.ID)
+	for _, member := range members {
+		nid, err := id.Unmarshal(member.NodeId)
+		if err != nil {
+			return nil, errors.Errorf("Failed to unmarshal member of "+
+				"node group %s: %+v", member.GroupName, err)
+		}
+		groups[member.GroupName] = append(groups[member.GroupName], nid)
+	}
+	return groups, nil
+}
+
+// Replace the avoid-list of the Application with the given ID
+func (d *DatabaseImpl) UpsertAvoidList(applicationId uint64, avoided []uint64) error {
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("application_id = ?", applicationId).
+			Delete(&AvoidedApplication{}).Error
+		if err != nil {
+			return err
+		}
+		for _, avoidedId := range avoided {
+			entry := &AvoidedApplication{
+				ApplicationId:        applicationId,
+				AvoidedApplicationId: avoidedId,
+			}
+			if err = tx.Create(entry).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Return all avoid-lists in Storage, mapping the Application ID to the IDs of
+// the Applications it avoids
+func (d *DatabaseImpl) GetAvoidLists() (map[uint64][]uint64, error) {
+	var entries []*AvoidedApplication
+	err := d.db.Order("application_id, avoided_application_id").Find(&entries).Error
+	if err != nil {
+		return nil, err
+	}
+
+	avoidLists := make(map[uint64][]uint64)
+	for _, entry := range entries {
+		avoidLists[entry.ApplicationId] = append(
+			avoidLists[entry.ApplicationId], entry.AvoidedApplicationId)
+	}
+	return avoidLists, nil
+}
+
 // If Node registration code is valid, add Node information
 // This was originally part of the map impl, and is only used in testing
 func (d *DatabaseImpl) BannedNode(id *id.ID, t interface{}) error {
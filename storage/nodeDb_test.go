@@ -8,12 +8,16 @@
 package storage
 
 import (
+	"bytes"
 	"errors"
 	"github.com/jinzhu/gorm"
 	"gitlab.com/elixxir/registration/storage/node"
 	"gitlab.com/xx_network/primitives/id"
 	"gitlab.com/xx_network/primitives/region"
+	"reflect"
+	"strconv"
 	"testing"
+	"time"
 )
 
 // Happy path
@@ -159,7 +163,7 @@ func TestDatabaseImpl_RegisterNode(t *testing.T) {
 
 	// Attempt to insert a node
 	err = d.RegisterNode(id.NewIdFromString("", id.Node, t), []byte("test"), code, addr,
-		cert, gwAddr, gwCert)
+		cert, gwAddr, gwCert, SelfServeRegistration)
 	if err != nil {
 		t.Fatalf("Failed call to RegisterNode: %+v", err)
 	}
@@ -167,7 +171,7 @@ func TestDatabaseImpl_RegisterNode(t *testing.T) {
 	// Verify the insert was successful
 	if info, err := d.GetNode(code); err != nil || info.NodeCertificate != cert ||
 		info.GatewayCertificate != gwCert || info.ServerAddress != addr ||
-		info.GatewayAddress != gwAddr {
+		info.GatewayAddress != gwAddr || info.RegistrationSource != SelfServeRegistration {
 		t.Errorf("Expected to successfully insert node information: %+v", info)
 	}
 }
@@ -190,7 +194,7 @@ func TestDatabaseImpl_RegisterNode_Invalid(t *testing.T) {
 
 	// Attempt to insert a node without an associated registration code
 	err = d.RegisterNode(id.NewIdFromString("", id.Node, t), []byte("test"), code, code,
-		code, code, code)
+		code, code, code, SelfServeRegistration)
 	// Verify the insert failed
 	// TODO this does not error in sqlite; update not finding rows is not an error in either sql implementation, but psql WILL error with foreign key issues
 	if err != nil {
@@ -399,6 +403,108 @@ func TestDatabaseImpl_UpdateNodeAddresses(t *testing.T) {
 	}
 }
 
+// Tests that the public address is stored without changing the inter-node
+// address.
+func TestDatabaseImpl_UpdateNodePublicAddress(t *testing.T) {
+	d, dc, err := NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = dc() }()
+	testString := "test"
+	testId := id.NewIdFromString(testString, id.Node, t)
+	applicationId := uint64(10)
+	err = d.InsertApplication(&Application{Id: applicationId}, &Node{
+		Code:          testString,
+		Id:            testId.Marshal(),
+		ServerAddress: "10.0.0.1:11420",
+		ApplicationId: applicationId,
+	})
+	if err != nil {
+		t.Fatalf("Failed to insert node: %+v", err)
+	}
+
+	if err = d.UpdateNodePublicAddress(testId, "1.2.3.4:11420"); err != nil {
+		t.Fatalf("Failed to update public address: %+v", err)
+	}
+	result, err := d.GetNode(testString)
+	if err != nil {
+		t.Fatalf("Failed to get node: %+v", err)
+	}
+	if result.PublicAddress != "1.2.3.4:11420" ||
+		result.ServerAddress != "10.0.0.1:11420" {
+		t.Errorf("Unexpected addresses: public %q, server %q",
+			result.PublicAddress, result.ServerAddress)
+	}
+}
+
+// Tests that the client port of a node's gateway is stored without changing
+// its gateway address.
+func TestDatabaseImpl_UpdateNodeGatewayClientPort(t *testing.T) {
+	d, dc, err := NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = dc() }()
+	testString := "test"
+	testId := id.NewIdFromString(testString, id.Node, t)
+	applicationId := uint64(10)
+	err = d.InsertApplication(&Application{Id: applicationId}, &Node{
+		Code:           testString,
+		Id:             testId.Marshal(),
+		GatewayAddress: "1.2.3.4:22840",
+		ApplicationId:  applicationId,
+	})
+	if err != nil {
+		t.Fatalf("Failed to insert node: %+v", err)
+	}
+
+	if err = d.UpdateNodeGatewayClientPort(testId, 8443); err != nil {
+		t.Fatalf("Failed to update gateway client port: %+v", err)
+	}
+	result, err := d.GetNode(testString)
+	if err != nil {
+		t.Fatalf("Failed to get node: %+v", err)
+	}
+	if result.GatewayClientPort != 8443 ||
+		result.GatewayAddress != "1.2.3.4:22840" {
+		t.Errorf("Unexpected gateway: client port %d, address %q",
+			result.GatewayClientPort, result.GatewayAddress)
+	}
+}
+
+// Tests that the probation of a node is stored.
+func TestDatabaseImpl_UpdateNodeProbation(t *testing.T) {
+	d, dc, err := NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = dc() }()
+	testId := id.NewIdFromString("test", id.Node, t)
+	err = d.InsertApplication(&Application{Id: 10}, &Node{
+		Code:          "test",
+		Id:            testId.Marshal(),
+		ApplicationId: 10,
+	})
+	if err != nil {
+		t.Fatalf("Failed to insert node: %+v", err)
+	}
+
+	since := time.Unix(1000, 0)
+	if err = d.UpdateNodeProbation(testId, true, 3, since); err != nil {
+		t.Fatalf("Failed to update probation: %+v", err)
+	}
+	result, err := d.GetNode("test")
+	if err != nil {
+		t.Fatalf("Failed to get node: %+v", err)
+	}
+	if !result.OnProbation || result.ProbationRounds != 3 ||
+		!result.ProbationSince.Equal(since) {
+		t.Errorf("Unexpected probation: %t, %d, %s", result.OnProbation,
+			result.ProbationRounds, result.ProbationSince)
+	}
+}
+
 // Happy path
 func TestDatabaseImpl_UpdateSequence(t *testing.T) {
 	d, dc, err := NewDatabase("", "", "TestDatabaseImpl_UpdateSequence", "", "")
@@ -442,3 +548,913 @@ func TestDatabaseImpl_UpdateSequence(t *testing.T) {
 			result.Sequence, testResult)
 	}
 }
+
+// Happy path
+func TestDatabaseImpl_UpdateNodeConnectivity(t *testing.T) {
+	d, dc, err := NewDatabase("", "", "TestDatabaseImpl_UpdateNodeConnectivity", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := dc()
+		if err != nil {
+			t.Errorf("Failed to close database: %+v", err)
+		}
+	}()
+
+	testString := "test"
+	testId := id.NewIdFromString(testString, id.Node, t)
+	applicationId := uint64(10)
+	err = d.InsertApplication(&Application{Id: applicationId}, &Node{
+		Code:          testString,
+		Id:            testId.Marshal(),
+		ApplicationId: applicationId,
+	})
+	if err != nil {
+		t.Fatalf("Failed to insert data for connectivity test")
+	}
+
+	for _, connectivity := range []uint32{node.PortFailed, node.PortUnknown} {
+		err = d.UpdateNodeConnectivity(testId, connectivity)
+		if err != nil {
+			t.Errorf(err.Error())
+		}
+
+		result, err := d.GetNode(testString)
+		if err != nil {
+			t.Fatalf("Failed to get node: %+v", err)
+		}
+		if result.Connectivity != connectivity {
+			t.Errorf("Connectivity did not update correctly, got %d expected %d",
+				result.Connectivity, connectivity)
+		}
+	}
+}
+
+// Happy path
+func TestDatabaseImpl_UpdateNodeStatus(t *testing.T) {
+	d, dc, err := NewDatabase("", "", "TestDatabaseImpl_UpdateNodeStatus", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := dc()
+		if err != nil {
+			t.Errorf("Failed to close database: %+v", err)
+		}
+	}()
+
+	testString := "test"
+	testId := id.NewIdFromString(testString, id.Node, t)
+	applicationId := uint64(10)
+	err = d.InsertApplication(&Application{Id: applicationId}, &Node{
+		Code:          testString,
+		Id:            testId.Marshal(),
+		ApplicationId: applicationId,
+	})
+	if err != nil {
+		t.Fatalf("Failed to insert data for status test")
+	}
+
+	for _, status := range []node.Status{node.Banned, node.Active} {
+		err = d.UpdateNodeStatus(testId, status)
+		if err != nil {
+			t.Errorf(err.Error())
+		}
+
+		result, err := d.GetNode(testString)
+		if err != nil {
+			t.Fatalf("Failed to get node: %+v", err)
+		}
+		if node.Status(result.Status) != status {
+			t.Errorf("Status did not update correctly, got %s expected %s",
+				node.Status(result.Status), status)
+		}
+	}
+}
+
+// Happy path: tests that changed versions update the Node and accumulate in
+// its history while repeated versions do not.
+func TestDatabaseImpl_UpdateNodeVersions(t *testing.T) {
+	d, dc, err := NewDatabase("", "", "TestDatabaseImpl_UpdateNodeVersions", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := dc()
+		if err != nil {
+			t.Errorf("Failed to close database: %+v", err)
+		}
+	}()
+
+	testString := "test"
+	testId := id.NewIdFromString(testString, id.Node, t)
+	applicationId := uint64(10)
+	err = d.InsertApplication(&Application{Id: applicationId}, &Node{
+		Code:          testString,
+		Id:            testId.Marshal(),
+		ApplicationId: applicationId,
+	})
+	if err != nil {
+		t.Fatalf("Failed to insert data for versions test")
+	}
+
+	reported := [][2]string{{"1.0.0", ""}, {"1.0.0", "1.0.0"},
+		{"1.0.0", "1.0.0"}, {"1.1.0", "1.0.0"}}
+	start := time.Now()
+	for i, versions := range reported {
+		err = d.UpdateNodeVersions(testId, versions[0], versions[1],
+			start.Add(time.Duration(i)*time.Minute))
+		if err != nil {
+			t.Fatalf("Failed to update versions: %+v", err)
+		}
+	}
+
+	result, err := d.GetNode(testString)
+	if err != nil {
+		t.Fatalf("Failed to get node: %+v", err)
+	}
+	if result.ServerVersion != "1.1.0" || result.GatewayVersion != "1.0.0" {
+		t.Errorf("Versions did not update correctly, got %s and %s",
+			result.ServerVersion, result.GatewayVersion)
+	}
+
+	history, err := d.GetNodeVersionHistory(testId)
+	if err != nil {
+		t.Fatalf("Failed to get version history: %+v", err)
+	}
+	expected := [][2]string{reported[0], reported[1], reported[3]}
+	if len(history) != len(expected) {
+		t.Fatalf("Expected %d versions in the history, got %d",
+			len(expected), len(history))
+	}
+	for i, versions := range expected {
+		if history[i].ServerVersion != versions[0] ||
+			history[i].GatewayVersion != versions[1] {
+			t.Errorf("Unexpected version %d in the history: %+v", i, history[i])
+		}
+	}
+}
+
+// Tests that ReRegisterNode moves the Node and the rows referencing it to the
+// new ID and refuses a code the old ID is not registered with.
+func TestDatabaseImpl_ReRegisterNode(t *testing.T) {
+	d, dc, err := NewDatabase("", "", "TestDatabaseImpl_ReRegisterNode", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := dc()
+		if err != nil {
+			t.Errorf("Failed to close database: %+v", err)
+		}
+	}()
+
+	code := "test"
+	oldId := id.NewIdFromString("old", id.Node, t)
+	newId := id.NewIdFromString("new", id.Node, t)
+	applicationId := uint64(10)
+	err = d.InsertApplication(&Application{Id: applicationId}, &Node{
+		Code:          code,
+		Id:            oldId.Marshal(),
+		Salt:          []byte("oldSalt"),
+		ApplicationId: applicationId,
+		Connectivity:  node.PortSuccessful,
+	})
+	if err != nil {
+		t.Fatalf("Failed to insert node: %+v", err)
+	}
+	now := time.Now()
+	err = d.InsertNodeMetric(&NodeMetric{NodeId: oldId.Marshal(),
+		StartTime: now, EndTime: now, NumPings: 5})
+	if err != nil {
+		t.Fatalf("Failed to insert node metric: %+v", err)
+	}
+	if err = d.UpdateNodeVersions(oldId, "1.0.0", "1.0.0", now); err != nil {
+		t.Fatalf("Failed to update versions: %+v", err)
+	}
+	err = d.InsertNodeHealthEvent(&NodeHealthEvent{NodeId: oldId.Marshal(),
+		Severity: "warning", Timestamp: now})
+	if err != nil {
+		t.Fatalf("Failed to insert health event: %+v", err)
+	}
+	err = d.InsertRoundMetric(&RoundMetric{Id: 1, RoundEnd: now,
+		PrecompStraggler: oldId.Marshal()}, [][]byte{oldId.Marshal()})
+	if err != nil {
+		t.Fatalf("Failed to insert round metric: %+v", err)
+	}
+	if err = d.UpsertNodeGroup("group", []*id.ID{oldId}); err != nil {
+		t.Fatalf("Failed to insert node group: %+v", err)
+	}
+
+	err = d.ReRegisterNode(oldId, newId, []byte("newSalt"), "wrong",
+		"1.1.1.1", "cert", "2.2.2.2", "gwCert")
+	if err == nil {
+		t.Errorf("Node moved with a code it is not registered with.")
+	}
+
+	err = d.ReRegisterNode(oldId, newId, []byte("newSalt"), code,
+		"1.1.1.1", "cert", "2.2.2.2", "gwCert")
+	if err != nil {
+		t.Fatalf("Failed to move node: %+v", err)
+	}
+
+	n, err := d.GetNode(code)
+	if err != nil {
+		t.Fatalf("Failed to get node: %+v", err)
+	}
+	if !bytes.Equal(n.Id, newId.Marshal()) || string(n.Salt) != "newSalt" ||
+		n.ServerAddress != "1.1.1.1" || n.NodeCertificate != "cert" ||
+		n.GatewayAddress != "2.2.2.2" || n.GatewayCertificate != "gwCert" {
+		t.Errorf("Node was not updated: %+v", n)
+	}
+	if n.RegistrationSource != NewKeyRegistration || n.Connectivity != node.PortUnknown {
+		t.Errorf("Unexpected source %s or connectivity %d",
+			n.RegistrationSource, n.Connectivity)
+	}
+
+	history, err := d.GetNodeVersionHistory(newId)
+	if err != nil || len(history) != 1 {
+		t.Errorf("Version history was not moved: %+v, %+v", history, err)
+	}
+	events, err := d.GetNodeHealthEvents(newId, time.Time{})
+	if err != nil || len(events) != 1 {
+		t.Errorf("Health events were not moved: %+v, %+v", events, err)
+	}
+	var metrics []NodeMetric
+	d.GetDatabaseImpl(t).db.Where("node_id = ?", newId.Marshal()).Find(&metrics)
+	if len(metrics) != 1 || metrics[0].NumPings != 5 {
+		t.Errorf("Node metrics were not moved: %+v", metrics)
+	}
+	count, err := d.GetNodeRoundParticipation(newId, now.Add(-time.Minute),
+		now.Add(time.Minute))
+	if err != nil || count != 1 {
+		t.Errorf("Round history was not moved: %d, %+v", count, err)
+	}
+	stats, err := d.GetStragglerStats(now.Add(-time.Minute))
+	if err != nil || len(stats) != 1 || !bytes.Equal(stats[0].NodeId, newId.Marshal()) {
+		t.Errorf("Straggler was not moved: %+v, %+v", stats, err)
+	}
+	groups, err := d.GetNodeGroups()
+	if err != nil || len(groups["group"]) != 1 || !groups["group"][0].Cmp(newId) {
+		t.Errorf("Group membership was not moved: %+v, %+v", groups, err)
+	}
+	if _, err = d.GetNodeById(oldId); err == nil {
+		t.Errorf("Node can still be found by its old ID.")
+	}
+}
+
+// Inserts a registered node with metrics, versions, health events, a round, a
+// group membership and an avoid list for TestDatabaseImpl_DeleteNodeRegistration
+func insertDeletionTestNode(t *testing.T, d Storage, code string, nid *id.ID,
+	applicationId uint64, now time.Time) {
+	err := d.InsertApplication(&Application{Id: applicationId}, &Node{
+		Code:          code,
+		Id:            nid.Marshal(),
+		ApplicationId: applicationId,
+	})
+	if err != nil {
+		t.Fatalf("Failed to insert node: %+v", err)
+	}
+	err = d.InsertNodeMetric(&NodeMetric{NodeId: nid.Marshal(),
+		StartTime: now, EndTime: now, NumPings: 5})
+	if err != nil {
+		t.Fatalf("Failed to insert node metric: %+v", err)
+	}
+	if err = d.UpdateNodeVersions(nid, "1.0.0", "1.0.0", now); err != nil {
+		t.Fatalf("Failed to update versions: %+v", err)
+	}
+	err = d.InsertNodeHealthEvent(&NodeHealthEvent{NodeId: nid.Marshal(),
+		Severity: "warning", Timestamp: now})
+	if err != nil {
+		t.Fatalf("Failed to insert health event: %+v", err)
+	}
+	err = d.InsertRoundMetric(&RoundMetric{Id: applicationId, RoundEnd: now,
+		PrecompStraggler: nid.Marshal()}, [][]byte{nid.Marshal()})
+	if err != nil {
+		t.Fatalf("Failed to insert round metric: %+v", err)
+	}
+	if err = d.UpsertNodeGroup(code, []*id.ID{nid}); err != nil {
+		t.Fatalf("Failed to insert node group: %+v", err)
+	}
+}
+
+// Tests that DeleteNodeRegistration() removes the node, the rows referencing
+// it and its application, purging or keeping its metrics as requested, and
+// leaves other nodes untouched.
+func TestDatabaseImpl_DeleteNodeRegistration(t *testing.T) {
+	d, dc, err := NewDatabase("", "", "TestDatabaseImpl_DeleteNodeRegistration", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := dc()
+		if err != nil {
+			t.Errorf("Failed to close database: %+v", err)
+		}
+	}()
+	db := d.GetDatabaseImpl(t).db
+
+	now := time.Now()
+	purged := id.NewIdFromString("purged", id.Node, t)
+	retained := id.NewIdFromString("retained", id.Node, t)
+	kept := id.NewIdFromString("kept", id.Node, t)
+	insertDeletionTestNode(t, d, "purged", purged, 1, now)
+	insertDeletionTestNode(t, d, "retained", retained, 2, now)
+	insertDeletionTestNode(t, d, "kept", kept, 3, now)
+	err = d.InsertApplication(&Application{Id: 4}, &Node{Code: "unregistered",
+		ApplicationId: 4})
+	if err != nil {
+		t.Fatalf("Failed to insert node: %+v", err)
+	}
+	if err = d.UpsertAvoidList(3, []uint64{1, 2}); err != nil {
+		t.Fatalf("Failed to insert avoid list: %+v", err)
+	}
+
+	if err = d.DeleteNodeRegistration("missing", true); err == nil {
+		t.Errorf("Deleted a node with an unknown code.")
+	}
+	if err = d.DeleteNodeRegistration("purged", true); err != nil {
+		t.Fatalf("Failed to delete node: %+v", err)
+	}
+	if err = d.DeleteNodeRegistration("retained", false); err != nil {
+		t.Fatalf("Failed to delete node: %+v", err)
+	}
+	if err = d.DeleteNodeRegistration("unregistered", true); err != nil {
+		t.Fatalf("Failed to delete unregistered node: %+v", err)
+	}
+
+	for _, code := range []string{"purged", "retained", "unregistered"} {
+		if _, err = d.GetNode(code); err == nil {
+			t.Errorf("Node %s was not deleted.", code)
+		}
+	}
+	var applications []Application
+	db.Find(&applications)
+	if len(applications) != 1 || applications[0].Id != 3 {
+		t.Errorf("Applications were not deleted: %+v", applications)
+	}
+	avoidLists, err := d.GetAvoidLists()
+	if err != nil || len(avoidLists) != 0 {
+		t.Errorf("Avoid lists of deleted applications were kept: %v, %+v",
+			avoidLists, err)
+	}
+
+	for _, nid := range []*id.ID{purged, retained} {
+		for _, table := range []interface{}{&[]NodeVersion{},
+			&[]NodeHealthEvent{}, &[]NodeGroupMember{}, &[]Topology{},
+			&[]NodeMetric{}} {
+			var count int
+			db.Model(table).Where("node_id = ?", nid.Marshal()).Count(&count)
+			if count != 0 {
+				t.Errorf("%d rows of %T reference deleted node %s.", count,
+					table, nid)
+			}
+		}
+	}
+	var detached []NodeMetric
+	db.Where("node_id IS NULL").Find(&detached)
+	if len(detached) != 1 || detached[0].NumPings != 5 {
+		t.Errorf("Metrics of the retained node were not kept: %+v", detached)
+	}
+	stats, err := d.GetStragglerStats(now.Add(-time.Minute))
+	if err != nil || len(stats) != 2 {
+		t.Errorf("Unexpected stragglers after deletion: %+v, %+v", stats, err)
+	}
+	for _, s := range stats {
+		if bytes.Equal(s.NodeId, purged.Marshal()) {
+			t.Errorf("Purged node remains a straggler.")
+		}
+	}
+
+	// The remaining node is untouched
+	if _, err = d.GetNode("kept"); err != nil {
+		t.Errorf("Remaining node was deleted: %+v", err)
+	}
+	history, err := d.GetNodeVersionHistory(kept)
+	if err != nil || len(history) != 1 {
+		t.Errorf("Remaining node lost its versions: %+v, %+v", history, err)
+	}
+	count, err := d.GetNodeRoundParticipation(kept, now.Add(-time.Minute),
+		now.Add(time.Minute))
+	if err != nil || count != 1 {
+		t.Errorf("Remaining node lost its rounds: %d, %+v", count, err)
+	}
+}
+
+// Happy path: tests that node groups can be stored, replaced, retrieved in
+// order, and deleted.
+func TestDatabaseImpl_NodeGroups(t *testing.T) {
+	d, dc, err := NewDatabase("", "", "TestDatabaseImpl_NodeGroups", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := dc()
+		if err != nil {
+			t.Errorf("Failed to close database: %+v", err)
+		}
+	}()
+
+	members := []*id.ID{
+		id.NewIdFromString("z", id.Node, t),
+		id.NewIdFromString("y", id.Node, t),
+		id.NewIdFromString("x", id.Node, t),
+	}
+	if err = d.UpsertNodeGroup("first", members); err != nil {
+		t.Fatalf("Failed to insert node group: %+v", err)
+	}
+	if err = d.UpsertNodeGroup("second", members[:2]); err != nil {
+		t.Fatalf("Failed to insert node group: %+v", err)
+	}
+	// Replace the members of the second group
+	if err = d.UpsertNodeGroup("second", members[2:]); err != nil {
+		t.Fatalf("Failed to replace node group: %+v", err)
+	}
+
+	groups, err := d.GetNodeGroups()
+	if err != nil {
+		t.Fatalf("Failed to get node groups: %+v", err)
+	}
+	expected := map[string][]*id.ID{"first": members, "second": members[2:]}
+	if !reflect.DeepEqual(groups, expected) {
+		t.Errorf("Unexpected node groups.\nexpected: %v\nreceived: %v",
+			expected, groups)
+	}
+
+	if err = d.DeleteNodeGroup("first"); err != nil {
+		t.Fatalf("Failed to delete node group: %+v", err)
+	}
+	groups, err = d.GetNodeGroups()
+	if err != nil {
+		t.Fatalf("Failed to get node groups: %+v", err)
+	}
+	if _, exists := groups["first"]; exists || len(groups) != 1 {
+		t.Errorf("Node group not deleted: %v", groups)
+	}
+}
+
+// Happy path
+func TestDatabaseImpl_AvoidLists(t *testing.T) {
+	d, dc, err := NewDatabase("", "", "TestDatabaseImpl_AvoidLists", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := dc()
+		if err != nil {
+			t.Errorf("Failed to close database: %+v", err)
+		}
+	}()
+
+	if err = d.UpsertAvoidList(1, []uint64{3, 2}); err != nil {
+		t.Fatalf("Failed to insert avoid-list: %+v", err)
+	}
+	if err = d.UpsertAvoidList(2, []uint64{1}); err != nil {
+		t.Fatalf("Failed to insert avoid-list: %+v", err)
+	}
+	// Replace and clear avoid-lists
+	if err = d.UpsertAvoidList(1, []uint64{4}); err != nil {
+		t.Fatalf("Failed to replace avoid-list: %+v", err)
+	}
+	if err = d.UpsertAvoidList(2, nil); err != nil {
+		t.Fatalf("Failed to clear avoid-list: %+v", err)
+	}
+
+	avoidLists, err := d.GetAvoidLists()
+	if err != nil {
+		t.Fatalf("Failed to get avoid-lists: %+v", err)
+	}
+	expected := map[uint64][]uint64{1: {4}}
+	if !reflect.DeepEqual(avoidLists, expected) {
+		t.Errorf("Unexpected avoid-lists.\nexpected: %v\nreceived: %v",
+			expected, avoidLists)
+	}
+}
+
+// Happy path
+func TestDatabaseImpl_GetApplicationsByTeam(t *testing.T) {
+	d, dc, err := NewDatabase("", "", "TestDatabaseImpl_GetApplicationsByTeam", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := dc()
+		if err != nil {
+			t.Errorf("Failed to close database: %+v", err)
+		}
+	}()
+
+	teams := []string{"blue", "red", "blue", ""}
+	for i, team := range teams {
+		err = d.InsertApplication(&Application{Id: uint64(i + 1), Team: team},
+			&Node{Code: strconv.Itoa(i), Id: []byte{byte(i)}})
+		if err != nil {
+			t.Fatalf("Failed to insert application: %+v", err)
+		}
+	}
+
+	testCases := map[string][]uint64{
+		"blue":    {1, 3},
+		"red":     {2},
+		"unknown": {},
+		"":        {},
+	}
+	for team, expected := range testCases {
+		applications, err := d.GetApplicationsByTeam(team)
+		if err != nil {
+			t.Fatalf("Failed to get applications for team %q: %+v", team, err)
+		}
+		checkApplicationIds(t, team, applications, expected)
+	}
+}
+
+// Happy path
+func TestDatabaseImpl_GetApplicationsByNetwork(t *testing.T) {
+	d, dc, err := NewDatabase("", "", "TestDatabaseImpl_GetApplicationsByNetwork", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := dc()
+		if err != nil {
+			t.Errorf("Failed to close database: %+v", err)
+		}
+	}()
+
+	networks := []string{"mainnet", "", "betanet", "mainnet"}
+	for i, network := range networks {
+		err = d.InsertApplication(&Application{Id: uint64(i + 1), Network: network},
+			&Node{Code: strconv.Itoa(i), Id: []byte{byte(i)}})
+		if err != nil {
+			t.Fatalf("Failed to insert application: %+v", err)
+		}
+	}
+
+	testCases := map[string][]uint64{
+		"mainnet": {1, 4},
+		"betanet": {3},
+		"unknown": {},
+		"":        {},
+	}
+	for network, expected := range testCases {
+		applications, err := d.GetApplicationsByNetwork(network)
+		if err != nil {
+			t.Fatalf("Failed to get applications for network %q: %+v",
+				network, err)
+		}
+		checkApplicationIds(t, network, applications, expected)
+	}
+}
+
+// checkApplicationIds errors if the IDs of the applications do not match the
+// expected IDs in order, or if any application is missing its node
+func checkApplicationIds(t *testing.T, filter string,
+	applications []*Application, expected []uint64) {
+	if applications == nil {
+		t.Errorf("Received nil applications for %q", filter)
+	}
+	ids := make([]uint64, 0, len(applications))
+	for _, app := range applications {
+		ids = append(ids, app.Id)
+		if app.Node.ApplicationId != app.Id {
+			t.Errorf("Application %d for %q was not returned with its node",
+				app.Id, filter)
+		}
+	}
+	if !reflect.DeepEqual(ids, expected) {
+		t.Errorf("Unexpected applications for %q.\nexpected: %v\nreceived: %v",
+			filter, expected, ids)
+	}
+}
+
+// Tests that imported registration codes are tagged as bulk imports.
+func TestPopulateNodeRegistrationCodes_Source(t *testing.T) {
+	var err error
+	var dc func() error
+	PermissioningDb, dc, err = NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = dc() }()
+
+	PopulateNodeRegistrationCodes([]node.Info{{RegCode: "AAAA", Order: "US"}})
+	n, err := PermissioningDb.GetNode("AAAA")
+	if err != nil {
+		t.Fatalf("Failed to get node: %+v", err)
+	}
+	if n.RegistrationSource != BulkImportRegistration {
+		t.Errorf("Unexpected registration source.\nexpected: %s\nreceived: %s",
+			BulkImportRegistration, n.RegistrationSource)
+	}
+}
+
+// Tests that the registration date of nodes registered before it was recorded
+// is backfilled from their earliest metric, and that other nodes are left as
+// they are.
+func TestBackfillDateRegistered(t *testing.T) {
+	d, dc, err := NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = dc() }()
+	db := d.database.(*DatabaseImpl).db
+
+	registered := time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)
+	earliest := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+	nodes := []*Node{
+		// Legacy node with metrics
+		{Code: "AAAA", Id: id.NewIdFromString("A", id.Node, t).Bytes(),
+			ApplicationId: 1},
+		// Node with a recorded registration date
+		{Code: "BBBB", Id: id.NewIdFromString("B", id.Node, t).Bytes(),
+			ApplicationId: 2, DateRegistered: registered},
+		// Legacy node without metrics
+		{Code: "CCCC", Id: id.NewIdFromString("C", id.Node, t).Bytes(),
+			ApplicationId: 3},
+	}
+	for _, n := range nodes {
+		err = d.InsertApplication(&Application{Id: n.ApplicationId}, n)
+		if err != nil {
+			t.Fatalf("Failed to insert node %s: %+v", n.Code, err)
+		}
+	}
+	for _, nodeIdx := range []int{0, 1} {
+		for _, start := range []time.Time{earliest.Add(time.Hour), earliest,
+			earliest.Add(2 * time.Hour)} {
+			err = d.InsertNodeMetric(&NodeMetric{NodeId: nodes[nodeIdx].Id,
+				StartTime: start, EndTime: start.Add(time.Minute)})
+			if err != nil {
+				t.Fatalf("Failed to insert node metric: %+v", err)
+			}
+		}
+	}
+
+	if err = backfillDateRegistered(db); err != nil {
+		t.Fatalf("Failed to backfill registration dates: %+v", err)
+	}
+
+	expected := map[string]time.Time{
+		"AAAA": earliest,
+		"BBBB": registered,
+		"CCCC": {},
+	}
+	for code, date := range expected {
+		n, err := d.GetNode(code)
+		if err != nil {
+			t.Fatalf("Failed to get node %s: %+v", code, err)
+		}
+		if !n.DateRegistered.Equal(date) {
+			t.Errorf("Unexpected registration date for %s."+
+				"\nexpected: %s\nreceived: %s", code, date, n.DateRegistered)
+		}
+	}
+}
+
+// Tests that GetRegistrationsByTimeRange returns the nodes registered within
+// the range, in the order they registered, and that GetRegistrationRate
+// computes the registrations per hour over it.
+func TestDatabaseImpl_GetRegistrationsByTimeRange(t *testing.T) {
+	d, dc, err := NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = dc() }()
+	db := d.database.(*DatabaseImpl).db
+
+	start := time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)
+	registered := []time.Time{
+		start.Add(-time.Minute),
+		start.Add(3 * time.Hour),
+		start,
+		start.Add(time.Hour),
+		start.Add(4 * time.Hour),
+	}
+	for i, date := range registered {
+		code := strconv.Itoa(i)
+		err = d.InsertApplication(&Application{Id: uint64(i + 1)},
+			&Node{Code: code, ApplicationId: uint64(i + 1)})
+		if err != nil {
+			t.Fatalf("Failed to insert application: %+v", err)
+		}
+		nid := id.NewIdFromUInt(uint64(i), id.Node, t)
+		err = d.RegisterNode(nid, []byte("salt"), code, "", "", "", "",
+			SelfServeRegistration)
+		if err != nil {
+			t.Fatalf("Failed to register node: %+v", err)
+		}
+		err = db.Model(&Node{}).Where("code = ?", code).
+			Update("date_registered", date).Error
+		if err != nil {
+			t.Fatalf("Failed to set registration date: %+v", err)
+		}
+	}
+
+	end := start.Add(4 * time.Hour)
+	nodes, err := d.GetRegistrationsByTimeRange(start, end)
+	if err != nil {
+		t.Fatalf("Failed to get registrations: %+v", err)
+	}
+	var codes []string
+	for _, n := range nodes {
+		codes = append(codes, n.Code)
+	}
+	if expected := []string{"2", "3", "1"}; !reflect.DeepEqual(expected, codes) {
+		t.Errorf("Unexpected registrations.\nexpected: %v\nreceived: %v",
+			expected, codes)
+	}
+
+	rate, err := d.GetRegistrationRate(start, end)
+	if err != nil {
+		t.Fatalf("Failed to get registration rate: %+v", err)
+	}
+	if rate != 0.75 {
+		t.Errorf("Unexpected registration rate.\nexpected: %f\nreceived: %f",
+			0.75, rate)
+	}
+
+	if _, err = d.GetRegistrationRate(end, start); err == nil {
+		t.Errorf("Expected an error for an inverted time range.")
+	}
+}
+
+// Tests that GetNodesPage pages through the nodes matching the filter in
+// registration code order, returns the total matching the filter, and that
+// ForEachNodePage visits each matching node exactly once.
+func TestDatabaseImpl_GetNodesPage(t *testing.T) {
+	d, dc, err := NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = dc() }()
+	db := d.database.(*DatabaseImpl).db
+
+	registered := time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)
+	// Inserted out of code order, so ordering does not follow insertion
+	codes := []string{"E", "B", "G", "A", "F", "C", "D"}
+	for i, code := range codes {
+		status := node.Active
+		if i%3 == 2 {
+			status = node.Banned
+		}
+		sequence := "US"
+		if i%2 == 1 {
+			sequence = "GB"
+		}
+		err = d.InsertApplication(&Application{Id: uint64(i + 1)},
+			&Node{Code: code, Sequence: sequence, Status: uint8(status),
+				ApplicationId: uint64(i + 1)})
+		if err != nil {
+			t.Fatalf("Failed to insert application: %+v", err)
+		}
+		err = db.Model(&Node{}).Where("code = ?", code).
+			Update("date_registered", registered.Add(time.Duration(i)*time.Hour)).Error
+		if err != nil {
+			t.Fatalf("Failed to set registration date: %+v", err)
+		}
+	}
+
+	pageCodes := func(nodes []*Node) []string {
+		received := make([]string, 0, len(nodes))
+		for _, n := range nodes {
+			received = append(received, n.Code)
+		}
+		return received
+	}
+
+	// Page boundaries follow the registration codes
+	var pages [][]string
+	afterCode := ""
+	for {
+		nodes, total, err := d.GetNodesPage(NodeFilter{}, afterCode, 3)
+		if err != nil {
+			t.Fatalf("Failed to get page: %+v", err)
+		}
+		if total != len(codes) {
+			t.Errorf("Unexpected total.\nexpected: %d\nreceived: %d",
+				len(codes), total)
+		}
+		if len(nodes) == 0 {
+			break
+		}
+		pages = append(pages, pageCodes(nodes))
+		afterCode = nodes[len(nodes)-1].Code
+	}
+	expected := [][]string{{"A", "B", "C"}, {"D", "E", "F"}, {"G"}}
+	if !reflect.DeepEqual(expected, pages) {
+		t.Errorf("Unexpected pages.\nexpected: %v\nreceived: %v", expected, pages)
+	}
+
+	// The same page is returned every time
+	for i := 0; i < 3; i++ {
+		nodes, _, err := d.GetNodesPage(NodeFilter{}, "C", 3)
+		if err != nil {
+			t.Fatalf("Failed to get page: %+v", err)
+		}
+		if received := pageCodes(nodes); !reflect.DeepEqual(expected[1], received) {
+			t.Errorf("Page is not stable.\nexpected: %v\nreceived: %v",
+				expected[1], received)
+		}
+	}
+
+	filters := map[string]struct {
+		filter   NodeFilter
+		expected []string
+	}{
+		"status": {NodeFilter{Statuses: []node.Status{node.Banned}},
+			[]string{"C", "G"}},
+		"sequence": {NodeFilter{Sequences: []string{"GB"}},
+			[]string{"A", "B", "C"}},
+		"application": {NodeFilter{ApplicationId: 4}, []string{"A"}},
+		"registered after": {NodeFilter{RegisteredAfter: registered.Add(4 * time.Hour)},
+			[]string{"C", "D"}},
+		"combined": {NodeFilter{Statuses: []node.Status{node.Active},
+			Sequences: []string{"US"}}, []string{"D", "E", "F"}},
+	}
+	for name, f := range filters {
+		nodes, total, err := d.GetNodesPage(f.filter, "", 100)
+		if err != nil {
+			t.Fatalf("Failed to get page filtered by %s: %+v", name, err)
+		}
+		if received := pageCodes(nodes); !reflect.DeepEqual(f.expected, received) ||
+			total != len(f.expected) {
+			t.Errorf("Unexpected nodes filtered by %s.\nexpected: %v\n"+
+				"received: %v (total %d)", name, f.expected, received, total)
+		}
+	}
+
+	if _, _, err = d.GetNodesPage(NodeFilter{}, "", 0); err == nil {
+		t.Errorf("Expected an error for an empty page size.")
+	}
+
+	var visited []string
+	err = d.ForEachNodePage(NodeFilter{}, 2, func(nodes []*Node) error {
+		if len(nodes) > 2 {
+			t.Errorf("Page of %d nodes exceeds the page size.", len(nodes))
+		}
+		visited = append(visited, pageCodes(nodes)...)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ForEachNodePage returned an error: %+v", err)
+	}
+	if all := []string{"A", "B", "C", "D", "E", "F", "G"}; !reflect.DeepEqual(all, visited) {
+		t.Errorf("Unexpected nodes visited.\nexpected: %v\nreceived: %v",
+			all, visited)
+	}
+}
+
+// Happy path: tests that health events are returned for the Node they were
+// reported by from the given time, oldest first.
+func TestDatabaseImpl_GetNodeHealthEvents(t *testing.T) {
+	d, dc, err := NewDatabase("", "", "TestDatabaseImpl_GetNodeHealthEvents", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := dc()
+		if err != nil {
+			t.Errorf("Failed to close database: %+v", err)
+		}
+	}()
+
+	ids := []*id.ID{id.NewIdFromString("a", id.Node, t),
+		id.NewIdFromString("b", id.Node, t)}
+	for i, nid := range ids {
+		applicationId := uint64(10 + i)
+		err = d.InsertApplication(&Application{Id: applicationId}, &Node{
+			Code:          nid.String(),
+			Id:            nid.Marshal(),
+			ApplicationId: applicationId,
+		})
+		if err != nil {
+			t.Fatalf("Failed to insert node: %+v", err)
+		}
+	}
+
+	start := time.Now()
+	reported := []*NodeHealthEvent{
+		{NodeId: ids[0].Marshal(), Severity: "warning", Message: "disk 90% full",
+			Timestamp: start},
+		{NodeId: ids[1].Marshal(), Severity: "critical", Message: "GPU failure",
+			Timestamp: start.Add(time.Minute)},
+		{NodeId: ids[0].Marshal(), Severity: "critical", Message: "disk full",
+			Timestamp: start.Add(2 * time.Minute)},
+		{NodeId: ids[0].Marshal(), Severity: "healthy",
+			Timestamp: start.Add(3 * time.Minute)},
+	}
+	for _, event := range reported {
+		if err = d.InsertNodeHealthEvent(event); err != nil {
+			t.Fatalf("Failed to insert health event: %+v", err)
+		}
+	}
+
+	events, err := d.GetNodeHealthEvents(ids[0], start.Add(time.Minute))
+	if err != nil {
+		t.Fatalf("Failed to get health events: %+v", err)
+	}
+	if len(events) != 2 || events[0].Message != "disk full" ||
+		events[1].Severity != "healthy" {
+		t.Errorf("Unexpected health events: %+v", events)
+	}
+}
@@ -0,0 +1,35 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package storage
+
+// Contains the reporting of nodes demoted to the diagnostic state by the
+// scheduler
+
+import (
+	"gitlab.com/elixxir/registration/storage/node"
+)
+
+// SetNodeDemotionHandler sets the function called when the scheduler demotes
+// a node to the diagnostic state, such as to alert its operator. It is called
+// by the scheduler, so it must not block.
+func (s *NetworkState) SetNodeDemotionHandler(handler func(n *node.State, reason string)) {
+	s.demotionHandlerMux.Lock()
+	defer s.demotionHandlerMux.Unlock()
+	s.demotionHandler = handler
+}
+
+// NodeDemoted calls the node demotion handler, if one is set, for the node
+// demoted to the diagnostic state for the given reason.
+func (s *NetworkState) NodeDemoted(n *node.State, reason string) {
+	s.demotionHandlerMux.RLock()
+	handler := s.demotionHandler
+	s.demotionHandlerMux.RUnlock()
+	if handler != nil {
+		handler(n, reason)
+	}
+}
@@ -0,0 +1,106 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Handles named groups of nodes used to build rounds from pre-defined node sets
+
+package storage
+
+import (
+	"github.com/pkg/errors"
+	"gitlab.com/xx_network/primitives/id"
+	"sort"
+)
+
+// LoadNodeGroups replaces the node groups with those persisted to Storage.
+func (s *NetworkState) LoadNodeGroups() error {
+	groups, err := PermissioningDb.GetNodeGroups()
+	if err != nil {
+		return errors.WithMessage(err, "Failed to load node groups")
+	}
+
+	s.nodeGroupsMux.Lock()
+	defer s.nodeGroupsMux.Unlock()
+	s.nodeGroups = groups
+	return nil
+}
+
+// SetNodeGroup defines the node group with the given name to contain the
+// given nodes, replacing any existing group of the same name. The group is
+// persisted to Storage before it takes effect.
+func (s *NetworkState) SetNodeGroup(name string, members []*id.ID) error {
+	if name == "" {
+		return errors.New("Node group name cannot be empty")
+	}
+	if len(members) == 0 {
+		return errors.Errorf("Node group %s must have at least one member", name)
+	}
+
+	// Copy the members to deduplicate them and so the caller cannot modify
+	// the group
+	seen := make(map[id.ID]bool, len(members))
+	group := make([]*id.ID, 0, len(members))
+	for _, nid := range members {
+		if seen[*nid] {
+			return errors.Errorf("Node %s is listed more than once in "+
+				"node group %s", nid, name)
+		}
+		seen[*nid] = true
+		group = append(group, nid.DeepCopy())
+	}
+
+	s.nodeGroupsMux.Lock()
+	defer s.nodeGroupsMux.Unlock()
+
+	err := PermissioningDb.UpsertNodeGroup(name, group)
+	if err != nil {
+		return errors.WithMessagef(err, "Failed to store node group %s", name)
+	}
+	s.nodeGroups[name] = group
+	return nil
+}
+
+// DeleteNodeGroup removes the node group with the given name. It is not an
+// error to delete a group which does not exist.
+func (s *NetworkState) DeleteNodeGroup(name string) error {
+	s.nodeGroupsMux.Lock()
+	defer s.nodeGroupsMux.Unlock()
+
+	err := PermissioningDb.DeleteNodeGroup(name)
+	if err != nil {
+		return errors.WithMessagef(err, "Failed to delete node group %s", name)
+	}
+	delete(s.nodeGroups, name)
+	return nil
+}
+
+// GetNodeGroup returns the members of the node group with the given name, in
+// the order they were defined. Returns false if the group does not exist.
+func (s *NetworkState) GetNodeGroup(name string) ([]*id.ID, bool) {
+	s.nodeGroupsMux.RLock()
+	defer s.nodeGroupsMux.RUnlock()
+
+	group, exists := s.nodeGroups[name]
+	if !exists {
+		return nil, false
+	}
+	members := make([]*id.ID, len(group))
+	copy(members, group)
+	return members, true
+}
+
+// GetNodeGroupNames returns the sorted names of all defined node groups.
+func (s *NetworkState) GetNodeGroupNames() []string {
+	s.nodeGroupsMux.RLock()
+	defer s.nodeGroupsMux.RUnlock()
+
+	names := make([]string, 0, len(s.nodeGroups))
+	for name := range s.nodeGroups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
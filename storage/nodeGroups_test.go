@@ -0,0 +1,108 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package storage
+
+import (
+	"gitlab.com/xx_network/primitives/id"
+	"reflect"
+	"testing"
+)
+
+// Tests that node groups set on the NetworkState can be retrieved, replaced,
+// and deleted, and that they are reloaded from Storage by a new NetworkState.
+func TestNetworkState_SetNodeGroup(t *testing.T) {
+	var err error
+	PermissioningDb, _, err = NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("Failed to create database: %+v", err)
+	}
+
+	state, privateKey, err := generateTestNetworkState()
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	group := []*id.ID{
+		id.NewIdFromString("c", id.Node, t),
+		id.NewIdFromString("a", id.Node, t),
+		id.NewIdFromString("b", id.Node, t),
+	}
+	if err = state.SetNodeGroup("alpha", group); err != nil {
+		t.Fatalf("SetNodeGroup() returned an error: %+v", err)
+	}
+	if err = state.SetNodeGroup("beta", group[:1]); err != nil {
+		t.Fatalf("SetNodeGroup() returned an error: %+v", err)
+	}
+
+	received, exists := state.GetNodeGroup("alpha")
+	if !exists || !reflect.DeepEqual(received, group) {
+		t.Errorf("GetNodeGroup() returned the wrong group."+
+			"\nexpected: %v\nreceived: %v", group, received)
+	}
+
+	names := state.GetNodeGroupNames()
+	if !reflect.DeepEqual(names, []string{"alpha", "beta"}) {
+		t.Errorf("Unexpected group names: %v", names)
+	}
+
+	// Replace a group
+	if err = state.SetNodeGroup("alpha", group[1:]); err != nil {
+		t.Fatalf("SetNodeGroup() returned an error: %+v", err)
+	}
+	if err = state.DeleteNodeGroup("beta"); err != nil {
+		t.Fatalf("DeleteNodeGroup() returned an error: %+v", err)
+	}
+	if _, exists = state.GetNodeGroup("beta"); exists {
+		t.Errorf("Deleted group still exists.")
+	}
+
+	// Restart and check the groups were persisted
+	reloaded, err := NewState(privateKey, 8, "", "", state.GetGeoBins())
+	if err != nil {
+		t.Fatalf("Failed to create new state: %+v", err)
+	}
+	if err = reloaded.LoadNodeGroups(); err != nil {
+		t.Fatalf("Failed to load node groups: %+v", err)
+	}
+	received, exists = reloaded.GetNodeGroup("alpha")
+	if !exists || !reflect.DeepEqual(received, group[1:]) {
+		t.Errorf("Reloaded state has the wrong group."+
+			"\nexpected: %v\nreceived: %v", group[1:], received)
+	}
+	if _, exists = reloaded.GetNodeGroup("beta"); exists {
+		t.Errorf("Reloaded state has a deleted group.")
+	}
+}
+
+// Error path: tests that invalid node groups are rejected.
+func TestNetworkState_SetNodeGroup_Invalid(t *testing.T) {
+	var err error
+	PermissioningDb, _, err = NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("Failed to create database: %+v", err)
+	}
+
+	state, _, err := generateTestNetworkState()
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	nid := id.NewIdFromString("a", id.Node, t)
+	if err = state.SetNodeGroup("", []*id.ID{nid}); err == nil {
+		t.Errorf("Expected error for an empty group name.")
+	}
+	if err = state.SetNodeGroup("empty", nil); err == nil {
+		t.Errorf("Expected error for a group without members.")
+	}
+	if err = state.SetNodeGroup("dup", []*id.ID{nid, nid}); err == nil {
+		t.Errorf("Expected error for a duplicated member.")
+	}
+	if len(state.GetNodeGroupNames()) != 0 {
+		t.Errorf("Invalid groups should not be stored.")
+	}
+}
@@ -0,0 +1,129 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Handles exporting the node map as a signed snapshot for audit
+
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/pkg/errors"
+	pb "gitlab.com/elixxir/comms/mixmessages"
+	"gitlab.com/elixxir/registration/storage/node"
+	"gitlab.com/xx_network/comms/signature"
+	"gitlab.com/xx_network/crypto/signature/rsa"
+	"google.golang.org/protobuf/proto"
+	"sort"
+	"time"
+)
+
+// NodeSnapshot is the state of every node in the node map at a point in time.
+type NodeSnapshot struct {
+	Timestamp time.Time           `json:"timestamp"`
+	Nodes     []NodeSnapshotEntry `json:"nodes"`
+}
+
+// NodeSnapshotEntry is the state of a single node in a NodeSnapshot.
+type NodeSnapshotEntry struct {
+	Id       []byte `json:"id"`
+	Status   string `json:"status"`
+	Activity string `json:"activity"`
+	Ordering string `json:"ordering"`
+	// One of the node connectivity statuses
+	Connectivity uint32 `json:"connectivity"`
+	Probation    bool   `json:"probation"`
+	Diagnostic   bool   `json:"diagnostic"`
+	// Poll counts of the last monitoring periods, oldest first
+	PollHistory []uint64 `json:"pollHistory"`
+	// Index of the node in the topology of its current round, -1 if it is
+	// not in a round
+	TopologyPosition int `json:"topologyPosition"`
+	// Whether the node relays its round updates to its gateway
+	RelaysUpdates bool `json:"relaysUpdates"`
+	// Times the node was left out of a team, keyed by exclusion reason
+	Exclusions map[string]uint64 `json:"exclusions"`
+	// Address the node's polls are observed to come from, and whether it
+	// differs from the advertised one
+	ObservedAddress string `json:"observedAddress"`
+	AddressMismatch bool   `json:"addressMismatch"`
+}
+
+// SignedNodeSnapshot serializes the node map, ordered by node ID, and signs it
+// with the permissioning key the same way the NDF is signed. The snapshot is
+// returned as a marshalled NDF message holding the JSON encoded NodeSnapshot
+// and its signature, which VerifyNodeSnapshot checks.
+func (s *NetworkState) SignedNodeSnapshot() ([]byte, error) {
+	snapshot := NodeSnapshot{Timestamp: time.Now()}
+	for _, n := range s.GetNodeMap().GetNodeStates() {
+		position, inRound := n.GetTopologyPosition()
+		if !inRound {
+			position = -1
+		}
+		observed, mismatched := n.GetObservedAddress()
+		snapshot.Nodes = append(snapshot.Nodes, NodeSnapshotEntry{
+			Id:           n.GetID().Marshal(),
+			Status:       n.GetStatus().String(),
+			Activity:     n.GetActivity().String(),
+			Ordering:     n.GetOrdering(),
+			Connectivity: n.GetRawConnectivity(),
+			Probation:    n.IsOnProbation(),
+			Diagnostic:   n.IsDiagnostic(),
+			PollHistory:  n.GetPollHistory(),
+
+			TopologyPosition: position,
+			RelaysUpdates:    n.RelaysUpdates(),
+			Exclusions:       exclusionBreakdown(n.GetExclusions()),
+			ObservedAddress:  observed,
+			AddressMismatch:  mismatched,
+		})
+	}
+	sort.Slice(snapshot.Nodes, func(i, j int) bool {
+		return bytes.Compare(snapshot.Nodes[i].Id, snapshot.Nodes[j].Id) < 0
+	})
+
+	data, err := json.Marshal(&snapshot)
+	if err != nil {
+		return nil, errors.Errorf("Failed to serialize node map: %+v", err)
+	}
+
+	msg := &pb.NDF{Ndf: data}
+	if err = signature.SignRsa(msg, s.rsaPrivateKey); err != nil {
+		return nil, errors.Errorf("Failed to sign node map snapshot: %+v", err)
+	}
+	return proto.Marshal(msg)
+}
+
+// exclusionBreakdown keys the exclusion counts by the name of their reason
+func exclusionBreakdown(counts [node.NumExclusionReasons]uint64) map[string]uint64 {
+	breakdown := make(map[string]uint64, len(counts))
+	for reason, count := range counts {
+		breakdown[node.ExclusionReason(reason).String()] = count
+	}
+	return breakdown
+}
+
+// VerifyNodeSnapshot verifies a snapshot produced by SignedNodeSnapshot
+// against the permissioning public key and returns its contents.
+func VerifyNodeSnapshot(signed []byte, pubKey *rsa.PublicKey) (*NodeSnapshot, error) {
+	msg := &pb.NDF{}
+	if err := proto.Unmarshal(signed, msg); err != nil {
+		return nil, errors.Errorf("Failed to unmarshal node map snapshot: "+
+			"%+v", err)
+	}
+	if err := signature.VerifyRsa(msg, pubKey); err != nil {
+		return nil, errors.Errorf("Failed to verify node map snapshot: %+v",
+			err)
+	}
+
+	snapshot := &NodeSnapshot{}
+	if err := json.Unmarshal(msg.Ndf, snapshot); err != nil {
+		return nil, errors.Errorf("Failed to parse node map snapshot: %+v",
+			err)
+	}
+	return snapshot, nil
+}
@@ -0,0 +1,109 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package storage
+
+import (
+	"bytes"
+	"crypto/rand"
+	pb "gitlab.com/elixxir/comms/mixmessages"
+	"gitlab.com/elixxir/primitives/current"
+	"gitlab.com/elixxir/registration/storage/node"
+	"gitlab.com/xx_network/crypto/signature/rsa"
+	"gitlab.com/xx_network/primitives/id"
+	"google.golang.org/protobuf/proto"
+	"testing"
+)
+
+// Tests that SignedNodeSnapshot produces a snapshot of the current node map
+// which verifies against the permissioning public key.
+func TestNetworkState_SignedNodeSnapshot(t *testing.T) {
+	var err error
+	PermissioningDb, _, err = NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("Failed to create database: %+v", err)
+	}
+	state, privateKey, err := generateTestNetworkState()
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	ids := []*id.ID{id.NewIdFromUInt(2, id.Node, t), id.NewIdFromUInt(1, id.Node, t)}
+	for i, nid := range ids {
+		if err = state.GetNodeMap().AddNode(nid, "US", "", "", uint64(i)); err != nil {
+			t.Fatalf("Failed to add node: %+v", err)
+		}
+	}
+	first := state.GetNodeMap().GetNode(ids[0])
+	if _, _, err = first.Update(current.WAITING); err != nil {
+		t.Fatalf("Failed to update node: %+v", err)
+	}
+	first.SetConnectivity(node.PortSuccessful)
+	if _, err = first.Ban(); err != nil {
+		t.Fatalf("Failed to ban node: %+v", err)
+	}
+	state.RecordExclusion(first, node.ExcludedConnectivity, 1)
+	first.SetObservedAddress("9.9.9.9")
+
+	signed, err := state.SignedNodeSnapshot()
+	if err != nil {
+		t.Fatalf("SignedNodeSnapshot() returned an error: %+v", err)
+	}
+	snapshot, err := VerifyNodeSnapshot(signed, privateKey.GetPublic())
+	if err != nil {
+		t.Fatalf("Failed to verify snapshot: %+v", err)
+	}
+
+	if len(snapshot.Nodes) != len(ids) {
+		t.Fatalf("Unexpected number of nodes.\nexpected: %d\nreceived: %d",
+			len(ids), len(snapshot.Nodes))
+	}
+	if !bytes.Equal(snapshot.Nodes[0].Id, ids[1].Marshal()) ||
+		!bytes.Equal(snapshot.Nodes[1].Id, ids[0].Marshal()) {
+		t.Errorf("Snapshot is not ordered by node ID: %+v", snapshot.Nodes)
+	}
+	entry := snapshot.Nodes[1]
+	expected := NodeSnapshotEntry{Id: ids[0].Marshal(),
+		Status: node.Banned.String(), Activity: current.WAITING.String(),
+		Ordering: "US", Connectivity: node.PortSuccessful,
+		TopologyPosition: -1, ObservedAddress: "9.9.9.9"}
+	if entry.Status != expected.Status || entry.Activity != expected.Activity ||
+		entry.Ordering != expected.Ordering ||
+		entry.Connectivity != expected.Connectivity ||
+		entry.TopologyPosition != expected.TopologyPosition ||
+		entry.ObservedAddress != expected.ObservedAddress ||
+		entry.AddressMismatch != expected.AddressMismatch {
+		t.Errorf("Snapshot does not reflect the node map."+
+			"\nexpected: %+v\nreceived: %+v", expected, entry)
+	}
+	if entry.Exclusions[node.ExcludedConnectivity.String()] != 1 ||
+		len(entry.Exclusions) != int(node.NumExclusionReasons) {
+		t.Errorf("Snapshot does not break down the node's exclusions: %v",
+			entry.Exclusions)
+	}
+
+	// Tampering with the snapshot or verifying under another key fails
+	msg := &pb.NDF{}
+	if err = proto.Unmarshal(signed, msg); err != nil {
+		t.Fatalf("Failed to unmarshal snapshot: %+v", err)
+	}
+	msg.Ndf = bytes.Replace(msg.Ndf, []byte("Banned"), []byte("Active"), 1)
+	tampered, err := proto.Marshal(msg)
+	if err != nil {
+		t.Fatalf("Failed to marshal snapshot: %+v", err)
+	}
+	if _, err = VerifyNodeSnapshot(tampered, privateKey.GetPublic()); err == nil {
+		t.Errorf("Tampered snapshot verified.")
+	}
+	otherKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %+v", err)
+	}
+	if _, err = VerifyNodeSnapshot(signed, otherKey.GetPublic()); err == nil {
+		t.Errorf("Snapshot verified under another key.")
+	}
+}
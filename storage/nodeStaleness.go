@@ -0,0 +1,130 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Handles classifying the liveness of nodes by the time since their last poll
+
+package storage
+
+import (
+	"github.com/pkg/errors"
+	"gitlab.com/xx_network/primitives/id"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Times since their last poll past which nodes are stale and dead when none
+// are configured
+const (
+	DefaultNodeStaleThreshold = time.Minute
+	DefaultNodeDeadThreshold  = 10 * time.Minute
+)
+
+// StalenessClass classifies a node's liveness by the time since its last poll.
+type StalenessClass uint8
+
+const (
+	// Fresh nodes polled within the stale threshold
+	Fresh StalenessClass = iota
+	// Stale nodes last polled at least the stale threshold ago, but within
+	// the dead threshold
+	Stale
+	// Dead nodes last polled at least the dead threshold ago
+	Dead
+)
+
+// String returns the name of the StalenessClass. This functions satisfies the
+// fmt.Stringer interface.
+func (sc StalenessClass) String() string {
+	switch sc {
+	case Fresh:
+		return "Fresh"
+	case Stale:
+		return "Stale"
+	case Dead:
+		return "Dead"
+	default:
+		return "UNKNOWN STALENESS CLASS: " + strconv.Itoa(int(sc))
+	}
+}
+
+// nodeStaleness holds the thresholds nodes are classified by
+type nodeStaleness struct {
+	stale time.Duration
+	dead  time.Duration
+
+	mux sync.RWMutex
+}
+
+// SetNodeStalenessThresholds sets the times since their last poll past which
+// nodes are classified as stale and dead. A threshold of 0 uses
+// DefaultNodeStaleThreshold or DefaultNodeDeadThreshold. Returns an error if
+// the dead threshold is below the stale one.
+func (s *NetworkState) SetNodeStalenessThresholds(stale, dead time.Duration) error {
+	if stale == 0 {
+		stale = DefaultNodeStaleThreshold
+	}
+	if dead == 0 {
+		dead = DefaultNodeDeadThreshold
+	}
+	if stale < 0 || dead < stale {
+		return errors.Errorf("Invalid node staleness thresholds: stale %s "+
+			"must be positive and dead %s must not be below it", stale, dead)
+	}
+
+	ns := &s.nodeStaleness
+	ns.mux.Lock()
+	defer ns.mux.Unlock()
+	ns.stale, ns.dead = stale, dead
+	return nil
+}
+
+// GetNodeStaleness returns the time since the node last polled and the
+// staleness class it falls in. Nodes which have not polled since they were
+// loaded are dead.
+func (s *NetworkState) GetNodeStaleness(nid *id.ID) (time.Duration, StalenessClass, error) {
+	return s.getNodeStaleness(nid, time.Now())
+}
+
+// getNodeStaleness returns the time since the node last polled as of now and
+// the staleness class it falls in.
+func (s *NetworkState) getNodeStaleness(nid *id.ID, now time.Time) (time.Duration, StalenessClass, error) {
+	n := s.GetNodeMap().GetNode(nid)
+	if n == nil {
+		return 0, Dead, errors.Errorf("Node %s is not registered", nid)
+	}
+
+	sincePoll := now.Sub(n.GetLastPoll())
+	if sincePoll < 0 {
+		sincePoll = 0
+	}
+	return sincePoll, s.classifyStaleness(sincePoll), nil
+}
+
+// classifyStaleness returns the staleness class of a node last polled the
+// given time ago.
+func (s *NetworkState) classifyStaleness(sincePoll time.Duration) StalenessClass {
+	ns := &s.nodeStaleness
+	ns.mux.RLock()
+	stale, dead := ns.stale, ns.dead
+	ns.mux.RUnlock()
+	if stale == 0 {
+		stale = DefaultNodeStaleThreshold
+	}
+	if dead == 0 {
+		dead = DefaultNodeDeadThreshold
+	}
+
+	switch {
+	case sincePoll >= dead:
+		return Dead
+	case sincePoll >= stale:
+		return Stale
+	default:
+		return Fresh
+	}
+}
@@ -0,0 +1,96 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package storage
+
+import (
+	"crypto/rand"
+	"gitlab.com/xx_network/crypto/signature/rsa"
+	"gitlab.com/xx_network/primitives/id"
+	"gitlab.com/xx_network/primitives/region"
+	"testing"
+	"time"
+)
+
+// Tests that nodes are classified as fresh, stale or dead by the time since
+// their last poll on either side of each threshold, for both the default and
+// configured thresholds.
+func TestNetworkState_GetNodeStaleness(t *testing.T) {
+	var closeDb func() error
+	var err error
+	PermissioningDb, closeDb, err = NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("Failed to create database: %+v", err)
+	}
+	t.Cleanup(func() { _ = closeDb() })
+
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate private key: %+v", err)
+	}
+	state, err := NewState(privateKey, 8, "", "", region.GetCountryBins())
+	if err != nil {
+		t.Fatalf("Failed to create state: %+v", err)
+	}
+	nid := id.NewIdFromUInt(0, id.Node, t)
+	if err = state.GetNodeMap().AddNode(nid, "US", "", "", 0); err != nil {
+		t.Fatalf("Failed to add node: %+v", err)
+	}
+	n := state.GetNodeMap().GetNode(nid)
+
+	// A node which has not polled since it was loaded is dead
+	if _, class, err := state.GetNodeStaleness(nid); err != nil || class != Dead {
+		t.Errorf("Node which never polled is %s, expected %s: %+v",
+			class, Dead, err)
+	}
+
+	now := time.Now()
+	check := func(stale, dead time.Duration) {
+		expected := []struct {
+			sincePoll time.Duration
+			class     StalenessClass
+		}{
+			{0, Fresh},
+			{stale - time.Nanosecond, Fresh},
+			{stale, Stale},
+			{dead - time.Nanosecond, Stale},
+			{dead, Dead},
+			{10 * dead, Dead},
+		}
+		for _, e := range expected {
+			n.SetLastPoll(now.Add(-e.sincePoll), t)
+			sincePoll, class, err := state.getNodeStaleness(nid, now)
+			if err != nil {
+				t.Fatalf("Failed to get node staleness: %+v", err)
+			}
+			if sincePoll != e.sincePoll || class != e.class {
+				t.Errorf("Node last polled %s ago is %s %s ago, expected %s.",
+					e.sincePoll, class, sincePoll, e.class)
+			}
+		}
+	}
+	check(DefaultNodeStaleThreshold, DefaultNodeDeadThreshold)
+
+	if err = state.SetNodeStalenessThresholds(5*time.Second, 30*time.Second); err != nil {
+		t.Fatalf("Failed to set thresholds: %+v", err)
+	}
+	check(5*time.Second, 30*time.Second)
+
+	// A poll after now, such as from clock skew, is fresh
+	n.SetLastPoll(now.Add(time.Second), t)
+	if sincePoll, class, _ := state.getNodeStaleness(nid, now); sincePoll != 0 ||
+		class != Fresh {
+		t.Errorf("Node polling in the future is %s %s ago.", class, sincePoll)
+	}
+
+	if err = state.SetNodeStalenessThresholds(time.Minute, time.Second); err == nil {
+		t.Errorf("Dead threshold below the stale one was accepted.")
+	}
+	if _, _, err = state.GetNodeStaleness(id.NewIdFromUInt(1, id.Node, t)); err == nil {
+		t.Errorf("Staleness of an unregistered node was returned.")
+	}
+}
@@ -10,10 +10,13 @@
 package storage
 
 import (
+	"bytes"
+	"crypto/sha256"
 	"github.com/jinzhu/gorm"
 	"github.com/pkg/errors"
 	jww "github.com/spf13/jwalterweatherman"
 	"gitlab.com/xx_network/primitives/id"
+	"sort"
 	"time"
 )
 
@@ -59,16 +62,130 @@ func (d *DatabaseImpl) InsertNodeMetric(metric *NodeMetric) error {
 	return d.db.Create(metric).Error
 }
 
+// Most NodeMetrics deleted at once by DeleteNodeMetricsBefore, so that
+// deleting a large backlog does not hold a lock on the table for long
+var nodeMetricDeleteBatchSize = 5000
+
+// Delete the NodeMetrics of monitoring periods which ended before the given
+// time, in batches, and return the number of NodeMetrics deleted
+func (d *DatabaseImpl) DeleteNodeMetricsBefore(cutoff time.Time) (int64, error) {
+	var deleted int64
+	for {
+		var ids []uint64
+		err := d.db.Model(&NodeMetric{}).Where("end_time < ?", cutoff).
+			Order("id").Limit(nodeMetricDeleteBatchSize).Pluck("id", &ids).Error
+		if err != nil {
+			return deleted, err
+		}
+		if len(ids) == 0 {
+			return deleted, nil
+		}
+
+		result := d.db.Where("id IN (?)", ids).Delete(&NodeMetric{})
+		if result.Error != nil {
+			return deleted, result.Error
+		}
+		deleted += result.RowsAffected
+		if len(ids) < nodeMetricDeleteBatchSize {
+			return deleted, nil
+		}
+	}
+}
+
+// Return the fraction of the monitoring periods which ended at or after the
+// given time in which each Node responded to pings, keyed by Node ID. Nodes
+// without NodeMetrics in that time are omitted
+func (d *DatabaseImpl) GetNodeUptimes(since time.Time) (map[id.ID]float64, error) {
+	var periods []struct {
+		NodeId []byte
+		Total  uint64
+		Up     uint64
+	}
+	err := d.db.Model(&NodeMetric{}).
+		Select("node_id, COUNT(*) AS total, "+
+			"SUM(CASE WHEN num_pings > 0 THEN 1 ELSE 0 END) AS up").
+		Where("end_time >= ? AND node_id IS NOT NULL", since).
+		Group("node_id").Scan(&periods).Error
+	if err != nil {
+		return nil, err
+	}
+
+	uptimes := make(map[id.ID]float64, len(periods))
+	for _, p := range periods {
+		nid, err := id.Unmarshal(p.NodeId)
+		if err != nil {
+			return nil, errors.Errorf("Failed to unmarshal node ID of "+
+				"NodeMetric: %+v", err)
+		}
+		uptimes[*nid] = float64(p.Up) / float64(p.Total)
+	}
+	return uptimes, nil
+}
+
 // Insert new RoundError object into Storage
-func (d *DatabaseImpl) InsertRoundError(roundId id.Round, errStr string) error {
+func (d *DatabaseImpl) InsertRoundError(roundId id.Round, errStr, rawErrStr string) error {
 	roundErr := &RoundError{
 		RoundMetricId: uint64(roundId),
 		Error:         errStr,
+		RawError:      rawErrStr,
 	}
 	jww.TRACE.Printf("Attempting to insert RoundError into DB: %+v", roundErr)
 	return d.db.Create(roundErr).Error
 }
 
+// Insert new RoundError object into Storage unless the round already has the
+// given limit of stored errors, in which case it is only counted by the
+// round's aggregate suppression error. The limit is checked by the insert
+// itself, so concurrent inserts for a round cannot exceed it. A limit of 0
+// stores every error.
+func (d *DatabaseImpl) InsertCappedRoundError(roundId id.Round, errStr,
+	rawErrStr string, limit uint) error {
+	if limit == 0 {
+		return d.InsertRoundError(roundId, errStr, rawErrStr)
+	}
+
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		// Serialize the inserts for the round by locking its metric, SQLite
+		// serializes every write transaction already
+		if tx.Dialect().GetName() == postgresDialect {
+			err := tx.Exec("SELECT id FROM round_metrics WHERE id = ? FOR UPDATE",
+				uint64(roundId)).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		jww.TRACE.Printf("Attempting to insert RoundError into DB: %s", errStr)
+		result := tx.Exec("INSERT INTO round_errors "+
+			"(round_metric_id, error, raw_error, suppressed) "+
+			"SELECT CAST(? AS BIGINT), CAST(? AS TEXT), CAST(? AS TEXT), 0 "+
+			"WHERE (SELECT COUNT(*) FROM round_errors "+
+			"WHERE round_metric_id = ? AND suppressed = 0) < ?",
+			uint64(roundId), errStr, rawErrStr, uint64(roundId), limit)
+		if result.Error != nil || result.RowsAffected > 0 {
+			return result.Error
+		}
+
+		jww.TRACE.Printf("Suppressing RoundError for round %d: %s", roundId, errStr)
+		result = tx.Exec("UPDATE round_errors SET suppressed = suppressed + 1, "+
+			"error = CAST(suppressed + 1 AS TEXT) || ?, "+
+			"raw_error = CAST(suppressed + 1 AS TEXT) || ? "+
+			"WHERE round_metric_id = ? AND suppressed > 0",
+			suppressedRoundErrorsSuffix, suppressedRoundErrorsSuffix,
+			uint64(roundId))
+		if result.Error != nil || result.RowsAffected > 0 {
+			return result.Error
+		}
+		marker := "1" + suppressedRoundErrorsSuffix
+		return tx.Create(&RoundError{
+			RoundMetricId: uint64(roundId),
+			Error:         marker,
+			RawError:      marker,
+			Suppressed:    1,
+		}).Error
+	})
+}
+
 // Insert new RoundMetric object with associated topology into Storage
 func (d *DatabaseImpl) InsertRoundMetric(metric *RoundMetric, topology [][]byte) error {
 
@@ -126,6 +243,237 @@ func (d *DatabaseImpl) GetEarliestRound(cutoff time.Duration) (id.Round, time.Ti
 	return roundId, result.RealtimeStart, nil
 }
 
+// Inserts the given ActiveRound into Storage, replacing any stored state for
+// the same round
+func (d *DatabaseImpl) UpsertActiveRound(activeRound *ActiveRound) error {
+	return d.db.Save(activeRound).Error
+}
+
+// Removes the ActiveRound with the given round ID from Storage
+func (d *DatabaseImpl) DeleteActiveRound(roundId id.Round) error {
+	return d.db.Delete(&ActiveRound{Id: uint64(roundId)}).Error
+}
+
+// Returns all ActiveRound from Storage, in order of round ID
+func (d *DatabaseImpl) GetActiveRounds() ([]*ActiveRound, error) {
+	var result []*ActiveRound
+	err := d.db.Order("id ASC").Find(&result).Error
+	return result, err
+}
+
+// Returns the number of rounds ending at or after start and before end that
+// the Node with the given ID was part of the team of
+func (d *DatabaseImpl) GetNodeRoundParticipation(nodeId *id.ID, start, end time.Time) (uint64, error) {
+	var count int64
+	err := d.db.Table("topologies").
+		Joins("JOIN round_metrics ON round_metrics.id = topologies.round_metric_id").
+		Where("topologies.node_id = ? AND round_metrics.round_end >= ? AND "+
+			"round_metrics.round_end < ?", nodeId.Marshal(), start, end).
+		Count(&count).Error
+	return uint64(count), err
+}
+
+// Returns the IDs of the team of the round with the given ID in topology
+// order. Rounds stored without their topology, such as those from before it
+// was recorded, have an empty team.
+func (d *DatabaseImpl) GetRoundTopology(roundId id.Round) ([]*id.ID, error) {
+	var rows []Topology
+	err := d.db.Where("round_metric_id = ?", uint64(roundId)).Find(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].Order < rows[j].Order })
+
+	team := make([]*id.ID, len(rows))
+	for i, row := range rows {
+		nid, err := id.Unmarshal(row.NodeId)
+		if err != nil {
+			return nil, errors.Errorf("Failed to unmarshal member %d of the "+
+				"topology of round %d: %+v", row.Order, roundId, err)
+		}
+		team[i] = nid
+	}
+	return team, nil
+}
+
+// Returns, for each Node that was the slowest of its team to finish a phase
+// of any round ending at or after since, how often and by how much on average
+// it trailed the rest of its team, in order of Node ID
+func (d *DatabaseImpl) GetStragglerStats(since time.Time) ([]*StragglerStats, error) {
+	type stragglerRow struct {
+		NodeId   []byte
+		Count    uint64
+		AvgDelta float64
+	}
+	queryPhase := func(column string) ([]stragglerRow, error) {
+		var rows []stragglerRow
+		err := d.db.Table("round_metrics").
+			Select(column+" AS node_id, COUNT(*) AS count, AVG("+
+				column+"_delta) AS avg_delta").
+			Where("round_end >= ? AND "+column+" IS NOT NULL", since).
+			Group(column).Scan(&rows).Error
+		return rows, err
+	}
+
+	precompRows, err := queryPhase("precomp_straggler")
+	if err != nil {
+		return nil, err
+	}
+	realtimeRows, err := queryPhase("realtime_straggler")
+	if err != nil {
+		return nil, err
+	}
+
+	statsMap := make(map[string]*StragglerStats)
+	getStats := func(nodeId []byte) *StragglerStats {
+		stats, exists := statsMap[string(nodeId)]
+		if !exists {
+			stats = &StragglerStats{NodeId: nodeId}
+			statsMap[string(nodeId)] = stats
+		}
+		return stats
+	}
+	for _, row := range precompRows {
+		stats := getStats(row.NodeId)
+		stats.PrecompCount = row.Count
+		stats.AvgPrecompDelta = time.Duration(row.AvgDelta)
+	}
+	for _, row := range realtimeRows {
+		stats := getStats(row.NodeId)
+		stats.RealtimeCount = row.Count
+		stats.AvgRealtimeDelta = time.Duration(row.AvgDelta)
+	}
+
+	result := make([]*StragglerStats, 0, len(statsMap))
+	for _, stats := range statsMap {
+		result = append(result, stats)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return bytes.Compare(result[i].NodeId, result[j].NodeId) < 0
+	})
+	return result, nil
+}
+
+// Returns the 50th, 95th and 99th percentile of the realtime durations of the
+// rounds whose realtime ended within the window. Rounds without a realtime
+// start are skipped. Returns gorm.ErrRecordNotFound if no rounds are in the
+// window; with few rounds, the higher percentiles are the slowest round.
+func (d *DatabaseImpl) GetRoundLatencyPercentiles(window time.Duration) (
+	p50, p95, p99 time.Duration, err error) {
+	var rows []RoundMetric
+	err = d.db.Select("realtime_start, realtime_end").
+		Where("realtime_end >= ? AND realtime_start > ?",
+			time.Now().Add(-window), time.Unix(0, 0)).
+		Find(&rows).Error
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	durations := make([]time.Duration, 0, len(rows))
+	for _, row := range rows {
+		if row.RealtimeEnd.Before(row.RealtimeStart) {
+			continue
+		}
+		durations = append(durations, row.RealtimeEnd.Sub(row.RealtimeStart))
+	}
+	if len(durations) == 0 {
+		return 0, 0, 0, gorm.ErrRecordNotFound
+	}
+	sort.Slice(durations, func(i, j int) bool {
+		return durations[i] < durations[j]
+	})
+	return durationPercentile(durations, 50), durationPercentile(durations, 95),
+		durationPercentile(durations, 99), nil
+}
+
+// durationPercentile returns the pth percentile of the sorted durations by the
+// nearest rank method, so that it is always one of the durations.
+func durationPercentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// Insert an NDF snapshot into Storage
+func (d *DatabaseImpl) InsertNdfSnapshot(snapshot *NdfSnapshot) error {
+	return d.db.Create(snapshot).Error
+}
+
+// Returns the last NDF snapshot taken at or before the given time
+func (d *DatabaseImpl) GetNdfSnapshotAt(t time.Time) (*NdfSnapshot, error) {
+	snapshot := &NdfSnapshot{}
+	err := d.db.Where("timestamp <= ?", t).Order("timestamp desc, id desc").
+		First(snapshot).Error
+	return snapshot, err
+}
+
+// Returns the last NDF snapshot taken with the given hash
+func (d *DatabaseImpl) GetNdfSnapshotByHash(hash []byte) (*NdfSnapshot, error) {
+	snapshot := &NdfSnapshot{}
+	err := d.db.Where("hash = ?", hash).Order("timestamp desc, id desc").
+		First(snapshot).Error
+	return snapshot, err
+}
+
+// Deletes the NDF snapshots taken before the given time, except for the last
+// of them, which was still published at that time
+func (d *DatabaseImpl) DeleteNdfSnapshotsBefore(cutoff time.Time) error {
+	last := &NdfSnapshot{}
+	err := d.db.Where("timestamp < ?", cutoff).Order("timestamp desc, id desc").
+		First(last).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	return d.db.Where("timestamp < ? AND id <> ?", cutoff, last.Id).
+		Delete(&NdfSnapshot{}).Error
+}
+
+// Inserts the scheduling config into Storage unless an identical one is
+// already stored, returning the ID of the stored config
+func (d *DatabaseImpl) InsertSchedulingConfig(config []byte) (uint64, error) {
+	hash := sha256.Sum256(config)
+	stored := &SchedulingConfig{}
+	err := d.db.Where("hash = ?", hash[:]).Take(stored).Error
+	if err == nil {
+		return stored.Id, nil
+	} else if !gorm.IsRecordNotFoundError(err) {
+		return 0, err
+	}
+
+	stored = &SchedulingConfig{Hash: hash[:], Config: string(config)}
+	if err = d.db.Create(stored).Error; err != nil {
+		// An identical config may have been inserted in the meantime
+		existing := &SchedulingConfig{}
+		if d.db.Where("hash = ?", hash[:]).Take(existing).Error == nil {
+			return existing.Id, nil
+		}
+		return 0, err
+	}
+	return stored.Id, nil
+}
+
+// Returns the scheduling config the round with the given ID was formed with.
+// Rounds stored without one, such as those from before it was recorded, have
+// a nil config.
+func (d *DatabaseImpl) GetRoundSchedulingConfig(roundId id.Round) (*SchedulingConfig, error) {
+	metric := &RoundMetric{}
+	err := d.db.Select("id, scheduling_config_id").
+		Where("id = ?", uint64(roundId)).Take(metric).Error
+	if err != nil {
+		return nil, err
+	} else if metric.SchedulingConfigId == nil {
+		return nil, nil
+	}
+
+	config := &SchedulingConfig{}
+	err = d.db.Where("id = ?", *metric.SchedulingConfigId).Take(config).Error
+	return config, err
+}
+
 // Returns all GeoBin from Storage
 func (d *DatabaseImpl) getBins() ([]*GeoBin, error) {
 	var result []*GeoBin
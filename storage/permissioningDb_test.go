@@ -12,7 +12,9 @@ import (
 	"fmt"
 	"github.com/jinzhu/gorm"
 	"gitlab.com/xx_network/primitives/id"
+	"reflect"
 	"strconv"
+	"sync"
 	"testing"
 	"time"
 )
@@ -231,6 +233,127 @@ func TestDatabaseImpl_InsertNodeMetric(t *testing.T) {
 	}
 }
 
+// Tests that only the NodeMetrics which ended before the cutoff are deleted
+// when there are more of them than fit in a batch, including an exact
+// multiple of the batch size.
+func TestDatabaseImpl_DeleteNodeMetricsBefore(t *testing.T) {
+	d, dc, err := NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = dc() })
+	db := d.GetDatabaseImpl(t)
+
+	defer func(size int) { nodeMetricDeleteBatchSize = size }(nodeMetricDeleteBatchSize)
+	nodeMetricDeleteBatchSize = 5
+
+	testId := id.NewIdFromString("TEST", id.Node, t)
+	err = d.InsertApplication(&Application{Id: 10},
+		&Node{Code: "TEST", Id: testId.Marshal(), ApplicationId: 10})
+	if err != nil {
+		t.Fatalf("Failed to insert node: %+v", err)
+	}
+	now := time.Now()
+	insert := func(num int, end time.Time) {
+		for i := 0; i < num; i++ {
+			err := d.InsertNodeMetric(&NodeMetric{NodeId: testId.Marshal(),
+				StartTime: end.Add(-time.Minute), EndTime: end, NumPings: 1})
+			if err != nil {
+				t.Fatalf("Failed to insert node metric: %+v", err)
+			}
+		}
+	}
+	insert(10, now.Add(-48*time.Hour))
+	insert(13, now.Add(-25*time.Hour))
+	insert(4, now.Add(-time.Hour))
+
+	deleted, err := d.DeleteNodeMetricsBefore(now.Add(-24 * time.Hour))
+	if err != nil {
+		t.Fatalf("DeleteNodeMetricsBefore() returned an error: %+v", err)
+	}
+	if deleted != 23 {
+		t.Errorf("Deleted %d node metrics, expected %d.", deleted, 23)
+	}
+	var remaining []*NodeMetric
+	if err = db.db.Find(&remaining).Error; err != nil {
+		t.Fatalf("Failed to get node metrics: %+v", err)
+	}
+	if len(remaining) != 4 {
+		t.Errorf("%d node metrics remain, expected %d.", len(remaining), 4)
+	}
+	for _, metric := range remaining {
+		if metric.EndTime.Before(now.Add(-24 * time.Hour)) {
+			t.Errorf("Node metric ending at %s was not deleted.", metric.EndTime)
+		}
+	}
+
+	insert(10, now.Add(-25*time.Hour))
+	if deleted, err = d.DeleteNodeMetricsBefore(now.Add(-24 * time.Hour)); err != nil {
+		t.Fatalf("DeleteNodeMetricsBefore() returned an error: %+v", err)
+	}
+	if deleted != 10 {
+		t.Errorf("Deleted %d node metrics, expected %d.", deleted, 10)
+	}
+	if deleted, err = d.DeleteNodeMetricsBefore(now.Add(-24 * time.Hour)); err != nil || deleted != 0 {
+		t.Errorf("Deleted %d node metrics with none past the cutoff: %+v",
+			deleted, err)
+	}
+}
+
+// Tests that GetNodeUptimes returns the fraction of each Node's monitoring
+// periods since the given time in which it responded to pings.
+func TestDatabaseImpl_GetNodeUptimes(t *testing.T) {
+	d, dc, err := NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = dc() })
+
+	now := time.Now()
+	nodes := []*id.ID{id.NewIdFromUInt(1, id.Node, t),
+		id.NewIdFromUInt(2, id.Node, t), id.NewIdFromUInt(3, id.Node, t)}
+	pings := [][]uint64{
+		{5, 3, 0, 8},
+		{0, 0, 1, 0},
+		{},
+	}
+	for i, nid := range nodes {
+		err = d.InsertApplication(&Application{Id: uint64(i + 1)},
+			&Node{Code: nid.String(), Id: nid.Marshal(),
+				ApplicationId: uint64(i + 1)})
+		if err != nil {
+			t.Fatalf("Failed to insert node: %+v", err)
+		}
+
+		// Periods before the window are not counted
+		err = d.InsertNodeMetric(&NodeMetric{NodeId: nid.Marshal(),
+			StartTime: now.Add(-49 * time.Hour),
+			EndTime:   now.Add(-48 * time.Hour)})
+		if err != nil {
+			t.Fatalf("Failed to insert node metric: %+v", err)
+		}
+		for j, numPings := range pings[i] {
+			end := now.Add(-time.Duration(j) * time.Hour)
+			err = d.InsertNodeMetric(&NodeMetric{NodeId: nid.Marshal(),
+				StartTime: end.Add(-time.Hour), EndTime: end,
+				NumPings: numPings})
+			if err != nil {
+				t.Fatalf("Failed to insert node metric: %+v", err)
+			}
+		}
+	}
+
+	uptimes, err := d.GetNodeUptimes(now.Add(-24 * time.Hour))
+	if err != nil {
+		t.Fatalf("GetNodeUptimes() returned an error: %+v", err)
+	}
+	expected := map[id.ID]float64{*nodes[0]: 0.75, *nodes[1]: 0.25}
+	if !reflect.DeepEqual(uptimes, expected) {
+		t.Errorf("Unexpected node uptimes.\nexpected: %v\nreceived: %v",
+			expected, uptimes)
+	}
+}
+
 // Happy path
 func TestDatabaseImpl_InsertRoundMetric(t *testing.T) {
 	d, dc, err := NewDatabase("", "", "TestDatabaseImpl_InsertRoundMetric", "", "")
@@ -333,18 +456,19 @@ func TestDatabaseImpl_InsertRoundError(t *testing.T) {
 		}
 	}
 	newErrors := []string{"err1", "err2", "err3"}
+	rawErrors := []string{"raw1", "raw2", "raw3"}
 
 	err = d.InsertRoundMetric(newMetric, newTopology)
 	if err != nil {
 		t.Errorf("Unable to insert round metric: %+v", err)
 	}
 
-	err = d.InsertRoundError(roundId, newErrors[0])
+	err = d.InsertRoundError(roundId, newErrors[0], rawErrors[0])
 	if err != nil {
 		t.Errorf("Unable to insert round error: %+v", err)
 	}
 
-	err = d.InsertRoundError(roundId, newErrors[1])
+	err = d.InsertRoundError(roundId, newErrors[1], rawErrors[1])
 	if err != nil {
 		t.Errorf("Unable to insert round error: %+v", err)
 	}
@@ -361,6 +485,173 @@ func TestDatabaseImpl_InsertRoundError(t *testing.T) {
 	if insertedMetric.RoundErrors[1].Error != newErrors[1] {
 		t.Errorf("Mismatched Error returned!")
 	}
+	if insertedMetric.RoundErrors[0].RawError != rawErrors[0] {
+		t.Errorf("Mismatched RawError returned!")
+	}
+	if insertedMetric.RoundErrors[1].RawError != rawErrors[1] {
+		t.Errorf("Mismatched RawError returned!")
+	}
+}
+
+// Tests that InsertCappedRoundError stores no more than the limit of errors
+// for a round and counts the rest in a single suppression error.
+func TestDatabaseImpl_InsertCappedRoundError(t *testing.T) {
+	d, dc, err := NewDatabase("", "", "TestDatabaseImpl_InsertCappedRoundError", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := dc()
+		if err != nil {
+			t.Errorf("Failed to close database: %+v", err)
+		}
+	}()
+	db := d.GetDatabaseImpl(t)
+
+	roundId := id.Round(1)
+	now := time.Now()
+	err = d.InsertRoundMetric(&RoundMetric{Id: uint64(roundId),
+		PrecompStart: now, PrecompEnd: now, RealtimeStart: now,
+		RealtimeEnd: now, RoundEnd: now}, nil)
+	if err != nil {
+		t.Fatalf("Unable to insert round metric: %+v", err)
+	}
+
+	for i := 0; i < 5; i++ {
+		err = d.InsertCappedRoundError(roundId, fmt.Sprintf("err%d", i),
+			fmt.Sprintf("raw%d", i), 2)
+		if err != nil {
+			t.Fatalf("Unable to insert round error %d: %+v", i, err)
+		}
+	}
+
+	var roundErrors []RoundError
+	err = db.db.Order("id ASC").Find(&roundErrors, "round_metric_id = ?", roundId).Error
+	if err != nil {
+		t.Fatalf("Failed to get round errors: %+v", err)
+	}
+	if len(roundErrors) != 3 {
+		t.Fatalf("Expected 2 errors and a suppression error, received %d: %+v",
+			len(roundErrors), roundErrors)
+	}
+	for i, roundErr := range roundErrors[:2] {
+		if roundErr.Error != fmt.Sprintf("err%d", i) || roundErr.Suppressed != 0 {
+			t.Errorf("Unexpected stored error %d: %+v", i, roundErr)
+		}
+	}
+	marker := roundErrors[2]
+	if marker.Suppressed != 3 || marker.Error != "3 additional errors suppressed" {
+		t.Errorf("Unexpected suppression error: %+v", marker)
+	}
+}
+
+// Tests that InsertCappedRoundError with a limit of 0 stores every error for a
+// round and suppresses none.
+func TestDatabaseImpl_InsertCappedRoundError_NoLimit(t *testing.T) {
+	d, dc, err := NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := dc()
+		if err != nil {
+			t.Errorf("Failed to close database: %+v", err)
+		}
+	}()
+	db := d.GetDatabaseImpl(t)
+
+	roundId := id.Round(1)
+	now := time.Now()
+	err = d.InsertRoundMetric(&RoundMetric{Id: uint64(roundId),
+		PrecompStart: now, PrecompEnd: now, RealtimeStart: now,
+		RealtimeEnd: now, RoundEnd: now}, nil)
+	if err != nil {
+		t.Fatalf("Unable to insert round metric: %+v", err)
+	}
+
+	for i := 0; i < 5; i++ {
+		err = d.InsertCappedRoundError(roundId, fmt.Sprintf("err%d", i),
+			fmt.Sprintf("raw%d", i), 0)
+		if err != nil {
+			t.Fatalf("Unable to insert round error %d: %+v", i, err)
+		}
+	}
+
+	var roundErrors []RoundError
+	err = db.db.Order("id ASC").Find(&roundErrors, "round_metric_id = ?", roundId).Error
+	if err != nil {
+		t.Fatalf("Failed to get round errors: %+v", err)
+	}
+	if len(roundErrors) != 5 {
+		t.Fatalf("Expected 5 errors, received %d: %+v",
+			len(roundErrors), roundErrors)
+	}
+	for i, roundErr := range roundErrors {
+		if roundErr.Error != fmt.Sprintf("err%d", i) || roundErr.Suppressed != 0 {
+			t.Errorf("Unexpected stored error %d: %+v", i, roundErr)
+		}
+	}
+}
+
+// Tests that concurrent inserts of errors for a round never store more than
+// the limit, and that every error beyond it is counted by the suppression
+// error.
+func TestDatabaseImpl_InsertCappedRoundError_Concurrent(t *testing.T) {
+	d, dc, err := NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := dc()
+		if err != nil {
+			t.Errorf("Failed to close database: %+v", err)
+		}
+	}()
+	db := d.GetDatabaseImpl(t)
+	d.SetRoundErrorLimit(3)
+	d.SetMetricRetry(50, time.Millisecond)
+
+	roundId := id.Round(1)
+	now := time.Now()
+	err = d.InsertRoundMetric(&RoundMetric{Id: uint64(roundId),
+		PrecompStart: now, PrecompEnd: now, RealtimeStart: now,
+		RealtimeEnd: now, RoundEnd: now}, nil)
+	if err != nil {
+		t.Fatalf("Unable to insert round metric: %+v", err)
+	}
+
+	const numErrors = 20
+	var wg sync.WaitGroup
+	for i := 0; i < numErrors; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			err := d.InsertRoundErrorWithRetry(roundId, fmt.Sprintf("err%d", i),
+				fmt.Sprintf("raw%d", i))
+			if err != nil {
+				t.Errorf("Unable to insert round error %d: %+v", i, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	var roundErrors []RoundError
+	err = db.db.Find(&roundErrors, "round_metric_id = ?", roundId).Error
+	if err != nil {
+		t.Fatalf("Failed to get round errors: %+v", err)
+	}
+	stored, suppressed := 0, uint64(0)
+	for _, roundErr := range roundErrors {
+		if roundErr.Suppressed == 0 {
+			stored++
+		} else {
+			suppressed += roundErr.Suppressed
+		}
+	}
+	if stored != 3 || suppressed != numErrors-3 {
+		t.Errorf("Expected 3 stored and %d suppressed errors, received %d "+
+			"and %d: %+v", numErrors-3, stored, suppressed, roundErrors)
+	}
 }
 
 // Happy path
@@ -695,3 +986,359 @@ func TestDatabaseImpl_GetBin(t *testing.T) {
 	}
 
 }
+
+// Happy path: tests that active rounds can be stored, replaced, retrieved in
+// order, and deleted.
+func TestDatabaseImpl_ActiveRounds(t *testing.T) {
+	d, dc, err := NewDatabase("", "", "TestDatabaseImpl_ActiveRounds", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := dc()
+		if err != nil {
+			t.Errorf("Failed to close database: %+v", err)
+		}
+	}()
+
+	for _, roundId := range []uint64{7, 3} {
+		err = d.UpsertActiveRound(&ActiveRound{Id: roundId, RoundInfo: []byte("old")})
+		if err != nil {
+			t.Fatalf("Failed to insert active round: %+v", err)
+		}
+	}
+	// Replace the stored state of a round
+	err = d.UpsertActiveRound(&ActiveRound{Id: 7, RoundInfo: []byte("new")})
+	if err != nil {
+		t.Fatalf("Failed to replace active round: %+v", err)
+	}
+
+	activeRounds, err := d.GetActiveRounds()
+	if err != nil {
+		t.Fatalf("Failed to get active rounds: %+v", err)
+	}
+	if len(activeRounds) != 2 || activeRounds[0].Id != 3 ||
+		activeRounds[1].Id != 7 || string(activeRounds[1].RoundInfo) != "new" {
+		t.Errorf("Unexpected active rounds: %+v", activeRounds)
+	}
+
+	err = d.DeleteActiveRound(3)
+	if err != nil {
+		t.Fatalf("Failed to delete active round: %+v", err)
+	}
+	activeRounds, err = d.GetActiveRounds()
+	if err != nil {
+		t.Fatalf("Failed to get active rounds: %+v", err)
+	}
+	if len(activeRounds) != 1 || activeRounds[0].Id != 7 {
+		t.Errorf("Active round not deleted: %+v", activeRounds)
+	}
+}
+
+// Happy path: tests that GetStragglerStats aggregates stragglers per node for
+// rounds ending after the cutoff.
+func TestDatabaseImpl_GetStragglerStats(t *testing.T) {
+	d, dc, err := NewDatabase("", "", "TestDatabaseImpl_GetStragglerStats", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := dc()
+		if err != nil {
+			t.Errorf("Failed to close database: %+v", err)
+		}
+	}()
+
+	nodeA := id.NewIdFromUInt(1, id.Node, t).Marshal()
+	nodeB := id.NewIdFromUInt(2, id.Node, t).Marshal()
+	now := time.Now()
+	metrics := []*RoundMetric{
+		{Id: 1, RoundEnd: now, PrecompStraggler: nodeA,
+			PrecompStragglerDelta: time.Second, RealtimeStraggler: nodeB,
+			RealtimeStragglerDelta: 4 * time.Second},
+		{Id: 2, RoundEnd: now, PrecompStraggler: nodeA,
+			PrecompStragglerDelta: 3 * time.Second},
+		// Round which ended before the cutoff
+		{Id: 3, RoundEnd: now.Add(-time.Hour), PrecompStraggler: nodeB,
+			PrecompStragglerDelta: time.Second},
+	}
+	for _, metric := range metrics {
+		if err = d.InsertRoundMetric(metric, nil); err != nil {
+			t.Fatalf("Failed to insert round metric: %+v", err)
+		}
+	}
+
+	stats, err := d.GetStragglerStats(now.Add(-time.Minute))
+	if err != nil {
+		t.Fatalf("GetStragglerStats() returned an error: %+v", err)
+	}
+
+	expected := []*StragglerStats{
+		{NodeId: nodeA, PrecompCount: 2, AvgPrecompDelta: 2 * time.Second},
+		{NodeId: nodeB, RealtimeCount: 1, AvgRealtimeDelta: 4 * time.Second},
+	}
+	if !reflect.DeepEqual(stats, expected) {
+		t.Errorf("Unexpected straggler stats.\nexpected: %+v\nreceived: %+v",
+			expected, stats)
+	}
+}
+
+// Happy path: tests that GetNodeRoundParticipation counts only the rounds the
+// node was part of the team of which ended within the window.
+func TestDatabaseImpl_GetNodeRoundParticipation(t *testing.T) {
+	d, dc, err := NewDatabase("", "", "TestDatabaseImpl_GetNodeRoundParticipation", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := dc()
+		if err != nil {
+			t.Errorf("Failed to close database: %+v", err)
+		}
+	}()
+
+	nodes := make([]*id.ID, 3)
+	for i := range nodes {
+		nodes[i] = id.NewIdFromUInt(uint64(i+1), id.Node, t)
+		err = d.InsertApplication(&Application{Id: uint64(i + 1)},
+			&Node{Code: fmt.Sprintf("TEST%d", i), Id: nodes[i].Marshal()})
+		if err != nil {
+			t.Fatalf("Failed to insert node for test: %+v", err)
+		}
+	}
+
+	now := time.Now()
+	start, end := now.Add(-time.Hour), now.Add(time.Minute)
+	rounds := []struct {
+		roundEnd time.Time
+		team     []*id.ID
+	}{
+		{now, []*id.ID{nodes[0], nodes[1]}},
+		{now.Add(-time.Minute), []*id.ID{nodes[1], nodes[0]}},
+		{now, []*id.ID{nodes[1], nodes[2]}},
+		// Rounds ending before and after the window
+		{now.Add(-2 * time.Hour), []*id.ID{nodes[0], nodes[2]}},
+		{end, []*id.ID{nodes[0], nodes[2]}},
+	}
+	for i, r := range rounds {
+		topology := make([][]byte, len(r.team))
+		for j, nid := range r.team {
+			topology[j] = nid.Marshal()
+		}
+		err = d.InsertRoundMetric(&RoundMetric{Id: uint64(i + 1),
+			RoundEnd: r.roundEnd}, topology)
+		if err != nil {
+			t.Fatalf("Failed to insert round metric: %+v", err)
+		}
+	}
+
+	expected := []uint64{2, 3, 1}
+	for i, nid := range nodes {
+		count, err := d.GetNodeRoundParticipation(nid, start, end)
+		if err != nil {
+			t.Fatalf("GetNodeRoundParticipation() returned an error for "+
+				"node %s: %+v", nid, err)
+		}
+		if count != expected[i] {
+			t.Errorf("Unexpected participation count for node %s."+
+				"\nexpected: %d\nreceived: %d", nid, expected[i], count)
+		}
+	}
+
+	// Node that was never part of a round
+	count, err := d.GetNodeRoundParticipation(
+		id.NewIdFromUInt(4, id.Node, t), start, end)
+	if err != nil || count != 0 {
+		t.Errorf("Unexpected participation of unknown node: %d, %+v",
+			count, err)
+	}
+}
+
+// Tests that the realtime latency percentiles are computed over the rounds in
+// the window, skipping rounds without a realtime start, and that small samples
+// are handled.
+func TestDatabaseImpl_GetRoundLatencyPercentiles(t *testing.T) {
+	d, dc, err := NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = dc() }()
+
+	_, _, _, err = d.GetRoundLatencyPercentiles(time.Hour)
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("Expected record not found err without rounds, got %+v", err)
+	}
+
+	now := time.Now()
+	insert := func(roundId uint64, start, end time.Time) {
+		err := d.InsertRoundMetric(&RoundMetric{Id: roundId,
+			PrecompStart: start, PrecompEnd: start, RealtimeStart: start,
+			RealtimeEnd: end, RoundEnd: end}, nil)
+		if err != nil {
+			t.Fatalf("Failed to insert round metric %d: %+v", roundId, err)
+		}
+	}
+
+	// A single round is every percentile
+	insert(1, now.Add(-3*time.Second), now)
+	p50, p95, p99, err := d.GetRoundLatencyPercentiles(time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to get percentiles: %+v", err)
+	}
+	if p50 != 3*time.Second || p95 != 3*time.Second || p99 != 3*time.Second {
+		t.Errorf("Unexpected percentiles of a single round: %s, %s, %s",
+			p50, p95, p99)
+	}
+
+	// Rounds 2 through 100 take 1 through 99 ms; the round outside the window
+	// and the round without a realtime start are not counted
+	for i := uint64(2); i <= 100; i++ {
+		insert(i, now.Add(-time.Duration(i-1)*time.Millisecond), now)
+	}
+	insert(101, now.Add(-3*time.Hour), now.Add(-2*time.Hour))
+	insert(102, time.Unix(0, 0), now)
+	p50, p95, p99, err = d.GetRoundLatencyPercentiles(time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to get percentiles: %+v", err)
+	}
+	if p50 != 50*time.Millisecond || p95 != 95*time.Millisecond ||
+		p99 != 99*time.Millisecond {
+		t.Errorf("Unexpected percentiles: %s, %s, %s", p50, p95, p99)
+	}
+}
+
+// Tests the nearest rank percentiles of small samples.
+func TestDurationPercentile(t *testing.T) {
+	sorted := []time.Duration{1, 2, 3}
+	tests := map[int]time.Duration{0: 1, 33: 1, 34: 2, 50: 2, 95: 3, 99: 3, 100: 3}
+	for p, expected := range tests {
+		if d := durationPercentile(sorted, p); d != expected {
+			t.Errorf("Percentile %d is %d instead of %d.", p, d, expected)
+		}
+	}
+}
+
+// Tests that GetRoundTopology returns the team in topology order whatever
+// order it was stored in, and an empty team for rounds without a topology.
+func TestDatabaseImpl_GetRoundTopology(t *testing.T) {
+	d, dc, err := NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := dc(); err != nil {
+			t.Errorf("Failed to close database: %+v", err)
+		}
+	}()
+	db := d.GetDatabaseImpl(t)
+
+	nodes := make([]*id.ID, 5)
+	for i := range nodes {
+		nodes[i] = id.NewIdFromUInt(uint64(i+1), id.Node, t)
+		err = d.InsertApplication(&Application{Id: uint64(i + 1)},
+			&Node{Code: fmt.Sprintf("TEST%d", i), Id: nodes[i].Marshal()})
+		if err != nil {
+			t.Fatalf("Failed to insert node for test: %+v", err)
+		}
+	}
+
+	// The first round's team is stored in the order given, the second one's
+	// out of order
+	now := time.Now()
+	topology := make([][]byte, len(nodes))
+	for i, nid := range nodes {
+		topology[i] = nid.Marshal()
+	}
+	if err = d.InsertRoundMetric(&RoundMetric{Id: 1, RoundEnd: now}, topology); err != nil {
+		t.Fatalf("Failed to insert round metric: %+v", err)
+	}
+	if err = d.InsertRoundMetric(&RoundMetric{Id: 2, RoundEnd: now}, nil); err != nil {
+		t.Fatalf("Failed to insert round metric: %+v", err)
+	}
+	for _, order := range []uint8{3, 0, 4, 2, 1} {
+		err = db.db.Create(&Topology{NodeId: nodes[order].Marshal(),
+			RoundMetricId: 2, Order: order}).Error
+		if err != nil {
+			t.Fatalf("Failed to insert topology: %+v", err)
+		}
+	}
+	if err = d.InsertRoundMetric(&RoundMetric{Id: 3, RoundEnd: now}, nil); err != nil {
+		t.Fatalf("Failed to insert round metric: %+v", err)
+	}
+
+	for _, roundId := range []id.Round{1, 2} {
+		team, err := d.GetRoundTopology(roundId)
+		if err != nil {
+			t.Fatalf("GetRoundTopology() returned an error for round %d: %+v",
+				roundId, err)
+		}
+		if !reflect.DeepEqual(team, nodes) {
+			t.Errorf("Unexpected topology of round %d."+
+				"\nexpected: %v\nreceived: %v", roundId, nodes, team)
+		}
+	}
+
+	// Rounds stored without a topology and unknown rounds
+	for _, roundId := range []id.Round{3, 4} {
+		team, err := d.GetRoundTopology(roundId)
+		if err != nil || len(team) != 0 {
+			t.Errorf("Unexpected topology of round %d: %v, %+v", roundId,
+				team, err)
+		}
+	}
+}
+
+// Tests that identical scheduling configs are stored once, and that
+// GetRoundSchedulingConfig returns the config each round references, a nil
+// config for rounds referencing none and an error for unknown rounds.
+func TestDatabaseImpl_GetRoundSchedulingConfig(t *testing.T) {
+	d, dc, err := NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := dc(); err != nil {
+			t.Errorf("Failed to close database: %+v", err)
+		}
+	}()
+
+	configs := []string{`{"teamSize":3}`, `{"teamSize":5}`, `{"teamSize":3}`}
+	configIds := make([]uint64, len(configs))
+	for i, config := range configs {
+		configIds[i], err = d.InsertSchedulingConfig([]byte(config))
+		if err != nil {
+			t.Fatalf("Failed to insert scheduling config: %+v", err)
+		}
+		err = d.InsertRoundMetric(&RoundMetric{Id: uint64(i + 1),
+			RoundEnd: time.Now(), SchedulingConfigId: &configIds[i]}, nil)
+		if err != nil {
+			t.Fatalf("Failed to insert round metric: %+v", err)
+		}
+	}
+	if configIds[0] != configIds[2] || configIds[0] == configIds[1] {
+		t.Errorf("Configs are not stored once each: %v", configIds)
+	}
+
+	for i, expected := range configs {
+		config, err := d.GetRoundSchedulingConfig(id.Round(i + 1))
+		if err != nil {
+			t.Fatalf("GetRoundSchedulingConfig() returned an error for "+
+				"round %d: %+v", i+1, err)
+		}
+		if config == nil || config.Id != configIds[i] || config.Config != expected {
+			t.Errorf("Unexpected scheduling config of round %d: %+v", i+1,
+				config)
+		}
+	}
+
+	if err = d.InsertRoundMetric(&RoundMetric{Id: 4, RoundEnd: time.Now()}, nil); err != nil {
+		t.Fatalf("Failed to insert round metric: %+v", err)
+	}
+	if config, err := d.GetRoundSchedulingConfig(4); err != nil || config != nil {
+		t.Errorf("Unexpected scheduling config of round without one: %+v, %+v",
+			config, err)
+	}
+	if _, err = d.GetRoundSchedulingConfig(5); err == nil {
+		t.Errorf("No error getting the scheduling config of an unknown round.")
+	}
+}
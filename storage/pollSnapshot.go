@@ -0,0 +1,123 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Handles the snapshot of the NDFs and newest round updates polls are served
+// from without taking any locks
+
+package storage
+
+import (
+	pb "gitlab.com/elixxir/comms/mixmessages"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Number of the newest round updates kept in the poll snapshot. Polls asking
+// for older updates are served from the update buffer
+const pollSnapshotUpdates = 256
+
+// PollSnapshot is an immutable view of the NDFs and newest round updates served
+// to polls. It is replaced as a whole whenever either changes, so it can be
+// read without locking. Nothing in it may be modified.
+type PollSnapshot struct {
+	FullNdf        *pb.NDF
+	FullNdfHash    []byte
+	PartialNdf     *pb.NDF
+	PartialNdfHash []byte
+
+	// Hash of the full NDF published before the current one, when the
+	// current one was published and the kinds of change it made to it, so
+	// that the delivery of changes may be spread. Unset until an NDF is
+	// published
+	PreviousFullNdfHash []byte
+	FullNdfPublished    time.Time
+	FullNdfChangeKinds  []string
+
+	// Newest round updates, in update ID order. They are every update in the
+	// buffer after updatesAfter
+	updates      []*pb.RoundInfo
+	updatesAfter int
+	lastUpdateID int
+}
+
+// pollSnapshot publishes the current PollSnapshot
+type pollSnapshot struct {
+	value atomic.Value
+
+	// Change the current full NDF made, carried into each snapshot
+	previousFullNdfHash []byte
+	fullNdfPublished    time.Time
+	fullNdfChangeKinds  []string
+
+	// Serializes refreshes so a newer snapshot is never replaced by an
+	// older one
+	mux sync.Mutex
+}
+
+// GetUpdates returns the round updates in the snapshot after the given update
+// ID. Returns false if it asks for updates older than the snapshot holds. The
+// returned updates must not be modified.
+func (ps *PollSnapshot) GetUpdates(id int) ([]*pb.RoundInfo, bool) {
+	if id < ps.updatesAfter {
+		return nil, false
+	}
+	i := sort.Search(len(ps.updates), func(i int) bool {
+		return int(ps.updates[i].UpdateID) > id
+	})
+	// Cap the slice so appending to it cannot write into the snapshot
+	return ps.updates[i:len(ps.updates):len(ps.updates)], true
+}
+
+// GetLastUpdateID returns the ID of the newest round update in the snapshot.
+func (ps *PollSnapshot) GetLastUpdateID() int {
+	return ps.lastUpdateID
+}
+
+// GetPollSnapshot returns the current snapshot of the NDFs and newest round
+// updates.
+func (s *NetworkState) GetPollSnapshot() *PollSnapshot {
+	return s.pollSnapshot.value.Load().(*PollSnapshot)
+}
+
+// refreshPollSnapshot publishes a new snapshot of the current NDFs and newest
+// round updates. It must be called after every change to either.
+func (s *NetworkState) refreshPollSnapshot() {
+	ps := &s.pollSnapshot
+	ps.mux.Lock()
+	defer ps.mux.Unlock()
+
+	newest := s.roundUpdates.GetLastUpdateID()
+	after := newest - pollSnapshotUpdates
+	ps.value.Store(&PollSnapshot{
+		FullNdf:        s.fullNdf.GetPb(),
+		FullNdfHash:    s.fullNdf.GetHash(),
+		PartialNdf:     s.partialNdf.GetPb(),
+		PartialNdfHash: s.partialNdf.GetHash(),
+
+		PreviousFullNdfHash: ps.previousFullNdfHash,
+		FullNdfPublished:    ps.fullNdfPublished,
+		FullNdfChangeKinds:  ps.fullNdfChangeKinds,
+
+		updates:      s.roundUpdates.GetUpdates(after),
+		updatesAfter: after,
+		lastUpdateID: newest,
+	})
+}
+
+// setPollSnapshotNdfChange sets the change the newly published full NDF made
+// to the one published before it, to be carried into the snapshots of it.
+func (s *NetworkState) setPollSnapshotNdfChange(previousHash []byte,
+	change *NdfChange) {
+	ps := &s.pollSnapshot
+	ps.mux.Lock()
+	defer ps.mux.Unlock()
+	ps.previousFullNdfHash = previousHash
+	ps.fullNdfPublished = change.Published
+	ps.fullNdfChangeKinds = change.Kinds()
+}
@@ -0,0 +1,322 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package storage
+
+import (
+	"bytes"
+	"crypto/rand"
+	pb "gitlab.com/elixxir/comms/mixmessages"
+	"gitlab.com/elixxir/comms/network/dataStructures"
+	"gitlab.com/elixxir/primitives/states"
+	"gitlab.com/xx_network/crypto/signature/rsa"
+	"gitlab.com/xx_network/primitives/id"
+	"gitlab.com/xx_network/primitives/ndf"
+	"gitlab.com/xx_network/primitives/region"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Number of concurrent pollers the poll benchmarks run
+const benchmarkPollers = 64
+
+// newPollSnapshotTestState returns a state with a database.
+func newPollSnapshotTestState(tb testing.TB) *NetworkState {
+	var closeDb func() error
+	var err error
+	PermissioningDb, closeDb, err = NewDatabase("", "", tb.Name(), "", "")
+	if err != nil {
+		tb.Fatalf("Failed to create database: %+v", err)
+	}
+	tb.Cleanup(func() { _ = closeDb() })
+
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		tb.Fatalf("Failed to generate private key: %+v", err)
+	}
+	state, err := NewState(privateKey, 8, "", "", region.GetCountryBins())
+	if err != nil {
+		tb.Fatalf("Failed to create state: %+v", err)
+	}
+	return state
+}
+
+// addPollSnapshotUpdates adds the number of round updates to the state and
+// waits for them to be published.
+func addPollSnapshotUpdates(tb testing.TB, state *NetworkState, count int) {
+	expected := state.GetLastUpdateID() + count
+	for i := 0; i < count; i++ {
+		err := state.AddRoundUpdate(&pb.RoundInfo{ID: uint64(i + 1),
+			State:      uint32(states.PRECOMPUTING),
+			Timestamps: make([]uint64, states.NUM_STATES)})
+		if err != nil {
+			tb.Fatalf("Failed to add round update: %+v", err)
+		}
+	}
+	for i := 0; state.GetLastUpdateID() < expected; i++ {
+		if i == 500 {
+			tb.Fatalf("Round updates were not published: newest update %d",
+				state.GetLastUpdateID())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// publishPollSnapshotNdf publishes an NDF holding a node with the given name.
+func publishPollSnapshotNdf(tb testing.TB, state *NetworkState, name string) {
+	nid := id.NewIdFromString(name, id.Node, tb)
+	state.InternalNdfLock.Lock()
+	state.UpdateInternalNdf(&ndf.NetworkDefinition{
+		Nodes: []ndf.Node{{ID: nid.Marshal(), Address: "0.0.0.0:11420"}}})
+	state.InternalNdfLock.Unlock()
+	if err := state.UpdateOutputNdf(); err != nil {
+		tb.Fatalf("Failed to publish NDF: %+v", err)
+	}
+}
+
+// updateIDs returns the update IDs of the round updates.
+func updateIDs(updates []*pb.RoundInfo) []uint64 {
+	ids := make([]uint64, len(updates))
+	for i, u := range updates {
+		ids[i] = u.UpdateID
+	}
+	return ids
+}
+
+// Tests that the poll snapshot serves the same round updates as the update
+// buffer, leaving those older than it holds to the buffer, and that it holds
+// the published NDFs once they change.
+func TestNetworkState_GetPollSnapshot(t *testing.T) {
+	state := newPollSnapshotTestState(t)
+	addPollSnapshotUpdates(t, state, pollSnapshotUpdates+50)
+
+	snapshot := state.GetPollSnapshot()
+	newest := state.roundUpdates.GetLastUpdateID()
+	if snapshot.GetLastUpdateID() != newest {
+		t.Errorf("Snapshot's newest update is %d, expected %d.",
+			snapshot.GetLastUpdateID(), newest)
+	}
+	for lastUpdate := -1; lastUpdate <= newest+2; lastUpdate++ {
+		expected := updateIDs(state.roundUpdates.GetUpdates(lastUpdate))
+		updates, err := state.GetUpdates(lastUpdate)
+		if err != nil {
+			t.Fatalf("GetUpdates() returned an error: %+v", err)
+		}
+		if received := updateIDs(updates); !reflect.DeepEqual(received, expected) {
+			t.Errorf("Updates after %d do not match the buffer."+
+				"\nexpected: %v\nreceived: %v", lastUpdate, expected, received)
+		}
+
+		_, inSnapshot := snapshot.GetUpdates(lastUpdate)
+		if inSnapshot != (lastUpdate >= newest-pollSnapshotUpdates) {
+			t.Errorf("Updates after %d served from the snapshot: %t",
+				lastUpdate, inSnapshot)
+		}
+	}
+
+	// Appending to served updates does not write into the snapshot
+	updates, _ := snapshot.GetUpdates(newest - 5)
+	_ = append(updates[:1], &pb.RoundInfo{UpdateID: 0})
+	if updates, _ = snapshot.GetUpdates(newest - 5); updates[1].UpdateID == 0 {
+		t.Errorf("Appending to served updates modified the snapshot.")
+	}
+
+	publishPollSnapshotNdf(t, state, "node")
+	snapshot = state.GetPollSnapshot()
+	if snapshot.FullNdf != state.GetFullNdf().GetPb() ||
+		!bytes.Equal(snapshot.FullNdfHash, state.GetFullNdf().GetHash()) {
+		t.Errorf("Snapshot does not hold the published full NDF.")
+	}
+	if snapshot.PartialNdf != state.GetPartialNdf().GetPb() ||
+		!bytes.Equal(snapshot.PartialNdfHash, state.GetPartialNdf().GetHash()) {
+		t.Errorf("Snapshot does not hold the published partial NDF.")
+	}
+}
+
+// Tests that polls reading the snapshot while round updates are added and NDFs
+// are published always see consistent NDFs and ordered updates. Run with the
+// race detector to check the snapshot is published safely.
+func TestNetworkState_GetPollSnapshot_ConcurrentRefresh(t *testing.T) {
+	state := newPollSnapshotTestState(t)
+	addPollSnapshotUpdates(t, state, 10)
+
+	done := make(chan struct{})
+	var readers sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+
+				snapshot := state.GetPollSnapshot()
+				if snapshot.FullNdf != nil {
+					hash, err := dataStructures.GenerateNDFHash(snapshot.FullNdf)
+					if err != nil || !bytes.Equal(hash, snapshot.FullNdfHash) {
+						t.Errorf("Snapshot's full NDF hash does not match it.")
+						return
+					}
+				}
+				lastUpdate := snapshot.GetLastUpdateID() - 5
+				updates, err := state.GetUpdates(lastUpdate)
+				if err != nil {
+					t.Errorf("GetUpdates() returned an error: %+v", err)
+					return
+				}
+				for j, u := range updates {
+					if int(u.UpdateID) <= lastUpdate ||
+						(j > 0 && u.UpdateID <= updates[j-1].UpdateID) {
+						t.Errorf("Updates after %d out of order: %v",
+							lastUpdate, updateIDs(updates))
+						return
+					}
+				}
+			}
+		}()
+	}
+
+	var writers sync.WaitGroup
+	writers.Add(1)
+	go func() {
+		defer writers.Done()
+		for i := 0; i < 100; i++ {
+			err := state.AddRoundUpdate(&pb.RoundInfo{ID: uint64(i + 1),
+				State:      uint32(states.PRECOMPUTING),
+				Timestamps: make([]uint64, states.NUM_STATES)})
+			if err != nil {
+				t.Errorf("Failed to add round update: %+v", err)
+				return
+			}
+		}
+	}()
+	for i := 0; i < 20; i++ {
+		publishPollSnapshotNdf(t, state, "node"+string(rune('a'+i)))
+	}
+	writers.Wait()
+	addPollSnapshotUpdates(t, state, 1)
+	close(done)
+	readers.Wait()
+
+	if !bytes.Equal(state.GetPollSnapshot().FullNdfHash, state.GetFullNdf().GetHash()) {
+		t.Errorf("Snapshot does not hold the last published NDF.")
+	}
+}
+
+// runPollers runs b.N polls spread across benchmarkPollers concurrent pollers.
+func runPollers(b *testing.B, poll func()) {
+	perPoller := b.N/benchmarkPollers + 1
+	var wg sync.WaitGroup
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < benchmarkPollers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < perPoller; j++ {
+				poll()
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// Benchmarks the NDF and round update reads of a node poll through the locked
+// NDF and update buffer, for comparison with
+// BenchmarkNetworkState_GetPollSnapshot.
+func BenchmarkNetworkState_PollLocked(b *testing.B) {
+	state := newPollSnapshotTestState(b)
+	addPollSnapshotUpdates(b, state, 100)
+	publishPollSnapshotNdf(b, state, "node")
+	staleHash := []byte("stale")
+	lastUpdate := state.GetLastUpdateID() - 5
+
+	runPollers(b, func() {
+		if !state.GetFullNdf().CompareHash(staleHash) {
+			_ = state.GetFullNdf().GetPb()
+			_ = state.GetPartialNdf().GetPb()
+		}
+		_ = state.roundUpdates.GetUpdates(lastUpdate)
+	})
+}
+
+// Benchmarks the NDF and round update reads of a node poll through the poll
+// snapshot.
+func BenchmarkNetworkState_GetPollSnapshot(b *testing.B) {
+	state := newPollSnapshotTestState(b)
+	addPollSnapshotUpdates(b, state, 100)
+	publishPollSnapshotNdf(b, state, "node")
+	staleHash := []byte("stale")
+	lastUpdate := state.GetLastUpdateID() - 5
+
+	runPollers(b, func() {
+		snapshot := state.GetPollSnapshot()
+		if !bytes.Equal(snapshot.FullNdfHash, staleHash) {
+			_ = snapshot.FullNdf
+			_ = snapshot.PartialNdf
+		}
+		_, _ = state.GetUpdates(lastUpdate)
+	})
+}
+
+// Tests that the poll snapshot of each published NDF holds the hash of the NDF
+// published before it and the kinds of change it made to it.
+func TestNetworkState_GetPollSnapshot_NdfChange(t *testing.T) {
+	state := newPollSnapshotTestState(t)
+	nid := id.NewIdFromString("node", id.Node, t)
+	publish := func(address, registration string, nodes bool) {
+		def := &ndf.NetworkDefinition{
+			Registration: ndf.Registration{Address: registration}}
+		if nodes {
+			def.Nodes = []ndf.Node{{ID: nid.Marshal(), Address: address}}
+		}
+		state.InternalNdfLock.Lock()
+		state.UpdateInternalNdf(def)
+		state.InternalNdfLock.Unlock()
+		if err := state.UpdateOutputNdf(); err != nil {
+			t.Fatalf("Failed to publish NDF: %+v", err)
+		}
+	}
+
+	tests := []struct {
+		name         string
+		address, reg string
+		nodes        bool
+		expected     []string
+	}{
+		{"First", "0.0.0.0:1", "0.0.0.0:2", true, []string{NdfChangeOther}},
+		{"Address", "0.0.0.0:3", "0.0.0.0:2", true, []string{NdfChangeAddress}},
+		{"AddressAndOther", "0.0.0.0:4", "0.0.0.0:5", true,
+			[]string{NdfChangeAddress, NdfChangeOther}},
+		{"Removed", "", "0.0.0.0:5", false, []string{NdfChangeNodesRemoved}},
+	}
+	for _, tt := range tests {
+		previousHash := state.GetFullNdf().GetHash()
+		// NDFs are only published with later timestamps
+		time.Sleep(time.Millisecond)
+		publish(tt.address, tt.reg, tt.nodes)
+
+		snapshot := state.GetPollSnapshot()
+		if !bytes.Equal(snapshot.PreviousFullNdfHash, previousHash) {
+			t.Errorf("%s: snapshot does not hold the previous NDF's hash.",
+				tt.name)
+		}
+		if !reflect.DeepEqual(snapshot.FullNdfChangeKinds, tt.expected) {
+			t.Errorf("%s: unexpected kinds of change."+
+				"\nexpected: %v\nreceived: %v", tt.name, tt.expected,
+				snapshot.FullNdfChangeKinds)
+		}
+		if snapshot.FullNdfPublished.IsZero() {
+			t.Errorf("%s: snapshot does not hold the publishing time.", tt.name)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Handles describing where a round failed in its round info, so that gateways
+// can tell the clients which must resend their messages
+
+package round
+
+import (
+	pb "gitlab.com/elixxir/comms/mixmessages"
+	"gitlab.com/elixxir/primitives/states"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Field numbers of the failure of a failed round in the RoundInfo message.
+// The state the round was in when it failed and whether clients must resend
+// their messages are sent as varints in the message's unknown fields until
+// the comms message declares them. They are not covered by the round's
+// signature.
+const (
+	FailedInField     protowire.Number = 13
+	ResendNeededField protowire.Number = 14
+)
+
+// ResendNeeded returns true if clients could have submitted messages to a
+// round which failed in the given state, in which case they must resend them.
+// Clients submit messages to queued rounds, so nothing is lost with a round
+// which failed before it was queued.
+func ResendNeeded(failedIn states.Round) bool {
+	return failedIn >= states.QUEUED
+}
+
+// setFailure adds the state the round failed in, and whether clients must
+// resend their messages, to the round info.
+func setFailure(ri *pb.RoundInfo, failedIn states.Round) {
+	unknown := ri.ProtoReflect().GetUnknown()
+	unknown = protowire.AppendTag(unknown, FailedInField, protowire.VarintType)
+	unknown = protowire.AppendVarint(unknown, uint64(failedIn))
+	unknown = protowire.AppendTag(unknown, ResendNeededField, protowire.VarintType)
+	unknown = protowire.AppendVarint(unknown,
+		protowire.EncodeBool(ResendNeeded(failedIn)))
+	ri.ProtoReflect().SetUnknown(unknown)
+}
+
+// GetFailure returns the state the round of the round info failed in and
+// whether clients must resend their messages, or false if the round info
+// does not describe a failure.
+func GetFailure(ri *pb.RoundInfo) (failedIn states.Round, resend bool, ok bool) {
+	unknown := ri.ProtoReflect().GetUnknown()
+	var hasState, hasResend bool
+	for len(unknown) > 0 {
+		num, typ, n := protowire.ConsumeTag(unknown)
+		if n < 0 {
+			return 0, false, false
+		}
+		unknown = unknown[n:]
+		if typ == protowire.VarintType && (num == FailedInField || num == ResendNeededField) {
+			v, n := protowire.ConsumeVarint(unknown)
+			if n < 0 {
+				return 0, false, false
+			}
+			if num == FailedInField {
+				failedIn, hasState = states.Round(v), true
+			} else {
+				resend, hasResend = protowire.DecodeBool(v), true
+			}
+		}
+		n = protowire.ConsumeFieldValue(num, typ, unknown)
+		if n < 0 {
+			return 0, false, false
+		}
+		unknown = unknown[n:]
+	}
+	return failedIn, resend, hasState && hasResend
+}
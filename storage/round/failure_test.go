@@ -0,0 +1,47 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package round
+
+import (
+	"gitlab.com/elixxir/primitives/states"
+	"gitlab.com/xx_network/comms/connect"
+	"gitlab.com/xx_network/primitives/id"
+	"testing"
+	"time"
+)
+
+// Tests that the round info of a failed round describes the state it failed
+// in and whether clients must resend, that it survives copying, and that
+// rounds which did not fail describe no failure.
+func TestState_BuildRoundInfo_Failure(t *testing.T) {
+	topology := connect.NewCircuit([]*id.ID{id.NewIdFromUInt(0, id.Node, t)})
+	for _, tc := range []struct {
+		failedIn states.Round
+		resend   bool
+	}{
+		{states.PRECOMPUTING, false},
+		{states.STANDBY, false},
+		{states.QUEUED, true},
+		{states.REALTIME, true},
+	} {
+		r := NewState_Testing(1, tc.failedIn, topology, t)
+		if _, _, ok := GetFailure(r.BuildRoundInfo()); ok {
+			t.Errorf("Round in %s describes a failure.", tc.failedIn)
+		}
+		if err := r.Update(states.FAILED, time.Now()); err != nil {
+			t.Fatalf("Failed to fail round: %+v", err)
+		}
+
+		failedIn, resend, ok := GetFailure(CopyRoundInfo(r.BuildRoundInfo()))
+		if !ok || failedIn != tc.failedIn || resend != tc.resend {
+			t.Errorf("Round failed in %s describes a failure in %s with "+
+				"resend %t (%t), expected resend %t.", tc.failedIn, failedIn,
+				resend, ok, tc.resend)
+		}
+	}
+}
@@ -10,6 +10,7 @@ package round
 import (
 	"github.com/pkg/errors"
 	jww "github.com/spf13/jwalterweatherman"
+	pb "gitlab.com/elixxir/comms/mixmessages"
 	"gitlab.com/xx_network/comms/connect"
 	"gitlab.com/xx_network/primitives/id"
 	"sync"
@@ -47,6 +48,26 @@ func (rsm *StateMap) AddRound(id id.Round, batchSize, addressSpaceSize uint32, r
 	return rsm.rounds[id], nil
 }
 
+// LoadRound recreates a round state from the round info of a previously saved
+// round and adds it to the structure. Will not overwrite an existing one.
+func (rsm *StateMap) LoadRound(ri *pb.RoundInfo) (*State, error) {
+	rsm.mux.Lock()
+	defer rsm.mux.Unlock()
+
+	if _, ok := rsm.rounds[id.Round(ri.ID)]; ok {
+		return nil, errors.New("cannot load a round which already exists")
+	}
+
+	s, err := loadState(ri)
+	if err != nil {
+		return nil, err
+	}
+
+	rsm.rounds[s.GetRoundID()] = s
+	jww.TRACE.Printf("Loaded round %d to StateMap[%d]", s.GetRoundID(), len(rsm.rounds))
+	return s, nil
+}
+
 // GetRound obtains round State from the state structure
 func (rsm *StateMap) GetRound(id id.Round) (*State, bool) {
 	rsm.mux.RLock()
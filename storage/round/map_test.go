@@ -141,3 +141,47 @@ func buildMockTopology(numNodes int, t *testing.T) *connect.Circuit {
 	}
 	return connect.NewCircuit(nodeLst)
 }
+
+// Tests that a round is recreated from its round info by LoadRound and that
+// an existing round is not overwritten.
+func TestStateMap_LoadRound(t *testing.T) {
+	sm := NewStateMap()
+
+	topology := connect.NewCircuit([]*id.ID{
+		id.NewIdFromUInt(1, id.Node, t), id.NewIdFromUInt(2, id.Node, t)})
+	original := newState(42, 32, 8, 5*time.Second, topology, time.Now())
+	if err := original.Update(states.PRECOMPUTING, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if err := original.Update(states.QUEUED, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	ri := original.BuildRoundInfo()
+
+	loaded, err := sm.LoadRound(ri)
+	if err != nil {
+		t.Fatalf("LoadRound() returned an error: %+v", err)
+	}
+
+	if loaded.GetRoundID() != 42 || loaded.GetRoundState() != states.QUEUED {
+		t.Errorf("Loaded round has the wrong ID or state: %d, %s",
+			loaded.GetRoundID(), loaded.GetRoundState())
+	}
+	if loaded.GetTopology().Len() != 2 ||
+		!loaded.GetTopology().GetNodeAtIndex(1).Cmp(topology.GetNodeAtIndex(1)) {
+		t.Errorf("Loaded round has the wrong topology.")
+	}
+	loadedInfo := loaded.BuildRoundInfo()
+	if loadedInfo.Timestamps[states.QUEUED] != ri.Timestamps[states.QUEUED] ||
+		loadedInfo.BatchSize != ri.BatchSize {
+		t.Errorf("Loaded round info does not match the original."+
+			"\nexpected: %v\nreceived: %v", ri, loadedInfo)
+	}
+	if stored, exists := sm.GetRound(42); !exists || stored != loaded {
+		t.Errorf("Loaded round not added to the map.")
+	}
+
+	if _, err = sm.LoadRound(ri); err == nil {
+		t.Errorf("LoadRound() should not overwrite an existing round.")
+	}
+}
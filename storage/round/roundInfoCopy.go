@@ -12,7 +12,6 @@ import (
 	"gitlab.com/xx_network/comms/messages"
 )
 
-// TODO: add test
 // CopyRoundInfo returns a deep copy of mixmessages.RoundInfo.
 func CopyRoundInfo(ri *pb.RoundInfo) *pb.RoundInfo {
 	// Copy the topology
@@ -58,7 +57,13 @@ func CopyRoundInfo(ri *pb.RoundInfo) *pb.RoundInfo {
 	}
 	copy(signatureCopy.Nonce, ri.GetSignature().GetNonce())
 	copy(signatureCopy.Signature, ri.GetSignature().GetSignature())
-	return &pb.RoundInfo{
+	eccSignatureCopy := &messages.ECCSignature{
+		Nonce:     make([]byte, len(ri.GetEccSignature().GetNonce())),
+		Signature: make([]byte, len(ri.GetEccSignature().GetSignature())),
+	}
+	copy(eccSignatureCopy.Nonce, ri.GetEccSignature().GetNonce())
+	copy(eccSignatureCopy.Signature, ri.GetEccSignature().GetSignature())
+	riCopy := &pb.RoundInfo{
 		ID:                         ri.GetID(),
 		UpdateID:                   ri.GetUpdateID(),
 		State:                      ri.GetState(),
@@ -70,5 +75,13 @@ func CopyRoundInfo(ri *pb.RoundInfo) *pb.RoundInfo {
 		ResourceQueueTimeoutMillis: ri.GetResourceQueueTimeoutMillis(),
 		Signature:                  signatureCopy,
 		AddressSpaceSize:           ri.GetAddressSpaceSize(),
+		EccSignature:               eccSignatureCopy,
+	}
+
+	// Copy the fields not yet declared by the message
+	unknown := ri.ProtoReflect().GetUnknown()
+	if len(unknown) > 0 {
+		riCopy.ProtoReflect().SetUnknown(append([]byte(nil), unknown...))
 	}
+	return riCopy
 }
@@ -0,0 +1,69 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package round
+
+import (
+	"gitlab.com/elixxir/comms/mixmessages"
+	"gitlab.com/xx_network/comms/messages"
+	"gitlab.com/xx_network/primitives/id"
+	"google.golang.org/protobuf/proto"
+	"testing"
+)
+
+// Tests that CopyRoundInfo copies every field, including the complete
+// topology of a multi-node round, and that the copy shares no memory with the
+// original.
+func TestCopyRoundInfo(t *testing.T) {
+	topology := make([][]byte, 5)
+	for i := range topology {
+		topology[i] = id.NewIdFromUInt(uint64(i+1), id.Node, t).Marshal()
+	}
+	ri := &mixmessages.RoundInfo{
+		ID:         42,
+		UpdateID:   7,
+		State:      3,
+		BatchSize:  32,
+		Topology:   topology,
+		Timestamps: []uint64{1, 2, 3},
+		Errors: []*mixmessages.RoundError{{Id: 42, NodeId: topology[1],
+			Error: "error", Signature: &messages.RSASignature{
+				Nonce: []byte("nonce"), Signature: []byte("sig")}}},
+		ClientErrors: []*mixmessages.ClientError{{ClientId: []byte("client"),
+			Error: "error", Source: []byte("source")}},
+		ResourceQueueTimeoutMillis: 5000,
+		Signature: &messages.RSASignature{Nonce: []byte("nonce"),
+			Signature: []byte("sig")},
+		AddressSpaceSize: 8,
+		EccSignature: &messages.ECCSignature{Nonce: []byte("eccNonce"),
+			Signature: []byte("eccSig")},
+	}
+
+	riCopy := CopyRoundInfo(ri)
+	if !proto.Equal(ri, riCopy) {
+		t.Fatalf("Copy does not match the original.\nexpected: %+v"+
+			"\nreceived: %+v", ri, riCopy)
+	}
+
+	// Changing the original must not change the copy
+	expected := proto.Clone(riCopy).(*mixmessages.RoundInfo)
+	for _, member := range ri.Topology {
+		member[0] ^= 0xFF
+	}
+	ri.Topology[0] = nil
+	ri.Errors[0].NodeId[0] ^= 0xFF
+	ri.Signature.Nonce[0] ^= 0xFF
+	ri.EccSignature.Signature[0] ^= 0xFF
+	if !proto.Equal(expected, riCopy) {
+		t.Errorf("Copy changed with the original.\nexpected: %+v"+
+			"\nreceived: %+v", expected, riCopy)
+	}
+	if len(riCopy.Topology) != len(topology) {
+		t.Errorf("Topology of the copy lost members: %d of %d",
+			len(riCopy.Topology), len(topology))
+	}
+}
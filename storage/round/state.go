@@ -31,12 +31,18 @@ type State struct {
 	//state of the round
 	state states.Round
 
+	// State the round was in when it failed, set once it fails
+	failedIn states.Round
+
 	// Number of nodes ready for the next transition
 	readyForTransition uint8
 
 	// List of round errors received from nodes
 	roundErrors []*pb.RoundError
 
+	// Number of round errors not stored due to the limit on stored errors
+	suppressedErrors uint64
+
 	// List of client errors received from nodes
 	clientErrors []*pb.ClientError
 
@@ -48,9 +54,34 @@ type State struct {
 	// in order to get better granularity for when realtime finished
 	realtimeCompletedTs int64
 
+	// Time each node reported finishing a phase, keyed on the state the node
+	// reported (STANDBY for precomputation, COMPLETED for realtime)
+	phaseReports map[states.Round]map[id.ID]time.Time
+
+	// Realtime delay and timeout of the scheduling params the round was
+	// formed with, zero if not set
+	realtimeDelay   time.Duration
+	realtimeTimeout time.Duration
+
+	// JSON encoded scheduling params the round was formed with, nil if not
+	// set
+	schedulingConfig []byte
+
+	// Whether the round's metric has been stored, and the writes referencing
+	// it which are waiting until it is
+	metricStored  bool
+	pendingWrites []func()
+
 	mux sync.RWMutex
 }
 
+// Straggler identifies the slowest node of a round's team to finish a phase
+// and how long after the first node of the team it reported finishing
+type Straggler struct {
+	NodeId *id.ID
+	Delta  time.Duration
+}
+
 // creates a round state object
 func newState(id id.Round, batchsize, addressSpaceSize uint32, resourceQueueTimeout time.Duration,
 	topology *connect.Circuit, pendingTs time.Time) *State {
@@ -86,6 +117,45 @@ func newState(id id.Round, batchsize, addressSpaceSize uint32, resourceQueueTime
 	}
 }
 
+// recreates a round state object from the round info of a previously saved
+// round state
+func loadState(ri *pb.RoundInfo) (*State, error) {
+	topology := make([]*id.ID, len(ri.GetTopology()))
+	for i, nidBytes := range ri.GetTopology() {
+		nid, err := id.Unmarshal(nidBytes)
+		if err != nil {
+			return nil, errors.Errorf("Failed to unmarshal node %d of the "+
+				"topology of round %d: %+v", i, ri.ID, err)
+		}
+		topology[i] = nid
+	}
+
+	if states.Round(ri.State) >= states.NUM_STATES {
+		return nil, errors.Errorf("Round %d has an invalid state %d",
+			ri.ID, ri.State)
+	}
+
+	base := CopyRoundInfo(ri)
+	base.UpdateID = math.MaxUint64
+	base.Signature = nil
+	base.EccSignature = nil
+	if len(base.Timestamps) < int(states.NUM_STATES) {
+		timestamps := make([]uint64, states.NUM_STATES)
+		copy(timestamps, base.Timestamps)
+		base.Timestamps = timestamps
+	}
+
+	return &State{
+		base:          base,
+		topology:      connect.NewCircuit(topology),
+		state:         states.Round(ri.State),
+		roundErrors:   base.Errors,
+		clientErrors:  base.ClientErrors,
+		roundComplete: make(chan struct{}, 1),
+		lastUpdate:    time.Now(),
+	}, nil
+}
+
 // creates a round state object
 func NewState_Testing(id id.Round, state states.Round, topology *connect.Circuit, t *testing.T) *State {
 	if t == nil {
@@ -135,6 +205,9 @@ func (s *State) Update(state states.Round, stamp time.Time) error {
 
 	s.lastUpdate = time.Now()
 
+	if state == states.FAILED {
+		s.failedIn = s.state
+	}
 	s.state = state
 	s.base.Timestamps[state] = uint64(stamp.UnixNano())
 	return nil
@@ -159,7 +232,11 @@ func (s *State) BuildRoundInfo() *pb.RoundInfo {
 	s.base.ClientErrors = s.clientErrors
 	s.base.State = uint32(s.state)
 
-	return CopyRoundInfo(s.base)
+	ri := CopyRoundInfo(s.base)
+	if s.state == states.FAILED {
+		setFailure(ri, s.failedIn)
+	}
+	return ri
 }
 
 // returns the state of the round
@@ -190,8 +267,10 @@ func (s *State) SetRealtimeCompletedTs(ts int64) {
 	s.realtimeCompletedTs = ts
 }
 
-// Append a round error to our list of stored rounderrors
-func (s *State) AppendError(roundError *pb.RoundError) {
+// Append a round error to our list of stored rounderrors. Once limit errors
+// are stored, further errors are only counted as suppressed. A limit of 0
+// stores every error.
+func (s *State) AppendError(roundError *pb.RoundError, limit uint) {
 	s.mux.Lock()
 	defer s.mux.Unlock()
 
@@ -201,9 +280,22 @@ func (s *State) AppendError(roundError *pb.RoundError) {
 		}
 	}
 
+	if limit > 0 && uint(len(s.roundErrors)) >= limit {
+		s.suppressedErrors++
+		return
+	}
+
 	s.roundErrors = append(s.roundErrors, roundError)
 }
 
+// GetSuppressedErrors returns the number of round errors which were not
+// stored because the limit on stored errors was reached
+func (s *State) GetSuppressedErrors() uint64 {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+	return s.suppressedErrors
+}
+
 // Append a round error to our list of stored rounderrors
 func (s *State) AppendClientErrors(clientErrors []*pb.ClientError) {
 	s.mux.Lock()
@@ -225,3 +317,122 @@ func (s *State) DenoteRoundCompleted() {
 	default:
 	}
 }
+
+// RecordPhaseReport stores the time the node reported finishing the phase of
+// the round denoted by the reported state. Only the first report of each node
+// is kept.
+func (s *State) RecordPhaseReport(nid *id.ID, reported states.Round, ts time.Time) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if s.phaseReports == nil {
+		s.phaseReports = make(map[states.Round]map[id.ID]time.Time)
+	}
+	reports, exists := s.phaseReports[reported]
+	if !exists {
+		reports = make(map[id.ID]time.Time, s.topology.Len())
+		s.phaseReports[reported] = reports
+	}
+	if _, exists = reports[*nid]; !exists {
+		reports[*nid] = ts
+	}
+}
+
+// HasPhaseReport returns true if the node reported finishing the phase denoted
+// by the reported state.
+func (s *State) HasPhaseReport(nid *id.ID, reported states.Round) bool {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	_, exists := s.phaseReports[reported][*nid]
+	return exists
+}
+
+// GetStraggler returns the node which reported finishing the phase denoted by
+// the reported state last, and how long after the first report it was made.
+// Returns nil unless every node of the team has reported finishing the phase,
+// as the slowest node is otherwise unknown.
+func (s *State) GetStraggler(reported states.Round) *Straggler {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	reports := s.phaseReports[reported]
+	if len(reports) == 0 || len(reports) < s.topology.Len() {
+		return nil
+	}
+
+	var first, last time.Time
+	var straggler id.ID
+	for nid, ts := range reports {
+		if first.IsZero() || ts.Before(first) {
+			first = ts
+		}
+		if last.IsZero() || ts.After(last) {
+			last = ts
+			straggler = nid
+		}
+	}
+
+	return &Straggler{NodeId: straggler.DeepCopy(), Delta: last.Sub(first)}
+}
+
+// SetRealtimeParams sets the realtime delay and timeout the round was formed
+// with.
+func (s *State) SetRealtimeParams(delay, timeout time.Duration) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.realtimeDelay = delay
+	s.realtimeTimeout = timeout
+}
+
+// GetRealtimeParams returns the realtime delay and timeout the round was
+// formed with. Zero values indicate they were not set.
+func (s *State) GetRealtimeParams() (time.Duration, time.Duration) {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+	return s.realtimeDelay, s.realtimeTimeout
+}
+
+// SetSchedulingConfig sets the JSON encoded scheduling params the round was
+// formed with.
+func (s *State) SetSchedulingConfig(config []byte) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.schedulingConfig = config
+}
+
+// GetSchedulingConfig returns the JSON encoded scheduling params the round was
+// formed with, nil if they were not set.
+func (s *State) GetSchedulingConfig() []byte {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+	return s.schedulingConfig
+}
+
+// AfterMetricStored calls write once the round's metric has been stored, in a
+// new goroutine if it already is, so that writes referencing the metric never
+// precede it.
+func (s *State) AfterMetricStored(write func()) {
+	s.mux.Lock()
+	if !s.metricStored {
+		s.pendingWrites = append(s.pendingWrites, write)
+		s.mux.Unlock()
+		return
+	}
+	s.mux.Unlock()
+	go write()
+}
+
+// SetMetricStored marks the round's metric as stored and calls the writes
+// which were waiting for it.
+func (s *State) SetMetricStored() {
+	s.mux.Lock()
+	s.metricStored = true
+	pending := s.pendingWrites
+	s.pendingWrites = nil
+	s.mux.Unlock()
+
+	for _, write := range pending {
+		write()
+	}
+}
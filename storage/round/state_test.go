@@ -9,6 +9,8 @@ package round
 
 import (
 	"bytes"
+	"fmt"
+	pb "gitlab.com/elixxir/comms/mixmessages"
 	"gitlab.com/elixxir/primitives/states"
 	"gitlab.com/xx_network/primitives/id"
 	"math"
@@ -377,3 +379,143 @@ func TestState_GetTopology(t *testing.T) {
 		t.Errorf("retruned topology did not match passed topology")
 	}
 }
+
+// Tests that GetStraggler returns the last node to report a phase and how
+// long after the first report it was made, only once every node reported.
+func TestState_GetStraggler(t *testing.T) {
+	topology := buildMockTopology(3, t)
+	ns := newState(42, 32, 8, time.Minute, topology, time.Now())
+
+	start := time.Now()
+	ns.RecordPhaseReport(topology.GetNodeAtIndex(2), states.STANDBY, start)
+	ns.RecordPhaseReport(topology.GetNodeAtIndex(0), states.STANDBY,
+		start.Add(5*time.Second))
+
+	if straggler := ns.GetStraggler(states.STANDBY); straggler != nil {
+		t.Errorf("GetStraggler() returned a straggler before every node "+
+			"reported: %+v", straggler)
+	}
+
+	ns.RecordPhaseReport(topology.GetNodeAtIndex(1), states.STANDBY,
+		start.Add(2*time.Second))
+	// A repeated report should not replace the first one
+	ns.RecordPhaseReport(topology.GetNodeAtIndex(1), states.STANDBY,
+		start.Add(time.Minute))
+
+	straggler := ns.GetStraggler(states.STANDBY)
+	if straggler == nil {
+		t.Fatalf("GetStraggler() did not return a straggler.")
+	}
+	if !straggler.NodeId.Cmp(topology.GetNodeAtIndex(0)) ||
+		straggler.Delta != 5*time.Second {
+		t.Errorf("Unexpected straggler.\nexpected: %s, %s\nreceived: %s, %s",
+			topology.GetNodeAtIndex(0), 5*time.Second, straggler.NodeId,
+			straggler.Delta)
+	}
+
+	if ns.GetStraggler(states.COMPLETED) != nil {
+		t.Errorf("GetStraggler() returned a straggler for an unreported phase.")
+	}
+}
+
+// Tests that AppendError stores no more than the limit of errors and counts
+// the rest as suppressed, ignoring duplicates.
+func TestState_AppendError_Limit(t *testing.T) {
+	s := NewState_Testing(42, states.FAILED, buildMockTopology(5, t), t)
+
+	for i := 0; i < 5; i++ {
+		s.AppendError(&pb.RoundError{Id: 42, Error: fmt.Sprintf("error %d", i)}, 2)
+	}
+	s.AppendError(&pb.RoundError{Id: 42, Error: "error 0"}, 2)
+
+	if errs := s.BuildRoundInfo().Errors; len(errs) != 2 ||
+		errs[0].Error != "error 0" || errs[1].Error != "error 1" {
+		t.Errorf("Unexpected stored errors: %+v", errs)
+	}
+	if s.GetSuppressedErrors() != 3 {
+		t.Errorf("Unexpected number of suppressed errors."+
+			"\nexpected: %d\nreceived: %d", 3, s.GetSuppressedErrors())
+	}
+
+	// A limit of 0 stores every error
+	s.AppendError(&pb.RoundError{Id: 42, Error: "error 5"}, 0)
+	if len(s.BuildRoundInfo().Errors) != 3 {
+		t.Errorf("Error not stored without a limit.")
+	}
+}
+
+// Tests that AppendError with a limit of 0 stores every distinct error and
+// suppresses none.
+func TestState_AppendError_NoLimit(t *testing.T) {
+	s := NewState_Testing(42, states.FAILED, buildMockTopology(5, t), t)
+
+	for i := 0; i < 5; i++ {
+		s.AppendError(&pb.RoundError{Id: 42, Error: fmt.Sprintf("error %d", i)}, 0)
+	}
+	s.AppendError(&pb.RoundError{Id: 42, Error: "error 0"}, 0)
+
+	errs := s.BuildRoundInfo().Errors
+	if len(errs) != 5 {
+		t.Fatalf("Expected 5 stored errors, received %d: %+v", len(errs), errs)
+	}
+	for i, e := range errs {
+		if e.Error != fmt.Sprintf("error %d", i) {
+			t.Errorf("Unexpected stored error %d: %+v", i, e)
+		}
+	}
+	if s.GetSuppressedErrors() != 0 {
+		t.Errorf("Errors suppressed without a limit: %d", s.GetSuppressedErrors())
+	}
+}
+
+// Tests that the realtime params set on the round are returned
+func TestState_SetRealtimeParams(t *testing.T) {
+	s := NewState_Testing(42, states.PENDING, buildMockTopology(5, t), t)
+
+	if delay, timeout := s.GetRealtimeParams(); delay != 0 || timeout != 0 {
+		t.Errorf("Realtime params set on new round: %s, %s", delay, timeout)
+	}
+
+	s.SetRealtimeParams(3*time.Second, 15*time.Second)
+	if delay, timeout := s.GetRealtimeParams(); delay != 3*time.Second ||
+		timeout != 15*time.Second {
+		t.Errorf("Unexpected realtime params.\nexpected: %s, %s"+
+			"\nreceived: %s, %s", 3*time.Second, 15*time.Second, delay, timeout)
+	}
+}
+
+// Tests that writes waiting for the round's metric are only called once it is
+// stored, and that writes made after it is stored are called at once.
+func TestState_AfterMetricStored(t *testing.T) {
+	s := NewState_Testing(42, states.FAILED, buildMockTopology(5, t), t)
+
+	written := make(chan int, 3)
+	s.AfterMetricStored(func() { written <- 1 })
+	s.AfterMetricStored(func() { written <- 2 })
+	select {
+	case w := <-written:
+		t.Fatalf("Write %d was called before the metric was stored.", w)
+	default:
+	}
+
+	s.SetMetricStored()
+	for _, expected := range []int{1, 2} {
+		select {
+		case w := <-written:
+			if w != expected {
+				t.Errorf("Writes were called out of order.\nexpected: %d"+
+					"\nreceived: %d", expected, w)
+			}
+		default:
+			t.Fatalf("Write %d was not called once the metric was stored.",
+				expected)
+		}
+	}
+
+	s.AfterMetricStored(func() { written <- 3 })
+	select {
+	case <-written:
+	case <-time.After(time.Second):
+		t.Errorf("Write after the metric was stored was not called.")
+	}
+}
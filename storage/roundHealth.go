@@ -0,0 +1,132 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Handles tracking the rate rounds fail at across the network
+
+package storage
+
+import (
+	"sync"
+	"time"
+)
+
+// Window the round failure rate is measured over when none is configured
+const DefaultRoundHealthWindow = 10 * time.Minute
+
+// A round which completed or failed within the round health window
+type roundOutcome struct {
+	time   time.Time
+	failed bool
+}
+
+// roundHealth tracks the outcomes of the rounds which ended within the window
+type roundHealth struct {
+	// Outcomes within the window, oldest first
+	outcomes []roundOutcome
+	window   time.Duration
+
+	// Number of rounds which completed since the server started
+	completed uint64
+
+	mux sync.Mutex
+}
+
+// RoundHealth is the number of rounds which completed and failed within the
+// round health window.
+type RoundHealth struct {
+	Window    time.Duration
+	Completed int
+	Failed    int
+}
+
+// FailureRate returns the fraction of the rounds which ended within the
+// window that failed, or 0 if none ended.
+func (rh RoundHealth) FailureRate() float64 {
+	if rh.Completed+rh.Failed == 0 {
+		return 0
+	}
+	return float64(rh.Failed) / float64(rh.Completed+rh.Failed)
+}
+
+// SetRoundHealthWindow sets the window the round failure rate is measured
+// over. A window of 0 uses DefaultRoundHealthWindow.
+func (s *NetworkState) SetRoundHealthWindow(window time.Duration) {
+	rh := &s.roundHealth
+	rh.mux.Lock()
+	defer rh.mux.Unlock()
+	rh.window = window
+}
+
+// RecordRoundCompleted records that a round completed.
+func (s *NetworkState) RecordRoundCompleted(now time.Time) {
+	s.roundHealth.record(roundOutcome{time: now}, now)
+}
+
+// RecordRoundFailed records that a round failed.
+func (s *NetworkState) RecordRoundFailed(now time.Time) {
+	s.roundHealth.record(roundOutcome{time: now, failed: true}, now)
+}
+
+// GetRoundHealth returns the number of rounds which completed and failed
+// within the round health window.
+func (s *NetworkState) GetRoundHealth(now time.Time) RoundHealth {
+	rh := &s.roundHealth
+	rh.mux.Lock()
+	defer rh.mux.Unlock()
+
+	rh.trim(now)
+	health := RoundHealth{Window: rh.getWindow()}
+	for _, outcome := range rh.outcomes {
+		if outcome.failed {
+			health.Failed++
+		} else {
+			health.Completed++
+		}
+	}
+	return health
+}
+
+// GetRoundsCompleted returns the number of rounds which completed since the
+// server started, regardless of the round health window.
+func (s *NetworkState) GetRoundsCompleted() uint64 {
+	rh := &s.roundHealth
+	rh.mux.Lock()
+	defer rh.mux.Unlock()
+	return rh.completed
+}
+
+// record adds the outcome of a round.
+func (rh *roundHealth) record(outcome roundOutcome, now time.Time) {
+	rh.mux.Lock()
+	defer rh.mux.Unlock()
+
+	rh.outcomes = append(rh.outcomes, outcome)
+	if !outcome.failed {
+		rh.completed++
+	}
+	rh.trim(now)
+}
+
+// getWindow returns the window outcomes are kept for. Must be called with the
+// lock held.
+func (rh *roundHealth) getWindow() time.Duration {
+	if rh.window <= 0 {
+		return DefaultRoundHealthWindow
+	}
+	return rh.window
+}
+
+// trim drops the outcomes of rounds which ended before the window. Must be
+// called with the lock held.
+func (rh *roundHealth) trim(now time.Time) {
+	cutoff := now.Add(-rh.getWindow())
+	i := 0
+	for i < len(rh.outcomes) && rh.outcomes[i].time.Before(cutoff) {
+		i++
+	}
+	rh.outcomes = rh.outcomes[i:]
+}
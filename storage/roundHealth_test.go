@@ -0,0 +1,63 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+// Tests that GetRoundHealth() only counts the rounds which ended within the
+// configured window.
+func TestNetworkState_GetRoundHealth(t *testing.T) {
+	s := &NetworkState{}
+	s.SetRoundHealthWindow(time.Minute)
+	start := time.Now()
+
+	s.RecordRoundFailed(start)
+	s.RecordRoundFailed(start.Add(10 * time.Second))
+	s.RecordRoundCompleted(start.Add(20 * time.Second))
+	s.RecordRoundCompleted(start.Add(30 * time.Second))
+
+	health := s.GetRoundHealth(start.Add(30 * time.Second))
+	expected := RoundHealth{Window: time.Minute, Completed: 2, Failed: 2}
+	if health != expected {
+		t.Errorf("Unexpected round health.\nexpected: %+v\nreceived: %+v",
+			expected, health)
+	}
+	if health.FailureRate() != 0.5 {
+		t.Errorf("Unexpected failure rate %v", health.FailureRate())
+	}
+
+	// The first failure falls out of the window
+	health = s.GetRoundHealth(start.Add(65 * time.Second))
+	if health.Completed != 2 || health.Failed != 1 {
+		t.Errorf("Unexpected round health after the window moved: %+v", health)
+	}
+
+	health = s.GetRoundHealth(start.Add(2 * time.Minute))
+	if health.Completed != 0 || health.Failed != 0 || health.FailureRate() != 0 {
+		t.Errorf("Rounds outside the window were counted: %+v", health)
+	}
+
+	// Completed rounds are still counted after leaving the window
+	if completed := s.GetRoundsCompleted(); completed != 2 {
+		t.Errorf("Unexpected number of rounds completed: %d", completed)
+	}
+}
+
+// Tests that the default window is used when none is configured.
+func TestNetworkState_GetRoundHealth_DefaultWindow(t *testing.T) {
+	s := &NetworkState{}
+	now := time.Now()
+	s.RecordRoundCompleted(now.Add(-DefaultRoundHealthWindow + time.Second))
+	if health := s.GetRoundHealth(now); health.Window != DefaultRoundHealthWindow ||
+		health.Completed != 1 {
+		t.Errorf("Unexpected round health: %+v", health)
+	}
+}
@@ -0,0 +1,196 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Handles checking that signed round updates and NDFs verify against the keys
+// published in the NDF before they are published
+
+package storage
+
+import (
+	"github.com/pkg/errors"
+	jww "github.com/spf13/jwalterweatherman"
+	pb "gitlab.com/elixxir/comms/mixmessages"
+	"gitlab.com/elixxir/registration/storage/round"
+	"gitlab.com/xx_network/comms/signature"
+	"gitlab.com/xx_network/crypto/signature/ec"
+	"gitlab.com/xx_network/crypto/signature/rsa"
+	"gitlab.com/xx_network/crypto/tls"
+	"gitlab.com/xx_network/primitives/ndf"
+	"sync"
+)
+
+// Round updates with an ID divisible by this are checked even when the
+// published keys have not changed since the last check
+const signatureCheckInterval = 1000
+
+// publishedKeys holds the keys the NDF publishes for verifying permissioning's
+// signatures
+type publishedKeys struct {
+	rsa *rsa.PublicKey
+	// Nil if the NDF does not publish an elliptic curve key
+	ec *ec.PublicKey
+}
+
+// signatureCheck tracks the published keys round updates were last checked
+// against
+type signatureCheck struct {
+	// Certificate and elliptic curve key the keys were loaded from
+	certificate string
+	ellipticKey string
+	keys        *publishedKeys
+
+	// Set once a round update has verified against the keys
+	verified bool
+
+	mux sync.Mutex
+}
+
+// loadPublishedKeys returns the keys published in the NDF, or nil if it
+// publishes no certificate to verify against.
+func loadPublishedKeys(def *ndf.NetworkDefinition) (*publishedKeys, error) {
+	if def == nil || def.Registration.TlsCertificate == "" {
+		return nil, nil
+	}
+
+	cert, err := tls.LoadCertificate(def.Registration.TlsCertificate)
+	if err != nil {
+		return nil, errors.Errorf("Failed to load the permissioning "+
+			"certificate published in the NDF: %+v", err)
+	}
+	keys := &publishedKeys{}
+	keys.rsa, err = tls.ExtractPublicKey(cert)
+	if err != nil {
+		return nil, errors.Errorf("Failed to extract the permissioning key "+
+			"published in the NDF: %+v", err)
+	}
+
+	if def.Registration.EllipticPubKey != "" {
+		keys.ec, err = ec.LoadPublicKey(def.Registration.EllipticPubKey)
+		if err != nil {
+			return nil, errors.Errorf("Failed to load the elliptic curve "+
+				"key published in the NDF: %+v", err)
+		}
+	}
+	return keys, nil
+}
+
+// dueKeys returns the keys the round update with the given ID must be checked
+// against, or nil if it need not be checked. Updates are checked until one
+// verifies against the keys currently published in the NDF, and then
+// every signatureCheckInterval updates.
+func (sc *signatureCheck) dueKeys(def *ndf.NetworkDefinition,
+	updateID uint64) (*publishedKeys, error) {
+	if def == nil {
+		return nil, nil
+	}
+
+	sc.mux.Lock()
+	defer sc.mux.Unlock()
+
+	certificate := def.Registration.TlsCertificate
+	ellipticKey := def.Registration.EllipticPubKey
+	if sc.keys == nil || certificate != sc.certificate ||
+		ellipticKey != sc.ellipticKey {
+		keys, err := loadPublishedKeys(def)
+		if err != nil {
+			return nil, err
+		}
+		sc.certificate, sc.ellipticKey = certificate, ellipticKey
+		sc.keys = keys
+		sc.verified = false
+	}
+
+	if sc.keys == nil || (sc.verified && updateID%signatureCheckInterval != 0) {
+		return nil, nil
+	}
+	return sc.keys, nil
+}
+
+// setVerified records whether a round update verified against the keys.
+func (sc *signatureCheck) setVerified(keys *publishedKeys, verified bool) {
+	sc.mux.Lock()
+	defer sc.mux.Unlock()
+	if sc.keys == keys {
+		sc.verified = verified
+	}
+}
+
+// checkRoundSignature signs a copy of the round update as it will be
+// published under the given update ID and verifies it against the keys
+// published in the NDF, when the update is due to be checked. An error is
+// returned if it does not verify, so that the update is not published.
+func (s *NetworkState) checkRoundSignature(r *pb.RoundInfo,
+	updateID uint64) error {
+	s.InternalNdfLock.RLock()
+	def := s.unprunedNdf
+	s.InternalNdfLock.RUnlock()
+
+	keys, err := s.signatureCheck.dueKeys(def, updateID)
+	if err != nil {
+		jww.ERROR.Printf("SIGNATURE SELF-CHECK FAILED: refusing to publish "+
+			"update %d of round %d: %+v", updateID, r.ID, err)
+		return err
+	}
+	if keys == nil {
+		return nil
+	}
+
+	probe := round.CopyRoundInfo(r)
+	probe.UpdateID = updateID
+	if err = signature.SignRsa(probe, s.rsaPrivateKey); err != nil {
+		return errors.Errorf("Failed to sign update %d of round %d: %+v",
+			updateID, r.ID, err)
+	}
+	if keys.ec != nil {
+		if err = signature.SignEddsa(probe, s.GetEllipticPrivateKey()); err != nil {
+			return errors.Errorf("Failed to sign update %d of round %d with "+
+				"elliptic curve key: %+v", updateID, r.ID, err)
+		}
+	}
+
+	err = signature.VerifyRsa(probe, keys.rsa)
+	if err == nil && keys.ec != nil {
+		err = signature.VerifyEddsa(probe, keys.ec)
+	}
+	if err != nil {
+		s.signatureCheck.setVerified(keys, false)
+		jww.ERROR.Printf("SIGNATURE SELF-CHECK FAILED: update %d of round "+
+			"%d does not verify against the keys published in the NDF, "+
+			"refusing to publish it: %+v", updateID, r.ID, err)
+		return errors.Errorf("Update %d of round %d does not verify "+
+			"against the keys published in the NDF: %+v", updateID, r.ID, err)
+	}
+
+	s.signatureCheck.setVerified(keys, true)
+	return nil
+}
+
+// checkNdfSignatures verifies the signed full and partial NDF messages against
+// the keys published in the NDF they carry. An error is returned if they do
+// not verify, so that the NDF is not published.
+func checkNdfSignatures(def *ndf.NetworkDefinition, msgs ...*pb.NDF) error {
+	keys, err := loadPublishedKeys(def)
+	if err != nil {
+		jww.ERROR.Printf("SIGNATURE SELF-CHECK FAILED: refusing to publish "+
+			"the NDF: %+v", err)
+		return err
+	}
+	if keys == nil {
+		return nil
+	}
+
+	for _, msg := range msgs {
+		if err = signature.VerifyRsa(msg, keys.rsa); err != nil {
+			jww.ERROR.Printf("SIGNATURE SELF-CHECK FAILED: the NDF does not "+
+				"verify against the key it publishes, refusing to publish "+
+				"it: %+v", err)
+			return errors.Errorf("NDF does not verify against the key it "+
+				"publishes: %+v", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package storage
+
+import (
+	"crypto/rand"
+	pb "gitlab.com/elixxir/comms/mixmessages"
+	"gitlab.com/elixxir/comms/testkeys"
+	"gitlab.com/elixxir/primitives/states"
+	"gitlab.com/xx_network/crypto/signature/ec"
+	"gitlab.com/xx_network/primitives/id"
+	"gitlab.com/xx_network/primitives/ndf"
+	"testing"
+	"time"
+)
+
+// Tests that AddRoundUpdate() refuses to publish round updates which do not
+// verify against the keys published in the NDF, without spending an update ID.
+func TestNetworkState_AddRoundUpdate_SignatureCheck(t *testing.T) {
+	var err error
+	PermissioningDb, _, err = NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("Failed to create database: %+v", err)
+	}
+	state, _, err := generateTestNetworkState()
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	newRound := func(rid uint64) *pb.RoundInfo {
+		return &pb.RoundInfo{ID: rid, State: uint32(states.PRECOMPUTING),
+			Topology:   [][]byte{id.NewIdFromUInt(1, id.Node, t).Marshal()},
+			Timestamps: make([]uint64, states.NUM_STATES)}
+	}
+	otherEcKey, err := ec.NewKeyPair(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate elliptic curve key: %+v", err)
+	}
+
+	mismatched := map[string]ndf.Registration{
+		"RSA key": {
+			TlsCertificate: string(testkeys.LoadFromPath(testkeys.GetGatewayCertPath())),
+			EllipticPubKey: state.GetEllipticPublicKey().MarshalText(),
+		},
+		"elliptic curve key": {
+			TlsCertificate: string(testkeys.LoadFromPath(testkeys.GetNodeCertPath())),
+			EllipticPubKey: otherEcKey.GetPublic().MarshalText(),
+		},
+		"certificate": {TlsCertificate: "not a certificate"},
+	}
+	for name, reg := range mismatched {
+		state.UpdateInternalNdf(&ndf.NetworkDefinition{Registration: reg})
+		updateID := state.updateID
+		if err = state.AddRoundUpdate(newRound(1)); err == nil {
+			t.Errorf("AddRoundUpdate() published an update with a "+
+				"mismatched %s.", name)
+		}
+		if state.updateID != updateID {
+			t.Errorf("AddRoundUpdate() spent update ID %d on an update "+
+				"with a mismatched %s.", updateID, name)
+		}
+	}
+	time.Sleep(100 * time.Millisecond)
+	if updates, _ := state.GetUpdates(0); len(updates) != 0 {
+		t.Errorf("Updates were published with mismatched keys: %v", updates)
+	}
+
+	// Updates are published once the NDF publishes the state's keys
+	state.UpdateInternalNdf(&ndf.NetworkDefinition{
+		Registration: ndf.Registration{
+			TlsCertificate: string(testkeys.LoadFromPath(testkeys.GetNodeCertPath())),
+			EllipticPubKey: state.GetEllipticPublicKey().MarshalText(),
+		},
+	})
+	if err = state.AddRoundUpdate(newRound(1)); err != nil {
+		t.Fatalf("AddRoundUpdate() unexpectedly produced an error: %+v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if updates, _ := state.GetUpdates(0); len(updates) != 1 {
+		t.Errorf("Expected 1 published update, received %d.", len(updates))
+	}
+}
+
+// Tests that round updates are checked until one verifies against the
+// published keys, then only every signatureCheckInterval updates until the
+// keys change.
+func TestSignatureCheck_dueKeys(t *testing.T) {
+	sc := &signatureCheck{}
+	def := &ndf.NetworkDefinition{Registration: ndf.Registration{
+		TlsCertificate: string(testkeys.LoadFromPath(testkeys.GetNodeCertPath())),
+	}}
+
+	keys, err := sc.dueKeys(def, 1)
+	if err != nil || keys == nil {
+		t.Fatalf("First update was not due to be checked: %v, %+v", keys, err)
+	}
+	if keys, _ = sc.dueKeys(def, 2); keys == nil {
+		t.Errorf("Update was not due to be checked before one verified.")
+	}
+
+	sc.setVerified(keys, true)
+	if keys, _ = sc.dueKeys(def, 3); keys != nil {
+		t.Errorf("Update was due to be checked after one verified.")
+	}
+	if keys, _ = sc.dueKeys(def, signatureCheckInterval); keys == nil {
+		t.Errorf("Sampled update was not due to be checked.")
+	}
+
+	changed := &ndf.NetworkDefinition{Registration: ndf.Registration{
+		TlsCertificate: string(testkeys.LoadFromPath(testkeys.GetGatewayCertPath())),
+	}}
+	if keys, _ = sc.dueKeys(changed, 4); keys == nil {
+		t.Errorf("Update was not due to be checked after the keys changed.")
+	}
+
+	if keys, err = sc.dueKeys(&ndf.NetworkDefinition{}, 5); keys != nil || err != nil {
+		t.Errorf("Update was due to be checked without published keys: "+
+			"%v, %+v", keys, err)
+	}
+}
+
+// Tests that UpdateOutputNdf() refuses to publish an NDF which does not verify
+// against the key it publishes.
+func TestNetworkState_UpdateOutputNdf_SignatureCheck(t *testing.T) {
+	var err error
+	PermissioningDb, _, err = NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("Failed to create database: %+v", err)
+	}
+	state, _, err := generateTestNetworkState()
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	state.UpdateInternalNdf(&ndf.NetworkDefinition{
+		Registration: ndf.Registration{
+			TlsCertificate: string(testkeys.LoadFromPath(testkeys.GetGatewayCertPath())),
+		},
+	})
+	published := state.GetFullNdf().Get()
+	if err = state.UpdateOutputNdf(); err == nil {
+		t.Errorf("UpdateOutputNdf() published an NDF with a mismatched key.")
+	}
+	if state.GetFullNdf().Get() != published {
+		t.Errorf("Full NDF was replaced by an NDF with a mismatched key.")
+	}
+
+	// The NDF is published once it publishes the state's key
+	state.UpdateInternalNdf(&ndf.NetworkDefinition{
+		Registration: ndf.Registration{
+			TlsCertificate: string(testkeys.LoadFromPath(testkeys.GetNodeCertPath())),
+		},
+	})
+	if err = state.UpdateOutputNdf(); err != nil {
+		t.Fatalf("UpdateOutputNdf() unexpectedly produced an error: %+v", err)
+	}
+	if state.GetFullNdf().Get() == published {
+		t.Errorf("Full NDF was not replaced.")
+	}
+}
@@ -26,8 +26,8 @@ import (
 	"gitlab.com/xx_network/primitives/id"
 	"gitlab.com/xx_network/primitives/ndf"
 	"gitlab.com/xx_network/primitives/region"
-	"gitlab.com/xx_network/primitives/utils"
 	"google.golang.org/protobuf/proto"
+	"regexp"
 	"strconv"
 	"strings"
 	"sync"
@@ -56,8 +56,13 @@ type NetworkState struct {
 	// List of states of Nodes to be disabled
 	disabledNodesStates *disabledNodes
 
+	// Called when the scheduler demotes a node to the diagnostic state
+	demotionHandler    func(n *node.State, reason string)
+	demotionHandlerMux sync.RWMutex
+
 	// Keep track of Country -> Bin mapping
-	geoBins map[string]region.GeoBin
+	geoBins    map[string]region.GeoBin
+	geoBinsMux sync.RWMutex
 
 	// NDF state
 	InternalNdfLock sync.RWMutex
@@ -70,6 +75,13 @@ type NetworkState struct {
 	partialNdf    *dataStructures.Ndf
 	fullNdf       *dataStructures.Ndf
 
+	// NDFs and newest round updates polls are served from without locking
+	pollSnapshot pollSnapshot
+
+	// Partial NDF in each alternate format, and the files they are output to
+	formatNdfs           map[string]*dataStructures.Ndf
+	formatNdfOutputPaths map[string]string
+
 	// Address space size
 	addressSpaceSize *uint32
 
@@ -86,6 +98,75 @@ type NetworkState struct {
 	// round states
 	roundID  id.Round
 	updateID uint64
+
+	// Additional patterns redacted from round errors before publishing
+	errorRedactions []*regexp.Regexp
+
+	// Named groups of nodes which rounds can be built from
+	nodeGroups    map[string][]*id.ID
+	nodeGroupsMux sync.RWMutex
+
+	// Applications each Application refuses to be teamed with, and the
+	// fraction of the network an avoid-list may exclude before it is flagged
+	avoidLists            map[uint64]map[uint64]bool
+	avoidListWarnFraction float64
+	avoidListsMux         sync.RWMutex
+
+	// Subscribers to NDF and round updates
+	ndfStream ndfStream
+
+	// Bins of the nodes teamed for recent rounds
+	binFairness binFairness
+
+	// Recently published NDFs kept for rollback
+	ndfHistory ndfHistory
+
+	// Changes of recently published NDFs
+	ndfChangelog ndfChangelog
+
+	// Rounds and nodes whose logging is elevated
+	debugTargets debugTargets
+
+	// Loggers the network state logs to, the global jww loggers if nil
+	logger *jww.Notepad
+
+	// Rate nodes are scheduled at, for estimating waiting times
+	schedulingRate schedulingRate
+
+	// Outcomes of the rounds which ended recently
+	roundHealth roundHealth
+
+	// Time node updates waited to be handled by the scheduler
+	updateLag updateLag
+
+	// Times since their last poll past which nodes are stale or dead
+	nodeStaleness nodeStaleness
+
+	// Published keys round update signatures are checked against
+	signatureCheck signatureCheck
+
+	// Whether each marshalled NDF is checked to unmarshal back to its source
+	// before it is published, set atomically
+	ndfRoundTripCheck uint32
+
+	// How far the network is through being drained, and the signal waking
+	// the scheduler when draining starts
+	drain drain
+
+	// Writes of the published NDFs to their output files
+	ndfWrites ndfWriter
+
+	// Policy for keeping snapshots of the published NDFs in Storage
+	ndfSnapshots ndfSnapshots
+
+	// Longest a node's polling lock is held once handed off with its update
+	// before it is released, zero to hold it until the update is handled,
+	// set atomically
+	pollingLockTimeout int64
+
+	// Times nodes were left out of a team for each reason, across the
+	// network, indexed by node.ExclusionReason and updated atomically
+	exclusions [node.NumExclusionReasons]uint64
 }
 
 // NewState returns a new NetworkState object.
@@ -101,6 +182,10 @@ func NewState(rsaPrivKey *rsa.PrivateKey, addressSpaceSize uint32,
 	if err != nil {
 		return nil, err
 	}
+	formatNdfs, err := newFormatNdfs()
+	if err != nil {
+		return nil, err
+	}
 
 	state := &NetworkState{
 		rounds:                     round.NewStateMap(),
@@ -109,6 +194,7 @@ func NewState(rsaPrivKey *rsa.PrivateKey, addressSpaceSize uint32,
 		nodes:                      node.NewStateMap(),
 		fullNdf:                    fullNdf,
 		partialNdf:                 partialNdf,
+		formatNdfs:                 formatNdfs,
 		rsaPrivateKey:              rsaPrivKey,
 		addressSpaceSize:           &addressSpaceSize,
 		unprunedNdf:                &ndf.NetworkDefinition{},
@@ -117,8 +203,15 @@ func NewState(rsaPrivKey *rsa.PrivateKey, addressSpaceSize uint32,
 		signedPartialNdfOutputPath: signedPartialNdfOutputPath,
 		roundUpdatesToAddCh:        make(chan *dataStructures.Round, 500),
 		geoBins:                    geoBins,
+		drain:                      drain{signal: make(chan struct{}, 1)},
+		nodeGroups:                 make(map[string][]*id.ID),
+		avoidLists:                 make(map[uint64]map[uint64]bool),
+		avoidListWarnFraction:      DefaultAvoidListWarnFraction,
 	}
 
+	// Publish the initial poll snapshot before any poll can read it
+	state.refreshPollSnapshot()
+
 	//begin the thread that reads and adds round updates
 	go state.RoundAdderRoutine()
 
@@ -243,6 +336,15 @@ func (s *NetworkState) SetPrunedNode(id *id.ID) {
 	s.pruneList[*id] = true
 }
 
+// Removes a Node from the pruned list
+// Used when a Node registers again under a new ID
+func (s *NetworkState) RemovePrunedNode(id *id.ID) {
+	s.pruneListMux.Lock()
+	defer s.pruneListMux.Unlock()
+
+	delete(s.pruneList, *id)
+}
+
 func (s *NetworkState) IsPruned(node *id.ID) bool {
 	s.pruneListMux.RLock()
 	defer s.pruneListMux.RUnlock()
@@ -269,23 +371,48 @@ func (s *NetworkState) GetPartialNdf() *dataStructures.Ndf {
 	return s.partialNdf
 }
 
-// GetGeoBin returns the GeoBin map.
+// GetGeoBin returns the GeoBin map. The map is replaced rather than modified
+// by SetGeoBins, so it must not be modified.
 func (s *NetworkState) GetGeoBins() map[string]region.GeoBin {
+	s.geoBinsMux.RLock()
+	defer s.geoBinsMux.RUnlock()
 	return s.geoBins
 }
 
-// GetUpdates returns all of the updates after the given ID.
+// GetUpdates returns all of the updates after the given ID. Updates recent
+// enough to be in the poll snapshot are served from it without locking. The
+// returned updates must not be modified.
 func (s *NetworkState) GetUpdates(id int) ([]*pb.RoundInfo, error) {
+	if updates, ok := s.GetPollSnapshot().GetUpdates(id); ok {
+		return updates, nil
+	}
 	return s.roundUpdates.GetUpdates(id), nil
 }
 
+// GetLastUpdateID returns the ID of the newest round update served to polls.
+func (s *NetworkState) GetLastUpdateID() int {
+	return s.GetPollSnapshot().GetLastUpdateID()
+}
+
 // AddRoundUpdate creates a copy of the round before inserting it into
 // roundUpdates.
 func (s *NetworkState) AddRoundUpdate(r *pb.RoundInfo) error {
 	s.updateMux.Lock()
 	defer s.updateMux.Unlock()
 
+	// Every member of the topology must be carried intact, as gateways and
+	// clients route by it
+	if err := validateRoundTopology(r); err != nil {
+		return err
+	}
+
 	roundCopy := round.CopyRoundInfo(r)
+
+	// Refuse to spend an update ID on an update clients could not verify
+	if err := s.checkRoundSignature(roundCopy, s.updateID); err != nil {
+		return err
+	}
+
 	updateID, err := s.IncrementUpdateID()
 	if err != nil {
 		return err
@@ -293,8 +420,11 @@ func (s *NetworkState) AddRoundUpdate(r *pb.RoundInfo) error {
 
 	roundCopy.UpdateID = updateID
 
+	// Persist the transition before signing modifies the copy
+	s.SaveActiveRound(roundCopy)
+
 	go func() {
-		err = signature.SignRsa(roundCopy, s.rsaPrivateKey)
+		err := signature.SignRsa(roundCopy, s.rsaPrivateKey)
 		if err != nil {
 			jww.FATAL.Panicf("Could not add round update %v "+
 				"for round %v due to failed signature: %+v",
@@ -321,6 +451,75 @@ func (s *NetworkState) AddRoundUpdate(r *pb.RoundInfo) error {
 	return nil
 }
 
+// validateRoundTopology returns an error if any member of the round's topology
+// is not a valid node ID or appears more than once.
+func validateRoundTopology(r *pb.RoundInfo) error {
+	members := make(map[id.ID]bool, len(r.GetTopology()))
+	for i, member := range r.GetTopology() {
+		nid, err := id.Unmarshal(member)
+		if err != nil {
+			return errors.Errorf("Round %d has invalid node ID %v at "+
+				"position %d of its topology: %+v", r.GetID(), member, i, err)
+		}
+		if members[*nid] {
+			return errors.Errorf("Round %d has node %s more than once in "+
+				"its topology", r.GetID(), nid)
+		}
+		members[*nid] = true
+	}
+	return nil
+}
+
+// SaveActiveRound stores the state of an in-flight round so it can be resumed
+// after a restart. Rounds which have completed or failed are removed from
+// Storage instead. Failures are logged but do not interrupt the round.
+func (s *NetworkState) SaveActiveRound(r *pb.RoundInfo) {
+	// Update ID 0 is a placeholder and not an actual round
+	if r.ID == 0 {
+		return
+	}
+
+	var err error
+	roundState := states.Round(r.State)
+	if roundState == states.COMPLETED || roundState == states.FAILED {
+		err = PermissioningDb.DeleteActiveRound(id.Round(r.ID))
+	} else {
+		var data []byte
+		data, err = proto.Marshal(r)
+		if err == nil {
+			err = PermissioningDb.UpsertActiveRound(&ActiveRound{
+				Id:        r.ID,
+				RoundInfo: data,
+			})
+		}
+	}
+
+	if err != nil {
+		jww.WARN.Printf("Failed to save state %s of round %d, it cannot "+
+			"be resumed after a restart: %+v", roundState, r.ID, err)
+	}
+}
+
+// GetActiveRounds returns the last saved state of every in-flight round.
+func (s *NetworkState) GetActiveRounds() ([]*pb.RoundInfo, error) {
+	activeRounds, err := PermissioningDb.GetActiveRounds()
+	if err != nil {
+		return nil, errors.WithMessage(err, "Failed to load active rounds")
+	}
+
+	roundInfos := make([]*pb.RoundInfo, 0, len(activeRounds))
+	for _, ar := range activeRounds {
+		ri := &pb.RoundInfo{}
+		err = proto.Unmarshal(ar.RoundInfo, ri)
+		if err != nil {
+			return nil, errors.Errorf("Failed to unmarshal active "+
+				"round %d: %+v", ar.Id, err)
+		}
+		roundInfos = append(roundInfos, ri)
+	}
+	return roundInfos, nil
+}
+
 // RoundAdderRoutine monitors a channel and keeps track of pending round updates,
 // adding them in order
 func (s *NetworkState) RoundAdderRoutine() {
@@ -344,6 +543,8 @@ func (s *NetworkState) RoundAdderRoutine() {
 			if err != nil {
 				jww.FATAL.Panicf("%+v", err)
 			}
+			s.refreshPollSnapshot()
+			s.ndfStream.publishRound(rnd.Get())
 			continue
 		}
 
@@ -361,6 +562,8 @@ func (s *NetworkState) RoundAdderRoutine() {
 			if err != nil {
 				jww.FATAL.Panicf("%+v", err)
 			}
+			s.refreshPollSnapshot()
+			s.ndfStream.publishRound(r.Get())
 			// Clean up processed round
 			delete(futureRoundUpdates, nextID)
 			nextID++
@@ -376,6 +579,45 @@ func (s *NetworkState) UpdateInternalNdf(newNdf *ndf.NetworkDefinition) {
 	s.unprunedNdf = newNdf.DeepCopy()
 }
 
+// addPublicAddresses publishes the public address of each node in the stripped
+// partial NDF which reported one separate from its inter-node address, which
+// stays in the full NDF only. Nodes with a single address keep no address in
+// the partial NDF.
+func (s *NetworkState) addPublicAddresses(partial *ndf.NetworkDefinition) *ndf.NetworkDefinition {
+	for i := range partial.Nodes {
+		nid, err := id.Unmarshal(partial.Nodes[i].ID)
+		if err != nil {
+			continue
+		}
+		if n := s.GetNodeMap().GetNode(nid); n != nil {
+			partial.Nodes[i].Address = n.GetPublicAddress()
+		}
+	}
+	return partial
+}
+
+// addGatewayClientPorts publishes the client facing address of each gateway in
+// the stripped partial NDF whose node reported a client port for it, while the
+// full NDF keeps the node facing address. Gateways with a single address are
+// published unchanged.
+func (s *NetworkState) addGatewayClientPorts(partial *ndf.NetworkDefinition) *ndf.NetworkDefinition {
+	// The stripped NDF shares its gateways with the full NDF
+	gateways := make([]ndf.Gateway, len(partial.Gateways))
+	copy(gateways, partial.Gateways)
+	for i := range gateways {
+		nid, err := id.Unmarshal(gateways[i].ID)
+		if err != nil {
+			continue
+		}
+		nid.SetType(id.Node)
+		if n := s.GetNodeMap().GetNode(nid); n != nil && n.GetGatewayClientPort() != 0 {
+			gateways[i].Address = n.GetGatewayClientAddress()
+		}
+	}
+	partial.Gateways = gateways
+	return partial
+}
+
 // UpdateOutputNdf takes the current unprunedNdf and signs and outputs
 // it to the full & partial ndf fields, along with writing it to disk.
 func (s *NetworkState) UpdateOutputNdf() (err error) {
@@ -420,18 +662,41 @@ func (s *NetworkState) UpdateOutputNdf() (err error) {
 	}
 	s.pruneListMux.RUnlock()
 
+	// Nodes which run without a public gateway are published without a
+	// gateway entry
+	gateways := newNdf.Gateways[:0]
+	for _, gw := range newNdf.Gateways {
+		if nid, err := id.Unmarshal(gw.ID); err == nil {
+			nid.SetType(id.Node)
+			if n := s.GetNodeMap().GetNode(nid); n != nil && n.IsGatewayless() {
+				continue
+			}
+		}
+		gateways = append(gateways, gw)
+	}
+	newNdf.Gateways = gateways
+
 	// Build NDF comms messages
 	fullNdfMsg := &pb.NDF{}
-	fullNdfMsg.Ndf, err = newNdf.Marshal()
+	fullNdfMsg.Ndf, err = marshalNdf(newNdf)
 	if err != nil {
 		return
 	}
+	partialNdf := s.addGatewayClientPorts(s.addPublicAddresses(newNdf.StripNdf()))
 	partialNdfMsg := &pb.NDF{}
-	partialNdfMsg.Ndf, err = newNdf.StripNdf().Marshal()
+	partialNdfMsg.Ndf, err = marshalNdf(partialNdf)
 	if err != nil {
 		return
 	}
 
+	// Refuse to publish an NDF which does not unmarshal back to its source
+	if err = s.checkNdfRoundTrip(newNdf, fullNdfMsg.Ndf); err != nil {
+		return err
+	}
+	if err = s.checkNdfRoundTrip(partialNdf, partialNdfMsg.Ndf); err != nil {
+		return err
+	}
+
 	// Sign NDF comms messages
 	err = signature.SignRsa(fullNdfMsg, s.rsaPrivateKey)
 	if err != nil {
@@ -442,7 +707,14 @@ func (s *NetworkState) UpdateOutputNdf() (err error) {
 		return
 	}
 
+	// Refuse to publish an NDF which does not verify against its own key
+	err = checkNdfSignatures(newNdf, fullNdfMsg, partialNdfMsg)
+	if err != nil {
+		return err
+	}
+
 	// Assign NDF comms messages
+	previousHash := s.fullNdf.GetHash()
 	err = s.fullNdf.Update(fullNdfMsg)
 	if err != nil {
 		return err
@@ -452,12 +724,20 @@ func (s *NetworkState) UpdateOutputNdf() (err error) {
 	if err != nil {
 		return err
 	}
+	s.updateFormatNdfs(partialNdf)
+
+	// Push the new NDF to the stream subscribers
+	s.ndfStream.publishNdf(s.fullNdf.GetPb())
+	published := s.recordNdf(s.fullNdf.GetPb(), loadedNdf)
+	change := s.recordNdfChange(published.Version, published.Published, newNdf)
+
+	// Serve the new NDF to polls along with the change it made
+	s.setPollSnapshotNdfChange(previousHash, change)
+	s.refreshPollSnapshot()
+	s.storeNdfSnapshot(s.fullNdf.GetHash(), fullNdfMsg.Ndf, published.Published)
 
 	// Output full NDF to file
-	err = outputToJSON(newNdf, s.fullNdfOutputPath)
-	if err != nil {
-		jww.ERROR.Printf("unable to output full NDF JSON file: %+v", err)
-	}
+	s.ndfWrites.write(s.fullNdfOutputPath, fullNdfMsg.Ndf)
 
 	// Marshal signed partial NDF
 	signedPartialNdfMarshal, err := proto.Marshal(s.partialNdf.GetPb())
@@ -469,11 +749,7 @@ func (s *NetworkState) UpdateOutputNdf() (err error) {
 	signedPartialEncoded := base64.StdEncoding.EncodeToString(signedPartialNdfMarshal)
 
 	// Output signed partial ndf to file
-	err = utils.WriteFile(s.signedPartialNdfOutputPath,
-		[]byte(signedPartialEncoded), utils.FilePerms, utils.DirPerms)
-	if err != nil {
-		jww.ERROR.Printf("unable to output signed partial NDF to file: %+v", err)
-	}
+	s.ndfWrites.write(s.signedPartialNdfOutputPath, []byte(signedPartialEncoded))
 
 	jww.INFO.Printf("Full NDF updated to: %s", base64.StdEncoding.EncodeToString(s.fullNdf.GetHash()))
 
@@ -495,11 +771,81 @@ func (s *NetworkState) GetEllipticPublicKey() *ec.PublicKey {
 	return s.ellipticPrivateKey.GetPublic()
 }
 
+// SetErrorRedactionPatterns compiles and sets the additional patterns which
+// are redacted from round errors. Must be called before polling begins.
+func (s *NetworkState) SetErrorRedactionPatterns(patterns []string) error {
+	compiled, err := CompileRedactionPatterns(patterns)
+	if err != nil {
+		return err
+	}
+	s.errorRedactions = compiled
+	return nil
+}
+
+// SanitizeRoundError returns a copy of the round error with its text passed
+// through SanitizeError. If nothing was redacted the original is returned
+// unchanged. Otherwise the node's signature no longer covers the text, so the
+// copy is re-signed with the permissioning key and attributed to
+// permissioning, so that it verifies against the key of the ID it carries.
+func (s *NetworkState) SanitizeRoundError(roundError *pb.RoundError) (*pb.RoundError, error) {
+	if roundError == nil {
+		return nil, nil
+	}
+
+	sanitized := SanitizeError(roundError.Error, s.errorRedactions)
+	if sanitized == roundError.Error {
+		return roundError, nil
+	}
+
+	sanitizedError := &pb.RoundError{
+		Id:     roundError.Id,
+		NodeId: id.Permissioning.Marshal(),
+		Error:  sanitized,
+	}
+	err := signature.SignRsa(sanitizedError, s.rsaPrivateKey)
+	if err != nil {
+		return nil, errors.Errorf("Failed to sign sanitized round "+
+			"error: %+v", err)
+	}
+	return sanitizedError, nil
+}
+
 // GetRoundMap returns the map of rounds.
 func (s *NetworkState) GetRoundMap() *round.StateMap {
 	return s.rounds
 }
 
+// GetRoundStateCounts returns the number of members of the round's team in
+// each round state, going by the activity each last reported. Members no
+// longer assigned to the round, or in an activity with no round state, are not
+// counted, so the counts can be compared against the size of the team to find
+// the members holding up a transition.
+func (s *NetworkState) GetRoundStateCounts(roundId id.Round) ([states.NUM_STATES]uint32, error) {
+	var counts [states.NUM_STATES]uint32
+	r, exists := s.rounds.GetRound(roundId)
+	if !exists {
+		return counts, errors.Errorf("Round %d is not in progress", roundId)
+	}
+
+	topology := r.GetTopology()
+	for i := 0; i < topology.Len(); i++ {
+		n := s.nodes.GetNode(topology.GetNodeAtIndex(i))
+		if n == nil {
+			continue
+		}
+		inRound, nodeRound := n.GetCurrentRound()
+		if !inRound || nodeRound.GetRoundID() != roundId {
+			continue
+		}
+		roundState, err := n.GetActivity().ConvertToRoundState()
+		if err != nil {
+			continue
+		}
+		counts[roundState]++
+	}
+	return counts, nil
+}
+
 // GetNodeMap returns the map of nodes.
 func (s *NetworkState) GetNodeMap() *node.StateMap {
 	return s.nodes
@@ -518,14 +864,35 @@ func (s *NetworkState) SetAddressSpaceSize(size uint32) {
 // NodeUpdateNotification sends a notification to the control thread of an
 // update to a nodes state.
 func (s *NetworkState) SendUpdateNotification(nun node.UpdateNotification) error {
+	// The node's polling lock is handed off with the update, and released
+	// here if the update cannot be sent
+	var n *node.State
+	if nodes := s.GetNodeMap(); nodes != nil {
+		n = nodes.GetNode(nun.Node)
+	}
+	if n != nil {
+		nun.PollingHold = n.HandOffPollingLock(
+			time.Duration(atomic.LoadInt64(&s.pollingLockTimeout)))
+	}
+
 	select {
 	case s.update <- nun:
 		return nil
 	default:
+		if n != nil && nun.PollingHold != 0 {
+			n.ReleasePollingLock(nun.PollingHold)
+		}
 		return errors.New("Could not send update notification")
 	}
 }
 
+// SetPollingLockTimeout sets the longest a node's polling lock is held once
+// handed off with its update before it is released, in case the update is
+// never handled. Zero holds it until the update is handled.
+func (s *NetworkState) SetPollingLockTimeout(timeout time.Duration) {
+	atomic.StoreInt64(&s.pollingLockTimeout, int64(timeout))
+}
+
 // GetNodeUpdateChannel returns a channel to receive node updates on.
 func (s *NetworkState) GetNodeUpdateChannel() <-chan node.UpdateNotification {
 	return s.update
@@ -588,6 +955,14 @@ func (s *NetworkState) IncrementRoundID() (id.Round, error) {
 	return oldRoundID, s.setId(RoundIdKey, uint64(s.roundID))
 }
 
+// GetNextRoundID returns the round ID the next call to IncrementRoundID
+// returns, without incrementing it
+// THIS IS NOT THREAD SAFE. IT IS INTENDED TO ONLY BE CALLED BY THE SERIAL
+// SCHEDULING THREAD
+func (s *NetworkState) GetNextRoundID() id.Round {
+	return s.roundID
+}
+
 // IncrementUpdateID increments the update ID
 // THIS IS NOT THREAD SAFE. IT IS INTENDED TO ONLY BE CALLED BY THE SERIAL
 // SCHEDULING THREAD
@@ -624,16 +999,3 @@ func (s *NetworkState) CreateDisabledNodes(path string, interval time.Duration)
 func (s *NetworkState) StartPollDisabledNodes(quitChan chan struct{}) {
 	s.disabledNodesStates.pollDisabledNodes(quitChan)
 }
-
-// outputNodeTopologyToJSON encodes the NodeTopology structure to JSON and
-// outputs it to the specified file path. An error is returned if the JSON
-// marshaling fails or if the JSON file cannot be created.
-func outputToJSON(ndfData *ndf.NetworkDefinition, filePath string) error {
-	// Generate JSON from structure
-	data, err := ndfData.Marshal()
-	if err != nil {
-		return err
-	}
-	// Write JSON to file
-	return utils.WriteFile(filePath, data, utils.FilePerms, utils.DirPerms)
-}
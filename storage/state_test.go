@@ -21,6 +21,7 @@ import (
 	"gitlab.com/elixxir/primitives/states"
 	"gitlab.com/elixxir/registration/storage/node"
 	"gitlab.com/elixxir/registration/storage/round"
+	"gitlab.com/xx_network/comms/connect"
 	"gitlab.com/xx_network/comms/signature"
 	"gitlab.com/xx_network/crypto/signature/rsa"
 	"gitlab.com/xx_network/primitives/id"
@@ -185,6 +186,7 @@ func TestNetworkState_GetUpdates(t *testing.T) {
 
 		expectedRoundInfo = append(expectedRoundInfo, roundInfo)
 	}
+	state.refreshPollSnapshot()
 
 	// Test GetUpdates()
 	roundInfo, err := state.GetUpdates(2)
@@ -255,6 +257,77 @@ func TestNetworkState_AddRoundUpdate(t *testing.T) {
 	}
 }
 
+// Tests that AddRoundUpdate() carries the complete topology of a multi-node
+// round into the signed update, unaffected by later changes to the original.
+func TestNetworkState_AddRoundUpdate_Topology(t *testing.T) {
+	var err error
+	PermissioningDb, _, err = NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("Failed to create database: %+v", err)
+	}
+	state, privateKey, err := generateTestNetworkState()
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	topology := make([][]byte, 5)
+	expected := make([][]byte, len(topology))
+	for i := range topology {
+		topology[i] = id.NewIdFromUInt(uint64(i+1), id.Node, t).Marshal()
+		expected[i] = id.NewIdFromUInt(uint64(i+1), id.Node, t).Marshal()
+	}
+	ri := &pb.RoundInfo{
+		ID:         0,
+		State:      uint32(states.PRECOMPUTING),
+		Topology:   topology,
+		Timestamps: make([]uint64, states.NUM_STATES),
+	}
+	if err = state.AddRoundUpdate(ri); err != nil {
+		t.Fatalf("AddRoundUpdate() unexpectedly produced an error: %+v", err)
+	}
+	topology[0][0] ^= 0xFF
+	topology[4] = nil
+	time.Sleep(100 * time.Millisecond)
+
+	updates, err := state.GetUpdates(0)
+	if err != nil || len(updates) != 1 {
+		t.Fatalf("GetUpdates() returned %d updates: %+v", len(updates), err)
+	}
+	if !reflect.DeepEqual(updates[0].Topology, expected) {
+		t.Errorf("Topology did not survive the round update intact."+
+			"\n\texpected: %v\n\treceived: %v", expected, updates[0].Topology)
+	}
+	if err = signature.VerifyRsa(updates[0], privateKey.GetPublic()); err != nil {
+		t.Errorf("Failed to verify RoundInfo signature: %+v", err)
+	}
+}
+
+// Tests that AddRoundUpdate() rejects topologies with invalid or repeated
+// members.
+func TestNetworkState_AddRoundUpdate_InvalidTopology(t *testing.T) {
+	var err error
+	PermissioningDb, _, err = NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("Failed to create database: %+v", err)
+	}
+	state, _, err := generateTestNetworkState()
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	nid := id.NewIdFromUInt(1, id.Node, t).Marshal()
+	for name, topology := range map[string][][]byte{
+		"invalid":  {nid, []byte("short")},
+		"repeated": {nid, nid},
+	} {
+		err = state.AddRoundUpdate(&pb.RoundInfo{ID: 1, Topology: topology,
+			Timestamps: make([]uint64, states.NUM_STATES)})
+		if err == nil {
+			t.Errorf("AddRoundUpdate() accepted a topology with %s members.", name)
+		}
+	}
+}
+
 // Tests that UpdateInternalNdf() updates fullNdf and partialNdf correctly.
 func TestNetworkState_UpdateOutputNdf(t *testing.T) {
 	// Expected values
@@ -345,6 +418,57 @@ func TestNetworkState_UpdateOutputNdf(t *testing.T) {
 	}
 }
 
+// Tests that the partial NDF publishes the public address of dual address
+// nodes and no address for single address nodes, while the full NDF publishes
+// the inter-node address of both.
+func TestNetworkState_UpdateOutputNdf_PublicAddresses(t *testing.T) {
+	var err error
+	PermissioningDb, _, err = NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("Failed to create database: %+v", err)
+	}
+	state, _, err := generateTestNetworkState()
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	dualId := id.NewIdFromString("dual", id.Node, t)
+	singleId := id.NewIdFromString("single", id.Node, t)
+	def := &ndf.NetworkDefinition{}
+	for _, nid := range []*id.ID{dualId, singleId} {
+		if err = state.GetNodeMap().AddNode(nid, "", "10.0.0.1:11420", "", 0); err != nil {
+			t.Fatalf("Failed to add node: %+v", err)
+		}
+		def.Nodes = append(def.Nodes, ndf.Node{ID: nid.Marshal(),
+			Address: "10.0.0.1:11420"})
+		gwId := nid.DeepCopy()
+		gwId.SetType(id.Gateway)
+		def.Gateways = append(def.Gateways, ndf.Gateway{ID: gwId.Marshal()})
+	}
+	state.GetNodeMap().GetNode(dualId).SetPublicAddress("1.2.3.4:11420")
+
+	state.UpdateInternalNdf(def)
+	if err = state.UpdateOutputNdf(); err != nil {
+		t.Fatalf("UpdateOutputNdf() produced an error: %+v", err)
+	}
+
+	for i, n := range state.GetFullNdf().Get().Nodes {
+		if n.Address != "10.0.0.1:11420" {
+			t.Errorf("Full NDF node %d has address %q instead of its "+
+				"inter-node address.", i, n.Address)
+		}
+	}
+	partial := state.GetPartialNdf().Get().Nodes
+	if partial[0].Address != "1.2.3.4:11420" {
+		t.Errorf("Partial NDF has address %q for the dual address node.",
+			partial[0].Address)
+	}
+	if partial[1].Address != "" {
+		t.Errorf("Partial NDF has address %q for the single address node.",
+			partial[1].Address)
+	}
+}
+
 // Tests that UpdateInternalNdf() generates an error when injected with invalid private
 // key.
 func TestNetworkState_UpdateOutputNdf_SignError(t *testing.T) {
@@ -394,6 +518,159 @@ func TestNetworkState_GetPrivateKey(t *testing.T) {
 	}
 }
 
+// Tests that SanitizeRoundError() redacts the error text and re-signs the
+// sanitized copy with the permissioning key under the permissioning ID,
+// leaving the original untouched.
+func TestNetworkState_SanitizeRoundError(t *testing.T) {
+	var err error
+	PermissioningDb, _, err = NewDatabase("", "", "", "", "")
+	if err != nil {
+		t.Fatalf("Failed to create database: %+v", err)
+	}
+
+	state, privateKey, err := generateTestNetworkState()
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	err = state.SetErrorRedactionPatterns([]string{`gpu\d+`})
+	if err != nil {
+		t.Fatalf("Failed to set redaction patterns: %+v", err)
+	}
+
+	rawText := "gpu0 failed reading /dev/nvidia0 from 8.8.8.8"
+	roundError := &pb.RoundError{
+		Id:     5,
+		NodeId: id.NewIdFromString("node", id.Node, t).Marshal(),
+		Error:  rawText,
+	}
+
+	sanitized, err := state.SanitizeRoundError(roundError)
+	if err != nil {
+		t.Fatalf("SanitizeRoundError() returned an error: %+v", err)
+	}
+
+	expected := SanitizeError(rawText, state.errorRedactions)
+	if sanitized.Error != expected {
+		t.Errorf("Unexpected sanitized error.\nexpected: %q\nreceived: %q",
+			expected, sanitized.Error)
+	}
+	if strings.Contains(sanitized.Error, "gpu0") ||
+		strings.Contains(sanitized.Error, "8.8.8.8") {
+		t.Errorf("Sensitive content not redacted: %q", sanitized.Error)
+	}
+	if roundError.Error != rawText {
+		t.Errorf("SanitizeRoundError() modified the original error.")
+	}
+	if sanitized.Id != roundError.Id {
+		t.Errorf("SanitizeRoundError() did not preserve the round.")
+	}
+	if !bytes.Equal(sanitized.NodeId, id.Permissioning.Marshal()) {
+		t.Errorf("Sanitized error is not attributed to permissioning: %v",
+			sanitized.NodeId)
+	}
+
+	err = signature.VerifyRsa(sanitized, privateKey.GetPublic())
+	if err != nil {
+		t.Errorf("Sanitized error not signed by permissioning: %+v", err)
+	}
+}
+
+// Tests that SanitizeRoundError() returns the original error when there is
+// nothing to redact, and nil for a nil error.
+func TestNetworkState_SanitizeRoundError_Unchanged(t *testing.T) {
+	var err error
+	PermissioningDb, _, err = NewDatabase("", "", "", "", "")
+	if err != nil {
+		t.Fatalf("Failed to create database: %+v", err)
+	}
+
+	state, _, err := generateTestNetworkState()
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	roundError := &pb.RoundError{Id: 5, Error: "precomputation failed"}
+	sanitized, err := state.SanitizeRoundError(roundError)
+	if err != nil {
+		t.Fatalf("SanitizeRoundError() returned an error: %+v", err)
+	}
+	if sanitized != roundError {
+		t.Errorf("SanitizeRoundError() did not return the original error.")
+	}
+
+	sanitized, err = state.SanitizeRoundError(nil)
+	if err != nil || sanitized != nil {
+		t.Errorf("SanitizeRoundError() did not handle a nil error: %v, %+v",
+			sanitized, err)
+	}
+}
+
+// Tests that GetRoundStateCounts() counts the members of a round's team in
+// each state as they advance, leaving out members not in the round.
+func TestNetworkState_GetRoundStateCounts(t *testing.T) {
+	var err error
+	PermissioningDb, _, err = NewDatabase("", "", t.Name(), "", "")
+	if err != nil {
+		t.Fatalf("Failed to create database: %+v", err)
+	}
+	state, _, err := generateTestNetworkState()
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	team := make([]*id.ID, 4)
+	for i := range team {
+		team[i] = id.NewIdFromUInt(uint64(i+1), id.Node, t)
+		if err = state.GetNodeMap().AddNode(team[i], "", "", "", 0); err != nil {
+			t.Fatalf("Failed to add node: %+v", err)
+		}
+	}
+	roundId := id.Round(5)
+	r, err := state.GetRoundMap().AddRound(roundId, 32, 8, time.Minute,
+		connect.NewCircuit(team))
+	if err != nil {
+		t.Fatalf("Failed to add round: %+v", err)
+	}
+	if err = r.Update(states.PRECOMPUTING, time.Now()); err != nil {
+		t.Fatalf("Failed to update round: %+v", err)
+	}
+
+	// The last member of the team is not in the round
+	for i, activity := range []current.Activity{current.STANDBY,
+		current.STANDBY, current.PRECOMPUTING} {
+		err = state.GetNodeMap().GetNode(team[i]).ResumeRound(r, activity)
+		if err != nil {
+			t.Fatalf("Failed to place node in round: %+v", err)
+		}
+	}
+
+	var expected [states.NUM_STATES]uint32
+	expected[states.PRECOMPUTING], expected[states.STANDBY] = 1, 2
+	counts, err := state.GetRoundStateCounts(roundId)
+	if err != nil {
+		t.Fatalf("GetRoundStateCounts() returned an error: %+v", err)
+	}
+	if counts != expected {
+		t.Errorf("Unexpected state counts.\nexpected: %v\nreceived: %v",
+			expected, counts)
+	}
+
+	_, _, err = state.GetNodeMap().GetNode(team[2]).Update(current.STANDBY)
+	if err != nil {
+		t.Fatalf("Failed to advance node: %+v", err)
+	}
+	expected[states.PRECOMPUTING], expected[states.STANDBY] = 0, 3
+	if counts, _ = state.GetRoundStateCounts(roundId); counts != expected {
+		t.Errorf("Unexpected state counts after advancing a node."+
+			"\nexpected: %v\nreceived: %v", expected, counts)
+	}
+
+	if _, err = state.GetRoundStateCounts(roundId + 1); err == nil {
+		t.Errorf("Expected an error for a round not in progress.")
+	}
+}
+
 // Tests that GetRoundMap() returns the correct round StateMap.
 func TestNetworkState_GetRoundMap(t *testing.T) {
 	// Generate new NetworkState
@@ -502,6 +779,61 @@ func TestNetworkState_NodeUpdateNotification_Error(t *testing.T) {
 	time.Sleep(1 * time.Second)
 }
 
+// Tests that the polling lock handed off with an update which cannot be sent
+// is released, and that the polling lock of a sent update is released by its
+// watchdog if the update is not handled within the polling lock timeout.
+func TestNetworkState_SendUpdateNotification_PollingLock(t *testing.T) {
+	state, _, err := generateTestNetworkState()
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	state.SetPollingLockTimeout(20 * time.Millisecond)
+	nid := id.NewIdFromString("node", id.Node, t)
+	if err = state.GetNodeMap().AddNode(nid, "", "", "", 0); err != nil {
+		t.Fatalf("Failed to add node: %+v", err)
+	}
+	n := state.GetNodeMap().GetNode(nid)
+	nun := node.UpdateNotification{Node: nid, FromActivity: current.NOT_STARTED,
+		ToActivity: current.WAITING}
+	lockWithin := func(timeout time.Duration) bool {
+		locked := make(chan struct{})
+		go func() {
+			n.GetPollingLock().Lock()
+			close(locked)
+		}()
+		select {
+		case <-locked:
+			return true
+		case <-time.After(timeout):
+			return false
+		}
+	}
+
+	// Sent and never handled
+	n.GetPollingLock().Lock()
+	if err = state.SendUpdateNotification(nun); err != nil {
+		t.Fatalf("Failed to send update: %+v", err)
+	}
+	if sent := <-state.update; sent.PollingHold == 0 {
+		t.Errorf("Update was sent without the hold of the polling lock.")
+	}
+	if !lockWithin(time.Second) {
+		t.Fatalf("Polling lock of an unhandled update was not released.")
+	}
+
+	// Dropped
+	for i := 0; i < updateBufferLength; i++ {
+		state.update <- nun
+	}
+	state.SetPollingLockTimeout(0)
+	if err = state.SendUpdateNotification(nun); err == nil {
+		t.Fatalf("Update was sent to a full channel.")
+	}
+	if !lockWithin(50 * time.Millisecond) {
+		t.Errorf("Polling lock of a dropped update was not released.")
+	}
+}
+
 // generateTestNetworkState returns a newly generated NetworkState and private
 // key. Errors created by generating the key or NetworkState are returned.
 func generateTestNetworkState() (*NetworkState, *rsa.PrivateKey, error) {
@@ -22,10 +22,40 @@ import (
 // Global variable for Database interaction
 var PermissioningDb Storage
 
+// Suffix, following the number of errors, of the error stored in place of the
+// errors for a round which exceed the limit on stored errors
+const suppressedRoundErrorsSuffix = " additional errors suppressed"
+
 // API for the storage layer
 type Storage struct {
 	// Stored Database interface
 	database
+
+	// Number of attempts and initial backoff for metric writes
+	metricWriteAttempts uint
+	metricWriteBackoff  time.Duration
+
+	// Maximum number of errors stored for a single round, 0 to store every
+	// error
+	roundErrorLimit uint
+
+	// Tracks whether the database can be reached, nil unless the health
+	// monitor is running
+	health *dbHealth
+}
+
+// SetRoundErrorLimit sets the maximum number of errors stored for a single
+// round, both in the round info published to nodes and in the database.
+// Further errors are counted by an aggregate error instead of being stored
+// individually. Zero removes the limit, so every error is stored.
+func (s *Storage) SetRoundErrorLimit(limit uint) {
+	s.roundErrorLimit = limit
+}
+
+// GetRoundErrorLimit returns the maximum number of errors stored for a single
+// round, or 0 if there is no limit.
+func (s *Storage) GetRoundErrorLimit() uint {
+	return s.roundErrorLimit
 }
 
 // Return GeoBins in Map format from Storage
@@ -53,6 +83,46 @@ func (s *Storage) UpdateLastActive(ids []*id.ID) error {
 	return s.updateLastActive(idsBytes, currentTime)
 }
 
+// GetRegistrationRate returns the number of nodes registered per hour at or
+// after start and before end
+func (s *Storage) GetRegistrationRate(start, end time.Time) (float64, error) {
+	if !end.After(start) {
+		return 0, errors.Errorf("Invalid time range: end %s is not after "+
+			"start %s", end, start)
+	}
+	nodes, err := s.GetRegistrationsByTimeRange(start, end)
+	if err != nil {
+		return 0, err
+	}
+	return float64(len(nodes)) / end.Sub(start).Hours(), nil
+}
+
+// ForEachNodePage calls fn with successive pages of at most pageSize of the
+// nodes matching the filter, in registration code order, so that every node
+// can be visited without holding them all in memory. Stops at the first error
+// returned by fn.
+func (s *Storage) ForEachNodePage(filter NodeFilter, pageSize int,
+	fn func(nodes []*Node) error) error {
+	afterCode := ""
+	for {
+		nodes, _, err := s.GetNodesPage(filter, afterCode, pageSize)
+		if err != nil {
+			return errors.WithMessagef(err, "Failed to get nodes after "+
+				"registration code %q", afterCode)
+		}
+		if len(nodes) == 0 {
+			return nil
+		}
+		if err = fn(nodes); err != nil {
+			return err
+		}
+		if len(nodes) < pageSize {
+			return nil
+		}
+		afterCode = nodes[len(nodes)-1].Code
+	}
+}
+
 // Helper for returning a uint64 from the State table
 func (s *Storage) GetStateInt(key string) (uint64, error) {
 	valueStr, err := s.GetStateValue(key)
@@ -68,5 +138,8 @@ func (s *Storage) GetStateInt(key string) (uint64, error) {
 
 // Test use only function for exposing DatabaseImpl
 func (s *Storage) GetDatabaseImpl(t *testing.T) *DatabaseImpl {
+	if m, ok := s.database.(*monitoredDatabase); ok {
+		return m.database.(*DatabaseImpl)
+	}
 	return s.database.(*DatabaseImpl)
 }
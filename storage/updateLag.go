@@ -0,0 +1,142 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Handles measuring how long node updates wait between being produced by a
+// poll and being handled by the scheduler
+
+package storage
+
+import (
+	"gitlab.com/elixxir/registration/storage/node"
+	"sync"
+	"time"
+)
+
+// Lag past which a warning is logged for an update when none is configured
+const DefaultUpdateLagThreshold = time.Second
+
+// Upper bounds of the buckets update lags are counted in. Lags longer than the
+// last bound are counted in a final bucket
+var updateLagBounds = []time.Duration{
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	5 * time.Second,
+}
+
+// updateLag tracks the lags of the node updates handled so far
+type updateLag struct {
+	stats     UpdateLagStats
+	threshold time.Duration
+
+	mux sync.Mutex
+}
+
+// UpdateLagStats describes how long node updates waited to be handled by the
+// scheduler after being produced.
+type UpdateLagStats struct {
+	// Upper bounds of the buckets in Counts
+	Bounds []time.Duration
+	// Number of updates whose lag was at most the matching bound and above
+	// the previous one. The last count holds the lags above every bound
+	Counts []uint64
+
+	// Number of updates handled, their total lag and the longest lag
+	Handled uint64
+	Total   time.Duration
+	Max     time.Duration
+
+	// Number of updates still queued when the last update was dequeued, and
+	// the most that were queued when any update was dequeued
+	LastQueueLength int
+	MaxQueueLength  int
+}
+
+// Mean returns the mean lag of the updates handled, or 0 if none were.
+func (uls UpdateLagStats) Mean() time.Duration {
+	if uls.Handled == 0 {
+		return 0
+	}
+	return uls.Total / time.Duration(uls.Handled)
+}
+
+// SetUpdateLagThreshold sets the lag past which a warning is logged for an
+// update. A threshold of 0 uses DefaultUpdateLagThreshold.
+func (s *NetworkState) SetUpdateLagThreshold(threshold time.Duration) {
+	ul := &s.updateLag
+	ul.mux.Lock()
+	defer ul.mux.Unlock()
+	ul.threshold = threshold
+}
+
+// RecordUpdateLag records how long the update waited between being produced
+// and being dequeued at the given time, along with the number of updates still
+// queued. A warning is logged if the lag exceeds the threshold. Updates
+// without a creation time are ignored.
+func (s *NetworkState) RecordUpdateLag(update node.UpdateNotification,
+	now time.Time) {
+	if update.Created.IsZero() {
+		return
+	}
+	lag := now.Sub(update.Created)
+	queued := len(s.update)
+
+	ul := &s.updateLag
+	ul.mux.Lock()
+	threshold := ul.threshold
+	if threshold == 0 {
+		threshold = DefaultUpdateLagThreshold
+	}
+	ul.stats.add(lag, queued)
+	ul.mux.Unlock()
+
+	if lag > threshold {
+		s.loggers().WARN.Printf("Update of node %s to %s was handled %s after it was "+
+			"produced, past the threshold of %s, with %d updates queued",
+			update.Node, update.ToActivity, lag, threshold, queued)
+	}
+}
+
+// GetUpdateLagStats returns the lags of the node updates handled so far.
+func (s *NetworkState) GetUpdateLagStats() UpdateLagStats {
+	ul := &s.updateLag
+	ul.mux.Lock()
+	defer ul.mux.Unlock()
+
+	stats := ul.stats
+	stats.Bounds = append([]time.Duration{}, updateLagBounds...)
+	stats.Counts = make([]uint64, len(updateLagBounds)+1)
+	copy(stats.Counts, ul.stats.Counts)
+	return stats
+}
+
+// add counts the lag in its bucket and records the queue length.
+func (uls *UpdateLagStats) add(lag time.Duration, queued int) {
+	if uls.Counts == nil {
+		uls.Counts = make([]uint64, len(updateLagBounds)+1)
+	}
+	bucket := len(updateLagBounds)
+	for i, bound := range updateLagBounds {
+		if lag <= bound {
+			bucket = i
+			break
+		}
+	}
+	uls.Counts[bucket]++
+
+	uls.Handled++
+	uls.Total += lag
+	if lag > uls.Max {
+		uls.Max = lag
+	}
+	uls.LastQueueLength = queued
+	if queued > uls.MaxQueueLength {
+		uls.MaxQueueLength = queued
+	}
+}
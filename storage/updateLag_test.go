@@ -0,0 +1,86 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package storage
+
+import (
+	"gitlab.com/elixxir/primitives/current"
+	"gitlab.com/elixxir/registration/storage/node"
+	"gitlab.com/xx_network/primitives/id"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Tests that the lag of an update handled by a delayed consumer is measured,
+// counted in its bucket with the queue length at dequeue, and warned about
+// once it exceeds the threshold.
+func TestNetworkState_RecordUpdateLag(t *testing.T) {
+	state := &NetworkState{update: make(chan node.UpdateNotification, 10)}
+	state.SetUpdateLagThreshold(20 * time.Millisecond)
+	nodeMap := node.NewStateMap()
+	for i := uint64(0); i < 2; i++ {
+		nid := id.NewIdFromUInt(i, id.Node, t)
+		if err := nodeMap.AddNode(nid, "", "", "", 0); err != nil {
+			t.Fatalf("Failed to add node: %+v", err)
+		}
+		_, nun, err := nodeMap.GetNode(nid).Update(current.WAITING)
+		if err != nil {
+			t.Fatalf("Failed to update node: %+v", err)
+		}
+		if nun.Created.IsZero() {
+			t.Fatalf("Update was not timestamped when produced.")
+		}
+		if err = state.SendUpdateNotification(nun); err != nil {
+			t.Fatalf("Failed to send update: %+v", err)
+		}
+	}
+	buf := captureInfoLogs(state)
+
+	// The consumer falls behind the polls
+	delay := 50 * time.Millisecond
+	time.Sleep(delay)
+	update := <-state.GetNodeUpdateChannel()
+	now := time.Now()
+	state.RecordUpdateLag(update, now)
+
+	stats := state.GetUpdateLagStats()
+	lag := now.Sub(update.Created)
+	if stats.Handled != 1 || stats.Max != lag || stats.Mean() != lag ||
+		lag < delay {
+		t.Errorf("Unexpected lag measured for an update delayed by %s: %+v",
+			delay, stats)
+	}
+	if stats.LastQueueLength != 1 || stats.MaxQueueLength != 1 {
+		t.Errorf("Unexpected queue length at dequeue: %d, %d",
+			stats.LastQueueLength, stats.MaxQueueLength)
+	}
+	for i, count := range stats.Counts {
+		inBucket := (i == len(stats.Bounds) || lag <= stats.Bounds[i]) &&
+			(i == 0 || lag > stats.Bounds[i-1])
+		if inBucket != (count == 1) {
+			t.Errorf("Lag of %s counted %d times in bucket %d.", lag, count, i)
+		}
+	}
+	if !strings.Contains(buf.String(), update.Node.String()) ||
+		!strings.Contains(buf.String(), current.WAITING.String()) {
+		t.Errorf("No warning logged for the lagging update: %q", buf.String())
+	}
+
+	// Updates within the threshold are counted without a warning
+	buf.Reset()
+	update = <-state.GetNodeUpdateChannel()
+	state.RecordUpdateLag(update, update.Created.Add(time.Millisecond))
+	if stats = state.GetUpdateLagStats(); stats.Handled != 2 ||
+		stats.Counts[0] != 1 || stats.LastQueueLength != 0 {
+		t.Errorf("Unexpected stats after a prompt update: %+v", stats)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("Warning logged for an update within the threshold: %q",
+			buf.String())
+	}
+}
@@ -0,0 +1,131 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Handles estimating how long a waiting node will wait before it is scheduled
+
+package storage
+
+import (
+	"gitlab.com/elixxir/primitives/current"
+	"gitlab.com/elixxir/registration/storage/node"
+	"sync"
+	"time"
+)
+
+// Window the scheduling rate is measured over
+const schedulingRateWindow = 10 * time.Minute
+
+// How long the network-wide scheduling rate and pool size are cached for
+const schedulingRateRefresh = 10 * time.Second
+
+// A team scheduled within the scheduling rate window
+type scheduledTeam struct {
+	time time.Time
+	size int
+}
+
+// schedulingRate tracks the rate nodes are scheduled at across the network
+type schedulingRate struct {
+	// Teams scheduled within the window, oldest first
+	teams []scheduledTeam
+
+	// Set while the scheduler is not creating rounds
+	paused bool
+
+	// Node slots scheduled per second and number of waiting nodes, as of
+	// computed
+	rate     float64
+	waiting  int
+	computed time.Time
+
+	mux sync.Mutex
+}
+
+// RecordScheduledTeam records that a team of the given size was scheduled.
+func (s *NetworkState) RecordScheduledTeam(size int, now time.Time) {
+	sr := &s.schedulingRate
+	sr.mux.Lock()
+	defer sr.mux.Unlock()
+
+	sr.teams = append(sr.teams, scheduledTeam{time: now, size: size})
+	sr.trim(now)
+}
+
+// SetSchedulingPaused records whether the scheduler has paused round creation.
+func (s *NetworkState) SetSchedulingPaused(paused bool) {
+	sr := &s.schedulingRate
+	sr.mux.Lock()
+	defer sr.mux.Unlock()
+	sr.paused = paused
+}
+
+// EstimateWait returns a rough estimate of how long the waiting node has left
+// before it is scheduled, from the number of waiting nodes, the rate nodes
+// were scheduled at over the last schedulingRateWindow and how long the node
+// has been waiting. Returns false if no estimate can be made, because round
+// creation is paused, no rounds were scheduled within the window, or the node
+// is excluded from scheduling. The network-wide values are cached for
+// schedulingRateRefresh.
+func (s *NetworkState) EstimateWait(n *node.State, now time.Time) (time.Duration, bool) {
+	if n.GetStatus() != node.Active || s.IsPruned(n.GetID()) {
+		return 0, false
+	}
+
+	sr := &s.schedulingRate
+	sr.mux.Lock()
+	if sr.paused {
+		sr.mux.Unlock()
+		return 0, false
+	}
+	if now.Sub(sr.computed) >= schedulingRateRefresh {
+		sr.trim(now)
+		slots := 0
+		for _, team := range sr.teams {
+			slots += team.size
+		}
+		sr.rate = float64(slots) / schedulingRateWindow.Seconds()
+		sr.waiting = s.countWaiting()
+		sr.computed = now
+	}
+	rate, waiting := sr.rate, sr.waiting
+	sr.mux.Unlock()
+
+	if rate == 0 {
+		return 0, false
+	}
+
+	// Time to work through the nodes waiting, less the time already waited
+	estimate := time.Duration(float64(waiting)/rate*float64(time.Second)) -
+		now.Sub(n.GetLastUpdate())
+	if estimate < 0 {
+		estimate = 0
+	}
+	return estimate, true
+}
+
+// countWaiting returns the number of nodes waiting to be scheduled
+func (s *NetworkState) countWaiting() int {
+	waiting := 0
+	for _, n := range s.GetNodeMap().GetNodeStates() {
+		if n.GetActivity() == current.WAITING && n.GetStatus() == node.Active &&
+			!s.IsPruned(n.GetID()) {
+			waiting++
+		}
+	}
+	return waiting
+}
+
+// trim drops the teams scheduled before the window. Must be called with the
+// lock held.
+func (sr *schedulingRate) trim(now time.Time) {
+	cutoff := now.Add(-schedulingRateWindow)
+	i := 0
+	for i < len(sr.teams) && sr.teams[i].time.Before(cutoff) {
+		i++
+	}
+	sr.teams = sr.teams[i:]
+}
@@ -0,0 +1,99 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package storage
+
+import (
+	"gitlab.com/elixxir/primitives/current"
+	"gitlab.com/elixxir/registration/storage/node"
+	"gitlab.com/xx_network/primitives/id"
+	"testing"
+	"time"
+)
+
+// Adds waiting nodes to the state and returns them
+func addWaitingNodes(t *testing.T, state *NetworkState, count int) []*node.State {
+	var nodes []*node.State
+	for i := 0; i < count; i++ {
+		nid := id.NewIdFromUInt(uint64(i), id.Node, t)
+		if err := state.GetNodeMap().AddNode(nid, "US", "", "", 0); err != nil {
+			t.Fatalf("Failed to add node: %+v", err)
+		}
+		n := state.GetNodeMap().GetNode(nid)
+		if _, _, err := n.Update(current.WAITING); err != nil {
+			t.Fatalf("Failed to update node: %+v", err)
+		}
+		nodes = append(nodes, n)
+	}
+	return nodes
+}
+
+// Tests that the estimate is the time to work through the waiting nodes at
+// the scheduling rate, and that it shrinks as the node waits.
+func TestNetworkState_EstimateWait(t *testing.T) {
+	state := newNdfHistoryTestState(t, 0, "")
+	nodes := addWaitingNodes(t, state, 10)
+	now := time.Now()
+
+	if _, ok := state.EstimateWait(nodes[0], now); ok {
+		t.Errorf("Estimate made before any team was scheduled.")
+	}
+
+	// 60 slots over the 10 minute window is one node every 10 seconds, so
+	// the 10 waiting nodes are worked through in 100 seconds
+	for i := 0; i < 20; i++ {
+		state.RecordScheduledTeam(3, now.Add(-time.Duration(i)*time.Second))
+	}
+	now = now.Add(schedulingRateRefresh)
+	estimate, ok := state.EstimateWait(nodes[0], now)
+	if !ok {
+		t.Fatalf("No estimate made.")
+	}
+	waited := now.Sub(nodes[0].GetLastUpdate())
+	if expected := 100*time.Second - waited; estimate != expected {
+		t.Errorf("Unexpected estimate.\nexpected: %s\nreceived: %s",
+			expected, estimate)
+	}
+
+	later, ok := state.EstimateWait(nodes[0], now.Add(30*time.Second))
+	if !ok || later >= estimate {
+		t.Errorf("Estimate did not shrink as the node waited: %s then %s",
+			estimate, later)
+	}
+	if last, _ := state.EstimateWait(nodes[0], now.Add(5*time.Minute)); last != 0 {
+		t.Errorf("Estimate not floored at zero: %s", last)
+	}
+}
+
+// Tests that no estimate is made while round creation is paused or for a node
+// excluded from scheduling.
+func TestNetworkState_EstimateWait_Unknown(t *testing.T) {
+	state := newNdfHistoryTestState(t, 0, "")
+	nodes := addWaitingNodes(t, state, 4)
+	now := time.Now()
+	state.RecordScheduledTeam(3, now)
+
+	state.SetSchedulingPaused(true)
+	if _, ok := state.EstimateWait(nodes[0], now); ok {
+		t.Errorf("Estimate made while round creation is paused.")
+	}
+	state.SetSchedulingPaused(false)
+	if _, ok := state.EstimateWait(nodes[0], now); !ok {
+		t.Errorf("No estimate made after round creation resumed.")
+	}
+
+	state.SetPrunedNode(nodes[1].GetID())
+	if _, ok := state.EstimateWait(nodes[1], now); ok {
+		t.Errorf("Estimate made for a pruned node.")
+	}
+
+	// Teams fall out of the window
+	if _, ok := state.EstimateWait(nodes[0],
+		now.Add(schedulingRateWindow+time.Second)); ok {
+		t.Errorf("Estimate made with no team scheduled within the window.")
+	}
+}
@@ -0,0 +1,199 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package transition
+
+import (
+	"encoding/json"
+	"github.com/pkg/errors"
+	"gitlab.com/elixxir/primitives/current"
+	"gitlab.com/elixxir/primitives/states"
+	"gitlab.com/xx_network/primitives/utils"
+	"sort"
+	"strings"
+)
+
+// table.go contains the declarative form of the transition table, so that
+// alternate state machines can be tried without changing newTransitions.
+
+// TransitionRule declares how a node may move into an activity.
+type TransitionRule struct {
+	// Whether the node must be assigned a round in the activity, one of No,
+	// Yes or Maybe
+	NeedsRound int `json:"needsRound"`
+	// Names of the states the round must be in, required if NeedsRound is Yes
+	RoundStates []string `json:"roundStates"`
+	// Names of the activities the node may move into the activity from
+	From []string `json:"from"`
+}
+
+// TransitionTable holds the rule for each activity, keyed by activity name.
+type TransitionTable map[string]TransitionRule
+
+// DefaultTransitionTable returns the table newTransitions is built from, as
+// a starting point for alternate tables.
+func DefaultTransitionTable() TransitionTable {
+	return TransitionTable{
+		"NOT_STARTED": {NeedsRound: No},
+		"WAITING": {NeedsRound: No,
+			From: []string{"NOT_STARTED", "COMPLETED", "ERROR"}},
+		"PRECOMPUTING": {NeedsRound: Yes, RoundStates: []string{"PRECOMPUTING"},
+			From: []string{"WAITING"}},
+		"STANDBY": {NeedsRound: Yes, RoundStates: []string{"PRECOMPUTING"},
+			From: []string{"WAITING", "PRECOMPUTING"}},
+		"REALTIME": {NeedsRound: Yes, RoundStates: []string{"QUEUED", "REALTIME"},
+			From: []string{"STANDBY"}},
+		"COMPLETED": {NeedsRound: Yes, RoundStates: []string{"REALTIME"},
+			From: []string{"REALTIME"}},
+		"ERROR": {NeedsRound: Maybe, From: []string{"NOT_STARTED", "WAITING",
+			"PRECOMPUTING", "STANDBY", "REALTIME", "COMPLETED"}},
+	}
+}
+
+// LoadTransitions reads a JSON encoded TransitionTable from the file at the
+// path and builds the transitions from it.
+func LoadTransitions(path string) (Transitions, error) {
+	data, err := utils.ReadFile(path)
+	if err != nil {
+		return Transitions{}, errors.Errorf("Failed to read transition "+
+			"table %s: %+v", path, err)
+	}
+
+	var table TransitionTable
+	if err = json.Unmarshal(data, &table); err != nil {
+		return Transitions{}, errors.Errorf("Failed to parse transition "+
+			"table %s: %+v", path, err)
+	}
+
+	return NewTransitionsFromTable(table)
+}
+
+// NewTransitionsFromTable builds the transitions from the table, after
+// validating it. Every activity other than CRASH, which nodes are never moved
+// into, must have a rule, and every activity with a rule other than
+// NOT_STARTED must be reachable from NOT_STARTED. Only the activities and
+// round states known to primitives can be named.
+func NewTransitionsFromTable(table TransitionTable) (Transitions, error) {
+	t := Transitions{}
+	defined := make(map[current.Activity]bool, len(table))
+
+	// Sort the names so that errors are reported deterministically
+	names := make([]string, 0, len(table))
+	for name := range table {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		rule := table[name]
+		to, err := parseActivity(name)
+		if err != nil {
+			return Transitions{}, err
+		}
+
+		switch rule.NeedsRound {
+		case No:
+			if len(rule.RoundStates) > 0 {
+				return Transitions{}, errors.Errorf("Activity %s does not "+
+					"need a round but has round states", name)
+			}
+		case Yes:
+			if len(rule.RoundStates) == 0 {
+				return Transitions{}, errors.Errorf("Activity %s needs a "+
+					"round but has no round states", name)
+			}
+		case Maybe:
+		default:
+			return Transitions{}, errors.Errorf("Activity %s has invalid "+
+				"needsRound %d, expected %d, %d or %d", name, rule.NeedsRound,
+				No, Yes, Maybe)
+		}
+
+		roundStates := make([]states.Round, 0, len(rule.RoundStates))
+		for _, stateName := range rule.RoundStates {
+			st, err := parseRoundState(stateName)
+			if err != nil {
+				return Transitions{}, errors.WithMessagef(err,
+					"Invalid round state for activity %s", name)
+			}
+			roundStates = append(roundStates, st)
+		}
+		if len(roundStates) == 0 {
+			roundStates = nil
+		}
+
+		from := make([]current.Activity, 0, len(rule.From))
+		for _, fromName := range rule.From {
+			f, err := parseActivity(fromName)
+			if err != nil {
+				return Transitions{}, errors.WithMessagef(err,
+					"Invalid transition into activity %s", name)
+			}
+			from = append(from, f)
+		}
+
+		t[to] = NewTransitionValidation(rule.NeedsRound, roundStates, from...)
+		defined[to] = true
+	}
+
+	var missing []string
+	for a := current.NOT_STARTED; a < current.CRASH; a++ {
+		if !defined[a] {
+			missing = append(missing, a.String())
+		}
+	}
+	if len(missing) > 0 {
+		return Transitions{}, errors.Errorf("Transition table has no rules "+
+			"for activities %s", strings.Join(missing, ", "))
+	}
+
+	// Walk the transitions out of NOT_STARTED to find unreachable activities
+	reached := [current.NUM_STATES]bool{current.NOT_STARTED: true}
+	queue := []current.Activity{current.NOT_STARTED}
+	for len(queue) > 0 {
+		from := queue[0]
+		queue = queue[1:]
+		for to := current.Activity(0); to < current.NUM_STATES; to++ {
+			if !reached[to] && t.IsValidTransition(to, from) {
+				reached[to] = true
+				queue = append(queue, to)
+			}
+		}
+	}
+	var unreachable []string
+	for a := current.Activity(0); a < current.NUM_STATES; a++ {
+		if defined[a] && !reached[a] {
+			unreachable = append(unreachable, a.String())
+		}
+	}
+	if len(unreachable) > 0 {
+		return Transitions{}, errors.Errorf("Activities %s cannot be reached "+
+			"from %s", strings.Join(unreachable, ", "), current.NOT_STARTED)
+	}
+
+	return t, nil
+}
+
+// parseActivity returns the activity with the given name
+func parseActivity(name string) (current.Activity, error) {
+	for a := current.Activity(0); a < current.NUM_STATES; a++ {
+		if a.String() == name {
+			return a, nil
+		}
+	}
+	return 0, errors.Errorf("Unknown activity %q", name)
+}
+
+// parseRoundState returns the round state with the given name
+func parseRoundState(name string) (states.Round, error) {
+	for st := states.Round(0); st < states.NUM_STATES; st++ {
+		if st.String() == name {
+			return st, nil
+		}
+	}
+	return 0, errors.Errorf("Unknown round state %q", name)
+}
@@ -0,0 +1,158 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package transition
+
+import (
+	"encoding/json"
+	"gitlab.com/elixxir/primitives/current"
+	"gitlab.com/elixxir/primitives/states"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// Tests that the default table builds the same transitions as newTransitions.
+func TestNewTransitionsFromTable_Default(t *testing.T) {
+	received, err := NewTransitionsFromTable(DefaultTransitionTable())
+	if err != nil {
+		t.Fatalf("Failed to build the default table: %+v", err)
+	}
+	if !reflect.DeepEqual(received, newTransitions()) {
+		t.Errorf("Default table does not match newTransitions.\n\t"+
+			"Expected: %+v\n\tReceived: %+v", newTransitions(), received)
+	}
+}
+
+// Tests that a custom table, where STANDBY can only be reached through
+// PRECOMPUTING and REALTIME only starts from a QUEUED round, is followed by
+// IsValidTransition, NeedsRound and IsValidRoundState.
+func TestNewTransitionsFromTable_Custom(t *testing.T) {
+	table := DefaultTransitionTable()
+	table["STANDBY"] = TransitionRule{NeedsRound: Yes,
+		RoundStates: []string{"PRECOMPUTING", "STANDBY"},
+		From:        []string{"PRECOMPUTING"}}
+	table["REALTIME"] = TransitionRule{NeedsRound: Yes,
+		RoundStates: []string{"QUEUED"}, From: []string{"STANDBY"}}
+	table["ERROR"] = TransitionRule{NeedsRound: No,
+		From: []string{"WAITING", "PRECOMPUTING"}}
+
+	custom, err := NewTransitionsFromTable(table)
+	if err != nil {
+		t.Fatalf("Failed to build the custom table: %+v", err)
+	}
+
+	if custom.IsValidTransition(current.STANDBY, current.WAITING) {
+		t.Errorf("STANDBY should not be reachable from WAITING.")
+	}
+	if !custom.IsValidTransition(current.STANDBY, current.PRECOMPUTING) {
+		t.Errorf("STANDBY should be reachable from PRECOMPUTING.")
+	}
+	if custom.IsValidTransition(current.ERROR, current.REALTIME) {
+		t.Errorf("ERROR should not be reachable from REALTIME.")
+	}
+
+	if custom.NeedsRound(current.ERROR) != No {
+		t.Errorf("Unexpected NeedsRound for ERROR: %d", custom.NeedsRound(current.ERROR))
+	}
+	if custom.NeedsRound(current.REALTIME) != Yes {
+		t.Errorf("Unexpected NeedsRound for REALTIME: %d", custom.NeedsRound(current.REALTIME))
+	}
+
+	if !custom.IsValidRoundState(current.STANDBY, states.STANDBY) {
+		t.Errorf("STANDBY round state should be valid for STANDBY.")
+	}
+	if custom.IsValidRoundState(current.REALTIME, states.REALTIME) {
+		t.Errorf("REALTIME round state should not be valid for REALTIME.")
+	}
+	if !custom.IsValidRoundState(current.REALTIME, states.QUEUED) {
+		t.Errorf("QUEUED round state should be valid for REALTIME.")
+	}
+}
+
+// Tests that malformed tables are rejected.
+func TestNewTransitionsFromTable_Malformed(t *testing.T) {
+	tests := map[string]func(TransitionTable){
+		"unknown activity": func(table TransitionTable) {
+			table["VERIFYING"] = TransitionRule{NeedsRound: No,
+				From: []string{"PRECOMPUTING"}}
+		},
+		"unknown from activity": func(table TransitionTable) {
+			table["STANDBY"] = TransitionRule{NeedsRound: Yes,
+				RoundStates: []string{"PRECOMPUTING"},
+				From:        []string{"VERIFYING"}}
+		},
+		"unknown round state": func(table TransitionTable) {
+			table["STANDBY"] = TransitionRule{NeedsRound: Yes,
+				RoundStates: []string{"VERIFYING"}, From: []string{"WAITING"}}
+		},
+		"missing activity": func(table TransitionTable) {
+			delete(table, "COMPLETED")
+		},
+		"unreachable activity": func(table TransitionTable) {
+			table["WAITING"] = TransitionRule{NeedsRound: No,
+				From: []string{"COMPLETED", "ERROR"}}
+			table["ERROR"] = TransitionRule{NeedsRound: Maybe,
+				From: []string{"WAITING", "PRECOMPUTING"}}
+		},
+		"invalid needsRound": func(table TransitionTable) {
+			table["WAITING"] = TransitionRule{NeedsRound: 3,
+				From: []string{"NOT_STARTED"}}
+		},
+		"round needed without round states": func(table TransitionTable) {
+			table["STANDBY"] = TransitionRule{NeedsRound: Yes,
+				From: []string{"WAITING"}}
+		},
+		"round states without round": func(table TransitionTable) {
+			table["WAITING"] = TransitionRule{NeedsRound: No,
+				RoundStates: []string{"PENDING"}, From: []string{"NOT_STARTED"}}
+		},
+	}
+
+	for name, malform := range tests {
+		table := DefaultTransitionTable()
+		malform(table)
+		if _, err := NewTransitionsFromTable(table); err == nil {
+			t.Errorf("Table with %s was not rejected.", name)
+		}
+	}
+}
+
+// Tests that LoadTransitions builds the transitions from a JSON file and
+// rejects files which cannot be parsed.
+func TestLoadTransitions(t *testing.T) {
+	dir := t.TempDir()
+
+	data, err := json.Marshal(DefaultTransitionTable())
+	if err != nil {
+		t.Fatalf("Failed to marshal table: %+v", err)
+	}
+	path := filepath.Join(dir, "transitions.json")
+	if err = os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("Failed to write table: %+v", err)
+	}
+
+	loaded, err := LoadTransitions(path)
+	if err != nil {
+		t.Fatalf("Failed to load table: %+v", err)
+	}
+	if !reflect.DeepEqual(loaded, newTransitions()) {
+		t.Errorf("Loaded table does not match newTransitions.")
+	}
+
+	badPath := filepath.Join(dir, "bad.json")
+	if err = os.WriteFile(badPath, []byte("{"), 0644); err != nil {
+		t.Fatalf("Failed to write table: %+v", err)
+	}
+	if _, err = LoadTransitions(badPath); err == nil {
+		t.Errorf("Invalid JSON was not rejected.")
+	}
+	if _, err = LoadTransitions(filepath.Join(dir, "missing.json")); err == nil {
+		t.Errorf("Missing file was not rejected.")
+	}
+}